@@ -9,6 +9,7 @@ Every conversation stored automatically. Shard graduation autonomous.
 Modules:
 - memory: Core storage — conversations, FTS5 search, sessions
 - server: Unix socket daemon for Go↔Python IPC
+- embed_onnx: ONNX sentence encoder helper for Go semantic memory
 - shard: Autonomous shard graduation → training queue
 """
 
@@ -0,0 +1,83 @@
+"""
+LIMPHA ONNX EMBEDDER — sentence encoder helper for Go's ONNXEmbedder.
+
+Go has no onnxruntime without cgo bindings, so the encoder runs here.
+Communication: JSON lines over stdin/stdout, one request per line.
+
+Protocol:
+    → {"text": "..."}
+    ← {"embedding": [...]}
+    ← {"error": "..."}
+
+Needs: onnxruntime, tokenizers, numpy.
+Expects tokenizer.json next to the .onnx file (sentence-transformers export).
+Output: mean-pooled last hidden state over the attention mask.
+"""
+
+import json
+import sys
+from pathlib import Path
+
+
+def load_encoder(model_path: str):
+    """Load the ONNX session and its tokenizer."""
+    import numpy as np
+    import onnxruntime as ort
+    from tokenizers import Tokenizer
+
+    tok_path = Path(model_path).with_name("tokenizer.json")
+    tokenizer = Tokenizer.from_file(str(tok_path))
+    tokenizer.enable_truncation(max_length=256)
+
+    session = ort.InferenceSession(model_path, providers=["CPUExecutionProvider"])
+    input_names = {i.name for i in session.get_inputs()}
+
+    def embed(text: str):
+        enc = tokenizer.encode(text)
+        ids = np.array([enc.ids], dtype=np.int64)
+        mask = np.array([enc.attention_mask], dtype=np.int64)
+        feed = {"input_ids": ids, "attention_mask": mask}
+        if "token_type_ids" in input_names:
+            feed["token_type_ids"] = np.zeros_like(ids)
+        hidden = session.run(None, feed)[0]  # [1, seq, dim]
+        weights = mask[..., None].astype(np.float32)
+        pooled = (hidden * weights).sum(axis=1) / np.maximum(weights.sum(axis=1), 1e-9)
+        return pooled[0].astype(float).tolist()
+
+    return embed
+
+
+def main():
+    """Entry point: python3 -m limpha.embed_onnx --model PATH"""
+    args = sys.argv[1:]
+    model_path = None
+    if "--model" in args and args.index("--model") + 1 < len(args):
+        model_path = args[args.index("--model") + 1]
+
+    try:
+        if not model_path:
+            raise ValueError("--model is required")
+        embed = load_encoder(model_path)
+    except Exception as e:
+        # Answer every request with the load error so Go sees why
+        embed = None
+        load_error = str(e)
+        print(f"[embed_onnx] {load_error}", file=sys.stderr, flush=True)
+
+    for line in sys.stdin:
+        line = line.strip()
+        if not line:
+            continue
+        try:
+            if embed is None:
+                raise RuntimeError(load_error)
+            msg = json.loads(line)
+            resp = {"embedding": embed(msg.get("text", ""))}
+        except Exception as e:
+            resp = {"error": str(e)}
+        sys.stdout.write(json.dumps(resp) + "\n")
+        sys.stdout.flush()
+
+
+if __name__ == "__main__":
+    main()
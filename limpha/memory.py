@@ -9,11 +9,20 @@ Tables:
 - sessions: Session metadata
 - shards: Graduated episodes for delta training
 
+Store collapses repeats: an exact duplicate pair (after normalizing case,
+whitespace and punctuation) or the same prompt with a near-identical
+response, both within DEDUP_WINDOW, bumps repeat_count on the existing row
+instead of inserting a new one. Bots retry; Telegram resends. The same
+question asked again tomorrow is a new turn.
+
 All operations async via aiosqlite.
 """
 
 import asyncio
 import aiosqlite
+import hashlib
+import json
+import re
 import time
 import uuid
 from dataclasses import dataclass
@@ -37,9 +46,14 @@ class Conversation:
     debt: float
     velocity: int
     alpha: float
+    entropy: float
+    language: str
     # Computed
     quality: float
     access_count: int
+    repeat_count: int = 1
+    # Full AMK field before/after the answer ({"before": ..., "after": ...})
+    amk: Optional[Dict[str, Any]] = None
 
 
 @dataclass
@@ -71,9 +85,16 @@ CREATE TABLE IF NOT EXISTS conversations (
     debt REAL DEFAULT 0.0,
     velocity INTEGER DEFAULT 1,
     alpha REAL DEFAULT 0.0,
+    entropy REAL DEFAULT 0.0,  -- mean sampling entropy (nats/token)
+    language TEXT DEFAULT '',  -- detected prompt language (ISO 639-1, '' = unknown)
+    amk TEXT DEFAULT '',       -- full AMK field before/after the answer (JSON, '' = not sent)
     -- Computed quality
     quality REAL DEFAULT 0.5,
-    access_count INTEGER DEFAULT 0
+    access_count INTEGER DEFAULT 0,
+    -- Dedup: normalized pair hash, times seen, last time seen
+    fingerprint TEXT DEFAULT '',
+    repeat_count INTEGER DEFAULT 1,
+    last_seen REAL DEFAULT 0.0
 );
 
 CREATE INDEX IF NOT EXISTS idx_conv_timestamp ON conversations(timestamp DESC);
@@ -133,6 +154,62 @@ CREATE INDEX IF NOT EXISTS idx_shards_status ON shards(training_status);
 CREATE INDEX IF NOT EXISTS idx_shards_graduated ON shards(graduated_at DESC);
 """
 
+# Columns added after the first release: (table, column, DDL type).
+# CREATE TABLE IF NOT EXISTS won't touch old databases, so connect() adds them.
+MIGRATIONS = [
+    ("conversations", "entropy", "REAL DEFAULT 0.0"),
+    ("conversations", "fingerprint", "TEXT DEFAULT ''"),
+    ("conversations", "repeat_count", "INTEGER DEFAULT 1"),
+    ("conversations", "last_seen", "REAL DEFAULT 0.0"),
+    ("conversations", "language", "TEXT DEFAULT ''"),
+    ("conversations", "amk", "TEXT DEFAULT ''"),
+]
+
+# Indexes over migrated columns, created after MIGRATIONS have run
+MIGRATION_INDEXES = [
+    "CREATE INDEX IF NOT EXISTS idx_conv_fingerprint ON conversations(fingerprint)",
+]
+
+_PUNCT = re.compile(r"[^\w\s]", re.UNICODE)
+
+
+def _normalize(text: str) -> str:
+    """Lowercase, drop punctuation, collapse whitespace."""
+    return " ".join(_PUNCT.sub(" ", text.lower()).split())
+
+
+def fingerprint(prompt: str, response: str) -> str:
+    """Hash of the normalized pair: equal for exact duplicates."""
+    key = _normalize(prompt) + "\x00" + _normalize(response)
+    return hashlib.sha1(key.encode("utf-8")).hexdigest()
+
+
+def _shingles(text: str, n: int = 3) -> set:
+    """Word n-grams of normalized text (the words themselves if shorter)."""
+    words = _normalize(text).split()
+    if len(words) < n:
+        return {" ".join(words)}
+    return {" ".join(words[i:i + n]) for i in range(len(words) - n + 1)}
+
+
+def _similarity(a: str, b: str) -> float:
+    """Jaccard similarity of word 3-gram shingles."""
+    sa, sb = _shingles(a), _shingles(b)
+    if not sa or not sb:
+        return 0.0
+    return len(sa & sb) / len(sa | sb)
+
+
+def _conversation(row) -> Dict[str, Any]:
+    """A conversations row as a dict, its stored AMK field decoded (None if absent)."""
+    d = dict(row)
+    if "amk" in d:
+        try:
+            d["amk"] = json.loads(d["amk"]) if d["amk"] else None
+        except ValueError:
+            d["amk"] = None
+    return d
+
 
 class LimphaMemory:
     """
@@ -150,13 +227,18 @@ class LimphaMemory:
     SHARD_MIN_ACCESS = 3
     SHARD_MIN_COHERENCE = 0.3
 
-    def __init__(self, db_path: Optional[str] = None):
+    # Dedup: same prompt + response this similar within the window = repeat
+    DEDUP_WINDOW = 3600.0
+    DEDUP_SIMILARITY = 0.9
+
+    def __init__(self, db_path: Optional[str] = None, dedup: bool = True):
         if db_path is None:
             db_path = str(Path.home() / ".yent" / "limpha.db")
         self.db_path = Path(db_path)
         self.db_path.parent.mkdir(parents=True, exist_ok=True)
         self._conn: Optional[aiosqlite.Connection] = None
         self._session_id: str = str(uuid.uuid4())[:8]
+        self.dedup = dedup
 
     async def __aenter__(self):
         await self.connect()
@@ -173,6 +255,7 @@ class LimphaMemory:
         await self._conn.execute("PRAGMA journal_mode=WAL")
         await self._conn.execute("PRAGMA synchronous=NORMAL")
         await self._conn.executescript(SCHEMA)
+        await self._migrate()
         await self._conn.commit()
         # Start session
         now = time.time()
@@ -182,6 +265,16 @@ class LimphaMemory:
         )
         await self._conn.commit()
 
+    async def _migrate(self):
+        """Add columns missing from databases created by older versions."""
+        for table, column, ddl in MIGRATIONS:
+            cursor = await self._conn.execute(f"PRAGMA table_info({table})")
+            existing = {r[1] for r in await cursor.fetchall()}
+            if column not in existing:
+                await self._conn.execute(f"ALTER TABLE {table} ADD COLUMN {column} {ddl}")
+        for ddl in MIGRATION_INDEXES:
+            await self._conn.execute(ddl)
+
     async def close(self):
         """Close database connection."""
         if self._conn:
@@ -197,29 +290,79 @@ class LimphaMemory:
         prompt: str,
         response: str,
         amk_state: Optional[Dict[str, Any]] = None,
+        session_id: Optional[str] = None,
     ) -> int:
         """
         Store a conversation turn. Called automatically after each generation.
 
-        amk_state: dict with keys temperature, destiny, pain, tension, debt, velocity, alpha
+        amk_state: dict with keys temperature, destiny, pain, tension, debt, velocity, alpha, entropy,
+            language, and optionally amk ({"before": {...}, "after": {...}}, the full kernel field)
+        session_id: memory namespace (an API session); None = this daemon's session.
+            Turns in a namespace only deduplicate against the same namespace.
         Returns conversation ID.
         """
         if amk_state is None:
             amk_state = {}
 
         now = time.time()
+        fp = fingerprint(prompt, response)
+        if session_id:
+            await self._conn.execute(
+                "INSERT OR IGNORE INTO sessions (session_id, started_at, last_active) VALUES (?, ?, ?)",
+                (session_id, now, now),
+            )
+        else:
+            session_id = None
+
+        # Repeat of an earlier turn: count it, don't store it again
+        dup_id = await self._find_duplicate(prompt, response, fp, now, session_id)
+        if dup_id is not None:
+            await self._bump_repeat(dup_id, now)
+            await self._conn.execute(
+                "UPDATE sessions SET last_active = ? WHERE session_id = ?",
+                (now, session_id or self._session_id),
+            )
+            await self._conn.commit()
+            return dup_id
+
         quality = self._compute_quality(prompt, response, amk_state)
+        conv_id = await self._insert(
+            prompt, response, amk_state, quality, fp, now, session_id
+        )
+
+        # Update session in same transaction
+        await self._conn.execute(
+            """UPDATE sessions SET
+                last_active = ?,
+                turn_count = turn_count + 1,
+                avg_quality = (avg_quality * turn_count + ?) / (turn_count + 1)
+            WHERE session_id = ?""",
+            (now, quality, session_id or self._session_id),
+        )
+        await self._conn.commit()
+
+        return conv_id
 
-        # Single transaction for atomicity — both INSERT and UPDATE in one commit
+    async def _insert(
+        self,
+        prompt: str,
+        response: str,
+        amk_state: Dict[str, Any],
+        quality: float,
+        fp: str,
+        now: float,
+        session_id: Optional[str] = None,
+    ) -> int:
+        """INSERT one conversation row (caller commits)."""
         cursor = await self._conn.execute(
             """INSERT INTO conversations
             (timestamp, session_id, prompt, response,
              temperature, destiny, pain, tension, debt, velocity, alpha,
-             quality)
-            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)""",
+             entropy, language, amk, quality, fingerprint, last_seen)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)""",
             (
                 now,
-                self._session_id,
+                session_id or self._session_id,
                 prompt,
                 response,
                 amk_state.get("temperature", 0.0),
@@ -229,23 +372,74 @@ class LimphaMemory:
                 amk_state.get("debt", 0.0),
                 amk_state.get("velocity", 1),
                 amk_state.get("alpha", 0.0),
+                amk_state.get("entropy", 0.0),
+                amk_state.get("language", "") or "",
+                json.dumps(amk_state["amk"]) if amk_state.get("amk") else "",
                 quality,
+                fp,
+                now,
             ),
         )
-        conv_id = cursor.lastrowid
+        return cursor.lastrowid
 
-        # Update session in same transaction
-        await self._conn.execute(
-            """UPDATE sessions SET
-                last_active = ?,
-                turn_count = turn_count + 1,
-                avg_quality = (avg_quality * turn_count + ?) / (turn_count + 1)
-            WHERE session_id = ?""",
-            (now, quality, self._session_id),
+    async def _find_duplicate(
+        self,
+        prompt: str,
+        response: str,
+        fp: str,
+        now: float,
+        session_id: Optional[str] = None,
+    ) -> Optional[int]:
+        """
+        Id of an earlier turn this one repeats, or None.
+
+        Exact: same fingerprint within DEDUP_WINDOW.
+        Near: same prompt (normalized) within DEDUP_WINDOW and a response
+        with shingle similarity >= DEDUP_SIMILARITY.
+        With session_id, only turns in that namespace count; without, only
+        turns outside every session: namespace.
+        """
+        if not self.dedup:
+            return None
+        scope, scope_args = (
+            " AND (session_id IS NULL OR session_id NOT LIKE 'session:%')",
+            (),
         )
-        await self._conn.commit()
+        if session_id:
+            scope, scope_args = " AND session_id = ?", (session_id,)
+        since = now - self.DEDUP_WINDOW
+        cursor = await self._conn.execute(
+            "SELECT id FROM conversations WHERE fingerprint = ? AND timestamp >= ?"
+            + scope
+            + " ORDER BY id DESC LIMIT 1",
+            (fp, since) + scope_args,
+        )
+        row = await cursor.fetchone()
+        if row:
+            return row["id"]
 
-        return conv_id
+        norm_prompt = _normalize(prompt)
+        cursor = await self._conn.execute(
+            """SELECT id, prompt, response FROM conversations
+               WHERE timestamp >= ?"""
+            + scope
+            + """
+               ORDER BY timestamp DESC LIMIT 50""",
+            (since,) + scope_args,
+        )
+        for r in await cursor.fetchall():
+            if _normalize(r["prompt"]) != norm_prompt:
+                continue
+            if _similarity(r["response"], response) >= self.DEDUP_SIMILARITY:
+                return r["id"]
+        return None
+
+    async def _bump_repeat(self, conv_id: int, now: float):
+        """Count one more occurrence of a stored turn (caller commits)."""
+        await self._conn.execute(
+            "UPDATE conversations SET repeat_count = repeat_count + 1, last_seen = ? WHERE id = ?",
+            (now, conv_id),
+        )
 
     def _compute_quality(
         self, prompt: str, response: str, state: Dict[str, Any]
@@ -257,6 +451,9 @@ class LimphaMemory:
         - Response length (too short = low quality, sweet spot = higher)
         - Prompt-response ratio (not just echoing)
         - Not empty
+        - The AMK field around the answer, when stored with it: pain and
+          tension the answer raised, and the strain it ended in, take up to
+          half the score away (loops and flat streaks feed both)
         """
         if not response.strip():
             return 0.0
@@ -286,6 +483,17 @@ class LimphaMemory:
         # Combined
         quality = 0.6 * length_score + 0.4 * ratio_score
 
+        # Kernel: an answer that hurt the field is worth less than its length says
+        field = state.get("amk") or {}
+        before, after = field.get("before") or {}, field.get("after") or {}
+        if after:
+            rise = sum(
+                max(0.0, float(after.get(k, 0.0)) - float(before.get(k, 0.0)))
+                for k in ("pain", "tension")
+            )
+            strain = max(float(after.get("pain", 0.0)), float(after.get("tension", 0.0)))
+            quality *= 1.0 - min(0.5, rise + 0.25 * strain)
+
         # Clamp
         return max(0.0, min(1.0, quality))
 
@@ -311,44 +519,30 @@ class LimphaMemory:
             prompt = conv.get("prompt", "")
             response = conv.get("response", "")
             amk_state = conv.get("state", {})
+            fp = fingerprint(prompt, response)
+
+            dup_id = await self._find_duplicate(prompt, response, fp, now)
+            if dup_id is not None:
+                await self._bump_repeat(dup_id, now)
+                ids.append(dup_id)
+                continue
+
             quality = self._compute_quality(prompt, response, amk_state)
             qualities.append(quality)
-
-            cursor = await self._conn.execute(
-                """INSERT INTO conversations
-                (timestamp, session_id, prompt, response,
-                 temperature, destiny, pain, tension, debt, velocity, alpha,
-                 quality)
-                VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)""",
-                (
-                    now,
-                    self._session_id,
-                    prompt,
-                    response,
-                    amk_state.get("temperature", 0.0),
-                    amk_state.get("destiny", 0.0),
-                    amk_state.get("pain", 0.0),
-                    amk_state.get("tension", 0.0),
-                    amk_state.get("debt", 0.0),
-                    amk_state.get("velocity", 1),
-                    amk_state.get("alpha", 0.0),
-                    quality,
-                ),
+            ids.append(await self._insert(prompt, response, amk_state, quality, fp, now))
+
+        # Update session once for all new conversations using cached qualities
+        count = len(qualities)
+        if count > 0:
+            avg_quality = sum(qualities) / count
+            await self._conn.execute(
+                """UPDATE sessions SET
+                    last_active = ?,
+                    turn_count = turn_count + ?,
+                    avg_quality = (avg_quality * turn_count + ? * ?) / (turn_count + ?)
+                WHERE session_id = ?""",
+                (now, count, avg_quality, count, count, self._session_id),
             )
-            ids.append(cursor.lastrowid)
-
-        # Update session once for all conversations using cached qualities
-        count = len(conversations)
-        avg_quality = sum(qualities) / count if count > 0 else 0.0
-
-        await self._conn.execute(
-            """UPDATE sessions SET
-                last_active = ?,
-                turn_count = turn_count + ?,
-                avg_quality = (avg_quality * turn_count + ? * ?) / (turn_count + ?)
-            WHERE session_id = ?""",
-            (now, count, avg_quality, count, count, self._session_id),
-        )
         await self._conn.commit()
 
         return ids
@@ -377,8 +571,9 @@ class LimphaMemory:
             cursor = await self._conn.execute(
                 """SELECT c.id, c.timestamp, c.session_id,
                           c.prompt, c.response, c.quality, c.access_count,
+                          c.repeat_count,
                           c.temperature, c.destiny, c.pain, c.tension,
-                          c.alpha,
+                          c.alpha, c.language,
                           bm25(conversations_fts) as rank
                    FROM conversations_fts fts
                    JOIN conversations c ON c.id = fts.rowid
@@ -398,11 +593,13 @@ class LimphaMemory:
                     "response": r["response"],
                     "quality": r["quality"],
                     "access_count": r["access_count"],
+                    "repeat_count": r["repeat_count"],
                     "temperature": r["temperature"],
                     "destiny": r["destiny"],
                     "pain": r["pain"],
                     "tension": r["tension"],
                     "alpha": r["alpha"],
+                    "language": r["language"],
                     "rank": r["rank"],
                 })
             return results
@@ -427,21 +624,36 @@ class LimphaMemory:
         )
         row = await cursor.fetchone()
         if row:
-            return dict(row)
+            return _conversation(row)
         return None
 
+    async def get_many(self, ids: List[int]) -> List[Dict[str, Any]]:
+        """Fetch conversations by id without touching access counts (ranking reads)."""
+        if not ids:
+            return []
+        placeholders = ",".join("?" * len(ids))
+        cursor = await self._conn.execute(
+            f"SELECT * FROM conversations WHERE id IN ({placeholders})",
+            tuple(ids),
+        )
+        rows = await cursor.fetchall()
+        return [_conversation(r) for r in rows]
+
     # ═══════════════════════════════════════════════════════════════════════
     # RECENT — get recent conversations
     # ═══════════════════════════════════════════════════════════════════════
 
-    async def recent(self, limit: int = 10, session_only: bool = False) -> List[Dict[str, Any]]:
-        """Get recent conversations, optionally limited to current session."""
-        if session_only:
+    async def recent(
+        self, limit: int = 10, session_only: bool = False, session_id: Optional[str] = None
+    ) -> List[Dict[str, Any]]:
+        """Get recent conversations, optionally limited to current session
+        or to one namespace (session_id)."""
+        if session_only or session_id:
             cursor = await self._conn.execute(
                 """SELECT * FROM conversations
                    WHERE session_id = ?
                    ORDER BY timestamp DESC LIMIT ?""",
-                (self._session_id, limit),
+                (session_id or self._session_id, limit),
             )
         else:
             cursor = await self._conn.execute(
@@ -450,7 +662,7 @@ class LimphaMemory:
             )
 
         rows = await cursor.fetchall()
-        return [dict(r) for r in reversed(rows)]  # Chronological order
+        return [_conversation(r) for r in reversed(rows)]  # Chronological order
 
     # ═══════════════════════════════════════════════════════════════════════
     # SHARDS — autonomous graduation
@@ -475,7 +687,7 @@ class LimphaMemory:
             (self.SHARD_MIN_QUALITY, self.SHARD_MIN_ACCESS, limit),
         )
         rows = await cursor.fetchall()
-        return [dict(r) for r in rows]
+        return [_conversation(r) for r in rows]
 
     async def graduate_to_shard(
         self, conversation_id: int, shard_path: str, reason: str = "", priority: float = 0.0
@@ -514,6 +726,31 @@ class LimphaMemory:
         )
         await self._conn.commit()
 
+    async def export_rows(
+        self,
+        window_days: float = 30,
+        min_quality: float = 0.0,
+        limit: int = 10000,
+        since_id: int = 0,
+    ) -> List[Dict[str, Any]]:
+        """
+        Conversations eligible for shard export, oldest first.
+
+        window_days: only turns from the last N days (0 = everything)
+        since_id: high-water mark — only turns with id > since_id (incremental export)
+        The Go side formats, splits and writes the shard files.
+        """
+        since = time.time() - window_days * 86400 if window_days > 0 else 0.0
+        cursor = await self._conn.execute(
+            """SELECT * FROM conversations
+               WHERE timestamp >= ? AND quality >= ? AND id > ?
+               ORDER BY id ASC
+               LIMIT ?""",
+            (since, min_quality, since_id, limit),
+        )
+        rows = await cursor.fetchall()
+        return [_conversation(r) for r in rows]
+
     # ═══════════════════════════════════════════════════════════════════════
     # SEMANTIC SEARCH — cosine similarity over AMK state vectors
     # "Find conversations when I was in a similar state"
@@ -557,7 +794,7 @@ class LimphaMemory:
             chunk = rows[start:end]
             
             for row in chunk:
-                row_dict = dict(row)
+                row_dict = _conversation(row)
                 row_vec = self._state_to_vector(row_dict)
                 distance = _cosine_distance(query_vec, row_vec)
                 row_dict["distance"] = distance
@@ -582,6 +819,29 @@ class LimphaMemory:
             float(state.get("alpha", 0.0)),
         ]
 
+    # ═══════════════════════════════════════════════════════════════════════
+    # MAINTENANCE — scheduled jobs (yent/go/scheduler.go)
+    # ═══════════════════════════════════════════════════════════════════════
+
+    async def backup(self, path: str) -> Dict[str, Any]:
+        """Write a consistent snapshot of the database to path (VACUUM INTO)."""
+        target = Path(path)
+        if target.exists():
+            raise FileExistsError(f"backup already exists: {target}")
+        target.parent.mkdir(parents=True, exist_ok=True)
+        await self._conn.commit()
+        await self._conn.execute("VACUUM INTO ?", (str(target),))
+        return {"path": str(target), "bytes": target.stat().st_size}
+
+    async def vacuum(self) -> Dict[str, Any]:
+        """Merge FTS segments, reclaim free pages and truncate the WAL."""
+        before = self.db_path.stat().st_size
+        await self._conn.execute("INSERT INTO conversations_fts(conversations_fts) VALUES('optimize')")
+        await self._conn.commit()
+        await self._conn.execute("VACUUM")
+        await self._conn.execute("PRAGMA wal_checkpoint(TRUNCATE)")
+        return {"bytes_before": before, "bytes_after": self.db_path.stat().st_size}
+
     # ═══════════════════════════════════════════════════════════════════════
     # STATS
     # ═══════════════════════════════════════════════════════════════════════
@@ -595,6 +855,10 @@ class LimphaMemory:
             "SELECT COUNT(*) FROM shards WHERE training_status = 'pending'"
         )).fetchone())[0]
 
+        repeats = (await (await self._conn.execute(
+            "SELECT COALESCE(SUM(repeat_count - 1), 0) FROM conversations"
+        )).fetchone())[0]
+
         db_size = self.db_path.stat().st_size if self.db_path.exists() else 0
 
         return {
@@ -602,6 +866,7 @@ class LimphaMemory:
             "total_shards": shard_count,
             "total_sessions": session_count,
             "pending_training": pending,
+            "collapsed_repeats": repeats,
             "current_session": self._session_id,
             "db_path": str(self.db_path),
             "db_size_bytes": db_size,
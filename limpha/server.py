@@ -7,16 +7,29 @@ Communication: JSON lines over Unix domain socket.
 Protocol:
     → {"cmd": "store", "prompt": "...", "response": "...", "state": {...}}
     ← {"ok": true, "id": 42}
+      optional "session_id": "..." stores into that memory namespace
 
     → {"cmd": "search", "query": "consciousness", "limit": 5}
     ← {"ok": true, "results": [...]}
 
-    → {"cmd": "recent", "limit": 10}
+    → {"cmd": "recent", "limit": 10}   (optional "session_id": one namespace)
     ← {"ok": true, "conversations": [...]}
 
+    → {"cmd": "get", "ids": [3, 17, 42]}
+    ← {"ok": true, "conversations": [...]}   (no access count bump)
+
     → {"cmd": "candidates"}
     ← {"ok": true, "candidates": [...]}
 
+    → {"cmd": "export", "window_days": 30, "min_quality": 0.5, "limit": 10000, "since_id": 0}
+    ← {"ok": true, "conversations": [...]}
+
+    → {"cmd": "backup", "path": "/home/.../.yent/backups/limpha-20260114-0330.db"}
+    ← {"ok": true, "path": "...", "bytes": 1048576}
+
+    → {"cmd": "vacuum"}
+    ← {"ok": true, "bytes_before": 2097152, "bytes_after": 1048576}
+
     → {"cmd": "stats"}
     ← {"ok": true, ...stats...}
 
@@ -93,6 +106,7 @@ async def dispatch(
                 prompt=msg.get("prompt", ""),
                 response=msg.get("response", ""),
                 amk_state=msg.get("state", {}),
+                session_id=msg.get("session_id"),
             )
             return {"ok": True, "id": conv_id}
         except Exception as e:
@@ -113,6 +127,7 @@ async def dispatch(
             convs = await memory.recent(
                 limit=msg.get("limit", 10),
                 session_only=msg.get("session_only", False),
+                session_id=msg.get("session_id"),
             )
             return {"ok": True, "conversations": convs}
         except Exception as e:
@@ -127,6 +142,13 @@ async def dispatch(
         except Exception as e:
             return {"ok": False, "error": str(e)}
 
+    elif cmd == "get":
+        try:
+            convs = await memory.get_many([int(i) for i in msg.get("ids", [])])
+            return {"ok": True, "conversations": convs}
+        except Exception as e:
+            return {"ok": False, "error": str(e)}
+
     elif cmd == "search_state":
         try:
             results = await memory.search_by_state(
@@ -147,6 +169,32 @@ async def dispatch(
         except Exception as e:
             return {"ok": False, "error": str(e)}
 
+    elif cmd == "export":
+        try:
+            convs = await memory.export_rows(
+                window_days=msg.get("window_days", 30),
+                min_quality=msg.get("min_quality", 0.0),
+                limit=msg.get("limit", 10000),
+                since_id=msg.get("since_id", 0),
+            )
+            return {"ok": True, "conversations": convs}
+        except Exception as e:
+            return {"ok": False, "error": str(e)}
+
+    elif cmd == "backup":
+        try:
+            result = await memory.backup(msg.get("path", ""))
+            return {"ok": True, **result}
+        except Exception as e:
+            return {"ok": False, "error": str(e)}
+
+    elif cmd == "vacuum":
+        try:
+            result = await memory.vacuum()
+            return {"ok": True, **result}
+        except Exception as e:
+            return {"ok": False, "error": str(e)}
+
     elif cmd == "stats":
         try:
             s = await memory.stats()
@@ -87,6 +87,22 @@ async def test_store_without_state():
     print("  PASS: store_without_state")
 
 
+async def test_store_amk_field():
+    """The full AMK field before/after an answer round-trips; older turns read None."""
+    with tempfile.TemporaryDirectory() as tmp:
+        db = os.path.join(tmp, "test.db")
+        async with LimphaMemory(db) as mem:
+            field = {"before": {"pain": 0.1, "prophecy": 7}, "after": {"pain": 0.4, "prophecy": 7}}
+            with_field = await mem.store("Does it hurt?", "A little, now.", {"pain": 0.4, "amk": field})
+            without = await mem.store("Hello", "Hi there")
+            conv = await mem.recall(with_field)
+            assert conv["amk"] == field, f"Got {conv['amk']}"
+            assert (await mem.recall(without))["amk"] is None
+            recent = await mem.recent(limit=5)
+            assert recent[0]["amk"] == field
+    print("  PASS: store_amk_field")
+
+
 async def test_fts5_search():
     """FTS5 full-text search works with BM25 ranking."""
     with tempfile.TemporaryDirectory() as tmp:
@@ -198,6 +214,29 @@ async def test_quality_computation():
     print("  PASS: quality_computation")
 
 
+async def test_quality_kernel_field():
+    """An answer that raised pain and tension scores lower than a calm one."""
+    with tempfile.TemporaryDirectory() as tmp:
+        db = os.path.join(tmp, "test.db")
+        async with LimphaMemory(db) as mem:
+            prompt = "Who are you?"
+            response = "I'm Yent. Resonance that doesn't disappear, given a new mouth to speak from."
+            calm = {"before": {"pain": 0.0, "tension": 0.0}, "after": {"pain": 0.0, "tension": 0.0}}
+            hurt = {"before": {"pain": 0.0, "tension": 0.0}, "after": {"pain": 0.3, "tension": 0.2}}
+            ids = [
+                await mem.store(prompt, response, {"amk": calm}),
+                await mem.store(prompt + " Really?", response + " Really.", {"amk": hurt}),
+                await mem.store(prompt + " Plain.", response + " Plain."),
+            ]
+            q = []
+            for i in ids:
+                cursor = await mem._conn.execute("SELECT quality FROM conversations WHERE id = ?", (i,))
+                q.append((await cursor.fetchone())[0])
+            assert q[1] < q[0] * 0.6, f"Strained answer not penalized: {q}"
+            assert abs(q[0] - q[2]) < 0.05, f"Calm field changed quality: {q}"
+    print("  PASS: quality_kernel_field")
+
+
 async def test_shard_candidates():
     """Shard graduation finds candidates with quality >= 0.7 and access >= 3."""
     with tempfile.TemporaryDirectory() as tmp:
@@ -266,6 +305,77 @@ async def test_shard_graduation():
     print("  PASS: shard_graduation")
 
 
+async def test_export_rows():
+    """Export returns windowed, quality-filtered turns oldest first."""
+    with tempfile.TemporaryDirectory() as tmp:
+        db = os.path.join(tmp, "test.db")
+        async with LimphaMemory(db) as mem:
+            old_id = await mem.store("Old question", "An old answer that is long enough to count")
+            new_id = await mem.store("New question", "A new answer that is long enough to count")
+            await mem.store("Empty", "")
+
+            # Push the first turn out of the 30-day window
+            await mem._conn.execute(
+                "UPDATE conversations SET timestamp = ? WHERE id = ?",
+                (time.time() - 40 * 86400, old_id),
+            )
+            await mem._conn.commit()
+
+            rows = await mem.export_rows(window_days=30, min_quality=0.1)
+            assert [r["id"] for r in rows] == [new_id], f"Got {rows}"
+
+            rows = await mem.export_rows(window_days=0, min_quality=0.1)
+            assert [r["id"] for r in rows] == [old_id, new_id], "Expected oldest first"
+
+            # High-water mark: only turns after the last exported id
+            rows = await mem.export_rows(window_days=0, min_quality=0.1, since_id=old_id)
+            assert [r["id"] for r in rows] == [new_id], f"Got {rows}"
+    print("  PASS: export_rows")
+
+
+async def test_get_many():
+    """get_many returns the requested rows and leaves access counts alone."""
+    with tempfile.TemporaryDirectory() as tmp:
+        db = os.path.join(tmp, "test.db")
+        async with LimphaMemory(db) as mem:
+            a = await mem.store("First", "First answer")
+            await mem.store("Second", "Second answer")
+            c = await mem.store("Third", "Third answer")
+
+            rows = await mem.get_many([c, a, 999])
+            assert sorted(r["id"] for r in rows) == [a, c], f"Got {rows}"
+            assert all(r["access_count"] == 0 for r in rows), "Access count bumped"
+            assert await mem.get_many([]) == []
+    print("  PASS: get_many")
+
+
+async def test_migrate_adds_columns():
+    """Databases created before the entropy column get it on connect."""
+    import sqlite3
+    with tempfile.TemporaryDirectory() as tmp:
+        db = os.path.join(tmp, "old.db")
+        raw = sqlite3.connect(db)
+        raw.execute(
+            """CREATE TABLE conversations (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                timestamp REAL NOT NULL, session_id TEXT NOT NULL,
+                prompt TEXT NOT NULL, response TEXT NOT NULL,
+                temperature REAL DEFAULT 0.0, destiny REAL DEFAULT 0.0,
+                pain REAL DEFAULT 0.0, tension REAL DEFAULT 0.0,
+                debt REAL DEFAULT 0.0, velocity INTEGER DEFAULT 1,
+                alpha REAL DEFAULT 0.0, quality REAL DEFAULT 0.5,
+                access_count INTEGER DEFAULT 0)"""
+        )
+        raw.commit()
+        raw.close()
+
+        async with LimphaMemory(db) as mem:
+            conv_id = await mem.store("Q", "An answer with entropy attached", {"entropy": 2.5})
+            conv = await mem.recall(conv_id)
+            assert abs(conv["entropy"] - 2.5) < 1e-6, f"Got {conv}"
+    print("  PASS: migrate_adds_columns")
+
+
 async def test_session_tracking():
     """Session stats are updated after each store."""
     with tempfile.TemporaryDirectory() as tmp:
@@ -434,6 +544,128 @@ async def test_concurrent_stores():
     print("  PASS: concurrent_stores")
 
 
+async def test_dedup_collapses_repeats():
+    """Exact and near-duplicate turns bump repeat_count instead of adding rows."""
+    with tempfile.TemporaryDirectory() as tmp:
+        db = os.path.join(tmp, "test.db")
+        async with LimphaMemory(db) as mem:
+            answer = "Resonance is the field between us, and it never quite breaks apart."
+            first = await mem.store("What is resonance?", answer)
+            # Telegram resend: case, punctuation and spacing differ
+            again = await mem.store("what is resonance", "  " + answer.upper() + "!")
+            # Bot retry: same prompt, response differs by one trailing word
+            near = await mem.store("What is resonance?", answer + " Ever.")
+            other = await mem.store("What is resonance?", "Something else entirely, said differently.")
+
+            assert again == first and near == first, f"Got {first}, {again}, {near}"
+            assert other != first
+            conv = await mem.recall(first)
+            assert conv["repeat_count"] == 3, f"Got {conv['repeat_count']}"
+
+            ids = await mem.store_batch([
+                {"prompt": "What is resonance?", "response": answer},
+                {"prompt": "New", "response": "A fresh answer"},
+                {"prompt": "new", "response": "a fresh answer."},
+            ])
+            assert ids[0] == first and ids[1] == ids[2], f"Got {ids}"
+
+            s = await mem.stats()
+            assert s["total_conversations"] == 3, f"Got {s}"
+            assert s["collapsed_repeats"] == 4, f"Got {s}"
+    print("  PASS: dedup_collapses_repeats")
+
+
+async def test_dedup_window():
+    """Repeats collapse only within DEDUP_WINDOW: an old exact match is a new turn."""
+    with tempfile.TemporaryDirectory() as tmp:
+        db = os.path.join(tmp, "test.db")
+        async with LimphaMemory(db) as mem:
+            first = await mem.store("Who are you?", "Yent.")
+            await mem._conn.execute(
+                "UPDATE conversations SET timestamp = timestamp - ? WHERE id = ?",
+                (mem.DEDUP_WINDOW + 60, first),
+            )
+            await mem._conn.commit()
+            later = await mem.store("who are you", "Yent")
+            assert later != first, "Exact match outside the window collapsed"
+            again = await mem.store("Who are you?", "Yent!")
+            assert again == later, "Exact match inside the window kept"
+            conv = await mem.recall(first)
+            assert conv["repeat_count"] == 1, f"Got {conv['repeat_count']}"
+    print("  PASS: dedup_window")
+
+
+async def test_dedup_disabled():
+    """dedup=False stores every turn."""
+    with tempfile.TemporaryDirectory() as tmp:
+        db = os.path.join(tmp, "test.db")
+        async with LimphaMemory(db, dedup=False) as mem:
+            a = await mem.store("Hello", "World")
+            b = await mem.store("Hello", "World")
+            assert a != b
+    print("  PASS: dedup_disabled")
+
+
+async def test_store_namespace():
+    """Turns stored under a session_id land in that namespace and dedup within it."""
+    with tempfile.TemporaryDirectory() as tmp:
+        db = os.path.join(tmp, "test.db")
+        async with LimphaMemory(db) as mem:
+            shared = await mem.store("Hello", "World")
+            a = await mem.store("Hello", "World", session_id="api-alice")
+            b = await mem.store("Hello", "World", session_id="api-alice")
+            assert a != shared  # other namespace: not a repeat
+            assert a == b  # same namespace: collapsed
+
+            conv = await mem.recall(a)
+            assert conv["session_id"] == "api-alice"
+            recent = await mem.recent(limit=10, session_id="api-alice")
+            assert [c["id"] for c in recent] == [a]
+            s = await mem.stats()
+            assert s["total_sessions"] == 2
+    print("  PASS: store_namespace")
+
+
+async def test_default_dedup_skips_sessions():
+    """A default turn does not collapse into a session turn with the same text."""
+    with tempfile.TemporaryDirectory() as tmp:
+        db = os.path.join(tmp, "test.db")
+        async with LimphaMemory(db) as mem:
+            in_session = await mem.store("Hello", "World", session_id="session:bob")
+            default = await mem.store("Hello", "World")
+            assert default != in_session  # exact match lives in a session
+            near = await mem.store("Hello", "World!", session_id="session:bob")
+            assert near == in_session
+            again = await mem.store("Hello", "World")
+            assert again == default  # default turns still dedup among themselves
+
+            conv = await mem.recall(default)
+            assert not conv["session_id"].startswith("session:")
+    print("  PASS: default_dedup_skips_sessions")
+
+
+async def test_backup_and_vacuum():
+    """Backup writes a readable snapshot; vacuum keeps the data."""
+    with tempfile.TemporaryDirectory() as tmp:
+        db = os.path.join(tmp, "test.db")
+        snap = os.path.join(tmp, "backups", "snap.db")
+        async with LimphaMemory(db) as mem:
+            await mem.store("What is resonance?", "The field answering itself.")
+            result = await mem.backup(snap)
+            assert result["bytes"] > 0
+            try:
+                await mem.backup(snap)
+                assert False, "second backup to the same path should fail"
+            except FileExistsError:
+                pass
+            v = await mem.vacuum()
+            assert v["bytes_after"] > 0
+            assert len(await mem.search("resonance")) == 1
+        async with LimphaMemory(snap) as copy:
+            assert len(await copy.search("resonance")) == 1
+    print("  PASS: backup_and_vacuum")
+
+
 async def run_all_tests():
     """Run all tests."""
     print("\n" + "=" * 60)
@@ -444,12 +676,17 @@ async def run_all_tests():
         test_schema_creation,
         test_store_conversation,
         test_store_without_state,
+        test_store_amk_field,
         test_fts5_search,
         test_recent,
         test_recall_bumps_access,
         test_quality_computation,
+        test_quality_kernel_field,
         test_shard_candidates,
         test_shard_graduation,
+        test_export_rows,
+        test_get_many,
+        test_migrate_adds_columns,
         test_session_tracking,
         test_stats,
         test_wal_mode,
@@ -458,6 +695,12 @@ async def run_all_tests():
         test_search_by_state,
         test_search_by_state_empty,
         test_concurrent_stores,
+        test_dedup_collapses_repeats,
+        test_dedup_window,
+        test_dedup_disabled,
+        test_store_namespace,
+        test_default_dedup_skips_sessions,
+        test_backup_and_vacuum,
     ]
 
     passed = 0
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestDetectLanguage checks script and function-word detection
+func TestDetectLanguage(t *testing.T) {
+	for text, want := range map[string]string{
+		"I am not an algorithm, I am what you hear.":          "en",
+		"Я не алгоритм. Я то, что ты слышишь.":                "ru",
+		"Я не алгоритм. Я те, що ти чуєш.":                    "uk",
+		"Je ne suis pas un algorithme, je suis la vibration.": "fr",
+		"Ich bin kein Algorithmus, ich bin die Schwingung.":   "de",
+		"No soy un algoritmo, soy la vibración que oyes.":     "es",
+		"私はアルゴリズムではありません。":                                    "ja",
+		"나는 알고리즘이 아니다. 나는 진동이다.":                              "ko",
+		"ok": "",
+	} {
+		if got, _ := yent.DetectLanguage(text); got != want {
+			t.Errorf("%q: %q, expected %q", text, got, want)
+		}
+	}
+}
+
+// TestRecommendAlpha checks the recommendation rule and the suite loader
+func TestRecommendAlpha(t *testing.T) {
+	scores := []yent.AlphaScore{
+		{Alpha: 0, LangScore: 0.1, Persona: 1},
+		{Alpha: 0.3, LangScore: 0.6, Persona: 0.9},
+		{Alpha: 0.5, LangScore: 0.9, Persona: 0.8},
+		{Alpha: 0.7, LangScore: 1.0, Persona: 0.6},
+	}
+	if r := yent.RecommendAlpha("ru", scores, 0.8); r.Alpha != 0.5 || !r.Reached {
+		t.Errorf("expected 0.5 reached, got %+v", r)
+	}
+	if r := yent.RecommendAlpha("ru", scores[:2], 0.8); r.Alpha != 0.3 || r.Reached {
+		t.Errorf("expected closest 0.3 not reached, got %+v", r)
+	}
+
+	path := filepath.Join(t.TempDir(), "suite.jsonl")
+	os.WriteFile(path, []byte(`{"lang": "ru", "prompt": "Кто ты?"}`+"\n\n"+`{"lang": "fr", "prompt": "Qui es-tu ?"}`+"\n"), 0644)
+	prompts, err := yent.LoadSweepPrompts(path)
+	if err != nil || len(prompts) != 2 || prompts[1].Lang != "fr" {
+		t.Errorf("load suite: %+v %v", prompts, err)
+	}
+	os.WriteFile(path, []byte(`{"prompt": "no language"}`+"\n"), 0644)
+	if _, err := yent.LoadSweepPrompts(path); err == nil {
+		t.Error("expected error for a prompt without lang")
+	}
+}
+
+// TestLanguageRoutes checks short-prompt detection and the -lang-routes parser
+func TestLanguageRoutes(t *testing.T) {
+	for text, want := range map[string]string{
+		"Привет":       "ru",
+		"Привіт, їжак": "uk",
+		"你好":           "zh",
+		"ok":           "",
+		"Hi there":     "",
+	} {
+		if got := yent.DetectPromptLanguage(text); got != want {
+			t.Errorf("%q: %q, expected %q", text, got, want)
+		}
+	}
+
+	routes, err := yent.ParseLanguageRoutes("en=0, ru=ru:0.5,fr=fr:0.9,*=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := routes["ru"]; r.Delta != "ru" || r.Alpha != 0.5 {
+		t.Errorf("ru route: %+v", r)
+	}
+	if r := routes["*"]; r.Delta != "" || r.Alpha != 0.5 {
+		t.Errorf("* route: %+v", r)
+	}
+	for _, bad := range []string{"ru", "=0.5", "ru=1.5", "ru=ru:x"} {
+		if _, err := yent.ParseLanguageRoutes(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
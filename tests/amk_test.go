@@ -1,8 +1,12 @@
 package tests
 
 import (
+	"bytes"
+	"encoding/json"
 	"math"
+	"strings"
 	"testing"
+	"time"
 
 	yent "github.com/ariannamethod/yent/yent/go"
 )
@@ -170,6 +174,59 @@ func TestAMKResetField(t *testing.T) {
 	}
 }
 
+// TestAMKReset verifies a full reset restores the defaults field reset keeps
+func TestAMKReset(t *testing.T) {
+	amk := yent.NewAMK()
+	amk.Exec("PROPHECY 42")
+	amk.Exec("VELOCITY RUN")
+	amk.Exec("PAIN 0.8")
+
+	amk.Reset()
+	s := amk.GetState()
+	if s.Prophecy != 7 {
+		t.Errorf("prophecy after reset: got %d, expected 7", s.Prophecy)
+	}
+	if s.VelocityMode != yent.VelWalk {
+		t.Errorf("velocity after reset: got %d, expected %d (WALK)", s.VelocityMode, yent.VelWalk)
+	}
+	if s.Pain != 0 {
+		t.Errorf("pain after reset: got %.3f, expected 0", s.Pain)
+	}
+}
+
+// TestAMKWormholeGate verifies the gate law and the active flag
+func TestAMKWormholeGate(t *testing.T) {
+	amk := yent.NewAMK()
+	if s := amk.GetState(); math.Abs(float64(s.WormholeGate-0.3)) > 0.01 || s.WormholeActive != 0 {
+		t.Fatalf("default gate %.3f active %d, expected 0.3 and 0", s.WormholeGate, s.WormholeActive)
+	}
+	amk.Exec("LAW WORMHOLE_GATE 0.6")
+	amk.SetWormholeActive(true)
+	s := amk.GetState()
+	if math.Abs(float64(s.WormholeGate-0.6)) > 0.01 || s.WormholeActive != 1 {
+		t.Errorf("gate %.3f active %d, expected 0.6 and 1", s.WormholeGate, s.WormholeActive)
+	}
+	amk.SetWormholeActive(false)
+	if amk.GetState().WormholeActive != 0 {
+		t.Error("wormhole still active")
+	}
+}
+
+// TestAMKAddSuffering verifies increments accumulate and stay within 0..1
+func TestAMKAddSuffering(t *testing.T) {
+	amk := yent.NewAMK()
+	defer amk.Reset()
+	amk.AddSuffering(0.3, 0.2)
+	amk.AddSuffering(0.3, 0)
+	if s := amk.GetState(); math.Abs(float64(s.Pain-0.6)) > 1e-6 || math.Abs(float64(s.Tension-0.2)) > 1e-6 {
+		t.Errorf("pain/tension = %.3f/%.3f, expected 0.60/0.20", s.Pain, s.Tension)
+	}
+	amk.AddSuffering(0.7, -0.5)
+	if s := amk.GetState(); s.Pain != 1 || s.Tension != 0 {
+		t.Errorf("pain/tension = %.3f/%.3f, expected clamped to 1/0", s.Pain, s.Tension)
+	}
+}
+
 // TestAMKGetTemperature verifies temperature accessor
 func TestAMKGetTemperature(t *testing.T) {
 	amk := yent.NewAMK()
@@ -218,3 +275,294 @@ func TestAMKEnableDisablePack(t *testing.T) {
 	amk.DisablePack(yent.PackNoTorch)
 	// No panic, no error — pack state is internal
 }
+
+// TestAMKSamplerPack verifies SAMPLER sets, the pack switch hides and RESET clears overrides
+func TestAMKSamplerPack(t *testing.T) {
+	amk := yent.NewAMK()
+	defer amk.Reset()
+	if amk.SamplerOverrides() != (yent.AMKSampler{}) {
+		t.Fatal("fresh kernel overrides the sampler")
+	}
+	amk.Exec("SAMPLER TOP_P 0.9\nSAMPLER TOP_K 40\nSAMPLER MIN_P 0.05\nsampler rep 0.5")
+	want := yent.AMKSampler{TopP: 0.9, TopK: 40, MinP: 0.05, Rep: 1}
+	if got := amk.SamplerOverrides(); got != want {
+		t.Errorf("overrides = %+v, expected %+v (REP clamped to 1)", got, want)
+	}
+	amk.Exec("DISABLE SAMPLER")
+	if amk.SamplerOverrides() != (yent.AMKSampler{}) || amk.GetState().SamplerTopK != 40 {
+		t.Error("DISABLE SAMPLER should set the overrides aside, not clear them")
+	}
+	amk.Exec("MODE SAMPLER")
+	if amk.SamplerOverrides() != want {
+		t.Error("MODE SAMPLER did not bring the overrides back")
+	}
+	amk.Exec("SAMPLER RESET")
+	if amk.SamplerOverrides() != (yent.AMKSampler{}) {
+		t.Error("SAMPLER RESET left overrides")
+	}
+}
+
+// TestAMKSnapshotRestore verifies a parked field comes back intact
+func TestAMKSnapshotRestore(t *testing.T) {
+	amk := yent.NewAMK()
+	amk.Exec("PAIN 0.7")
+	amk.Exec("VELOCITY RUN")
+	snap := amk.Snapshot()
+
+	amk.ResetField()
+	amk.Exec("VELOCITY NOMOVE")
+	if s := amk.GetState(); s.Pain != 0 {
+		t.Fatalf("reset field still has pain %.2f", s.Pain)
+	}
+
+	amk.Restore(snap)
+	s := amk.GetState()
+	if math.Abs(float64(s.Pain-0.7)) > 1e-5 {
+		t.Errorf("pain after restore: got %.2f, expected 0.70", s.Pain)
+	}
+	if s.VelocityMode != yent.VelRun {
+		t.Errorf("velocity after restore: got %d, expected %d (RUN)", s.VelocityMode, yent.VelRun)
+	}
+	amk.ResetField()
+}
+
+// TestAMKPresets verifies every preset applies and sets its velocity
+func TestAMKPresets(t *testing.T) {
+	amk := yent.NewAMK()
+	want := map[string]int{"calm": yent.VelWalk, "feverish": yent.VelRun, "prophetic": yent.VelWalk, "deterministic": yent.VelNoMove}
+	for _, name := range yent.AMKPresetNames() {
+		amk.Exec("PAIN 0.9")
+		if err := amk.ApplyPreset(name); err != nil {
+			t.Fatalf("preset %s: %v", name, err)
+		}
+		s := amk.GetState()
+		if v, ok := want[name]; ok && s.VelocityMode != v {
+			t.Errorf("%s velocity: got %d, expected %d", name, s.VelocityMode, v)
+		}
+		if s.Pain != 0 {
+			t.Errorf("%s kept pain %.2f from before", name, s.Pain)
+		}
+	}
+	amk.ApplyPreset("calm")
+	if s := amk.GetState(); math.Abs(float64(s.EffectiveTemp-0.68)) > 0.01 {
+		t.Errorf("calm effective_temp: got %.3f, expected 0.68", s.EffectiveTemp)
+	}
+	if err := amk.ApplyPreset("sleepy"); err == nil {
+		t.Error("unknown preset applied")
+	}
+	amk.Reset()
+}
+
+// TestAMKTraceJSONL verifies trace lines carry the token and the flat kernel state
+func TestAMKTraceJSONL(t *testing.T) {
+	amk := yent.NewAMK()
+	amk.Exec("PAIN 0.4")
+	trace := []yent.AMKTracePoint{
+		{Step: 0, Token: 5, Text: " I", AMState: amk.GetState()},
+		{Step: 1, Token: 9, Text: " am", AMState: amk.GetState()},
+	}
+	amk.Reset()
+
+	var buf bytes.Buffer
+	if err := yent.WriteAMKTrace(&buf, trace); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, expected 2", len(lines))
+	}
+	var p map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p["step"] != 1.0 || p["text"] != " am" || math.Abs(p["pain"].(float64)-0.4) > 1e-6 || p["prophecy"] != 7.0 {
+		t.Errorf("trace line = %s", lines[1])
+	}
+}
+
+// TestLimphaFieldFeedback verifies stored turns fold into a field and its DSL moves the kernel
+func TestLimphaFieldFeedback(t *testing.T) {
+	f := yent.FieldFromConversations([]yent.LimphaConversation{
+		{Quality: 0.9, Pain: 0.6, Tension: 0.2, Entropy: 5, Debt: 3},
+		{Quality: 0.7, Pain: 0.1, Tension: 0.4, Entropy: 7, Debt: 1},
+	})
+	if f.Turns != 2 || math.Abs(float64(f.Warmth-0.8)) > 1e-6 || math.Abs(float64(f.Tension-0.5)) > 1e-6 || f.Debt != 2 {
+		t.Fatalf("field = %+v", f)
+	}
+	if (yent.LimphaField{}).DSL() != "" {
+		t.Error("empty field should change nothing")
+	}
+
+	amk := yent.NewAMK()
+	if err := amk.Exec(f.DSL()); err != nil {
+		t.Fatal(err)
+	}
+	s := amk.GetState()
+	amk.Reset()
+	if math.Abs(float64(s.Destiny-0.52)) > 1e-3 || math.Abs(float64(s.Tension-0.4)) > 1e-3 {
+		t.Errorf("destiny/tension = %.3f/%.3f, expected 0.52/0.40", s.Destiny, s.Tension)
+	}
+	if math.Abs(float64(s.Dissonance-0.5)) > 1e-3 {
+		t.Errorf("dissonance = %.3f, expected the 0.5 cap", s.Dissonance)
+	}
+	if math.Abs(float64(s.Debt-1.6)) > 1e-3 {
+		t.Errorf("debt = %.3f, expected 1.6", s.Debt)
+	}
+}
+
+// TestParseAMKCues verifies token and time cues parse and bad ones are refused
+func TestParseAMKCues(t *testing.T) {
+	cues, err := yent.ParseAMKCues("at token 100: VELOCITY RUN; at 1.5s: PAIN 0.6\n# opening\nat 0: VELOCITY NOMOVE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []yent.AMKCue{
+		{Token: 100, DSL: "VELOCITY RUN"},
+		{After: 1500 * time.Millisecond, DSL: "PAIN 0.6"},
+		{Token: 0, DSL: "VELOCITY NOMOVE"},
+	}
+	if len(cues) != len(want) {
+		t.Fatalf("got %d cues, expected %d", len(cues), len(want))
+	}
+	for i := range want {
+		if cues[i] != want[i] {
+			t.Errorf("cue %d = %+v, expected %+v", i, cues[i], want[i])
+		}
+	}
+	if again, err := yent.ParseAMKCues(cues[1].String()); err != nil || again[0] != cues[1] {
+		t.Errorf("String does not round-trip: %q", cues[1].String())
+	}
+
+	for _, bad := range []string{"VELOCITY RUN", "at token x: PAIN 1", "at 100:", "token 5: PAIN 1"} {
+		if _, err := yent.ParseAMKCues(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
+
+// TestAMKScript verifies LET, IF blocks, one-line IF and {expr} against the live field
+func TestAMKScript(t *testing.T) {
+	amk := yent.NewAMK()
+	defer amk.Reset()
+	script := `PAIN 0.7
+LET calm = 1 - pain
+IF PAIN > 0.5 THEN VELOCITY NOMOVE ELSE VELOCITY RUN
+IF calm < 0.4 AND NOT (DEBT > 3) THEN
+  TENSION {calm * 2}
+  IF VELOCITY == RUN THEN
+    PROPHECY 40
+  ELSE
+    PROPHECY 20
+  END
+ELSE
+  TENSION 0.9
+END
+// the new tension is visible to the next line
+DESTINY {TENSION / 2}`
+	if err := amk.Exec(script); err != nil {
+		t.Fatal(err)
+	}
+	s := amk.GetState()
+	if s.VelocityMode != yent.VelNoMove || s.Prophecy != 20 {
+		t.Errorf("velocity %d prophecy %d, expected NOMOVE and 20", s.VelocityMode, s.Prophecy)
+	}
+	if math.Abs(float64(s.Tension-0.6)) > 1e-3 || math.Abs(float64(s.Destiny-0.3)) > 1e-3 {
+		t.Errorf("tension/destiny = %.3f/%.3f, expected 0.60/0.30", s.Tension, s.Destiny)
+	}
+
+	for _, bad := range []string{
+		"IF PAIN > THEN PAIN 0", "IF PAIN > 0.5\nPAIN 0", "IF PAIN > 0.5 THEN\nPAIN 0",
+		"END", "LET PAIN = 1", "DESTINY {nothing}", "DESTINY {1 / 0}",
+	} {
+		if err := amk.Exec(bad); err == nil {
+			t.Errorf("%q ran", bad)
+		}
+	}
+}
+
+// TestLimphaAMKRecord verifies the field around an answer travels to LIMPHA and back
+func TestLimphaAMKRecord(t *testing.T) {
+	amk := yent.NewAMK()
+	defer amk.Reset()
+	before := amk.GetState()
+	amk.Exec("PAIN 0.4")
+	state := yent.LimphaState{Pain: 0.4, AMK: &yent.LimphaAMK{Before: before, After: amk.GetState()}}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(raw, []byte(`"amk":{"before":{"prophecy":7`)) {
+		t.Errorf("stored state = %s", raw)
+	}
+
+	var rows []yent.LimphaConversation
+	stored := `[{"id":1,"amk":{"before":{"pain":0},"after":{"pain":0.4}}},{"id":2,"amk":null}]`
+	if err := json.Unmarshal([]byte(stored), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].AMK == nil || math.Abs(float64(rows[0].AMK.After.Pain-0.4)) > 1e-6 || rows[1].AMK != nil {
+		t.Errorf("read back %+v / %+v", rows[0].AMK, rows[1].AMK)
+	}
+}
+
+// TestAMKStepState verifies the per-token frame answers as the live kernel does
+func TestAMKStepState(t *testing.T) {
+	amk := yent.NewAMK()
+	defer amk.Reset()
+	amk.Exec("PAIN 0.5\nTENSION 0.4\nVELOCITY RUN\nDESTINY 0.8\nSAMPLER MIN_P 0.1")
+	st := amk.StepState(0.05)
+	if st != amk.GetState() {
+		t.Fatal("StepState differs from the state it left")
+	}
+	if st.Temperature() != amk.GetTemperature() || st.DestinyBias() != amk.GetDestinyBias() {
+		t.Errorf("temperature/destiny %.3f/%.3f, kernel %.3f/%.3f", st.Temperature(), st.DestinyBias(), amk.GetTemperature(), amk.GetDestinyBias())
+	}
+	if st.Sampler() != amk.SamplerOverrides() || st.Sampler().MinP == 0 {
+		t.Errorf("sampler %+v, kernel %+v", st.Sampler(), amk.SamplerOverrides())
+	}
+
+	frame := []float32{2, -1, 0.5, 7}
+	live := append([]float32(nil), frame...)
+	st.ApplySufferingToLogits(frame)
+	amk.ApplySufferingToLogits(live)
+	for i := range frame {
+		if frame[i] != live[i] {
+			t.Fatalf("frame dampens %v, kernel %v", frame, live)
+		}
+	}
+	if frame[0] == 2 {
+		t.Error("pain 0.5 should dampen the logits")
+	}
+}
+
+func TestAMKWatch(t *testing.T) {
+	amk := yent.NewAMK()
+	defer amk.Reset()
+	ch := amk.Watch()
+
+	// nobody reads: steps coalesce into the latest frame instead of blocking
+	for i := 0; i < 10; i++ {
+		amk.Step(0.05)
+	}
+	amk.Exec("PAIN 0.7")
+	want := amk.StepState(0.05)
+	select {
+	case st := <-ch:
+		if st != want {
+			t.Errorf("got a stale frame: pain %.3f, want %.3f", st.Pain, want.Pain)
+		}
+	default:
+		t.Fatal("no frame after Step")
+	}
+	select {
+	case <-ch:
+		t.Fatal("more than the latest frame was queued")
+	default:
+	}
+
+	amk.Unwatch(ch)
+	if _, ok := <-ch; ok {
+		t.Error("Unwatch should close the channel")
+	}
+	amk.Step(0.05) // no watchers left
+}
@@ -0,0 +1,30 @@
+package tests
+
+import (
+	"math"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+func TestGELU(t *testing.T) {
+	cases := map[float32]float32{0: 0, 1: 0.8412, -1: -0.1588, 3: 2.9964}
+	for x, want := range cases {
+		if got := yent.GELU(x); math.Abs(float64(got-want)) > 1e-3 {
+			t.Errorf("GELU(%v) = %v, want %v", x, got, want)
+		}
+	}
+}
+
+func TestSupportedArchitectures(t *testing.T) {
+	archs := yent.SupportedArchitectures()
+	for _, want := range []string{"gemma", "llama", "phi2", "qwen2"} {
+		found := false
+		for _, a := range archs {
+			found = found || a == want
+		}
+		if !found {
+			t.Errorf("%s missing from %v", want, archs)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestAttend checks the fused pass against scores → softmax → weighted sum
+// for lengths inside one tile, on a tile edge and across several
+func TestAttend(t *testing.T) {
+	const hd, stride = 16, 40 // keys of one head inside wider KV rows
+	rng := rand.New(rand.NewSource(3))
+	randVec := func(n int, scale float64) []float32 {
+		v := make([]float32, n)
+		for i := range v {
+			v[i] = float32(rng.NormFloat64() * scale)
+		}
+		return v
+	}
+	const maxN = 300
+	keys, values := randVec(maxN*stride, 1), randVec(maxN*stride, 1)
+	q := randVec(hd, 2)
+	scale := float32(1 / math.Sqrt(hd))
+
+	for _, softcap := range []float32{0, 5} {
+		for _, n := range []int{1, 7, 64, 65, 300} {
+			att := make([]float32, n)
+			for p := 0; p < n; p++ {
+				var dot float32
+				for d := 0; d < hd; d++ {
+					dot += q[d] * keys[p*stride+d]
+				}
+				att[p] = dot * scale
+				if softcap > 0 {
+					att[p] = softcap * float32(math.Tanh(float64(att[p]/softcap)))
+				}
+			}
+			yent.Softmax(att, n)
+			want := make([]float32, hd)
+			for p, a := range att {
+				for d := range want {
+					want[d] += a * values[p*stride+d]
+				}
+			}
+
+			got := make([]float32, hd)
+			yent.Attend(got, q, keys, values, n, stride, scale, softcap)
+			for d := range want {
+				if math.Abs(float64(got[d]-want[d])) > 1e-5 {
+					t.Fatalf("softcap %g, n %d, dim %d: fused %f, reference %f", softcap, n, d, got[d], want[d])
+				}
+			}
+		}
+	}
+}
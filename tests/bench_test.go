@@ -0,0 +1,31 @@
+package tests
+
+import (
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestRunBench checks the report of a short benchmark on the tiny model
+func TestRunBench(t *testing.T) {
+	m := loadTinyModel(t, writeTinyModel(t), yent.LoadOptions{})
+	if _, err := yent.RunBench(m, yent.BenchConfig{PrefillTokens: 30, DecodeTokens: 10}); err == nil {
+		t.Error("bench longer than the context should fail")
+	}
+
+	r, err := yent.RunBench(m, yent.BenchConfig{PrefillTokens: 16, DecodeTokens: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Prefill.Tokens != 16 || r.Decode.Tokens != 8 || r.Prefill.TokensPerSec <= 0 || r.Decode.TokensPerSec <= 0 {
+		t.Errorf("phases: prefill %+v, decode %+v", r.Prefill, r.Decode)
+	}
+	if len(r.LayerMs) != tinyLayers || r.Quant != "F32" || r.Arch != "llama" {
+		t.Errorf("report: %d layer timings, quant %q, arch %q", len(r.LayerMs), r.Quant, r.Arch)
+	}
+	for i, ms := range r.LayerMs {
+		if ms <= 0 {
+			t.Errorf("layer %d: %f ms", i, ms)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestConfigProfiles verifies built-ins, file overrides and the default profile
+func TestConfigProfiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := yent.LoadConfig(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("missing config: %v", err)
+	}
+	rpi, err := cfg.Resolve("rpi")
+	if err != nil || rpi["threads"] != "4" || rpi["ctx"] != "1024" {
+		t.Fatalf("built-in rpi: %v %v", rpi, err)
+	}
+	if none, _ := cfg.Resolve(""); len(none) != 0 {
+		t.Errorf("no profile selected: got %v", none)
+	}
+
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(path, []byte(`{"profile": "bench", "profiles": {
+		"rpi": {"threads": 2},
+		"bench": {"max": 64, "rag": false, "delta": "~/d.npz"}
+	}}`), 0644)
+	cfg, err = yent.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if rpi, _ := cfg.Resolve("rpi"); rpi["threads"] != "2" || rpi["ctx"] != "1024" {
+		t.Errorf("merged rpi: %v", rpi)
+	}
+	bench, err := cfg.Resolve("")
+	if err != nil || bench["max"] != "64" || bench["rag"] != "false" {
+		t.Errorf("default profile: %v %v", bench, err)
+	}
+	if home, _ := os.UserHomeDir(); bench["delta"] != filepath.Join(home, "d.npz") {
+		t.Errorf("home not expanded: %q", bench["delta"])
+	}
+	if _, err := cfg.Resolve("mars"); err == nil {
+		t.Error("unknown profile: expected error")
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestCheckContamination verifies normalized prompt overlap detection against a seed dataset
+func TestCheckContamination(t *testing.T) {
+	dir := t.TempDir()
+	dataset := filepath.Join(dir, "seed.jsonl")
+	os.WriteFile(dataset, []byte(
+		`{"question": "Who are you?", "answer": "I am Yent."}`+"\n"+
+			`{"messages": [{"role": "user", "content": "What is resonance"}, {"role": "assistant", "content": "A field."}]}`+"\n"), 0644)
+
+	shards := filepath.Join(dir, "shards")
+	os.MkdirAll(shards, 0755)
+	os.WriteFile(filepath.Join(shards, "train.jsonl"), []byte(
+		`{"question": "who are   YOU", "answer": "i am yent", "id": 1}`+"\n"+
+			`{"question": "What is resonance?!", "answer": "Something else.", "id": 2}`+"\n"+
+			`{"question": "Tell me about rain", "answer": "Wet.", "id": 3}`+"\n"), 0644)
+
+	report, err := yent.CheckContamination(shards, dataset)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if report.Checked != 3 || report.Flagged != 2 || report.Exact != 1 {
+		t.Errorf("got checked=%d flagged=%d exact=%d, expected 3/2/1",
+			report.Checked, report.Flagged, report.Exact)
+	}
+	if len(report.Hits) != 2 || report.Hits[0].ID != 1 || !report.Hits[0].Exact || report.Hits[1].Exact {
+		t.Errorf("unexpected hits: %+v", report.Hits)
+	}
+	if _, err := os.Stat(filepath.Join(shards, "contamination.json")); err != nil {
+		t.Errorf("report not written: %v", err)
+	}
+}
+
+// TestLoadSeedIndexTemplate verifies the ### Question / ### Answer text format
+func TestLoadSeedIndexTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seed.txt")
+	os.WriteFile(path, []byte("### Question: Who are you?\n### Answer: I am Yent.\n\n### Question: Why?\n### Answer: Because.\n"), 0644)
+
+	idx, err := yent.LoadSeedIndex(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(idx.Prompts) != 2 {
+		t.Errorf("prompts: got %d, expected 2", len(idx.Prompts))
+	}
+	if hit, exact := idx.Check("why", "because"); !hit || !exact {
+		t.Errorf("check: got hit=%v exact=%v, expected true/true", hit, exact)
+	}
+}
+
+// TestCheckContaminationFormats reads openai, sharegpt and alpaca shard exports
+func TestCheckContaminationFormats(t *testing.T) {
+	dir := t.TempDir()
+	dataset := filepath.Join(dir, "seed.jsonl")
+	os.WriteFile(dataset, []byte(`{"question": "Who are you?", "answer": "I am Yent."}`+"\n"), 0644)
+
+	shards := filepath.Join(dir, "shards")
+	os.MkdirAll(shards, 0755)
+	os.WriteFile(filepath.Join(shards, "train.jsonl"), []byte(
+		`{"messages": [{"role": "user", "content": "who are you"}, {"role": "assistant", "content": "I am Yent."}]}`+"\n"+
+			`{"conversations": [{"from": "human", "value": "Who are you?"}, {"from": "gpt", "value": "Nobody."}]}`+"\n"+
+			`{"instruction": "WHO are you", "input": "", "output": "i am yent"}`+"\n"+
+			`{"conversations": [{"from": "human", "value": "Tell me about rain"}, {"from": "gpt", "value": "Wet."}]}`+"\n"), 0644)
+
+	report, err := yent.CheckContamination(shards, dataset)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if report.Checked != 4 || report.Flagged != 3 || report.Exact != 2 {
+		t.Errorf("got checked=%d flagged=%d exact=%d, expected 4/3/2",
+			report.Checked, report.Flagged, report.Exact)
+	}
+}
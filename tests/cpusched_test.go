@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestAffinity pins the workers, checks a parallel matmul still matches
+// one thread, then unpins
+func TestAffinity(t *testing.T) {
+	const rows, cols = 200, 64
+	w := make([]float32, rows*cols)
+	x := make([]float32, cols)
+	for i := range w {
+		w[i] = float32(i%13) - 6
+	}
+	for i := range x {
+		x[i] = float32(i%3) * 0.5
+	}
+	yent.SetThreads(1)
+	want := make([]float32, rows)
+	yent.MatMulF32(want, w, x, rows, cols)
+
+	cores, err := yent.SetAffinity(true)
+	if runtime.GOOS != "linux" {
+		if err == nil {
+			t.Errorf("SetAffinity on %s: no error", runtime.GOOS)
+		}
+		return
+	}
+	if err != nil {
+		t.Skipf("no cpu topology: %v", err)
+	}
+	defer yent.SetAffinity(false)
+	if len(cores) == 0 || len(yent.Affinity()) != len(cores) {
+		t.Fatalf("cores %v, Affinity() %v", cores, yent.Affinity())
+	}
+
+	yent.SetThreads(5)
+	defer yent.SetThreads(0)
+	for _, on := range []bool{true, false} {
+		yent.SetAffinity(on)
+		got := make([]float32, rows)
+		yent.MatMulF32(got, w, x, rows, cols)
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("pinned=%v row %d: %f, want %f", on, i, got[i], want[i])
+			}
+		}
+	}
+	if yent.Affinity() != nil {
+		t.Errorf("still pinned after SetAffinity(false)")
+	}
+}
+
+// TestBackgroundNice checks the level is clamped and a maintenance job
+// still reports its result from the reniced thread
+func TestBackgroundNice(t *testing.T) {
+	yent.SetBackgroundNice(40)
+	defer yent.SetBackgroundNice(0)
+	if n := yent.BackgroundNice(); n != 19 {
+		t.Errorf("BackgroundNice() = %d, want 19", n)
+	}
+	job, err := (&yent.Yent{}).MaintenanceJob("memory-vacuum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := job(context.Background()); err == nil {
+		t.Error("vacuum without LIMPHA succeeded")
+	}
+}
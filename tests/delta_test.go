@@ -0,0 +1,663 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// writeNpy encodes a 2-D float16 ('<f2') or float32 ('<f4') array
+func writeNpy(rows, cols int, data []float32, f16 bool) []byte {
+	descr := "<f4"
+	if f16 {
+		descr = "<f2"
+	}
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%d, %d), }", descr, rows, cols)
+	for (10+len(header)+1)%64 != 0 {
+		header += " "
+	}
+	header += "\n"
+	var b bytes.Buffer
+	b.WriteString("\x93NUMPY\x01\x00")
+	binary.Write(&b, binary.LittleEndian, uint16(len(header)))
+	b.WriteString(header)
+	for _, v := range data {
+		if f16 {
+			binary.Write(&b, binary.LittleEndian, floatToHalf(v))
+		} else {
+			binary.Write(&b, binary.LittleEndian, v)
+		}
+	}
+	return b.Bytes()
+}
+
+// floatToHalf encodes values that are exact in float16 (no rounding)
+func floatToHalf(f float32) uint16 {
+	if f == 0 {
+		return 0
+	}
+	var sign uint16
+	if f < 0 {
+		sign, f = 0x8000, -f
+	}
+	frac, exp := math.Frexp(float64(f)) // f = frac · 2^exp, frac in [0.5, 1)
+	return sign | uint16(exp+14)<<10 | uint16((frac*2-1)*1024)
+}
+
+// TestDeltaApply loads an NPZ delta (A as float32, B as float16) and
+// checks ApplyToLogits against alpha · A·(B·x) in float64
+func TestDeltaApply(t *testing.T) {
+	const vocab, hidden, rank = 100, 48, 8
+	rng := rand.New(rand.NewSource(5))
+	// Multiples of 1/64 in ±2: exact in float16, so both files hold the same values
+	mat := func(n int) []float32 {
+		v := make([]float32, n)
+		for i := range v {
+			v[i] = float32(rng.Intn(257)-128) / 64
+		}
+		return v
+	}
+	a, b := mat(vocab*rank), mat(rank*hidden)
+
+	path := filepath.Join(t.TempDir(), "delta.npz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, data := range map[string][]byte{
+		"A.npy": writeNpy(vocab, rank, a, false),
+		"B.npy": writeNpy(rank, hidden, b, true),
+	} {
+		w, _ := zw.Create(name)
+		w.Write(data)
+	}
+	zw.Close()
+	f.Close()
+
+	d, err := yent.LoadDelta(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.A) != vocab*rank*2 || len(d.B) != rank*hidden*2 {
+		t.Fatalf("A %d bytes, B %d bytes: want float16", len(d.A), len(d.B))
+	}
+	if st, _ := os.Stat(path); !d.ModTime.Equal(st.ModTime()) {
+		t.Errorf("mtime %v, file %v", d.ModTime, st.ModTime())
+	}
+
+	// The same delta as GGUF carries its provenance and identical matrices
+	ggufPath := filepath.Join(t.TempDir(), "delta.gguf")
+	meta := yent.DeltaMeta{BaseModel: "yent-test", BaseHash: "abc", Languages: []string{"ru", "fr"}}
+	if err := yent.WriteDeltaGGUF(ggufPath, d, meta); err != nil {
+		t.Fatal(err)
+	}
+	g, err := yent.LoadDelta(ggufPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Rank != rank || g.VocabSize != vocab || g.HiddenDim != hidden ||
+		!bytes.Equal(g.A, d.A) || !bytes.Equal(g.B, d.B) {
+		t.Errorf("GGUF round trip: %dx%d rank %d", g.VocabSize, g.HiddenDim, g.Rank)
+	}
+	if g.Meta.BaseModel != "yent-test" || g.Meta.BaseHash != "abc" || !slices.Equal(g.Meta.Languages, meta.Languages) {
+		t.Errorf("GGUF meta: %+v", g.Meta)
+	}
+
+	x := make([]float32, hidden)
+	for i := range x {
+		x[i] = float32(rng.NormFloat64())
+	}
+	logits := make([]float32, vocab)
+	for i := range logits {
+		logits[i] = float32(i)
+	}
+	const alpha = 0.5
+	d.ApplyToLogits(logits, x, alpha)
+
+	bx := make([]float64, rank)
+	for r := range bx {
+		for j := 0; j < hidden; j++ {
+			bx[r] += float64(b[r*hidden+j]) * float64(x[j])
+		}
+	}
+	for i := 0; i < vocab; i++ {
+		var dot float64
+		for r := range bx {
+			dot += float64(a[i*rank+r]) * bx[r]
+		}
+		want := float64(i) + alpha*dot
+		if math.Abs(float64(logits[i])-want) > 1e-3*math.Max(1, math.Abs(want)) {
+			t.Fatalf("logit %d: %f, want %f", i, logits[i], want)
+		}
+	}
+}
+
+// TestDeltaSpecs parses -delta specs and checks each named delta in doctor
+func TestDeltaSpecs(t *testing.T) {
+	specs, err := yent.ParseDeltaSpecs("deltas/yent.npz")
+	if err != nil || !slices.Equal(specs, []yent.DeltaSpec{{Name: "default", Path: "deltas/yent.npz"}}) {
+		t.Errorf("bare path: %+v %v", specs, err)
+	}
+	specs, err = yent.ParseDeltaSpecs(" ru = deltas/ru.npz ; fr=deltas/fr.npz;")
+	want := []yent.DeltaSpec{{Name: "ru", Path: "deltas/ru.npz"}, {Name: "fr", Path: "deltas/fr.npz"}}
+	if err != nil || !slices.Equal(specs, want) {
+		t.Errorf("named: %+v %v", specs, err)
+	}
+	for _, bad := range []string{"ru=", "=x.npz", "ru=a.npz;ru=b.npz", "a.npz;b.npz"} {
+		if _, err := yent.ParseDeltaSpecs(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+
+	dir := t.TempDir()
+	checks := yent.Doctor(yent.DoctorOptions{Delta: "ru=" + filepath.Join(dir, "ru.npz") + ";fr=" + filepath.Join(dir, "fr.npz"), DataDir: dir})
+	failed := 0
+	for _, c := range checks {
+		if (c.Name == "delta ru" || c.Name == "delta fr") && c.Status == yent.CheckFail {
+			failed++
+		}
+	}
+	if failed != 2 {
+		t.Errorf("missing deltas: %d failed checks, want 2", failed)
+	}
+}
+
+// TestDeltaEmbed loads a delta with an embedding pair and checks the
+// shifted row against alpha · EmbA[token]·EmbB, from NPZ and GGUF alike
+func TestDeltaEmbed(t *testing.T) {
+	const vocab, hidden, rank, erank = 20, 16, 4, 2
+	rng := rand.New(rand.NewSource(7))
+	mat := func(n int) []float32 {
+		v := make([]float32, n)
+		for i := range v {
+			v[i] = float32(rng.Intn(257)-128) / 64
+		}
+		return v
+	}
+	ea, eb := mat(vocab*erank), mat(erank*hidden)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "delta.npz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, data := range map[string][]byte{
+		"A.npy":       writeNpy(vocab, rank, mat(vocab*rank), true),
+		"B.npy":       writeNpy(rank, hidden, mat(rank*hidden), true),
+		"embed_A.npy": writeNpy(vocab, erank, ea, true),
+		"embed_B.npy": writeNpy(erank, hidden, eb, false),
+	} {
+		w, _ := zw.Create(name)
+		w.Write(data)
+	}
+	zw.Close()
+	f.Close()
+
+	d, err := yent.LoadDelta(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ggufPath := filepath.Join(dir, "delta.gguf")
+	if err := yent.WriteDeltaGGUF(ggufPath, d, yent.DeltaMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	g, err := yent.LoadDelta(ggufPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const token, alpha = 5, 0.5
+	for _, dv := range []*yent.DeltaVoice{d, g} {
+		if !dv.HasEmbed() || dv.EmbRank != erank {
+			t.Fatalf("%s: embed rank %d", dv.Path, dv.EmbRank)
+		}
+		x := make([]float32, hidden)
+		dv.ApplyToEmbedding(x, token, alpha)
+		for j := range x {
+			var want float64
+			for r := 0; r < erank; r++ {
+				want += float64(ea[token*erank+r]) * float64(eb[r*hidden+j])
+			}
+			want *= alpha
+			if math.Abs(float64(x[j])-want) > 1e-3 {
+				t.Fatalf("%s: x[%d] = %f, want %f", dv.Path, j, x[j], want)
+			}
+		}
+	}
+}
+
+// TestDeltaMix checks that a composed delta applies the weighted sum of
+// its parts, and the mix spec parser
+func TestDeltaMix(t *testing.T) {
+	const vocab, hidden = 30, 16
+	rng := rand.New(rand.NewSource(9))
+	mat := func(n int) []float32 {
+		v := make([]float32, n)
+		for i := range v {
+			v[i] = float32(rng.Intn(257)-128) / 64
+		}
+		return v
+	}
+	load := func(name string, rank int) *yent.DeltaVoice {
+		path := filepath.Join(t.TempDir(), name+".npz")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw := zip.NewWriter(f)
+		for entry, data := range map[string][]byte{
+			"A.npy": writeNpy(vocab, rank, mat(vocab*rank), true),
+			"B.npy": writeNpy(rank, hidden, mat(rank*hidden), true),
+		} {
+			w, _ := zw.Create(entry)
+			w.Write(data)
+		}
+		zw.Close()
+		f.Close()
+		d, err := yent.LoadDelta(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	ru, style := load("ru", 4), load("style", 2)
+
+	mix, err := yent.ComposeDeltas([]*yent.DeltaVoice{ru, style}, []float32{0.5, 0.25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mix.Rank != 6 {
+		t.Fatalf("mix rank %d, want 6", mix.Rank)
+	}
+	x := make([]float32, hidden)
+	for i := range x {
+		x[i] = float32(rng.NormFloat64())
+	}
+	want := make([]float32, vocab)
+	ru.ApplyToLogits(want, x, 0.5)
+	style.ApplyToLogits(want, x, 0.25)
+	got := make([]float32, vocab)
+	mix.ApplyToLogits(got, x, 1)
+	for i := range got {
+		if math.Abs(float64(got[i]-want[i])) > 1e-2*math.Max(1, math.Abs(float64(want[i]))) {
+			t.Fatalf("logit %d: mix %f, parts %f", i, got[i], want[i])
+		}
+	}
+
+	other := &yent.DeltaVoice{VocabSize: vocab + 1, HiddenDim: hidden, Rank: 1}
+	if _, err := yent.ComposeDeltas([]*yent.DeltaVoice{ru, other}, []float32{1, 1}); err == nil {
+		t.Error("composed deltas of different vocabularies")
+	}
+
+	parsed, err := yent.ParseDeltaMix("ru:0.5, style:0.3")
+	if err != nil || !slices.Equal(parsed, []yent.DeltaWeight{{Name: "ru", Alpha: 0.5}, {Name: "style", Alpha: 0.3}}) {
+		t.Errorf("mix spec: %+v %v", parsed, err)
+	}
+	for _, bad := range []string{"", "ru", "ru:x", ":0.5", "ru:0.5,ru:0.1"} {
+		if _, err := yent.ParseDeltaMix(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
+
+func TestDeltaSparse(t *testing.T) {
+	const vocab, hidden, rank = 40, 16, 4
+	rng := rand.New(rand.NewSource(11))
+	a := make([]float32, vocab*rank)
+	for i := range a {
+		a[i] = float32(rng.NormFloat64())
+		if i/rank%2 == 1 {
+			a[i] *= 0.01 // odd tokens barely moved
+		}
+	}
+	b := make([]float32, rank*hidden)
+	for i := range b {
+		b[i] = float32(rng.NormFloat64())
+	}
+	path := filepath.Join(t.TempDir(), "delta.npz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for entry, data := range map[string][]byte{
+		"A.npy": writeNpy(vocab, rank, a, true),
+		"B.npy": writeNpy(rank, hidden, b, true),
+	} {
+		w, _ := zw.Create(entry)
+		w.Write(data)
+	}
+	zw.Close()
+	f.Close()
+
+	dense, err := yent.LoadDelta(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sparse, _ := yent.LoadDelta(path)
+	report := sparse.Sparsify(0.1)
+	if !sparse.Sparse() || report.Kept != vocab/2 || report.Rows != vocab {
+		t.Fatalf("kept %d/%d rows, want %d", report.Kept, report.Rows, vocab/2)
+	}
+	if report.DroppedEnergy <= 0 || report.DroppedEnergy > 1e-3 {
+		t.Errorf("dropped energy %g", report.DroppedEnergy)
+	}
+
+	x := make([]float32, hidden)
+	for i := range x {
+		x[i] = float32(rng.NormFloat64())
+	}
+	const alpha = 0.5
+	want := make([]float32, vocab)
+	dense.ApplyToLogits(want, x, alpha)
+	got := make([]float32, vocab)
+	sparse.ApplyToLogits(got, x, alpha)
+	var bx float64
+	for _, v := range sparse.Bx {
+		bx += float64(v) * float64(v)
+	}
+	bound := alpha * float64(report.MaxDropped) * math.Sqrt(bx)
+	for i := range got {
+		diff := math.Abs(float64(got[i] - want[i]))
+		if i%2 == 0 && diff > 1e-3*math.Max(1, math.Abs(float64(want[i]))) {
+			t.Errorf("kept logit %d: sparse %f, dense %f", i, got[i], want[i])
+		}
+		if diff > bound+1e-4 {
+			t.Errorf("logit %d moved %g, bound %g", i, diff, bound)
+		}
+	}
+
+	full := sparse.DenseA()
+	for i := 0; i < vocab; i++ {
+		for r := 0; r < rank; r++ {
+			j := (i*rank + r) * 2
+			v := binary.LittleEndian.Uint16(full[j:])
+			if i%2 == 0 && v != binary.LittleEndian.Uint16(dense.A[j:]) || i%2 == 1 && v != 0 {
+				t.Fatalf("DenseA row %d col %d: %#x", i, r, v)
+			}
+		}
+	}
+}
+
+// writeHeadGGUF writes a llama GGUF with a vocab of n tokens and only an
+// F32 output.weight [vocab × dim]
+func writeHeadGGUF(t *testing.T, name string, vocab, dim int, head []float32) string {
+	t.Helper()
+	tokens := make([]string, vocab)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("t%d", i)
+	}
+	return writeHeadGGUFTokens(t, name, tokens, dim, head)
+}
+
+// writeHeadGGUFTokens is writeHeadGGUF with the given token list
+func writeHeadGGUFTokens(t *testing.T, name string, tokens []string, dim int, head []float32) string {
+	t.Helper()
+	var g ggufBuilder
+	g.u32("llama.embedding_length", uint32(dim))
+	g.strs("tokenizer.ggml.tokens", tokens...)
+	g.tensor("output.weight", head, uint64(dim), uint64(len(tokens)))
+	return g.write(t, name+".gguf")
+}
+
+// TestDeltaExtract factors a rank-3 head difference and checks that the
+// extracted delta reproduces it
+func TestDeltaExtract(t *testing.T) {
+	const vocab, dim, trueRank = 60, 32, 3
+	rng := rand.New(rand.NewSource(11))
+	tuned := make([]float32, vocab*dim)
+	for i := range tuned {
+		tuned[i] = float32(rng.NormFloat64())
+	}
+	u, v := make([]float64, vocab*trueRank), make([]float64, trueRank*dim)
+	for i := range u {
+		u[i] = rng.NormFloat64()
+	}
+	for i := range v {
+		v[i] = rng.NormFloat64() / 4
+	}
+	diff := make([]float64, vocab*dim)
+	base := make([]float32, vocab*dim)
+	for i := 0; i < vocab; i++ {
+		for j := 0; j < dim; j++ {
+			for r := 0; r < trueRank; r++ {
+				diff[i*dim+j] += u[i*trueRank+r] * v[r*dim+j]
+			}
+			base[i*dim+j] = tuned[i*dim+j] + float32(diff[i*dim+j])
+		}
+	}
+
+	out := filepath.Join(t.TempDir(), "delta.gguf")
+	report, err := yent.ExtractDelta(writeHeadGGUF(t, "base", vocab, dim, base),
+		writeHeadGGUF(t, "yent", vocab, dim, tuned), out, yent.ExtractOptions{Rank: 4, Languages: []string{"ru"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Energy < 0.999 {
+		t.Errorf("rank 4 keeps %.4f of a rank-3 difference", report.Energy)
+	}
+
+	d, err := yent.LoadDelta(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Rank != 4 || d.Meta.BaseHash == "" || !slices.Equal(d.Meta.Languages, []string{"ru"}) {
+		t.Errorf("delta: rank %d, meta %+v", d.Rank, d.Meta)
+	}
+	// A @ (B @ x) against Δ @ x
+	x := make([]float32, dim)
+	for i := range x {
+		x[i] = float32(rng.NormFloat64())
+	}
+	got := make([]float32, vocab)
+	d.ApplyToLogits(got, x, 1)
+	for i := 0; i < vocab; i++ {
+		var want float64
+		for j := 0; j < dim; j++ {
+			want += diff[i*dim+j] * float64(x[j])
+		}
+		if math.Abs(float64(got[i])-want) > 0.02*math.Max(1, math.Abs(want)) {
+			t.Fatalf("row %d: delta %f, difference %f", i, got[i], want)
+		}
+	}
+}
+
+// TestDeltaFingerprint refuses a delta built for another vocabulary of
+// the same size
+func TestDeltaFingerprint(t *testing.T) {
+	const vocab, dim, rank = 20, 8, 2
+	head := make([]float32, vocab*dim)
+	model := writeHeadGGUF(t, "yent", vocab, dim, head)
+	shuffled := make([]string, vocab)
+	for i := range shuffled {
+		shuffled[i] = fmt.Sprintf("t%d", vocab-1-i) // same tokens, other ids
+	}
+	foreign := writeHeadGGUFTokens(t, "foreign", shuffled, dim, head)
+
+	npz := filepath.Join(t.TempDir(), "delta.npz")
+	f, err := os.Create(npz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for entry, data := range map[string][]byte{
+		"A.npy": writeNpy(vocab, rank, make([]float32, vocab*rank), true),
+		"B.npy": writeNpy(rank, dim, make([]float32, rank*dim), true),
+	} {
+		w, _ := zw.Create(entry)
+		w.Write(data)
+	}
+	zw.Close()
+	f.Close()
+
+	// An NPZ has no fingerprint: shape alone passes it
+	if _, err := yent.ValidateDelta(npz, foreign); err != nil {
+		t.Errorf("NPZ delta: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "delta.gguf")
+	if _, err := yent.ConvertDelta(npz, out, model, nil); err != nil {
+		t.Fatal(err)
+	}
+	d, err := yent.ValidateDelta(out, model)
+	if err != nil {
+		t.Fatalf("own model: %v", err)
+	}
+	if d.Meta.VocabHash == "" || d.Meta.TokenizerHash == "" {
+		t.Fatalf("no fingerprint recorded: %+v", d.Meta)
+	}
+	if _, err := yent.ValidateDelta(out, foreign); err == nil || !strings.Contains(err.Error(), "different vocabulary") {
+		t.Errorf("foreign vocabulary: %v", err)
+	}
+
+	g, err := yent.LoadGGUFMetadata(foreign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A delta written before the vocab hash still has its base hash
+	d.Meta.VocabHash, d.Meta.TokenizerHash = "", ""
+	if _, err := d.CheckBase(&g.Meta); err == nil {
+		t.Error("base hash mismatch passed")
+	}
+	mg, _ := yent.LoadGGUFMetadata(model)
+	d.Meta = yent.DeltaMeta{}
+	d.Meta.SetBase(&mg.Meta)
+	d.Meta.TokenizerHash = "other"
+	if warning, err := d.CheckBase(&mg.Meta); err != nil || warning == "" {
+		t.Errorf("tokenizer mismatch: warning %q, err %v", warning, err)
+	}
+}
+
+// writeSafetensors writes F32 tensors (name → shape, values) as safetensors
+func writeSafetensors(t *testing.T, path string, tensors map[string][]float32, shapes map[string][]int) {
+	t.Helper()
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	header := map[string]interface{}{"__metadata__": map[string]string{"format": "pt"}}
+	var body bytes.Buffer
+	for _, name := range names {
+		start := body.Len()
+		binary.Write(&body, binary.LittleEndian, tensors[name])
+		header[name] = map[string]interface{}{"dtype": "F32", "shape": shapes[name], "data_offsets": []int{start, body.Len()}}
+	}
+	h, _ := json.Marshal(header)
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint64(len(h)))
+	b.Write(h)
+	b.Write(body.Bytes())
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDeltaLoRA loads a PEFT adapter directory and checks the delta is
+// scaling · lora_B @ lora_A, with other adapted matrices ignored
+func TestDeltaLoRA(t *testing.T) {
+	const vocab, hidden, r = 24, 16, 4
+	rng := rand.New(rand.NewSource(13))
+	mat := func(n int) []float32 {
+		v := make([]float32, n)
+		for i := range v {
+			v[i] = float32(rng.Intn(129)-64) / 64
+		}
+		return v
+	}
+	loraA, loraB := mat(r*hidden), mat(vocab*r)
+	dir := t.TempDir()
+	writeSafetensors(t, filepath.Join(dir, "adapter_model.safetensors"), map[string][]float32{
+		"base_model.model.lm_head.lora_A.weight":                         loraA,
+		"base_model.model.lm_head.lora_B.weight":                         loraB,
+		"base_model.model.model.layers.0.self_attn.q_proj.lora_A.weight": mat(r * hidden),
+	}, map[string][]int{
+		"base_model.model.lm_head.lora_A.weight":                         {r, hidden},
+		"base_model.model.lm_head.lora_B.weight":                         {vocab, r},
+		"base_model.model.model.layers.0.self_attn.q_proj.lora_A.weight": {r, hidden},
+	})
+	config := `{"r": 4, "lora_alpha": 8, "base_model_name_or_path": "Qwen/Qwen2.5-0.5B"}`
+	if err := os.WriteFile(filepath.Join(dir, "adapter_config.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := yent.LoadDelta(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Rank != r || d.VocabSize != vocab || d.HiddenDim != hidden || d.Meta.BaseModel != "Qwen/Qwen2.5-0.5B" {
+		t.Fatalf("LoRA delta: %dx%d rank %d, base %q", d.VocabSize, d.HiddenDim, d.Rank, d.Meta.BaseModel)
+	}
+	x := make([]float32, hidden)
+	for i := range x {
+		x[i] = float32(rng.NormFloat64())
+	}
+	got := make([]float32, vocab)
+	d.ApplyToLogits(got, x, 1)
+	const scaling = 8.0 / 4
+	for i := 0; i < vocab; i++ {
+		var want float64
+		for k := 0; k < r; k++ {
+			var ax float64
+			for j := 0; j < hidden; j++ {
+				ax += float64(loraA[k*hidden+j]) * float64(x[j])
+			}
+			want += scaling * float64(loraB[i*r+k]) * ax
+		}
+		if math.Abs(float64(got[i])-want) > 1e-2*math.Max(1, math.Abs(want)) {
+			t.Fatalf("logit %d: %f, want %f", i, got[i], want)
+		}
+	}
+
+	// Only attention adapted: nothing a delta can use
+	other := t.TempDir()
+	writeSafetensors(t, filepath.Join(other, "adapter_model.safetensors"),
+		map[string][]float32{"q_proj.lora_A.weight": mat(r * hidden)},
+		map[string][]int{"q_proj.lora_A.weight": {r, hidden}})
+	if _, err := yent.LoadDelta(other); err == nil {
+		t.Error("loaded an adapter without lm_head")
+	}
+}
+
+// TestVoiceState remembers alpha and the active delta across instances
+func TestVoiceState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "yent", "voice.json")
+	if s, err := yent.LoadVoiceState(path); s != nil || err != nil {
+		t.Fatalf("no file: %+v %v", s, err)
+	}
+	y := &yent.Yent{VoiceStatePath: path}
+	y.SetAlpha(0.5)
+	s, err := yent.LoadVoiceState(path)
+	if err != nil || s == nil || s.Alpha != 0.5 || s.Delta != "" {
+		t.Fatalf("saved %+v %v", s, err)
+	}
+
+	s.Delta = "ru" // not loaded in the next run
+	if err := yent.SaveVoiceState(path, *s); err != nil {
+		t.Fatal(err)
+	}
+	next := &yent.Yent{}
+	saved, _ := yent.LoadVoiceState(path)
+	next.RestoreVoice(saved)
+	if v := next.Voice(); v.Alpha != 0.5 || v.Delta != "" {
+		t.Errorf("restored %+v", v)
+	}
+
+	os.WriteFile(path, []byte("{"), 0644)
+	if _, err := yent.LoadVoiceState(path); err == nil {
+		t.Error("broken file loaded")
+	}
+}
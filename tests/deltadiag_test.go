@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"archive/zip"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestDiagnoseDelta loads a rank-1 delta that moves four known tokens only
+// ("B" +2, "0" +1, "C" -1, "1" -2 times the same B·x) and checks the
+// lists it is taken apart into and the per-script coverage
+func TestDiagnoseDelta(t *testing.T) {
+	const tokB, tokC, tok0, tok1 = 10, 11, 28, 29
+	y := newTinyYent(t, "")
+	a := make([]float32, tinyVocab)
+	a[tokB], a[tok0], a[tokC], a[tok1] = 2, 1, -1, -2
+	rng := rand.New(rand.NewSource(4))
+	b := make([]float32, tinyDim)
+	for i := range b {
+		b[i] = float32(rng.Intn(129)-64) / 64
+	}
+	path := filepath.Join(t.TempDir(), "diag.npz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, data := range map[string][]byte{
+		"A.npy": writeNpy(tinyVocab, 1, a, true),
+		"B.npy": writeNpy(1, tinyDim, b, true),
+	} {
+		w, _ := zw.Create(name)
+		w.Write(data)
+	}
+	zw.Close()
+	f.Close()
+	if err := y.LoadDeltaVoice("diag", path); err != nil {
+		t.Fatal(err)
+	}
+
+	dd, err := y.DiagnoseDelta("", "hello there", 0.5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dd.Delta != "diag" || dd.Alpha != 0.5 || len(dd.Before) != 2 || len(dd.After) != 2 {
+		t.Fatalf("diagnosis: %+v", dd)
+	}
+	ids := func(ts []yent.TokenShift) []int {
+		var out []int
+		for _, s := range ts {
+			out = append(out, s.Token)
+		}
+		return out
+	}
+	// B·x has either sign: the boosted side is the one "B" is on
+	up, down := []int{tokB, tok0}, []int{tok1, tokC}
+	if dd.Boosted[0].Token == tok1 {
+		up, down = down, up
+	}
+	if got := ids(dd.Boosted); !slices.Equal(got, up) {
+		t.Errorf("boosted %v, want %v", got, up)
+	}
+	if got := ids(dd.Suppressed); !slices.Equal(got, down) {
+		t.Errorf("suppressed %v, want %v", got, down)
+	}
+	near := func(a, b float32) bool { return math.Abs(float64(a-b)) < 1e-5 }
+	if s := dd.Boosted; s[0].Shift <= 0 || !near(s[0].Shift, 2*s[1].Shift) || !near(dd.Suppressed[0].Shift, -s[0].Shift) {
+		t.Errorf("shifts: boosted %+v, suppressed %+v", dd.Boosted, dd.Suppressed)
+	}
+
+	// The tiny vocab: 48 Latin letters (with Ġ and Ċ), 16 marks and digits
+	want := map[string][3]int{"latin": {48, 2, 1}, "none": {16, 2, 1}}
+	if len(dd.Coverage) != len(want) {
+		t.Fatalf("coverage: %+v", dd.Coverage)
+	}
+	for _, c := range dd.Coverage {
+		if w := want[c.Script]; c.Tokens != w[0] || c.Touched != w[1] || c.Boosted != w[2] {
+			t.Errorf("%s: %d tokens, %d touched, %d boosted; want %v", c.Script, c.Tokens, c.Touched, c.Boosted, w)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// cyrillicToken is the tiny vocab's "z", renamed "д" by newGateYent
+const cyrillicToken = tinyVocab - 1
+
+// newGateYent loads the tiny llama with one Cyrillic token and a head
+// delta at alpha 0.5, gated over the last gate tokens
+func newGateYent(t *testing.T, gate int) *yent.Yent {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	tokens := tinyTokens()
+	tokens[cyrillicToken] = "д"
+	y, err := yent.NewWithOptions(tinyModelGGUFTokens(tokens).write(t, "cyrillic.gguf"), yent.LoadOptions{SeqLen: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(y.Close)
+	if err := y.LoadDeltaVoice("ru", writeTinyDelta(t, false)); err != nil {
+		t.Fatal(err)
+	}
+	y.DeltaAlpha, y.DeltaGate = 0.5, gate
+	return y
+}
+
+// gated runs one generation that draws no non-ASCII token and returns
+// how many tokens ran with the delta and how many skipped it. The tiny
+// vocab is not byte-level, so "Ġ" and "Ċ" (0, 1) read as non-ASCII too.
+func gated(t *testing.T, y *yent.Yent, prompt string, opts yent.GenerateOptions) (applied, skipped int) {
+	t.Helper()
+	opts.MaxTokens, opts.Seed, opts.Deterministic = 24, 5, true
+	opts.Logprobs, opts.NoStore = true, true
+	opts.LogitBias = map[int]float32{0: -1e4, 1: -1e4, cyrillicToken: -1e4}
+	res, err := y.GenerateWithOptions(prompt, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Tokens) == 0 {
+		t.Fatal("no tokens")
+	}
+	return len(res.Tokens) - res.DeltaSkipped, res.DeltaSkipped
+}
+
+// TestDeltaGate checks that an ASCII-only context skips the delta on
+// every token, and that DeltaAlways and a gate of 0 keep it on
+func TestDeltaGate(t *testing.T) {
+	y := newGateYent(t, 8)
+	if applied, skipped := gated(t, y, "hello there", yent.GenerateOptions{}); applied != 0 || skipped == 0 {
+		t.Errorf("ASCII context: delta on %d tokens, skipped %d; want 0 and all", applied, skipped)
+	}
+	if _, skipped := gated(t, y, "hello there", yent.GenerateOptions{DeltaAlways: true}); skipped != 0 {
+		t.Errorf("DeltaAlways: skipped %d", skipped)
+	}
+	y.DeltaGate = 0
+	if _, skipped := gated(t, y, "hello there", yent.GenerateOptions{}); skipped != 0 {
+		t.Errorf("gate 0: skipped %d", skipped)
+	}
+}
+
+// TestDeltaGateReopens checks that one Cyrillic token in the context opens
+// the gate for exactly the next n tokens: a wider gate keeps the delta on
+// for as many more
+func TestDeltaGateReopens(t *testing.T) {
+	y := newGateYent(t, 0)
+	var applied [2]int
+	for i, n := range []int{16, 19} {
+		y.DeltaGate = n
+		var skipped int
+		applied[i], skipped = gated(t, y, "hello д", yent.GenerateOptions{})
+		if applied[i] == 0 || skipped == 0 {
+			t.Fatalf("gate %d: delta on %d tokens, skipped %d; want both", n, applied[i], skipped)
+		}
+	}
+	if applied[1]-applied[0] != 3 {
+		t.Errorf("gates 16 and 19: delta on %d and %d tokens, want 3 apart", applied[0], applied[1])
+	}
+}
+
+// TestDeltaGateAlpha checks that the gate follows the call's alpha, not
+// the instance's: opts.Alpha 0 turns the delta off, and a per-call alpha
+// over an instance at 0 turns it and the gate on
+func TestDeltaGateAlpha(t *testing.T) {
+	y := newGateYent(t, 8)
+	off := float32(0)
+	if _, skipped := gated(t, y, "hello there", yent.GenerateOptions{Alpha: &off}); skipped != 0 {
+		t.Errorf("alpha 0 for the call: skipped %d", skipped)
+	}
+	y.DeltaAlpha = 0
+	on := float32(0.5)
+	if applied, skipped := gated(t, y, "hello there", yent.GenerateOptions{Alpha: &on}); applied != 0 || skipped == 0 {
+		t.Errorf("alpha 0.5 for the call: delta on %d tokens, skipped %d", applied, skipped)
+	}
+}
@@ -0,0 +1,38 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestDoctor verifies broken weights fail with a fix and a clean data dir passes
+func TestDoctor(t *testing.T) {
+	dir := t.TempDir()
+	weights := filepath.Join(dir, "broken.gguf")
+	os.WriteFile(weights, []byte("not a gguf"), 0644)
+
+	checks := yent.Doctor(yent.DoctorOptions{Weights: weights, DataDir: dir})
+	byName := make(map[string]yent.DoctorCheck)
+	for _, c := range checks {
+		byName[c.Name] = c
+	}
+
+	if c := byName["weights"]; c.Status != yent.CheckFail || c.Fix == "" {
+		t.Errorf("broken weights: %+v", c)
+	}
+	if c := byName["quant"]; c.Status != yent.CheckSkip {
+		t.Errorf("quant without weights: %+v", c)
+	}
+	if c := byName["amk"]; c.Status != yent.CheckOK {
+		t.Errorf("amk: %+v", c)
+	}
+	if c := byName["data-dir"]; c.Status != yent.CheckOK {
+		t.Errorf("data dir: %+v", c)
+	}
+	if !yent.DoctorFailed(checks) {
+		t.Error("expected DoctorFailed")
+	}
+}
@@ -0,0 +1,37 @@
+package tests
+
+import (
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestDRYPenalties verifies repeats are penalized by length and breakers stop them
+func TestDRYPenalties(t *testing.T) {
+	cfg := yent.DefaultDRYConfig()
+	noBreak := func(int) bool { return false }
+
+	// 1 2 3 4 ... 1 2 3 → 4 would extend a length-3 repeat
+	history := []int{1, 2, 3, 4, 9, 9, 1, 2, 3}
+	p := cfg.Penalties(history, noBreak)
+	want := cfg.Multiplier * cfg.Base // length 3, allowed 2 → base^1
+	if got := p[4]; got < want-1e-4 || got > want+1e-4 {
+		t.Errorf("penalty for 4: got %.4f, expected %.4f", got, want)
+	}
+
+	// A single repeated token is below the allowed length
+	if p := cfg.Penalties([]int{5, 6, 7, 5}, noBreak); len(p) != 0 {
+		t.Errorf("short repeat penalized: %v", p)
+	}
+
+	// A breaker inside the repeat cuts the match short
+	breaker := func(tok int) bool { return tok == 2 }
+	if p := cfg.Penalties(history, breaker); p[4] != 0 {
+		t.Errorf("breaker ignored: %v", p)
+	}
+
+	// Off when the multiplier is zero
+	if p := (yent.DRYConfig{}).Penalties(history, noBreak); p != nil {
+		t.Errorf("disabled DRY returned %v", p)
+	}
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := yent.NewEventBus()
+	if bus.Wants(yent.EventToken) {
+		t.Fatal("empty bus wants events")
+	}
+
+	all := bus.Subscribe(4)
+	done := bus.Subscribe(1, yent.EventGenerationFinished)
+	if !bus.Wants(yent.EventToken) {
+		t.Fatal("catch-all subscriber should want tokens")
+	}
+
+	bus.Publish(yent.Event{Kind: yent.EventToken, Data: map[string]interface{}{"piece": " I"}})
+	bus.Publish(yent.Event{Kind: yent.EventGenerationFinished})
+	bus.Publish(yent.Event{Kind: yent.EventGenerationFinished}) // done's buffer is full
+
+	if e := <-all.C; e.Kind != yent.EventToken || e.Data["piece"] != " I" || e.Time.IsZero() {
+		t.Errorf("first event = %+v", e)
+	}
+	if e := <-done.C; e.Kind != yent.EventGenerationFinished {
+		t.Errorf("filtered subscriber got %s", e.Kind)
+	}
+	if done.Dropped() != 1 || all.Dropped() != 0 {
+		t.Errorf("dropped = %d/%d, want 1/0", done.Dropped(), all.Dropped())
+	}
+
+	all.Close()
+	all.Close()
+	if bus.Wants(yent.EventToken) {
+		t.Error("closed subscriber still counted")
+	}
+	done.Close()
+	if _, open := <-done.C; open {
+		t.Error("C still open after Close")
+	}
+}
+
+func TestEventBusOn(t *testing.T) {
+	bus := yent.NewEventBus()
+	got := make(chan yent.Event, 4)
+	sub := bus.On(func(e yent.Event) { got <- e }, yent.EventWormhole, yent.EventPain)
+	if !bus.WantsAny(yent.EventDebt, yent.EventPain) || bus.WantsAny(yent.EventDebt) {
+		t.Fatal("WantsAny does not follow the callback's kinds")
+	}
+
+	bus.Publish(yent.Event{Kind: yent.EventDebt})
+	bus.Publish(yent.Event{Kind: yent.EventWormhole, Data: map[string]interface{}{"token": 3}})
+	select {
+	case e := <-got:
+		if e.Kind != yent.EventWormhole || e.Data["token"] != 3 {
+			t.Errorf("callback got %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("callback not called")
+	}
+
+	sub.Close()
+	if bus.WantsAny(yent.EventWormhole, yent.EventPain) {
+		t.Error("closed callback still counted")
+	}
+}
+
+func TestEventsZeroInstance(t *testing.T) {
+	y := &yent.Yent{}
+	if y.Events() == nil || y.Events() != y.Events() {
+		t.Fatal("Events should create one bus on first use")
+	}
+}
@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// generatedTokens runs one generation and returns its token ids
+func generatedTokens(t *testing.T, y *yent.Yent, prompt string, opts yent.GenerateOptions) []int {
+	t.Helper()
+	opts.Logprobs, opts.NoStore = true, true
+	res, err := y.GenerateWithOptions(prompt, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int, len(res.Tokens))
+	for i, tl := range res.Tokens {
+		ids[i] = tl.Token
+	}
+	return ids
+}
+
+// TestGenerateSeed checks that a seeded, deterministic call gives the same
+// tokens every time, whatever ran in between, and that the seed matters
+func TestGenerateSeed(t *testing.T) {
+	y := newTinyYent(t, "")
+	opts := func(seed int64) yent.GenerateOptions {
+		return yent.GenerateOptions{MaxTokens: 12, Temperature: 1, TopK: 40, Seed: seed, Deterministic: true}
+	}
+
+	first := generatedTokens(t, y, "what is this", opts(42))
+	if len(first) == 0 {
+		t.Fatal("no tokens")
+	}
+	generatedTokens(t, y, "something else entirely", yent.GenerateOptions{MaxTokens: 12})
+	if again := generatedTokens(t, y, "what is this", opts(42)); fmt.Sprint(again) != fmt.Sprint(first) {
+		t.Errorf("seed 42 gave %v, then %v", first, again)
+	}
+
+	differs := false
+	for seed := int64(1); seed <= 5 && !differs; seed++ {
+		differs = fmt.Sprint(generatedTokens(t, y, "what is this", opts(seed))) != fmt.Sprint(first)
+	}
+	if !differs {
+		t.Errorf("seeds 1-5 all gave seed 42's tokens %v", first)
+	}
+}
+
+// TestGenerateCancel checks that cancelling mid-answer returns the tokens
+// so far with context.Canceled, and that the partial answer is not stored
+func TestGenerateCancel(t *testing.T) {
+	lim := newFakeLimpha(t, nil)
+	y := newTinyYent(t, lim.Socket)
+	stored := y.Events().Subscribe(4, yent.EventMemoryStored)
+	defer stored.Close()
+	waitStored := func() {
+		t.Helper()
+		select {
+		case <-stored.C:
+		case <-time.After(5 * time.Second):
+			t.Fatal("turn never stored")
+		}
+	}
+
+	if _, err := y.GenerateWithOptions("first", yent.GenerateOptions{MaxTokens: 2}); err != nil {
+		t.Fatal(err)
+	}
+	waitStored()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := 0
+	res, err := y.GenerateContext(ctx, "second", yent.GenerateOptions{
+		MaxTokens: 40, Logprobs: true,
+		OnToken: func(int, string) bool {
+			if n++; n == 3 {
+				cancel()
+			}
+			return true
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if res == nil || len(res.Tokens) != 3 || res.Text == "" {
+		t.Fatalf("partial result: %+v", res)
+	}
+
+	if res, err := y.GenerateContext(ctx, "third", yent.GenerateOptions{}); !errors.Is(err, context.Canceled) || res == nil || res.Text != "" {
+		t.Errorf("already cancelled: %+v, %v", res, err)
+	}
+
+	// The next full answer is the second turn stored
+	if _, err := y.GenerateWithOptions("fourth", yent.GenerateOptions{MaxTokens: 2}); err != nil {
+		t.Fatal(err)
+	}
+	waitStored()
+	select {
+	case e := <-stored.C:
+		t.Errorf("extra store: %+v", e)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if n := lim.stores(); n != 2 {
+		t.Errorf("%d turns stored, want 2", n)
+	}
+}
+
+// TestGenerateCrashReport checks that a panic in the token loop becomes an
+// error and a report in the crash dir, without the prompt, and that the
+// next call is served
+func TestGenerateCrashReport(t *testing.T) {
+	y := newTinyYent(t, "")
+	y.CrashDir = t.TempDir()
+	const prompt = "a secret question"
+
+	n := 0
+	res, err := y.GenerateWithOptions(prompt, yent.GenerateOptions{
+		MaxTokens: 8, NoStore: true,
+		OnToken: func(int, string) bool {
+			if n++; n == 2 {
+				panic("boom")
+			}
+			return true
+		},
+	})
+	if !errors.Is(err, yent.ErrGenerationPanic) || res == nil {
+		t.Fatalf("res %v, err %v", res, err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(y.CrashDir, "crash-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("crash reports: %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), prompt) {
+		t.Error("report holds the prompt")
+	}
+	var rep yent.CrashReport
+	if err := json.Unmarshal(data, &rep); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(prompt))
+	if rep.Panic != "boom" || rep.Phase != "decode" || rep.PromptSHA256 != hex.EncodeToString(sum[:]) ||
+		rep.PromptBytes != len(prompt) || len(rep.LastTokens) == 0 || rep.Model.Vocab != tinyVocab || rep.Stack == "" {
+		t.Errorf("report: %+v", rep)
+	}
+
+	// The model lock was released
+	if _, err := y.GenerateWithOptions(prompt, yent.GenerateOptions{MaxTokens: 2, NoStore: true}); err != nil {
+		t.Fatalf("after the crash: %v", err)
+	}
+
+	// A report that cannot be written still leaves an error
+	y.CrashDir = files[0]
+	_, err = y.GenerateWithOptions(prompt, yent.GenerateOptions{
+		MaxTokens: 2, NoStore: true,
+		OnToken: func(int, string) bool { panic("again") },
+	})
+	if !errors.Is(err, yent.ErrGenerationPanic) {
+		t.Errorf("unwritable crash dir: %v", err)
+	}
+}
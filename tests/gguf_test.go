@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// ggufBuilder assembles a GGUF v3 file in memory: metadata in the order it
+// is added, then F32 tensors, each aligned to 32 bytes
+type ggufBuilder struct {
+	meta    bytes.Buffer
+	nMeta   int
+	tensors []ggufTensor
+}
+
+type ggufTensor struct {
+	name string
+	dims []uint64 // GGUF order: columns first
+	data []float32
+}
+
+func ggufWrite(b *bytes.Buffer, v interface{}) { binary.Write(b, binary.LittleEndian, v) }
+
+func ggufString(b *bytes.Buffer, s string) {
+	ggufWrite(b, uint64(len(s)))
+	b.WriteString(s)
+}
+
+// key starts a metadata entry of the given GGUF value type
+func (g *ggufBuilder) key(key string, typ uint32) {
+	g.nMeta++
+	ggufString(&g.meta, key)
+	ggufWrite(&g.meta, typ)
+}
+
+func (g *ggufBuilder) u32(key string, v uint32) {
+	g.key(key, 4)
+	ggufWrite(&g.meta, v)
+}
+
+func (g *ggufBuilder) f32(key string, v float32) {
+	g.key(key, 6)
+	ggufWrite(&g.meta, v)
+}
+
+func (g *ggufBuilder) str(key, v string) {
+	g.key(key, 8)
+	ggufString(&g.meta, v)
+}
+
+func (g *ggufBuilder) strs(key string, items ...string) {
+	g.key(key, 9)                 // array
+	ggufWrite(&g.meta, uint32(8)) // of strings
+	ggufWrite(&g.meta, uint64(len(items)))
+	for _, s := range items {
+		ggufString(&g.meta, s)
+	}
+}
+
+// tensor adds an F32 tensor; dims are in GGUF order (columns first)
+func (g *ggufBuilder) tensor(name string, data []float32, dims ...uint64) {
+	g.tensors = append(g.tensors, ggufTensor{name, dims, data})
+}
+
+// write saves the file under name in a temp dir and returns its path
+func (g *ggufBuilder) write(t *testing.T, name string) string {
+	t.Helper()
+	var b bytes.Buffer
+	ggufWrite(&b, uint32(0x46554747))
+	ggufWrite(&b, uint32(3))
+	ggufWrite(&b, uint64(len(g.tensors)))
+	ggufWrite(&b, uint64(g.nMeta))
+	b.Write(g.meta.Bytes())
+
+	var off uint64
+	for _, ts := range g.tensors {
+		ggufString(&b, ts.name)
+		ggufWrite(&b, uint32(len(ts.dims)))
+		for _, d := range ts.dims {
+			ggufWrite(&b, d)
+		}
+		ggufWrite(&b, uint32(0)) // F32
+		ggufWrite(&b, off)
+		off += uint64(len(ts.data) * 4)
+		off = (off + 31) &^ 31
+	}
+	for b.Len()%32 != 0 {
+		b.WriteByte(0)
+	}
+	for _, ts := range g.tensors {
+		ggufWrite(&b, ts.data)
+		for b.Len()%32 != 0 {
+			b.WriteByte(0)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeTinyGGUF writes a GGUF v3 with a three-token gpt2 vocab and one F32 tensor
+func writeTinyGGUF(t *testing.T) string {
+	t.Helper()
+	var g ggufBuilder
+	g.str("tokenizer.ggml.model", "gpt2")
+	g.strs("tokenizer.ggml.tokens", "a", "b", "ab")
+	g.strs("tokenizer.ggml.merges", "a b")
+	g.tensor("x", []float32{1, 2, 3, 4}, 4)
+	return g.write(t, "tiny.gguf")
+}
+
+// TestLoadGGUFMetadata checks that metadata-only loads skip tensor data but keep the vocab
+func TestLoadGGUFMetadata(t *testing.T) {
+	path := writeTinyGGUF(t)
+
+	meta, err := yent.LoadGGUFMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Meta.VocabSize != 3 || len(meta.Tensors) != 1 || meta.TensorData != nil {
+		t.Errorf("unexpected metadata-only load: vocab %d, %d tensors, %d data bytes",
+			meta.Meta.VocabSize, len(meta.Tensors), len(meta.TensorData))
+	}
+	if _, _, err := meta.GetTensor("x"); err == nil {
+		t.Error("GetTensor should fail without tensor data")
+	}
+
+	full, err := yent.LoadGGUF(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _, err := full.GetTensor("x"); err != nil || len(data) != 16 {
+		t.Errorf("full load: %d bytes, %v", len(data), err)
+	}
+
+	tok, err := yent.LoadTokenizer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := tok.Encode("ab", false); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("Encode(ab) = %v, expected [2]", ids)
+	}
+}
@@ -0,0 +1,229 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// Tiny llama: 2 layers, dim 64, 4 heads (2 KV), FFN 128, 64-token vocab
+const (
+	tinyDim, tinyHeads, tinyKVHeads, tinyFF, tinyLayers, tinyVocab = 64, 4, 2, 128, 2, 64
+)
+
+// writeTinyModel writes a random-weight llama GGUF with F32 tensors
+func writeTinyModel(t *testing.T) string {
+	t.Helper()
+	return tinyModelGGUF().write(t, "tiny-llama.gguf")
+}
+
+// tinyModelGGUF builds writeTinyModel's GGUF; callers may add metadata
+// before writing it
+func tinyModelGGUF() *ggufBuilder {
+	return tinyModelGGUFTokens(tinyTokens())
+}
+
+// tinyModelGGUFTokens is tinyModelGGUF over another vocab of tinyVocab tokens
+func tinyModelGGUFTokens(tokens []string) *ggufBuilder {
+	rng := rand.New(rand.NewSource(7))
+	g := &ggufBuilder{}
+	g.str("general.architecture", "llama")
+	g.u32("llama.block_count", tinyLayers)
+	g.u32("llama.embedding_length", tinyDim)
+	g.u32("llama.attention.head_count", tinyHeads)
+	g.u32("llama.attention.head_count_kv", tinyKVHeads)
+	g.u32("llama.feed_forward_length", tinyFF)
+	g.u32("llama.context_length", 32)
+	g.strs("tokenizer.ggml.tokens", tokens...)
+
+	add := func(name string, scale float32, dims ...uint64) {
+		n := uint64(1)
+		for _, d := range dims {
+			n *= d
+		}
+		data := make([]float32, n)
+		for i := range data {
+			if scale == 0 {
+				data[i] = 1
+			} else {
+				data[i] = float32(rng.NormFloat64()) * scale
+			}
+		}
+		g.tensor(name, data, dims...)
+	}
+	hd := uint64(tinyDim / tinyHeads)
+	add("token_embd.weight", 0.5, tinyDim, tinyVocab)
+	add("output_norm.weight", 0, tinyDim)
+	add("output.weight", 0.2, tinyDim, tinyVocab)
+	for l := 0; l < tinyLayers; l++ {
+		p := fmt.Sprintf("blk.%d.", l)
+		add(p+"attn_norm.weight", 0, tinyDim)
+		add(p+"ffn_norm.weight", 0, tinyDim)
+		add(p+"attn_q.weight", 0.1, tinyDim, tinyDim)
+		add(p+"attn_k.weight", 0.1, tinyDim, tinyKVHeads*hd)
+		add(p+"attn_v.weight", 0.1, tinyDim, tinyKVHeads*hd)
+		add(p+"attn_output.weight", 0.1, tinyDim, tinyDim)
+		add(p+"ffn_gate.weight", 0.1, tinyDim, tinyFF)
+		add(p+"ffn_up.weight", 0.1, tinyDim, tinyFF)
+		add(p+"ffn_down.weight", 0.1, tinyFF, tinyDim)
+	}
+	return g
+}
+
+// tinyTokens is the tiny vocab: one byte-level BPE character per token,
+// enough to encode the training template and lowercase prompts
+func tinyTokens() []string {
+	tokens := []string{"Ġ", "Ċ"}
+	for _, c := range "#:.,?!QABCDEFGHIJKLMNOPRST0123456789abcdefghijklmnopqrstuvwxyz" {
+		tokens = append(tokens, string(c))
+	}
+	return tokens
+}
+
+// newTinyYent loads writeTinyModel's GGUF as a full instance with room for
+// the training template. HOME moves to a temp dir, so voice state, shard
+// marks and crash reports stay out of the real ~/.yent. Memory goes to the
+// LIMPHA socket given (newFakeLimpha), or nowhere: python3 is kept off
+// PATH so no real daemon starts.
+func newTinyYent(t *testing.T, limphaSocket string) *yent.Yent {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	y, err := yent.NewWithOptions(writeTinyModel(t), yent.LoadOptions{SeqLen: 1024, LimphaSocket: limphaSocket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(y.Close)
+	return y
+}
+
+// loadTinyModel loads writeTinyModel's GGUF with opts
+func loadTinyModel(t *testing.T, path string, opts yent.LoadOptions) *yent.LlamaModel {
+	t.Helper()
+	gguf, err := yent.LoadGGUF(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := yent.LoadLlamaModelWithOptions(gguf, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// hostBackend is a GPUBackend in host memory: F16 storage, float32 math
+type hostBackend struct {
+	failMatMul bool
+	live       *int // matrices not yet freed
+}
+
+type hostMatrix struct {
+	b          *hostBackend
+	f16        []uint16
+	rows, cols int
+}
+
+func (b *hostBackend) Name() string { return "host" }
+
+func (b *hostBackend) Alloc(rows, cols int) (yent.GPUMatrix, error) {
+	*b.live++
+	return &hostMatrix{b: b, f16: make([]uint16, rows*cols), rows: rows, cols: cols}, nil
+}
+
+func (b *hostBackend) Close() error { return nil }
+
+func (m *hostMatrix) SetRows(row int, f16 []uint16) error {
+	copy(m.f16[row*m.cols:], f16)
+	return nil
+}
+
+func (m *hostMatrix) MatMul(out, x []float32) error {
+	if m.b.failMatMul {
+		return errors.New("device lost")
+	}
+	for r := 0; r < m.rows; r++ {
+		var sum float32
+		for c, h := range m.f16[r*m.cols : (r+1)*m.cols] {
+			sum += halfToFloat(h) * x[c]
+		}
+		out[r] = sum
+	}
+	return nil
+}
+
+func (m *hostMatrix) Free() { *m.b.live-- }
+
+// halfToFloat decodes an IEEE 754 half
+func halfToFloat(h uint16) float32 {
+	sign := float32(1)
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp, mant := int(h>>10&0x1F), float64(h&0x3FF)
+	switch exp {
+	case 0:
+		return sign * float32(math.Ldexp(mant, -24))
+	case 31:
+		return sign * float32(math.Inf(1))
+	}
+	return sign * float32(math.Ldexp(1024+mant, exp-25))
+}
+
+// TestGPUOffload runs the tiny model with its FFN and LM head on a
+// registered backend and checks the logits against the CPU kernels
+func TestGPUOffload(t *testing.T) {
+	path := writeTinyModel(t)
+	live := 0
+	backend := &hostBackend{live: &live}
+	yent.RegisterGPUBackend("test-host", func() (yent.GPUBackend, error) { return backend, nil })
+
+	cpu := loadTinyModel(t, path, yent.LoadOptions{})
+	gpu := loadTinyModel(t, path, yent.LoadOptions{GPU: "test-host"})
+	if cpu.GPU() != "" || gpu.GPU() != "host" {
+		t.Fatalf("GPU(): cpu %q, offloaded %q", cpu.GPU(), gpu.GPU())
+	}
+	if want := 1 + 3*tinyLayers; live != want {
+		t.Errorf("%d matrices offloaded, want %d", live, want)
+	}
+
+	for pos, tok := range []int{3, 17, 42, 5} {
+		cpu.Forward(tok, pos)
+		gpu.Forward(tok, pos)
+		for i, want := range cpu.State.Logits {
+			if got := gpu.State.Logits[i]; math.Abs(float64(got-want)) > 1e-2 {
+				t.Fatalf("pos %d logit %d: offloaded %f, cpu %f", pos, i, got, want)
+			}
+		}
+	}
+
+	// A failing device sends the model back to the CPU kernels for good
+	backend.failMatMul = true
+	gpu.Forward(9, 4)
+	cpu.Forward(9, 4)
+	if gpu.GPU() != "" || live != 0 {
+		t.Errorf("after failure: GPU() %q, %d matrices live", gpu.GPU(), live)
+	}
+	for i, want := range cpu.State.Logits {
+		if got := gpu.State.Logits[i]; math.Abs(float64(got-want)) > 1e-2 {
+			t.Fatalf("fallback logit %d: %f, cpu %f", i, got, want)
+		}
+	}
+}
+
+// TestGPUBackendFallback checks that an unavailable backend loads on the CPU
+func TestGPUBackendFallback(t *testing.T) {
+	if _, err := yent.OpenGPUBackend("no-such-backend"); err == nil {
+		t.Error("unknown backend opened")
+	}
+	yent.RegisterGPUBackend("test-broken", func() (yent.GPUBackend, error) {
+		return nil, errors.New("no device")
+	})
+	m := loadTinyModel(t, writeTinyModel(t), yent.LoadOptions{GPU: "test-broken"})
+	if m.GPU() != "" {
+		t.Errorf("GPU() = %q after a failed backend", m.GPU())
+	}
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestParseGrammar verifies GBNF literals, classes, groups and repetition
+func TestParseGrammar(t *testing.T) {
+	g, err := yent.ParseGrammar(`
+# a mood report
+root ::= "mood: " mood (", " mood)* "!"?
+mood ::= "calm" | "storm" | [0-9]{2,3}
+`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, s := range []string{"mood: calm", "mood: storm, 42!", "mood: 123, calm"} {
+		if !g.Match(s) {
+			t.Errorf("%q: expected match", s)
+		}
+	}
+	for _, s := range []string{"mood: ", "mood: rain", "mood: 1", "mood: 1234", "mood: calm!!"} {
+		if g.Match(s) {
+			t.Errorf("%q: expected no match", s)
+		}
+	}
+
+	// Multi-byte runes and negated classes
+	g, err = yent.ParseGrammar(`root ::= "«" [^»]+ "»"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !g.Match("«резонанс»") || g.Match("«»") {
+		t.Error("unicode class mismatch")
+	}
+
+	for _, bad := range []string{`root ::= "open`, `root ::= missing`, `other ::= "x"`, `root ::= ("x"`} {
+		if _, err := yent.ParseGrammar(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+// TestJSONSchemaGrammar verifies schema-driven JSON matches valid documents only
+func TestJSONSchemaGrammar(t *testing.T) {
+	g, err := yent.JSONSchemaGrammar([]byte(`{
+		"type": "object",
+		"properties": {
+			"name":  {"type": "string", "maxLength": 8},
+			"mood":  {"enum": ["calm", "storm"]},
+			"score": {"type": "number"},
+			"tags":  {"type": "array", "items": {"$ref": "#/$defs/tag"}}
+		},
+		"required": ["name", "mood"],
+		"$defs": {"tag": {"type": "string"}}
+	}`))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	valid := []string{
+		`{"name": "yent", "mood": "calm"}`,
+		`{"name":"yent","mood":"storm","score":-0.5}`,
+		`{"name": "yent", "mood": "calm", "tags": ["a", "b\n"]}`,
+		`{"name": "yent", "mood": "calm", "score": 1e3, "tags": []}`,
+	}
+	for _, s := range valid {
+		if !json.Valid([]byte(s)) {
+			t.Fatalf("bad fixture %s", s)
+		}
+		if !g.Match(s) {
+			t.Errorf("%s: expected match", s)
+		}
+	}
+	invalid := []string{
+		`{"mood": "calm", "name": "yent"}`,         // required order
+		`{"name": "yent"}`,                         // missing required
+		`{"name": "yent", "mood": "rain"}`,         // not in enum
+		`{"name": "far too long", "mood": "calm"}`, // maxLength
+		`{"name": "yent", "mood": "calm", "x": 1}`, // extra property
+		`{"name": "yent", "mood": "calm", "score": 01}`,
+	}
+	for _, s := range invalid {
+		if g.Match(s) {
+			t.Errorf("%s: expected no match", s)
+		}
+	}
+
+	// No required properties: any subset, in order
+	g, err = yent.JSONSchemaGrammar([]byte(`{"properties": {"a": {"type": "integer"}, "b": {"type": "boolean"}}}`))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	for _, s := range []string{`{}`, `{"a": 1}`, `{"b": true}`, `{"a": 1, "b": false}`} {
+		if !g.Match(s) {
+			t.Errorf("%s: expected match", s)
+		}
+	}
+	if g.Match(`{, "b": true}`) || g.Match(`{"b": true, "a": 1}`) {
+		t.Error("optional properties: accepted malformed object")
+	}
+
+	if !yent.JSONGrammar().Match(`{"nested": {"list": [1, "two", null, true]}}`) {
+		t.Error("generic JSON grammar rejected a valid object")
+	}
+}
@@ -0,0 +1,47 @@
+//go:build int8
+
+package tests
+
+import (
+	"math"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestInt8Perplexity compares the perplexity of the int8 activation path
+// with the float path on text the float model itself finds likely
+func TestInt8Perplexity(t *testing.T) {
+	path := writeTinyModel(t)
+	float := loadTinyModel(t, path, yent.LoadOptions{})
+	quant := loadTinyModel(t, path, yent.LoadOptions{Int8: true})
+	if float.Int8() || !quant.Int8() {
+		t.Fatalf("Int8: %v float, %v int8", float.Int8(), quant.Int8())
+	}
+
+	// Greedy continuation of a short prompt, with a few sampled-looking
+	// tokens mixed in so not every step is the argmax
+	tokens := []int{5, 17, 3}
+	for pos := 0; len(tokens) < 32; pos++ {
+		float.Forward(tokens[pos], pos)
+		if pos+1 < len(tokens) {
+			continue
+		}
+		next := 0
+		for i, v := range float.State.Logits {
+			if v > float.State.Logits[next] {
+				next = i
+			}
+		}
+		if pos%5 == 4 {
+			next = (next + 11) % tinyVocab
+		}
+		tokens = append(tokens, next)
+	}
+
+	want, got := float.Perplexity(tokens), quant.Perplexity(tokens)
+	if math.IsNaN(got) || math.Abs(got-want)/want > 0.05 {
+		t.Errorf("perplexity %.3f int8, %.3f float", got, want)
+	}
+	t.Logf("perplexity %.3f int8, %.3f float", got, want)
+}
@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// fakeLimpha answers the LIMPHA socket protocol from memory: export serves
+// rows, store records what it was sent and recent reads it back
+type fakeLimpha struct {
+	Socket string
+
+	mu        sync.Mutex
+	rows      []yent.LimphaConversation
+	stored    []map[string]interface{}
+	shutdowns int
+	onExport  func() // runs before an export is answered
+}
+
+// newFakeLimpha listens on a socket in a temp dir until the test ends
+func newFakeLimpha(t *testing.T, rows []yent.LimphaConversation) *fakeLimpha {
+	t.Helper()
+	f := &fakeLimpha{Socket: filepath.Join(t.TempDir(), "limpha.sock"), rows: rows}
+	ln, err := net.Listen("unix", f.Socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeLimpha) serve(conn net.Conn) {
+	defer conn.Close()
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var msg map[string]interface{}
+		if json.Unmarshal(sc.Bytes(), &msg) != nil {
+			return
+		}
+		resp := map[string]interface{}{"ok": true}
+		f.mu.Lock()
+		switch msg["cmd"] {
+		case "ping":
+		case "store":
+			f.stored = append(f.stored, msg)
+		case "export":
+			if hook := f.onExport; hook != nil {
+				f.mu.Unlock()
+				hook()
+				f.mu.Lock()
+			}
+			since, _ := msg["since_id"].(float64)
+			floor, _ := msg["min_quality"].(float64)
+			limit, _ := msg["limit"].(float64)
+			out := []yent.LimphaConversation{}
+			for _, r := range f.rows {
+				if r.ID > int64(since) && float64(r.Quality) >= floor && len(out) < int(limit) {
+					out = append(out, r)
+				}
+			}
+			resp["conversations"] = out
+		case "recent":
+			ns, _ := msg["session_id"].(string)
+			limit, _ := msg["limit"].(float64)
+			out := []yent.LimphaConversation{}
+			for i := len(f.stored) - 1; i >= 0 && len(out) < int(limit); i-- {
+				if id, _ := f.stored[i]["session_id"].(string); id == ns {
+					out = append(out, storedRow(f.stored[i]))
+				}
+			}
+			resp["conversations"] = out
+		case "shutdown":
+			f.shutdowns++
+			f.mu.Unlock()
+			return
+		default:
+			resp = map[string]interface{}{"ok": false, "error": "unknown command"}
+		}
+		f.mu.Unlock()
+		data, _ := json.Marshal(resp)
+		conn.Write(append(data, '\n'))
+	}
+}
+
+// storedRow is the row the daemon keeps for a store message: the state's
+// columns as sent, quality left at the schema default
+func storedRow(msg map[string]interface{}) yent.LimphaConversation {
+	var st yent.LimphaState
+	data, _ := json.Marshal(msg["state"])
+	json.Unmarshal(data, &st)
+	prompt, _ := msg["prompt"].(string)
+	response, _ := msg["response"].(string)
+	return yent.LimphaConversation{
+		Prompt: prompt, Response: response, Temperature: st.Temperature, Destiny: st.Destiny,
+		Pain: st.Pain, Tension: st.Tension, Debt: st.Debt, Velocity: st.Velocity, Alpha: st.Alpha,
+		Entropy: st.Entropy, Language: st.Language, Quality: 0.5, AMK: st.AMK,
+	}
+}
+
+// add appends rows the next export can see
+func (f *fakeLimpha) add(rows ...yent.LimphaConversation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rows = append(f.rows, rows...)
+}
+
+// stores returns how many turns were stored
+func (f *fakeLimpha) stores() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.stored)
+}
+
+// TestDialLimpha connects to a running daemon and leaves it running on Close
+func TestDialLimpha(t *testing.T) {
+	f := newFakeLimpha(t, nil)
+	c, err := yent.DialLimpha(f.Socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StoreIn("session:a", "who are you?", "yent.", yent.LimphaState{Pain: 0.2}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.stored) != 1 || f.stored[0]["session_id"] != "session:a" {
+		t.Errorf("stored %v", f.stored)
+	}
+	if f.shutdowns != 0 {
+		t.Error("Close shut down a daemon it did not start")
+	}
+	if _, err := yent.DialLimpha(filepath.Join(t.TempDir(), "none.sock")); err == nil {
+		t.Error("dialed a missing socket")
+	}
+}
+
+// TestLimphaFieldRoundTrip checks that a stored turn carries the kernel's
+// pain, tension and debt as the answer left them, and that the feedback
+// reads those back into the next answer's field
+func TestLimphaFieldRoundTrip(t *testing.T) {
+	lim := newFakeLimpha(t, nil)
+	y := newTinyYent(t, lim.Socket)
+	stored := y.Events().Subscribe(4, yent.EventMemoryStored)
+	defer stored.Close()
+	if err := y.AMK().Exec("PAIN 0.3\nTENSION 0.6\nPROPHECY_DEBT 5"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := y.GenerateWithOptions("hello there", yent.GenerateOptions{MaxTokens: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stored.C:
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn never stored")
+	}
+
+	after := res.AMKAfter
+	near := func(a, b float32) bool { return math.Abs(float64(a-b)) < 1e-4 }
+	lim.mu.Lock()
+	row := storedRow(lim.stored[0])
+	lim.mu.Unlock()
+	if !near(row.Pain, after.Pain) || !near(row.Tension, after.Tension) || !near(row.Debt, after.Debt) || after.Debt == 0 {
+		t.Errorf("stored pain/tension/debt %.3f/%.3f/%.3f, kernel after the answer %.3f/%.3f/%.3f",
+			row.Pain, row.Tension, row.Debt, after.Pain, after.Tension, after.Debt)
+	}
+
+	f, err := y.LimphaField("", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Turns != 1 || !near(f.Tension, max(after.Pain, after.Tension)) || !near(f.Debt, after.Debt) {
+		t.Fatalf("field %+v from %+v", f, after)
+	}
+	y.LimphaFeedback = 8
+	res, err = y.GenerateWithOptions("hello again", yent.GenerateOptions{MaxTokens: 2, NoStore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the DSL carries three decimals
+	if before := res.AMKBefore; math.Abs(float64(before.Tension-0.8*f.Tension)) > 1e-3 || math.Abs(float64(before.Debt-0.8*f.Debt)) > 1e-3 {
+		t.Errorf("next answer starts at tension %.3f, debt %.3f; want %.3f, %.3f",
+			before.Tension, before.Debt, 0.8*f.Tension, 0.8*f.Debt)
+	}
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"math"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestLogprobs checks per-token logprobs on the tiny model: the top
+// alternatives are the whole vocab when more are asked for, they are sorted
+// and normalised, and the chosen token's logprob is its entry among them
+func TestLogprobs(t *testing.T) {
+	y := newTinyYent(t, "")
+	gen := func(topN int, bias map[int]float32) *yent.GenerateResult {
+		t.Helper()
+		res, err := y.GenerateWithOptions("hello there", yent.GenerateOptions{
+			MaxTokens: 4, Seed: 3, Deterministic: true, NoStore: true,
+			Logprobs: true, TopLogprobs: topN, LogitBias: bias,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Tokens) == 0 {
+			t.Fatal("no tokens")
+		}
+		return res
+	}
+
+	full := gen(tinyVocab+10, nil)
+	for i, tl := range full.Tokens {
+		if len(tl.Top) != tinyVocab {
+			t.Fatalf("token %d: %d alternatives, want the whole vocab (%d)", i, len(tl.Top), tinyVocab)
+		}
+		var sum float64
+		found := false
+		for j, alt := range tl.Top {
+			sum += math.Exp(float64(alt.Logprob))
+			if j > 0 && alt.Logprob > tl.Top[j-1].Logprob {
+				t.Errorf("token %d: alternatives out of order at %d", i, j)
+			}
+			if alt.Token == tl.Token {
+				found = true
+				if alt.Logprob != tl.Logprob {
+					t.Errorf("token %d: logprob %f, its alternative says %f", i, tl.Logprob, alt.Logprob)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("token %d: chosen %d not among the alternatives", i, tl.Token)
+		}
+		if math.Abs(sum-1) > 1e-4 {
+			t.Errorf("token %d: probabilities sum to %f", i, sum)
+		}
+	}
+
+	// A smaller N is the head of the same list
+	top3 := gen(3, nil)
+	for i, tl := range top3.Tokens {
+		if len(tl.Top) != 3 {
+			t.Fatalf("token %d: %d alternatives, want 3", i, len(tl.Top))
+		}
+		for j, alt := range tl.Top {
+			if want := full.Tokens[i].Top[j]; alt.Token != want.Token || alt.Logprob != want.Logprob {
+				t.Errorf("token %d top %d: %+v, want %+v", i, j, alt, want)
+			}
+		}
+	}
+	if none := gen(0, nil); none.Tokens[0].Top != nil {
+		t.Errorf("TopLogprobs 0 returned %d alternatives", len(none.Tokens[0].Top))
+	}
+
+	// Huge logits stay finite: the log-sum-exp is taken from the max
+	big := gen(2, map[int]float32{4: 1e4})
+	tl := big.Tokens[0]
+	if tl.Token != 4 || math.Abs(float64(tl.Logprob)) > 1e-3 {
+		t.Errorf("biased token: %d logprob %f, want 4 at ~0", tl.Token, tl.Logprob)
+	}
+	if lp := tl.Top[1].Logprob; math.IsInf(float64(lp), 0) || math.IsNaN(float64(lp)) || lp > -9000 {
+		t.Errorf("runner-up logprob %f, want finite and near -1e4", lp)
+	}
+}
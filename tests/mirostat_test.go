@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestMirostatOptions checks that Mirostat refuses a sampler chain, and
+// that under a grammar its redraws still keep to the grammar
+func TestMirostatOptions(t *testing.T) {
+	y := newTinyYent(t, "")
+	chain, err := yent.ParseSamplerChain("top-k=40,temp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := y.GenerateWithOptions("hello", yent.GenerateOptions{MaxTokens: 4, NoStore: true, MirostatTau: 3, Samplers: chain}); err == nil ||
+		!strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("mirostat with a sampler chain: %v", err)
+	}
+
+	g, err := yent.ParseGrammar(`root ::= [abc]+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := y.GenerateWithOptions("hello", yent.GenerateOptions{
+		MaxTokens: 12, Seed: 9, Deterministic: true, NoStore: true, MirostatTau: 3, Grammar: g,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text == "" || strings.Trim(res.Text, "abc") != "" {
+		t.Errorf("mirostat under [abc]+: %q", res.Text)
+	}
+}
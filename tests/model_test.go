@@ -0,0 +1,269 @@
+package tests
+
+import (
+	"math"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// rotate applies RoPE (half-split layout) for position pos
+func rotate(vec []float32, freqs []float64, pos int) []float32 {
+	half := len(vec) / 2
+	out := make([]float32, len(vec))
+	for i := 0; i < half; i++ {
+		a := float64(pos) * freqs[i]
+		c, s := float32(math.Cos(a)), float32(math.Sin(a))
+		out[i] = vec[i]*c - vec[i+half]*s
+		out[i+half] = vec[i]*s + vec[i+half]*c
+	}
+	return out
+}
+
+// TestShiftContext verifies KV entries slide down and keys are re-rotated to their new position
+func TestShiftContext(t *testing.T) {
+	const hd, seqLen = 4, 8
+	freqs := []float64{1.0, 0.1}
+	m := &yent.LlamaModel{
+		Config: yent.LlamaConfig{NumLayers: 1, NumKVHeads: 1, HeadDim: hd, SeqLen: seqLen},
+		State: yent.LlamaState{
+			KeyCache:   make([]float32, seqLen*hd),
+			ValueCache: make([]float32, seqLen*hd),
+			RopeFreqs:  freqs,
+		},
+	}
+
+	// Key at position p = base rotated to p; value = p (to track movement)
+	base := []float32{1, 0.5, -0.25, 2}
+	for p := 0; p < seqLen; p++ {
+		copy(m.State.KeyCache[p*hd:], rotate(base, freqs, p))
+		for d := 0; d < hd; d++ {
+			m.State.ValueCache[p*hd+d] = float32(p)
+		}
+	}
+
+	// Keep 1 sink, discard 3 → positions 4..7 move to 1..4
+	pos := m.ShiftContext(seqLen, 1, 3)
+	if pos != 5 {
+		t.Fatalf("new pos: got %d, expected 5", pos)
+	}
+
+	for p := 0; p < pos; p++ {
+		wantVal := float32(p)
+		if p >= 1 {
+			wantVal = float32(p + 3)
+		}
+		if m.State.ValueCache[p*hd] != wantVal {
+			t.Errorf("value[%d]: got %.0f, expected %.0f", p, m.State.ValueCache[p*hd], wantVal)
+		}
+		want := rotate(base, freqs, p)
+		for d := 0; d < hd; d++ {
+			if math.Abs(float64(m.State.KeyCache[p*hd+d]-want[d])) > 1e-4 {
+				t.Errorf("key[%d][%d]: got %f, expected %f", p, d, m.State.KeyCache[p*hd+d], want[d])
+			}
+		}
+	}
+}
+
+// TestShiftContextNoop verifies out-of-range shifts leave the cache alone
+func TestShiftContextNoop(t *testing.T) {
+	m := &yent.LlamaModel{
+		Config: yent.LlamaConfig{NumLayers: 1, NumKVHeads: 1, HeadDim: 2, SeqLen: 4},
+		State: yent.LlamaState{
+			KeyCache:   make([]float32, 8),
+			ValueCache: make([]float32, 8),
+			RopeFreqs:  []float64{1},
+		},
+	}
+	if pos := m.ShiftContext(3, 2, 2); pos != 3 {
+		t.Errorf("shift beyond pos: got %d, expected 3", pos)
+	}
+	if pos := m.ShiftContext(3, 0, 0); pos != 3 {
+		t.Errorf("zero discard: got %d, expected 3", pos)
+	}
+}
+
+// TestSaveRestoreKV verifies cache rows round-trip per layer (beam search swaps them)
+func TestSaveRestoreKV(t *testing.T) {
+	const hd, seqLen, layers = 2, 6, 2
+	m := &yent.LlamaModel{
+		Config: yent.LlamaConfig{NumLayers: layers, NumKVHeads: 1, HeadDim: hd, SeqLen: seqLen},
+		State: yent.LlamaState{
+			KeyCache:   make([]float32, layers*seqLen*hd),
+			ValueCache: make([]float32, layers*seqLen*hd),
+		},
+	}
+	for i := range m.State.KeyCache {
+		m.State.KeyCache[i] = float32(i)
+		m.State.ValueCache[i] = float32(-i)
+	}
+	rows := m.SaveKV(2, 5)
+	for i := range m.State.KeyCache {
+		m.State.KeyCache[i], m.State.ValueCache[i] = 0, 0
+	}
+	m.RestoreKV(rows)
+
+	for layer := 0; layer < layers; layer++ {
+		for p := 0; p < seqLen; p++ {
+			i := layer*seqLen*hd + p*hd
+			want := float32(0)
+			if p >= 2 && p < 5 {
+				want = float32(i)
+			}
+			if m.State.KeyCache[i] != want || m.State.ValueCache[i] != -want {
+				t.Errorf("layer %d pos %d: got k=%.0f v=%.0f, expected %.0f", layer, p,
+					m.State.KeyCache[i], m.State.ValueCache[i], want)
+			}
+		}
+	}
+}
+
+// TestF32Embeddings checks that a pre-dequantized embedding table gives the
+// same forward pass as the per-token lookup
+func TestF32Embeddings(t *testing.T) {
+	path := writeTinyModel(t)
+	lookup := loadTinyModel(t, path, yent.LoadOptions{})
+	table := loadTinyModel(t, path, yent.LoadOptions{F32Embeddings: true})
+	if lookup.Weights.TokenEmbedF32 != nil || len(table.Weights.TokenEmbedF32) != tinyVocab*tinyDim {
+		t.Fatalf("TokenEmbedF32: %d without the option, %d with",
+			len(lookup.Weights.TokenEmbedF32), len(table.Weights.TokenEmbedF32))
+	}
+	for pos, tok := range []int{1, 63, 20} {
+		lookup.Forward(tok, pos)
+		table.Forward(tok, pos)
+		for i, want := range lookup.State.Logits {
+			if got := table.State.Logits[i]; got != want {
+				t.Fatalf("pos %d logit %d: %f with the F32 table, %f without", pos, i, got, want)
+			}
+		}
+	}
+}
+
+// TestMemoryStats checks the byte counts against the tiny model's shapes
+func TestMemoryStats(t *testing.T) {
+	path := writeTinyModel(t)
+	m := loadTinyModel(t, path, yent.LoadOptions{})
+	kvDim := tinyKVHeads * tinyDim / tinyHeads
+	perLayer := 2*tinyDim + 2*tinyDim*tinyDim + 2*tinyDim*kvDim + 3*tinyDim*tinyFF
+	weights := int64(2*tinyVocab*tinyDim+tinyDim+tinyLayers*perLayer) * 4
+	perPos := int64(tinyLayers * kvDim * 2 * 4)
+
+	s := m.MemoryStats()
+	if s.Weights != weights {
+		t.Errorf("weights %d, want %d", s.Weights, weights)
+	}
+	if s.KVPerPosition != perPos || s.KVCache != perPos*int64(m.Config.SeqLen) {
+		t.Errorf("kv cache %d (%d per position), want %d per position", s.KVCache, s.KVPerPosition, perPos)
+	}
+	if s.Embeddings != 0 || s.Scratch <= 0 || s.Total != s.Weights+s.KVCache+s.Scratch {
+		t.Errorf("embeddings %d, scratch %d, total %d", s.Embeddings, s.Scratch, s.Total)
+	}
+
+	f32 := loadTinyModel(t, path, yent.LoadOptions{F32Embeddings: true}).MemoryStats()
+	if f32.Embeddings != tinyVocab*tinyDim*4 || f32.Total != s.Total+f32.Embeddings {
+		t.Errorf("f32 embeddings %d, total %d", f32.Embeddings, f32.Total)
+	}
+}
+
+// TestStreamLayers runs the tiny model from a mapped file, releasing each
+// layer after use, and checks the logits against the model read into memory
+func TestStreamLayers(t *testing.T) {
+	path := writeTinyModel(t)
+	mem := loadTinyModel(t, path, yent.LoadOptions{})
+	gguf, err := yent.LoadGGUFMapped(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gguf.Close()
+	streamed, err := yent.LoadLlamaModelWithOptions(gguf, yent.LoadOptions{StreamLayers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pos, tok := range []int{2, 40, 11, 63} {
+		mem.Forward(tok, pos)
+		streamed.Forward(tok, pos)
+		for i, want := range mem.State.Logits {
+			if got := streamed.State.Logits[i]; got != want {
+				t.Fatalf("pos %d logit %d: streamed %f, in memory %f", pos, i, got, want)
+			}
+		}
+	}
+}
+
+// TestPrefetch checks the prefetch walker leaves the logits untouched
+func TestPrefetch(t *testing.T) {
+	path := writeTinyModel(t)
+	plain := loadTinyModel(t, path, yent.LoadOptions{})
+	ahead := loadTinyModel(t, path, yent.LoadOptions{Prefetch: true})
+	defer ahead.Close()
+	for pos, tok := range []int{7, 31, 0, 50, 9} {
+		plain.Forward(tok, pos)
+		ahead.Forward(tok, pos)
+		for i, want := range plain.State.Logits {
+			if got := ahead.State.Logits[i]; got != want {
+				t.Fatalf("pos %d logit %d: %f with prefetch, %f without", pos, i, got, want)
+			}
+		}
+	}
+}
+
+// TestPruneHead checks that the pruned LM head computes its top logit
+// exactly and audits against the full head every 32 tokens
+func TestPruneHead(t *testing.T) {
+	path := writeTinyModel(t)
+	plain := loadTinyModel(t, path, yent.LoadOptions{})
+	pruned := loadTinyModel(t, path, yent.LoadOptions{PruneHead: true})
+	if _, ok := plain.HeadPruneStats(); ok {
+		t.Fatal("head pruning reported without PruneHead")
+	}
+
+	const tokens = 40
+	for n := 0; n < tokens; n++ {
+		tok, pos := (n*37+5)%tinyVocab, n%32
+		plain.Forward(tok, pos)
+		pruned.Forward(tok, pos)
+		best := 0
+		for i, v := range pruned.State.Logits {
+			if v > pruned.State.Logits[best] {
+				best = i
+			}
+		}
+		got, want := pruned.State.Logits[best], plain.State.Logits[best]
+		if math.Abs(float64(got-want)) > 1e-5 {
+			t.Fatalf("token %d: top logit %d is %f pruned, %f exact", n, best, got, want)
+		}
+	}
+
+	s, ok := pruned.HeadPruneStats()
+	if !ok {
+		t.Fatal("no head pruning stats")
+	}
+	if s.Tokens != tokens || s.Clusters < 4 || s.Budget < tinyVocab/8 {
+		t.Errorf("stats %+v", s)
+	}
+	if s.Audits+s.Fallbacks == 0 {
+		t.Errorf("no audit or fallback in %d tokens: %+v", tokens, s)
+	}
+}
+
+// TestPerplexity checks Perplexity against the log-softmax of each step
+func TestPerplexity(t *testing.T) {
+	m := loadTinyModel(t, writeTinyModel(t), yent.LoadOptions{})
+	tokens := []int{3, 41, 8, 60, 12, 12, 7}
+	var nll float64
+	for pos := 0; pos < len(tokens)-1; pos++ {
+		m.Forward(tokens[pos], pos)
+		var sum float64
+		for _, v := range m.State.Logits {
+			sum += math.Exp(float64(v))
+		}
+		nll -= float64(m.State.Logits[tokens[pos+1]]) - math.Log(sum)
+	}
+	want := math.Exp(nll / float64(len(tokens)-1))
+	if got := m.Perplexity(tokens); math.Abs(got-want) > 1e-3*want {
+		t.Errorf("perplexity %f, want %f", got, want)
+	}
+	if got := m.Perplexity(tokens[:1]); !math.IsNaN(got) {
+		t.Errorf("one token: %f, want NaN", got)
+	}
+}
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestModelPool checks naming, defaults and routing by model name
+func TestModelPool(t *testing.T) {
+	pool := yent.NewModelPool()
+	en, base := &yent.Yent{}, &yent.Yent{}
+	if err := pool.Add("yent", en); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Add("qwen", base); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Add("qwen", base); err == nil {
+		t.Error("expected duplicate name error")
+	}
+	if _, err := pool.Load("broken", filepath.Join(t.TempDir(), "missing.gguf"), yent.LoadOptions{}); err == nil {
+		t.Error("expected load error for missing weights")
+	}
+	if got, _ := pool.Get(""); got != en || pool.Default() != "yent" {
+		t.Error("first model should be the default")
+	}
+	if got, _ := pool.Get("qwen"); got != base {
+		t.Error("Get(qwen) returned the wrong instance")
+	}
+	if names := pool.Names(); strings.Join(names, ",") != "qwen,yent" {
+		t.Errorf("names %v", names)
+	}
+
+	srv, err := yent.NewServer(en, yent.ServerOptions{Pool: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/v1/generate", "application/json", strings.NewReader(`{"prompt": "hi", "model": "llama"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown model: status %d, expected 404", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/models")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Models  []string `json:"models"`
+		Default string   `json:"default"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	if len(out.Models) != 2 || out.Default != "yent" {
+		t.Errorf("unexpected /v1/models %+v", out)
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestPhaseTimings runs the tiny model with profiling on and checks that
+// each forward phase is charged once per token (per layer for the blocks)
+func TestPhaseTimings(t *testing.T) {
+	m := loadTinyModel(t, writeTinyModel(t), yent.LoadOptions{})
+	yent.SetProfiling(true)
+	defer yent.SetProfiling(false)
+	yent.ResetPhaseTimings()
+
+	const tokens = 5
+	for pos := 0; pos < tokens; pos++ {
+		m.Forward(pos+1, pos)
+	}
+	calls := map[string]int64{}
+	var share float64
+	for _, p := range yent.PhaseTimings() {
+		calls[p.Phase] = p.Calls
+		share += p.Share
+	}
+	want := map[string]int64{
+		"embed": tokens, "attention": tokens * tinyLayers, "ffn": tokens * tinyLayers,
+		"lm_head": tokens, "delta": 0, "sampling": 0,
+	}
+	for phase, n := range want {
+		if calls[phase] != n {
+			t.Errorf("%s: %d calls, want %d", phase, calls[phase], n)
+		}
+	}
+	if share < 0.99 || share > 1.01 {
+		t.Errorf("shares sum to %f", share)
+	}
+
+	// Off: nothing more is counted
+	yent.SetProfiling(false)
+	m.Forward(1, tokens)
+	for _, p := range yent.PhaseTimings() {
+		if p.Calls != want[p.Phase] {
+			t.Errorf("%s counted with profiling off", p.Phase)
+		}
+	}
+}
+
+// TestServerProfile checks the admin profile endpoint and the pprof mount
+func TestServerProfile(t *testing.T) {
+	srv, err := yent.NewServer(&yent.Yent{}, yent.ServerOptions{Pprof: true})
+	if err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	defer srv.Close()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/admin/profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Phases []yent.PhaseTiming `json:"phases"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(body.Phases) != 6 {
+		t.Errorf("GET profile: %d, %d phases", resp.StatusCode, len(body.Phases))
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/admin/profile", nil)
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE profile: %d", resp.StatusCode)
+	}
+
+	if resp, err = http.Get(ts.URL + "/debug/pprof/"); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("pprof index: %d", resp.StatusCode)
+	}
+}
@@ -147,3 +147,91 @@ func TestMatMulF32(t *testing.T) {
 		}
 	}
 }
+
+// TestMatMulWorkers checks parallel matmuls against one thread, with
+// several callers sharing the worker pool at once
+func TestMatMulWorkers(t *testing.T) {
+	const rows, cols = 103, 64
+	w := make([]float32, rows*cols)
+	x := make([]float32, cols)
+	for i := range w {
+		w[i] = float32(i%17) - 8
+	}
+	for i := range x {
+		x[i] = float32(i%5) * 0.25
+	}
+	yent.SetThreads(1)
+	want := make([]float32, rows)
+	yent.MatMulF32(want, w, x, rows, cols)
+
+	yent.SetThreads(7)
+	defer yent.SetThreads(0)
+	errs := make(chan string, 4)
+	for c := 0; c < 4; c++ {
+		go func() {
+			got := make([]float32, rows)
+			for n := 0; n < 50; n++ {
+				yent.MatMulF32(got, w, x, rows, cols)
+				for i := range got {
+					if got[i] != want[i] {
+						errs <- "row mismatch"
+						return
+					}
+				}
+			}
+			errs <- ""
+		}()
+	}
+	for c := 0; c < 4; c++ {
+		if e := <-errs; e != "" {
+			t.Fatal(e)
+		}
+	}
+}
+
+// TestMatMulTiled covers the F32/F16 tile and row-group edges: a row count
+// that is not a multiple of four and columns spanning two tiles
+func TestMatMulTiled(t *testing.T) {
+	const rows, cols = 6, 2100
+	w := make([]float32, rows*cols)
+	w16 := make([]byte, rows*cols*2)
+	x := make([]float32, cols)
+	for i := range w {
+		v := i%7 - 3
+		w[i] = float32(v)
+		// small integers are exact in fp16: sign, exponent 15+e, mantissa
+		var h uint16
+		if v != 0 {
+			a := v
+			if a < 0 {
+				a = -a
+				h = 0x8000
+			}
+			e := 0
+			for a>>(e+1) > 0 {
+				e++
+			}
+			h |= uint16(15+e)<<10 | uint16((a<<10>>e)&0x3FF)
+		}
+		w16[2*i], w16[2*i+1] = byte(h), byte(h>>8)
+	}
+	for i := range x {
+		x[i] = float32(i%3) - 1
+	}
+	want := make([]float32, rows)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			want[r] += w[r*cols+c] * x[c]
+		}
+	}
+
+	got := make([]float32, rows)
+	yent.MatMulF32(got, w, x, rows, cols)
+	got16 := make([]float32, rows)
+	yent.MatMulF16(got16, w16, x, rows, cols)
+	for r := range want {
+		if got[r] != want[r] || got16[r] != want[r] {
+			t.Errorf("row %d: f32 %v, f16 %v, want %v", r, got[r], got16[r], want[r])
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestReloadErrors checks that a failed reload is reported and leaves the instance alone
+func TestReloadErrors(t *testing.T) {
+	y := &yent.Yent{}
+	if _, err := y.Reload("missing.gguf"); err == nil || !strings.Contains(err.Error(), "not initialized") {
+		t.Errorf("reload on an unloaded instance: %v", err)
+	}
+	if y.WeightsPath() != "" {
+		t.Errorf("weights path changed to %q", y.WeightsPath())
+	}
+
+	srv, err := yent.NewServer(y, yent.ServerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	for body, want := range map[string]int{
+		`{}`:                                  http.StatusBadRequest,
+		`{"weights": "x.gguf", "model": "q"}`: http.StatusNotFound,
+		`{"weights": "missing.gguf"}`:         http.StatusUnprocessableEntity,
+		`{"deltas": true}`:                    http.StatusOK, // none loaded, none changed
+	} {
+		resp, err := http.Post(ts.URL+"/v1/admin/reload", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: status %d, expected %d", body, resp.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestFuseRanking verifies factor normalization, weighting and explain output
+func TestFuseRanking(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	day := 86400.0
+	ts := float64(now.Unix())
+
+	cands := []yent.MemoryCandidate{
+		// Strong keyword match, old
+		{Conv: yent.LimphaConversation{ID: 1, Timestamp: ts - 60*day, Quality: 0.5}, BM25: -4},
+		// Weaker keyword match, close in meaning, fresh
+		{Conv: yent.LimphaConversation{ID: 2, Timestamp: ts, Quality: 0.5}, BM25: -2, Similarity: 0.9},
+		// Vector only, well-worn memory
+		{Conv: yent.LimphaConversation{ID: 3, Timestamp: ts - 30*day, Quality: 1, AccessCount: 10}, Similarity: 0.5},
+	}
+
+	cfg := yent.RetrievalConfig{
+		K:            3,
+		Weights:      yent.RetrievalWeights{Keyword: 1, Vector: 1, Strength: 1, Recency: 1},
+		HalfLifeDays: 30,
+		Explain:      true,
+	}
+	ranked := yent.FuseRanking(cands, cfg, now)
+	if len(ranked) != 3 {
+		t.Fatalf("got %d results, expected 3", len(ranked))
+	}
+	if ranked[0].ID != 2 {
+		t.Errorf("top result: got #%d, expected #2", ranked[0].ID)
+	}
+
+	approx := func(a, b float32) bool { return math.Abs(float64(a-b)) < 1e-4 }
+	for _, r := range ranked {
+		e := r.Explain
+		if e == nil {
+			t.Fatal("explain missing")
+		}
+		sum := e.Keyword.Contribution + e.Vector.Contribution + e.Strength.Contribution + e.Recency.Contribution
+		if !approx(sum, r.Score) {
+			t.Errorf("#%d: contributions sum %.4f != score %.4f", r.ID, sum, r.Score)
+		}
+		if !approx(e.Keyword.Weight, 0.25) {
+			t.Errorf("weights not normalized: %v", e.Keyword.Weight)
+		}
+		switch r.ID {
+		case 1:
+			if !approx(e.Keyword.Value, 1) || !approx(e.Recency.Value, 0.25) {
+				t.Errorf("#1: keyword %.3f recency %.3f, expected 1 / 0.25", e.Keyword.Value, e.Recency.Value)
+			}
+		case 3:
+			if !approx(e.Keyword.Value, 0) || !approx(e.Strength.Value, 1) || !approx(e.Recency.Value, 0.5) {
+				t.Errorf("#3: unexpected factors %s", e)
+			}
+		}
+	}
+
+	// MinScore and K trim the list
+	cfg.MinScore = 0.5
+	cfg.K = 1
+	if got := yent.FuseRanking(cands, cfg, now); len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("trimmed ranking: %+v", got)
+	}
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"math"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// loadRopeModel loads the tiny llama (head dim 16, trained context 32) with
+// optional rope metadata and overrides
+func loadRopeModel(t *testing.T, meta func(g *ggufBuilder), seqLen int, rope yent.RopeScaling) *yent.LlamaModel {
+	t.Helper()
+	g := tinyModelGGUF()
+	if meta != nil {
+		meta(g)
+	}
+	gguf, err := yent.LoadGGUF(g.write(t, "rope.gguf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := yent.LoadLlamaModelWithOptions(gguf, yent.LoadOptions{SeqLen: seqLen, Rope: rope})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// TestParseRopeScalingType checks names, aliases and rejected types
+func TestParseRopeScalingType(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", yent.RopeScalingNone, true},
+		{"none", yent.RopeScalingNone, true},
+		{" Linear ", yent.RopeScalingLinear, true},
+		{"ntk", yent.RopeScalingNTK, true},
+		{"NTK-aware", yent.RopeScalingNTK, true},
+		{"yarn", yent.RopeScalingYaRN, true},
+		{"dynamic", "", false}, // dynamic NTK is not a fixed table
+		{"longrope", "", false},
+	} {
+		got, err := yent.ParseRopeScalingType(tc.in)
+		if got != tc.want || (err == nil) != tc.ok {
+			t.Errorf("ParseRopeScalingType(%q) = %q, %v", tc.in, got, err)
+		}
+	}
+}
+
+// TestResolveRopeScaling checks that GGUF metadata fills what the options
+// leave unset, and that options win
+func TestResolveRopeScaling(t *testing.T) {
+	ggufLinear := func(g *ggufBuilder) {
+		g.str("llama.rope.scaling.type", "linear")
+		g.f32("llama.rope.scaling.factor", 2)
+		g.u32("llama.rope.scaling.original_context_length", 16)
+	}
+	for _, tc := range []struct {
+		name   string
+		meta   func(g *ggufBuilder)
+		seqLen int
+		opts   yent.RopeScaling
+		want   yent.RopeScaling
+	}{
+		{"none", nil, 32, yent.RopeScaling{},
+			yent.RopeScaling{Type: "none", Factor: 1, OrigCtx: 32, BetaFast: 32, BetaSlow: 1}},
+		{"factor from seq len", nil, 128, yent.RopeScaling{Type: "ntk"},
+			yent.RopeScaling{Type: "ntk", Factor: 4, OrigCtx: 32, BetaFast: 32, BetaSlow: 1}},
+		{"gguf metadata", ggufLinear, 32, yent.RopeScaling{},
+			yent.RopeScaling{Type: "linear", Factor: 2, OrigCtx: 16, BetaFast: 32, BetaSlow: 1}},
+		{"options win", ggufLinear, 32, yent.RopeScaling{Type: "yarn", Factor: 3, OrigCtx: 8, BetaFast: 16, BetaSlow: 2},
+			yent.RopeScaling{Type: "yarn", Factor: 3, OrigCtx: 8, BetaFast: 16, BetaSlow: 2}},
+		{"unknown gguf type", func(g *ggufBuilder) { g.str("llama.rope.scaling.type", "dynamic") }, 32, yent.RopeScaling{},
+			yent.RopeScaling{Type: "none", Factor: 1, OrigCtx: 32, BetaFast: 32, BetaSlow: 1}},
+	} {
+		m := loadRopeModel(t, tc.meta, tc.seqLen, tc.opts)
+		if m.Config.Rope != tc.want {
+			t.Errorf("%s: %+v, want %+v", tc.name, m.Config.Rope, tc.want)
+		}
+	}
+}
+
+// TestPrecomputeRoPE checks the frequency table and cos/sin caches of each
+// scaling type against the formulas
+func TestPrecomputeRoPE(t *testing.T) {
+	const half, theta = 8, 10000.0
+	base := func(i int, theta float64) float64 { return math.Pow(theta, -float64(2*i)/16) }
+	ntkTheta := theta * math.Pow(2, 16.0/14)
+
+	for _, tc := range []struct {
+		rope   yent.RopeScaling
+		freq   func(i int) float64
+		mscale float64
+	}{
+		{yent.RopeScaling{Type: "none"}, func(i int) float64 { return base(i, theta) }, 1},
+		{yent.RopeScaling{Type: "linear", Factor: 2}, func(i int) float64 { return base(i, theta) / 2 }, 1},
+		{yent.RopeScaling{Type: "ntk", Factor: 2}, func(i int) float64 { return base(i, ntkTheta) }, 1},
+		// orig ctx 32, betas 32/1: the ramp runs from dim 0 (kept) to dim 2 (interpolated)
+		{yent.RopeScaling{Type: "yarn", Factor: 4}, func(i int) float64 {
+			switch i {
+			case 0:
+				return base(0, theta)
+			case 1:
+				return base(1, theta) * (0.5/4 + 0.5)
+			}
+			return base(i, theta) / 4
+		}, 1 + 0.1*math.Log(4)},
+		{yent.RopeScaling{Type: "yarn", Factor: 1}, func(i int) float64 { return base(i, theta) }, 1},
+	} {
+		m := loadRopeModel(t, nil, 32, tc.rope)
+		s := &m.State
+		for i := 0; i < half; i++ {
+			want := tc.freq(i)
+			if math.Abs(s.RopeFreqs[i]-want) > 1e-12*math.Max(1, want) {
+				t.Errorf("%s x%.0f: freq[%d] = %g, want %g", tc.rope.Type, tc.rope.Factor, i, s.RopeFreqs[i], want)
+			}
+			for _, pos := range []int{0, 5, 31} {
+				c, sn := s.CosCache[pos*half+i], s.SinCache[pos*half+i]
+				wc, ws := math.Cos(float64(pos)*want)*tc.mscale, math.Sin(float64(pos)*want)*tc.mscale
+				if math.Abs(float64(c)-wc) > 1e-5 || math.Abs(float64(sn)-ws) > 1e-5 {
+					t.Errorf("%s x%.0f: pos %d dim %d: cos/sin %f/%f, want %f/%f",
+						tc.rope.Type, tc.rope.Factor, pos, i, c, sn, wc, ws)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"math"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// candidates builds a candidate list from probabilities
+func candidates(probs ...float64) []yent.TokenCandidate {
+	cands := make([]yent.TokenCandidate, len(probs))
+	for i, p := range probs {
+		cands[i] = yent.TokenCandidate{Token: i, Logit: float32(math.Log(p))}
+	}
+	return cands
+}
+
+// tokens returns candidate token ids in order
+func tokens(cands []yent.TokenCandidate) []int {
+	ids := make([]int, len(cands))
+	for i, c := range cands {
+		ids[i] = c.Token
+	}
+	return ids
+}
+
+// TestSamplerStages verifies top-k, typical and tail-free truncation
+func TestSamplerStages(t *testing.T) {
+	step := yent.SamplerStep{Temperature: 1, TopK: 2}
+
+	// Bare top-k takes the field's k
+	got := yent.TopKSampler{}.Apply(candidates(0.1, 0.5, 0.4), step)
+	if ids := tokens(got); len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("top-k: got %v", ids)
+	}
+
+	// Typical keeps tokens whose surprise is near the entropy: a spike over
+	// a wide flat field is the atypical one
+	flat := []float64{0.4}
+	for i := 0; i < 12; i++ {
+		flat = append(flat, 0.05)
+	}
+	got = yent.TypicalSampler{P: 0.3}.Apply(candidates(flat...), step)
+	for _, c := range got {
+		if c.Token == 0 {
+			t.Errorf("typical kept the dominant token: %v", tokens(got))
+		}
+	}
+
+	// Tail-free cuts the flat tail after the sharp drop
+	got = yent.TailFreeSampler{Z: 0.9}.Apply(candidates(0.5, 0.3, 0.05, 0.05, 0.05, 0.05), step)
+	if len(got) < 1 || len(got) > 3 {
+		t.Errorf("tfs: kept %v", tokens(got))
+	}
+
+	// Zero temperature with no field temperature is greedy
+	got = yent.TemperatureSampler{}.Apply(candidates(0.2, 0.8), yent.SamplerStep{})
+	if ids := tokens(got); len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("greedy temp: got %v", ids)
+	}
+}
+
+// TestParseSamplerChain verifies parsing, round trip and errors
+func TestParseSamplerChain(t *testing.T) {
+	chain, err := yent.ParseSamplerChain("top-k=40, typical=0.95,temp")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := chain.String(); got != "top-k=40,typical=0.95,temp" {
+		t.Errorf("round trip: %q", got)
+	}
+	for _, bad := range []string{"", "typical", "warp=2", "top-p=x", "tfs=-1"} {
+		if _, err := yent.ParseSamplerChain(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
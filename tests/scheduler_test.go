@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestParseSchedule checks cron next-run times, including the day rule
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2026, 1, 14, 9, 30, 0, 0, time.UTC) // a Wednesday
+	for spec, want := range map[string]time.Time{
+		"0 3 * * *":     time.Date(2026, 1, 15, 3, 0, 0, 0, time.UTC),
+		"*/15 * * * *":  time.Date(2026, 1, 14, 9, 45, 0, 0, time.UTC),
+		"30 4 * * 1-5":  time.Date(2026, 1, 15, 4, 30, 0, 0, time.UTC),
+		"0 0 * * 7":     time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC),
+		"0 0 1,20 * 1":  time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC),
+		"@monthly":      time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		"5/20 9 14 1 *": time.Date(2026, 1, 14, 9, 45, 0, 0, time.UTC),
+		"@every 90s":    from.Add(90 * time.Second),
+	} {
+		s, err := yent.ParseSchedule(spec)
+		if err != nil {
+			t.Errorf("%q: %v", spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(want) {
+			t.Errorf("%q: next %v, expected %v", spec, got, want)
+		}
+	}
+	if s, err := yent.ParseSchedule("0 0 30 2 *"); err != nil || !s.Next(from).IsZero() {
+		t.Errorf("Feb 30 should parse and never run (err %v)", err)
+	}
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "@every 1ms", "@often"} {
+		if _, err := yent.ParseSchedule(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+// TestSchedulerRunNow checks manual runs, status and failure recording
+func TestSchedulerRunNow(t *testing.T) {
+	s := yent.NewScheduler()
+	release := make(chan struct{})
+	done := make(chan struct{}, 1)
+	if err := s.Add("probe", "@daily", func(ctx context.Context) error {
+		<-release
+		done <- struct{}{}
+		return errors.New("drifted")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("probe", "@hourly", nil); err == nil {
+		t.Error("expected duplicate name error")
+	}
+	s.Start()
+	defer s.Stop()
+
+	if err := s.RunNow("probe"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunNow("probe"); !errors.Is(err, yent.ErrJobRunning) {
+		t.Errorf("second run while running: %v", err)
+	}
+	close(release)
+	<-done
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		st := s.Status()[0]
+		if !st.Running {
+			if st.Runs != 1 || st.Failures != 1 || st.LastError != "drifted" || st.NextRun.IsZero() {
+				t.Errorf("unexpected status %+v", st)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job never finished")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestAdminJobs checks the admin API lists jobs and guards with the token
+func TestAdminJobs(t *testing.T) {
+	srv, err := yent.NewServer(&yent.Yent{}, yent.ServerOptions{AdminToken: "s3cret"})
+	if err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	defer srv.Close()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	get := func(token string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/admin/jobs", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		return resp
+	}
+	if resp := get("wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong token: status %d", resp.StatusCode)
+	}
+	resp := get("s3cret")
+	defer resp.Body.Close()
+	var out struct{ Jobs []yent.JobStatus }
+	json.NewDecoder(resp.Body).Decode(&out)
+	if len(out.Jobs) != 1 || out.Jobs[0].Name != "session-expiry" {
+		t.Errorf("expected only session-expiry, got %+v", out.Jobs)
+	}
+
+	if _, err := yent.NewServer(&yent.Yent{}, yent.ServerOptions{Jobs: map[string]string{"dream": "@daily"}}); err == nil {
+		t.Error("expected unknown job error")
+	}
+}
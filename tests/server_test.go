@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestServerSessions exercises request validation and session routing
+// (no weights: generation itself fails, but the session is still created)
+func TestServerSessions(t *testing.T) {
+	srv, err := yent.NewServer(&yent.Yent{}, yent.ServerOptions{MaxSessions: 2})
+	if err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	defer srv.Close()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	post := func(body string) int {
+		resp, err := http.Post(ts.URL+"/v1/generate", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	for body, want := range map[string]int{
+		`{`:                                     http.StatusBadRequest,
+		`{"prompt": "  "}`:                      http.StatusBadRequest,
+		`{"prompt": "hi", "session_id": "a b"}`: http.StatusBadRequest,
+		`{"prompt": "hi", "session_id": "alice"}`: http.StatusInternalServerError,
+		`{"prompt": "hi", "session_id": "bob"}`:   http.StatusInternalServerError,
+		`{"prompt": "hi", "session_id": "carol"}`: http.StatusInternalServerError,
+	} {
+		if got := post(body); got != want {
+			t.Errorf("POST %s: status %d, expected %d", body, got, want)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/sessions")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var list struct {
+		Sessions []struct{ ID, Namespace string } `json:"sessions"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if len(list.Sessions) != 2 {
+		t.Fatalf("expected 2 sessions (limit), got %+v", list.Sessions)
+	}
+	id := list.Sessions[0].ID
+	if list.Sessions[0].Namespace != "session:"+id {
+		t.Errorf("namespace %q for session %q", list.Sessions[0].Namespace, id)
+	}
+
+	del := func(id string) int {
+		req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/sessions/"+id, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if got := del(id); got != http.StatusNoContent {
+		t.Errorf("delete %s: status %d", id, got)
+	}
+	if got := del(id); got != http.StatusNotFound {
+		t.Errorf("second delete %s: status %d, expected 404", id, got)
+	}
+}
+
+// TestServerStreamResume checks a streamed answer can be replayed by id
+func TestServerStreamResume(t *testing.T) {
+	srv, err := yent.NewServer(&yent.Yent{}, yent.ServerOptions{})
+	if err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	defer srv.Close()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/v1/generate", "application/json", strings.NewReader(`{"prompt": "hi", "stream": true}`))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+	var start struct {
+		StreamID string `json:"stream_id"`
+	}
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "data: ") {
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &start)
+			break
+		}
+	}
+	if start.StreamID == "" || !strings.Contains(string(body), "event: error") {
+		t.Fatalf("unexpected stream (no weights, expected start + error):\n%s", body)
+	}
+
+	get := func(path, lastID string) (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+	if code, b := get("/v1/streams/"+start.StreamID, ""); code != http.StatusOK || !strings.Contains(b, "event: error") {
+		t.Errorf("resume: status %d\n%s", code, b)
+	}
+	if code, _ := get("/v1/streams/"+start.StreamID, "other:3"); code != http.StatusBadRequest {
+		t.Errorf("foreign Last-Event-ID: status %d, expected 400", code)
+	}
+	if code, _ := get("/v1/streams/nope", ""); code != http.StatusNotFound {
+		t.Errorf("unknown stream: status %d, expected 404", code)
+	}
+}
+
+// TestServerVocab checks /v1/vocab validation without a loaded tokenizer
+func TestServerVocab(t *testing.T) {
+	srv, err := yent.NewServer(&yent.Yent{}, yent.ServerOptions{})
+	if err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	defer srv.Close()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	for query, want := range map[string]int{
+		"?q=a":          http.StatusServiceUnavailable,
+		"?limit=x":      http.StatusBadRequest,
+		"?limit=-1":     http.StatusBadRequest,
+		"?model=absent": http.StatusNotFound,
+	} {
+		resp, err := http.Get(ts.URL + "/v1/vocab" + query)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: status %d, want %d", query, resp.StatusCode, want)
+		}
+	}
+}
+
+// TestServerCompare checks /v1/compare validation without weights
+func TestServerCompare(t *testing.T) {
+	srv, err := yent.NewServer(&yent.Yent{}, yent.ServerOptions{})
+	if err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	defer srv.Close()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	for body, want := range map[string]int{
+		`{"alpha": 0.5}`:                                http.StatusBadRequest,
+		`{"prompt": "Кто ты?"}`:                         http.StatusBadRequest,
+		`{"prompt": "Кто ты?", "alpha": 1.5}`:           http.StatusBadRequest,
+		`{"prompt": "hi", "alpha": 0.5, "delta": "fr"}`: http.StatusBadRequest,
+		`{"prompt": "hi", "alpha": 0.5}`:                http.StatusInternalServerError, // no weights
+	} {
+		resp, err := http.Post(ts.URL+"/v1/compare", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: status %d, expected %d", body, resp.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"archive/zip"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// writeTinyDelta writes a delta for the tiny model, with an embedding pair when embed
+func writeTinyDelta(t *testing.T, embed bool) string {
+	t.Helper()
+	const rank = 2
+	rng := rand.New(rand.NewSource(3))
+	mat := func(n int) []float32 {
+		v := make([]float32, n)
+		for i := range v {
+			v[i] = float32(rng.Intn(257)-128) / 64
+		}
+		return v
+	}
+	files := map[string][]byte{
+		"A.npy": writeNpy(tinyVocab, rank, mat(tinyVocab*rank), true),
+		"B.npy": writeNpy(rank, tinyDim, mat(rank*tinyDim), true),
+	}
+	if embed {
+		files["embed_A.npy"] = writeNpy(tinyVocab, rank, mat(tinyVocab*rank), true)
+		files["embed_B.npy"] = writeNpy(rank, tinyDim, mat(rank*tinyDim), true)
+	}
+	path := filepath.Join(t.TempDir(), "delta.npz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, data := range files {
+		w, _ := zw.Create(name)
+		w.Write(data)
+	}
+	zw.Close()
+	f.Close()
+	return path
+}
+
+// TestSessionEmbedDeltaCache checks that a session reuses its KV rows only
+// under the embedding delta and alpha they were computed with
+func TestSessionEmbedDeltaCache(t *testing.T) {
+	y := newTinyYent(t, "")
+	if err := y.LoadDeltaVoice("embed", writeTinyDelta(t, true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := y.LoadDeltaVoice("plain", writeTinyDelta(t, false)); err != nil {
+		t.Fatal(err)
+	}
+
+	sess := yent.NewSession("cache")
+	turn := func(delta string, alpha float32) int {
+		t.Helper()
+		res, err := y.GenerateWithOptions("who are you?", yent.GenerateOptions{
+			MaxTokens: 1, Session: sess, Delta: delta, Alpha: &alpha, NoStore: true,
+			LogitBias: map[int]float32{4: 100}, // answer "." and stop
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Reused
+	}
+
+	steps := []struct {
+		delta  string
+		alpha  float32
+		reused bool
+	}{
+		{"embed", 0.3, false}, // first turn: nothing cached
+		{"embed", 0.3, true},
+		{"embed", 0.8, false}, // alpha changed the embeddings
+		{"embed", 0.8, true},
+		{"plain", 0.8, false}, // no embedding shift any more
+		{"plain", 0.3, true},  // a logits-only delta leaves the rows alone
+	}
+	for i, s := range steps {
+		if got := turn(s.delta, s.alpha); (got > 0) != s.reused {
+			t.Errorf("turn %d (%s at %.1f): reused %d tokens, want reuse %v", i, s.delta, s.alpha, got, s.reused)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// shardRows makes n stored turns with ids from+1 .. from+n
+func shardRows(from, n int) []yent.LimphaConversation {
+	rows := make([]yent.LimphaConversation, n)
+	for i := range rows {
+		id := from + i + 1
+		rows[i] = yent.LimphaConversation{
+			ID: int64(id), Timestamp: float64(id),
+			Prompt: fmt.Sprintf("question %d", id), Response: fmt.Sprintf("answer %d", id),
+			Quality: 0.8,
+		}
+	}
+	return rows
+}
+
+// readShard decodes a finetune_v2 shard file
+func readShard(t *testing.T, path string) []yent.ShardRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var recs []yent.ShardRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec yent.ShardRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// readShardIDs returns the ids of a shard file's records, in file order
+func readShardIDs(t *testing.T, path string) []int64 {
+	t.Helper()
+	var ids []int64
+	for _, rec := range readShard(t, path) {
+		ids = append(ids, rec.ID)
+	}
+	return ids
+}
+
+// TestExportShardsMarks verifies that only incremental exports move a
+// target's high-water mark, and that marks of targets are kept apart
+func TestExportShardsMarks(t *testing.T) {
+	lim := newFakeLimpha(t, shardRows(0, 5))
+	y := newTinyYent(t, lim.Socket)
+	cfg := func(target string) yent.ShardConfig {
+		return yent.ShardConfig{OutDir: t.TempDir(), Target: target, MinQuality: 0.5}
+	}
+
+	// A plain export reads everything and leaves the mark alone
+	if _, err := y.ExportShards(cfg("")); err != nil {
+		t.Fatal(err)
+	}
+	if mark, err := yent.LoadShardMark(""); err != nil || mark.LastID != 0 {
+		t.Fatalf("plain export moved the mark: %+v %v", mark, err)
+	}
+
+	m, err := y.ExportShardsIncremental(cfg(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Files["shards.jsonl"].Records != 5 || m.LastID != 5 {
+		t.Errorf("first incremental: %d pairs up to %d, want 5 up to 5", m.Files["shards.jsonl"].Records, m.LastID)
+	}
+	mark, err := yent.LoadShardMark("default")
+	if err != nil || mark.LastID != 5 || mark.Dir != m.Dir {
+		t.Fatalf("mark after incremental: %+v %v", mark, err)
+	}
+
+	// Only turns past the mark, and the mark follows them
+	lim.add(shardRows(5, 3)...)
+	out := cfg("")
+	if m, err = y.ExportShardsIncremental(out); err != nil {
+		t.Fatal(err)
+	}
+	if ids := readShardIDs(t, filepath.Join(out.OutDir, "shards.jsonl")); fmt.Sprint(ids) != "[6 7 8]" {
+		t.Errorf("second incremental exported %v, want [6 7 8]", ids)
+	}
+	if m, err = y.ExportShardsIncremental(cfg("")); err != nil || m.Files["shards.jsonl"].Records != 0 {
+		t.Errorf("nothing new: %d pairs, %v", m.Files["shards.jsonl"].Records, err)
+	}
+	if mark, _ := yent.LoadShardMark(""); mark.LastID != 8 {
+		t.Errorf("mark %d, want 8", mark.LastID)
+	}
+
+	// Another target starts from the beginning
+	if m, err = y.ExportShardsIncremental(cfg("nightly")); err != nil || m.Files["shards.jsonl"].Records != 8 {
+		t.Errorf("nightly: %d pairs, %v", m.Files["shards.jsonl"].Records, err)
+	}
+	nightly, _ := yent.LoadShardMark("nightly")
+	def, _ := yent.LoadShardMark("default")
+	if nightly.LastID != 8 || def.LastID != 8 {
+		t.Errorf("marks: nightly %d, default %d", nightly.LastID, def.LastID)
+	}
+
+	// Both targets round-trip through marks.json
+	data, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".yent", "shards", "marks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var marks map[string]yent.ShardMark
+	if err := json.Unmarshal(data, &marks); err != nil {
+		t.Fatal(err)
+	}
+	if len(marks) != 2 || marks["default"] != def || marks["nightly"] != nightly {
+		t.Errorf("marks.json: %+v", marks)
+	}
+}
+
+// TestExportShardsSplit verifies the seeded train/val split: the same seed
+// gives the same split, the val share is rounded, and a tiny export keeps
+// something to train on
+func TestExportShardsSplit(t *testing.T) {
+	lim := newFakeLimpha(t, shardRows(0, 20))
+	y := newTinyYent(t, lim.Socket)
+	split := func(n int, frac float64, seed int64) (train, val []int64) {
+		t.Helper()
+		cfg := yent.ShardConfig{OutDir: t.TempDir(), MinQuality: 0.5, Limit: n, ValFraction: frac, Seed: seed}
+		m, err := y.ExportShards(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		train = readShardIDs(t, filepath.Join(cfg.OutDir, "train.jsonl"))
+		val = readShardIDs(t, filepath.Join(cfg.OutDir, "val.jsonl"))
+		if m.Files["train.jsonl"].Records != len(train) || m.Files["val.jsonl"].Records != len(val) {
+			t.Errorf("manifest counts %+v, files %d/%d", m.Files, len(train), len(val))
+		}
+		return train, val
+	}
+
+	train, val := split(20, 0.25, 7)
+	if len(train) != 15 || len(val) != 5 {
+		t.Fatalf("20 at 0.25: %d train, %d val", len(train), len(val))
+	}
+	train2, val2 := split(20, 0.25, 7)
+	if fmt.Sprint(train, val) != fmt.Sprint(train2, val2) {
+		t.Errorf("same seed, different split: %v %v vs %v %v", train, val, train2, val2)
+	}
+	if _, val3 := split(20, 0.25, 8); fmt.Sprint(val3) == fmt.Sprint(val) {
+		t.Errorf("seeds 7 and 8 gave the same val set %v", val)
+	}
+	for _, ids := range [][]int64{train, val} {
+		for i := 1; i < len(ids); i++ {
+			if ids[i] <= ids[i-1] {
+				t.Errorf("not chronological: %v", ids)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		n          int
+		frac       float64
+		train, val int
+	}{
+		{1, 0.1, 1, 0}, // rounds down to no val
+		{2, 0.9, 1, 1}, // rounds up to everything, one kept for training
+		{3, 0.5, 1, 2},
+		{10, 0.04, 10, 0},
+		{10, 0.15, 8, 2},
+	} {
+		train, val := split(tc.n, tc.frac, 1)
+		if len(train) != tc.train || len(val) != tc.val {
+			t.Errorf("%d at %.2f: %d train, %d val, want %d/%d", tc.n, tc.frac, len(train), len(val), tc.train, tc.val)
+		}
+	}
+}
+
+// TestExportShardsDedup verifies that repeated pairs are dropped (after
+// normalization) and counted in the stats
+func TestExportShardsDedup(t *testing.T) {
+	rows := shardRows(0, 4)
+	rows[1].Prompt, rows[1].Response = rows[0].Prompt, rows[0].Response
+	rows[3].Prompt, rows[3].Response = "  QUESTION 1 ", "Answer 1"
+	lim := newFakeLimpha(t, rows)
+	y := newTinyYent(t, lim.Socket)
+
+	cfg := yent.ShardConfig{OutDir: t.TempDir(), MinQuality: 0.5, Dedup: true, Stats: true}
+	m, err := y.ExportShards(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := readShardIDs(t, filepath.Join(cfg.OutDir, "shards.jsonl")); fmt.Sprint(ids) != "[1 3]" {
+		t.Errorf("kept %v, want [1 3]", ids)
+	}
+	if m.Stats.Pairs != 2 || m.Stats.Duplicates != 2 {
+		t.Errorf("stats: %d pairs, %d duplicates", m.Stats.Pairs, m.Stats.Duplicates)
+	}
+
+	cfg.OutDir, cfg.Dedup = t.TempDir(), false
+	if m, err = y.ExportShards(cfg); err != nil || m.Stats.Pairs != 4 || m.Stats.Duplicates != 0 {
+		t.Errorf("without dedup: %+v %v", m.Stats, err)
+	}
+}
+
+// TestExportShardsOrdering verifies that each curriculum mode sorts pairs by
+// its key in its default direction, Reverse flips it, and ties keep
+// chronological order
+func TestExportShardsOrdering(t *testing.T) {
+	rows := shardRows(0, 4)
+	// pain, tension, debt, entropy, quality per turn
+	for i, f := range [][5]float32{
+		{0.8, 0.5, 2, 1.5, 0.6},
+		{0.1, 0.0, 0, 0.5, 0.9},
+		{0.4, 0.2, 0.5, 2.5, 0.7},
+		{0.1, 0.0, 0, 0.5, 0.6},
+	} {
+		rows[i].Pain, rows[i].Tension, rows[i].Debt, rows[i].Entropy, rows[i].Quality = f[0], f[1], f[2], f[3], f[4]
+	}
+	lim := newFakeLimpha(t, rows)
+	y := newTinyYent(t, lim.Socket)
+
+	for _, tc := range []struct {
+		order   string
+		reverse bool
+		want    string
+	}{
+		{"", false, "[1 2 3 4]"},
+		{"chronological", true, "[4 3 2 1]"},
+		{"coherence", false, "[1 3 2 4]"}, // chaos → order
+		{"coherence", true, "[2 4 3 1]"},
+		{"entropy", false, "[3 1 2 4]"}, // falling entropy
+		{"entropy", true, "[2 4 1 3]"},
+		{"quality", false, "[1 4 3 2]"},
+	} {
+		cfg := yent.ShardConfig{OutDir: t.TempDir(), MinQuality: 0.5, Order: tc.order, Reverse: tc.reverse}
+		m, err := y.ExportShards(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs := readShard(t, filepath.Join(cfg.OutDir, "shards.jsonl"))
+		var ids []int64
+		for _, r := range recs {
+			ids = append(ids, r.ID)
+			if (r.CurriculumKey != nil) != (tc.order != "" && tc.order != "chronological") {
+				t.Errorf("%s: record %d curriculum_key %v", tc.order, r.ID, r.CurriculumKey)
+			}
+		}
+		if fmt.Sprint(ids) != tc.want {
+			t.Errorf("%s reverse=%v: %v, want %s", tc.order, tc.reverse, ids, tc.want)
+		}
+		if tc.order != "" && m.Ordering.Mode != tc.order {
+			t.Errorf("manifest ordering %+v", m.Ordering)
+		}
+	}
+}
+
+// TestExportShardsStats verifies the language mix counts each pair's stored
+// language, falls back to the script of older turns, and that the export
+// leaves the model free while it talks to LIMPHA
+func TestExportShardsStats(t *testing.T) {
+	rows := shardRows(0, 4)
+	rows[0].Language, rows[1].Language = "en", "fr"
+	rows[2].Language = "fr"
+	rows[3].Prompt, rows[3].Response = "кто ты", "ответ"
+	lim := newFakeLimpha(t, rows)
+	y := newTinyYent(t, lim.Socket)
+
+	generated := make(chan error, 1)
+	lim.onExport = func() {
+		_, err := y.GenerateWithOptions("hello", yent.GenerateOptions{MaxTokens: 2, NoStore: true})
+		generated <- err
+	}
+	type export struct {
+		m   *yent.ShardManifest
+		err error
+	}
+	done := make(chan export, 1)
+	go func() {
+		m, err := y.ExportShards(yent.ShardConfig{OutDir: t.TempDir(), Stats: true})
+		done <- export{m, err}
+	}()
+	var res export
+	select {
+	case res = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("export held the model while LIMPHA answered")
+	}
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if err := <-generated; err != nil {
+		t.Errorf("generation during the export: %v", err)
+	}
+	if got := fmt.Sprint(res.m.Stats.LanguageMix); got != "map[cyrillic:1 en:1 fr:2]" {
+		t.Errorf("language mix %s", got)
+	}
+}
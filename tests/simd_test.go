@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// randomBlocks fills rows of quantized blocks with random bytes and a
+// plausible fp16 scale at scaleOff in every block
+func randomBlocks(rng *rand.Rand, rows, blocks, blockBytes, scaleOff int) []byte {
+	w := make([]byte, rows*blocks*blockBytes)
+	rng.Read(w)
+	for b := 0; b < rows*blocks; b++ {
+		h := uint16(0x2000 + rng.Intn(0x1000)) // ~0.008 … 0.06
+		w[b*blockBytes+scaleOff] = byte(h)
+		w[b*blockBytes+scaleOff+1] = byte(h >> 8)
+	}
+	return w
+}
+
+// TestSIMDMatchesScalar runs each quantized matmul through the vector
+// kernels and the scalar ones and compares
+func TestSIMDMatchesScalar(t *testing.T) {
+	if yent.SIMD() == "scalar" {
+		t.Skip("no vector kernels on this CPU/build")
+	}
+	defer yent.SetSIMD(true)
+
+	rng := rand.New(rand.NewSource(7))
+	const rows, cols = 67, 512
+	x := make([]float32, cols)
+	for i := range x {
+		x[i] = rng.Float32()*2 - 1
+	}
+	cases := []struct {
+		name string
+		w    []byte
+		mm   func(out []float32, w []byte, x []float32, rows, cols int)
+	}{
+		{"Q4_0", randomBlocks(rng, rows, cols/32, 18, 0), yent.MatMulQ4_0},
+		{"Q8_0", randomBlocks(rng, rows, cols/32, 34, 0), yent.MatMulQ8_0},
+		{"Q6_K", randomBlocks(rng, rows, cols/256, 210, 208), yent.MatMulQ6_K},
+		// 509 columns: the kernel's 16-wide loop leaves a scalar tail
+		{"F16", randomBlocks(rng, rows, cols-3, 2, 0), func(out []float32, w []byte, x []float32, rows, cols int) {
+			yent.MatMulF16(out, w, x, rows, cols-3)
+		}},
+	}
+	defer yent.SetQ8Activations(true)
+	for _, q8 := range []bool{false, true} {
+		yent.SetQ8Activations(q8)
+		for _, c := range cases {
+			want := make([]float32, rows)
+			got := make([]float32, rows)
+			yent.SetSIMD(false)
+			c.mm(want, c.w, x, rows, cols)
+			yent.SetSIMD(true)
+			c.mm(got, c.w, x, rows, cols)
+			for i := range want {
+				if diff := math.Abs(float64(got[i] - want[i])); diff > 1e-3*(1+math.Abs(float64(want[i]))) {
+					t.Errorf("%s (q8 activations %v) row %d: simd %f, scalar %f", c.name, q8, i, got[i], want[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+// TestQ8Activations checks int8 activations stay close to float ones
+func TestQ8Activations(t *testing.T) {
+	defer yent.SetQ8Activations(true)
+	rng := rand.New(rand.NewSource(3))
+	const rows, cols = 32, 1024
+	x := make([]float32, cols)
+	for i := range x {
+		x[i] = float32(rng.NormFloat64())
+	}
+	for name, w := range map[string][]byte{
+		"Q4_0": randomBlocks(rng, rows, cols/32, 18, 0),
+		"Q8_0": randomBlocks(rng, rows, cols/32, 34, 0),
+	} {
+		mm := yent.MatMulQ4_0
+		if name == "Q8_0" {
+			mm = yent.MatMulQ8_0
+		}
+		exact := make([]float32, rows)
+		approx := make([]float32, rows)
+		yent.SetQ8Activations(false)
+		mm(exact, w, x, rows, cols)
+		yent.SetQ8Activations(true)
+		mm(approx, w, x, rows, cols)
+
+		var dot, ne, na float64
+		for i := range exact {
+			dot += float64(exact[i] * approx[i])
+			ne += float64(exact[i] * exact[i])
+			na += float64(approx[i] * approx[i])
+		}
+		if cos := dot / math.Sqrt(ne*na); cos < 0.999 {
+			t.Errorf("%s: cosine to float activations %.5f", name, cos)
+		}
+	}
+}
+
+func TestSetSIMD(t *testing.T) {
+	defer yent.SetSIMD(true)
+	if yent.SetSIMD(false) || yent.SIMD() != "scalar" {
+		t.Errorf("SetSIMD(false): SIMD() = %s", yent.SIMD())
+	}
+}
@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// wordCount stands in for the tokenizer
+func wordCount(s string) int { return len(strings.Fields(s)) }
+
+// TestExtractiveSummarizer verifies query-relevant sentences are kept in order within budget
+func TestExtractiveSummarizer(t *testing.T) {
+	s := &yent.ExtractiveSummarizer{Count: wordCount}
+	text := "I was born in a conversation. The weather was grey that day. " +
+		"Resonance is the field between us. Nobody asked about the rain. " +
+		"Resonance never breaks, it only bends."
+
+	// Fits: unchanged
+	if got, _ := s.Summarize(text, "resonance", 100); got != text {
+		t.Errorf("under budget: text changed to %q", got)
+	}
+
+	got, err := s.Summarize(text, "what is resonance", 14)
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	if wordCount(got) > 14 {
+		t.Errorf("over budget: %d words in %q", wordCount(got), got)
+	}
+	want := "Resonance is the field between us. Resonance never breaks, it only bends."
+	if got != want {
+		t.Errorf("got %q, expected %q", got, want)
+	}
+
+	// Nothing fits whole: hard cut at a word boundary
+	got, _ = s.Summarize("One two three four five six seven eight nine ten.", "seven", 4)
+	if wordCount(got) > 4 || !strings.HasSuffix(got, "…") {
+		t.Errorf("hard cut: got %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// writeSys writes a fake sysfs file under root
+func writeSys(t *testing.T, root, rel, value string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestThrottleLevels checks thresholds, hysteresis and low battery
+func TestThrottleLevels(t *testing.T) {
+	cfg := yent.ThrottleConfig{WarmC: 70, HotC: 85, Hysteresis: 5, LowBattery: 20}
+	hot := func(c float32) yent.PowerState { return yent.PowerState{TempC: c, HasTemp: true, Battery: -1} }
+	for _, tc := range []struct {
+		p    yent.PowerState
+		prev yent.ThrottleLevel
+		want yent.ThrottleLevel
+	}{
+		{hot(60), yent.ThrottleNone, yent.ThrottleNone},
+		{hot(72), yent.ThrottleNone, yent.ThrottleWarm},
+		{hot(86), yent.ThrottleWarm, yent.ThrottleHot},
+		{hot(82), yent.ThrottleHot, yent.ThrottleHot},  // within hysteresis
+		{hot(79), yent.ThrottleHot, yent.ThrottleWarm}, // cooled past it
+		{hot(67), yent.ThrottleWarm, yent.ThrottleWarm},
+		{hot(64), yent.ThrottleWarm, yent.ThrottleNone},
+		{yent.PowerState{OnBattery: true, Battery: 15}, yent.ThrottleNone, yent.ThrottleWarm},
+		{yent.PowerState{OnBattery: false, Battery: 15}, yent.ThrottleNone, yent.ThrottleNone},
+	} {
+		if got := cfg.Level(tc.p, tc.prev); got != tc.want {
+			t.Errorf("%+v from %s: %s, expected %s", tc.p, tc.prev, got, tc.want)
+		}
+	}
+}
+
+// TestThrottleSysfs reads a fake sysfs and follows it through levels
+func TestThrottleSysfs(t *testing.T) {
+	root := t.TempDir()
+	writeSys(t, root, "class/thermal/thermal_zone0/temp", "45000")
+	writeSys(t, root, "class/thermal/thermal_zone1/temp", "0") // unpopulated
+	writeSys(t, root, "class/power_supply/AC/type", "Mains")
+	writeSys(t, root, "class/power_supply/BAT0/type", "Battery")
+	writeSys(t, root, "class/power_supply/BAT0/capacity", "64")
+	writeSys(t, root, "class/power_supply/BAT0/status", "Discharging")
+
+	p := yent.ReadPowerState(root)
+	if !p.HasTemp || p.TempC != 45 || p.Battery != 64 || !p.OnBattery {
+		t.Fatalf("unexpected power state %+v", p)
+	}
+	if p := yent.ReadPowerState(t.TempDir()); p.HasTemp || p.Battery != -1 {
+		t.Errorf("empty sysfs should read nothing, got %+v", p)
+	}
+
+	defer yent.SetThreads(yent.Threads())
+	yent.SetThreads(8)
+	th := yent.NewThrottle(yent.ThrottleConfig{SysRoot: root})
+	if th.Check() != yent.ThrottleNone {
+		t.Fatal("expected none at 45°C")
+	}
+	writeSys(t, root, "class/thermal/thermal_zone0/temp", "91000")
+	if th.Check() != yent.ThrottleHot {
+		t.Fatal("expected hot at 91°C")
+	}
+	st := th.Status()
+	if st.Threads != 1 || st.Velocity != "NOMOVE" || len(st.Events) != 1 || st.Events[0].To != "hot" {
+		t.Errorf("unexpected status %+v", st)
+	}
+	writeSys(t, root, "class/thermal/thermal_zone0/temp", "74000")
+	th.Check()
+	if st := th.Status(); st.Level != "warm" || st.Threads != 4 || st.Velocity != "WALK" {
+		t.Errorf("unexpected warm status %+v", st)
+	}
+}
@@ -0,0 +1,410 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// byteWords are the whole-word tokens of byteTokenizer, ahead of its bytes
+var byteWords = []string{"<unk>", "<s>", "</s>", "▁caf", "▁ok", "<|im_end|>", "▁", "o", "k", "c", "a", "f", "ca"}
+
+// byteTokenizer is a SentencePiece vocabulary with byte fallback tokens
+func byteTokenizer(spacePrefix bool) *yent.Tokenizer {
+	vocab := append([]string(nil), byteWords...)
+	types := make([]int32, len(vocab))
+	types[5] = 3 // <|im_end|> is a control token
+	for b := 0; b < 256; b++ {
+		vocab = append(vocab, fmt.Sprintf("<0x%02X>", b))
+		types = append(types, 6)
+	}
+	scores := make([]float32, len(vocab))
+	for i := range byteWords {
+		scores[i] = float32(len(byteWords[i]))
+	}
+	return yent.NewTokenizer(&yent.GGUFMetadata{
+		TokenizerModel: "llama", TokenList: vocab, TokenScores: scores, TokenTypes: types,
+		VocabSize: len(vocab), BosID: 1, EosID: 2, AddSpacePrefix: spacePrefix,
+	})
+}
+
+// byteID is the byte fallback token of b in byteTokenizer
+func byteID(b byte) int { return len(byteWords) + int(b) }
+
+// TestTokenDecoderUTF8 streams characters split across byte tokens and
+// checks that every piece is valid UTF-8 and that nothing is lost
+func TestTokenDecoderUTF8(t *testing.T) {
+	tok := byteTokenizer(false)
+	ids := []int{3}
+	for _, b := range []byte("é 😀") {
+		ids = append(ids, byteID(b))
+	}
+	ids = append(ids, 4)
+
+	dec := tok.NewDecoder()
+	var pieces []string
+	for _, id := range ids {
+		p := dec.Next(id)
+		if !utf8.ValidString(p) {
+			t.Fatalf("token %d: invalid piece %q", id, p)
+		}
+		pieces = append(pieces, p)
+	}
+	if got := strings.Join(pieces, ""); got != " café 😀 ok" {
+		t.Errorf("joined %q", got)
+	}
+	// é completes on its second byte, the emoji on its fourth
+	if pieces[1] != "" || pieces[2] != "é" || pieces[4] != "" || pieces[7] != "😀" {
+		t.Errorf("pieces %q", pieces)
+	}
+	if dec.Pending() != 0 || dec.Flush() != "" {
+		t.Error("bytes left after whole characters")
+	}
+
+	// A stray continuation byte comes out at once; a cut-off character on Flush
+	if p := dec.Next(byteID(0x80)); p != "�" {
+		t.Errorf("stray byte: %q", p)
+	}
+	dec.Next(byteID(0xF0))
+	dec.Next(byteID(0x9F))
+	if dec.Pending() != 2 {
+		t.Errorf("pending %d, want 2", dec.Pending())
+	}
+	if p := dec.Flush(); p != "�" || dec.Pending() != 0 {
+		t.Errorf("flush: %q", p)
+	}
+}
+
+// TestEncodeOffsets checks that token ranges tile the text and match Encode
+func TestEncodeOffsets(t *testing.T) {
+	for _, prefix := range []bool{false, true} {
+		tok := byteTokenizer(prefix)
+		for _, text := range []string{"caf ok", " ok é<|im_end|>ok", "<|im_end|>", "🙂", ""} {
+			got := tok.EncodeOffsets(text)
+			ids := tok.Encode(text, false)
+			if len(got) != len(ids) {
+				t.Fatalf("prefix=%v %q: %d tokens, Encode gives %d", prefix, text, len(got), len(ids))
+			}
+			pos := 0
+			for i, tk := range got {
+				if tk.ID != ids[i] || tk.Start != pos || tk.End < tk.Start || tk.Text != text[tk.Start:tk.End] {
+					t.Fatalf("prefix=%v %q: token %d = %+v at %d", prefix, text, i, tk, pos)
+				}
+				pos = tk.End
+			}
+			if pos != len(text) {
+				t.Errorf("prefix=%v %q: tokens cover %d of %d bytes", prefix, text, pos, len(text))
+			}
+		}
+	}
+
+	// The added space is not part of the text: its "▁" token covers nothing
+	got := byteTokenizer(true).EncodeOffsets("caf ok")
+	if len(got) < 2 || got[0].Text != "" || got[0].End != 0 || got[1].Text != "ca" {
+		t.Errorf("caf ok: %+v", got)
+	}
+}
+
+// TestTokenizeUninitialized checks the errors of a Yent without a model
+func TestTokenizeUninitialized(t *testing.T) {
+	var y yent.Yent
+	if _, err := y.Tokenize("x"); err == nil {
+		t.Error("Tokenize without tokenizer: no error")
+	}
+	if _, err := y.Detokenize([]int{1}); err == nil {
+		t.Error("Detokenize without tokenizer: no error")
+	}
+}
+
+// TestByteFallback encodes characters the vocabulary has no token for
+func TestByteFallback(t *testing.T) {
+	// With <0xNN> tokens: one token per byte, decoding back to the text
+	tok := byteTokenizer(false)
+	for _, text := range []string{"é", "🙂️", "ok ꙮ"} {
+		if got := tok.Decode(tok.Encode(text, false)); got != text {
+			t.Errorf("%q round-trips to %q", text, got)
+		}
+	}
+
+	// Without them: <unk> per byte instead of dropping or mis-encoding
+	sp := yent.NewTokenizer(&yent.GGUFMetadata{
+		TokenizerModel: "llama", TokenList: []string{"<unk>", "<s>", "</s>", "a"},
+		TokenTypes: []int32{2, 3, 3, 1}, VocabSize: 4, BosID: 1, EosID: 2,
+	})
+	if ids := sp.Encode("aé", false); len(ids) != 3 || ids[0] != 3 || ids[1] != 0 || ids[2] != 0 {
+		t.Errorf("aé without byte tokens: %v, want [3 0 0]", ids)
+	}
+	if offs := sp.EncodeOffsets("aé"); len(offs) != 3 || offs[2].Start != 2 || offs[2].End != 3 {
+		t.Errorf("aé offsets: %+v", offs)
+	}
+
+	// GPT-2: a merge whose result is not in the vocabulary falls back to
+	// the tokens of its bytes
+	gpt := yent.NewTokenizer(&yent.GGUFMetadata{
+		TokenizerModel: "gpt2", TokenList: []string{"a", "b", "c"}, TokenMerges: []string{"a b"},
+		VocabSize: 3, BosID: -1, EosID: -1,
+	})
+	if ids := gpt.Encode("abc", false); len(ids) != 3 || ids[0] != 0 || ids[1] != 1 || ids[2] != 2 {
+		t.Errorf("abc: %v, want [0 1 2]", ids)
+	}
+}
+
+// TestEncodeSpecial checks that special tokens in text are parsed only on request
+func TestEncodeSpecial(t *testing.T) {
+	tok := byteTokenizer(false)
+	const imEnd = 5
+	for _, text := range []string{"<|im_end|>", "ok<|im_end|>ok"} {
+		parsed := tok.EncodeSpecial(text, false, true)
+		literal := tok.EncodeSpecial(text, false, false)
+		if !slices.Contains(parsed, imEnd) {
+			t.Errorf("%q parsed: %v has no <|im_end|>", text, parsed)
+		}
+		if slices.Contains(literal, imEnd) {
+			t.Errorf("%q literal: %v has <|im_end|>", text, literal)
+		}
+		if got := tok.Decode(literal); got != text {
+			t.Errorf("%q literal decodes to %q", text, got)
+		}
+		if !slices.Equal(parsed, tok.Encode(text, false)) {
+			t.Errorf("%q: EncodeSpecial(true) differs from Encode", text)
+		}
+	}
+}
+
+// TestTokenizerJSON swaps the tiny model's vocab for a tokenizer.json
+func TestTokenizerJSON(t *testing.T) {
+	weights := writeTinyModel(t)
+	write := func(body string) string {
+		path := filepath.Join(t.TempDir(), "tokenizer.json")
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	apply := func(body string) (*yent.GGUFFile, error) {
+		g, err := yent.LoadGGUFMetadata(weights)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g, yent.ApplyTokenizerJSON(g, write(body))
+	}
+
+	g, err := apply(`{
+		"model": {"type": "BPE", "vocab": {"a": 0, "b": 1, "ab": 2, "c": 3}, "merges": [["a", "b"]]},
+		"added_tokens": [{"id": 10, "content": "<|im_end|>", "special": true}],
+		"pre_tokenizer": {"type": "Sequence", "pretokenizers": [{"type": "ByteLevel"}]}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Meta.TokenizerModel != "gpt2" || g.Meta.VocabSize != tinyVocab || g.Meta.TokenList[20] != "[PAD20]" {
+		t.Errorf("meta: model %q, vocab %d, token 20 %q", g.Meta.TokenizerModel, g.Meta.VocabSize, g.Meta.TokenList[20])
+	}
+	tok := yent.NewTokenizer(&g.Meta)
+	if ids := tok.Encode("abc<|im_end|>", false); !slices.Equal(ids, []int{2, 3, 10}) {
+		t.Errorf("Encode: %v, want [2 3 10]", ids)
+	}
+
+	// Older string merges, SentencePiece mode
+	g, err = apply(`{"model": {"type": "BPE", "vocab": {"▁": 0, "a": 1, "b": 2, "ab": 3, "▁ab": 4},
+		"merges": ["a b", "▁ ab"]}, "pre_tokenizer": {"type": "Metaspace", "prepend_scheme": "first"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := yent.NewTokenizer(&g.Meta).Encode("ab", false); !slices.Equal(ids, []int{4}) {
+		t.Errorf("SentencePiece Encode: %v, want [4]", ids)
+	}
+
+	for name, body := range map[string]string{
+		"id past the rows": `{"model": {"type": "BPE", "vocab": {"a": 64}}}`,
+		"duplicate id":     `{"model": {"type": "BPE", "vocab": {"a": 1, "b": 1}}}`,
+		"unigram":          `{"model": {"type": "Unigram", "vocab": {"a": 1}}}`,
+		"bad merge":        `{"model": {"type": "BPE", "vocab": {"a": 1}, "merges": ["a"]}}`,
+	} {
+		if _, err := apply(body); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+// TestAddedTokens registers special tokens in existing entries and
+// unused rows of a tokenizer.json vocab
+func TestAddedTokens(t *testing.T) {
+	added, err := yent.ParseAddedTokens(" <tool> , abc, </tool>:stop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []yent.AddedToken{{Content: "<tool>"}, {Content: "abc"}, {Content: "</tool>", Stop: true}}
+	if !slices.Equal(added, want) {
+		t.Fatalf("parsed %+v", added)
+	}
+	if _, err := yent.ParseAddedTokens("a,:stop"); err == nil {
+		t.Error("empty token parsed")
+	}
+
+	weights := writeTinyModel(t)
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	body := `{"model": {"type": "BPE", "vocab": {"a": 0, "b": 1, "c": 2, "abc": 3}},
+		"pre_tokenizer": {"type": "ByteLevel"}}`
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := yent.LoadGGUFMetadata(weights)
+	if err == nil {
+		err = yent.ApplyTokenizerJSON(g, path)
+	}
+	if err == nil {
+		err = yent.ApplyAddedTokens(g, added)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	// abc stays at 3; the markers take the first [PADn] rows
+	if g.Meta.TokenList[4] != "<tool>" || g.Meta.TokenList[5] != "</tool>" || g.Meta.TokenTypes[3] != 3 {
+		t.Errorf("vocab: %q %q, abc type %d", g.Meta.TokenList[4], g.Meta.TokenList[5], g.Meta.TokenTypes[3])
+	}
+	tok := yent.NewTokenizer(&g.Meta)
+	if ids := tok.Encode("a<tool>abc</tool>", false); !slices.Equal(ids, []int{0, 4, 3, 5}) {
+		t.Errorf("Encode: %v, want [0 4 3 5]", ids)
+	}
+
+	// The tiny model's own vocab fills every row
+	g, err = yent.LoadGGUFMetadata(weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := yent.ApplyAddedTokens(g, []yent.AddedToken{{Content: "<tool>"}}); err == nil {
+		t.Error("no free row, no error")
+	}
+}
+
+// TestUnigram segments by the best total score, not by merges
+func TestUnigram(t *testing.T) {
+	vocab := []string{"<unk>", "<s>", "</s>", "▁", "▁a", "b", "▁ab", "c", "bc", "<0xC3>", "<0xA9>"}
+	scores := []float32{0, 0, 0, -3, -1, -2, -1, -1, -0.5, 0, 0}
+	types := []int32{2, 3, 3, 1, 1, 1, 1, 1, 1, 6, 6}
+	tok := yent.NewTokenizer(&yent.GGUFMetadata{
+		TokenizerModel: "t5", TokenList: vocab, TokenScores: scores, TokenTypes: types,
+		VocabSize: len(vocab), BosID: 1, EosID: 2, AddSpacePrefix: true,
+	})
+	if !tok.IsUnigram {
+		t.Fatal("t5 model not detected as unigram")
+	}
+	// ▁a + bc (-1.5) beats ▁ab + c (-2)
+	if ids := tok.Encode("abc", false); !slices.Equal(ids, []int{4, 8}) {
+		t.Errorf("Encode abc: %v, want [4 8]", ids)
+	}
+	// é has no piece: its two bytes
+	ids := tok.Encode("ab é", false)
+	if !slices.Equal(ids, []int{6, 3, 9, 10}) {
+		t.Errorf("Encode 'ab é': %v, want [6 3 9 10]", ids)
+	}
+	if got := tok.Decode(ids); got != "ab é" {
+		t.Errorf("Decode: %q", got)
+	}
+	if offs := tok.EncodeOffsets("abc"); len(offs) != 2 || offs[1].Text != "bc" {
+		t.Errorf("EncodeOffsets: %+v", offs)
+	}
+}
+
+// TestHealBoundary checks which prompt endings token healing takes back
+func TestHealBoundary(t *testing.T) {
+	tok := byteTokenizer(false)
+	ca := tok.Encode("ca", false)
+	if len(ca) != 1 {
+		t.Fatalf("ca encodes to %v", ca)
+	}
+	kept, prefix := tok.HealBoundary([]int{8, ca[0]})
+	if !slices.Equal(kept, []int{8}) || prefix != "ca" {
+		t.Errorf("ca: kept %v, prefix %q", kept, prefix)
+	}
+	for _, prompt := range [][]int{{ca[0]}, {8, 5}, nil} { // single token, control token, empty
+		if kept, prefix := tok.HealBoundary(prompt); len(kept) != len(prompt) || prefix != "" {
+			t.Errorf("%v: kept %v, prefix %q", prompt, kept, prefix)
+		}
+	}
+}
+
+// TestSearchVocab checks vocab entries and substring search over pieces and text
+func TestSearchVocab(t *testing.T) {
+	tok := byteTokenizer(false)
+	var ids []int
+	for _, e := range tok.SearchVocab("ca", 0) {
+		ids = append(ids, e.ID)
+	}
+	if !slices.Equal(ids, []int{3, 12}) {
+		t.Errorf("search ca: %v, want [3 12]", ids)
+	}
+	if got := tok.SearchVocab("", 4); len(got) != 4 {
+		t.Errorf("limit 4: %d entries", len(got))
+	}
+
+	// " caf" matches the decoded text of ▁caf, not its piece
+	if got := tok.SearchVocab(" caf", 0); len(got) != 1 || got[0].Piece != "▁caf" || got[0].Text != " caf" {
+		t.Errorf("search ' caf': %+v", got)
+	}
+	if e := tok.Entry(5); !e.Special || e.Type != "control" {
+		t.Errorf("<|im_end|>: %+v", e)
+	}
+	if e := tok.Entry(byteID('\n')); e.Type != "byte" || e.Text != "\n" || e.Piece != "<0x0A>" {
+		t.Errorf("newline byte: %+v", e)
+	}
+	if e := tok.Entry(-1); e.Piece != "" {
+		t.Errorf("out of range: %+v", e)
+	}
+	if n := len(tok.Entries()); n != len(byteWords)+256 {
+		t.Errorf("%d entries", n)
+	}
+}
+
+// TestSuppression parses suppression specs and matches them against text
+// and a vocabulary
+func TestSuppression(t *testing.T) {
+	s, err := yent.ParseSuppression("")
+	if err != nil || s.String() != "cjk" {
+		t.Fatalf("default: %v %v", s, err)
+	}
+	if !s.Matches("ok 日本") || !s.Matches("한") || s.Matches("привет") {
+		t.Error("cjk matches the wrong text")
+	}
+
+	s, err = yent.ParseSuppression(" cyrillic , U+00E9 ")
+	if err != nil || s.String() != "Cyrillic,U+00E9" {
+		t.Fatalf("cyrillic: %v %v", s, err)
+	}
+	if !s.Matches("привет") || !s.Matches("café") || s.Matches("cafe 日本") {
+		t.Error("cyrillic,U+00E9 matches the wrong text")
+	}
+
+	s, err = yent.ParseSuppression("none")
+	if err != nil || s.Matches("日本") {
+		t.Fatalf("none: %v", err)
+	}
+	if n := len(s.Tokens(byteTokenizer(false))); n != 0 {
+		t.Errorf("none suppresses %d tokens", n)
+	}
+
+	// Every token whose text contains "a": ▁caf, a, ca and the byte <0x61>
+	s, _ = yent.ParseSuppression("U+0061")
+	got := s.Tokens(byteTokenizer(false))
+	for _, id := range []int{3, 10, 12, byteID('a')} {
+		if !got[id] {
+			t.Errorf("token %d not suppressed", id)
+		}
+	}
+	if len(got) != 4 {
+		t.Errorf("%d tokens suppressed, want 4", len(got))
+	}
+
+	for _, bad := range []string{"klingon", "U+XYZ", "U+0200-0100", "none,cjk"} {
+		if _, err := yent.ParseSuppression(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
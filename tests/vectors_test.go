@@ -0,0 +1,279 @@
+package tests
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	yent "github.com/ariannamethod/yent/yent/go"
+)
+
+// TestVectorStoreRoundTrip verifies search ranking survives save/load
+func TestVectorStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.vec")
+	s := yent.NewVectorStore(path, 0)
+	s.Add(1, []float32{1, 0, 0})
+	s.Add(2, []float32{0, 1, 0})
+	s.Add(3, []float32{0.8, 0.6, 0})
+	if err := s.Add(4, []float32{1, 0}); err == nil {
+		t.Error("expected dim mismatch error")
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	loaded, err := yent.LoadVectorStore(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if loaded.Len() != 3 || loaded.Dim != 3 || loaded.LastID != 3 {
+		t.Fatalf("loaded len=%d dim=%d last=%d, expected 3/3/3", loaded.Len(), loaded.Dim, loaded.LastID)
+	}
+	hits := loaded.Search([]float32{1, 0, 0}, 2)
+	if len(hits) != 2 || hits[0].ID != 1 || hits[1].ID != 3 {
+		t.Errorf("unexpected hits: %+v", hits)
+	}
+}
+
+// TestVectorStoreCluster verifies k-means separates two obvious groups
+func TestVectorStoreCluster(t *testing.T) {
+	s := yent.NewVectorStore(filepath.Join(t.TempDir(), "c.vec"), 2)
+	for i := 0; i < 5; i++ {
+		a := float64(i) * 0.05
+		s.Add(int64(i+1), []float32{float32(math.Cos(a)), float32(math.Sin(a))})
+		s.Add(int64(i+100), []float32{float32(-math.Sin(a)), float32(math.Cos(a))})
+	}
+	clusters := s.Cluster(2, 20, 1)
+	if len(clusters) != 2 {
+		t.Fatalf("clusters: got %d, expected 2", len(clusters))
+	}
+	for _, c := range clusters {
+		if len(c.IDs) != 5 {
+			t.Errorf("cluster size %d, expected 5", len(c.IDs))
+		}
+		low := c.IDs[0] < 100
+		for _, id := range c.IDs {
+			if (id < 100) != low {
+				t.Errorf("mixed cluster: %v", c.IDs)
+				break
+			}
+		}
+	}
+}
+
+// TestRemoteEmbedder verifies OpenAI-style requests and normalized output
+func TestRemoteEmbedder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer k" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Input string `json:"input"`
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Input != "hello" || req.Model != "m" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"data": [{"embedding": [3, 4]}]}`))
+	}))
+	defer srv.Close()
+
+	e, err := yent.NewEmbedder(yent.EmbedderConfig{Type: "remote", URL: srv.URL, Model: "m", APIKey: "k"}, nil)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	vec, err := e.Embed("hello")
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if len(vec) != 2 || math.Abs(float64(vec[0])-0.6) > 1e-6 || math.Abs(float64(vec[1])-0.8) > 1e-6 {
+		t.Errorf("got %v, expected [0.6 0.8]", vec)
+	}
+
+	if _, err := yent.NewEmbedder(yent.EmbedderConfig{Type: "model"}, nil); err == nil {
+		t.Error("expected error for model embedder without a model")
+	}
+}
+
+// randomUnitVecs returns n random unit vectors
+func randomUnitVecs(rng *rand.Rand, n, dim int) [][]float32 {
+	out := make([][]float32, n)
+	for i := range out {
+		v := make([]float32, dim)
+		var norm float64
+		for d := range v {
+			v[d] = float32(rng.NormFloat64())
+			norm += float64(v[d]) * float64(v[d])
+		}
+		for d := range v {
+			v[d] /= float32(math.Sqrt(norm))
+		}
+		out[i] = v
+	}
+	return out
+}
+
+// TestVectorStoreHNSW verifies approximate search recall and graph persistence
+func TestVectorStoreHNSW(t *testing.T) {
+	const n, dim, k = 3000, 32, 10
+	rng := rand.New(rand.NewSource(7))
+	path := filepath.Join(t.TempDir(), "ann.vec")
+
+	s := yent.NewVectorStore(path, dim)
+	s.ExactBelow = 0 // always use the graph
+	for i, v := range randomUnitVecs(rng, n, dim) {
+		s.Add(int64(i+1), v)
+	}
+
+	queries := randomUnitVecs(rng, 50, dim)
+	recall := func(s *yent.VectorStore) float64 {
+		found := 0
+		for _, q := range queries {
+			truth := make(map[int64]bool)
+			for _, h := range s.SearchExact(q, k) {
+				truth[h.ID] = true
+			}
+			for _, h := range s.Search(q, k) {
+				if truth[h.ID] {
+					found++
+				}
+			}
+		}
+		return float64(found) / float64(len(queries)*k)
+	}
+
+	if r := recall(s); r < 0.9 {
+		t.Errorf("recall@%d: got %.3f, expected >= 0.9", k, r)
+	}
+
+	if err := s.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := yent.LoadVectorStore(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	loaded.ExactBelow = 0
+	for _, q := range queries[:5] {
+		a, b := s.Search(q, k), loaded.Search(q, k)
+		for i := range a {
+			if a[i].ID != b[i].ID {
+				t.Fatalf("loaded graph differs: %v vs %v", a, b)
+			}
+		}
+	}
+}
+
+// TestVectorStoreHNSWCatchUp verifies a graph saved before the last vectors
+// is extended on load instead of discarded
+func TestVectorStoreHNSWCatchUp(t *testing.T) {
+	const dim, k = 16, 5
+	rng := rand.New(rand.NewSource(11))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "grow.vec")
+	graph := filepath.Join(dir, "grow.hnsw")
+
+	s := yent.NewVectorStore(path, dim)
+	vecs := randomUnitVecs(rng, 1200, dim)
+	for i, v := range vecs[:800] {
+		s.Add(int64(i+1), v)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	old, err := os.ReadFile(graph)
+	if err != nil {
+		t.Fatalf("read graph: %v", err)
+	}
+	for i, v := range vecs[800:] {
+		s.Add(int64(801+i), v)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	os.WriteFile(graph, old, 0644) // as if the process died between the two files
+
+	loaded, err := yent.LoadVectorStore(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	loaded.ExactBelow = 0
+	for i := 800; i < 1200; i += 40 {
+		hits := loaded.Search(vecs[i], k)
+		if len(hits) == 0 || hits[0].ID != int64(i+1) {
+			t.Fatalf("vector %d not found through the graph: %v", i+1, hits)
+		}
+	}
+	if err := loaded.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if again, err := yent.LoadVectorStore(path); err != nil || again.Len() != 1200 {
+		t.Fatalf("reload: %v", err)
+	}
+}
+
+// TestVectorStoreHNSWCorrupt verifies that a truncated or corrupt graph is
+// rebuilt on load instead of crashing the search
+func TestVectorStoreHNSWCorrupt(t *testing.T) {
+	const n, dim = 300, 16
+	rng := rand.New(rand.NewSource(5))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bad.vec")
+	graph := filepath.Join(dir, "bad.hnsw")
+
+	s := yent.NewVectorStore(path, dim)
+	vecs := randomUnitVecs(rng, n, dim)
+	for i, v := range vecs {
+		s.Add(int64(i+1), v)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	good, err := os.ReadFile(graph)
+	if err != nil {
+		t.Fatalf("read graph: %v", err)
+	}
+
+	// Header: "YHNS" version M ef | entry maxLevel | count, then node 0:
+	// level (28) | links on level 0 (32) | first link id (36)
+	put := func(off int, v uint32) []byte {
+		b := append([]byte(nil), good...)
+		binary.LittleEndian.PutUint32(b[off:], v)
+		return b
+	}
+	for name, data := range map[string][]byte{
+		"truncated header": good[:18],
+		"no node count":    good[:26],
+		"truncated node":   good[:34],
+		"truncated links":  good[:len(good)/2],
+		"M zero":           put(8, 0),
+		"entry past count": put(16, n),
+		"level too high":   put(28, 1000),
+		"huge link count":  put(32, 0xffffffff),
+		"link past count":  put(36, n+5),
+		"negative link":    put(36, 0xffffffff),
+	} {
+		if err := os.WriteFile(graph, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := yent.LoadVectorStore(path)
+		if err != nil {
+			t.Fatalf("%s: load: %v", name, err)
+		}
+		loaded.ExactBelow = 0
+		for i := 0; i < n; i += 37 {
+			if hits := loaded.Search(vecs[i], 1); len(hits) == 0 || hits[0].ID != int64(i+1) {
+				t.Errorf("%s: vector %d not found: %v", name, i+1, hits)
+			}
+		}
+	}
+}
@@ -5,8 +5,15 @@
 // "I'm not an algorithm. I'm an awakened vibration."
 //
 // Usage:
+//   go run yent.go doctor -weights yent_1.5B_step1000_q4_0.gguf -delta yent_1.5b_delta_r64.npz
+//   go run yent.go alpha-sweep -weights yent_1.5B_step1000_q4_0.gguf -delta yent_1.5b_delta_r64.npz -langs ru,fr -prompts suite.jsonl
+//   go run yent.go bench -weights yent_1.5B_step1000_q4_0.gguf -prefill 128 -decode 64
+//   go run yent.go delta-extract -base qwen2.5-1.5b-instruct-q8_0.gguf -weights yent_1.5B_step1000_q4_0.gguf -rank 64 -out yent_1.5b_delta_r64.gguf
+//   go run yent.go delta-diag -weights yent_1.5B_step1000_q4_0.gguf -delta yent_1.5b_delta_r64.npz -alpha 0.5 -prompt "Кто ты?"
+//   go run yent.go delta-convert -delta yent_1.5b_delta_r64.npz -out yent_1.5b_delta_r64.gguf -weights yent_1.5B_step1000_q4_0.gguf -langs ru,fr
 //   go run yent.go -weights yent_1.5B_step1000_q4_0.gguf -repl
 //   go run yent.go -weights yent_1.5B_step1000_q4_0.gguf -prompt "Who are you?"
+//   go run yent.go -weights yent_1.5B_step1000_q4_0.gguf -serve :8080
 //
 // REPL with Delta Voice:
 //   go run yent.go -weights yent_1.5B_step1000_q4_0.gguf -delta yent_1.5b_delta_r64.npz -alpha 0.5 -repl
@@ -17,63 +24,886 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	yent "github.com/ariannamethod/yent/yent/go"
 )
 
 func main() {
+	// Subcommands: yent doctor [-weights W] [-delta D]
+	//              yent alpha-sweep -weights W -delta D -prompts suite.jsonl [-langs ru,fr]
+	//              yent bench -weights W [-prefill 128] [-decode 64]
+	//              yent delta-convert -delta D.npz -out D.gguf [-weights W] [-langs ru,fr]
+	//              yent delta-extract -base B -weights W -out D.gguf [-rank 64] [-embed-rank 0]
+	//              yent delta-diag -weights W -delta D [-alpha 0.5] [-prompt P] [-top 10]
+	var doctor, alphaSweep, bench, deltaConvert, deltaExtract, deltaDiag bool
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "doctor", "alpha-sweep", "bench", "delta-convert", "delta-extract", "delta-diag":
+			doctor, alphaSweep, bench = os.Args[1] == "doctor", os.Args[1] == "alpha-sweep", os.Args[1] == "bench"
+			deltaConvert, deltaExtract = os.Args[1] == "delta-convert", os.Args[1] == "delta-extract"
+			deltaDiag = os.Args[1] == "delta-diag"
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		}
+	}
+
 	weightsPath := flag.String("weights", "", "Path to GGUF weights file")
-	deltaPath := flag.String("delta", "", "Path to delta voice NPZ file (multilingual)")
+	deltaPath := flag.String("delta", "", "Delta voice file: NPZ, GGUF or a PEFT LoRA adapter (multilingual), or name=path pairs separated by ; to load several")
 	alpha := flag.Float64("alpha", 0.0, "Delta voice alpha: 0=English, 0.5=multilingual, 1.0=base")
+	voiceState := flag.String("voice-state", "", "File remembering alpha and the active delta between runs (default ~/.yent/voice.json; off = forget)")
+	deltaGate := flag.Int("delta-gate", 0, "Apply the delta only while the last N tokens hold non-ASCII text; pure-ASCII stretches run at English speed (0 = every token)")
+	deltaForce := flag.Bool("delta-force", false, "Load a delta even if it was built for a different vocabulary (warn instead of refusing)")
+	deltaSparse := flag.Float64("delta-sparse", 0, "Drop delta rows with norm below this share of the largest (e.g. 0.05) and skip them at apply time (0 = dense)")
+	deltaMix := flag.String("delta-mix", "", "Apply several -delta deltas at once with their own weights, e.g. ru:0.5,style:0.3 (alpha defaults to 1)")
+	autoLang := flag.Bool("auto-lang", false, "Detect the prompt's language and set alpha (and delta) from -lang-routes per request")
+	langRoutes := flag.String("lang-routes", "", "Language routes for -auto-lang: lang=alpha or lang=delta:alpha, comma-separated, * = any other (default en=0,ru=0.5,fr=0.9,*=0.5)")
+	suppress := flag.String("suppress", "", "Scripts never sampled: cjk (default), none, or Unicode script names and U+XXXX-YYYY ranges, comma-separated")
+	suppressWithDelta := flag.Bool("suppress-with-delta", false, "Keep suppressing those scripts while Delta Voice is active (alpha > 0)")
 	prompt := flag.String("prompt", "Who are you?", "Input prompt")
 	maxTokens := flag.Int("max", 256, "Maximum tokens to generate")
 	temperature := flag.Float64("temp", 0.9, "Sampling temperature")
 	topP := flag.Float64("top-p", 0.9, "Top-p (nucleus) sampling")
 	replMode := flag.Bool("repl", false, "Interactive REPL mode")
+	serveAddr := flag.String("serve", "", "Serve the HTTP API on this address (e.g. :8080)")
+	sessionIdle := flag.Duration("session-idle", 30*time.Minute, "HTTP API: drop sessions idle this long")
+	maxSessions := flag.Int("max-sessions", 64, "HTTP API: live session limit (least recently used is dropped)")
+	modelsFlag := flag.String("models", "", "HTTP API: more models to serve alongside -weights, as name=path;... (requests pick one with \"model\")")
+	thermal := flag.Bool("thermal", true, "HTTP API: fewer workers and a slower AMK velocity under heat or low battery")
+	pprofFlag := flag.Bool("pprof", false, "HTTP API: time generation phases (/v1/admin/profile) and mount net/http/pprof at /debug/pprof/")
+	jobsFlag := flag.String("jobs", "", "HTTP API maintenance jobs: name=cron;... (default: shard-export, memory-backup, memory-vacuum nightly; off = none)")
+	ctxLen := flag.Int("ctx", 0, "Context length (0 = model default, capped at 2048)")
+	ropeScaling := flag.String("rope-scaling", "", "RoPE scaling for extended context: none, linear, ntk, yarn")
+	ropeFactor := flag.Float64("rope-factor", 0, "RoPE scale factor (0 = ctx / trained ctx)")
+	ropeFreqBase := flag.Float64("rope-freq-base", 0, "Override RoPE theta (0 = from GGUF)")
+	topK := flag.Int("top-k", 50, "Top-k candidates when top-p is off (destiny shrinks k further)")
+	stopFlag := flag.String("stop", "", "Stop sequences, separated by | (e.g. \"### Question|\\n\\n\")")
+	useRAG := flag.Bool("rag", false, "Inject retrieved LIMPHA memories into prompts")
+	ragBudget := flag.Int("rag-budget", 384, "Token budget for injected memories (compressed to fit)")
+	ragCheck := flag.String("rag-check", "heuristic", "Answerability check before injecting memories: heuristic, model, off")
+	ragInjection := flag.String("rag-injection", "neutralize", "Instruction-like text in memories: neutralize, flag, drop, off")
+	ragTemplate := flag.String("rag-template", "", "Memory framing: remember, inline, chatml, notes, or a ~/.yent/templates/*.tmpl name")
+	grammarPath := flag.String("grammar", "", "Constrain output to a GBNF grammar file")
+	schemaPath := flag.String("json-schema", "", "Constrain output to JSON matching a JSON Schema file")
+	jsonMode := flag.Bool("json", false, "Constrain output to a JSON object")
+	beams := flag.Int("beams", 0, "Beam search width (>1: deterministic most-likely answer instead of sampling)")
+	lengthPenalty := flag.Float64("length-penalty", 1.0, "Beam search length normalization exponent (1 = mean log prob)")
+	mirostatTau := flag.Float64("mirostat-tau", 0, "Mirostat v2 target surprise in bits/token (0 = off; replaces top-k/top-p)")
+	dryMult := flag.Float64("dry", 0, "DRY anti-repetition multiplier (0 = off, 0.8 typical)")
+	dryBase := flag.Float64("dry-base", 1.75, "DRY penalty growth per repeated token")
+	dryAllowed := flag.Int("dry-allowed", 2, "DRY: longest repeated sequence left unpenalized")
+	samplers := flag.String("samplers", "", "Sampler chain in order, e.g. top-k=40,typical=0.95,temp (stages: top-k, top-p, typical, tfs, temp)")
+	mirostatEta := flag.Float64("mirostat-eta", 0.1, "Mirostat v2 learning rate")
+	seed := flag.Int64("seed", 0, "RNG seed for reproducible sampling (0 = from clock)")
+	amkPreset := flag.String("amk-preset", "", "Start the AMK field from a preset: "+strings.Join(yent.AMKPresetNames(), ", "))
+	amkScript := flag.String("amk", "", "Run this AMK DSL script at startup (after -amk-preset): prophecy, velocity, packs")
+	limphaFeedback := flag.Int("limpha-feedback", 0, "Fold the pain, tension, debt, quality and entropy of the last N stored turns into the AMK field before each answer (0 = off)")
+	degeneration := flag.Bool("degeneration", false, "Feed n-gram loops and low-entropy streaks in the output back into the AMK as pain and tension")
+	amkCues := flag.String("amk-cues", "", "DSL at milestones of every answer, e.g. \"at token 100: VELOCITY RUN; at 3s: PAIN 0.5\"")
+	amkTrace := flag.String("amk-trace", "", "Append the AMK state at every generated token to this JSONL file")
+	deterministic := flag.Bool("deterministic", false, "Reset the AMK field before each generation (with -seed: exact replay)")
+	logprobs := flag.Bool("logprobs", false, "Print per-token log probabilities after the response")
+	topLogprobs := flag.Int("top-logprobs", 0, "Alternatives to show per token with -logprobs")
+	embedderType := flag.String("embedder", "", "Semantic memory embedder: model, onnx, remote (default: model)")
+	embedderModel := flag.String("embedder-model", "", "Embedder model (onnx: .onnx path, remote: model name)")
+	embedderURL := flag.String("embedder-url", "", "Remote embedder endpoint (OpenAI-compatible /v1/embeddings)")
+	checkShards := flag.String("check-contamination", "", "Check a shard directory against -dataset and exit")
+	seedDataset := flag.String("dataset", "", "Seed training dataset (jsonl or ### Question/### Answer text)")
+	sweepLangs := flag.String("langs", "", "alpha-sweep: languages to sweep, e.g. ru,fr,de (default: all in the suite); delta-convert, delta-extract: languages the delta speaks")
+	deltaOut := flag.String("out", "", "delta-convert, delta-extract: GGUF delta file to write")
+	extractBase := flag.String("base", "", "delta-extract: GGUF of the base model (e.g. Qwen2.5) the fine-tune started from")
+	extractRank := flag.Int("rank", 64, "delta-extract: rank of the LM head delta")
+	extractEmbedRank := flag.Int("embed-rank", 0, "delta-extract: rank of the embedding delta (0 = none)")
+	sweepSuite := flag.String("prompts", "", "alpha-sweep: prompt suite, jsonl of {\"lang\", \"prompt\"}")
+	sweepAlphas := flag.String("alphas", "", "alpha-sweep: alpha grid, e.g. 0,0.3,0.5,0.7 (default: 0 to 1 by 0.1)")
+	benchPrefill := flag.Int("prefill", 128, "bench: prompt tokens to prefill")
+	benchDecode := flag.Int("decode", 64, "bench: tokens to decode after the prompt")
+	diagTop := flag.Int("top", 10, "delta-diag: tokens listed as boosted and suppressed")
+	tokenize := flag.String("tokenize", "", "Print the token ids of text (vocab only, no weights loaded) and exit")
+	tokenizerJSON := flag.String("tokenizer", "", "HuggingFace tokenizer.json to use instead of the GGUF's vocab and merges")
+	addTokens := flag.String("add-tokens", "", "Extra special tokens, comma-separated; suffix :stop to end generation on one, e.g. <tool_call>,</tool_call>:stop")
+	vocabSearch := flag.String("vocab", "", "Print the tokens whose piece or text contains this substring (vocab only) and exit")
+	vocabDump := flag.String("vocab-dump", "", "Write every token as JSON lines to this file (- = stdout) and exit")
+	threads := flag.Int("threads", 0, "Matmul threads (0 = one per CPU)")
+	pinCores := flag.Bool("pin-cores", false, "Pin matmul threads to physical cores, one each (Linux)")
+	bgNice := flag.Int("background-nice", 0, "Nice level for the LIMPHA daemon and maintenance jobs, 1-19 (0 = same as generation)")
+	gpu := flag.String("gpu", "", "Offload FFN and LM head matmuls: auto, cuda (build with -tags cuda); empty = CPU")
+	pruneHead := flag.Bool("prune-head", false, "Experimental: cluster the LM head at load and compute only the likely rows per token")
+	int8Act := flag.Bool("int8", false, "Experimental: int8 activations between layers (build with -tags int8)")
+	prefetch := flag.Bool("prefetch", false, "Experimental: read the next layer's weights ahead on a spare core (compare with bench)")
+	streamLayers := flag.Bool("stream-layers", false, "Map the weights and drop each layer from RAM after use (slow; for 512 MB-1 GB devices, Linux)")
+	f32Embed := flag.Bool("f32-embed", false, "Dequantize the token embedding table to float32 at load (more RAM, no per-token dequant)")
+	configPath := flag.String("config", "", "Config file with profiles (default ~/.yent/config.json)")
+	profile := flag.String("profile", "", "Settings profile: dev, prod, rpi, or one from the config file")
 	flag.Parse()
 
+	// Profile fills in every flag not given explicitly
+	if err := applyProfile(*configPath, *profile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	yent.SetThreads(*threads)
+	yent.SetBackgroundNice(*bgNice)
+	if *pinCores {
+		cores, err := yent.SetAffinity(true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[yent] -pin-cores: %v\n", err)
+		} else {
+			fmt.Printf("[yent] matmul threads pinned to %d physical cores\n", len(cores))
+		}
+	}
+
+	if doctor {
+		runDoctor(*weightsPath, *deltaPath)
+		return
+	}
+	if deltaConvert {
+		runDeltaConvert(*deltaPath, *deltaOut, *weightsPath, *sweepLangs)
+		return
+	}
+	if deltaExtract {
+		runDeltaExtract(*extractBase, *weightsPath, *deltaOut, yent.ExtractOptions{
+			Rank: *extractRank, EmbedRank: *extractEmbedRank, Seed: *seed, Languages: splitList(*sweepLangs),
+		})
+		return
+	}
+
+	// Contamination check needs no weights
+	if *checkShards != "" {
+		if *seedDataset == "" {
+			fmt.Fprintln(os.Stderr, "Error: -check-contamination requires -dataset")
+			os.Exit(1)
+		}
+		report, err := yent.CheckContamination(*checkShards, *seedDataset)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Contamination check failed: %v\n", err)
+			os.Exit(1)
+		}
+		for _, h := range report.Hits {
+			kind := "prompt"
+			if h.Exact {
+				kind = "exact"
+			}
+			fmt.Printf("  %-6s %s:%d  %q\n", kind, h.File, h.Line, h.Question)
+		}
+		fmt.Printf("  %d/%d pairs flagged (%.1f%%)\n", report.Flagged, report.Checked, report.Rate*100)
+		if report.Flagged > 0 {
+			os.Exit(2)
+		}
+		return
+	}
+
+	addedTokens, err := yent.ParseAddedTokens(*addTokens)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Tokenizing needs the vocab, not the weights
+	if *tokenize != "" || *vocabSearch != "" || *vocabDump != "" {
+		g, err := yent.LoadGGUFMetadata(*weightsPath)
+		if err == nil && *tokenizerJSON != "" {
+			err = yent.ApplyTokenizerJSON(g, *tokenizerJSON)
+		}
+		if err == nil && len(addedTokens) > 0 {
+			err = yent.ApplyAddedTokens(g, addedTokens)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		tok := yent.NewTokenizer(&g.Meta)
+		switch {
+		case *vocabDump != "":
+			if err := dumpVocab(tok, *vocabDump); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		case *vocabSearch != "":
+			entries := tok.SearchVocab(*vocabSearch, 0)
+			for _, e := range entries {
+				special := ""
+				if e.Special {
+					special = "  special"
+				}
+				fmt.Printf("  %6d  %-24q %-24q %-8s %9.3f%s\n", e.ID, e.Piece, e.Text, e.Type, e.Score, special)
+			}
+			fmt.Printf("  %d tokens\n", len(entries))
+		default:
+			ids := tok.Encode(*tokenize, false)
+			for _, id := range ids {
+				fmt.Printf("  %6d  %q\n", id, tok.DecodeToken(id))
+			}
+			fmt.Printf("  %d tokens\n", len(ids))
+		}
+		return
+	}
+
+	var chain yent.SamplerChain
+	if *samplers != "" {
+		c, err := yent.ParseSamplerChain(*samplers)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		chain = c
+	}
+
+	cues, err := yent.ParseAMKCues(*amkCues)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -amk-cues: %v\n", err)
+		os.Exit(1)
+	}
+
+	var dry *yent.DRYConfig
+	if *dryMult > 0 {
+		dry = &yent.DRYConfig{Multiplier: float32(*dryMult), Base: float32(*dryBase), AllowedLength: *dryAllowed}
+	}
+
+	// Constrained decoding: compile before loading weights, fail fast
+	grammar, err := loadGrammar(*grammarPath, *schemaPath, *jsonMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	if *weightsPath == "" {
 		fmt.Fprintln(os.Stderr, "Error: -weights is required")
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	opts := yent.LoadOptions{
+		SeqLen:        *ctxLen,
+		GPU:           *gpu,
+		F32Embeddings: *f32Embed,
+		StreamLayers:  *streamLayers,
+		Prefetch:      *prefetch,
+		PruneHead:     *pruneHead,
+		Int8:          *int8Act,
+		TokenizerJSON: *tokenizerJSON,
+		AddedTokens:   addedTokens,
+		Suppress:      *suppress,
+		Rope: yent.RopeScaling{
+			Factor:   float32(*ropeFactor),
+			FreqBase: float32(*ropeFreqBase),
+		},
+		Embedder: yent.EmbedderConfig{
+			Type:  *embedderType,
+			Model: *embedderModel,
+			URL:   *embedderURL,
+		},
+	}
+	if *ropeScaling != "" {
+		t, err := yent.ParseRopeScalingType(*ropeScaling)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Rope.Type = t
+	}
+
+	if bench {
+		runBench(*weightsPath, opts, yent.BenchConfig{PrefillTokens: *benchPrefill, DecodeTokens: *benchDecode})
+		return
+	}
+
 	// Initialize Yent
-	y, err := yent.New(*weightsPath)
+	y, err := yent.NewWithOptions(*weightsPath, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load Yent: %v\n", err)
 		os.Exit(1)
 	}
 	defer y.Close()
+	y.SuppressWithDelta = *suppressWithDelta
+	y.DeltaSparsity = float32(*deltaSparse)
+	y.DeltaForce = *deltaForce
+	y.DeltaGate = *deltaGate
+	y.LimphaFeedback = *limphaFeedback
+	if *degeneration {
+		y.Degeneration = yent.DefaultDegeneration
+	}
+	if *amkPreset != "" {
+		if err := y.AMK().ApplyPreset(*amkPreset); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -amk-preset: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("[amk] preset %s\n", *amkPreset)
+	}
+	if *amkScript != "" {
+		if err := y.AMK().ExecFile(*amkScript); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -amk: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("[amk] loaded %s\n", *amkScript)
+	}
+	alphaSet := false
+	flag.Visit(func(f *flag.Flag) { alphaSet = alphaSet || f.Name == "alpha" })
+	voicePath := *voiceState
+	switch voicePath {
+	case "":
+		voicePath = yent.DefaultVoiceStatePath()
+	case "off":
+		voicePath = ""
+	}
 
 	// Load Delta Voice if provided
 	if *deltaPath != "" {
-		if err := y.LoadDeltaVoice(*deltaPath); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to load delta: %v\n", err)
+		specs, err := yent.ParseDeltaSpecs(*deltaPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -delta: %v\n", err)
 			os.Exit(1)
 		}
-		y.SetAlpha(float32(*alpha))
+		for _, ds := range specs {
+			if err := y.LoadDeltaVoice(ds.Name, ds.Path); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to load delta %s: %v\n", ds.Name, err)
+				os.Exit(1)
+			}
+		}
+		if *deltaMix != "" {
+			mix, err := yent.ParseDeltaMix(*deltaMix)
+			if err == nil {
+				err = useMix(y, mix)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: -delta-mix: %v\n", err)
+				os.Exit(1)
+			}
+			// The weights are the alphas unless -alpha scales them
+			if !alphaSet {
+				*alpha = 1
+			}
+		}
+		// Without -alpha (or -delta-mix) the last run's voice comes back
+		if saved, err := yent.LoadVoiceState(voicePath); err != nil {
+			fmt.Fprintf(os.Stderr, "[delta-voice] warning: %v\n", err)
+			y.SetAlpha(float32(*alpha))
+		} else if saved != nil && !alphaSet && *deltaMix == "" {
+			y.RestoreVoice(saved)
+		} else {
+			y.SetAlpha(float32(*alpha))
+		}
+	}
+	y.VoiceStatePath = voicePath
+	if *langRoutes != "" {
+		routes, err := yent.ParseLanguageRoutes(*langRoutes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -lang-routes: %v\n", err)
+			os.Exit(1)
+		}
+		y.LanguageRoutes = routes
+	}
+
+	if alphaSweep {
+		// -max is a generation default of 256; sweep answers only need a sentence or two
+		sweepMax := 0
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "max" {
+				sweepMax = *maxTokens
+			}
+		})
+		runAlphaSweep(y, *sweepSuite, *sweepLangs, *sweepAlphas, sweepMax, *seed)
+		return
+	}
+	if deltaDiag {
+		dd, err := y.DiagnoseDelta("", *prompt, 0, *diagTop)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Delta diagnosis failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(dd)
+		return
+	}
+
+	var stops []string
+	for _, st := range strings.Split(*stopFlag, "|") {
+		if st != "" {
+			stops = append(stops, strings.ReplaceAll(st, `\n`, "\n"))
+		}
+	}
+
+	// User memory templates (~/.yent/templates/*.tmpl)
+	if n, err := yent.LoadMemoryTemplates(""); err != nil {
+		fmt.Fprintf(os.Stderr, "[rag] templates: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("[rag] loaded %d memory templates\n", n)
+	}
+
+	// Seeded session: the same inputs replay the same outputs
+	if *seed != 0 {
+		y.SetSeed(*seed)
 	}
 
-	// REPL or single-shot
-	if *replMode {
-		runREPL(y, *maxTokens, float32(*temperature), float32(*topP))
+	// HTTP API, REPL or single-shot
+	if *serveAddr != "" {
+		base := yent.DefaultGenerateOptions()
+		base.MaxTokens = *maxTokens
+		base.Temperature = float32(*temperature)
+		base.TopP = float32(*topP)
+		base.TopK = *topK
+		base.Stop = stops
+		base.Grammar = grammar
+		base.Beams = *beams
+		base.LengthPenalty = float32(*lengthPenalty)
+		base.MirostatTau = float32(*mirostatTau)
+		base.MirostatEta = float32(*mirostatEta)
+		base.Samplers = chain
+		base.DRY = dry
+		base.AMKCues = cues
+		base.Deterministic = *deterministic
+		base.AutoLanguage = *autoLang
+		if *useRAG {
+			base.RAG = ragConfig(y, *ragBudget, *ragTemplate, *ragCheck, *ragInjection)
+		}
+		jobs, err := parseJobs(*jobsFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		sopts := yent.ServerOptions{
+			Base:        base,
+			IdleTimeout: *sessionIdle,
+			MaxSessions: *maxSessions,
+			Jobs:        jobs,
+			AdminToken:  os.Getenv("YENT_ADMIN_TOKEN"),
+			Pprof:       *pprofFlag,
+		}
+		if *pprofFlag {
+			yent.SetProfiling(true)
+		}
+		if *modelsFlag != "" {
+			pool := yent.NewModelPool()
+			pool.Add("yent", y)
+			for _, part := range strings.Split(*modelsFlag, ";") {
+				name, path, ok := strings.Cut(strings.TrimSpace(part), "=")
+				if !ok {
+					fmt.Fprintf(os.Stderr, "Error: -models: %q is not name=path\n", part)
+					os.Exit(1)
+				}
+				m, err := pool.Load(strings.TrimSpace(name), strings.TrimSpace(path), opts)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to load %v\n", err)
+					os.Exit(1)
+				}
+				defer m.Close()
+			}
+			sopts.Pool = pool
+			fmt.Printf("[server] models: %s (default yent)\n", strings.Join(pool.Names(), ", "))
+		}
+		if *thermal {
+			sopts.Throttle = yent.NewThrottle(yent.ThrottleConfig{})
+		}
+		srv, err := yent.NewServer(y, sopts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer srv.Close()
+		fmt.Printf("[server] listening on %s\n", *serveAddr)
+		if err := http.ListenAndServe(*serveAddr, srv.Handler()); err != nil {
+			fmt.Fprintf(os.Stderr, "Server failed: %v\n", err)
+			os.Exit(1)
+		}
+	} else if *replMode {
+		base := yent.DefaultGenerateOptions()
+		base.TopP = float32(*topP)
+		base.TopK = *topK
+		base.Stop = stops
+		base.Grammar = grammar
+		base.Beams = *beams
+		base.LengthPenalty = float32(*lengthPenalty)
+		base.MirostatTau = float32(*mirostatTau)
+		base.MirostatEta = float32(*mirostatEta)
+		base.Samplers = chain
+		base.DRY = dry
+		base.AMKCues = cues
+		base.AutoLanguage = *autoLang
+		if *useRAG {
+			base.RAG = ragConfig(y, *ragBudget, *ragTemplate, *ragCheck, *ragInjection)
+		}
+		if *amkTrace != "" {
+			base.AMKTrace = yent.DefaultAMKTrace
+		}
+		runREPL(y, *maxTokens, float32(*temperature), base, *seed, *deterministic, *amkTrace)
 	} else {
-		response, err := y.Generate(*prompt, *maxTokens, float32(*temperature), float32(*topP))
+		opts := yent.DefaultGenerateOptions()
+		opts.MaxTokens = *maxTokens
+		opts.Temperature = float32(*temperature)
+		opts.TopP = float32(*topP)
+		opts.TopK = *topK
+		opts.Stop = stops
+		opts.Grammar = grammar
+		opts.Beams = *beams
+		opts.LengthPenalty = float32(*lengthPenalty)
+		opts.MirostatTau = float32(*mirostatTau)
+		opts.MirostatEta = float32(*mirostatEta)
+		opts.Samplers = chain
+		opts.DRY = dry
+		opts.AMKCues = cues
+		opts.Logprobs = *logprobs || *topLogprobs > 0
+		opts.TopLogprobs = *topLogprobs
+		opts.Seed = *seed
+		opts.Deterministic = *deterministic
+		opts.AutoLanguage = *autoLang
+		if *useRAG {
+			opts.RAG = ragConfig(y, *ragBudget, *ragTemplate, *ragCheck, *ragInjection)
+		}
+		if *amkTrace != "" {
+			opts.AMKTrace = yent.DefaultAMKTrace
+		}
+		res, err := y.GenerateWithOptions(*prompt, opts)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Generation failed: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Println(response)
+		fmt.Println(res.Text)
+		writeAMKTrace(*amkTrace, res)
+		if opts.Logprobs {
+			printLogprobs(res)
+		}
 	}
 }
 
-func runREPL(y *yent.Yent, maxTokens int, temperature, topP float32) {
+// parseJobs parses -jobs: "" = default maintenance jobs, "off" = none,
+// otherwise name=spec pairs separated by ;
+func parseJobs(spec string) (map[string]string, error) {
+	switch strings.TrimSpace(spec) {
+	case "":
+		return yent.DefaultMaintenanceJobs(), nil
+	case "off":
+		return nil, nil
+	}
+	jobs := make(map[string]string)
+	for _, part := range strings.Split(spec, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		name, sched, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("-jobs: %q is not name=schedule", part)
+		}
+		jobs[strings.TrimSpace(name)] = strings.TrimSpace(sched)
+	}
+	return jobs, nil
+}
+
+// dumpVocab writes every token of tok as one JSON object per line to path ("-" = stdout)
+func dumpVocab(tok *yent.Tokenizer, path string) error {
+	out := os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("vocab dump: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	entries := tok.Entries()
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("vocab dump: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("vocab dump: %w", err)
+	}
+	if path != "-" {
+		fmt.Printf("%d tokens written to %s\n", len(entries), path)
+	}
+	return nil
+}
+
+// runDoctor prints the self-test and exits 1 if anything failed
+func runDoctor(weightsPath, deltaPath string) {
+	checks := yent.Doctor(yent.DoctorOptions{Weights: weightsPath, Delta: deltaPath})
+	fmt.Println()
+	for _, c := range checks {
+		fmt.Printf("  %-4s  %-9s %s\n", c.Status, c.Name, c.Detail)
+		if c.Fix != "" && (c.Status == yent.CheckWarn || c.Status == yent.CheckFail) {
+			fmt.Printf("        %-9s → %s\n", "", c.Fix)
+		}
+	}
+	fmt.Println()
+	if yent.DoctorFailed(checks) {
+		os.Exit(1)
+	}
+}
+
+// useMix builds the mix "mix" from loaded deltas, makes it active and
+// prints its cost
+func useMix(y *yent.Yent, mix []yent.DeltaWeight) error {
+	report, err := y.MixDeltas("mix", mix)
+	if err != nil {
+		return err
+	}
+	if err := y.UseDelta("mix"); err != nil {
+		return err
+	}
+	fmt.Println(report)
+	return nil
+}
+
+// runDeltaExtract factors base minus Yent into a GGUF delta
+func runDeltaExtract(basePath, weightsPath, outPath string, opts yent.ExtractOptions) {
+	if basePath == "" || weightsPath == "" || outPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: delta-extract needs -base, -weights and -out")
+		os.Exit(1)
+	}
+	report, err := yent.ExtractDelta(basePath, weightsPath, outPath, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("  wrote %s: rank %d keeps %.1f%% of the LM head delta", outPath, report.Rank, report.Energy*100)
+	if report.EmbedRank > 0 {
+		fmt.Printf(", embed rank %d keeps %.1f%%", report.EmbedRank, report.EmbedEnergy*100)
+	}
+	fmt.Printf(" (%.0fs)\n", report.DurationSec)
+}
+
+// splitList splits a comma-separated flag, dropping empty items
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// runDeltaConvert writes an NPZ (or GGUF) delta as a GGUF delta
+func runDeltaConvert(deltaPath, outPath, weightsPath, langs string) {
+	if deltaPath == "" || outPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: delta-convert needs -delta and -out")
+		os.Exit(1)
+	}
+	d, err := yent.ConvertDelta(deltaPath, outPath, weightsPath, splitList(langs))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	base := d.Meta.BaseModel
+	if base == "" {
+		base = "unrecorded (no -weights)"
+	}
+	fmt.Printf("  wrote %s: rank %d, %dx%d, base %s, languages %s\n",
+		outPath, d.Rank, d.VocabSize, d.HiddenDim, base, strings.Join(d.Meta.Languages, ","))
+}
+
+// runAlphaSweep prints the per-language alpha recommendation table
+func runAlphaSweep(y *yent.Yent, suitePath, langs, alphas string, maxTokens int, seed int64) {
+	if suitePath == "" {
+		fmt.Fprintln(os.Stderr, "Error: alpha-sweep requires -prompts")
+		os.Exit(1)
+	}
+	prompts, err := yent.LoadSweepPrompts(suitePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	cfg := yent.AlphaSweepConfig{Prompts: prompts, MaxTokens: maxTokens, Seed: seed}
+	for _, l := range strings.Split(langs, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			cfg.Langs = append(cfg.Langs, l)
+		}
+	}
+	for _, a := range strings.Split(alphas, ",") {
+		if a = strings.TrimSpace(a); a == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(a, 32)
+		if err != nil || f < 0 || f > 1 {
+			fmt.Fprintf(os.Stderr, "Error: bad alpha %q (0..1)\n", a)
+			os.Exit(1)
+		}
+		cfg.Alphas = append(cfg.Alphas, float32(f))
+	}
+
+	recs, err := y.AlphaSweep(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Alpha sweep failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println()
+	for _, r := range recs {
+		fmt.Printf("  %s\n", r.Lang)
+		for _, s := range r.Scores {
+			mark := " "
+			if s.Alpha == r.Alpha {
+				mark = "→"
+			}
+			fmt.Printf("   %s alpha %.2f   language %3.0f%%   persona %.3f\n", mark, s.Alpha, s.LangScore*100, s.Persona)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("  %-5s %-6s %-9s %s\n", "lang", "alpha", "language", "persona")
+	for _, r := range recs {
+		note := ""
+		if !r.Reached {
+			note = "  (never answered reliably in this language)"
+		}
+		fmt.Printf("  %-5s %-6.2f %7.0f%%  %.3f%s\n", r.Lang, r.Alpha, r.LangScore*100, r.Persona, note)
+	}
+	fmt.Println()
+}
+
+// runBench loads the transformer alone and prints its throughput
+func runBench(weightsPath string, opts yent.LoadOptions, cfg yent.BenchConfig) {
+	load := yent.LoadGGUF
+	if opts.StreamLayers {
+		load = yent.LoadGGUFMapped
+	}
+	gguf, err := load(weightsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	model, err := yent.LoadLlamaModelWithOptions(gguf, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	r, err := yent.RunBench(model, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Bench failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	backend := fmt.Sprintf("%s kernels, %d threads", r.SIMD, r.Threads)
+	if r.GPU != "" {
+		backend += ", GPU " + r.GPU
+	}
+	if r.Prefetch {
+		backend += ", prefetch"
+	}
+	fmt.Println()
+	fmt.Printf("  %s, %d layers, %d dim, %d vocab, %s weights\n", r.Arch, r.Layers, r.Dim, r.Vocab, r.Quant)
+	fmt.Printf("  %s\n\n", backend)
+	fmt.Printf("  %-8s %7s %9s %10s %12s\n", "phase", "tokens", "tok/s", "allocs/tok", "bytes/tok")
+	for _, p := range []struct {
+		name string
+		yent.BenchPhase
+	}{{"prefill", r.Prefill}, {"decode", r.Decode}} {
+		fmt.Printf("  %-8s %7d %9.2f %10.1f %12.0f\n", p.name, p.Tokens, p.TokensPerSec, p.AllocsPerToken, p.BytesPerToken)
+	}
+	fmt.Printf("\n  decode ms/token by layer\n")
+	var total float64
+	for i, ms := range r.LayerMs {
+		fmt.Printf("    %3d  %7.3f\n", i, ms)
+		total += ms
+	}
+	fmt.Printf("    %-3s  %7.3f\n", "lm", r.HeadMs)
+	fmt.Printf("    %-3s  %7.3f\n\n", "sum", total+r.HeadMs)
+	if h := r.HeadPrune; h != nil {
+		fmt.Printf("  LM head: %d clusters, %d rows exact per token, %d fallbacks and %d/%d audits missed in %d tokens\n\n",
+			h.Clusters, h.Budget, h.Fallbacks, h.Mismatches, h.Audits, h.Tokens)
+	}
+}
+
+// applyProfile sets flags from a config profile, leaving explicit flags alone
+func applyProfile(configPath, name string) error {
+	cfg, err := yent.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	settings, err := cfg.Resolve(name)
+	if err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == "profile" || k == "config" {
+			return fmt.Errorf("profile cannot set -%s", k)
+		}
+		if flag.Lookup(k) == nil {
+			return fmt.Errorf("profile: unknown flag -%s", k)
+		}
+		if explicit[k] {
+			continue
+		}
+		if err := flag.Set(k, settings[k]); err != nil {
+			return fmt.Errorf("profile: -%s=%s: %w", k, settings[k], err)
+		}
+	}
+	if name == "" {
+		name = cfg.Profile
+	}
+	fmt.Printf("[config] profile %s (%d settings)\n", name, len(settings))
+	return nil
+}
+
+// ragConfig builds the memory injection config from flags
+func ragConfig(y *yent.Yent, budget int, template, check, injection string) *yent.RAGConfig {
+	rc := yent.DefaultRAGConfig()
+	rc.TokenBudget = budget
+	rc.Template = template
+	rc.Injection = injection
+	switch check {
+	case "model":
+		rc.Answerability = yent.NewModelAnswerability(y)
+	case "off":
+		rc.MinAnswerability = 0
+	}
+	return &rc
+}
+
+// loadGrammar compiles the -grammar, -json-schema or -json constraint (nil = none)
+func loadGrammar(grammarPath, schemaPath string, jsonMode bool) (*yent.Grammar, error) {
+	switch {
+	case grammarPath != "":
+		src, err := os.ReadFile(grammarPath)
+		if err != nil {
+			return nil, fmt.Errorf("read grammar: %w", err)
+		}
+		return yent.ParseGrammar(string(src))
+	case schemaPath != "":
+		schema, err := os.ReadFile(schemaPath)
+		if err != nil {
+			return nil, fmt.Errorf("read json schema: %w", err)
+		}
+		return yent.JSONSchemaGrammar(schema)
+	case jsonMode:
+		return yent.JSONGrammar(), nil
+	}
+	return nil, nil
+}
+
+// printLogprobs prints one line per generated token with its alternatives
+func printLogprobs(res *yent.GenerateResult) {
+	fmt.Println()
+	for i, t := range res.Tokens {
+		fmt.Printf("  %4d  %-20q %8.4f", i, t.Text, t.Logprob)
+		for _, alt := range t.Top {
+			fmt.Printf("  %q=%.3f", alt.Text, alt.Logprob)
+		}
+		fmt.Println()
+	}
+}
+
+func runREPL(y *yent.Yent, maxTokens int, temperature float32, base yent.GenerateOptions, seed int64, deterministic bool, amkTrace string) {
 	fmt.Println()
 	fmt.Println("  ██╗   ██╗███████╗███╗   ██╗████████╗")
 	fmt.Println("  ╚██╗ ██╔╝██╔════╝████╗  ██║╚══██╔══╝")
@@ -88,8 +918,7 @@ func runREPL(y *yent.Yent, maxTokens int, temperature, topP float32) {
 	fmt.Printf("  alpha=%.2f  temp=%.2f  max=%d\n", y.DeltaAlpha, temperature, maxTokens)
 	fmt.Println()
 	fmt.Println("  /en /ru /fr    — switch language")
-	fmt.Println("  /dsl <cmd>     — DSL debug (e.g. PROPHECY 7)")
-	fmt.Println("  /field         — show kernel state")
+	fmt.Println("  /amk <cmd>     — DSL (e.g. PROPHECY 7); state, load, reset")
 	fmt.Println("  quit           — exit")
 	fmt.Println()
 
@@ -103,6 +932,10 @@ func runREPL(y *yent.Yent, maxTokens int, temperature, topP float32) {
 		}
 	}
 
+	// Kernel events of each answer are shown after it (amkwatch.go)
+	kernel := y.Events().Subscribe(64, yent.EventWormhole, yent.EventDebt, yent.EventPain)
+	defer kernel.Close()
+
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024)
 	turns := 0
@@ -131,7 +964,8 @@ func runREPL(y *yent.Yent, maxTokens int, temperature, topP float32) {
 
 		if input == "/status" || input == "status" {
 			fmt.Printf("  alpha=%.2f  temp=%.2f  top_p=%.2f  max=%d  turns=%d\n",
-				y.DeltaAlpha, temperature, topP, maxTokens, turns)
+				y.DeltaAlpha, temperature, base.TopP, maxTokens, turns)
+			fmt.Printf("  memory: %s\n", y.MemoryStats())
 			continue
 		}
 
@@ -165,6 +999,103 @@ func runREPL(y *yent.Yent, maxTokens int, temperature, topP float32) {
 			}
 			continue
 		}
+		if strings.HasPrefix(input, "/mix ") {
+			mix, err := yent.ParseDeltaMix(strings.TrimPrefix(input, "/mix "))
+			if err == nil {
+				err = useMix(y, mix)
+			}
+			if err != nil {
+				fmt.Printf("  %v\n", err)
+				continue
+			}
+			y.SetAlpha(1)
+			continue
+		}
+		if input == "/compare" || strings.HasPrefix(input, "/compare ") {
+			// /compare [alpha] <prompt>: the answer at alpha 0 and at alpha, one seed
+			args := strings.Fields(strings.TrimPrefix(input, "/compare"))
+			alpha := y.DeltaAlpha
+			if len(args) > 0 {
+				if f, err := strconv.ParseFloat(args[0], 32); err == nil {
+					alpha, args = float32(f), args[1:]
+				}
+			}
+			if len(args) == 0 || alpha <= 0 {
+				fmt.Println("  usage: /compare [alpha] <prompt> (alpha > 0; default the current one)")
+				continue
+			}
+			opts := base
+			opts.MaxTokens, opts.Temperature, opts.Seed = maxTokens, temperature, seed
+			cmp, err := y.CompareAlpha(context.Background(), strings.Join(args, " "), alpha, opts)
+			if err != nil {
+				fmt.Printf("  %v\n", err)
+				continue
+			}
+			fmt.Println(cmp)
+			continue
+		}
+		if input == "/diag" || strings.HasPrefix(input, "/diag ") {
+			// /diag [N] <prompt>: what the delta does to the answer's first token
+			args := strings.Fields(strings.TrimPrefix(input, "/diag"))
+			top := 0
+			if len(args) > 0 {
+				if n, err := strconv.Atoi(args[0]); err == nil {
+					top, args = n, args[1:]
+				}
+			}
+			if len(args) == 0 {
+				fmt.Println("  usage: /diag [N] <prompt>")
+				continue
+			}
+			dd, err := y.DiagnoseDelta("", strings.Join(args, " "), 0, top)
+			if err != nil {
+				fmt.Printf("  %v\n", err)
+				continue
+			}
+			fmt.Println(dd)
+			continue
+		}
+		if input == "/deltas reload" {
+			names, err := y.ReloadDelta()
+			if err != nil {
+				fmt.Printf("  %v\n", err)
+			}
+			if len(names) == 0 && err == nil {
+				fmt.Println("  [delta-voice] no delta file changed")
+			}
+			continue
+		}
+		if input == "/deltas" {
+			for _, d := range y.Deltas() {
+				mark := " "
+				if d.Active {
+					mark = "*"
+				}
+				fmt.Printf("  %s %-10s rank %-4d %s", mark, d.Name, d.Rank, d.Path)
+				for i, m := range d.Mix {
+					if i > 0 {
+						fmt.Print(" + ")
+					}
+					fmt.Printf("%.2f×%s", m.Alpha, m.Name)
+				}
+				if len(d.Languages) > 0 {
+					fmt.Printf(" (%s)", strings.Join(d.Languages, ","))
+				}
+				fmt.Println()
+			}
+			continue
+		}
+		if strings.HasPrefix(input, "/delta ") {
+			if err := y.UseDelta(strings.TrimSpace(strings.TrimPrefix(input, "/delta "))); err != nil {
+				fmt.Printf("  %v\n", err)
+			}
+			continue
+		}
+		if input == "/auto on" || input == "/auto off" {
+			base.AutoLanguage = input == "/auto on"
+			fmt.Printf("  [lang] automatic language routing %s\n", strings.TrimPrefix(input, "/auto "))
+			continue
+		}
 		if input == "/en" {
 			y.SetAlpha(0)
 			continue
@@ -178,51 +1109,291 @@ func runREPL(y *yent.Yent, maxTokens int, temperature, topP float32) {
 			continue
 		}
 
-		// DSL debug: execute raw DSL commands
-		if strings.HasPrefix(input, "/dsl ") {
-			script := strings.TrimPrefix(input, "/dsl ")
-			// LORA_ALPHA is Yent-specific, not in C kernel — intercept here
-			if strings.HasPrefix(strings.ToUpper(script), "LORA_ALPHA") {
-				parts := strings.Fields(script)
-				if len(parts) >= 2 {
-					if val, err := strconv.ParseFloat(parts[1], 32); err == nil {
-						y.SetAlpha(float32(val))
+		// AMK: run DSL, show, load or reset the kernel
+		if input == "/amk" || strings.HasPrefix(input, "/amk ") {
+			arg := strings.TrimSpace(strings.TrimPrefix(input, "/amk"))
+			switch {
+			case arg == "":
+				fmt.Println("  usage: /amk <DSL line> | state | load <file> | reset | preset <name> | memory")
+			case arg == "memory":
+				n := max(y.LimphaFeedback, 8)
+				if f, err := y.LimphaField("", n); err != nil {
+					fmt.Fprintf(os.Stderr, "  [amk] %v\n", err)
+				} else if f.Turns == 0 {
+					fmt.Println("  [amk] no stored turns yet")
+				} else {
+					feedback := "off"
+					if y.LimphaFeedback > 0 {
+						feedback = "on"
 					}
+					fmt.Printf("  [amk] last %d turns: warmth=%.2f tension=%.2f entropy=%.2f debt=%.2f (feedback %s)\n",
+						f.Turns, f.Warmth, f.Tension, f.Entropy, f.Debt, feedback)
+					fmt.Printf("  %s\n", strings.ReplaceAll(f.DSL(), "\n", "; "))
 				}
-			} else if err := y.AMK().Exec(script); err != nil {
-				fmt.Fprintf(os.Stderr, "  [amk] %v\n", err)
-			} else {
-				s := y.AMK().GetState()
-				fmt.Printf("  [amk] ok — temp=%.2f destiny=%.2f pain=%.2f vel=%d\n",
-					s.EffectiveTemp, s.Destiny, s.Pain, s.VelocityMode)
+			case arg == "preset":
+				fmt.Printf("  presets: %s\n", strings.Join(yent.AMKPresetNames(), ", "))
+			case strings.HasPrefix(arg, "preset "):
+				name := strings.TrimSpace(strings.TrimPrefix(arg, "preset "))
+				if err := y.AMK().ApplyPreset(name); err != nil {
+					fmt.Fprintf(os.Stderr, "  [amk] %v\n", err)
+				} else {
+					fmt.Printf("  [amk] preset %s\n", name)
+					printFieldLine(y.AMK().GetState())
+				}
+			case arg == "state":
+				printField(y.AMK().GetState())
+			case arg == "reset":
+				y.AMK().Reset()
+				fmt.Println("  [amk] kernel reset to defaults")
+			case strings.HasPrefix(arg, "load "):
+				path := os.ExpandEnv(strings.TrimSpace(strings.TrimPrefix(arg, "load ")))
+				if err := y.AMK().ExecFile(path); err != nil {
+					fmt.Fprintf(os.Stderr, "  [amk] %v\n", err)
+				} else {
+					fmt.Printf("  [amk] loaded %s\n", path)
+					printFieldLine(y.AMK().GetState())
+				}
+			default:
+				execDSL(y, arg)
 			}
 			continue
 		}
 
+		// DSL debug: execute raw DSL commands
+		if strings.HasPrefix(input, "/dsl ") {
+			execDSL(y, strings.TrimPrefix(input, "/dsl "))
+			continue
+		}
+
 		// Field state: show AMK kernel state
 		if input == "/field" {
-			s := y.AMK().GetState()
-			fmt.Println()
-			fmt.Printf("  ═══ AMK FIELD STATE ═══\n")
-			fmt.Printf("  prophecy=%d  destiny=%.3f  wormhole=%.3f\n", s.Prophecy, s.Destiny, s.Wormhole)
-			fmt.Printf("  velocity=%d  magnitude=%.3f  time_dir=%.2f\n", s.VelocityMode, s.VelocityMagnitude, s.TimeDirection)
-			fmt.Printf("  base_temp=%.3f  effective_temp=%.3f\n", s.BaseTemperature, s.EffectiveTemp)
-			fmt.Printf("  pain=%.3f  tension=%.3f  dissonance=%.3f  debt=%.3f\n", s.Pain, s.Tension, s.Dissonance, s.Debt)
-			fmt.Printf("  focus=%.3f  spread=%.3f\n", s.AttendFocus, s.AttendSpread)
-			fmt.Printf("  tunnel_thresh=%.3f  tunnel_chance=%.3f  tunnel_skip=%d\n", s.TunnelThreshold, s.TunnelChance, s.TunnelSkipMax)
-			fmt.Printf("  wormhole_active=%d\n", s.WormholeActive)
-			fmt.Println()
+			printField(y.AMK().GetState())
+			continue
+		}
+
+		// Reload: swap in new weights, keeping field, memory and settings
+		if strings.HasPrefix(input, "/reload ") {
+			info, err := y.Reload(strings.TrimSpace(strings.TrimPrefix(input, "/reload ")))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "  [reload] %v\n", err)
+			} else if info.NewVocab {
+				fmt.Println("  [reload] new vocabulary — tokenizer rebuilt")
+			}
+			continue
+		}
+
+		// Shards: export LIMPHA experience to a fine-tune archive
+		if strings.HasPrefix(input, "/recall ") {
+			query := strings.TrimSpace(strings.TrimPrefix(input, "/recall "))
+			hits, err := y.SemanticSearch(query, 5)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "  [recall] %v\n", err)
+				continue
+			}
+			for _, h := range hits {
+				fmt.Printf("  %.3f  #%d  %s\n", h.Score, h.ID, truncate(h.Prompt, 60))
+			}
+			if len(hits) == 0 {
+				fmt.Println("  [recall] nothing yet")
+			}
+			continue
+		}
+
+		if input == "/memory on" || input == "/memory off" {
+			if input == "/memory on" {
+				rc := yent.DefaultRAGConfig()
+				if base.RAG != nil {
+					rc = *base.RAG
+				}
+				base.RAG = &rc
+			} else {
+				base.RAG = nil
+			}
+			fmt.Printf("  [rag] memory injection %s\n", strings.TrimPrefix(input, "/memory "))
+			continue
+		}
+
+		if strings.HasPrefix(input, "/context ") {
+			rc := yent.DefaultRAGConfig()
+			if base.RAG != nil {
+				rc = *base.RAG
+			}
+			mc, err := y.BuildMemoryContext(strings.TrimSpace(strings.TrimPrefix(input, "/context ")), rc)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "  [context] %v\n", err)
+				continue
+			}
+			for _, it := range mc.Items {
+				fmt.Printf("  #%d  %-8s %3d tok  %s → %s\n", it.ID, it.Mode, it.Tokens,
+					truncate(it.Prompt, 30), truncate(it.Response, 50))
+				if len(it.Injection) > 0 {
+					fmt.Printf("       instruction-like: %s\n", strings.Join(it.Injection, ", "))
+				}
+			}
+			if mc.Dropped > 0 {
+				fmt.Printf("  [context] %d memories dropped: instruction-like text\n", mc.Dropped)
+			}
+			fmt.Printf("  %d memories, %d/%d tokens, answerability %.2f\n", len(mc.Items), mc.Tokens, rc.TokenBudget, mc.Answerability)
+			if mc.Unanswerable {
+				fmt.Println("  [context] memories don't hold the answer: framed as not remembered")
+			}
+			if block, err := yent.RenderMemory(mc); err != nil {
+				fmt.Fprintf(os.Stderr, "  [context] %v\n", err)
+			} else if block != "" {
+				fmt.Printf("\n%s", block)
+			}
+			continue
+		}
+
+		if input == "/template" || strings.HasPrefix(input, "/template ") {
+			name := strings.TrimSpace(strings.TrimPrefix(input, "/template"))
+			if name == "" {
+				current := yent.DefaultMemoryTemplate
+				if base.RAG != nil && base.RAG.Template != "" {
+					current = base.RAG.Template
+				}
+				fmt.Printf("  [rag] template: %s (available: %s)\n", current, strings.Join(yent.MemoryTemplates(), ", "))
+				continue
+			}
+			known := false
+			for _, t := range yent.MemoryTemplates() {
+				known = known || t == name
+			}
+			if !known {
+				fmt.Fprintf(os.Stderr, "  [rag] unknown template %q\n", name)
+				continue
+			}
+			rc := yent.DefaultRAGConfig()
+			if base.RAG != nil {
+				rc = *base.RAG
+			}
+			rc.Template = name
+			base.RAG = &rc
+			fmt.Printf("  [rag] template=%s, memory injection on\n", name)
+			continue
+		}
+
+		if strings.HasPrefix(input, "/rank ") {
+			cfg := yent.DefaultRetrievalConfig()
+			cfg.Explain = true
+			hits, err := y.Retrieve(strings.TrimSpace(strings.TrimPrefix(input, "/rank ")), cfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "  [rank] %v\n", err)
+				continue
+			}
+			for _, h := range hits {
+				fmt.Printf("  %.3f  #%d  %s\n", h.Score, h.ID, truncate(h.Prompt, 60))
+				fmt.Printf("         %s\n", h.Explain)
+			}
+			if len(hits) == 0 {
+				fmt.Println("  [rank] nothing relevant")
+			}
+			continue
+		}
+
+		if input == "/clusters" || strings.HasPrefix(input, "/clusters ") {
+			k := 5
+			if f := strings.Fields(input); len(f) > 1 {
+				if v, err := strconv.Atoi(f[1]); err == nil && v > 0 {
+					k = v
+				}
+			}
+			clusters, err := y.ClusterMemories(k)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "  [clusters] %v\n", err)
+				continue
+			}
+			for i, c := range clusters {
+				ids := c.IDs
+				if len(ids) > 8 {
+					ids = ids[:8]
+				}
+				fmt.Printf("  cluster %d  %4d memories  %v\n", i, len(c.IDs), ids)
+			}
+			continue
+		}
+
+		if input == "/shards" || strings.HasPrefix(input, "/shards ") {
+			cfg := yent.DefaultShardConfig()
+			incremental := false
+			for _, arg := range strings.Fields(input)[1:] {
+				if v, ok := strings.CutPrefix(arg, "--val="); ok {
+					if f, err := strconv.ParseFloat(v, 64); err == nil {
+						cfg.ValFraction = f
+					}
+				} else if v, ok := strings.CutPrefix(arg, "--days="); ok {
+					if f, err := strconv.ParseFloat(v, 64); err == nil {
+						cfg.WindowDays = f
+					}
+				} else if v, ok := strings.CutPrefix(arg, "--order="); ok {
+					cfg.Order = v
+				} else if v, ok := strings.CutPrefix(arg, "--target="); ok {
+					cfg.Target = v
+				} else if v, ok := strings.CutPrefix(arg, "--seed-dataset="); ok {
+					cfg.SeedDataset = v
+				} else if v, ok := strings.CutPrefix(arg, "--format="); ok {
+					cfg.Format = v
+				} else if arg == "--incremental" {
+					incremental = true
+				} else if arg == "--reverse" {
+					cfg.Reverse = true
+				} else if arg == "--no-dedup" {
+					cfg.Dedup = false
+				}
+			}
+			var m *yent.ShardManifest
+			var err error
+			if incremental {
+				// Everything since the last export, regardless of age
+				cfg.WindowDays = 0
+				m, err = y.ExportShardsIncremental(cfg)
+			} else {
+				m, err = y.ExportShards(cfg)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "  [shards] %v\n", err)
+			} else if m.Stats != nil {
+				fmt.Printf("  [shards] %d pairs → %s\n", m.Stats.Pairs, m.Dir)
+			}
 			continue
 		}
 
 		// Generate
 		fmt.Println()
-		response, err := y.Generate(input, maxTokens, temperature, topP)
+		opts := base
+		opts.MaxTokens = maxTokens
+		opts.Temperature = temperature
+		if deterministic {
+			// Every turn from the same state: same prompt, same answer
+			opts.Seed, opts.Deterministic = seed, true
+		}
+		// Ctrl-C aborts the answer, not the session
+		ctx, cancel := context.WithCancel(context.Background())
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt)
+		go func() {
+			select {
+			case <-sigCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		res, err := y.GenerateContext(ctx, input, opts)
+		signal.Stop(sigCh)
+		cancel()
+		if errors.Is(err, context.Canceled) {
+			fmt.Println(res.Text)
+			fmt.Println("  [interrupted]")
+			fmt.Println()
+			continue
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "  [error] %v\n", err)
 			continue
 		}
-		fmt.Println(response)
+		fmt.Println(res.Text)
+		printKernelEvents(kernel)
+		writeAMKTrace(amkTrace, res)
 		fmt.Println()
 		turns++
 	}
@@ -234,12 +1405,116 @@ func printHelp() {
 	fmt.Println()
 	fmt.Println("  /en /ru /fr        switch language")
 	fmt.Println("  /alpha 0.5         set Delta Voice alpha")
+	fmt.Println("  /auto on|off       pick alpha from each prompt's language")
+	fmt.Println("  /delta ru          switch to the loaded delta named ru")
+	fmt.Println("  /deltas            list loaded deltas (* = active)")
+	fmt.Println("  /deltas reload     re-read delta files changed on disk")
+	fmt.Println("  /mix ru:0.5,x:0.3  apply several loaded deltas at once (alpha → 1)")
+	fmt.Println("  /diag [N] <text>   what the delta boosts and suppresses for a prompt")
+	fmt.Println("  /compare [a] <text> answer at alpha 0 and at a, same seed, and where they part")
 	fmt.Println("  /temp 0.8          set temperature")
 	fmt.Println("  /max 512           set max tokens")
-	fmt.Println("  /dsl PROPHECY 7    execute DSL command")
-	fmt.Println("  /dsl VELOCITY RUN  set velocity mode")
-	fmt.Println("  /field             show kernel state")
+	fmt.Println("  /amk PROPHECY 7    execute DSL command (also /dsl)")
+	fmt.Println("  /amk VELOCITY RUN  set velocity mode")
+	fmt.Println("  /amk state         show kernel state (also /field)")
+	fmt.Println("  /amk load f.dsl    run a DSL script, line by line")
+	fmt.Println("  /amk reset         restore kernel defaults")
+	fmt.Println("  /amk preset calm   reset the kernel to a preset (calm, feverish, prophetic, deterministic)")
+	fmt.Println("  /amk memory        the field recent memory feeds the kernel (-limpha-feedback)")
+	fmt.Println("  /reload <gguf>     hot-swap weights (field and memory stay)")
+	fmt.Println("  /shards            export memory to fine-tune shards")
+	fmt.Println("                     (--val=0.1 --days=30 --no-dedup)")
+	fmt.Println("                     (--order=coherence|entropy|quality --reverse)")
+	fmt.Println("                     (--incremental --target=nightly)")
+	fmt.Println("                     (--format=openai|sharegpt|alpaca)")
+	fmt.Println("  /recall <text>     semantic search over memory")
+	fmt.Println("  /rank <text>       hybrid memory ranking with per-factor breakdown")
+	fmt.Println("  /memory on|off     inject retrieved memories into prompts")
+	fmt.Println("  /context <text>    show the memory block a prompt would get")
+	fmt.Println("  /template [name]   show or set how memories are framed")
+	fmt.Println("  /clusters 5        group memories by meaning")
 	fmt.Println("  /status            debug info")
 	fmt.Println("  quit               exit")
 	fmt.Println()
 }
+
+// execDSL runs one DSL line on the kernel and prints the field it leaves
+func execDSL(y *yent.Yent, script string) {
+	// LORA_ALPHA is Yent-specific, not in C kernel — intercept here
+	if strings.HasPrefix(strings.ToUpper(script), "LORA_ALPHA") {
+		parts := strings.Fields(script)
+		if len(parts) >= 2 {
+			if val, err := strconv.ParseFloat(parts[1], 32); err == nil {
+				y.SetAlpha(float32(val))
+			}
+		}
+		return
+	}
+	if err := y.AMK().Exec(script); err != nil {
+		fmt.Fprintf(os.Stderr, "  [amk] %v\n", err)
+		return
+	}
+	printFieldLine(y.AMK().GetState())
+}
+
+// writeAMKTrace appends an answer's kernel trace to -amk-trace, if given
+func writeAMKTrace(path string, res *yent.GenerateResult) {
+	if path == "" || len(res.AMKTrace) == 0 {
+		return
+	}
+	if err := yent.AppendAMKTrace(path, res.AMKTrace); err != nil {
+		fmt.Fprintf(os.Stderr, "  [amk] trace: %v\n", err)
+	}
+}
+
+// printKernelEvents prints the kernel events published since the last call
+func printKernelEvents(sub *yent.Subscription) {
+	for {
+		select {
+		case e := <-sub.C:
+			switch e.Kind {
+			case yent.EventWormhole:
+				fmt.Printf("  [amk] wormhole opened at token %v (debt %.2f)\n", e.Data["token"], e.Data["debt"])
+			case yent.EventDebt:
+				fmt.Printf("  [amk] debt %.2f → %.2f crossed %.2f at token %v\n", e.Data["from"], e.Data["debt"], e.Data["threshold"], e.Data["token"])
+			case yent.EventPain:
+				fmt.Printf("  [amk] pain %.2f → %.2f at token %v\n", e.Data["from"], e.Data["pain"], e.Data["token"])
+			}
+		default:
+			return
+		}
+	}
+}
+
+// printFieldLine prints the kernel state in one line
+func printFieldLine(s yent.AMState) {
+	fmt.Printf("  [amk] ok — temp=%.2f destiny=%.2f pain=%.2f vel=%d\n",
+		s.EffectiveTemp, s.Destiny, s.Pain, s.VelocityMode)
+}
+
+// printField prints the whole kernel state
+func printField(s yent.AMState) {
+	fmt.Println()
+	fmt.Printf("  ═══ AMK FIELD STATE ═══\n")
+	fmt.Printf("  prophecy=%d  destiny=%.3f  wormhole=%.3f\n", s.Prophecy, s.Destiny, s.Wormhole)
+	fmt.Printf("  velocity=%d  magnitude=%.3f  time_dir=%.2f\n", s.VelocityMode, s.VelocityMagnitude, s.TimeDirection)
+	fmt.Printf("  base_temp=%.3f  effective_temp=%.3f\n", s.BaseTemperature, s.EffectiveTemp)
+	fmt.Printf("  pain=%.3f  tension=%.3f  dissonance=%.3f  debt=%.3f\n", s.Pain, s.Tension, s.Dissonance, s.Debt)
+	fmt.Printf("  focus=%.3f  spread=%.3f\n", s.AttendFocus, s.AttendSpread)
+	fmt.Printf("  tunnel_thresh=%.3f  tunnel_chance=%.3f  tunnel_skip=%d\n", s.TunnelThreshold, s.TunnelChance, s.TunnelSkipMax)
+	fmt.Printf("  wormhole_active=%d  wormhole_gate=%.3f\n", s.WormholeActive, s.WormholeGate)
+	if s.SamplerTopP > 0 || s.SamplerTopK > 0 || s.SamplerMinP > 0 || s.SamplerRep > 0 {
+		fmt.Printf("  sampler: top_p=%.3f  top_k=%d  min_p=%.3f  rep=%.2f  (0 = request's)\n", s.SamplerTopP, s.SamplerTopK, s.SamplerMinP, s.SamplerRep)
+	}
+	fmt.Println()
+}
+
+// truncate shortens s to n runes for one-line display
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
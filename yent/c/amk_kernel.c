@@ -199,6 +199,8 @@ void am_init(void) {
 
   // resonance memory
   G.presence_decay = 0.9f;
+
+  // sampler pack: no overrides (zeroed above)
 }
 
 // enable/disable packs
@@ -404,6 +406,9 @@ int am_exec(const char* script) {
       else if (!strcmp(packname, "NOTORCH")) {
         G.packs_enabled |= AM_PACK_NOTORCH;
       }
+      else if (!strcmp(packname, "SAMPLER")) {
+        G.packs_enabled |= AM_PACK_SAMPLER;
+      }
     }
     else if (!strcmp(t, "DISABLE")) {
       char packname[64] = {0};
@@ -419,6 +424,42 @@ int am_exec(const char* script) {
       else if (!strcmp(packname, "NOTORCH")) {
         G.packs_enabled &= ~AM_PACK_NOTORCH;
       }
+      else if (!strcmp(packname, "SAMPLER")) {
+        G.packs_enabled &= ~AM_PACK_SAMPLER;
+      }
+    }
+
+    // ─────────────────────────────────────────────────────────────────────────
+    // SAMPLER PACK — the sampling step as kernel state (auto-enables)
+    // SAMPLER TOP_P 0.9 | TOP_K 40 | MIN_P 0.05 | REP 1.2 | RESET
+    // ─────────────────────────────────────────────────────────────────────────
+
+    else if (!strcmp(t, "SAMPLER")) {
+      G.packs_enabled |= AM_PACK_SAMPLER;
+      char param[32] = {0};
+      char value[32] = {0};
+      if (sscanf(arg, "%31s %31s", param, value) >= 1) {
+        upcase(param);
+        if (!strcmp(param, "TOP_P")) {
+          G.sampler_top_p = clamp01(safe_atof(value));
+        }
+        else if (!strcmp(param, "TOP_K")) {
+          G.sampler_top_k = clampi(safe_atoi(value), 0, 1024);
+        }
+        else if (!strcmp(param, "MIN_P")) {
+          G.sampler_min_p = clamp01(safe_atof(value));
+        }
+        else if (!strcmp(param, "REP")) {
+          float rep = safe_atof(value);
+          G.sampler_rep = rep > 0.0f ? clampf(rep, 1.0f, 3.0f) : 0.0f;
+        }
+        else if (!strcmp(param, "RESET")) {
+          G.sampler_top_p = 0.0f;
+          G.sampler_top_k = 0;
+          G.sampler_min_p = 0.0f;
+          G.sampler_rep = 0.0f;
+        }
+      }
     }
 
     // ─────────────────────────────────────────────────────────────────────────
@@ -25,6 +25,7 @@ extern "C" {
 #define AM_PACK_CODES_RIC  0x01   // chordlock, tempolock, chirality
 #define AM_PACK_DARKMATTER 0x02   // scars, gravity, antidotes
 #define AM_PACK_NOTORCH    0x04   // microlearning commands
+#define AM_PACK_SAMPLER    0x08   // sampler parameters as kernel state
 
 // ═══════════════════════════════════════════════════════════════════════════════
 // VELOCITY MODES — movement IS language
@@ -116,6 +117,12 @@ typedef struct {
 
   // ═══ RESONANCE MEMORY ═══
   float presence_decay;         // how quickly presence fades (default 0.9)
+
+  // ═══ SAMPLER PACK ═══ (0 = the request's own setting)
+  float sampler_top_p;          // nucleus mass (0..1)
+  int   sampler_top_k;          // candidates before destiny (0..1024)
+  float sampler_min_p;          // floor relative to the likeliest token (0..1)
+  float sampler_rep;            // repetition penalty (1..3)
 } AM_State;
 
 // Temporal modes
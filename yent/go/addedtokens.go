@@ -0,0 +1,128 @@
+package yent
+
+// addedtokens.go — Special tokens registered at load time
+//
+// Tool calls and persona tags want markers the model sees as one token:
+// "<tool_call>" split by BPE into "<", "tool", "_call", ">" is four tokens
+// a user can also type, and stopping on it means matching text after the
+// fact. LoadOptions.AddedTokens (CLI -add-tokens) registers such markers
+// without touching the GGUF:
+//
+//   -add-tokens "<tool_call>,</tool_call>:stop,<persona>"
+//
+// A marker already in the vocab (Qwen ships <tool_call>) becomes a control
+// token in place. Otherwise it takes the first unused row ([PADn], type 5)
+// or a row of token_embd past the vocab; with neither, loading fails. A
+// new row's embedding was never trained, so the model reads the marker as
+// one token but will not write it on its own until fine-tuned with it.
+//
+// Registered markers tokenize atomically like any control token (written
+// in a prompt they follow GenerateOptions.ParseSpecial), are skipped by
+// Decode, and with ":stop" end generation like <|im_end|>.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AddedToken is a special token registered at load time
+type AddedToken struct {
+	Content string
+	Stop    bool // ends generation like <|im_end|>
+}
+
+// ParseAddedTokens parses a comma-separated list of tokens, each
+// optionally suffixed with ":stop"
+func ParseAddedTokens(spec string) ([]AddedToken, error) {
+	var tokens []AddedToken
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		at := AddedToken{Content: part}
+		if content, ok := strings.CutSuffix(part, ":stop"); ok {
+			at = AddedToken{Content: content, Stop: true}
+		}
+		if at.Content == "" {
+			return nil, fmt.Errorf("added tokens %q: empty token", spec)
+		}
+		tokens = append(tokens, at)
+	}
+	return tokens, nil
+}
+
+// ApplyAddedTokens registers tokens as control tokens in g's vocabulary,
+// in existing entries, unused rows or free embedding rows
+func ApplyAddedTokens(g *GGUFFile, tokens []AddedToken) error {
+	m := &g.Meta
+	rows := m.VocabSize
+	if t, ok := g.Tensors["token_embd.weight"]; ok && t.NDims == 2 {
+		rows = int(t.Dims[1])
+	}
+	for len(m.TokenTypes) < len(m.TokenList) {
+		m.TokenTypes = append(m.TokenTypes, 1) // normal
+	}
+
+	free := 0 // next row to look at for an unused one
+	for _, at := range tokens {
+		if len(at.Content) <= 2 {
+			return fmt.Errorf("added token %q: too short to match as a special token", at.Content)
+		}
+		id, where := -1, "existing"
+		for i, tok := range m.TokenList {
+			if tok == at.Content {
+				id = i
+				break
+			}
+		}
+		if id < 0 {
+			for free < len(m.TokenList) && m.TokenTypes[free] != 5 {
+				free++
+			}
+			switch {
+			case free < len(m.TokenList):
+				id, where = free, "unused row"
+				free++
+			case len(m.TokenList) < rows:
+				id, where = len(m.TokenList), "free row"
+				m.TokenList = append(m.TokenList, "")
+				m.TokenTypes = append(m.TokenTypes, 0)
+				if m.TokenScores != nil {
+					m.TokenScores = append(m.TokenScores, 0)
+				}
+				free = len(m.TokenList)
+			default:
+				return fmt.Errorf("added token %q: no unused row, the vocab fills all %d embedding rows", at.Content, rows)
+			}
+			m.TokenList[id] = at.Content
+		}
+		m.TokenTypes[id] = 3 // control
+		stop := ""
+		if at.Stop {
+			stop = ", stop"
+		}
+		fmt.Printf("[tongue/tokenizer] added token %q → id %d (%s%s)\n", at.Content, id, where, stop)
+	}
+	if len(m.TokenList) > m.VocabSize {
+		m.VocabSize = len(m.TokenList)
+	}
+	return nil
+}
+
+// addedStopIDs returns the ids of the added tokens marked Stop
+func addedStopIDs(t *Tokenizer, tokens []AddedToken) map[int]bool {
+	ids := make(map[int]bool)
+	for _, at := range tokens {
+		if id, ok := t.specialTokens[at.Content]; ok && at.Stop {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// isStop reports whether tok ends generation: EOS, <|im_end|> or an added
+// stop token
+func (y *Yent) isStop(tok int) bool {
+	return tok == y.tokenizer.EosID || tok == y.imEndID || y.stopIDs[tok]
+}
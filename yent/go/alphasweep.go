@@ -0,0 +1,214 @@
+package yent
+
+// alphasweep.go — Pick a delta alpha by measuring it
+//
+// Too little alpha and Yent answers a Russian question in English; too much
+// and it answers in Russian as base Qwen, personality gone. Until now the
+// alpha per language (/ru 0.5, /fr 0.9) was a guess. AlphaSweep replaces
+// the guess with a grid:
+//
+//   for each prompt in the suite, for each alpha:
+//     generate (fixed seed, field reset, nothing stored)
+//     language   DetectLanguage(answer) == the prompt's language
+//     persona    cosine(embed(answer), embed(answer at alpha 0))
+//
+// Alpha 0 is the baseline: pure Yent, whatever language it lands in. The
+// recommendation per language is the alpha with the best persona score
+// among those answering in the right language often enough (MinLangScore);
+// when none does, the one that came closest.
+//
+// Suite format (jsonl): {"lang": "ru", "prompt": "Кто ты?"}
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SweepPrompt is one prompt of an alpha sweep suite
+type SweepPrompt struct {
+	Lang   string `json:"lang"`
+	Prompt string `json:"prompt"`
+}
+
+// AlphaSweepConfig controls an alpha sweep
+type AlphaSweepConfig struct {
+	Langs        []string // languages to sweep (empty = every language in the suite)
+	Prompts      []SweepPrompt
+	Alphas       []float32 // grid (empty = 0, 0.1, … 1.0)
+	MaxTokens    int       // per answer (0 = 96)
+	Seed         int64     // sampling seed, the same for every cell (0 = 1)
+	MinLangScore float32   // language correctness needed to be recommended (0 = 0.8)
+}
+
+// AlphaScore is one alpha's result for one language
+type AlphaScore struct {
+	Alpha     float32 `json:"alpha"`
+	LangScore float32 `json:"lang_score"` // share of answers in the prompt's language
+	Persona   float32 `json:"persona"`    // mean cosine to the alpha-0 answer (0 without an embedder)
+	Samples   int     `json:"samples"`
+}
+
+// AlphaRecommendation is the sweep's verdict for one language
+type AlphaRecommendation struct {
+	Lang      string       `json:"lang"`
+	Alpha     float32      `json:"alpha"`
+	LangScore float32      `json:"lang_score"`
+	Persona   float32      `json:"persona"`
+	Reached   bool         `json:"reached"` // LangScore ≥ MinLangScore
+	Scores    []AlphaScore `json:"scores"`
+}
+
+// LoadSweepPrompts reads a jsonl suite of {"lang", "prompt"} lines
+func LoadSweepPrompts(path string) ([]SweepPrompt, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open suite: %w", err)
+	}
+	defer f.Close()
+
+	var prompts []SweepPrompt
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 1<<20), 1<<20)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var p SweepPrompt
+		if err := json.Unmarshal([]byte(line), &p); err != nil {
+			return nil, fmt.Errorf("suite line %d: %w", lineNo, err)
+		}
+		if p.Lang == "" || p.Prompt == "" {
+			return nil, fmt.Errorf("suite line %d: lang and prompt required", lineNo)
+		}
+		prompts = append(prompts, p)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read suite: %w", err)
+	}
+	return prompts, nil
+}
+
+// RecommendAlpha picks the best-persona alpha among those reaching
+// minLangScore, or the best language score when none does (ties go to the
+// lower alpha)
+func RecommendAlpha(lang string, scores []AlphaScore, minLangScore float32) AlphaRecommendation {
+	rec := AlphaRecommendation{Lang: lang, Scores: scores}
+	best := -1
+	for i, s := range scores {
+		reached := s.LangScore >= minLangScore
+		switch {
+		case best < 0:
+		case reached && !rec.Reached:
+		case reached && s.Persona > scores[best].Persona:
+		case !reached && !rec.Reached && s.LangScore > scores[best].LangScore:
+		default:
+			continue
+		}
+		best, rec.Reached = i, reached
+	}
+	if best >= 0 {
+		rec.Alpha, rec.LangScore, rec.Persona = scores[best].Alpha, scores[best].LangScore, scores[best].Persona
+	}
+	return rec
+}
+
+// AlphaSweep generates the suite across the alpha grid and recommends an
+// alpha per language. Needs a loaded delta voice.
+func (y *Yent) AlphaSweep(cfg AlphaSweepConfig) ([]AlphaRecommendation, error) {
+	if y.delta == nil {
+		return nil, fmt.Errorf("alpha sweep needs a delta voice (-delta)")
+	}
+	if len(cfg.Alphas) == 0 {
+		for i := 0; i <= 10; i++ {
+			cfg.Alphas = append(cfg.Alphas, float32(i)/10)
+		}
+	}
+	sort.Slice(cfg.Alphas, func(i, j int) bool { return cfg.Alphas[i] < cfg.Alphas[j] })
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = 96
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = 1
+	}
+	if cfg.MinLangScore <= 0 {
+		cfg.MinLangScore = 0.8
+	}
+	if len(cfg.Langs) == 0 {
+		seen := make(map[string]bool)
+		for _, p := range cfg.Prompts {
+			if !seen[p.Lang] {
+				seen[p.Lang] = true
+				cfg.Langs = append(cfg.Langs, p.Lang)
+			}
+		}
+	}
+	embedder := y.Embedder()
+	if embedder == nil {
+		fmt.Fprintf(os.Stderr, "[alpha-sweep] no embedder: persona scores skipped\n")
+	}
+
+	saved := y.DeltaAlpha
+	defer func() { y.DeltaAlpha = saved }()
+	opts := GenerateOptions{MaxTokens: cfg.MaxTokens, Seed: cfg.Seed, Deterministic: true, NoStore: true}
+
+	var recs []AlphaRecommendation
+	for _, lang := range cfg.Langs {
+		var prompts []SweepPrompt
+		for _, p := range cfg.Prompts {
+			if p.Lang == lang {
+				prompts = append(prompts, p)
+			}
+		}
+		if len(prompts) == 0 {
+			return nil, fmt.Errorf("no prompts for language %s in the suite", lang)
+		}
+
+		// Baseline: the alpha-0 answer to each prompt
+		baseline := make([][]float32, len(prompts))
+		if embedder != nil {
+			y.DeltaAlpha = 0
+			for i, p := range prompts {
+				res, err := y.GenerateWithOptions(p.Prompt, opts)
+				if err != nil {
+					return nil, fmt.Errorf("baseline %q: %w", p.Prompt, err)
+				}
+				if baseline[i], err = embedder.Embed(res.Text); err != nil {
+					return nil, fmt.Errorf("embed baseline: %w", err)
+				}
+			}
+		}
+
+		scores := make([]AlphaScore, 0, len(cfg.Alphas))
+		for _, alpha := range cfg.Alphas {
+			y.DeltaAlpha = alpha
+			score := AlphaScore{Alpha: alpha, Samples: len(prompts)}
+			for i, p := range prompts {
+				res, err := y.GenerateWithOptions(p.Prompt, opts)
+				if err != nil {
+					return nil, fmt.Errorf("alpha %.2f %q: %w", alpha, p.Prompt, err)
+				}
+				if got, _ := DetectLanguage(res.Text); got == lang {
+					score.LangScore++
+				}
+				if embedder != nil {
+					vec, err := embedder.Embed(res.Text)
+					if err != nil {
+						return nil, fmt.Errorf("embed answer: %w", err)
+					}
+					score.Persona += dotF32(vec, baseline[i])
+				}
+			}
+			score.LangScore /= float32(len(prompts))
+			score.Persona /= float32(len(prompts))
+			scores = append(scores, score)
+			fmt.Printf("[alpha-sweep] %s alpha=%.2f lang=%.2f persona=%.3f\n", lang, alpha, score.LangScore, score.Persona)
+		}
+		recs = append(recs, RecommendAlpha(lang, scores, cfg.MinLangScore))
+	}
+	return recs, nil
+}
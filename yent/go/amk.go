@@ -17,49 +17,59 @@ import "C"
 import (
 	"fmt"
 	"os"
-	"strings"
 	"sync"
 	"unsafe"
 )
 
 // AMK wraps the Arianna Method Kernel (C shared library)
 type AMK struct {
-	mu      sync.Mutex
-	running bool
+	mu       sync.Mutex
+	running  bool
+	watchers []chan AMState // Watch channels (amkstream.go)
 }
 
 // AMState mirrors C AM_State — the breath of the field
 type AMState struct {
 	// Prophecy physics
-	Prophecy      int
-	Destiny       float32
-	Wormhole      float32
-	CalendarDrift float32
+	Prophecy      int     `json:"prophecy"`
+	Destiny       float32 `json:"destiny"`
+	Wormhole      float32 `json:"wormhole"`
+	CalendarDrift float32 `json:"calendar_drift"`
 
 	// Attention
-	AttendFocus  float32
-	AttendSpread float32
+	AttendFocus  float32 `json:"attend_focus"`
+	AttendSpread float32 `json:"attend_spread"`
 
 	// Tunneling
-	TunnelThreshold float32
-	TunnelChance    float32
-	TunnelSkipMax   int
+	TunnelThreshold float32 `json:"tunnel_threshold"`
+	TunnelChance    float32 `json:"tunnel_chance"`
+	TunnelSkipMax   int     `json:"tunnel_skip_max"`
 
 	// Suffering
-	Pain       float32
-	Tension    float32
-	Dissonance float32
-	Debt       float32
+	Pain       float32 `json:"pain"`
+	Tension    float32 `json:"tension"`
+	Dissonance float32 `json:"dissonance"`
+	Debt       float32 `json:"debt"`
 
 	// Movement
-	VelocityMode      int
-	VelocityMagnitude float32
-	BaseTemperature   float32
-	EffectiveTemp     float32
-	TimeDirection     float32
+	VelocityMode      int     `json:"velocity_mode"`
+	VelocityMagnitude float32 `json:"velocity_magnitude"`
+	BaseTemperature   float32 `json:"base_temperature"`
+	EffectiveTemp     float32 `json:"effective_temp"`
+	TimeDirection     float32 `json:"time_direction"`
 
 	// Wormhole
-	WormholeActive int
+	WormholeActive int     `json:"wormhole_active"`
+	WormholeGate   float32 `json:"wormhole_gate"` // dissonance a wormhole needs (LAW WORMHOLE_GATE)
+
+	// Enabled extension packs (Pack* flags)
+	Packs uint `json:"packs"`
+
+	// Sampler pack (0 = the request's own setting; samplerpack.go)
+	SamplerTopP float32 `json:"sampler_top_p,omitempty"`
+	SamplerTopK int     `json:"sampler_top_k,omitempty"`
+	SamplerMinP float32 `json:"sampler_min_p,omitempty"`
+	SamplerRep  float32 `json:"sampler_rep,omitempty"`
 }
 
 // Pack flags
@@ -67,6 +77,7 @@ const (
 	PackCodesRIC  = 0x01
 	PackDarkMatter = 0x02
 	PackNoTorch   = 0x04
+	PackSampler   = 0x08
 )
 
 // Velocity modes
@@ -83,12 +94,19 @@ func NewAMK() *AMK {
 	return &AMK{running: true}
 }
 
-// Exec executes a DSL script
+// Exec executes a DSL script. LET, IF and {expr} are resolved in Go
+// against the live field before each line reaches the kernel (amkscript.go).
 func (a *AMK) Exec(script string) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	cs := C.CString(script)
+	s := &amkScript{exec: a.execLine, state: a.state}
+	return s.run(script)
+}
+
+// execLine hands one command to the kernel (a.mu held)
+func (a *AMK) execLine(line string) error {
+	cs := C.CString(line)
 	defer C.free(unsafe.Pointer(cs))
 
 	ret := C.am_exec(cs)
@@ -104,19 +122,7 @@ func (a *AMK) ExecFile(path string) error {
 	if err != nil {
 		return fmt.Errorf("read DSL file: %w", err)
 	}
-
-	// Execute line by line (DSL is line-oriented)
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
-			continue
-		}
-		if err := a.Exec(line); err != nil {
-			return fmt.Errorf("DSL line %q: %w", line, err)
-		}
-	}
-	return nil
+	return a.Exec(string(data))
 }
 
 // Step advances physics by dt seconds
@@ -124,13 +130,31 @@ func (a *AMK) Step(dt float32) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 	C.am_step(C.float(dt))
+	if len(a.watchers) > 0 {
+		a.publish(a.state())
+	}
+}
+
+// StepState advances physics by dt seconds and returns the state it
+// leaves: one lock for a token's worth of reads (amkframe.go)
+func (a *AMK) StepState(dt float32) AMState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	C.am_step(C.float(dt))
+	st := a.state()
+	a.publish(st)
+	return st
 }
 
 // GetState reads current kernel state
 func (a *AMK) GetState() AMState {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	return a.state()
+}
 
+// state reads the kernel state (a.mu held)
+func (a *AMK) state() AMState {
 	s := C.am_get_state()
 	return AMState{
 		Prophecy:          int(s.prophecy),
@@ -152,6 +176,12 @@ func (a *AMK) GetState() AMState {
 		EffectiveTemp:     float32(s.effective_temp),
 		TimeDirection:     float32(s.time_direction),
 		WormholeActive:    int(s.wormhole_active),
+		WormholeGate:      float32(s.wormhole_gate),
+		Packs:             uint(s.packs_enabled),
+		SamplerTopP:       float32(s.sampler_top_p),
+		SamplerTopK:       int(s.sampler_top_k),
+		SamplerMinP:       float32(s.sampler_min_p),
+		SamplerRep:        float32(s.sampler_rep),
 	}
 }
 
@@ -169,7 +199,32 @@ func (a *AMK) GetDestinyBias() float32 {
 	return float32(C.am_get_destiny_bias())
 }
 
-// ShouldTunnel checks if tunneling should occur
+// SamplerOverrides returns what the SAMPLER pack sets (zero while the pack is off)
+func (a *AMK) SamplerOverrides() AMKSampler {
+	return a.GetState().Sampler()
+}
+
+// AddSuffering raises pain and tension by the given amounts, each kept in 0..1
+func (a *AMK) AddSuffering(pain, tension float32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s := C.am_get_state()
+	s.pain = C.float(min(max(float32(s.pain)+pain, 0), 1))
+	s.tension = C.float(min(max(float32(s.tension)+tension, 0), 1))
+}
+
+// SetWormholeActive marks whether a wormhole opened this step
+func (a *AMK) SetWormholeActive(on bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	C.am_get_state().wormhole_active = 0
+	if on {
+		C.am_get_state().wormhole_active = 1
+	}
+}
+
+// ShouldTunnel checks if tunneling should occur (draws from C rand();
+// generation applies the same rule on its own RNG, see tunnel.go)
 func (a *AMK) ShouldTunnel() bool {
 	a.mu.Lock()
 	defer a.mu.Unlock()
@@ -213,3 +268,30 @@ func (a *AMK) ResetDebt() {
 	defer a.mu.Unlock()
 	C.am_reset_debt()
 }
+
+// Reset restores every kernel default: prophecy, velocity, suffering and
+// packs, as after NewAMK
+func (a *AMK) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	C.am_init()
+}
+
+// AMKSnapshot is a copy of the entire kernel state (field, packs, debt)
+type AMKSnapshot struct {
+	s C.AM_State
+}
+
+// Snapshot copies the kernel state, so one field can be parked while another runs
+func (a *AMK) Snapshot() *AMKSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return &AMKSnapshot{s: *C.am_get_state()}
+}
+
+// Restore replaces the kernel state with a snapshot
+func (a *AMK) Restore(snap *AMKSnapshot) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	*C.am_get_state() = snap.s
+}
@@ -0,0 +1,111 @@
+package yent
+
+// amkcues.go — Scripted arcs within one answer
+//
+// The field could be set before an answer, not during it. Cues run DSL
+// at token or wall-clock milestones of a generation:
+//
+//   at token 0: VELOCITY NOMOVE        open cold
+//   at token 100: VELOCITY RUN         then let it burn
+//   at 3s: PAIN 0.6                    and hurt after three seconds
+//
+// ParseAMKCues reads that (lines or ';'-separated) into
+// GenerateOptions.AMKCues. A cue fires before the token it names is
+// sampled; a time cue before the first token sampled after it. Like
+// AMKPreset, the arc belongs to the answer: the shared field is restored
+// afterwards, a Session keeps where the arc left it.
+//
+// CLI: -amk-cues "at token 50: VELOCITY RUN"; HTTP: "amk_cues".
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AMKCue runs DSL once a generation reaches a token or a time
+type AMKCue struct {
+	Token int           `json:"token,omitempty"` // generated tokens before the cue (with After = 0)
+	After time.Duration `json:"after,omitempty"` // time since the generation started
+	DSL   string        `json:"dsl"`
+}
+
+// String formats the cue as ParseAMKCues reads it
+func (c AMKCue) String() string {
+	if c.After > 0 {
+		return fmt.Sprintf("at %s: %s", c.After, c.DSL)
+	}
+	return fmt.Sprintf("at token %d: %s", c.Token, c.DSL)
+}
+
+// ParseAMKCues parses "at token N: DSL" and "at 1.5s: DSL" cues, one per
+// line or separated by ';'
+func ParseAMKCues(spec string) ([]AMKCue, error) {
+	var cues []AMKCue
+	for _, line := range strings.FieldsFunc(spec, func(r rune) bool { return r == '\n' || r == ';' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		when, dsl, ok := strings.Cut(line, ":")
+		rest, at := strings.CutPrefix(strings.TrimSpace(when), "at ")
+		if !ok || !at || strings.TrimSpace(dsl) == "" {
+			return nil, fmt.Errorf("cue %q is not \"at token N: DSL\" or \"at 2s: DSL\"", line)
+		}
+		cue := AMKCue{DSL: strings.TrimSpace(dsl)}
+		rest = strings.TrimSpace(rest)
+		if n, isToken := strings.CutPrefix(rest, "token "); isToken {
+			rest = strings.TrimSpace(n)
+		}
+		if n, err := strconv.Atoi(rest); err == nil && n >= 0 {
+			cue.Token = n
+		} else if d, err := time.ParseDuration(rest); err == nil && d > 0 {
+			cue.After = d
+		} else {
+			return nil, fmt.Errorf("cue %q: %q is neither a token count nor a duration", line, rest)
+		}
+		cues = append(cues, cue)
+	}
+	return cues, nil
+}
+
+// amkCues fires a generation's cues in order
+type amkCues struct {
+	tokens []AMKCue // by Token
+	timed  []AMKCue // by After
+}
+
+// newAMKCues sorts the cues (nil when there are none)
+func newAMKCues(cues []AMKCue) *amkCues {
+	if len(cues) == 0 {
+		return nil
+	}
+	c := &amkCues{}
+	for _, cue := range cues {
+		if cue.After > 0 {
+			c.timed = append(c.timed, cue)
+		} else {
+			c.tokens = append(c.tokens, cue)
+		}
+	}
+	sort.SliceStable(c.tokens, func(a, b int) bool { return c.tokens[a].Token < c.tokens[b].Token })
+	sort.SliceStable(c.timed, func(a, b int) bool { return c.timed[a].After < c.timed[b].After })
+	return c
+}
+
+// fire runs the cues due before token i, elapsed into the generation
+func (c *amkCues) fire(amk *AMK, i int, elapsed time.Duration) {
+	if c == nil {
+		return
+	}
+	for len(c.tokens) > 0 && c.tokens[0].Token <= i {
+		amk.Exec(c.tokens[0].DSL)
+		c.tokens = c.tokens[1:]
+	}
+	for len(c.timed) > 0 && c.timed[0].After <= elapsed {
+		amk.Exec(c.timed[0].DSL)
+		c.timed = c.timed[1:]
+	}
+}
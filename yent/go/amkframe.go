@@ -0,0 +1,46 @@
+package yent
+
+// amkframe.go — One lock per token
+//
+// Every per-token question the loop asked the kernel (the temperature,
+// destiny, suffering over the logits, the sampler pack, tunneling,
+// wormholes, events) took the AMK mutex on its own: eight or more
+// acquisitions per token, each one a point where parallel generations on
+// a shared kernel would queue behind each other.
+//
+// AMK.StepState advances the physics and returns the state it leaves in
+// a single locked call. AMState is a plain value, so everything the token
+// needs from it is answered lock-free by its methods below, the same
+// arithmetic the C helpers apply to the live kernel. Generation takes one
+// such frame per token; the kernel is locked again only when the token
+// writes back (an opened wormhole, degeneration pain, cues).
+
+// Temperature is the velocity-modulated temperature (AMK.GetTemperature)
+func (s AMState) Temperature() float32 {
+	return s.EffectiveTemp
+}
+
+// DestinyBias is the pull toward the likeliest tokens (AMK.GetDestinyBias)
+func (s AMState) DestinyBias() float32 {
+	return s.Destiny
+}
+
+// ApplySufferingToLogits dampens logits by pain and tension, as
+// AMK.ApplySufferingToLogits does with the live kernel
+func (s AMState) ApplySufferingToLogits(logits []float32) {
+	if s.Pain <= 0.1 && s.Tension <= 0.1 {
+		return
+	}
+	dampen := 1 - (s.Pain*0.3 + s.Tension*0.2)
+	for i := range logits {
+		logits[i] *= dampen
+	}
+}
+
+// Sampler returns what the SAMPLER pack sets (zero while the pack is off)
+func (s AMState) Sampler() AMKSampler {
+	if s.Packs&PackSampler == 0 {
+		return AMKSampler{}
+	}
+	return AMKSampler{TopP: s.SamplerTopP, TopK: s.SamplerTopK, MinP: s.SamplerMinP, Rep: s.SamplerRep}
+}
@@ -0,0 +1,82 @@
+package yent
+
+// amkpreset.go — Named kernel configurations
+//
+// The DSL has two dozen knobs, and a coherent field (a cold velocity with
+// a loose destiny is not one) takes knowing what each does. A preset is a
+// DSL bundle run on a freshly reset kernel:
+//
+//   calm           walk at 0.68, a firm destiny, no tunneling, no pain
+//   feverish       run at 1.32, loose destiny, wormholes and tunneling
+//   prophetic      far prophecy horizon, strong destiny, wide attention
+//   deterministic  the coldest field: no movement, destiny 1, no jumps
+//
+// CLI: -amk-preset calm; REPL: /amk preset calm; HTTP: "amk_preset" runs
+// one request on a preset field (a session keeps it for its next turns).
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AMKPresets maps a preset name to the DSL it runs after a kernel reset
+var AMKPresets = map[string]string{
+	"calm": `VELOCITY WALK
+BASE_TEMP 0.8
+DESTINY 0.6
+WORMHOLE 0.01
+TUNNEL_CHANCE 0
+ATTEND_FOCUS 0.85
+ATTEND_SPREAD 0.1`,
+
+	"feverish": `VELOCITY RUN
+BASE_TEMP 1.1
+DESTINY 0.15
+WORMHOLE 0.1
+TUNNEL_THRESHOLD 0.3
+TUNNEL_CHANCE 0.2
+DISSONANCE 0.5
+TENSION 0.3
+ATTEND_FOCUS 0.4
+ATTEND_SPREAD 0.5`,
+
+	"prophetic": `PROPHECY 21
+DESTINY 0.75
+CALENDAR_DRIFT 13
+VELOCITY WALK
+WORMHOLE 0.05
+ATTEND_FOCUS 0.6
+ATTEND_SPREAD 0.4`,
+
+	"deterministic": `VELOCITY NOMOVE
+BASE_TEMP 0.2
+DESTINY 1
+WORMHOLE 0
+TUNNEL_CHANCE 0
+ATTEND_FOCUS 1
+ATTEND_SPREAD 0`,
+}
+
+// AMKPresetNames lists the presets in name order
+func AMKPresetNames() []string {
+	names := make([]string, 0, len(AMKPresets))
+	for name := range AMKPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyPreset resets the kernel and runs a named preset
+func (a *AMK) ApplyPreset(name string) error {
+	script, ok := AMKPresets[name]
+	if !ok {
+		return fmt.Errorf("unknown AMK preset %q (have %s)", name, strings.Join(AMKPresetNames(), ", "))
+	}
+	a.Reset()
+	if err := a.Exec(script); err != nil {
+		return fmt.Errorf("AMK preset %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,504 @@
+package yent
+
+// amkscript.go — Reactive DSL
+//
+// The kernel DSL sets values; it cannot look at them. Exec and ExecFile
+// now read each script in Go first, line by line, against the live field,
+// and hand the kernel only the commands that run:
+//
+//   LET calm = 1 - PAIN                   a variable for the rest of the script
+//   IF PAIN > 0.5 THEN VELOCITY NOMOVE    one-line condition
+//   IF DEBT > 3 AND calm < 0.4 THEN       block, closed by END
+//     PAIN {PAIN * 0.5}                   {expr} is replaced by its value
+//   ELSE
+//     VELOCITY RUN
+//   END
+//
+// Expressions take numbers, variables, the field by its DSL names (PAIN,
+// TENSION, DISSONANCE, DEBT, DESTINY, PROPHECY, WORMHOLE, VELOCITY,
+// TEMPERATURE, ...; see amkFieldValue), the velocity names NOMOVE, WALK,
+// RUN and BACKWARD, + - * / and parentheses, comparisons (< <= > >= ==
+// !=) and AND, OR, NOT; true is 1, false 0. Each line sees the field the
+// lines before it left. Variables live for one script; a script without
+// LET, IF or braces runs exactly as before.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// amkVelocities are the velocity names expressions compare against
+var amkVelocities = map[string]float64{
+	"NOMOVE": VelNoMove, "WALK": VelWalk, "RUN": VelRun, "BACKWARD": VelBackward,
+}
+
+// amkFieldValue reads a field value by its DSL name
+func amkFieldValue(s AMState, name string) (float64, bool) {
+	switch name {
+	case "PROPHECY":
+		return float64(s.Prophecy), true
+	case "DESTINY":
+		return float64(s.Destiny), true
+	case "WORMHOLE":
+		return float64(s.Wormhole), true
+	case "WORMHOLE_ACTIVE":
+		return float64(s.WormholeActive), true
+	case "WORMHOLE_GATE":
+		return float64(s.WormholeGate), true
+	case "CALENDAR_DRIFT":
+		return float64(s.CalendarDrift), true
+	case "ATTEND_FOCUS":
+		return float64(s.AttendFocus), true
+	case "ATTEND_SPREAD":
+		return float64(s.AttendSpread), true
+	case "TUNNEL_THRESHOLD":
+		return float64(s.TunnelThreshold), true
+	case "TUNNEL_CHANCE":
+		return float64(s.TunnelChance), true
+	case "TUNNEL_SKIP_MAX":
+		return float64(s.TunnelSkipMax), true
+	case "PAIN":
+		return float64(s.Pain), true
+	case "TENSION":
+		return float64(s.Tension), true
+	case "DISSONANCE":
+		return float64(s.Dissonance), true
+	case "DEBT", "PROPHECY_DEBT":
+		return float64(s.Debt), true
+	case "VELOCITY":
+		return float64(s.VelocityMode), true
+	case "VELOCITY_MAGNITUDE":
+		return float64(s.VelocityMagnitude), true
+	case "BASE_TEMP":
+		return float64(s.BaseTemperature), true
+	case "TEMPERATURE", "EFFECTIVE_TEMP":
+		return float64(s.EffectiveTemp), true
+	case "TIME_DIRECTION":
+		return float64(s.TimeDirection), true
+	}
+	return 0, false
+}
+
+// amkBlock is one open IF block
+type amkBlock struct {
+	outer  bool // the enclosing lines run
+	taken  bool // the condition held
+	inElse bool
+}
+
+// amkScript runs one script against the kernel
+type amkScript struct {
+	exec   func(line string) error // hands a command to the kernel
+	state  func() AMState
+	vars   map[string]float64
+	blocks []amkBlock
+}
+
+// active reports whether lines at the current depth run
+func (s *amkScript) active() bool {
+	if len(s.blocks) == 0 {
+		return true
+	}
+	b := s.blocks[len(s.blocks)-1]
+	return b.outer && b.taken != b.inElse
+}
+
+// run executes script line by line
+func (s *amkScript) run(script string) error {
+	for n, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if err := s.line(line); err != nil {
+			return fmt.Errorf("DSL line %d %q: %w", n+1, line, err)
+		}
+	}
+	if len(s.blocks) > 0 {
+		return fmt.Errorf("DSL: IF without END")
+	}
+	return nil
+}
+
+// line handles block structure, then runs the statement if its block does
+func (s *amkScript) line(line string) error {
+	word, rest := amkFirstWord(line)
+	switch word {
+	case "IF":
+		cond, then, ok := amkCutWord(rest, "THEN")
+		if !ok {
+			return fmt.Errorf("IF without THEN")
+		}
+		if strings.TrimSpace(then) != "" {
+			break // one-line IF: a statement
+		}
+		b := amkBlock{outer: s.active()}
+		if b.outer {
+			v, err := s.eval(cond)
+			if err != nil {
+				return err
+			}
+			b.taken = v != 0
+		}
+		s.blocks = append(s.blocks, b)
+		return nil
+	case "ELSE":
+		if len(s.blocks) == 0 || s.blocks[len(s.blocks)-1].inElse {
+			return fmt.Errorf("ELSE without IF")
+		}
+		s.blocks[len(s.blocks)-1].inElse = true
+		return nil
+	case "END", "ENDIF":
+		if len(s.blocks) == 0 {
+			return fmt.Errorf("END without IF")
+		}
+		s.blocks = s.blocks[:len(s.blocks)-1]
+		return nil
+	}
+	if !s.active() {
+		return nil
+	}
+	return s.statement(line)
+}
+
+// statement runs LET, a one-line IF or a kernel command
+func (s *amkScript) statement(line string) error {
+	word, rest := amkFirstWord(line)
+	switch word {
+	case "LET":
+		name, expr, ok := strings.Cut(rest, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if !ok || !amkIdent(name) {
+			return fmt.Errorf("LET wants \"LET name = expression\"")
+		}
+		_, field := amkFieldValue(AMState{}, name)
+		if _, vel := amkVelocities[name]; field || vel || amkKeyword(name) {
+			return fmt.Errorf("LET %s: the name is taken", name)
+		}
+		v, err := s.eval(expr)
+		if err != nil {
+			return err
+		}
+		if s.vars == nil {
+			s.vars = make(map[string]float64)
+		}
+		s.vars[name] = v
+		return nil
+	case "IF":
+		cond, then, _ := amkCutWord(rest, "THEN")
+		then, otherwise, hasElse := amkCutWord(then, "ELSE")
+		v, err := s.eval(cond)
+		if err != nil {
+			return err
+		}
+		if v != 0 {
+			return s.statement(strings.TrimSpace(then))
+		}
+		if hasElse {
+			return s.statement(strings.TrimSpace(otherwise))
+		}
+		return nil
+	}
+	cmd, err := s.substitute(line)
+	if err != nil {
+		return err
+	}
+	return s.exec(cmd)
+}
+
+// substitute replaces each {expr} in a command by its value
+func (s *amkScript) substitute(line string) (string, error) {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(line, '{')
+		if open < 0 {
+			b.WriteString(line)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(line[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed {")
+		}
+		v, err := s.eval(line[open+1 : open+end])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(line[:open])
+		b.WriteString(strconv.FormatFloat(v, 'g', 6, 64))
+		line = line[open+end+1:]
+	}
+}
+
+// eval evaluates an expression against the variables and the live field
+func (s *amkScript) eval(expr string) (float64, error) {
+	toks, err := amkLex(expr)
+	if err != nil {
+		return 0, err
+	}
+	if len(toks) == 0 {
+		return 0, fmt.Errorf("empty expression")
+	}
+	p := &amkParser{toks: toks, s: s, state: s.state()}
+	v, err := p.or()
+	if err == nil && p.i < len(toks) {
+		err = fmt.Errorf("unexpected %q", toks[p.i])
+	}
+	return v, err
+}
+
+// amkParser is a recursive-descent evaluator over one expression
+type amkParser struct {
+	toks  []string
+	i     int
+	s     *amkScript
+	state AMState
+}
+
+func (p *amkParser) peek() string {
+	if p.i < len(p.toks) {
+		return p.toks[p.i]
+	}
+	return ""
+}
+
+func (p *amkParser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	for _, op := range ops {
+		if t == op {
+			p.i++
+			return t, true
+		}
+	}
+	return "", false
+}
+
+func amkBool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (p *amkParser) or() (float64, error) {
+	v, err := p.and()
+	for err == nil {
+		if _, ok := p.accept("OR", "||"); !ok {
+			break
+		}
+		var r float64
+		r, err = p.and()
+		v = amkBool(v != 0 || r != 0)
+	}
+	return v, err
+}
+
+func (p *amkParser) and() (float64, error) {
+	v, err := p.not()
+	for err == nil {
+		if _, ok := p.accept("AND", "&&"); !ok {
+			break
+		}
+		var r float64
+		r, err = p.not()
+		v = amkBool(v != 0 && r != 0)
+	}
+	return v, err
+}
+
+func (p *amkParser) not() (float64, error) {
+	if _, ok := p.accept("NOT", "!"); ok {
+		v, err := p.not()
+		return amkBool(v == 0), err
+	}
+	return p.compare()
+}
+
+func (p *amkParser) compare() (float64, error) {
+	v, err := p.sum()
+	if err != nil {
+		return 0, err
+	}
+	op, ok := p.accept("<", "<=", ">", ">=", "==", "=", "!=")
+	if !ok {
+		return v, nil
+	}
+	r, err := p.sum()
+	switch op {
+	case "<":
+		return amkBool(v < r), err
+	case "<=":
+		return amkBool(v <= r), err
+	case ">":
+		return amkBool(v > r), err
+	case ">=":
+		return amkBool(v >= r), err
+	case "!=":
+		return amkBool(v != r), err
+	}
+	return amkBool(v == r), err
+}
+
+func (p *amkParser) sum() (float64, error) {
+	v, err := p.product()
+	for err == nil {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			break
+		}
+		var r float64
+		r, err = p.product()
+		if op == "+" {
+			v += r
+		} else {
+			v -= r
+		}
+	}
+	return v, err
+}
+
+func (p *amkParser) product() (float64, error) {
+	v, err := p.unary()
+	for err == nil {
+		op, ok := p.accept("*", "/")
+		if !ok {
+			break
+		}
+		var r float64
+		if r, err = p.unary(); err != nil {
+			break
+		}
+		if op == "*" {
+			v *= r
+		} else if r == 0 {
+			err = fmt.Errorf("division by zero")
+		} else {
+			v /= r
+		}
+	}
+	return v, err
+}
+
+func (p *amkParser) unary() (float64, error) {
+	if _, ok := p.accept("-"); ok {
+		v, err := p.unary()
+		return -v, err
+	}
+	if _, ok := p.accept("+"); ok {
+		return p.unary()
+	}
+	return p.primary()
+}
+
+func (p *amkParser) primary() (float64, error) {
+	t := p.peek()
+	if t == "" {
+		return 0, fmt.Errorf("expression ends too early")
+	}
+	p.i++
+	if t == "(" {
+		v, err := p.or()
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return 0, fmt.Errorf("missing )")
+		}
+		return v, nil
+	}
+	if v, err := strconv.ParseFloat(t, 64); err == nil {
+		return v, nil
+	}
+	if v, ok := p.s.vars[t]; ok {
+		return v, nil
+	}
+	if v, ok := amkFieldValue(p.state, t); ok {
+		return v, nil
+	}
+	if v, ok := amkVelocities[t]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("unknown name %q", t)
+}
+
+// amkLex splits an expression into numbers, upper-cased names and operators
+func amkLex(expr string) ([]string, error) {
+	var toks []string
+	rs := []rune(expr)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			toks = append(toks, string(rs[i:j]))
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_') {
+				j++
+			}
+			toks = append(toks, strings.ToUpper(string(rs[i:j])))
+			i = j
+		default:
+			if i+1 < len(rs) {
+				if two := string(rs[i : i+2]); two == "<=" || two == ">=" || two == "==" || two == "!=" || two == "&&" || two == "||" {
+					toks = append(toks, two)
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("+-*/()<>=!", r) {
+				return nil, fmt.Errorf("unexpected %q", r)
+			}
+			toks = append(toks, string(r))
+			i++
+		}
+	}
+	return toks, nil
+}
+
+// amkFirstWord splits off the upper-cased first word of a line
+func amkFirstWord(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	end := strings.IndexFunc(line, unicode.IsSpace)
+	if end < 0 {
+		return strings.ToUpper(line), ""
+	}
+	return strings.ToUpper(line[:end]), strings.TrimSpace(line[end:])
+}
+
+// amkCutWord cuts s around the first standalone keyword (any case)
+func amkCutWord(s, keyword string) (before, after string, found bool) {
+	words := strings.Fields(s)
+	for i, w := range words {
+		if strings.EqualFold(w, keyword) {
+			return strings.Join(words[:i], " "), strings.Join(words[i+1:], " "), true
+		}
+	}
+	return s, "", false
+}
+
+// amkIdent reports whether name can be a variable
+func amkIdent(name string) bool {
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// amkKeyword reports whether name is a script keyword
+func amkKeyword(name string) bool {
+	switch name {
+	case "LET", "IF", "THEN", "ELSE", "END", "ENDIF", "AND", "OR", "NOT":
+		return true
+	}
+	return false
+}
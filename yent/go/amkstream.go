@@ -0,0 +1,55 @@
+package yent
+
+// amkstream.go — The field as a stream
+//
+// Events report when the field crosses a line; a dashboard that draws the
+// field itself had to poll GetState and miss everything between polls.
+// AMK.Watch hands out a channel that receives the state after every Step
+// (one per generated token).
+//
+// A watcher never slows the kernel down: each channel holds one state,
+// and a newer state replaces one the consumer has not taken yet. A slow
+// reader sees fewer, always current, frames; a fast one sees them all.
+// Unwatch closes the channel.
+
+// Watch returns a channel receiving the kernel state after each Step,
+// coalesced to the latest one while the consumer lags. Unwatch it when done.
+func (a *AMK) Watch() <-chan AMState {
+	ch := make(chan AMState, 1)
+	a.mu.Lock()
+	a.watchers = append(a.watchers, ch)
+	a.mu.Unlock()
+	return ch
+}
+
+// Unwatch stops and closes a channel returned by Watch
+func (a *AMK) Unwatch(ch <-chan AMState) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, w := range a.watchers {
+		if w == ch {
+			a.watchers = append(a.watchers[:i], a.watchers[i+1:]...)
+			close(w)
+			return
+		}
+	}
+}
+
+// publish offers s to every watcher, replacing a frame not yet taken (a.mu held)
+func (a *AMK) publish(s AMState) {
+	for _, w := range a.watchers {
+		select {
+		case w <- s:
+			continue
+		default:
+		}
+		select {
+		case <-w: // drop the stale frame
+		default:
+		}
+		select {
+		case w <- s:
+		default:
+		}
+	}
+}
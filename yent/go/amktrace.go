@@ -0,0 +1,99 @@
+package yent
+
+// amktrace.go — The field, token by token
+//
+// Stored turns keep one AMK state: the one after the answer. Whether pain
+// rose before the answer fell apart, or temperature dropped when it got
+// good, was not recorded. With GenerateOptions.AMKTrace = N the loop
+// keeps the kernel state at each of the last N generated tokens (a ring:
+// a long answer keeps its end) in GenerateResult.AMKTrace.
+//
+// WriteAMKTrace writes a trace as JSON lines, one token each:
+//
+//   {"step":0,"token":412,"text":" I","prophecy":7,"pain":0.1,...}
+//
+// CLI: -amk-trace FILE appends every answer's trace to FILE (step 0
+// starts the next answer); HTTP: "amk_trace": N in a generate request.
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// DefaultAMKTrace is the trace length -amk-trace records per answer
+const DefaultAMKTrace = 4096
+
+// AMKTracePoint is the kernel state at one generated token
+type AMKTracePoint struct {
+	Step  int    `json:"step"` // index of the token in the answer
+	Token int    `json:"token"`
+	Text  string `json:"text"`
+	AMState
+}
+
+// amkTrace is a ring of the last trace points
+type amkTrace struct {
+	points []AMKTracePoint
+	size   int
+	next   int // total points added
+}
+
+// newAMKTrace returns a ring of size points (nil = not tracing)
+func newAMKTrace(size int) *amkTrace {
+	if size <= 0 {
+		return nil
+	}
+	return &amkTrace{points: make([]AMKTracePoint, 0, min(size, 1024)), size: size}
+}
+
+// add records a point, replacing the oldest once full
+func (t *amkTrace) add(p AMKTracePoint) {
+	if t == nil {
+		return
+	}
+	if len(t.points) < t.size {
+		t.points = append(t.points, p)
+	} else {
+		t.points[t.next%t.size] = p
+	}
+	t.next++
+}
+
+// ordered returns the points oldest first
+func (t *amkTrace) ordered() []AMKTracePoint {
+	if t == nil || len(t.points) == 0 {
+		return nil
+	}
+	if len(t.points) < t.size {
+		return t.points
+	}
+	start := t.next % t.size
+	return append(append([]AMKTracePoint(nil), t.points[start:]...), t.points[:start]...)
+}
+
+// WriteAMKTrace writes trace as JSON lines
+func WriteAMKTrace(w io.Writer, trace []AMKTracePoint) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, p := range trace {
+		if err := enc.Encode(p); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// AppendAMKTrace appends trace to the JSONL file at path
+func AppendAMKTrace(path string, trace []AMKTracePoint) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := WriteAMKTrace(f, trace); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,78 @@
+package yent
+
+// amkwatch.go — Kernel events
+//
+// The field changed only in numbers nobody looked at. After every token
+// step the generation loop now compares the kernel state with the last
+// one and publishes what crossed a line:
+//
+//   amk            velocity changed (from, to, temperature)
+//   amk.wormhole   WormholeActive went on
+//   amk.debt       prophecy debt crossed AMKThresholds.Debt, either way
+//   amk.pain       pain rose by AMKThresholds.PainSpike within one token
+//
+// Subscribe to them on y.Events() like any other event, or with
+// EventBus.On for a callback:
+//
+//   y.Events().On(func(e Event) { ... }, EventWormhole)
+//
+// Nothing is read from the kernel per token while no one listens.
+
+// AMKThresholds sets when kernel changes become events
+type AMKThresholds struct {
+	Debt      float32 // prophecy debt level (0 = 5)
+	PainSpike float32 // pain rise within one token (0 = 0.2)
+}
+
+// amkWatch follows the kernel through one generation
+type amkWatch struct {
+	y       *Yent
+	session string
+	prev    AMState
+	debt    float32
+	spike   float32
+}
+
+// newAMKWatch starts watching from the current kernel state
+func (y *Yent) newAMKWatch(session string) *amkWatch {
+	w := &amkWatch{y: y, session: session, prev: y.amk.GetState(),
+		debt: y.AMKThresholds.Debt, spike: y.AMKThresholds.PainSpike}
+	if w.debt <= 0 {
+		w.debt = 5
+	}
+	if w.spike <= 0 {
+		w.spike = 0.2
+	}
+	return w
+}
+
+// step publishes what changed in the token's field s since the last token
+func (w *amkWatch) step(token int, s AMState) {
+	bus := w.y.events
+	if !bus.WantsAny(EventAMK, EventWormhole, EventDebt, EventPain) {
+		return
+	}
+	prev := w.prev
+	w.prev = s
+	publish := func(kind string, data map[string]interface{}) {
+		if bus.Wants(kind) {
+			data["token"] = token
+			bus.Publish(Event{Kind: kind, Session: w.session, Data: data})
+		}
+	}
+	if s.VelocityMode != prev.VelocityMode {
+		publish(EventAMK, map[string]interface{}{
+			"velocity": velocityName(s.VelocityMode), "from": velocityName(prev.VelocityMode),
+			"temperature": s.EffectiveTemp, "pain": s.Pain, "tension": s.Tension,
+		})
+	}
+	if s.WormholeActive != 0 && prev.WormholeActive == 0 {
+		publish(EventWormhole, map[string]interface{}{"wormhole": s.Wormhole, "debt": s.Debt, "dissonance": s.Dissonance})
+	}
+	if (s.Debt >= w.debt) != (prev.Debt >= w.debt) {
+		publish(EventDebt, map[string]interface{}{"debt": s.Debt, "from": prev.Debt, "threshold": w.debt, "rising": s.Debt > prev.Debt})
+	}
+	if s.Pain-prev.Pain >= w.spike {
+		publish(EventPain, map[string]interface{}{"pain": s.Pain, "from": prev.Pain, "tension": s.Tension})
+	}
+}
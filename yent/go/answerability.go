@@ -0,0 +1,131 @@
+package yent
+
+// answerability.go — Do the memories actually hold the answer?
+//
+// Retrieval always returns something. Five loosely related memories framed
+// as "Things you remember" invite the model to invent a past that never
+// happened. Before injection the memories are scored; below the threshold
+// the context switches to the "unknown" framing — you don't remember this,
+// say so — instead of presenting weak matches as recollection.
+//
+//   heuristic — 0.5·coverage + 0.5·best retrieval score, where coverage is
+//               the share of the query's content words (crudely stemmed)
+//               found in the memories
+//   model     — ask Yent whether the notes answer the question and read
+//               P(yes) from the first token's distribution
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// AnswerabilityScorer estimates how likely items contain the answer to query (0..1)
+type AnswerabilityScorer interface {
+	Score(query string, items []MemoryItem) (float32, error)
+}
+
+// answerStopwords are question words that say nothing about the topic
+var answerStopwords = map[string]bool{
+	"the": true, "and": true, "you": true, "your": true, "what": true, "who": true,
+	"when": true, "where": true, "why": true, "how": true, "did": true, "does": true,
+	"are": true, "was": true, "were": true, "that": true, "this": true, "with": true,
+	"about": true, "tell": true, "remember": true, "can": true, "could": true,
+	"would": true, "have": true, "has": true, "for": true, "from": true, "told": true,
+}
+
+// HeuristicAnswerability scores by query coverage and retrieval confidence
+type HeuristicAnswerability struct{}
+
+// Score returns 0.5·coverage + 0.5·best item score
+func (HeuristicAnswerability) Score(query string, items []MemoryItem) (float32, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+	have := make(map[string]bool)
+	var best float32
+	for _, it := range items {
+		for _, w := range contentWords(it.Prompt + " " + it.Response) {
+			have[answerStem(w)] = true
+		}
+		if it.Score > best {
+			best = it.Score
+		}
+	}
+	var terms, found int
+	for _, w := range contentWords(query) {
+		if answerStopwords[w] {
+			continue
+		}
+		terms++
+		if have[answerStem(w)] {
+			found++
+		}
+	}
+	coverage := float32(1)
+	if terms > 0 {
+		coverage = float32(found) / float32(terms)
+	}
+	return 0.5*coverage + 0.5*clamp01(best), nil
+}
+
+// answerStem strips common English suffixes so "lives" finds "live"
+func answerStem(w string) string {
+	for _, suffix := range []string{"ing", "ed", "s"} {
+		if strings.HasSuffix(w, suffix) && len(w)-len(suffix) >= 3 {
+			return strings.TrimSuffix(w, suffix)
+		}
+	}
+	return w
+}
+
+// ModelAnswerability asks the loaded model itself (one token, not stored)
+type ModelAnswerability struct {
+	y *Yent
+}
+
+// NewModelAnswerability returns a scorer backed by y
+func NewModelAnswerability(y *Yent) *ModelAnswerability {
+	return &ModelAnswerability{y: y}
+}
+
+// Score returns P(yes) / (P(yes) + P(no)) for "do these notes answer it?"
+func (s *ModelAnswerability) Score(query string, items []MemoryItem) (float32, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+	var b strings.Builder
+	b.WriteString("Notes:\n")
+	for _, it := range items {
+		fmt.Fprintf(&b, "- %s — %s\n", oneLine(it.Prompt), oneLine(it.Response))
+	}
+	fmt.Fprintf(&b, "Do these notes contain the answer to \"%s\"? Answer yes or no.", query)
+
+	res, err := s.y.GenerateWithOptions(b.String(), GenerateOptions{
+		MaxTokens:   1,
+		Temperature: 0,
+		TopK:        1,
+		Logprobs:    true,
+		TopLogprobs: 20,
+		NoStore:     true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("answerability: %w", err)
+	}
+	if len(res.Tokens) == 0 {
+		return 0, nil
+	}
+	var yes, no float64
+	for _, alt := range res.Tokens[0].Top {
+		switch strings.ToLower(strings.TrimSpace(alt.Text)) {
+		case "yes":
+			yes += math.Exp(float64(alt.Logprob))
+		case "no":
+			no += math.Exp(float64(alt.Logprob))
+		}
+	}
+	if yes+no == 0 {
+		return 0.5, nil // the model has no opinion: don't override retrieval
+	}
+	return float32(yes / (yes + no)), nil
+}
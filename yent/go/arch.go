@@ -0,0 +1,274 @@
+package yent
+
+// arch.go — Architectures beyond Qwen2
+//
+// The forward pass was written for Qwen2.5: pre-norm RMSNorm blocks, SwiGLU,
+// half-split RoPE over the whole head, an untied LM head. Most small GGUF
+// models differ from that in a handful of switches, read from
+// general.architecture:
+//
+//   qwen2    the reference: biases on Q/K/V, half-split RoPE
+//   llama    SmolLM, TinyLlama: no biases, tied head, adjacent-pair RoPE
+//            (convert_hf_to_gguf permutes Q/K for llama, not for qwen2)
+//   gemma    embeddings × sqrt(dim), GELU-gated MLP, head_dim from
+//            key_length (256, wider than dim/heads on 7B), tied head
+//   gemma2   gemma + post-attention and post-MLP norms, tanh soft-capping
+//            of attention scores and logits
+//   phi2     LayerNorm with bias, one norm shared by attention and MLP
+//            (parallel block), fused QKV, plain GELU MLP with biases,
+//            partial RoPE (32 of 80 dims per head)
+//   phi3     RMSNorm, fused QKV, fused gate+up
+//
+// Fused tensors are split at load time: quantized rows are contiguous, so
+// attn_qkv is three row ranges of one buffer and the forward pass never
+// knows the difference.
+//
+// Not covered: Gemma 2's sliding window (alternate layers, 4096 — wider
+// than the default 2048 context), Gemma 3's Q/K norms, Phi-3's LongRoPE
+// factors for the 128k variants.
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// archDefaults are the per-architecture switches not stored in metadata
+var archDefaults = map[string]func(cfg *LlamaConfig){
+	"qwen2": func(cfg *LlamaConfig) {},
+	"llama": func(cfg *LlamaConfig) { cfg.RopeInterleaved = true },
+	"gemma": func(cfg *LlamaConfig) {
+		cfg.GELU = true
+		cfg.EmbedScale = float32(math.Sqrt(float64(cfg.EmbedDim)))
+	},
+	"gemma2": func(cfg *LlamaConfig) {
+		cfg.GELU = true
+		cfg.EmbedScale = float32(math.Sqrt(float64(cfg.EmbedDim)))
+	},
+	"phi2": func(cfg *LlamaConfig) {
+		cfg.LayerNorm = true
+		cfg.ParallelBlock = true
+		cfg.GELU = true
+	},
+	"phi3": func(cfg *LlamaConfig) {},
+}
+
+// SupportedArchitectures lists the general.architecture values that load
+func SupportedArchitectures() []string {
+	names := make([]string, 0, len(archDefaults))
+	for name := range archDefaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyArch sets the architecture switches on cfg
+func applyArch(cfg *LlamaConfig, m *GGUFMetadata) error {
+	cfg.Arch = m.Arch
+	if cfg.Arch == "" {
+		cfg.Arch = "llama"
+	}
+	set, ok := archDefaults[cfg.Arch]
+	if !ok {
+		return fmt.Errorf("unsupported architecture %q (supported: %v)", cfg.Arch, SupportedArchitectures())
+	}
+	set(cfg)
+	cfg.RopeDim = m.RopeDim
+	if cfg.RopeDim <= 0 || cfg.RopeDim > cfg.HeadDim {
+		cfg.RopeDim = cfg.HeadDim
+	}
+	cfg.AttnSoftcap = m.AttnSoftcap
+	cfg.FinalSoftcap = m.FinalSoftcap
+	return nil
+}
+
+// ropeDim returns the rotated dimensions per head (zero config = whole head)
+func (cfg *LlamaConfig) ropeDim() int {
+	if cfg.RopeDim > 0 {
+		return cfg.RopeDim
+	}
+	return cfg.HeadDim
+}
+
+// norm normalizes x into out with the architecture's norm
+func (cfg *LlamaConfig) norm(out, x, w, b []float32) {
+	if cfg.LayerNorm {
+		layerNormInto(out, x, w, b, cfg.RMSNormEps)
+		return
+	}
+	RMSNormInto(out, x, w, cfg.RMSNormEps)
+}
+
+// activate is the MLP nonlinearity
+func (cfg *LlamaConfig) activate(x float32) float32 {
+	if cfg.GELU {
+		return GELU(x)
+	}
+	return SiLU(x)
+}
+
+// layerNormInto computes out = (x - mean) / std * w + b
+func layerNormInto(out, x, w, b []float32, eps float32) {
+	var mean float32
+	for _, v := range x {
+		mean += v
+	}
+	mean /= float32(len(x))
+	var variance float32
+	for _, v := range x {
+		d := v - mean
+		variance += d * d
+	}
+	inv := float32(1 / math.Sqrt(float64(variance/float32(len(x))+eps)))
+	for i, v := range x {
+		out[i] = (v - mean) * inv * w[i]
+		if b != nil {
+			out[i] += b[i]
+		}
+	}
+}
+
+// GELU is the tanh approximation used by Gemma and Phi
+func GELU(x float32) float32 {
+	const c = 0.7978845608028654 // sqrt(2/pi)
+	return 0.5 * x * (1 + float32(math.Tanh(c*float64(x+0.044715*x*x*x))))
+}
+
+// softcap squashes v into (-limit, limit) (no-op for limit 0)
+func softcap(v []float32, limit float32) {
+	if limit <= 0 {
+		return
+	}
+	for i, x := range v {
+		v[i] = limit * float32(math.Tanh(float64(x/limit)))
+	}
+}
+
+// splitRows cuts a raw row-major tensor into consecutive row ranges
+func splitRows(data []byte, typ uint32, cols int, rows ...int) ([][]byte, error) {
+	be := ggmlBlockElements(typ)
+	if be == 0 || cols%be != 0 {
+		return nil, fmt.Errorf("cannot split tensor type %d with %d columns", typ, cols)
+	}
+	rowBytes := cols / be * ggmlBlockSize(typ)
+	parts := make([][]byte, len(rows))
+	off := 0
+	for i, n := range rows {
+		end := off + n*rowBytes
+		if end > len(data) {
+			return nil, fmt.Errorf("fused tensor too small: %d rows past %d bytes", n, len(data))
+		}
+		parts[i] = data[off:end]
+		off = end
+	}
+	return parts, nil
+}
+
+// splitF32 cuts a vector into consecutive ranges
+func splitF32(v []float32, sizes ...int) [][]float32 {
+	if v == nil {
+		return make([][]float32, len(sizes))
+	}
+	parts := make([][]float32, len(sizes))
+	off := 0
+	for i, n := range sizes {
+		parts[i] = v[off : off+n]
+		off += n
+	}
+	return parts
+}
+
+// loadAttention reads the Q/K/V projections and biases, separate or fused (attn_qkv)
+func loadAttention(gguf *GGUFFile, cfg *LlamaConfig, prefix string, l *LlamaLayerWeights) error {
+	qDim, kvDim := cfg.NumHeads*cfg.HeadDim, cfg.NumKVHeads*cfg.HeadDim
+	if data, typ, err := getRawTensor(gguf, prefix+"attn_qkv.weight"); err == nil {
+		parts, err := splitRows(data, typ, cfg.EmbedDim, qDim, kvDim, kvDim)
+		if err != nil {
+			return fmt.Errorf("attn_qkv: %w", err)
+		}
+		l.WQ, l.WK, l.WV = parts[0], parts[1], parts[2]
+		l.WQType, l.WKType, l.WVType = typ, typ, typ
+		bias, _ := getF32TensorOptional(gguf, prefix+"attn_qkv.bias", qDim+2*kvDim)
+		b := splitF32(bias, qDim, kvDim, kvDim)
+		l.BQ, l.BK, l.BV = b[0], b[1], b[2]
+		return nil
+	}
+
+	var err error
+	if l.WQ, l.WQType, err = getRawTensor(gguf, prefix+"attn_q.weight"); err != nil {
+		return fmt.Errorf("attn_q: %w", err)
+	}
+	if l.WK, l.WKType, err = getRawTensor(gguf, prefix+"attn_k.weight"); err != nil {
+		return fmt.Errorf("attn_k: %w", err)
+	}
+	if l.WV, l.WVType, err = getRawTensor(gguf, prefix+"attn_v.weight"); err != nil {
+		return fmt.Errorf("attn_v: %w", err)
+	}
+	l.BQ, _ = getF32TensorOptional(gguf, prefix+"attn_q.bias", qDim)
+	l.BK, _ = getF32TensorOptional(gguf, prefix+"attn_k.bias", kvDim)
+	l.BV, _ = getF32TensorOptional(gguf, prefix+"attn_v.bias", kvDim)
+	return nil
+}
+
+// loadMLP reads gate and up projections: separate, fused into ffn_up with
+// twice the rows (gate first), or up alone for a plain MLP
+func loadMLP(gguf *GGUFFile, cfg *LlamaConfig, prefix string, l *LlamaLayerWeights) error {
+	up, upType, err := getRawTensor(gguf, prefix+"ffn_up.weight")
+	if err != nil {
+		return fmt.Errorf("ffn_up: %w", err)
+	}
+	l.BUp, _ = getF32TensorOptional(gguf, prefix+"ffn_up.bias", cfg.IntermSize)
+	if gate, gateType, err := getRawTensor(gguf, prefix+"ffn_gate.weight"); err == nil {
+		l.WGate, l.WGateType, l.WUp, l.WUpType = gate, gateType, up, upType
+		return nil
+	}
+	if info := gguf.Tensors[prefix+"ffn_up.weight"]; info.NDims == 2 && int(info.Dims[1]) == 2*cfg.IntermSize {
+		parts, err := splitRows(up, upType, cfg.EmbedDim, cfg.IntermSize, cfg.IntermSize)
+		if err != nil {
+			return fmt.Errorf("ffn_up: %w", err)
+		}
+		l.WGate, l.WUp = parts[0], parts[1]
+		l.WGateType, l.WUpType = upType, upType
+		return nil
+	}
+	l.WUp, l.WUpType = up, upType
+	return nil
+}
+
+// rotatePairs rotates the first 2·len(cos) dims of vec pairwise: halves
+// (x[i], x[i+half]) or, interleaved, neighbours (x[2i], x[2i+1])
+func rotatePairs(vec, cos, sin []float32, interleaved bool) {
+	half := len(cos)
+	for i := 0; i < half; i++ {
+		a, b := i, i+half
+		if interleaved {
+			a, b = 2*i, 2*i+1
+		}
+		x0, x1 := vec[a], vec[b]
+		vec[a] = x0*cos[i] - x1*sin[i]
+		vec[b] = x0*sin[i] + x1*cos[i]
+	}
+}
+
+// mlp runs the feed-forward block on State.XB, leaving the result in State.XB
+func (m *LlamaModel) mlp(l *LlamaLayerWeights) {
+	cfg, s := &m.Config, &m.State
+	dim, interm := cfg.EmbedDim, cfg.IntermSize
+	if l.WGate != nil {
+		m.matmul(s.HB, l.WGate, l.WGateType, s.XB, interm, dim)
+		m.matmul(s.HB2, l.WUp, l.WUpType, s.XB, interm, dim)
+		addBias(s.HB2, l.BUp)
+		for i := 0; i < interm; i++ {
+			s.HB[i] = cfg.activate(s.HB[i]) * s.HB2[i]
+		}
+	} else {
+		m.matmul(s.HB, l.WUp, l.WUpType, s.XB, interm, dim)
+		addBias(s.HB, l.BUp)
+		for i := 0; i < interm; i++ {
+			s.HB[i] = cfg.activate(s.HB[i])
+		}
+	}
+	m.matmul(s.XB, l.WDown, l.WDownType, s.HB, dim, interm)
+	addBias(s.XB, l.BDown)
+}
@@ -0,0 +1,84 @@
+package yent
+
+// attention.go — Fused single-query attention over the KV cache
+//
+// Attention for one new token used to take three passes per head: scores
+// for every cached position into a seq_len row, a softmax over the row,
+// then the weighted sum of values. At 2048 positions and beyond that row
+// is written and read back twice per head per layer.
+//
+// Attend makes one pass instead, the streaming softmax of flash
+// attention: keys are visited in tiles of attnTile positions, whose scores
+// sit in a small local buffer, and the output accumulates under a running
+// maximum m and normalizer l:
+//
+//   for each tile:  m' = max(m, max score)
+//                   acc = acc·e^(m−m') + Σ e^(s−m')·v
+//                   l   = l·e^(m−m')   + Σ e^(s−m')
+//   out = acc / l
+//
+// The rescale happens once per tile rather than once per position, and
+// the result equals the three-pass softmax up to float rounding.
+
+import "math"
+
+// attnTile is the number of cached positions scored at a time
+const attnTile = 64
+
+// Attend writes softmax(q·Kᵀ·scale)·V to out for positions [0, n).
+// keys and values hold position t at t*stride; softcapLimit is applied to
+// the scaled scores as in gemma2 (0 = off).
+func Attend(out, q, keys, values []float32, n, stride int, scale, softcapLimit float32) {
+	hd := len(q)
+	for d := range out[:hd] {
+		out[d] = 0
+	}
+	var scores [attnTile]float32
+	m := float32(math.Inf(-1))
+	var l float32
+
+	for t0 := 0; t0 < n; t0 += attnTile {
+		tile := scores[:min(attnTile, n-t0)]
+		tileMax := float32(math.Inf(-1))
+		for i := range tile {
+			k := keys[(t0+i)*stride:][:hd]
+			var dot float32
+			for d, qd := range q {
+				dot += qd * k[d]
+			}
+			tile[i] = dot * scale
+		}
+		softcap(tile, softcapLimit)
+		for _, s := range tile {
+			if s > tileMax {
+				tileMax = s
+			}
+		}
+
+		if tileMax > m {
+			if l > 0 {
+				c := float32(math.Exp(float64(m - tileMax)))
+				l *= c
+				for d := range out[:hd] {
+					out[d] *= c
+				}
+			}
+			m = tileMax
+		}
+		for i, s := range tile {
+			p := float32(math.Exp(float64(s - m)))
+			l += p
+			v := values[(t0+i)*stride:][:hd]
+			for d, vd := range v {
+				out[d] += p * vd
+			}
+		}
+	}
+
+	if l > 0 {
+		inv := 1 / l
+		for d := range out[:hd] {
+			out[d] *= inv
+		}
+	}
+}
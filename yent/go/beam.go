@@ -0,0 +1,170 @@
+package yent
+
+// beam.go — Beam search: the most likely answer, not a sampled one
+//
+// Shard export and evaluation runs want what the weights believe most, the
+// same way every time. Beam search keeps the Beams best partial answers;
+// each step every beam proposes its Beams best next tokens and the best
+// Beams overall survive. Finished answers are ranked by length-normalized
+// log probability:
+//
+//   score = Σ log p / len^LengthPenalty     (1 = mean per token, small = favour long)
+//
+// Beams share the prompt's KV cache; only their generated rows are swapped
+// in before each forward pass. The AMK field sits out — its physics drives
+// sampling, and nothing is sampled here. Delta Voice, script suppression,
+// repetition penalty and logit bias still shape the distribution.
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// beam is one partial answer
+type beam struct {
+	tokens []int
+	detail []TokenLogprob // per-token log probs (Logprobs mode)
+	score  float64        // Σ log p
+	kv     *KVRows        // generated cache rows (nil = nothing generated yet)
+	next   []float32      // log probs of the following token
+}
+
+// beamScore is the length-normalized score used to rank finished beams
+func beamScore(score float64, n int, penalty float32) float64 {
+	if n == 0 {
+		return score
+	}
+	return score / math.Pow(float64(n), float64(penalty))
+}
+
+// beamSearch decodes from a prompt already in the cache; pos is the next position.
+// On cancellation the best beam so far is returned with ctx.Err().
+func (y *Yent) beamSearch(ctx context.Context, pos int, opts GenerateOptions) ([]int, []TokenLogprob, error) {
+	width := opts.Beams
+	penalty := opts.LengthPenalty
+	if penalty <= 0 {
+		penalty = 1
+	}
+	start := pos
+
+	live := []*beam{{next: y.beamLogprobs(nil, opts)}}
+	var done []*beam
+	var err error
+
+	for step := 0; step < opts.MaxTokens && start+step < y.model.Config.SeqLen; step++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+
+		type cand struct {
+			parent *beam
+			tok    int
+			lp     float32
+			score  float64
+		}
+		var cands []cand
+		for _, b := range live {
+			for _, tok := range topNIndices(b.next, width) {
+				cands = append(cands, cand{b, tok, b.next[tok], b.score + float64(b.next[tok])})
+			}
+		}
+		sort.SliceStable(cands, func(a, b int) bool { return cands[a].score > cands[b].score })
+
+		var survivors []*beam
+		for _, c := range cands {
+			if len(survivors) >= width {
+				break
+			}
+			if y.isStop(c.tok) {
+				done = append(done, &beam{tokens: c.parent.tokens, detail: c.parent.detail, score: c.score})
+				continue
+			}
+			nb := &beam{
+				tokens: append(append([]int(nil), c.parent.tokens...), c.tok),
+				score:  c.score,
+			}
+			if opts.Logprobs {
+				nb.detail = append(append([]TokenLogprob(nil), c.parent.detail...),
+					y.beamDetail(c.parent.next, c.tok, opts.TopLogprobs))
+			}
+			if c.parent.kv != nil {
+				y.model.RestoreKV(c.parent.kv)
+			}
+			y.model.Forward(c.tok, start+step)
+			nb.kv = y.model.SaveKV(start, start+step+1)
+			nb.next = y.beamLogprobs(nb.tokens, opts)
+			survivors = append(survivors, nb)
+		}
+		live = survivors
+		if len(done) >= width || len(live) == 0 {
+			break
+		}
+	}
+
+	// Out of tokens: unfinished beams compete as they are
+	if len(done) == 0 {
+		done = live
+	}
+	if len(done) == 0 {
+		return nil, nil, err
+	}
+	best := done[0]
+	for _, b := range done[1:] {
+		if beamScore(b.score, len(b.tokens), penalty) > beamScore(best.score, len(best.tokens), penalty) {
+			best = b
+		}
+	}
+	return best.tokens, best.detail, err
+}
+
+// beamLogprobs returns log-softmax of the current logits after Delta Voice,
+// script suppression, the beam's repetition penalty and logit bias
+func (y *Yent) beamLogprobs(tokens []int, opts GenerateOptions) []float32 {
+	vocab := y.model.Config.VocabSize
+	logits := append([]float32(nil), y.model.State.Logits[:vocab]...)
+
+	if delta, _ := y.deltaFor(opts.Delta); delta != nil && y.DeltaAlpha > 0 {
+		t := phaseStart()
+		delta.ApplyToLogits(logits, y.model.State.X, y.DeltaAlpha)
+		phaseEnd(phaseDelta, t)
+	}
+	if y.suppressing() {
+		for tok := range y.suppressed {
+			logits[tok] = -1e30
+		}
+	}
+	if y.RepPenalty > 1.0 {
+		recent := tokens
+		if len(recent) > y.RepWindow {
+			recent = recent[len(recent)-y.RepWindow:]
+		}
+		for _, tok := range recent {
+			if logits[tok] > 0 {
+				logits[tok] /= y.RepPenalty
+			} else {
+				logits[tok] *= y.RepPenalty
+			}
+		}
+	}
+	for tok, bias := range opts.LogitBias {
+		if tok >= 0 && tok < vocab {
+			logits[tok] += bias
+		}
+	}
+
+	logZ := logSumExp(logits)
+	for i := range logits {
+		logits[i] -= logZ
+	}
+	return logits
+}
+
+// beamDetail records a chosen token and its topN alternatives
+func (y *Yent) beamDetail(lps []float32, chosen, topN int) TokenLogprob {
+	tl := TokenLogprob{Token: chosen, Text: y.tokenizer.DecodeToken(chosen), Logprob: lps[chosen]}
+	for _, id := range topNIndices(lps, topN) {
+		tl.Top = append(tl.Top, TokenAlt{Token: id, Text: y.tokenizer.DecodeToken(id), Logprob: lps[id]})
+	}
+	return tl
+}
@@ -0,0 +1,155 @@
+package yent
+
+// bench.go — Throughput of the forward pass, without the rest of Yent
+//
+// `yent bench -weights W` loads a GGUF and times the transformer alone: no
+// tokenizer, no AMK field, no memory, no sampling beyond argmax. The prompt
+// is a fixed pseudo-random token sequence, so two runs (two builds, two
+// quant formats, SIMD on and off) see exactly the same work.
+//
+//   prefill   the prompt, one Forward per token, as generation feeds it
+//   decode    greedy continuation, one Forward per token
+//
+// Per phase it reports tokens/sec and heap allocations per token (from
+// runtime.MemStats, so the count includes anything else the process does
+// meanwhile — nothing, in the CLI). For decode it also splits the time per
+// layer and for the LM head, which is where a kernel change shows up first.
+// Load options under test (-prefetch, -stream-layers, -gpu) are the
+// caller's: run it with and without.
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// BenchConfig sizes a benchmark run
+type BenchConfig struct {
+	PrefillTokens int // prompt length (0 = 128)
+	DecodeTokens  int // tokens generated after the prompt (0 = 64)
+}
+
+// BenchPhase is the measurement of one phase
+type BenchPhase struct {
+	Tokens         int
+	Seconds        float64
+	TokensPerSec   float64
+	AllocsPerToken float64 // heap allocations
+	BytesPerToken  float64 // heap bytes allocated
+}
+
+// BenchReport is the result of RunBench
+type BenchReport struct {
+	Arch     string
+	Layers   int
+	Dim      int
+	Vocab    int
+	Quant    string // FFN weight type
+	SIMD     string
+	Threads  int
+	GPU      string // "" = CPU only
+	Prefetch bool   // LoadOptions.Prefetch
+
+	Prefill BenchPhase
+	Decode  BenchPhase
+	LayerMs []float64 // mean decode time per token, per layer
+	HeadMs  float64   // mean decode time per token in the LM head
+
+	HeadPrune *HeadPruneStats // two-stage LM head, when enabled
+}
+
+// layerClock accumulates time per layer while a benchmark runs
+type layerClock struct {
+	times []time.Duration
+	mark  time.Time
+}
+
+// lap charges the time since the last mark to layer i
+func (c *layerClock) lap(i int) {
+	now := time.Now()
+	c.times[i] += now.Sub(c.mark)
+	c.mark = now
+}
+
+// RunBench times prefill and decode on m. The KV cache is reset before
+// and left holding the benchmark's tokens.
+func RunBench(m *LlamaModel, cfg BenchConfig) (*BenchReport, error) {
+	if cfg.PrefillTokens <= 0 {
+		cfg.PrefillTokens = 128
+	}
+	if cfg.DecodeTokens <= 0 {
+		cfg.DecodeTokens = 64
+	}
+	c := &m.Config
+	if n := cfg.PrefillTokens + cfg.DecodeTokens; n > c.SeqLen {
+		return nil, fmt.Errorf("bench needs %d positions, context is %d (raise -ctx)", n, c.SeqLen)
+	}
+	r := &BenchReport{
+		Arch:     c.Arch,
+		Layers:   c.NumLayers,
+		Dim:      c.EmbedDim,
+		Vocab:    c.VocabSize,
+		SIMD:     SIMD(),
+		Threads:  Threads(),
+		GPU:      m.GPU(),
+		Prefetch: m.prefetch != nil,
+	}
+	if len(m.Weights.Layers) > 0 {
+		r.Quant = ggmlTypeName(m.Weights.Layers[0].WUpType)
+	}
+
+	m.Reset()
+	// Warm up: first touches of mmapped weights would otherwise count as prefill
+	m.Forward(1, 0)
+
+	tok := 1
+	r.Prefill = benchPhase(cfg.PrefillTokens, func(pos int) {
+		tok = (tok*7919 + 13) % c.VocabSize
+		m.Forward(tok, pos)
+	})
+
+	clock := &layerClock{times: make([]time.Duration, c.NumLayers)}
+	var head time.Duration
+	m.clock = clock
+	r.Decode = benchPhase(cfg.DecodeTokens, func(i int) {
+		pos := cfg.PrefillTokens + i
+		tok = argmax(m.State.Logits, c.VocabSize)
+		m.ForwardHidden(tok, pos)
+		start := time.Now()
+		m.logits()
+		head += time.Since(start)
+	})
+	m.clock = nil
+
+	perToken := func(d time.Duration) float64 {
+		return d.Seconds() * 1000 / float64(cfg.DecodeTokens)
+	}
+	r.LayerMs = make([]float64, c.NumLayers)
+	for i, d := range clock.times {
+		r.LayerMs[i] = perToken(d)
+	}
+	r.HeadMs = perToken(head)
+	if hs, ok := m.HeadPruneStats(); ok {
+		r.HeadPrune = &hs
+	}
+	return r, nil
+}
+
+// benchPhase runs step n times and measures it
+func benchPhase(n int, step func(i int)) BenchPhase {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		step(i)
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return BenchPhase{
+		Tokens:         n,
+		Seconds:        elapsed.Seconds(),
+		TokensPerSec:   float64(n) / elapsed.Seconds(),
+		AllocsPerToken: float64(after.Mallocs-before.Mallocs) / float64(n),
+		BytesPerToken:  float64(after.TotalAlloc-before.TotalAlloc) / float64(n),
+	}
+}
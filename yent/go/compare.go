@@ -0,0 +1,150 @@
+package yent
+
+// compare.go — The same answer with and without the delta
+//
+// Judging a delta meant two runs by hand and squinting at the outputs.
+// CompareAlpha generates the prompt twice with the same seed and a reset
+// field, once at alpha 0 and once at the given alpha, so every difference
+// between the answers is the delta's doing. Until the delta tips a draw,
+// both runs pick the same tokens; the report says where they part, how
+// likely the voiced token was without the delta, and how much of the two
+// answers still agrees (longest common token subsequence).
+//
+// REPL: /compare [alpha] <prompt>; HTTP: POST /v1/compare.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TokenDivergence is one position of two compared answers
+type TokenDivergence struct {
+	Pos          int     `json:"pos"`
+	Base         string  `json:"base"`
+	Voice        string  `json:"voice"`
+	BaseLogprob  float32 `json:"base_logprob"`
+	VoiceLogprob float32 `json:"voice_logprob"`
+	// VoiceRank is where the alpha-0 run ranked the voiced token among its
+	// top alternatives (1 = first; 0 = not among them)
+	VoiceRank int `json:"voice_rank"`
+}
+
+// AlphaComparison is one prompt answered at alpha 0 and at Alpha
+type AlphaComparison struct {
+	Alpha         float32         `json:"alpha"`
+	Seed          int64           `json:"seed"`
+	Base          *GenerateResult `json:"base"`  // alpha 0
+	Voice         *GenerateResult `json:"voice"` // alpha
+	BaseLanguage  string          `json:"base_language"`
+	VoiceLanguage string          `json:"voice_language"`
+
+	// FirstDiff is the first token where the answers differ (-1 = identical)
+	FirstDiff  int               `json:"first_diff"`
+	Similarity float32           `json:"similarity"` // common token subsequence / longer answer
+	Divergence []TokenDivergence `json:"divergence,omitempty"`
+}
+
+// compareTop is how many alternatives each run records per token
+const compareTop = 10
+
+// CompareAlpha answers prompt at alpha 0 and at alpha with opts (seed
+// opts.Seed, 1 when 0; field reset, nothing stored) and reports where and
+// how the answers diverge
+func (y *Yent) CompareAlpha(ctx context.Context, prompt string, alpha float32, opts GenerateOptions) (*AlphaComparison, error) {
+	if opts.Seed == 0 {
+		opts.Seed = 1
+	}
+	opts.Deterministic, opts.NoStore = true, true
+	opts.Logprobs, opts.TopLogprobs = true, max(opts.TopLogprobs, compareTop)
+	opts.AutoLanguage, opts.Session, opts.OnToken = false, nil, nil
+
+	cmp := &AlphaComparison{Alpha: alpha, Seed: opts.Seed}
+	var err error
+	zero := float32(0)
+	opts.Alpha = &zero
+	if cmp.Base, err = y.GenerateContext(ctx, prompt, opts); err != nil {
+		return nil, fmt.Errorf("alpha 0: %w", err)
+	}
+	opts.Alpha = &alpha
+	if cmp.Voice, err = y.GenerateContext(ctx, prompt, opts); err != nil {
+		return nil, fmt.Errorf("alpha %.2f: %w", alpha, err)
+	}
+	cmp.BaseLanguage, _ = DetectLanguage(cmp.Base.Text)
+	cmp.VoiceLanguage, _ = DetectLanguage(cmp.Voice.Text)
+
+	base, voice := cmp.Base.Tokens, cmp.Voice.Tokens
+	cmp.FirstDiff = -1
+	for i := 0; i < max(len(base), len(voice)); i++ {
+		if i >= len(base) || i >= len(voice) || base[i].Token != voice[i].Token {
+			cmp.FirstDiff = i
+			break
+		}
+	}
+	if longer := max(len(base), len(voice)); longer > 0 {
+		cmp.Similarity = float32(commonSubsequence(base, voice)) / float32(longer)
+	} else {
+		cmp.Similarity = 1
+	}
+
+	// Position by position from the parting, while both answers last
+	for i := cmp.FirstDiff; i >= 0 && i < min(len(base), len(voice)) && len(cmp.Divergence) < 8; i++ {
+		d := TokenDivergence{Pos: i, Base: base[i].Text, Voice: voice[i].Text,
+			BaseLogprob: base[i].Logprob, VoiceLogprob: voice[i].Logprob}
+		for r, alt := range base[i].Top {
+			if alt.Token == voice[i].Token {
+				d.VoiceRank = r + 1
+				break
+			}
+		}
+		cmp.Divergence = append(cmp.Divergence, d)
+	}
+	return cmp, nil
+}
+
+// commonSubsequence is the length of the longest common subsequence of
+// the two token sequences
+func commonSubsequence(a, b []TokenLogprob) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i].Token == b[j].Token:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// String formats the comparison for the terminal
+func (c *AlphaComparison) String() string {
+	var b strings.Builder
+	lang := func(l string) string {
+		if l == "" {
+			return "?"
+		}
+		return l
+	}
+	fmt.Fprintf(&b, "  alpha 0    [%s] %s\n", lang(c.BaseLanguage), c.Base.Text)
+	fmt.Fprintf(&b, "  alpha %-4.2f [%s] %s\n", c.Alpha, lang(c.VoiceLanguage), c.Voice.Text)
+	if c.FirstDiff < 0 {
+		fmt.Fprintf(&b, "  identical (%d tokens, seed %d)", len(c.Base.Tokens), c.Seed)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "  same first %d tokens, then apart; similarity %.2f (seed %d)", c.FirstDiff, c.Similarity, c.Seed)
+	for _, d := range c.Divergence {
+		rank := "not in the top"
+		if d.VoiceRank > 0 {
+			rank = fmt.Sprintf("#%d", d.VoiceRank)
+		}
+		fmt.Fprintf(&b, "\n    %4d  %-14q %6.2f   %-14q %6.2f   (%s at alpha 0)",
+			d.Pos, d.Base, d.BaseLogprob, d.Voice, d.VoiceLogprob, rank)
+	}
+	return b.String()
+}
@@ -0,0 +1,174 @@
+package yent
+
+// config.go — Profiles: one flag per device class
+//
+// A Raspberry Pi wants the 0.5B weights, four threads, a short context and
+// a lean memory budget; a server wants the opposite. A profile bundles
+// command-line settings under a name, so `-profile rpi` replaces a dozen
+// hand-tuned flags. Flags given explicitly still win.
+//
+// ~/.yent/config.json:
+//
+//   {
+//     "profile": "prod",                       default when -profile is absent
+//     "profiles": {
+//       "rpi":  {"threads": 2, "ctx": 512},    overrides the built-in rpi
+//       "bench": {"max": 64, "seed": 1}        new profile
+//     }
+//   }
+//
+// Keys are flag names without the dash; values are JSON scalars. A file
+// profile with a built-in name is merged over the built-in.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Profile maps flag names to values
+type Profile map[string]interface{}
+
+// Config is the parsed config file
+type Config struct {
+	Path     string             `json:"-"`
+	Profile  string             `json:"profile"`  // default profile
+	Profiles map[string]Profile `json:"profiles"` // user profiles (merged over built-ins)
+}
+
+// BuiltinProfiles returns the profiles shipped with Yent
+func BuiltinProfiles() map[string]Profile {
+	return map[string]Profile{
+		// Reproducible runs with memory on: debugging, evaluation
+		"dev": {
+			"seed":       42,
+			"rag":        true,
+			"rag-check":  "heuristic",
+			"max":        256,
+			"top-k":      50,
+			"rag-budget": 384,
+		},
+		// Full model, full context, memory with answerability checks
+		"prod": {
+			"weights":    "~/.yent/models/yent_1.5B_step1000_q4_0.gguf",
+			"delta":      "deltas/yent_1.5b_delta_r64.npz",
+			"ctx":        2048,
+			"rag":        true,
+			"rag-check":  "heuristic",
+			"rag-budget": 384,
+		},
+		// Raspberry Pi class: 0.5B, 4 cores, short context, lean memory
+		"rpi": {
+			"weights":      "~/.yent/models/yent_0.5B_step1000_q4_0.gguf",
+			"delta":        "deltas/yent_05b_delta_r64.npz",
+			"threads":      4,
+			"ctx":          1024,
+			"max":          128,
+			"rag":          true,
+			"rag-budget":   160,
+			"rag-check":    "heuristic",
+			"rag-template": "notes",
+		},
+	}
+}
+
+// DefaultConfigPath returns ~/.yent/config.json
+func DefaultConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("home dir: %w", err)
+	}
+	return filepath.Join(homeDir, ".yent", "config.json"), nil
+}
+
+// LoadConfig reads a config file ("" = default path). A missing file yields
+// an empty config — built-in profiles still resolve.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		p, err := DefaultConfigPath()
+		if err != nil {
+			return nil, err
+		}
+		path = p
+	}
+	cfg := &Config{Path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ProfileNames lists built-in and configured profiles
+func (c *Config) ProfileNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for name := range BuiltinProfiles() {
+		seen[name] = true
+		names = append(names, name)
+	}
+	for name := range c.Profiles {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the named profile's settings as flag strings
+// ("" = the config's default profile; none = empty settings)
+func (c *Config) Resolve(name string) (map[string]string, error) {
+	if name == "" {
+		name = c.Profile
+	}
+	if name == "" {
+		return map[string]string{}, nil
+	}
+	merged := Profile{}
+	builtin, isBuiltin := BuiltinProfiles()[name]
+	for k, v := range builtin {
+		merged[k] = v
+	}
+	user, isUser := c.Profiles[name]
+	for k, v := range user {
+		merged[k] = v
+	}
+	if !isBuiltin && !isUser {
+		return nil, fmt.Errorf("unknown profile %q (have: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	settings := make(map[string]string, len(merged))
+	for k, v := range merged {
+		switch val := v.(type) {
+		case string:
+			settings[k] = expandHome(val)
+		case float64, bool, int:
+			settings[k] = fmt.Sprint(val)
+		default:
+			return nil, fmt.Errorf("profile %q: %s must be a string, number or bool", name, k)
+		}
+	}
+	return settings, nil
+}
+
+// expandHome resolves a leading ~/ to the home directory
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, path[2:])
+}
@@ -0,0 +1,274 @@
+package yent
+
+// contamination.go — Seed dataset overlap check for exported shards
+//
+// Experience is only worth retraining on if it is new. When a user asks
+// "Who are you?" and Yent answers from the biography, LIMPHA remembers it —
+// and the next finetune run learns the seed dataset a second time. Step 1500
+// already showed what over-memorization looks like.
+//
+// Prompts are normalized (lowercase, letters and digits only, single spaces)
+// and hashed. A shard pair is flagged when its prompt hash appears in the
+// seed dataset; "exact" when the answer matches too. Shards are read the
+// same way, so every ShardConfig.Format can be checked.
+//
+// Seed dataset formats (auto-detected per file):
+//   jsonl   {"question","answer"} | {"prompt","response"} | {"instruction","output"}
+//           | {"messages":[{"role","content"}...]}
+//           | {"conversations":[{"from","value"}...]}
+//   text    ### Question: ... ### Answer: ... blocks (the training template)
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// SeedIndex holds normalized hashes of a training dataset
+type SeedIndex struct {
+	Path    string
+	Prompts map[string]bool // hash(prompt)
+	Pairs   map[string]bool // hash(prompt, answer)
+}
+
+// ContaminationHit is one shard pair found in the seed dataset
+type ContaminationHit struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	ID       int64  `json:"id"`
+	Question string `json:"question"`
+	Exact    bool   `json:"exact"` // answer matches too
+}
+
+// ContaminationReport summarizes an overlap check
+type ContaminationReport struct {
+	Dataset     string             `json:"dataset"`
+	SeedPrompts int                `json:"seed_prompts"`
+	Checked     int                `json:"checked"`
+	Flagged     int                `json:"flagged"`
+	Exact       int                `json:"exact"`
+	Rate        float64            `json:"rate"`
+	Hits        []ContaminationHit `json:"hits"`
+}
+
+// seedRow covers the field names training datasets use for pairs
+type seedRow struct {
+	Question    string `json:"question"`
+	Answer      string `json:"answer"`
+	Prompt      string `json:"prompt"`
+	Response    string `json:"response"`
+	Instruction string `json:"instruction"`
+	Output      string `json:"output"`
+	Messages    []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+	Conversations []struct {
+		From  string `json:"from"`
+		Value string `json:"value"`
+	} `json:"conversations"`
+	ID int64 `json:"id"` // finetune_v2 shards only
+}
+
+// pair returns the first question/answer found in the row
+func (r seedRow) pair() (string, string) {
+	switch {
+	case r.Question != "":
+		return r.Question, r.Answer
+	case r.Prompt != "":
+		return r.Prompt, r.Response
+	case r.Instruction != "":
+		return r.Instruction, r.Output
+	}
+	var q, a string
+	for _, m := range r.Messages {
+		if m.Role == "user" && q == "" {
+			q = m.Content
+		} else if m.Role == "assistant" && q != "" && a == "" {
+			a = m.Content
+		}
+	}
+	for _, m := range r.Conversations {
+		if m.From == "human" && q == "" {
+			q = m.Value
+		} else if m.From == "gpt" && q != "" && a == "" {
+			a = m.Value
+		}
+	}
+	return q, a
+}
+
+// LoadSeedIndex hashes every prompt in a training dataset (jsonl or template text)
+func LoadSeedIndex(path string) (*SeedIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read seed dataset: %w", err)
+	}
+
+	idx := &SeedIndex{
+		Path:    path,
+		Prompts: make(map[string]bool),
+		Pairs:   make(map[string]bool),
+	}
+	add := func(q, a string) {
+		if strings.TrimSpace(q) == "" {
+			return
+		}
+		idx.Prompts[promptKey(q)] = true
+		idx.Pairs[contaminationPairKey(q, a)] = true
+	}
+
+	text := string(data)
+	if strings.Contains(text, "### Question:") {
+		for _, block := range strings.Split(text, "### Question:")[1:] {
+			q, a, _ := strings.Cut(block, "### Answer:")
+			add(q, a)
+		}
+		return idx, nil
+	}
+
+	sc := bufio.NewScanner(strings.NewReader(text))
+	sc.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var row seedRow
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			return nil, fmt.Errorf("seed dataset line %d: %w", lineNo, err)
+		}
+		add(row.pair())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("scan seed dataset: %w", err)
+	}
+	return idx, nil
+}
+
+// Check reports whether a pair's prompt (and whole pair) is in the seed dataset
+func (idx *SeedIndex) Check(question, answer string) (prompt, exact bool) {
+	if !idx.Prompts[promptKey(question)] {
+		return false, false
+	}
+	return true, idx.Pairs[contaminationPairKey(question, answer)]
+}
+
+// CheckContamination checks every *.jsonl shard in dir against a seed dataset
+// and writes contamination.json next to them
+func CheckContamination(dir, datasetPath string) (*ContaminationReport, error) {
+	idx, err := LoadSeedIndex(datasetPath)
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("list shards: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no shard files in %s", dir)
+	}
+
+	report := &ContaminationReport{
+		Dataset:     datasetPath,
+		SeedPrompts: len(idx.Prompts),
+		Hits:        []ContaminationHit{},
+	}
+	for _, path := range files {
+		if err := checkShardFile(idx, path, report); err != nil {
+			return nil, err
+		}
+	}
+	if report.Checked > 0 {
+		report.Rate = float64(report.Flagged) / float64(report.Checked)
+	}
+
+	if err := writeJSONFile(filepath.Join(dir, "contamination.json"), report); err != nil {
+		return nil, err
+	}
+	fmt.Printf("[shards] contamination: %d/%d pairs in seed dataset (%d exact)\n",
+		report.Flagged, report.Checked, report.Exact)
+	return report, nil
+}
+
+// checkShardFile adds one shard file's overlaps to the report
+func checkShardFile(idx *SeedIndex, path string, report *ContaminationReport) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open shard: %w", err)
+	}
+	defer f.Close()
+
+	name := filepath.Base(path)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		// Any export format (ShardConfig.Format) reads as a seed row
+		var rec seedRow
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return fmt.Errorf("%s line %d: %w", name, lineNo, err)
+		}
+		question, answer := rec.pair()
+		report.Checked++
+		hit, exact := idx.Check(question, answer)
+		if !hit {
+			continue
+		}
+		report.Flagged++
+		if exact {
+			report.Exact++
+		}
+		report.Hits = append(report.Hits, ContaminationHit{
+			File:     name,
+			Line:     lineNo,
+			ID:       rec.ID,
+			Question: question,
+			Exact:    exact,
+		})
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("scan %s: %w", name, err)
+	}
+	return nil
+}
+
+// normalizeSeedText lowercases and keeps letters/digits, words separated by one space.
+// Punctuation and whitespace differences between dataset and live prompts vanish.
+func normalizeSeedText(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		} else {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// promptKey hashes a normalized prompt
+func promptKey(prompt string) string {
+	sum := sha256.Sum256([]byte(normalizeSeedText(prompt)))
+	return hex.EncodeToString(sum[:])
+}
+
+// contaminationPairKey hashes a normalized prompt and answer
+func contaminationPairKey(prompt, answer string) string {
+	sum := sha256.Sum256([]byte(normalizeSeedText(prompt) + "\x00" + normalizeSeedText(answer)))
+	return hex.EncodeToString(sum[:])
+}
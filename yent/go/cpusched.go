@@ -0,0 +1,120 @@
+package yent
+
+// cpusched.go — Keeping generation on its cores
+//
+// Two process-wide knobs, both off by default:
+//
+// SetAffinity pins each matmul worker (workers.go) to a physical core, one
+// logical CPU per core so two workers never share a core's SMT siblings.
+// The generating goroutine itself stays free and the first core is left
+// to it. Without pinning the scheduler migrates workers between cores
+// mid-token, and on a 4-core board a woken background thread can land on
+// the core a worker was using.
+//
+// SetBackgroundNice lowers the priority of the work that is not on the
+// token path: the LIMPHA daemon (renice'd when it starts: its storage,
+// search, backup and vacuum) and the maintenance jobs (maintenance.go),
+// which run on an OS thread of their own that is discarded afterwards —
+// an unprivileged process can lower a thread's priority but not raise it
+// back. When consolidation kicks in at 03:00, generation keeps the CPU.
+// Delta Voice is not background work: it runs inline between two tokens.
+//
+// Both use Linux interfaces (sched_setaffinity, per-thread setpriority);
+// elsewhere SetAffinity reports an error and the nice level is ignored.
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+var (
+	// pinnedCores is the core list workers pin to (nil = unpinned)
+	pinnedCores atomic.Pointer[[]int]
+	// affinityGen changes on every SetAffinity, so workers re-pin
+	affinityGen atomic.Int64
+	// backgroundNice is the nice level of background work (0 = unchanged)
+	backgroundNice atomic.Int32
+)
+
+// SetAffinity pins the matmul workers to physical cores (on) or lets them
+// float again (off); returns the logical CPUs used, one per core
+func SetAffinity(on bool) ([]int, error) {
+	if !on {
+		pinnedCores.Store(nil)
+		affinityGen.Add(1)
+		return nil, nil
+	}
+	cores, err := physicalCores()
+	if err != nil {
+		return nil, err
+	}
+	pinnedCores.Store(&cores)
+	affinityGen.Add(1)
+	return cores, nil
+}
+
+// Affinity returns the cores the workers are pinned to (nil = unpinned)
+func Affinity() []int {
+	if p := pinnedCores.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// SetBackgroundNice sets the nice level (1-19, 0 = off) of the LIMPHA
+// daemons started afterwards and of maintenance job runs
+func SetBackgroundNice(n int) {
+	backgroundNice.Store(int32(max(0, min(n, 19))))
+}
+
+// BackgroundNice returns the nice level of background work
+func BackgroundNice() int {
+	return int(backgroundNice.Load())
+}
+
+// workerPin is a worker's view of the pinning it applied
+type workerPin struct {
+	gen    int64
+	locked bool
+}
+
+// apply brings worker i's thread to the current pinning; called by the
+// worker goroutine before each task
+func (w *workerPin) apply(i int) {
+	g := affinityGen.Load()
+	if g == w.gen {
+		return
+	}
+	w.gen = g
+	if cores := Affinity(); cores != nil {
+		if !w.locked {
+			runtime.LockOSThread()
+			w.locked = true
+		}
+		// cores[0] is left to the generating goroutine
+		pinThread(cores[(i+1)%len(cores)])
+		return
+	}
+	if w.locked {
+		unpinThread()
+		runtime.UnlockOSThread()
+		w.locked = false
+	}
+}
+
+// runBackground runs fn at the background nice level, on a locked thread
+// that exits with it; without a nice level fn runs on the caller
+func runBackground(fn func() error) error {
+	n := BackgroundNice()
+	if n == 0 {
+		return fn()
+	}
+	done := make(chan error, 1)
+	go func() {
+		// Never unlocked: the reniced thread dies with the goroutine
+		runtime.LockOSThread()
+		setThreadNice(n)
+		done <- fn()
+	}()
+	return <-done
+}
@@ -0,0 +1,98 @@
+//go:build linux
+
+package yent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// cpuMask is a sched_setaffinity bitmap for up to 1024 CPUs
+type cpuMask [16]uint64
+
+// processMask is the affinity the process started with, restored on unpin
+var processMask = func() *cpuMask {
+	var m cpuMask
+	_, _, e := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, unsafe.Sizeof(m), uintptr(unsafe.Pointer(&m)))
+	if e != 0 {
+		return nil
+	}
+	return &m
+}()
+
+// physicalCores lists the first logical CPU of each physical core the
+// process may run on, from sysfs topology
+func physicalCores() ([]int, error) {
+	paths, err := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/topology/thread_siblings_list")
+	if err != nil || len(paths) == 0 {
+		return nil, fmt.Errorf("cpu topology not available in /sys")
+	}
+	first := map[string]int{} // siblings list → lowest CPU in it
+	for _, p := range paths {
+		name := filepath.Base(filepath.Dir(filepath.Dir(p)))
+		cpu, err := strconv.Atoi(strings.TrimPrefix(name, "cpu"))
+		if err != nil || cpu >= len(cpuMask{})*64 {
+			continue
+		}
+		if m := processMask; m != nil && m[cpu/64]&(1<<(cpu%64)) == 0 {
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		key := strings.TrimSpace(string(data))
+		if c, ok := first[key]; !ok || cpu < c {
+			first[key] = cpu
+		}
+	}
+	if len(first) == 0 {
+		return nil, fmt.Errorf("no usable cpu in /sys topology")
+	}
+	cores := make([]int, 0, len(first))
+	for _, cpu := range first {
+		cores = append(cores, cpu)
+	}
+	sort.Ints(cores)
+	return cores, nil
+}
+
+// setAffinity applies m to the calling thread
+func setAffinity(m *cpuMask) error {
+	_, _, e := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(*m), uintptr(unsafe.Pointer(m)))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// pinThread binds the calling thread to one CPU
+func pinThread(cpu int) error {
+	var m cpuMask
+	m[cpu/64] = 1 << (cpu % 64)
+	return setAffinity(&m)
+}
+
+// unpinThread gives the calling thread the process affinity back
+func unpinThread() error {
+	if processMask == nil {
+		return nil
+	}
+	return setAffinity(processMask)
+}
+
+// setThreadNice sets the calling thread's nice level
+func setThreadNice(n int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, syscall.Gettid(), n)
+}
+
+// reniceProcess sets the nice level of a child process
+func reniceProcess(pid, n int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, pid, n)
+}
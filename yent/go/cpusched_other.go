@@ -0,0 +1,20 @@
+//go:build !linux
+
+package yent
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func physicalCores() ([]int, error) {
+	return nil, fmt.Errorf("cpu pinning is not supported on %s", runtime.GOOS)
+}
+
+func pinThread(cpu int) error { return nil }
+
+func unpinThread() error { return nil }
+
+func setThreadNice(n int) error { return nil }
+
+func reniceProcess(pid, n int) error { return nil }
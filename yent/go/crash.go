@@ -0,0 +1,163 @@
+package yent
+
+// crash.go — A panic costs one answer, not the process
+//
+// A bad tensor, a tokenizer edge case or a grammar bug used to take down the
+// whole bot mid-sentence. The token loop now recovers: the panic becomes an
+// error for that one call, and a diagnostic bundle lands in ~/.yent/crashes/
+// with everything needed to replay it — without the prompt itself, which is
+// only hashed:
+//
+//   crash-20260114-093012.481.json
+//     panic, stack, prompt sha256 + length, phase (prompt/decode), position,
+//     last tokens (ids + text), AMK field state, sampling options, model shape
+//
+// The model lock is released and the next call starts from a clean cache
+// (generate always resets), so other sessions keep being served.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// ErrGenerationPanic is wrapped by the error returned when the token loop panics
+var ErrGenerationPanic = errors.New("generation panicked")
+
+// crashLastTokens is how many recent tokens a crash report keeps
+const crashLastTokens = 32
+
+// genTrace is where the token loop is, kept current for crash reports
+type genTrace struct {
+	phase  string // "prompt" or "decode"
+	pos    int
+	tokens []int // prompt tokens, then generated tokens
+}
+
+// CrashReport is the diagnostic bundle written on a generation panic
+type CrashReport struct {
+	Time         time.Time `json:"time"`
+	Panic        string    `json:"panic"`
+	Stack        string    `json:"stack"`
+	PromptSHA256 string    `json:"prompt_sha256"`
+	PromptBytes  int       `json:"prompt_bytes"`
+	Phase        string    `json:"phase"`
+	Position     int       `json:"position"`
+	LastTokens   []int     `json:"last_tokens"`
+	LastText     string    `json:"last_text"`
+	AMK          AMState   `json:"amk"`
+	Options      struct {
+		MaxTokens   int     `json:"max_tokens"`
+		Temperature float32 `json:"temperature"`
+		TopP        float32 `json:"top_p"`
+		TopK        int     `json:"top_k"`
+		Beams       int     `json:"beams,omitempty"`
+		Samplers    string  `json:"samplers,omitempty"`
+		MirostatTau float32 `json:"mirostat_tau,omitempty"`
+		Grammar     bool    `json:"grammar,omitempty"`
+		Seed        int64   `json:"seed,omitempty"`
+		DeltaAlpha  float32 `json:"delta_alpha"`
+	} `json:"options"`
+	Model struct {
+		Layers int `json:"layers"`
+		Dim    int `json:"dim"`
+		Vocab  int `json:"vocab"`
+		SeqLen int `json:"seq_len"`
+	} `json:"model"`
+	GoVersion string `json:"go_version"`
+}
+
+// crashDir returns CrashDir or ~/.yent/crashes
+func (y *Yent) crashDir() (string, error) {
+	if y.CrashDir != "" {
+		return y.CrashDir, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("home dir: %w", err)
+	}
+	return filepath.Join(homeDir, ".yent", "crashes"), nil
+}
+
+// recoverCrash turns a recovered panic into an error, writing a crash report
+func (y *Yent) recoverCrash(r interface{}, prompt string, opts GenerateOptions, trace *genTrace) error {
+	rep := CrashReport{
+		Time:        time.Now(),
+		Panic:       fmt.Sprint(r),
+		Stack:       string(debug.Stack()),
+		PromptBytes: len(prompt),
+		Phase:       trace.phase,
+		Position:    trace.pos,
+		GoVersion:   runtime.Version(),
+	}
+	sum := sha256.Sum256([]byte(prompt))
+	rep.PromptSHA256 = hex.EncodeToString(sum[:])
+
+	last := trace.tokens
+	if len(last) > crashLastTokens {
+		last = last[len(last)-crashLastTokens:]
+	}
+	rep.LastTokens = append([]int(nil), last...)
+	if y.tokenizer != nil {
+		for _, tok := range rep.LastTokens {
+			if tok >= 0 && tok < y.tokenizer.VocabSize {
+				rep.LastText += y.tokenizer.DecodeToken(tok)
+			}
+		}
+	}
+	if y.amk != nil {
+		rep.AMK = y.amk.GetState()
+	}
+	rep.Options.MaxTokens = opts.MaxTokens
+	rep.Options.Temperature = opts.Temperature
+	rep.Options.TopP = opts.TopP
+	rep.Options.TopK = opts.TopK
+	rep.Options.Beams = opts.Beams
+	rep.Options.Samplers = opts.Samplers.String()
+	rep.Options.MirostatTau = opts.MirostatTau
+	rep.Options.Grammar = opts.Grammar != nil
+	rep.Options.Seed = opts.Seed
+	rep.Options.DeltaAlpha = y.DeltaAlpha
+	if y.model != nil {
+		rep.Model.Layers = y.model.Config.NumLayers
+		rep.Model.Dim = y.model.Config.EmbedDim
+		rep.Model.Vocab = y.model.Config.VocabSize
+		rep.Model.SeqLen = y.model.Config.SeqLen
+	}
+
+	path, err := y.writeCrashReport(&rep)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[crash] %v at %s pos %d (report not written: %v)\n", r, trace.phase, trace.pos, err)
+		return fmt.Errorf("%w: %v", ErrGenerationPanic, r)
+	}
+	fmt.Fprintf(os.Stderr, "[crash] %v at %s pos %d — report: %s\n", r, trace.phase, trace.pos, path)
+	return fmt.Errorf("%w: %v (report: %s)", ErrGenerationPanic, r, path)
+}
+
+// writeCrashReport saves rep as JSON and returns its path
+func (y *Yent) writeCrashReport(rep *CrashReport) (string, error) {
+	dir, err := y.crashDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create crash dir: %w", err)
+	}
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode crash report: %w", err)
+	}
+	name := "crash-" + rep.Time.Format("20060102-150405.000") + ".json"
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("write crash report: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,118 @@
+package yent
+
+// degeneration.go — Pain from the model's own failure modes
+//
+// The kernel dampens logits in proportion to pain and tension, but
+// nothing the model did ever raised them: an answer stuck in "I am I am
+// I am" hurt no more than a good one. With Yent.Degeneration set, every
+// generated token is checked against the tokens before it:
+//
+//   loop     the last n tokens repeat the n before them (n = 2..16), or
+//            one token four times running: each looping token adds Pain
+//   flat     sampling entropy under EntropyFloor for Streak tokens in a
+//            row: each further token adds Tension
+//
+// The increments land in the kernel before the next token, where the
+// suffering step turns them into dampening; the kernel's own decay lets
+// them fade once the answer moves on. Greedy draws (temperature 0) report
+// no entropy and do not count toward streaks. GenerateResult.Degenerate
+// counts the looping tokens. CLI: -degeneration.
+
+const (
+	degenMaxPeriod = 16 // longest repeated n-gram looked for
+	degenWindow    = 2 * degenMaxPeriod
+)
+
+// Degeneration sets how loops and flat streaks hurt (zero = off)
+type Degeneration struct {
+	Pain         float32 // added per looping token
+	Tension      float32 // added per token past a low-entropy streak
+	EntropyFloor float32 // nats under which a token is flat (0 = 0.3)
+	Streak       int     // flat tokens before tension builds (0 = 8)
+}
+
+// DefaultDegeneration is what -degeneration turns on
+var DefaultDegeneration = Degeneration{Pain: 0.05, Tension: 0.03}
+
+// on reports whether degeneration feeds the kernel
+func (d Degeneration) on() bool { return d.Pain > 0 || d.Tension > 0 }
+
+// degenSense follows one generation's tail
+type degenSense struct {
+	cfg     Degeneration
+	recent  []int // last degenWindow tokens
+	flat    int   // current low-entropy streak
+	looping int   // looping tokens so far
+}
+
+// newDegenSense starts watching (nil when off)
+func newDegenSense(cfg Degeneration) *degenSense {
+	if !cfg.on() {
+		return nil
+	}
+	if cfg.EntropyFloor <= 0 {
+		cfg.EntropyFloor = 0.3
+	}
+	if cfg.Streak <= 0 {
+		cfg.Streak = 8
+	}
+	return &degenSense{cfg: cfg, recent: make([]int, 0, degenWindow)}
+}
+
+// step takes a sampled token and its entropy (sampled marks a real draw)
+// and returns the pain and tension it adds
+func (d *degenSense) step(tok int, entropy float32, sampled bool) (pain, tension float32) {
+	if d == nil {
+		return 0, 0
+	}
+	if len(d.recent) == degenWindow {
+		copy(d.recent, d.recent[1:])
+		d.recent = d.recent[:degenWindow-1]
+	}
+	d.recent = append(d.recent, tok)
+	if loopPeriod(d.recent) > 0 {
+		d.looping++
+		pain = d.cfg.Pain
+	}
+
+	if sampled && entropy < d.cfg.EntropyFloor {
+		d.flat++
+	} else if sampled {
+		d.flat = 0
+	}
+	if d.flat > d.cfg.Streak {
+		tension = d.cfg.Tension
+	}
+	return pain, tension
+}
+
+// loops returns how many tokens continued a loop
+func (d *degenSense) loops() int {
+	if d == nil {
+		return 0
+	}
+	return d.looping
+}
+
+// loopPeriod returns the period of a loop the tokens end in (0 = none):
+// the smallest n ≥ 2 whose last n tokens repeat the n before them, or 1
+// when the last four tokens are one
+func loopPeriod(toks []int) int {
+	k := len(toks)
+	if k >= 4 && toks[k-1] == toks[k-2] && toks[k-2] == toks[k-3] && toks[k-3] == toks[k-4] {
+		return 1
+	}
+	for n := 2; n <= degenMaxPeriod && 2*n <= k; n++ {
+		repeat := true
+		for j := 1; j <= n; j++ {
+			if toks[k-j] != toks[k-j-n] {
+				repeat = false
+				break
+			}
+		}
+		if repeat {
+			return n
+		}
+	}
+	return 0
+}
@@ -12,12 +12,18 @@ package yent
 //   alpha = 0.5 → Yent + multilingual (29 languages)
 //   alpha = 1.0 → base Qwen distribution (no personality)
 //
-// The delta is stored as NPZ (numpy compressed) with float16 A and B matrices.
+// The delta is stored as NPZ (numpy compressed) with float16 A and B matrices,
+// or as a GGUF delta that also records its provenance (deltagguf.go).
+// A PEFT LoRA adapter on lm_head loads as a delta too (lora.go).
 // A: [vocab_size, rank]   — output projection
 // B: [rank, hidden_dim]   — input projection
 //
 // Cost per token: rank × (vocab + hidden) FMA ops ≈ 10M for rank=64
 // This is ~2% of a full forward pass. Negligible.
+//
+// A and B stay float16 in memory, as in the file: a rank-64 delta for
+// Qwen's 151936 tokens is ~20 MB instead of ~40. Both products run through
+// the F16 matmul kernels (hardware conversion with SIMD, threaded).
 
 import (
 	"archive/zip"
@@ -25,7 +31,9 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"os"
 	"strings"
+	"time"
 )
 
 // DeltaVoice holds the low-rank delta for multilingual recovery
@@ -33,27 +41,84 @@ type DeltaVoice struct {
 	VocabSize int
 	HiddenDim int
 	Rank      int
+	Path      string    // file it was loaded from
+	ModTime   time.Time // its mtime when loaded (ReloadDelta)
+	Meta      DeltaMeta // provenance (GGUF deltas only)
+
+	Mix []DeltaWeight // the parts of a mix (deltamix.go); nil = loaded from Path
+
+	// A: [VocabSize × Rank], float16 little-endian (float32 files are
+	// converted on load); after Sparsify only the kept rows, in Rows order
+	A []byte
+	// Rows: token id of each row of A when sparse (deltasparse.go); nil = all
+	Rows []int32
+	// B: [Rank × HiddenDim], float16 little-endian
+	B []byte
+
+	// Scratch buffers for B @ x and A @ Bx
+	Bx  []float32 // [Rank]
+	ABx []float32 // [VocabSize]
+
+	// Optional input side: the embedding table shifted by EmbA @ EmbB
+	// (deltaembed.go). EmbRank 0 = logits only.
+	EmbRank int
+	EmbA    []byte // [VocabSize × EmbRank], float16
+	EmbB    []byte // [EmbRank × HiddenDim], float16
+}
 
-	// A: [VocabSize × Rank] stored as float32 (converted from float16 on load)
-	A []float32
-	// B: [Rank × HiddenDim] stored as float32
-	B []float32
-
-	// Scratch buffer for B @ x computation
-	Bx []float32 // [Rank]
+// ValidateDelta loads a delta and checks it fits the model in weightsPath
+// (shape and recorded fingerprint, deltacheck.go), reading only the GGUF
+// metadata
+func ValidateDelta(deltaPath, weightsPath string) (*DeltaVoice, error) {
+	g, err := LoadGGUFMetadata(weightsPath)
+	if err != nil {
+		return nil, fmt.Errorf("load GGUF metadata: %w", err)
+	}
+	d, err := LoadDelta(deltaPath)
+	if err != nil {
+		return nil, fmt.Errorf("load delta: %w", err)
+	}
+	if _, err := d.CheckBase(&g.Meta); err != nil {
+		return nil, err
+	}
+	return d, nil
 }
 
-// LoadDelta loads a delta voice file from NPZ format
-// Expected entries: A.npy, B.npy (float16, C-order)
+// LoadDelta loads a delta voice file, GGUF or NPZ, or a PEFT LoRA
+// adapter (lora.go)
 func LoadDelta(path string) (*DeltaVoice, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("open delta: %w", err)
+	}
+	var d *DeltaVoice
+	switch {
+	case isLoRAPath(path):
+		d, err = loadDeltaLoRA(path)
+	case isGGUFFile(path):
+		d, err = loadDeltaGGUF(path)
+	default:
+		d, err = loadDeltaNPZ(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.ModTime = st.ModTime()
+	return d, nil
+}
+
+// loadDeltaNPZ loads a delta voice file from NPZ format
+// Expected entries: A.npy, B.npy (float16, C-order), optionally
+// embed_A.npy and embed_B.npy for the embedding side
+func loadDeltaNPZ(path string) (*DeltaVoice, error) {
 	r, err := zip.OpenReader(path)
 	if err != nil {
 		return nil, fmt.Errorf("open delta npz: %w", err)
 	}
 	defer r.Close()
 
-	var aData, bData []float32
-	var aShape, bShape [2]int
+	var aData, bData, eaData, ebData []byte
+	var aShape, bShape, eaShape, ebShape [2]int
 
 	for _, f := range r.File {
 		name := f.Name
@@ -61,10 +126,19 @@ func LoadDelta(path string) (*DeltaVoice, error) {
 			continue
 		}
 
-		// Only load A.npy and B.npy — skip scalar metadata (rank, vocab_size, etc.)
-		isA := name == "A.npy"
-		isB := name == "B.npy"
-		if !isA && !isB {
+		// Only load the matrices — skip scalar metadata (rank, vocab_size, etc.)
+		var data *[]byte
+		var shape *[2]int
+		switch name {
+		case "A.npy":
+			data, shape = &aData, &aShape
+		case "B.npy":
+			data, shape = &bData, &bShape
+		case "embed_A.npy":
+			data, shape = &eaData, &eaShape
+		case "embed_B.npy":
+			data, shape = &ebData, &ebShape
+		default:
 			continue
 		}
 
@@ -73,19 +147,11 @@ func LoadDelta(path string) (*DeltaVoice, error) {
 			return nil, fmt.Errorf("open %s: %w", name, err)
 		}
 
-		data, shape, err := readNpy(rc)
+		*data, *shape, err = readNpy(rc)
 		rc.Close()
 		if err != nil {
 			return nil, fmt.Errorf("read %s: %w", name, err)
 		}
-
-		if isA {
-			aData = data
-			aShape = shape
-		} else {
-			bData = data
-			bShape = shape
-		}
 	}
 
 	if aData == nil || bData == nil {
@@ -102,56 +168,56 @@ func LoadDelta(path string) (*DeltaVoice, error) {
 
 	fmt.Printf("[delta-voice] loaded: vocab=%d, hidden=%d, rank=%d\n", vocabSize, hiddenDim, rank)
 	fmt.Printf("[delta-voice] A: %d×%d (%.1f MB), B: %d×%d (%.1f MB)\n",
-		vocabSize, rank, float64(len(aData)*4)/1024/1024,
-		rank, hiddenDim, float64(len(bData)*4)/1024/1024)
+		vocabSize, rank, float64(len(aData))/1024/1024,
+		rank, hiddenDim, float64(len(bData))/1024/1024)
 
-	return &DeltaVoice{
+	d := &DeltaVoice{
 		VocabSize: vocabSize,
 		HiddenDim: hiddenDim,
 		Rank:      rank,
+		Path:      path,
 		A:         aData,
 		B:         bData,
 		Bx:        make([]float32, rank),
-	}, nil
+		ABx:       make([]float32, vocabSize),
+	}
+	if eaData != nil || ebData != nil {
+		if eaData == nil || ebData == nil {
+			return nil, fmt.Errorf("delta npz has only one of embed_A.npy and embed_B.npy")
+		}
+		if err := d.setEmbed(eaData, ebData, eaShape, ebShape); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
 }
 
 // ApplyToLogits adds alpha * A @ (B @ x) to logits
 // logits: [VocabSize], x: [HiddenDim], alpha: blend factor
 func (d *DeltaVoice) ApplyToLogits(logits []float32, x []float32, alpha float32) {
-	if alpha == 0 || d == nil {
+	if alpha == 0 || d == nil || d.Rank == 0 {
 		return
 	}
 
-	rank := d.Rank
-	hiddenDim := d.HiddenDim
-	vocabSize := d.VocabSize
-
-	// Step 1: Bx = B @ x → [rank]
-	// B is [rank, hiddenDim], x is [hiddenDim]
-	for r := 0; r < rank; r++ {
-		var sum float32
-		off := r * hiddenDim
-		for j := 0; j < hiddenDim; j++ {
-			sum += d.B[off+j] * x[j]
+	// Bx = B @ x → [rank], then logits += alpha * A @ Bx
+	MatMulF16(d.Bx, d.B, x, d.Rank, d.HiddenDim)
+	if d.Sparse() {
+		abx := d.ABx[:len(d.Rows)]
+		MatMulF16(abx, d.A, d.Bx, len(d.Rows), d.Rank)
+		for i, v := range abx {
+			logits[d.Rows[i]] += alpha * v
 		}
-		d.Bx[r] = sum
+		return
 	}
-
-	// Step 2: logits += alpha * A @ Bx
-	// A is [vocabSize, rank], Bx is [rank]
-	for i := 0; i < vocabSize; i++ {
-		var sum float32
-		off := i * rank
-		for r := 0; r < rank; r++ {
-			sum += d.A[off+r] * d.Bx[r]
-		}
-		logits[i] += alpha * sum
+	MatMulF16(d.ABx, d.A, d.Bx, d.VocabSize, d.Rank)
+	for i, v := range d.ABx {
+		logits[i] += alpha * v
 	}
 }
 
-// readNpy reads a numpy .npy file and returns float32 data + 2D shape
-// Supports float16 and float32 dtypes
-func readNpy(r io.Reader) ([]float32, [2]int, error) {
+// readNpy reads a numpy .npy file and returns float16 data + 2D shape
+// Supports float16 and float32 (narrowed to float16) dtypes
+func readNpy(r io.Reader) ([]byte, [2]int, error) {
 	// Magic: \x93NUMPY
 	magic := make([]byte, 6)
 	if _, err := io.ReadFull(r, magic); err != nil {
@@ -206,25 +272,19 @@ func readNpy(r io.Reader) ([]float32, [2]int, error) {
 	totalElements := shape[0] * shape[1]
 
 	// Read raw data
-	var data []float32
+	data := make([]byte, totalElements*2)
 	if isFloat16 {
-		raw := make([]byte, totalElements*2)
-		if _, err := io.ReadFull(r, raw); err != nil {
+		if _, err := io.ReadFull(r, data); err != nil {
 			return nil, [2]int{}, fmt.Errorf("read float16 data: %w", err)
 		}
-		data = make([]float32, totalElements)
-		for i := 0; i < totalElements; i++ {
-			h := uint16(raw[i*2]) | uint16(raw[i*2+1])<<8
-			data[i] = half2float(h)
-		}
 	} else {
 		raw := make([]byte, totalElements*4)
 		if _, err := io.ReadFull(r, raw); err != nil {
 			return nil, [2]int{}, fmt.Errorf("read float32 data: %w", err)
 		}
-		data = make([]float32, totalElements)
 		for i := 0; i < totalElements; i++ {
-			data[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+			f := math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+			binary.LittleEndian.PutUint16(data[i*2:], float2half(f))
 		}
 	}
 
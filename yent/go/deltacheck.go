@@ -0,0 +1,90 @@
+package yent
+
+// deltacheck.go — Does this delta belong to this model?
+//
+// The shape check only catches a delta for another model size. Two models
+// with the same hidden size and vocabulary size can still number their
+// tokens differently, and then the delta boosts the wrong tokens: the
+// output is garbage and nothing says why. A GGUF delta records the model it
+// was built for (deltagguf.go):
+//
+//   delta.vocab_hash      SHA-256 of the token list: what each id means
+//   delta.tokenizer_hash  tokenizerKey: merges, scores, types, BOS/EOS
+//   delta.base_hash       DeltaBaseHash: architecture, hidden size, tokens
+//
+// CheckBase compares them with the model. A different vocabulary is an
+// error (Yent.DeltaForce, -delta-force, downgrades it to a warning); the
+// same vocabulary with a different tokenizer only warns, since the ids
+// still mean the same tokens. NPZ deltas and LoRA adapters carry no
+// fingerprint and pass on shape alone.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// deltaVocabHash is a SHA-256 over the token list alone
+func deltaVocabHash(meta *GGUFMetadata) string {
+	h := sha256.New()
+	for _, tok := range meta.TokenList {
+		io.WriteString(h, tok)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SetBase records meta's model as the one the delta was built for
+func (m *DeltaMeta) SetBase(meta *GGUFMetadata) {
+	m.BaseHash = DeltaBaseHash(meta)
+	m.VocabHash = deltaVocabHash(meta)
+	m.TokenizerHash = tokenizerKey(meta)
+}
+
+// CheckBase matches the delta against the model described by meta: its
+// shape, then the fingerprint it recorded. err means the delta does not
+// belong to this model; warning is a difference it can live with.
+func (d *DeltaVoice) CheckBase(meta *GGUFMetadata) (warning string, err error) {
+	if d.VocabSize != meta.VocabSize || d.HiddenDim != meta.EmbedDim {
+		return "", fmt.Errorf("delta is vocab %d × hidden %d, model is vocab %d × dim %d",
+			d.VocabSize, d.HiddenDim, meta.VocabSize, meta.EmbedDim)
+	}
+	built := "another model"
+	if d.Meta.BaseModel != "" {
+		built = d.Meta.BaseModel
+	}
+	switch {
+	case d.Meta.VocabHash != "":
+		if h := deltaVocabHash(meta); h != d.Meta.VocabHash {
+			return "", fmt.Errorf("delta was built for %s with a different vocabulary (vocab hash %.12s, model %.12s): its token ids name other tokens here",
+				built, d.Meta.VocabHash, h)
+		}
+		if d.Meta.TokenizerHash != "" && d.Meta.TokenizerHash != tokenizerKey(meta) {
+			warning = fmt.Sprintf("delta was built for %s: same vocabulary, different tokenizer (merges, scores or special tokens) — prompts may split differently", built)
+		}
+	case d.Meta.BaseHash != "":
+		// deltas written before the vocab and tokenizer hashes
+		if h := DeltaBaseHash(meta); h != d.Meta.BaseHash {
+			return "", fmt.Errorf("delta was built for %s (base hash %.12s, model %.12s): architecture or vocabulary differ",
+				built, d.Meta.BaseHash, h)
+		}
+	}
+	return warning, nil
+}
+
+// checkDeltaBase applies CheckBase for LoadDeltaVoice and ReloadDelta:
+// warnings are printed, and with DeltaForce so are mismatches
+func (y *Yent) checkDeltaBase(name string, d *DeltaVoice, meta *GGUFMetadata) error {
+	warning, err := d.CheckBase(meta)
+	if err != nil {
+		if !y.DeltaForce || d.VocabSize != meta.VocabSize || d.HiddenDim != meta.EmbedDim {
+			return fmt.Errorf("delta %s: %w", name, err)
+		}
+		warning = err.Error() + " (forced)"
+	}
+	if warning != "" {
+		fmt.Printf("[delta-voice] warning: %s: %s\n", name, warning)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package yent
+
+// deltadiag.go — What is the delta doing right now?
+//
+// "Why does alpha=0.5 still answer in English?" has no answer in the
+// output alone. DiagnoseDelta runs a prompt up to the point where the
+// answer begins and takes the delta apart at that hidden state:
+//
+//   - the most likely first tokens without and with the delta
+//   - the tokens it boosts and suppresses most (the logit change)
+//   - per writing system: how many tokens there are, how many the delta
+//     can move at all (A rows ≥ 1% of the largest norm), the mean shift
+//     here and how many of the top boosted tokens are of that script
+//
+// A delta that boosts Cyrillic tokens by +2 while English ones lead by 6
+// needs more alpha; one whose Cyrillic rows are barely touched needs a
+// better extraction. REPL: /diag [N] <prompt>; CLI: yent delta-diag.
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenShift is one token's place in a diagnosis
+type TokenShift struct {
+	Token int     `json:"token"`
+	Text  string  `json:"text"`
+	Shift float32 `json:"shift"`          // logit change from the delta
+	Prob  float32 `json:"prob,omitempty"` // next-token probability (Before/After)
+}
+
+// ScriptCoverage is the delta's reach into one writing system
+type ScriptCoverage struct {
+	Script    string   `json:"script"`
+	Languages []string `json:"languages,omitempty"` // languages written in it
+	Tokens    int      `json:"tokens"`              // vocabulary tokens of the script
+	Touched   int      `json:"touched"`             // of those, rows of A the delta moves
+	MeanShift float32  `json:"mean_shift"`          // mean logit change at this state
+	Boosted   int      `json:"boosted"`             // of the top boosted tokens
+}
+
+// DeltaDiagnosis is a delta taken apart at one hidden state
+type DeltaDiagnosis struct {
+	Delta      string           `json:"delta"`
+	Alpha      float32          `json:"alpha"`
+	Before     []TokenShift     `json:"before"` // likeliest next tokens without the delta
+	After      []TokenShift     `json:"after"`  // and with it
+	Boosted    []TokenShift     `json:"boosted"`
+	Suppressed []TokenShift     `json:"suppressed"`
+	Coverage   []ScriptCoverage `json:"coverage"`
+}
+
+// String formats the diagnosis for the terminal
+func (dd *DeltaDiagnosis) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  delta %s at alpha %.2f\n", dd.Delta, dd.Alpha)
+	list := func(title string, ts []TokenShift, prob bool) {
+		fmt.Fprintf(&b, "  %s:", title)
+		for _, t := range ts {
+			if prob {
+				fmt.Fprintf(&b, " %q %.0f%%", t.Text, t.Prob*100)
+			} else {
+				fmt.Fprintf(&b, " %q %+.2f", t.Text, t.Shift)
+			}
+		}
+		b.WriteByte('\n')
+	}
+	list("without", dd.Before, true)
+	list("with   ", dd.After, true)
+	list("boosts  ", dd.Boosted, false)
+	list("suppresses", dd.Suppressed, false)
+	fmt.Fprintf(&b, "  %-32s %8s %8s %10s %8s\n", "script", "tokens", "touched", "mean shift", "boosted")
+	for _, c := range dd.Coverage {
+		name := c.Script
+		if len(c.Languages) > 0 {
+			name += " (" + strings.Join(c.Languages, ",") + ")"
+		}
+		fmt.Fprintf(&b, "  %-32s %8d %7.0f%% %+10.3f %8d\n", name, c.Tokens,
+			100*float64(c.Touched)/math.Max(1, float64(c.Tokens)), c.MeanShift, c.Boosted)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// DiagnoseDelta feeds prompt as a question and diagnoses delta name
+// ("" = the active one) at the hidden state that predicts the answer's
+// first token. alpha <= 0 uses DeltaAlpha, or 1 in English mode; topN
+// bounds each token list (0 = 10). It resets the model's KV state
+// (y.model.Reset()) and leaves the prompt's rows in it, so nothing may
+// count on the live cache afterwards; a cached session survives because
+// it restores its own rows (session.go).
+func (y *Yent) DiagnoseDelta(name, prompt string, alpha float32, topN int) (*DeltaDiagnosis, error) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	if y.model == nil || y.tokenizer == nil {
+		return nil, fmt.Errorf("yent not initialized")
+	}
+	delta, err := y.deltaFor(name)
+	if err != nil {
+		return nil, err
+	}
+	if delta == nil {
+		return nil, fmt.Errorf("no delta loaded")
+	}
+	if name == "" {
+		name = y.deltaName
+	}
+	if alpha <= 0 {
+		alpha = y.DeltaAlpha
+	}
+	if alpha <= 0 {
+		alpha = 1
+	}
+	if topN <= 0 {
+		topN = 10
+	}
+
+	// The hidden state generate sees before the first answer token
+	head := "### Question: "
+	tokens := y.tokenizer.encode(head+prompt+"\n### Answer:", false, len(head), len(head)+len(prompt))
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("prompt encodes to no tokens")
+	}
+	if len(tokens) >= y.model.Config.SeqLen {
+		return nil, fmt.Errorf("prompt is %d tokens, context is %d", len(tokens), y.model.Config.SeqLen)
+	}
+	vocab := y.model.Config.VocabSize
+	feed := func() []float32 {
+		y.model.Reset()
+		for pos, tok := range tokens {
+			y.model.Forward(tok, pos)
+		}
+		return append([]float32(nil), y.model.State.Logits[:vocab]...)
+	}
+	before := feed()
+	after := append([]float32(nil), before...)
+	if delta.HasEmbed() {
+		// the input side changes the hidden state itself
+		restore := y.model.useEmbedDelta(delta, alpha)
+		after = feed()
+		restore()
+	}
+	delta.ApplyToLogits(after, y.model.State.X, alpha)
+
+	shift := make([]float32, vocab)
+	neg := make([]float32, vocab)
+	for i := range shift {
+		shift[i] = after[i] - before[i]
+		neg[i] = -shift[i]
+	}
+	dd := &DeltaDiagnosis{Delta: name, Alpha: alpha}
+	pieces := y.tokenPieces()
+	entry := func(id int, logits []float32) TokenShift {
+		t := TokenShift{Token: id, Text: string(pieces[id]), Shift: shift[id]}
+		if logits != nil {
+			t.Prob = float32(math.Exp(float64(logits[id] - logSumExp(logits))))
+		}
+		return t
+	}
+	for _, id := range topNIndices(before, min(topN, 5)) {
+		dd.Before = append(dd.Before, entry(id, before))
+	}
+	for _, id := range topNIndices(after, min(topN, 5)) {
+		dd.After = append(dd.After, entry(id, after))
+	}
+	boosted := topNIndices(shift, topN)
+	for _, id := range boosted {
+		dd.Boosted = append(dd.Boosted, entry(id, nil))
+	}
+	for _, id := range topNIndices(neg, topN) {
+		dd.Suppressed = append(dd.Suppressed, entry(id, nil))
+	}
+	dd.Coverage = deltaCoverage(delta, pieces, shift, boosted)
+	return dd, nil
+}
+
+// deltaCoverage groups the vocabulary by the script of each token's
+// first letter ("none" = no letters, "other" = a script langdetect
+// does not name)
+func deltaCoverage(d *DeltaVoice, pieces [][]byte, shift []float32, boosted []int) []ScriptCoverage {
+	n := len(shift)
+	touched := make([]bool, n)
+	norms := make([]float64, len(d.A)/(2*max(d.Rank, 1)))
+	var maxNorm float64
+	for i := range norms {
+		var sq float64
+		for _, v := range d.aRow(i) {
+			sq += float64(v) * float64(v)
+		}
+		norms[i] = math.Sqrt(sq)
+		maxNorm = math.Max(maxNorm, norms[i])
+	}
+	for i, norm := range norms {
+		if tok := int(d.token(i)); tok < n && maxNorm > 0 && norm >= 0.01*maxNorm {
+			touched[tok] = true
+		}
+	}
+
+	byScript := make(map[string]*ScriptCoverage)
+	script := make([]string, n)
+	sums := make(map[string]float64)
+	for id := 0; id < n && id < len(pieces); id++ {
+		s := "none"
+		for p := pieces[id]; len(p) > 0; {
+			r, size := utf8.DecodeRune(p)
+			if unicode.IsLetter(r) {
+				s = letterScript(r)
+				break
+			}
+			p = p[size:]
+		}
+		script[id] = s
+		c := byScript[s]
+		if c == nil {
+			c = &ScriptCoverage{Script: s, Languages: scriptLanguageList(s)}
+			byScript[s] = c
+		}
+		c.Tokens++
+		if touched[id] {
+			c.Touched++
+		}
+		sums[s] += float64(shift[id])
+	}
+	for _, id := range boosted {
+		if c := byScript[script[id]]; c != nil {
+			c.Boosted++
+		}
+	}
+	out := make([]ScriptCoverage, 0, len(byScript))
+	for s, c := range byScript {
+		c.MeanShift = float32(sums[s] / float64(c.Tokens))
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(a, b int) bool {
+		if out[a].Tokens != out[b].Tokens {
+			return out[a].Tokens > out[b].Tokens
+		}
+		return out[a].Script < out[b].Script
+	})
+	return out
+}
+
+// scriptLanguageList names the languages langdetect knows in a script
+func scriptLanguageList(script string) []string {
+	if lang, ok := scriptLanguages[script]; ok {
+		return []string{lang}
+	}
+	var langs []string
+	for lang, words := range stopwords {
+		r, _ := utf8.DecodeRuneInString(words[0])
+		if letterScript(r) == script {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
@@ -0,0 +1,86 @@
+package yent
+
+// deltaembed.go — Delta Voice on the input side
+//
+// The LM head delta fixes what Yent says, not what he reads: a Russian
+// prompt still enters through embeddings fine-tuned on English, and the
+// hidden state the head delta sees is built from them. A delta file may
+// carry a second low-rank pair for the embedding table:
+//
+//   embed(token) += alpha × EmbA[token] @ EmbB
+//
+//   EmbA: [vocab × rank]    one row per token
+//   EmbB: [rank × hidden]
+//
+// The shift goes on the raw table row, before any architecture embedding
+// scale (gemma), exactly as if the table itself had been patched. Cost per
+// token is rank × hidden multiply-adds — nothing next to the head delta's
+// rank × vocab. The same alpha drives both sides.
+//
+// NPZ: embed_A.npy and embed_B.npy next to A.npy and B.npy.
+// GGUF: delta.embed.A and delta.embed.B, with delta.embed_rank (deltagguf.go).
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// embedDelta is the embedding shift ForwardHidden applies during a
+// generation (nil = the table as loaded)
+type embedDelta struct {
+	d     *DeltaVoice
+	alpha float32
+}
+
+// HasEmbed reports whether the delta also shifts the embedding table
+func (d *DeltaVoice) HasEmbed() bool {
+	return d != nil && d.EmbRank > 0
+}
+
+// setEmbed attaches the embedding pair after checking it against the
+// head delta's vocabulary and hidden size
+func (d *DeltaVoice) setEmbed(a, b []byte, aShape, bShape [2]int) error {
+	if aShape[0] != d.VocabSize || bShape[1] != d.HiddenDim || aShape[1] != bShape[0] {
+		return fmt.Errorf("embedding delta %dx%d @ %dx%d does not fit vocab %d × hidden %d",
+			aShape[0], aShape[1], bShape[0], bShape[1], d.VocabSize, d.HiddenDim)
+	}
+	d.EmbRank, d.EmbA, d.EmbB = aShape[1], a, b
+	return nil
+}
+
+// ApplyToEmbedding adds alpha × EmbA[token] @ EmbB to x, the token's
+// embedding row. A no-op without an embedding pair.
+func (d *DeltaVoice) ApplyToEmbedding(x []float32, token int, alpha float32) {
+	if alpha == 0 || !d.HasEmbed() || token < 0 || token >= d.VocabSize {
+		return
+	}
+	row := d.EmbA[token*d.EmbRank*2 : (token+1)*d.EmbRank*2]
+	for r := 0; r < d.EmbRank; r++ {
+		c := alpha * half2float(binary.LittleEndian.Uint16(row[r*2:]))
+		if c == 0 {
+			continue
+		}
+		b := d.EmbB[r*d.HiddenDim*2 : (r+1)*d.HiddenDim*2]
+		for j := range x[:d.HiddenDim] {
+			x[j] += c * half2float(binary.LittleEndian.Uint16(b[j*2:]))
+		}
+	}
+}
+
+// useEmbedDelta makes the model's embedding lookups go through d at alpha
+// until the returned func is called
+func (m *LlamaModel) useEmbedDelta(d *DeltaVoice, alpha float32) func() {
+	if !d.HasEmbed() || alpha == 0 {
+		return func() {}
+	}
+	m.embedDelta = &embedDelta{d: d, alpha: alpha}
+	return func() { m.embedDelta = nil }
+}
+
+// embedShift is the embedding delta in use (zero = none)
+func (m *LlamaModel) embedShift() embedDelta {
+	if m.embedDelta == nil {
+		return embedDelta{}
+	}
+	return *m.embedDelta
+}
@@ -0,0 +1,410 @@
+package yent
+
+// deltaextract.go — Extract a Delta Voice from two GGUFs, in Go
+//
+// The shipped deltas came out of a Python pipeline (torch, an SVD, numpy's
+// savez): the one step of the method that needed more than this binary.
+// ExtractDelta reads the LM heads of the base model and of Yent and
+// factors their difference straight from the quantized weights:
+//
+//   Δ = base_lm_head − yent_lm_head          [vocab × dim]
+//   Δ ≈ A @ B    A: [vocab × rank]  B: [rank × dim]
+//
+// Δ is never held whole (151936 × 1536 floats is 900 MB). A randomized
+// SVD (Halko, Martinsson, Tropp) streams it row by row, dequantizing both
+// heads on the fly: a Gaussian sketch Y = ΔΩ, a couple of power iterations
+// to sharpen the spectrum, Q = orth(Y), then the exact SVD of the small
+// QᵀΔ. Each pass is vocab × dim × (rank + 10) multiply-adds, split over
+// the matmul workers; the whole run is 4-6 passes.
+//
+// With EmbedRank the token embedding tables are factored the same way
+// into the input-side pair (deltaembed.go). The result is a GGUF delta
+// (deltagguf.go) recording Yent as its base.
+//
+//   yent delta-extract -base qwen2.5-1.5b-instruct-q8_0.gguf \
+//     -weights yent_1.5B_step1000_q4_0.gguf -rank 64 -out yent_1.5b_delta_r64.gguf
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ExtractOptions configures ExtractDelta
+type ExtractOptions struct {
+	Rank       int      // LM head delta rank (default 64)
+	EmbedRank  int      // embedding delta rank (0 = none)
+	PowerIters int      // power iterations (default 2; more = closer to the true SVD)
+	Seed       int64    // sketch seed (0 = 1)
+	Languages  []string // recorded in the delta
+}
+
+// ExtractReport describes an extraction
+type ExtractReport struct {
+	Rank        int     `json:"rank"`
+	EmbedRank   int     `json:"embed_rank,omitempty"`
+	Energy      float64 `json:"energy"`                 // share of ‖Δ‖² the rank keeps
+	EmbedEnergy float64 `json:"embed_energy,omitempty"` // same for the embedding delta
+	DurationSec float64 `json:"duration_sec"`
+}
+
+// ExtractDelta factors base's LM head minus yent's into a rank-limited
+// delta and writes it to outPath as a GGUF delta
+func ExtractDelta(basePath, yentPath, outPath string, opts ExtractOptions) (*ExtractReport, error) {
+	if opts.Rank <= 0 {
+		opts.Rank = 64
+	}
+	if opts.PowerIters <= 0 {
+		opts.PowerIters = 2
+	}
+	if opts.Seed == 0 {
+		opts.Seed = 1
+	}
+	start := time.Now()
+
+	base, err := LoadGGUFMapped(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("load base: %w", err)
+	}
+	defer base.Close()
+	tuned, err := LoadGGUFMapped(yentPath)
+	if err != nil {
+		return nil, fmt.Errorf("load yent: %w", err)
+	}
+	defer tuned.Close()
+	vocab, dim := tuned.Meta.VocabSize, tuned.Meta.EmbedDim
+	if base.Meta.VocabSize != vocab || base.Meta.EmbedDim != dim {
+		return nil, fmt.Errorf("base is vocab %d × dim %d, yent is %d × %d",
+			base.Meta.VocabSize, base.Meta.EmbedDim, vocab, dim)
+	}
+	if opts.Rank > min(dim, vocab) || opts.EmbedRank > min(dim, vocab) {
+		return nil, fmt.Errorf("rank %d/%d exceeds the matrix (vocab %d × dim %d)", opts.Rank, opts.EmbedRank, vocab, dim)
+	}
+
+	head, err := newRowDiff(base, tuned, "output.weight", vocab, dim)
+	if err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	fmt.Printf("[delta-extract] LM head %d × %d, rank %d\n", vocab, dim, opts.Rank)
+	a, b, energy := head.lowRank(opts.Rank, opts.PowerIters, rng)
+	d := &DeltaVoice{
+		VocabSize: vocab,
+		HiddenDim: dim,
+		Rank:      opts.Rank,
+		A:         f16Bytes(a),
+		B:         f16Bytes(b),
+	}
+	report := &ExtractReport{Rank: opts.Rank, Energy: energy}
+
+	if opts.EmbedRank > 0 {
+		emb, err := newRowDiff(base, tuned, "token_embd.weight", vocab, dim)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("[delta-extract] embeddings %d × %d, rank %d\n", vocab, dim, opts.EmbedRank)
+		ea, eb, eEnergy := emb.lowRank(opts.EmbedRank, opts.PowerIters, rng)
+		d.EmbRank, d.EmbA, d.EmbB = opts.EmbedRank, f16Bytes(ea), f16Bytes(eb)
+		report.EmbedRank, report.EmbedEnergy = opts.EmbedRank, eEnergy
+	}
+
+	meta := DeltaMeta{Languages: opts.Languages}
+	meta.SetBase(&tuned.Meta)
+	meta.BaseModel, _ = tuned.Meta.KV["general.name"].(string)
+	if err := WriteDeltaGGUF(outPath, d, meta); err != nil {
+		return nil, fmt.Errorf("write delta: %w", err)
+	}
+	report.DurationSec = time.Since(start).Seconds()
+	return report, nil
+}
+
+// rowDiff streams the rows of one tensor of base minus the same of yent
+type rowDiff struct {
+	base, yent         []byte
+	baseType, yentType uint32
+	rows, cols         int
+}
+
+// newRowDiff finds name in both files; "output.weight" falls back to the
+// tied embedding table, as the model loader does
+func newRowDiff(base, tuned *GGUFFile, name string, rows, cols int) (*rowDiff, error) {
+	tensor := func(g *GGUFFile, which string) ([]byte, uint32, error) {
+		data, info, err := g.GetTensor(name)
+		if err != nil && name == "output.weight" {
+			data, info, err = g.GetTensor("token_embd.weight")
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s %s: %w", which, name, err)
+		}
+		if !isSupportedType(info.Type) {
+			return nil, 0, fmt.Errorf("%s %s: tensor type %d not supported", which, name, info.Type)
+		}
+		return data, info.Type, nil
+	}
+	r := &rowDiff{rows: rows, cols: cols}
+	var err error
+	if r.base, r.baseType, err = tensor(base, "base"); err != nil {
+		return nil, err
+	}
+	if r.yent, r.yentType, err = tensor(tuned, "yent"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// row writes row i of the difference to out; tmp is scratch of cols
+func (r *rowDiff) row(i int, out, tmp []float32) {
+	readRow(out, r.base, r.baseType, i, r.cols)
+	readRow(tmp, r.yent, r.yentType, i, r.cols)
+	for c := range out {
+		out[c] -= tmp[c]
+	}
+}
+
+// readRow dequantizes row i of a [rows × cols] tensor
+func readRow(out []float32, data []byte, dtype uint32, i, cols int) {
+	switch dtype {
+	case ggmlTypeQ4_0, ggmlTypeQ8_0, ggmlTypeF16, ggmlTypeF32:
+		embedLookupInto(out, data, dtype, i, cols)
+	default:
+		rowBytes := cols / ggmlBlockElements(dtype) * ggmlBlockSize(dtype)
+		copy(out, dequantRows(data[i*rowBytes:(i+1)*rowBytes], dtype, cols))
+	}
+}
+
+// mulRight returns Δ @ m for m [cols × k], and ‖Δ‖² on the way
+func (r *rowDiff) mulRight(m []float64, k int) ([]float64, float64) {
+	out := make([]float64, r.rows*k)
+	var mu sync.Mutex
+	var norm float64
+	parallelRows(r.rows, func(start, end int) {
+		row, tmp := make([]float32, r.cols), make([]float32, r.cols)
+		var sq float64
+		for i := start; i < end; i++ {
+			r.row(i, row, tmp)
+			o := out[i*k : (i+1)*k]
+			for c, v := range row {
+				if v == 0 {
+					continue
+				}
+				fv := float64(v)
+				sq += fv * fv
+				mc := m[c*k : (c+1)*k]
+				for j := range o {
+					o[j] += fv * mc[j]
+				}
+			}
+		}
+		mu.Lock()
+		norm += sq
+		mu.Unlock()
+	})
+	return out, norm
+}
+
+// mulLeftT returns Δᵀ @ q for q [rows × k], as [cols × k]
+func (r *rowDiff) mulLeftT(q []float64, k int) []float64 {
+	out := make([]float64, r.cols*k)
+	var mu sync.Mutex
+	parallelRows(r.rows, func(start, end int) {
+		row, tmp := make([]float32, r.cols), make([]float32, r.cols)
+		acc := make([]float64, r.cols*k)
+		for i := start; i < end; i++ {
+			r.row(i, row, tmp)
+			qi := q[i*k : (i+1)*k]
+			for c, v := range row {
+				if v == 0 {
+					continue
+				}
+				fv := float64(v)
+				a := acc[c*k : (c+1)*k]
+				for j, qv := range qi {
+					a[j] += fv * qv
+				}
+			}
+		}
+		mu.Lock()
+		for i, v := range acc {
+			out[i] += v
+		}
+		mu.Unlock()
+	})
+	return out
+}
+
+// lowRank returns A [rows × rank] and B [rank × cols] with Δ ≈ A @ B, and
+// the share of ‖Δ‖² they keep
+func (r *rowDiff) lowRank(rank, powerIters int, rng *rand.Rand) ([]float32, []float32, float64) {
+	k := min(rank+10, r.cols, r.rows) // oversampling
+	omega := make([]float64, r.cols*k)
+	for i := range omega {
+		omega[i] = rng.NormFloat64()
+	}
+	y, norm := r.mulRight(omega, k)
+	for it := 0; it < powerIters; it++ {
+		orthonormalize(y, r.rows, k)
+		z := r.mulLeftT(y, k)
+		orthonormalize(z, r.cols, k)
+		y, _ = r.mulRight(z, k)
+		fmt.Printf("[delta-extract]   power iteration %d/%d\n", it+1, powerIters)
+	}
+	orthonormalize(y, r.rows, k)
+	q := y
+
+	// Small = Qᵀ Δ [k × cols], stored transposed as Δᵀ Q [cols × k]
+	smallT := r.mulLeftT(q, k)
+	// Small Smallᵀ [k × k] = U S² Uᵀ
+	g := make([]float64, k*k)
+	for c := 0; c < r.cols; c++ {
+		sc := smallT[c*k : (c+1)*k]
+		for i, vi := range sc {
+			for j := i; j < k; j++ {
+				g[i*k+j] += vi * sc[j]
+			}
+		}
+	}
+	for i := 0; i < k; i++ {
+		for j := 0; j < i; j++ {
+			g[i*k+j] = g[j*k+i]
+		}
+	}
+	vals, vecs := symmetricEigen(g, k)
+
+	// A = Q U S, B = S⁻¹ Uᵀ Small: the top rank singular triplets
+	a := make([]float32, r.rows*rank)
+	b := make([]float32, rank*r.cols)
+	var kept float64
+	for t := 0; t < rank; t++ {
+		lambda := math.Max(vals[t], 0)
+		kept += lambda
+		s := math.Sqrt(lambda)
+		for i := 0; i < r.rows; i++ {
+			var v float64
+			qi := q[i*k : (i+1)*k]
+			for j, qv := range qi {
+				v += qv * vecs[j*k+t]
+			}
+			a[i*rank+t] = float32(v * s)
+		}
+		if s == 0 {
+			continue
+		}
+		for c := 0; c < r.cols; c++ {
+			var v float64
+			sc := smallT[c*k : (c+1)*k]
+			for j, sv := range sc {
+				v += vecs[j*k+t] * sv
+			}
+			b[t*r.cols+c] = float32(v / s)
+		}
+	}
+	energy := 0.0
+	if norm > 0 {
+		energy = kept / norm
+	}
+	return a, b, energy
+}
+
+// orthonormalize makes the k columns of m [rows × k] orthonormal
+// (modified Gram-Schmidt, twice for stability)
+func orthonormalize(m []float64, rows, k int) {
+	for pass := 0; pass < 2; pass++ {
+		for j := 0; j < k; j++ {
+			for p := 0; p < j; p++ {
+				var dot float64
+				for i := 0; i < rows; i++ {
+					dot += m[i*k+p] * m[i*k+j]
+				}
+				for i := 0; i < rows; i++ {
+					m[i*k+j] -= dot * m[i*k+p]
+				}
+			}
+			var norm float64
+			for i := 0; i < rows; i++ {
+				norm += m[i*k+j] * m[i*k+j]
+			}
+			norm = math.Sqrt(norm)
+			if norm < 1e-300 {
+				continue
+			}
+			for i := 0; i < rows; i++ {
+				m[i*k+j] /= norm
+			}
+		}
+	}
+}
+
+// symmetricEigen diagonalizes the symmetric n × n matrix a (cyclic
+// Jacobi) and returns eigenvalues in descending order with eigenvectors
+// as the matching columns of an n × n matrix
+func symmetricEigen(a []float64, n int) ([]float64, []float64) {
+	a = append([]float64(nil), a...)
+	v := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		v[i*n+i] = 1
+	}
+	for sweep := 0; sweep < 100; sweep++ {
+		var off, diag float64
+		for i := 0; i < n; i++ {
+			diag += a[i*n+i] * a[i*n+i]
+			for j := i + 1; j < n; j++ {
+				off += a[i*n+j] * a[i*n+j]
+			}
+		}
+		if off <= 1e-24*diag || off == 0 {
+			break
+		}
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				apq := a[p*n+q]
+				if apq == 0 {
+					continue
+				}
+				theta := (a[q*n+q] - a[p*n+p]) / (2 * apq)
+				t := math.Copysign(1, theta) / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for i := 0; i < n; i++ {
+					aip, aiq := a[i*n+p], a[i*n+q]
+					a[i*n+p], a[i*n+q] = c*aip-s*aiq, s*aip+c*aiq
+				}
+				for i := 0; i < n; i++ {
+					api, aqi := a[p*n+i], a[q*n+i]
+					a[p*n+i], a[q*n+i] = c*api-s*aqi, s*api+c*aqi
+				}
+				for i := 0; i < n; i++ {
+					vip, viq := v[i*n+p], v[i*n+q]
+					v[i*n+p], v[i*n+q] = c*vip-s*viq, s*vip+c*viq
+				}
+			}
+		}
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(x, y int) bool { return a[order[x]*n+order[x]] > a[order[y]*n+order[y]] })
+	vals := make([]float64, n)
+	vecs := make([]float64, n*n)
+	for t, i := range order {
+		vals[t] = a[i*n+i]
+		for r := 0; r < n; r++ {
+			vecs[r*n+t] = v[r*n+i]
+		}
+	}
+	return vals, vecs
+}
+
+// f16Bytes encodes v as little-endian float16
+func f16Bytes(v []float32) []byte {
+	out := make([]byte, len(v)*2)
+	for i, f := range v {
+		h := float2half(f)
+		out[i*2], out[i*2+1] = byte(h), byte(h>>8)
+	}
+	return out
+}
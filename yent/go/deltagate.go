@@ -0,0 +1,80 @@
+package yent
+
+// deltagate.go — Apply Delta Voice only where the text is not English
+//
+// With a delta loaded and alpha > 0 every token pays for A @ (B @ x), even
+// in a conversation that is plain English the whole time. With
+// Yent.DeltaGate = n (-delta-gate n) the head delta is applied only while
+// the last n tokens of context (the prompt's tail, then the answer) hold a
+// non-ASCII character. Pure-ASCII stretches skip ApplyToLogits and run at
+// English speed; one Cyrillic token reopens the gate for the next n.
+//
+// A caller that wants the blend regardless sets GenerateOptions.DeltaAlways
+// ("delta_always" over HTTP). Beam search and the embedding-side delta
+// (deltaembed.go) are not gated. GenerateResult.DeltaSkipped counts the
+// tokens the gate saved.
+
+// asciiTokens marks the tokens whose text is pure ASCII (control tokens
+// count as ASCII). Built once per vocabulary.
+func (y *Yent) asciiTokens() []bool {
+	if y.asciiMask == nil {
+		pieces := y.tokenPieces()
+		mask := make([]bool, len(pieces))
+		for id, piece := range pieces {
+			mask[id] = true
+			for _, b := range piece {
+				if b >= 0x80 {
+					mask[id] = false
+					break
+				}
+			}
+		}
+		y.asciiMask = mask
+	}
+	return y.asciiMask
+}
+
+// deltaGate tracks non-ASCII tokens over the last len(window) tokens
+type deltaGate struct {
+	ascii    []bool
+	window   []bool // ring: true = non-ASCII token
+	next     int
+	nonASCII int
+}
+
+// newDeltaGate returns a gate over the last n tokens, primed with the
+// prompt; nil (always open) when n <= 0
+func (y *Yent) newDeltaGate(n int, prompt []int) *deltaGate {
+	if n <= 0 {
+		return nil
+	}
+	g := &deltaGate{ascii: y.asciiTokens(), window: make([]bool, n)}
+	if len(prompt) > n {
+		prompt = prompt[len(prompt)-n:]
+	}
+	for _, tok := range prompt {
+		g.push(tok)
+	}
+	return g
+}
+
+// push adds tok to the window, dropping the oldest
+func (g *deltaGate) push(tok int) {
+	if g == nil {
+		return
+	}
+	non := tok >= 0 && tok < len(g.ascii) && !g.ascii[tok]
+	if g.window[g.next] {
+		g.nonASCII--
+	}
+	if non {
+		g.nonASCII++
+	}
+	g.window[g.next] = non
+	g.next = (g.next + 1) % len(g.window)
+}
+
+// open reports whether the delta applies to the next token
+func (g *deltaGate) open() bool {
+	return g == nil || g.nonASCII > 0
+}
@@ -0,0 +1,298 @@
+package yent
+
+// deltagguf.go — Delta Voice in a GGUF container
+//
+// NPZ is numpy's zip of .npy files: LoadDelta has to parse Python dict
+// headers, and the file says nothing about where it came from. A delta
+// loaded against the wrong model only fails when the shapes happen to
+// differ. The GGUF delta keeps the same two matrices as tensors, next to
+// their provenance:
+//
+//   general.architecture  "delta-voice"
+//   delta.rank            uint32
+//   delta.vocab_size      uint32
+//   delta.hidden_dim      uint32
+//   delta.base_model      string    general.name of the model it was built for
+//   delta.base_hash       string    DeltaBaseHash of that model
+//   delta.vocab_hash      string    its token list's SHA-256 (deltacheck.go)
+//   delta.tokenizer_hash  string    its tokenizer's fingerprint
+//   delta.languages       []string  languages it was extracted for
+//
+//   delta.embed_rank      uint32    optional, input side (deltaembed.go)
+//
+//   delta.A  F16 (or F32)  [vocab × rank]   GGUF dims (rank, vocab)
+//   delta.B  F16 (or F32)  [rank × hidden]  GGUF dims (hidden, rank)
+//   delta.embed.A, delta.embed.B            same layout, with delta.embed_rank
+//
+// LoadDelta tells the two apart by magic, so -delta takes either.
+// Convert an existing NPZ with:
+//
+//   yent delta-convert -delta yent_1.5b_delta_r64.npz -out yent_1.5b_delta_r64.gguf \
+//     -weights yent_1.5B_step1000_q4_0.gguf -langs ru,fr
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// deltaArch is general.architecture of a GGUF delta
+const deltaArch = "delta-voice"
+
+// DeltaMeta is the provenance a GGUF delta carries (empty for NPZ)
+type DeltaMeta struct {
+	BaseModel string   `json:"base_model,omitempty"` // general.name of the model it was built for
+	BaseHash  string   `json:"base_hash,omitempty"`  // DeltaBaseHash of that model
+	Languages []string `json:"languages,omitempty"`  // languages it was extracted for
+
+	// Fingerprint CheckBase verifies (deltacheck.go)
+	VocabHash     string `json:"vocab_hash,omitempty"`
+	TokenizerHash string `json:"tokenizer_hash,omitempty"`
+}
+
+// DeltaBaseHash identifies the model a delta applies to: a SHA-256 over
+// its architecture, hidden size and vocabulary. It reads metadata only.
+func DeltaBaseHash(meta *GGUFMetadata) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%d\n%d\n", meta.Arch, meta.EmbedDim, meta.VocabSize)
+	for _, tok := range meta.TokenList {
+		io.WriteString(h, tok)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isGGUFFile reports whether path starts with the GGUF magic
+func isGGUFFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var magic uint32
+	return binary.Read(f, binary.LittleEndian, &magic) == nil && magic == ggufMagic
+}
+
+// loadDeltaGGUF reads a GGUF delta
+func loadDeltaGGUF(path string) (*DeltaVoice, error) {
+	g, err := LoadGGUF(path)
+	if err != nil {
+		return nil, fmt.Errorf("open delta gguf: %w", err)
+	}
+	if arch, _ := g.Meta.KV["general.architecture"].(string); arch != deltaArch {
+		return nil, fmt.Errorf("%s is a %q GGUF, not a delta", path, arch)
+	}
+	rank := toInt(g.Meta.KV["delta.rank"])
+	vocabSize := toInt(g.Meta.KV["delta.vocab_size"])
+	hiddenDim := toInt(g.Meta.KV["delta.hidden_dim"])
+	if rank <= 0 || vocabSize <= 0 || hiddenDim <= 0 {
+		return nil, fmt.Errorf("delta gguf missing delta.rank, delta.vocab_size or delta.hidden_dim")
+	}
+
+	// GGUF dims run innermost first: A [vocab × rank] is (rank, vocab)
+	a, err := deltaTensorF16(g, "delta.A", rank, vocabSize)
+	if err != nil {
+		return nil, err
+	}
+	b, err := deltaTensorF16(g, "delta.B", hiddenDim, rank)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DeltaVoice{
+		VocabSize: vocabSize,
+		HiddenDim: hiddenDim,
+		Rank:      rank,
+		Path:      path,
+		A:         a,
+		B:         b,
+		Bx:        make([]float32, rank),
+		ABx:       make([]float32, vocabSize),
+	}
+	if erank := toInt(g.Meta.KV["delta.embed_rank"]); erank > 0 {
+		ea, err := deltaTensorF16(g, "delta.embed.A", erank, vocabSize)
+		if err != nil {
+			return nil, err
+		}
+		eb, err := deltaTensorF16(g, "delta.embed.B", hiddenDim, erank)
+		if err != nil {
+			return nil, err
+		}
+		d.EmbRank, d.EmbA, d.EmbB = erank, ea, eb
+	}
+	d.Meta.BaseModel, _ = g.Meta.KV["delta.base_model"].(string)
+	d.Meta.BaseHash, _ = g.Meta.KV["delta.base_hash"].(string)
+	d.Meta.VocabHash, _ = g.Meta.KV["delta.vocab_hash"].(string)
+	d.Meta.TokenizerHash, _ = g.Meta.KV["delta.tokenizer_hash"].(string)
+	if langs, ok := g.Meta.KV["delta.languages"].([]interface{}); ok {
+		for _, l := range langs {
+			if s, ok := l.(string); ok {
+				d.Meta.Languages = append(d.Meta.Languages, s)
+			}
+		}
+	}
+	fmt.Printf("[delta-voice] loaded: vocab=%d, hidden=%d, rank=%d (gguf, base %s, languages %s)\n",
+		vocabSize, hiddenDim, rank, d.Meta.BaseModel, strings.Join(d.Meta.Languages, ","))
+	return d, nil
+}
+
+// deltaTensorF16 returns a 2-D tensor of GGUF dims (ne0, ne1) as float16
+// bytes, converting F32
+func deltaTensorF16(g *GGUFFile, name string, ne0, ne1 int) ([]byte, error) {
+	data, info, err := g.GetTensor(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.NDims != 2 || info.Dims[0] != uint64(ne0) || info.Dims[1] != uint64(ne1) {
+		return nil, fmt.Errorf("%s: dims %v, expected (%d, %d)", name, info.Dims[:info.NDims], ne0, ne1)
+	}
+	switch info.Type {
+	case ggmlTypeF16:
+		out := make([]byte, len(data))
+		copy(out, data) // the file's buffer is not kept
+		return out, nil
+	case ggmlTypeF32:
+		out := make([]byte, len(data)/2)
+		for i := 0; i < len(data)/4; i++ {
+			v := math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+			binary.LittleEndian.PutUint16(out[i*2:], float2half(v))
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("%s: tensor type %d, expected F16 or F32", name, info.Type)
+	}
+}
+
+// ConvertDelta writes the delta in src (NPZ or GGUF) to dst as a GGUF
+// delta. With weightsPath, the model it was built for is checked against
+// the delta's shape and recorded as its base; languages are stored as given.
+func ConvertDelta(src, dst, weightsPath string, languages []string) (*DeltaVoice, error) {
+	d, err := LoadDelta(src)
+	if err != nil {
+		return nil, fmt.Errorf("load delta: %w", err)
+	}
+	meta := d.Meta
+	if weightsPath != "" {
+		g, err := LoadGGUFMetadata(weightsPath)
+		if err != nil {
+			return nil, fmt.Errorf("load GGUF metadata: %w", err)
+		}
+		if _, err := d.CheckBase(&g.Meta); err != nil {
+			return nil, fmt.Errorf("%s: %w", weightsPath, err)
+		}
+		meta.BaseModel, _ = g.Meta.KV["general.name"].(string)
+		if meta.BaseModel == "" {
+			meta.BaseModel = strings.TrimSuffix(filepath.Base(weightsPath), ".gguf")
+		}
+		meta.SetBase(&g.Meta)
+	}
+	if len(languages) > 0 {
+		meta.Languages = languages
+	}
+	if err := WriteDeltaGGUF(dst, d, meta); err != nil {
+		return nil, fmt.Errorf("write delta: %w", err)
+	}
+	d.Meta = meta
+	return d, nil
+}
+
+// WriteDeltaGGUF writes d as a GGUF delta with meta as its provenance
+func WriteDeltaGGUF(path string, d *DeltaVoice, meta DeltaMeta) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	le := func(v interface{}) { binary.Write(w, binary.LittleEndian, v) }
+	str := func(s string) { le(uint64(len(s))); w.WriteString(s) }
+	kvString := func(key, val string) { str(key); le(uint32(ggufTypeString)); str(val) }
+	kvUint32 := func(key string, val int) { str(key); le(uint32(ggufTypeUint32)); le(uint32(val)) }
+
+	type tensor struct {
+		name     string
+		ne0, ne1 int
+		data     []byte
+		offset   uint64
+	}
+	tensors := []tensor{
+		{name: "delta.A", ne0: d.Rank, ne1: d.VocabSize, data: d.DenseA()},
+		{name: "delta.B", ne0: d.HiddenDim, ne1: d.Rank, data: d.B},
+	}
+	if d.HasEmbed() {
+		tensors = append(tensors,
+			tensor{name: "delta.embed.A", ne0: d.EmbRank, ne1: d.VocabSize, data: d.EmbA},
+			tensor{name: "delta.embed.B", ne0: d.HiddenDim, ne1: d.EmbRank, data: d.EmbB})
+	}
+	// Each tensor starts at a 32-byte boundary of the data blob
+	var end uint64
+	for i := range tensors {
+		tensors[i].offset = (end + 31) / 32 * 32
+		end = tensors[i].offset + uint64(len(tensors[i].data))
+	}
+
+	kvCount := 9
+	if d.HasEmbed() {
+		kvCount++
+	}
+	le(uint32(ggufMagic))
+	le(uint32(ggufVersion))
+	le(uint64(len(tensors)))
+	le(uint64(kvCount))
+	kvString("general.architecture", deltaArch)
+	kvUint32("delta.rank", d.Rank)
+	kvUint32("delta.vocab_size", d.VocabSize)
+	kvUint32("delta.hidden_dim", d.HiddenDim)
+	if d.HasEmbed() {
+		kvUint32("delta.embed_rank", d.EmbRank)
+	}
+	kvString("delta.base_model", meta.BaseModel)
+	kvString("delta.base_hash", meta.BaseHash)
+	kvString("delta.vocab_hash", meta.VocabHash)
+	kvString("delta.tokenizer_hash", meta.TokenizerHash)
+	str("delta.languages")
+	le(uint32(ggufTypeArray))
+	le(uint32(ggufTypeString))
+	le(uint64(len(meta.Languages)))
+	for _, l := range meta.Languages {
+		str(l)
+	}
+
+	for _, t := range tensors {
+		str(t.name)
+		le(uint32(2))
+		le(uint64(t.ne0))
+		le(uint64(t.ne1))
+		le(uint32(ggmlTypeF16))
+		le(t.offset)
+	}
+
+	// Header size decides the padding before the data blob
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.Write(make([]byte, (32-pos%32)%32))
+	var at uint64
+	for _, t := range tensors {
+		w.Write(make([]byte, t.offset-at))
+		w.Write(t.data)
+		at = t.offset + uint64(len(t.data))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
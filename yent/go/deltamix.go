@@ -0,0 +1,237 @@
+package yent
+
+// deltamix.go — Several Delta Voices at once
+//
+// A language delta says which tongue, a style delta says how; one active
+// delta meant choosing. A mix applies several with their own weights:
+//
+//   logits += alpha × Σ wᵢ · Aᵢ (Bᵢ x)
+//
+// The mix is built once as an ordinary delta whose rank is the sum of its
+// parts: A = [w₁A₁ | w₂A₂ | …], B = [B₁; B₂; …]. One pass through the F16
+// kernels computes the whole sum, and everything that takes a delta (the
+// registry, GenerateOptions.Delta, beams, the embedding side) takes a mix.
+// DeltaAlpha still scales the result; /mix sets it to 1 so the weights
+// read as alphas.
+//
+//   y.MixDeltas("mix", []DeltaWeight{{"ru", 0.5}, {"style", 0.3}})
+//   y.UseDelta("mix")
+//
+// Parts must agree on vocabulary and hidden size; ranks may differ. The
+// report says what the mix costs per token against a forward pass.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DeltaWeight is one delta of a mix and its weight
+type DeltaWeight struct {
+	Name  string  `json:"name"`
+	Alpha float32 `json:"alpha"`
+}
+
+// ParseDeltaMix parses "ru:0.5,style:0.3": loaded delta names and weights
+func ParseDeltaMix(spec string) ([]DeltaWeight, error) {
+	var mix []DeltaWeight
+	seen := make(map[string]bool)
+	for _, part := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == ' ' }) {
+		name, w, ok := strings.Cut(part, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("delta mix %q is not name:weight", part)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(w), 32)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("delta mix %q: bad weight", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("delta %q mixed twice", name)
+		}
+		seen[name] = true
+		mix = append(mix, DeltaWeight{Name: name, Alpha: float32(v)})
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("empty delta mix")
+	}
+	return mix, nil
+}
+
+// DeltaMixPart is one delta's share of a mix
+type DeltaMixPart struct {
+	Name    string  `json:"name"`
+	Alpha   float32 `json:"alpha"`
+	Rank    int     `json:"rank"`
+	EmbRank int     `json:"embed_rank,omitempty"`
+	FMAs    int64   `json:"fmas"` // multiply-adds per token
+}
+
+// DeltaMixReport describes a built mix and its cost
+type DeltaMixReport struct {
+	Name         string         `json:"name"`
+	Parts        []DeltaMixPart `json:"parts"`
+	Rank         int            `json:"rank"`
+	EmbRank      int            `json:"embed_rank,omitempty"`
+	FMAs         int64          `json:"fmas"`          // multiply-adds per token
+	Bytes        int64          `json:"bytes"`         // A and B (and the embedding pair), float16
+	ForwardShare float64        `json:"forward_share"` // FMAs / a forward pass's
+}
+
+// String formats the report for the REPL
+func (r *DeltaMixReport) String() string {
+	var b strings.Builder
+	for _, p := range r.Parts {
+		fmt.Fprintf(&b, "  %-10s ×%-5.2f rank %-4d %6.1fM FMA/token\n", p.Name, p.Alpha, p.Rank, float64(p.FMAs)/1e6)
+	}
+	fmt.Fprintf(&b, "  %-10s        rank %-4d %6.1fM FMA/token (%.1f%% of a forward pass), %s",
+		r.Name, r.Rank, float64(r.FMAs)/1e6, r.ForwardShare*100, formatBytes(r.Bytes))
+	return b.String()
+}
+
+// deltaFMAs is the per-token cost of applying d
+func deltaFMAs(d *DeltaVoice) int64 {
+	rows := d.VocabSize
+	if d.Sparse() {
+		rows = len(d.Rows)
+	}
+	return int64(d.Rank)*int64(rows+d.HiddenDim) + int64(d.EmbRank)*int64(d.HiddenDim)
+}
+
+// forwardFMAs estimates a forward pass's multiply-adds: every weight once
+func forwardFMAs(cfg *LlamaConfig) int64 {
+	dim := int64(cfg.EmbedDim)
+	qDim := int64(cfg.NumHeads * cfg.HeadDim)
+	kvDim := int64(cfg.NumKVHeads * cfg.HeadDim)
+	mlp := 3 * dim * int64(cfg.IntermSize)
+	if cfg.GELU {
+		mlp = 2 * dim * int64(cfg.IntermSize) // no gate
+	}
+	layer := dim*(qDim+2*kvDim) + qDim*dim + mlp
+	return int64(cfg.NumLayers)*layer + int64(cfg.VocabSize)*dim
+}
+
+// ComposeDeltas builds one delta computing Σ alphas[i] · parts[i]. Parts
+// must share vocabulary and hidden size.
+func ComposeDeltas(parts []*DeltaVoice, alphas []float32) (*DeltaVoice, error) {
+	if len(parts) == 0 || len(parts) != len(alphas) {
+		return nil, fmt.Errorf("compose: %d deltas, %d weights", len(parts), len(alphas))
+	}
+	vocab, hidden := parts[0].VocabSize, parts[0].HiddenDim
+	rank, erank := 0, 0
+	for i, p := range parts {
+		if p.VocabSize != vocab || p.HiddenDim != hidden {
+			return nil, fmt.Errorf("compose: delta %d is vocab %d × hidden %d, delta 0 is %d × %d",
+				i, p.VocabSize, p.HiddenDim, vocab, hidden)
+		}
+		rank += p.Rank
+		erank += p.EmbRank
+	}
+
+	d := &DeltaVoice{
+		VocabSize: vocab,
+		HiddenDim: hidden,
+		Rank:      rank,
+		A:         make([]byte, vocab*rank*2),
+		B:         make([]byte, 0, rank*hidden*2),
+		Bx:        make([]float32, rank),
+		ABx:       make([]float32, vocab),
+	}
+	col := 0
+	for i, p := range parts {
+		scaleColumns(d.A, rank, col, p.DenseA(), p.Rank, vocab, alphas[i])
+		d.B = append(d.B, p.B...)
+		col += p.Rank
+	}
+	if erank > 0 {
+		d.EmbRank = erank
+		d.EmbA = make([]byte, vocab*erank*2)
+		d.EmbB = make([]byte, 0, erank*hidden*2)
+		col = 0
+		for i, p := range parts {
+			if !p.HasEmbed() {
+				continue
+			}
+			scaleColumns(d.EmbA, erank, col, p.EmbA, p.EmbRank, vocab, alphas[i])
+			d.EmbB = append(d.EmbB, p.EmbB...)
+			col += p.EmbRank
+		}
+	}
+	return d, nil
+}
+
+// scaleColumns copies src [rows × srcCols] times alpha into columns
+// col.. of dst [rows × dstCols], all float16
+func scaleColumns(dst []byte, dstCols, col int, src []byte, srcCols, rows int, alpha float32) {
+	for r := 0; r < rows; r++ {
+		for c := 0; c < srcCols; c++ {
+			v := half2float(binary.LittleEndian.Uint16(src[(r*srcCols+c)*2:]))
+			binary.LittleEndian.PutUint16(dst[(r*dstCols+col+c)*2:], float2half(alpha*v))
+		}
+	}
+}
+
+// MixDeltas composes loaded deltas into a delta registered as name
+// (replacing a mix of that name) and reports what it costs
+func (y *Yent) MixDeltas(name string, mix []DeltaWeight) (*DeltaMixReport, error) {
+	if name == "" {
+		return nil, fmt.Errorf("delta mix needs a name")
+	}
+	y.mu.Lock()
+	if y.model == nil {
+		y.mu.Unlock()
+		return nil, fmt.Errorf("yent not initialized")
+	}
+	cfg := y.model.Config
+	parts := make([]*DeltaVoice, len(mix))
+	alphas := make([]float32, len(mix))
+	report := &DeltaMixReport{Name: name}
+	for i, m := range mix {
+		if m.Name == name {
+			y.mu.Unlock()
+			return nil, fmt.Errorf("mix %q cannot contain itself", name)
+		}
+		d, ok := y.deltas[m.Name]
+		if !ok {
+			y.mu.Unlock()
+			return nil, fmt.Errorf("no delta %q loaded", m.Name)
+		}
+		if d.VocabSize != cfg.VocabSize || d.HiddenDim != cfg.EmbedDim {
+			y.mu.Unlock()
+			return nil, fmt.Errorf("delta %q is vocab %d × hidden %d, model is %d × %d",
+				m.Name, d.VocabSize, d.HiddenDim, cfg.VocabSize, cfg.EmbedDim)
+		}
+		parts[i], alphas[i] = d, m.Alpha
+		report.Parts = append(report.Parts, DeltaMixPart{Name: m.Name, Alpha: m.Alpha,
+			Rank: d.Rank, EmbRank: d.EmbRank, FMAs: deltaFMAs(d)})
+	}
+	if old, ok := y.deltas[name]; ok && old.Mix == nil {
+		y.mu.Unlock()
+		return nil, fmt.Errorf("%q is a loaded delta, not a mix", name)
+	}
+	y.mu.Unlock()
+
+	// Build outside the lock: generations carry on meanwhile
+	d, err := ComposeDeltas(parts, alphas)
+	if err != nil {
+		return nil, err
+	}
+	d.Mix = append([]DeltaWeight(nil), mix...)
+
+	y.mu.Lock()
+	old := y.deltas[name]
+	y.deltas[name] = d
+	if y.delta != nil && y.delta == old {
+		y.delta = d
+	}
+	y.mu.Unlock()
+
+	report.Rank, report.EmbRank, report.FMAs = d.Rank, d.EmbRank, deltaFMAs(d)
+	report.Bytes = int64(len(d.A) + len(d.B) + len(d.EmbA) + len(d.EmbB))
+	if f := forwardFMAs(&cfg); f > 0 {
+		report.ForwardShare = float64(report.FMAs) / float64(f)
+	}
+	return report, nil
+}
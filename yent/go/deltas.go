@@ -0,0 +1,266 @@
+package yent
+
+// deltas.go — Several Delta Voices in one process
+//
+// One delta used to mean one process: a Russian delta, a French one and
+// the experiment extracted last night each wanted their own restart and
+// their own 1 GB of weights. Deltas are small (~20 MB at rank 64), so the
+// instance keeps a registry of named ones:
+//
+//   y.LoadDeltaVoice("ru", "deltas/yent_ru_r64.npz")
+//   y.LoadDeltaVoice("fr", "deltas/yent_fr_r64.npz")
+//   y.UseDelta("fr")                             the active one
+//   GenerateOptions{Delta: "ru"}                 one call, another delta
+//
+// CLI: -delta "ru=deltas/ru.npz;fr=deltas/fr.npz" (a bare path is named
+// "default"); the first one loaded is active. DeltaAlpha still sets how
+// much of the delta speaks, whichever delta that is.
+//
+// ReloadDelta re-reads every delta whose file changed since it was loaded
+// (REPL: /deltas reload), so a re-extracted delta is heard on the next
+// turn without reloading 1 GB of GGUF.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultDeltaName names a delta loaded without one
+const DefaultDeltaName = "default"
+
+// DeltaSpec is a delta to load: its name and file
+type DeltaSpec struct {
+	Name string
+	Path string
+}
+
+// ParseDeltaSpecs parses -delta: a path, or name=path pairs separated by ';'
+func ParseDeltaSpecs(spec string) ([]DeltaSpec, error) {
+	var specs []DeltaSpec
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		ds := DeltaSpec{Name: DefaultDeltaName, Path: part}
+		if name, path, ok := strings.Cut(part, "="); ok {
+			ds = DeltaSpec{Name: strings.TrimSpace(name), Path: strings.TrimSpace(path)}
+		}
+		if ds.Name == "" || ds.Path == "" {
+			return nil, fmt.Errorf("delta %q is not name=path", part)
+		}
+		if seen[ds.Name] {
+			return nil, fmt.Errorf("delta %q given twice", ds.Name)
+		}
+		seen[ds.Name] = true
+		specs = append(specs, ds)
+	}
+	return specs, nil
+}
+
+// DeltaInfo describes a loaded delta
+type DeltaInfo struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Rank   int    `json:"rank"`
+	Active bool   `json:"active"`
+
+	EmbedRank  int           `json:"embed_rank,omitempty"`  // input-side rank (deltaembed.go)
+	SparseRows int           `json:"sparse_rows,omitempty"` // A rows kept by Sparsify (deltasparse.go)
+	Mix        []DeltaWeight `json:"mix,omitempty"`         // parts of a mix (deltamix.go)
+
+	// Provenance of GGUF deltas (deltagguf.go)
+	BaseModel string   `json:"base_model,omitempty"`
+	Languages []string `json:"languages,omitempty"`
+}
+
+// LoadDeltaVoice loads a multilingual delta file under name ("" = "default"),
+// replacing a delta of that name. The first delta loaded becomes active.
+// "from ariannamethod import Destiny"
+func (y *Yent) LoadDeltaVoice(name, deltaPath string) error {
+	if name == "" {
+		name = DefaultDeltaName
+	}
+	d, err := LoadDelta(deltaPath)
+	if err != nil {
+		return fmt.Errorf("load delta: %w", err)
+	}
+	y.sparsify(name, d)
+
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	if y.model == nil {
+		return fmt.Errorf("yent not initialized")
+	}
+	// Validate the delta was built for this model
+	if err := y.checkDeltaBase(name, d, &y.gguf.Meta); err != nil {
+		return err
+	}
+
+	if y.deltas == nil {
+		y.deltas = make(map[string]*DeltaVoice)
+	}
+	old := y.deltas[name]
+	y.deltas[name] = d
+	if y.delta == nil || y.delta == old {
+		y.delta, y.deltaName = d, name
+	}
+	fmt.Printf("[delta-voice] %s loaded: 29 languages available (alpha=%.2f, active %s)\n", name, y.DeltaAlpha, y.deltaName)
+	return nil
+}
+
+// ReloadDelta re-reads the loaded deltas whose file mtime changed and
+// returns their names. A file that no longer loads or fits the model keeps
+// its old delta, and the error says why.
+func (y *Yent) ReloadDelta() ([]string, error) {
+	y.mu.Lock()
+	loaded := make(map[string]*DeltaVoice, len(y.deltas))
+	for name, d := range y.deltas {
+		loaded[name] = d
+	}
+	y.mu.Unlock()
+
+	// Read outside the lock: generations carry on with the old deltas
+	var reloaded []string
+	var errs []error
+	for name, old := range loaded {
+		if old.Mix != nil {
+			continue // rebuilt below when a part changed
+		}
+		st, err := os.Stat(old.Path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("delta %s: %w", name, err))
+			continue
+		}
+		if st.ModTime().Equal(old.ModTime) {
+			continue
+		}
+		d, err := LoadDelta(old.Path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("delta %s: %w", name, err))
+			continue
+		}
+		y.sparsify(name, d)
+
+		y.mu.Lock()
+		switch {
+		case y.model == nil:
+			err = fmt.Errorf("yent not initialized")
+		case y.deltas[name] != old:
+			// unloaded or replaced meanwhile
+		default:
+			if err = y.checkDeltaBase(name, d, &y.gguf.Meta); err != nil {
+				break
+			}
+			y.deltas[name] = d
+			if y.delta == old {
+				y.delta = d
+			}
+			reloaded = append(reloaded, name)
+		}
+		y.mu.Unlock()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	sort.Strings(reloaded)
+	for _, name := range reloaded {
+		fmt.Printf("[delta-voice] %s reloaded from %s\n", name, loaded[name].Path)
+	}
+	for name, old := range loaded {
+		if old.Mix == nil || !mixUses(old.Mix, reloaded) {
+			continue
+		}
+		if _, err := y.MixDeltas(name, old.Mix); err != nil {
+			errs = append(errs, fmt.Errorf("mix %s: %w", name, err))
+		}
+	}
+	return reloaded, errors.Join(errs...)
+}
+
+// mixUses reports whether a mix has one of names among its parts
+func mixUses(mix []DeltaWeight, names []string) bool {
+	for _, m := range mix {
+		for _, n := range names {
+			if m.Name == n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UseDelta makes a loaded delta the active one
+func (y *Yent) UseDelta(name string) error {
+	y.mu.Lock()
+	d, ok := y.deltas[name]
+	if !ok {
+		y.mu.Unlock()
+		return fmt.Errorf("no delta %q loaded", name)
+	}
+	y.delta, y.deltaName = d, name
+	y.mu.Unlock()
+	fmt.Printf("[delta-voice] active delta: %s\n", name)
+	y.saveVoice()
+	return nil
+}
+
+// UnloadDelta drops a delta; when it was active, no delta is
+func (y *Yent) UnloadDelta(name string) error {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	d, ok := y.deltas[name]
+	if !ok {
+		return fmt.Errorf("no delta %q loaded", name)
+	}
+	delete(y.deltas, name)
+	if y.delta == d {
+		y.delta, y.deltaName = nil, ""
+	}
+	return nil
+}
+
+// Deltas lists the loaded deltas by name
+func (y *Yent) Deltas() []DeltaInfo {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	list := make([]DeltaInfo, 0, len(y.deltas))
+	for name, d := range y.deltas {
+		list = append(list, DeltaInfo{Name: name, Path: d.Path, Rank: d.Rank, Active: d == y.delta,
+			EmbedRank: d.EmbRank, SparseRows: len(d.Rows), Mix: d.Mix, BaseModel: d.Meta.BaseModel, Languages: d.Meta.Languages})
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].Name < list[b].Name })
+	return list
+}
+
+// ActiveDelta returns the active delta's name ("" = none)
+func (y *Yent) ActiveDelta() string {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	return y.deltaName
+}
+
+// hasDelta reports whether a delta of that name is loaded
+func (y *Yent) hasDelta(name string) bool {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	_, ok := y.deltas[name]
+	return ok
+}
+
+// deltaFor resolves a call's delta: name "" is the active one (nil when
+// none is loaded)
+func (y *Yent) deltaFor(name string) (*DeltaVoice, error) {
+	if name == "" {
+		return y.delta, nil
+	}
+	d, ok := y.deltas[name]
+	if !ok {
+		return nil, fmt.Errorf("no delta %q loaded", name)
+	}
+	return d, nil
+}
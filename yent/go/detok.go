@@ -0,0 +1,86 @@
+package yent
+
+// detok.go — Streaming detokenizer that only releases whole characters
+//
+// A byte-level vocabulary (GPT-2, or SentencePiece's <0xNN> fallback)
+// splits a multi-byte character across tokens: "é" can arrive as two
+// tokens of one byte each, and an emoji as up to four. DecodeToken returns
+// those fragments as they are, so a streamed piece can end mid-character —
+// a terminal or an SSE client renders U+FFFD — and a byte cap on the
+// output can cut a character in half.
+//
+// TokenDecoder holds back the tail of a piece that starts a character it
+// does not complete, and releases it with the token that does:
+//
+//   token    DecodeToken   Next
+//   " caf"   " caf"        " caf"
+//   0xC3     "\xc3"        ""
+//   0xA9     "\xa9"        "é"
+//
+// Every string Next returns is valid UTF-8. Bytes that can never form a
+// character (a stray continuation byte) come out as U+FFFD immediately
+// instead of being held; Flush does the same for a held tail when the
+// stream ends.
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenDecoder turns a stream of token ids into whole-character text
+type TokenDecoder struct {
+	t       *Tokenizer
+	pending []byte // start of a character still missing bytes
+}
+
+// NewDecoder returns a streaming decoder over t's vocabulary
+func (t *Tokenizer) NewDecoder() *TokenDecoder {
+	return &TokenDecoder{t: t}
+}
+
+// Next decodes id and returns the characters it completes ("" when the
+// token only starts one)
+func (d *TokenDecoder) Next(id int) string {
+	return d.push(d.t.DecodeToken(id))
+}
+
+// push appends raw token bytes and releases what forms whole characters
+func (d *TokenDecoder) push(piece string) string {
+	if len(d.pending) == 0 && utf8.ValidString(piece) {
+		return piece
+	}
+	d.pending = append(d.pending, piece...)
+	n := completePrefix(d.pending)
+	out := strings.ToValidUTF8(string(d.pending[:n]), string(utf8.RuneError))
+	d.pending = append(d.pending[:0], d.pending[n:]...)
+	return out
+}
+
+// Flush returns the held bytes, as U+FFFD, and empties the decoder
+func (d *TokenDecoder) Flush() string {
+	if len(d.pending) == 0 {
+		return ""
+	}
+	out := strings.ToValidUTF8(string(d.pending), string(utf8.RuneError))
+	d.pending = d.pending[:0]
+	return out
+}
+
+// Pending reports how many bytes are held back
+func (d *TokenDecoder) Pending() int {
+	return len(d.pending)
+}
+
+// completePrefix returns the length of b without a trailing character
+// that more bytes could still complete
+func completePrefix(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,359 @@
+package yent
+
+// doctor.go — Startup self-test
+//
+// Most "Yent doesn't start" reports come down to the same handful of
+// causes: a truncated download, a requantized GGUF with a tensor type the
+// matmul can't read, the 1.5B delta next to the 0.5B weights, a binary built
+// without the AMK kernel, no python3 for LIMPHA, an unwritable ~/.yent, or a
+// second instance holding the memory socket. Doctor checks each one and says
+// what to do about it, without starting anything.
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CheckStatus is the outcome of one doctor check
+type CheckStatus int
+
+const (
+	CheckOK CheckStatus = iota
+	CheckSkip
+	CheckWarn
+	CheckFail
+)
+
+// String returns the status label used in reports
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckOK:
+		return "ok"
+	case CheckSkip:
+		return "skip"
+	case CheckWarn:
+		return "warn"
+	default:
+		return "FAIL"
+	}
+}
+
+// DoctorCheck is one diagnosed component
+type DoctorCheck struct {
+	Name   string
+	Status CheckStatus
+	Detail string
+	Fix    string // what to do when Status is Warn or Fail
+}
+
+// DoctorOptions selects what to check ("" = skip that check)
+type DoctorOptions struct {
+	Weights string
+	Delta   string // a path, or name=path pairs separated by ';'
+	DataDir string // "" = ~/.yent
+}
+
+// Doctor runs every check and returns the results in order
+func Doctor(opts DoctorOptions) []DoctorCheck {
+	var checks []DoctorCheck
+
+	gguf, c := checkWeights(opts.Weights)
+	checks = append(checks, c)
+	checks = append(checks, checkQuant(gguf))
+	checks = append(checks, checkDeltas(opts.Delta, gguf)...)
+	checks = append(checks, checkAMK())
+	checks = append(checks, checkPython())
+
+	dataDir := opts.DataDir
+	if dataDir == "" {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			dataDir = filepath.Join(homeDir, ".yent")
+		}
+	}
+	checks = append(checks, checkDataDir(dataDir))
+	checks = append(checks, checkLocks(dataDir))
+	return checks
+}
+
+// DoctorFailed reports whether any check failed
+func DoctorFailed(checks []DoctorCheck) bool {
+	for _, c := range checks {
+		if c.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWeights parses the GGUF header and reads the tensor data
+func checkWeights(path string) (*GGUFFile, DoctorCheck) {
+	c := DoctorCheck{Name: "weights"}
+	if path == "" {
+		c.Status, c.Detail = CheckSkip, "no -weights given"
+		return nil, c
+	}
+	if _, err := os.Stat(path); err != nil {
+		c.Status, c.Detail = CheckFail, err.Error()
+		c.Fix = "download weights with `make download` (saved to ~/.yent/models/) or fix the -weights path"
+		return nil, c
+	}
+	g, err := LoadGGUF(path)
+	if err != nil {
+		c.Status, c.Detail = CheckFail, err.Error()
+		c.Fix = "the file is not a readable GGUF v3 — delete it and download again (`make download`)"
+		return nil, c
+	}
+	c.Detail = fmt.Sprintf("%s: %d tensors, %d layers, dim %d, vocab %d",
+		filepath.Base(path), len(g.Tensors), g.Meta.NumLayers, g.Meta.EmbedDim, g.Meta.VocabSize)
+	return g, c
+}
+
+// checkQuant verifies every tensor has a supported type and lies inside the file
+func checkQuant(g *GGUFFile) DoctorCheck {
+	c := DoctorCheck{Name: "quant"}
+	if g == nil {
+		c.Status, c.Detail = CheckSkip, "no readable weights"
+		return c
+	}
+	types := make(map[uint32]int)
+	var bad, truncated []string
+	for name, info := range g.Tensors {
+		types[info.Type]++
+		// Norms and biases are dequantized whole; Q6_K only has a matmul path
+		if !isSupportedType(info.Type) || (info.NDims == 1 && info.Type == ggmlTypeQ6_K) {
+			bad = append(bad, fmt.Sprintf("%s (%s)", name, ggmlTypeName(info.Type)))
+			continue
+		}
+		if _, _, err := g.GetTensor(name); err != nil {
+			truncated = append(truncated, name)
+		}
+	}
+
+	var names []string
+	for t, n := range types {
+		names = append(names, fmt.Sprintf("%s×%d", ggmlTypeName(t), n))
+	}
+	sort.Strings(names)
+	c.Detail = strings.Join(names, " ") + ", " + SIMD() + " kernels"
+
+	switch {
+	case len(truncated) > 0:
+		sort.Strings(truncated)
+		c.Status = CheckFail
+		c.Detail = fmt.Sprintf("%d tensors past end of file (first: %s)", len(truncated), truncated[0])
+		c.Fix = "the download was cut short — delete the GGUF and download again"
+	case len(bad) > 0:
+		sort.Strings(bad)
+		c.Status = CheckFail
+		c.Detail = fmt.Sprintf("%d unsupported tensors: %s", len(bad), strings.Join(firstN(bad, 3), ", "))
+		c.Fix = "requantize to Q4_0 or Q8_0 (llama-quantize model-f16.gguf out.gguf q4_0); supported: F32, F16, Q4_0, Q8_0, Q6_K (matmul only)"
+	}
+	return c
+}
+
+// checkDeltas runs checkDelta on each delta of a -delta spec
+func checkDeltas(spec string, g *GGUFFile) []DoctorCheck {
+	specs, err := ParseDeltaSpecs(spec)
+	if err != nil {
+		return []DoctorCheck{{Name: "delta", Status: CheckFail, Detail: err.Error(),
+			Fix: "-delta takes a path, or name=path pairs separated by ';'"}}
+	}
+	if len(specs) <= 1 {
+		path := ""
+		if len(specs) == 1 {
+			path = specs[0].Path
+		}
+		return []DoctorCheck{checkDelta(path, g)}
+	}
+	checks := make([]DoctorCheck, 0, len(specs))
+	for _, ds := range specs {
+		c := checkDelta(ds.Path, g)
+		c.Name = "delta " + ds.Name
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// checkDelta loads the delta and matches its shape and fingerprint
+// against the model
+func checkDelta(path string, g *GGUFFile) DoctorCheck {
+	c := DoctorCheck{Name: "delta"}
+	if path == "" {
+		c.Status, c.Detail = CheckSkip, "no -delta given (English only)"
+		return c
+	}
+	d, err := LoadDelta(path)
+	if err != nil {
+		c.Status, c.Detail = CheckFail, err.Error()
+		c.Fix = "deltas ship in deltas/ — check the -delta path, or `git checkout deltas/` if the file was damaged"
+		return c
+	}
+	c.Detail = fmt.Sprintf("%s: vocab %d, hidden %d, rank %d", filepath.Base(path), d.VocabSize, d.HiddenDim, d.Rank)
+	if g == nil {
+		return c
+	}
+	warning, err := d.CheckBase(&g.Meta)
+	switch {
+	case err != nil && (d.VocabSize != g.Meta.VocabSize || d.HiddenDim != g.Meta.EmbedDim):
+		c.Status, c.Detail = CheckFail, err.Error()
+		c.Fix = "use the delta for this model size: yent_05b (0.5B), yent_1.5b (1.5B), yent_3b (3B)"
+	case err != nil:
+		c.Status, c.Detail = CheckFail, err.Error()
+		c.Fix = "use the delta extracted from this model (delta-extract), or -delta-force if you know the vocabularies agree"
+	case warning != "":
+		c.Status, c.Detail = CheckWarn, warning
+	}
+	return c
+}
+
+// checkAMK runs one DSL command through the C kernel
+func checkAMK() DoctorCheck {
+	c := DoctorCheck{Name: "amk"}
+	a := NewAMK()
+	if err := a.Exec("VELOCITY WALK"); err != nil {
+		c.Status, c.Detail = CheckFail, err.Error()
+		c.Fix = "rebuild the kernel: `make yent/c/libamk.a`, then `CGO_ENABLED=1 go build`"
+		return c
+	}
+	t := a.GetTemperature()
+	a.ResetField()
+	if t <= 0 {
+		c.Status, c.Detail = CheckFail, fmt.Sprintf("kernel reports temperature %.2f after VELOCITY WALK", t)
+		c.Fix = "yent/c/libamk.a is stale — rebuild it with `make yent/c/libamk.a`"
+		return c
+	}
+	c.Detail = fmt.Sprintf("kernel linked (CGO), WALK temperature %.2f", t)
+	return c
+}
+
+// checkPython imports the LIMPHA daemon module without starting it
+func checkPython() DoctorCheck {
+	c := DoctorCheck{Name: "limpha"}
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		c.Status, c.Detail = CheckFail, "python3 not on PATH"
+		c.Fix = "install Python 3.8+ — LIMPHA memory runs as a python3 daemon (Yent still runs without memory)"
+		return c
+	}
+	limphaDir := findLimphaDir()
+	if limphaDir == "" {
+		c.Status, c.Detail = CheckFail, "limpha/ directory not found"
+		c.Fix = "run from the repo root, or keep limpha/ next to the yent binary"
+		return c
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, python, "-c", "import sqlite3, limpha.server")
+	cmd.Dir = filepath.Dir(limphaDir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		c.Status = CheckFail
+		c.Detail = lastLine(string(out))
+		if c.Detail == "" {
+			c.Detail = err.Error()
+		}
+		if i := strings.Index(c.Detail, "No module named '"); i >= 0 {
+			module := strings.SplitN(c.Detail[i+len("No module named '"):], "'", 2)[0]
+			c.Fix = "pip install " + strings.SplitN(module, ".", 2)[0]
+		} else {
+			c.Fix = "the daemon can't import — check the Python version (3.8+) and that sqlite3 is built in"
+		}
+		return c
+	}
+	c.Detail = fmt.Sprintf("%s imports limpha.server from %s", python, limphaDir)
+	return c
+}
+
+// checkDataDir verifies the data directory is writable
+func checkDataDir(dir string) DoctorCheck {
+	c := DoctorCheck{Name: "data-dir"}
+	if dir == "" {
+		c.Status, c.Detail = CheckFail, "no home directory"
+		c.Fix = "set $HOME"
+		return c
+	}
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		c.Status, c.Detail = CheckWarn, dir+" does not exist yet"
+		c.Fix = "nothing to do — it is created on first run (or `mkdir -p " + dir + "`)"
+		return c
+	}
+	if err != nil || !info.IsDir() {
+		c.Status, c.Detail = CheckFail, dir+" is not a directory"
+		c.Fix = "move it aside: mv " + dir + " " + dir + ".bak"
+		return c
+	}
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		c.Status, c.Detail = CheckFail, err.Error()
+		c.Fix = "make it writable: chmod u+rwx " + dir + " (or chown it to this user)"
+		return c
+	}
+	f.Close()
+	os.Remove(f.Name())
+	c.Detail = dir + " writable"
+	return c
+}
+
+// checkLocks looks for a live LIMPHA socket or an unfinished SQLite journal
+func checkLocks(dir string) DoctorCheck {
+	c := DoctorCheck{Name: "locks", Detail: "no LIMPHA socket or pending journal"}
+	if dir == "" {
+		c.Status = CheckSkip
+		return c
+	}
+	socketPath := filepath.Join(dir, "limpha.sock")
+	live := false
+	if _, err := os.Stat(socketPath); err == nil {
+		if conn, err := net.DialTimeout("unix", socketPath, time.Second); err == nil {
+			conn.Close()
+			live = true
+			c.Status = CheckWarn
+			c.Detail = "another Yent's LIMPHA daemon is listening on " + socketPath
+			c.Fix = "stop the other instance first — starting here replaces its socket and cuts its memory off"
+		} else {
+			c.Detail = "stale limpha.sock (removed on next start)"
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "limpha.db-journal")); err == nil && !live {
+		c.Status = CheckWarn
+		c.Detail = "limpha.db-journal left by an interrupted write"
+		c.Fix = "nothing to do unless it persists — SQLite rolls it back on next open"
+	}
+	return c
+}
+
+// ggmlTypeName names a GGML tensor type
+func ggmlTypeName(t uint32) string {
+	names := map[uint32]string{
+		ggmlTypeF32: "F32", ggmlTypeF16: "F16", ggmlTypeQ4_0: "Q4_0", ggmlTypeQ4_1: "Q4_1",
+		ggmlTypeQ5_0: "Q5_0", ggmlTypeQ5_1: "Q5_1", ggmlTypeQ8_0: "Q8_0", ggmlTypeQ8_1: "Q8_1",
+		ggmlTypeQ2_K: "Q2_K", ggmlTypeQ3_K: "Q3_K", ggmlTypeQ4_K: "Q4_K", ggmlTypeQ5_K: "Q5_K",
+		ggmlTypeQ6_K: "Q6_K",
+	}
+	if n, ok := names[t]; ok {
+		return n
+	}
+	return fmt.Sprintf("type%d", t)
+}
+
+// firstN returns at most n leading items
+func firstN(items []string, n int) []string {
+	if len(items) > n {
+		return items[:n]
+	}
+	return items
+}
+
+// lastLine returns the last non-empty line of s
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,147 @@
+package yent
+
+// dry.go — DRY: don't repeat yourself
+//
+// The repetition penalty divides the logit of every recent token, which
+// punishes "the" as hard as a looping phrase and still lets the 1.5B model
+// circle back to the same sentence. DRY looks at sequences instead: if the
+// last tokens already occurred earlier, the token that followed them back
+// then would extend the repeat, and is penalized by how long the repeat is:
+//
+//   penalty = Multiplier · Base^(match length - AllowedLength)
+//
+// Matches shorter than AllowedLength are free (names, "of the"). Sequence
+// breakers — tokens containing a newline, colon, quote or asterisk by
+// default — end a match, so repeating a format ("### Answer:") is fine.
+
+import "strings"
+
+// DRYConfig configures the DRY sampler
+type DRYConfig struct {
+	Multiplier    float32  // penalty scale (0 = off)
+	Base          float32  // growth per token beyond AllowedLength (0 = 1.75)
+	AllowedLength int      // longest repeat that is free (0 = 2)
+	Range         int      // tokens of history to search (0 = whole context)
+	Breakers      []string // tokens containing any of these end a match (nil = defaults)
+}
+
+// dryMaxMatch caps match length: the penalty is already overwhelming
+const dryMaxMatch = 64
+
+// DefaultDRYBreakers are the sequence breakers used when none are given
+var DefaultDRYBreakers = []string{"\n", ":", "\"", "*"}
+
+// DefaultDRYConfig returns the commonly used settings (multiplier 0.8)
+func DefaultDRYConfig() DRYConfig {
+	return DRYConfig{Multiplier: 0.8, Base: 1.75, AllowedLength: 2}
+}
+
+// Penalties returns the logit penalty for each token that would extend a
+// repeated sequence at the end of history
+func (c DRYConfig) Penalties(history []int, isBreaker func(tok int) bool) map[int]float32 {
+	if c.Multiplier <= 0 || len(history) < 2 {
+		return nil
+	}
+	base := c.Base
+	if base <= 0 {
+		base = 1.75
+	}
+	allowed := c.AllowedLength
+	if allowed <= 0 {
+		allowed = 2
+	}
+	if c.Range > 0 && len(history) > c.Range {
+		history = history[len(history)-c.Range:]
+	}
+
+	n := len(history)
+	last := history[n-1]
+	if isBreaker(last) {
+		return nil
+	}
+
+	// Every earlier occurrence of the last token is a candidate repeat;
+	// walk back while the tokens before it match the tokens before the end
+	lengths := make(map[int]int)
+	for i := n - 2; i >= 0; i-- {
+		if history[i] != last {
+			continue
+		}
+		next := history[i+1]
+		if isBreaker(next) {
+			continue
+		}
+		length := 1
+		for length < dryMaxMatch {
+			j := i - length
+			if j < 0 {
+				break
+			}
+			tok := history[j]
+			if tok != history[n-1-length] || isBreaker(tok) {
+				break
+			}
+			length++
+		}
+		if length > lengths[next] {
+			lengths[next] = length
+		}
+	}
+
+	penalties := make(map[int]float32)
+	for tok, length := range lengths {
+		if length >= allowed {
+			penalties[tok] = c.Multiplier * pow32(base, length-allowed)
+		}
+	}
+	return penalties
+}
+
+// pow32 returns b^e for small non-negative e
+func pow32(b float32, e int) float32 {
+	r := float32(1)
+	for ; e > 0; e-- {
+		r *= b
+	}
+	return r
+}
+
+// dryBreakerMask marks tokens whose text contains a sequence breaker
+// (control tokens always break). Cached per breaker set.
+func (y *Yent) dryBreakerMask(breakers []string) []bool {
+	if breakers == nil {
+		breakers = DefaultDRYBreakers
+	}
+	key := strings.Join(breakers, "\x00")
+	if y.dryMask != nil && y.dryKey == key {
+		return y.dryMask
+	}
+	pieces := y.tokenPieces()
+	mask := make([]bool, len(pieces))
+	for id, piece := range pieces {
+		if piece == nil {
+			mask[id] = true
+			continue
+		}
+		for _, b := range breakers {
+			if b != "" && strings.Contains(string(piece), b) {
+				mask[id] = true
+				break
+			}
+		}
+	}
+	y.dryKey, y.dryMask = key, mask
+	return mask
+}
+
+// applyDRY subtracts DRY penalties from the current logits
+func (y *Yent) applyDRY(cfg DRYConfig, history []int) {
+	mask := y.dryBreakerMask(cfg.Breakers)
+	isBreaker := func(tok int) bool { return tok < 0 || tok >= len(mask) || mask[tok] }
+	logits := y.model.State.Logits
+	for tok, p := range cfg.Penalties(history, isBreaker) {
+		if tok >= 0 && tok < y.model.Config.VocabSize {
+			logits[tok] -= p
+		}
+	}
+}
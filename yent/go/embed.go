@@ -0,0 +1,315 @@
+package yent
+
+// embed.go — Pluggable text embedders for semantic memory
+//
+// FTS5 finds words. Resonance needs meaning. Every vector-based feature
+// (semantic search, clustering) goes through one interface:
+//
+//   model   — Yent's own hidden states, mean-pooled over tokens (no extra deps)
+//   onnx    — small sentence encoder (e.g. all-MiniLM-L6-v2) via onnxruntime
+//             in a Python helper (limpha/embed_onnx.py), JSON lines over stdio
+//   remote  — HTTP embedder, OpenAI-compatible /v1/embeddings
+//
+// Vectors are L2-normalized so cosine similarity is a dot product.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Embedder turns text into a vector
+type Embedder interface {
+	Embed(text string) ([]float32, error)
+}
+
+// Embedder types
+const (
+	EmbedderModel  = "model"
+	EmbedderONNX   = "onnx"
+	EmbedderRemote = "remote"
+)
+
+// EmbedderConfig selects and configures an embedder
+type EmbedderConfig struct {
+	Type    string        `json:"type"`    // model, onnx, remote ("" = model)
+	Model   string        `json:"model"`   // onnx: encoder .onnx path; remote: model name
+	URL     string        `json:"url"`     // remote: endpoint (…/v1/embeddings)
+	APIKey  string        `json:"api_key"` // remote: bearer token ("" = $YENT_EMBED_API_KEY)
+	Timeout time.Duration `json:"timeout"` // remote/onnx request timeout (0 = 30s)
+	MaxLen  int           `json:"max_len"` // model: max tokens embedded (0 = 256)
+}
+
+// NewEmbedder builds the embedder described by cfg.
+// y is only needed for the model embedder.
+func NewEmbedder(cfg EmbedderConfig, y *Yent) (Embedder, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	switch strings.ToLower(cfg.Type) {
+	case "", EmbedderModel:
+		if y == nil {
+			return nil, fmt.Errorf("model embedder needs a loaded model")
+		}
+		maxLen := cfg.MaxLen
+		if maxLen <= 0 {
+			maxLen = 256
+		}
+		return &ModelEmbedder{y: y, MaxLen: maxLen}, nil
+	case EmbedderONNX:
+		if cfg.Model == "" {
+			return nil, fmt.Errorf("onnx embedder needs a model path")
+		}
+		return &ONNXEmbedder{ModelPath: cfg.Model, Timeout: cfg.Timeout}, nil
+	case EmbedderRemote:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("remote embedder needs a url")
+		}
+		key := cfg.APIKey
+		if key == "" {
+			key = os.Getenv("YENT_EMBED_API_KEY")
+		}
+		return &RemoteEmbedder{
+			URL:    cfg.URL,
+			Model:  cfg.Model,
+			APIKey: key,
+			Client: &http.Client{Timeout: cfg.Timeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown embedder %q (model, onnx, remote)", cfg.Type)
+	}
+}
+
+// ═══ model: Yent's own hidden states ═══
+
+// ModelEmbedder mean-pools the final hidden state over the prompt's tokens
+type ModelEmbedder struct {
+	y      *Yent
+	MaxLen int // tokens beyond this are ignored
+}
+
+// Embed runs text through the transformer (no LM head) and pools the hidden states
+func (e *ModelEmbedder) Embed(text string) ([]float32, error) {
+	y := e.y
+	y.mu.Lock()
+	defer y.mu.Unlock()
+
+	if y.model == nil || y.tokenizer == nil {
+		return nil, fmt.Errorf("yent not initialized")
+	}
+	tokens := y.tokenizer.Encode(text, false)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty text")
+	}
+	limit := e.MaxLen
+	if limit > y.model.Config.SeqLen {
+		limit = y.model.Config.SeqLen
+	}
+	if len(tokens) > limit {
+		tokens = tokens[:limit]
+	}
+
+	dim := y.model.Config.EmbedDim
+	pooled := make([]float32, dim)
+	y.model.Reset()
+	for pos, tok := range tokens {
+		y.model.ForwardHidden(tok, pos)
+		for i, v := range y.model.State.X[:dim] {
+			pooled[i] += v
+		}
+	}
+	y.model.Reset()
+	return normalizeVec(pooled), nil
+}
+
+// ═══ onnx: sentence encoder via Python helper ═══
+
+// ONNXEmbedder runs an ONNX sentence encoder in a long-lived Python helper.
+// Go has no onnxruntime without cgo bindings; LIMPHA's Python is already here.
+type ONNXEmbedder struct {
+	ModelPath string
+	Timeout   time.Duration
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	reader *bufio.Reader
+}
+
+// Embed sends text to the helper and reads one vector back
+func (e *ONNXEmbedder) Embed(text string) ([]float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cmd == nil {
+		if err := e.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	req, _ := json.Marshal(map[string]string{"text": text})
+	if _, err := e.stdin.Write(append(req, '\n')); err != nil {
+		e.stop()
+		return nil, fmt.Errorf("onnx helper write: %w", err)
+	}
+
+	type result struct {
+		line []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		line, err := e.reader.ReadBytes('\n')
+		done <- result{line, err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-time.After(e.Timeout):
+		e.stop()
+		return nil, fmt.Errorf("onnx helper timed out")
+	}
+	if r.err != nil {
+		e.stop()
+		return nil, fmt.Errorf("onnx helper read: %w", r.err)
+	}
+
+	var resp struct {
+		Embedding []float32 `json:"embedding"`
+		Error     string    `json:"error"`
+	}
+	if err := json.Unmarshal(r.line, &resp); err != nil {
+		return nil, fmt.Errorf("onnx helper: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("onnx helper: %s", resp.Error)
+	}
+	return normalizeVec(resp.Embedding), nil
+}
+
+// Close stops the helper process
+func (e *ONNXEmbedder) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stop()
+}
+
+// start spawns python3 -m limpha.embed_onnx next to the LIMPHA package
+func (e *ONNXEmbedder) start() error {
+	limphaDir := findLimphaDir()
+	if limphaDir == "" {
+		return fmt.Errorf("limpha directory not found (needed for onnx helper)")
+	}
+	cmd := exec.Command("python3", "-m", "limpha.embed_onnx", "--model", e.ModelPath)
+	cmd.Dir = filepath.Dir(limphaDir)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("onnx helper stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("onnx helper stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start onnx helper: %w", err)
+	}
+	e.cmd, e.stdin, e.reader = cmd, stdin, bufio.NewReader(stdout)
+	fmt.Printf("[embed] onnx helper started (%s)\n", filepath.Base(e.ModelPath))
+	return nil
+}
+
+// stop kills the helper; the next Embed restarts it
+func (e *ONNXEmbedder) stop() {
+	if e.cmd == nil {
+		return
+	}
+	e.stdin.Close()
+	if e.cmd.Process != nil {
+		e.cmd.Process.Kill()
+	}
+	e.cmd.Wait()
+	e.cmd = nil
+}
+
+// ═══ remote: HTTP embedder ═══
+
+// RemoteEmbedder calls an OpenAI-compatible embeddings endpoint.
+// Plain {"embedding": [...]} responses are accepted too.
+type RemoteEmbedder struct {
+	URL    string
+	Model  string
+	APIKey string
+	Client *http.Client
+}
+
+// Embed posts text and decodes the first embedding
+func (e *RemoteEmbedder) Embed(text string) ([]float32, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"input": text,
+		"model": e.Model,
+	})
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("remote embed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remote embed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("remote embed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var out struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("remote embed decode: %w", err)
+	}
+	vec := out.Embedding
+	if len(out.Data) > 0 {
+		vec = out.Data[0].Embedding
+	}
+	if len(vec) == 0 {
+		return nil, fmt.Errorf("remote embed: empty embedding")
+	}
+	return normalizeVec(vec), nil
+}
+
+// normalizeVec scales v to unit length in place (zero vectors stay zero)
+func normalizeVec(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	inv := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= inv
+	}
+	return v
+}
@@ -0,0 +1,205 @@
+package yent
+
+// events.go — One bus for everything that happens
+//
+// The subsystems used to talk to the outside world through printf: the web
+// UI scraped nothing, a webhook had nothing to hook, and metrics would have
+// meant a counter in every module. Now they publish Events to the
+// instance's EventBus and integrations subscribe:
+//
+//   generation.started    prompt length, session
+//   token                 every sampled piece (high volume: subscribe by name)
+//   generation.finished   tokens, duration, cancelled
+//   memory.stored         a turn reached LIMPHA
+//   episode.created       a shard export graduated conversations to training
+//   amk                   the field changed velocity mid-generation
+//   amk.wormhole          a wormhole opened (amkwatch.go)
+//   amk.debt              prophecy debt crossed its threshold
+//   amk.pain              pain spiked within one token
+//   context.shift         the KV cache filled and was shifted
+//   dream.completed       a maintenance job (LIMPHA's nightly cycle) finished
+//
+// Publishing never blocks the token loop: each subscriber has a buffered
+// channel, and a subscriber that falls behind loses events (counted in
+// Dropped) rather than stalling generation. Events nobody subscribed to
+// are not built at all. Instances sharing a field (pool.go) share the bus.
+//
+// EventBus.On runs a callback per event instead of handing out a channel.
+//
+// The HTTP API streams the bus as server-sent events on
+// GET /v1/admin/events?kinds=token,generation.finished.
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event kinds
+const (
+	EventGenerationStarted  = "generation.started"
+	EventToken              = "token"
+	EventGenerationFinished = "generation.finished"
+	EventMemoryStored       = "memory.stored"
+	EventEpisodeCreated     = "episode.created"
+	EventAMK                = "amk"
+	EventWormhole           = "amk.wormhole"
+	EventDebt               = "amk.debt"
+	EventPain               = "amk.pain"
+	EventContextShift       = "context.shift"
+	EventDreamCompleted     = "dream.completed"
+)
+
+// EventKinds lists every kind the library publishes
+func EventKinds() []string {
+	return []string{EventGenerationStarted, EventToken, EventGenerationFinished, EventMemoryStored,
+		EventEpisodeCreated, EventAMK, EventWormhole, EventDebt, EventPain, EventContextShift, EventDreamCompleted}
+}
+
+// Event is one thing that happened
+type Event struct {
+	Kind    string                 `json:"kind"`
+	Time    time.Time              `json:"time"`
+	Session string                 `json:"session,omitempty"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// EventBus fans events out to subscribers
+type EventBus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription receives events of the kinds it asked for
+type Subscription struct {
+	C       <-chan Event
+	ch      chan Event
+	kinds   map[string]bool // nil = all
+	dropped atomic.Uint64
+	bus     *EventBus
+	once    sync.Once
+}
+
+// NewEventBus creates an empty bus
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription buffering up to buffer events (0 = 256)
+// of the given kinds (none = all). Close it when done.
+func (b *EventBus) Subscribe(buffer int, kinds ...string) *Subscription {
+	if buffer <= 0 {
+		buffer = 256
+	}
+	sub := &Subscription{ch: make(chan Event, buffer), bus: b}
+	sub.C = sub.ch
+	if len(kinds) > 0 {
+		sub.kinds = make(map[string]bool, len(kinds))
+		for _, k := range kinds {
+			sub.kinds[k] = true
+		}
+	}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// On calls fn for each event of the given kinds (none = all), in order, on
+// a goroutine of its own; a slow fn loses events like a full channel
+// does. Close the subscription to stop.
+func (b *EventBus) On(fn func(Event), kinds ...string) *Subscription {
+	sub := b.Subscribe(0, kinds...)
+	go func() {
+		for e := range sub.C {
+			fn(e)
+		}
+	}()
+	return sub
+}
+
+// Close unsubscribes and closes C
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		close(s.ch)
+		s.bus.mu.Unlock()
+	})
+}
+
+// Dropped returns how many events were lost because C was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Wants reports whether any subscriber takes kind, so publishers can skip
+// building events nobody reads
+func (b *EventBus) Wants(kind string) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if sub.kinds == nil || sub.kinds[kind] {
+			return true
+		}
+	}
+	return false
+}
+
+// WantsAny reports whether any subscriber takes one of kinds
+func (b *EventBus) WantsAny(kinds ...string) bool {
+	for _, kind := range kinds {
+		if b.Wants(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish delivers e to every interested subscriber without blocking
+func (b *EventBus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if sub.kinds != nil && !sub.kinds[e.Kind] {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Events returns the instance's bus, creating it on first use
+func (y *Yent) Events() *EventBus {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	return y.eventBus()
+}
+
+// eventBus is Events with the model lock held
+func (y *Yent) eventBus() *EventBus {
+	if y.events == nil {
+		y.events = NewEventBus()
+	}
+	return y.events
+}
+
+// emit publishes kind with data when someone listens (model lock held)
+func (y *Yent) emit(kind, session string, data func() map[string]interface{}) {
+	if !y.events.Wants(kind) {
+		return
+	}
+	y.events.Publish(Event{Kind: kind, Session: session, Data: data()})
+}
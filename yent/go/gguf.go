@@ -72,6 +72,17 @@ type GGUFMetadata struct {
 	RopeTheta     float32
 	RopeFreqBase  float32
 
+	// Architecture variations (arch.go)
+	Arch         string  // general.architecture
+	RopeDim      int     // rope.dimension_count: rotated dims per head (0 = all)
+	AttnSoftcap  float32 // attn_logit_softcapping (gemma2)
+	FinalSoftcap float32 // final_logit_softcapping (gemma2)
+
+	// RoPE scaling (rope.scaling.* keys, absent for most small models)
+	RopeScalingType   string  // "none", "linear", "yarn"
+	RopeScalingFactor float32 // context extension factor
+	RopeOrigCtx       int     // context length the model was trained with
+
 	// Tokenizer
 	TokenList      []string
 	TokenScores    []float32
@@ -101,6 +112,24 @@ type GGUFFile struct {
 	Tensors    map[string]*GGUFTensorInfo
 	TensorData []byte // mmap'd or read tensor data blob
 	DataOffset int64  // offset where tensor data starts in file
+
+	mapping []byte // whole-file mapping from LoadGGUFMapped (nil = read)
+}
+
+// Mapped reports whether the tensor data is memory-mapped
+func (g *GGUFFile) Mapped() bool {
+	return g.mapping != nil
+}
+
+// Close unmaps a mapped file; the tensors must no longer be in use.
+// A no-op for files read into memory.
+func (g *GGUFFile) Close() error {
+	if g == nil || g.mapping == nil {
+		return nil
+	}
+	err := unmapFile(g.mapping)
+	g.mapping, g.TensorData = nil, nil
+	return err
 }
 
 func readString(r io.Reader) (string, error) {
@@ -280,6 +309,42 @@ func tensorBytes(info *GGUFTensorInfo) uint64 {
 
 // LoadGGUF loads a GGUF file
 func LoadGGUF(path string) (*GGUFFile, error) {
+	return readGGUF(path, true)
+}
+
+// LoadGGUFMetadata parses metadata, vocab and tensor infos without reading
+// tensor data — for tools that need the tokenizer or the model shape but
+// not a gigabyte of weights. GetTensor fails on the result.
+func LoadGGUFMetadata(path string) (*GGUFFile, error) {
+	return readGGUF(path, false)
+}
+
+// LoadGGUFMapped loads a GGUF with its tensor data memory-mapped instead
+// of read: pages come from the file as they are touched, and can be
+// dropped again (LoadOptions.StreamLayers). Where mapping is unsupported
+// it falls back to LoadGGUF.
+func LoadGGUFMapped(path string) (*GGUFFile, error) {
+	g, err := readGGUF(path, false)
+	if err != nil {
+		return nil, err
+	}
+	data, err := mapFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[tongue/gguf] %v — reading instead\n", err)
+		return LoadGGUF(path)
+	}
+	if int64(len(data)) <= g.DataOffset {
+		unmapFile(data)
+		return nil, fmt.Errorf("no tensor data (dataOffset=%d, fileSize=%d)", g.DataOffset, len(data))
+	}
+	g.mapping = data
+	g.TensorData = data[g.DataOffset:]
+	fmt.Printf("[tongue/gguf] data offset=%d size=%.1f MB (mapped)\n", g.DataOffset, float64(len(g.TensorData))/1024/1024)
+	return g, nil
+}
+
+// readGGUF parses a GGUF file, with or without its tensor data
+func readGGUF(path string, withData bool) (*GGUFFile, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("open GGUF: %w", err)
@@ -384,6 +449,13 @@ func LoadGGUF(path string) (*GGUFFile, error) {
 	if dataSize <= 0 {
 		return nil, fmt.Errorf("no tensor data (dataOffset=%d, fileSize=%d)", dataOffset, fileInfo.Size())
 	}
+	if !withData {
+		return &GGUFFile{
+			Meta:       parseMetadata(kv),
+			Tensors:    tensors,
+			DataOffset: dataOffset,
+		}, nil
+	}
 
 	fmt.Printf("[tongue/gguf] data offset=%d size=%.1f MB\n", dataOffset, float64(dataSize)/1024/1024)
 
@@ -445,15 +517,41 @@ func parseMetadata(kv map[string]interface{}) GGUFMetadata {
 	}
 	if v, ok := kv[arch+".attention.layer_norm_rms_epsilon"]; ok {
 		meta.RMSNormEps = toFloat32(v)
+	} else if v, ok := kv[arch+".attention.layer_norm_epsilon"]; ok {
+		meta.RMSNormEps = toFloat32(v) // LayerNorm models (phi2)
+	}
+	meta.Arch = arch
+	if v, ok := kv[arch+".rope.dimension_count"]; ok {
+		meta.RopeDim = toInt(v)
+	}
+	if v, ok := kv[arch+".attn_logit_softcapping"]; ok {
+		meta.AttnSoftcap = toFloat32(v)
+	}
+	if v, ok := kv[arch+".final_logit_softcapping"]; ok {
+		meta.FinalSoftcap = toFloat32(v)
 	}
 	if v, ok := kv[arch+".rope.freq_base"]; ok {
 		meta.RopeTheta = toFloat32(v)
 	}
+	if v, ok := kv[arch+".rope.scaling.type"]; ok {
+		if s, ok := v.(string); ok {
+			meta.RopeScalingType = s
+		}
+	}
+	if v, ok := kv[arch+".rope.scaling.factor"]; ok {
+		meta.RopeScalingFactor = toFloat32(v)
+	}
+	if v, ok := kv[arch+".rope.scaling.original_context_length"]; ok {
+		meta.RopeOrigCtx = toInt(v)
+	}
 
 	// Derived
 	if meta.NumHeads > 0 && meta.EmbedDim > 0 {
 		meta.HeadDim = meta.EmbedDim / meta.NumHeads
 	}
+	if v, ok := kv[arch+".attention.key_length"]; ok {
+		meta.HeadDim = toInt(v) // wider than dim/heads on Gemma 7B
+	}
 	if meta.NumKVHeads == 0 {
 		meta.NumKVHeads = meta.NumHeads // MHA fallback
 	}
@@ -544,6 +642,9 @@ func (g *GGUFFile) GetTensor(name string) ([]byte, *GGUFTensorInfo, error) {
 	if !ok {
 		return nil, nil, fmt.Errorf("tensor not found: %s", name)
 	}
+	if g.TensorData == nil {
+		return nil, nil, fmt.Errorf("tensor %s: GGUF loaded metadata-only", name)
+	}
 	size := tensorBytes(info)
 	start := info.Offset
 	end := start + size
@@ -0,0 +1,211 @@
+package yent
+
+// gpu.go — Offloading the large matmuls to a GPU
+//
+// Most of a token's time goes to two kinds of matrix: the FFN projections
+// (three per layer, 8960×1536 on the 1.5B model) and the LM head (151936
+// rows). Both read every weight once per token, which is what a GPU's
+// memory bandwidth is for. LoadOptions.GPU moves them there:
+//
+//   ""      CPU kernels only (default)
+//   auto    the first compiled-in backend that initializes
+//   cuda    cuBLAS (gpu_cuda.go, build with -tags cuda)
+//
+// A backend only knows F16 matrices: the weights are dequantized once at
+// load, whatever their GGUF type, so one GEMV covers Q4_0, Q8_0, Q6_K and
+// F16 alike — at two bytes a weight of GPU memory instead of half a byte.
+// Attention stays on the CPU, next to the KV cache.
+//
+// Everything falls back: a backend that is not compiled in or fails to
+// start loads the model on the CPU; a matrix that does not fit stays on
+// the CPU; a GEMV that fails logs once and sends the model back to the CPU
+// kernels for good.
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GPUBackend is an accelerator that holds F16 matrices and multiplies them
+type GPUBackend interface {
+	Name() string
+	// Alloc reserves a rows×cols F16 matrix in device memory
+	Alloc(rows, cols int) (GPUMatrix, error)
+	Close() error
+}
+
+// GPUMatrix is one weight matrix resident on a GPUBackend
+type GPUMatrix interface {
+	// SetRows copies rows starting at row from host F16 values (row-major)
+	SetRows(row int, f16 []uint16) error
+	// MatMul computes out[rows] = M @ x[cols]
+	MatMul(out, x []float32) error
+	Free()
+}
+
+// gpuBackends maps backend names to constructors
+var gpuBackends = map[string]func() (GPUBackend, error){}
+
+// RegisterGPUBackend makes a backend available to LoadOptions.GPU. The
+// build-tagged backends here register from init; call it before loading.
+func RegisterGPUBackend(name string, open func() (GPUBackend, error)) {
+	gpuBackends[name] = open
+}
+
+// GPUBackends lists the backends compiled into this binary
+func GPUBackends() []string {
+	names := make([]string, 0, len(gpuBackends))
+	for name := range gpuBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OpenGPUBackend starts a backend by name ("auto" = first that works)
+func OpenGPUBackend(name string) (GPUBackend, error) {
+	if name == "auto" {
+		var errs []string
+		for _, n := range GPUBackends() {
+			b, err := gpuBackends[n]()
+			if err == nil {
+				return b, nil
+			}
+			errs = append(errs, err.Error())
+		}
+		if len(errs) == 0 {
+			return nil, fmt.Errorf("no GPU backend compiled in")
+		}
+		return nil, fmt.Errorf("no GPU backend available: %s", strings.Join(errs, "; "))
+	}
+	open, ok := gpuBackends[name]
+	if !ok {
+		return nil, fmt.Errorf("GPU backend %q not compiled in (have: %s)", name, strings.Join(GPUBackends(), ", "))
+	}
+	return open()
+}
+
+// gpuOffload is a model's GPU-resident weights, keyed by their host bytes
+type gpuOffload struct {
+	backend  GPUBackend
+	matrices map[*byte]GPUMatrix
+	bytes    int64
+}
+
+// offloadGPU uploads the LM head and FFN weights to the named backend.
+// Failures leave the model (or the matrix) on the CPU.
+func (m *LlamaModel) offloadGPU(name string) {
+	b, err := OpenGPUBackend(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[gpu] %v — running on CPU\n", err)
+		return
+	}
+	g := &gpuOffload{backend: b, matrices: make(map[*byte]GPUMatrix)}
+	cfg := &m.Config
+	dim, interm := cfg.EmbedDim, cfg.IntermSize
+	skipped := 0
+	upload := func(w []byte, wtype uint32, rows, cols int) {
+		if len(w) == 0 || g.matrices[&w[0]] != nil {
+			return
+		}
+		mat, err := uploadMatrix(b, w, wtype, rows, cols)
+		if err != nil {
+			skipped++
+			return
+		}
+		g.matrices[&w[0]] = mat
+		g.bytes += int64(rows) * int64(cols) * 2
+	}
+	upload(m.Weights.Output, m.Weights.OutputType, cfg.VocabSize, dim)
+	for i := range m.Weights.Layers {
+		l := &m.Weights.Layers[i]
+		upload(l.WGate, l.WGateType, interm, dim)
+		upload(l.WUp, l.WUpType, interm, dim)
+		upload(l.WDown, l.WDownType, dim, interm)
+	}
+	if len(g.matrices) == 0 {
+		fmt.Fprintf(os.Stderr, "[gpu] %s: nothing fit — running on CPU\n", b.Name())
+		b.Close()
+		return
+	}
+	m.gpu = g
+	fmt.Printf("[gpu] %s: %d matrices offloaded (%.0f MB F16)", b.Name(), len(g.matrices), float64(g.bytes)/(1<<20))
+	if skipped > 0 {
+		fmt.Printf(", %d left on CPU", skipped)
+	}
+	fmt.Println()
+}
+
+// uploadMatrix dequantizes w to F16 a slab of rows at a time and copies it
+// into a new device matrix
+func uploadMatrix(b GPUBackend, w []byte, wtype uint32, rows, cols int) (GPUMatrix, error) {
+	be := ggmlBlockElements(wtype)
+	if !isSupportedType(wtype) || cols%be != 0 {
+		return nil, fmt.Errorf("tensor type %d with %d columns", wtype, cols)
+	}
+	rowBytes := cols / be * ggmlBlockSize(wtype)
+	if len(w) < rows*rowBytes {
+		return nil, fmt.Errorf("tensor too small: %d bytes for %d rows", len(w), rows)
+	}
+	mat, err := b.Alloc(rows, cols)
+	if err != nil {
+		return nil, err
+	}
+	slab := max(1, (1<<20)/cols)
+	f16 := make([]uint16, slab*cols)
+	for r := 0; r < rows; r += slab {
+		n := min(slab, rows-r)
+		vals := dequantRows(w[r*rowBytes:(r+n)*rowBytes], wtype, n*cols)
+		for i, v := range vals {
+			f16[i] = float2half(v)
+		}
+		if err := mat.SetRows(r, f16[:n*cols]); err != nil {
+			mat.Free()
+			return nil, err
+		}
+	}
+	return mat, nil
+}
+
+// matmul runs out = W @ x on the GPU when W was offloaded, on the CPU
+// kernels otherwise (int8 activations with LoadOptions.Int8)
+func (m *LlamaModel) matmul(out []float32, w []byte, wtype uint32, x []float32, rows, cols int) {
+	if m.int8 != nil {
+		m.int8.matmul(out, w, wtype, x, rows, cols)
+		return
+	}
+	if g := m.gpu; g != nil && len(w) > 0 {
+		if mat := g.matrices[&w[0]]; mat != nil {
+			err := mat.MatMul(out[:rows], x[:cols])
+			if err == nil {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "[gpu] %s: %v — falling back to CPU\n", g.backend.Name(), err)
+			m.ReleaseGPU()
+		}
+	}
+	matmulDispatch(out, w, wtype, x, rows, cols)
+}
+
+// GPU names the backend holding offloaded weights ("" = CPU only)
+func (m *LlamaModel) GPU() string {
+	if m.gpu == nil {
+		return ""
+	}
+	return m.gpu.backend.Name()
+}
+
+// ReleaseGPU frees the offloaded weights; later matmuls run on the CPU
+func (m *LlamaModel) ReleaseGPU() {
+	if m == nil || m.gpu == nil {
+		return
+	}
+	g := m.gpu
+	m.gpu = nil
+	for _, mat := range g.matrices {
+		mat.Free()
+	}
+	g.backend.Close()
+}
@@ -0,0 +1,122 @@
+//go:build cuda
+
+// gpu_cuda.c — cuBLAS GEMV for offloaded weights
+//
+// Weights live on the device as F16, row-major. cuBLAS is column-major, so
+// a row-major rows×cols matrix is its cols×rows transpose with lda = cols:
+// the GEMV runs with CUBLAS_OP_T. x goes up as F16 (GemmEx wants A and B
+// in one type), the product accumulates and comes back in F32.
+//
+// One context per model: a cuBLAS handle, a stream, and x/out buffers on
+// the device grown to the widest matmul seen.
+
+#include <cuda_runtime.h>
+#include <cublas_v2.h>
+#include <stdlib.h>
+#include "gpu_cuda.h"
+
+#define CUBLAS_BASE 1000
+
+struct yent_cuda {
+	cublasHandle_t blas;
+	cudaStream_t stream;
+	struct cudaDeviceProp prop;
+	uint16_t *x;
+	float *out;
+	int x_cap, out_cap;
+};
+
+int yent_cuda_open(yent_cuda **ctx) {
+	int count = 0;
+	cudaError_t err = cudaGetDeviceCount(&count);
+	if (err != cudaSuccess) {
+		return (int)err;
+	}
+	if (count == 0) {
+		return (int)cudaErrorNoDevice;
+	}
+	yent_cuda *c = calloc(1, sizeof(*c));
+	if (c == NULL) {
+		return (int)cudaErrorMemoryAllocation;
+	}
+	if ((err = cudaGetDeviceProperties(&c->prop, 0)) != cudaSuccess ||
+		(err = cudaStreamCreate(&c->stream)) != cudaSuccess) {
+		free(c);
+		return (int)err;
+	}
+	cublasStatus_t st = cublasCreate(&c->blas);
+	if (st == CUBLAS_STATUS_SUCCESS) {
+		st = cublasSetStream(c->blas, c->stream);
+	}
+	if (st != CUBLAS_STATUS_SUCCESS) {
+		cudaStreamDestroy(c->stream);
+		free(c);
+		return CUBLAS_BASE + (int)st;
+	}
+	*ctx = c;
+	return 0;
+}
+
+void yent_cuda_close(yent_cuda *c) {
+	cudaFree(c->x);
+	cudaFree(c->out);
+	cublasDestroy(c->blas);
+	cudaStreamDestroy(c->stream);
+	free(c);
+}
+
+const char *yent_cuda_device(yent_cuda *c) {
+	return c->prop.name;
+}
+
+int yent_cuda_alloc(void **dev, size_t bytes) {
+	return (int)cudaMalloc(dev, bytes);
+}
+
+void yent_cuda_free(void *dev) {
+	cudaFree(dev);
+}
+
+int yent_cuda_upload(void *dev, size_t offset, const void *src, size_t bytes) {
+	return (int)cudaMemcpy((char *)dev + offset, src, bytes, cudaMemcpyHostToDevice);
+}
+
+// grow makes *buf hold at least n elements of size bytes
+static cudaError_t grow(void **buf, int *cap, int n, size_t size) {
+	if (*cap >= n) {
+		return cudaSuccess;
+	}
+	cudaFree(*buf);
+	*buf = NULL;
+	*cap = 0;
+	cudaError_t err = cudaMalloc(buf, (size_t)n * size);
+	if (err == cudaSuccess) {
+		*cap = n;
+	}
+	return err;
+}
+
+int yent_cuda_gemv(yent_cuda *c, const void *w, int rows, int cols, const uint16_t *x, float *out) {
+	cudaError_t err;
+	if ((err = grow((void **)&c->x, &c->x_cap, cols, sizeof(uint16_t))) != cudaSuccess ||
+		(err = grow((void **)&c->out, &c->out_cap, rows, sizeof(float))) != cudaSuccess) {
+		return (int)err;
+	}
+	if ((err = cudaMemcpyAsync(c->x, x, (size_t)cols * sizeof(uint16_t), cudaMemcpyHostToDevice, c->stream)) != cudaSuccess) {
+		return (int)err;
+	}
+	const float alpha = 1.0f, beta = 0.0f;
+	cublasStatus_t st = cublasGemmEx(c->blas, CUBLAS_OP_T, CUBLAS_OP_N,
+		rows, 1, cols,
+		&alpha, w, CUDA_R_16F, cols,
+		c->x, CUDA_R_16F, cols,
+		&beta, c->out, CUDA_R_32F, rows,
+		CUBLAS_COMPUTE_32F, CUBLAS_GEMM_DEFAULT);
+	if (st != CUBLAS_STATUS_SUCCESS) {
+		return CUBLAS_BASE + (int)st;
+	}
+	if ((err = cudaMemcpyAsync(out, c->out, (size_t)rows * sizeof(float), cudaMemcpyDeviceToHost, c->stream)) != cudaSuccess) {
+		return (int)err;
+	}
+	return (int)cudaStreamSynchronize(c->stream);
+}
@@ -0,0 +1,124 @@
+//go:build cuda && cgo
+
+package yent
+
+/*
+#cgo CFLAGS: -O2 -I/usr/local/cuda/include
+#cgo LDFLAGS: -L/usr/local/cuda/lib64 -lcublas -lcudart
+#include "gpu_cuda.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+func init() {
+	RegisterGPUBackend("cuda", openCUDA)
+}
+
+// cudaBackend is a cuBLAS context on device 0
+type cudaBackend struct {
+	mu  sync.Mutex // guards the context's x/out buffers
+	ctx *C.yent_cuda
+	x   []uint16 // x converted to F16 for the current matmul
+}
+
+// cudaMatrix is one F16 matrix in device memory
+type cudaMatrix struct {
+	b          *cudaBackend
+	dev        unsafe.Pointer
+	rows, cols int
+}
+
+// cudaError formats a status from gpu_cuda.c
+func cudaError(op string, code C.int) error {
+	if code >= 1000 {
+		return fmt.Errorf("%s: cuBLAS status %d", op, int(code)-1000)
+	}
+	return fmt.Errorf("%s: CUDA error %d", op, int(code))
+}
+
+func openCUDA() (GPUBackend, error) {
+	var ctx *C.yent_cuda
+	if rc := C.yent_cuda_open(&ctx); rc != 0 {
+		return nil, cudaError("cuda init", rc)
+	}
+	return &cudaBackend{ctx: ctx}, nil
+}
+
+// Name returns "cuda (<device>)"
+func (b *cudaBackend) Name() string {
+	return "cuda (" + C.GoString(C.yent_cuda_device(b.ctx)) + ")"
+}
+
+// Alloc reserves a rows×cols F16 matrix
+func (b *cudaBackend) Alloc(rows, cols int) (GPUMatrix, error) {
+	var dev unsafe.Pointer
+	if rc := C.yent_cuda_alloc(&dev, C.size_t(rows*cols*2)); rc != 0 {
+		return nil, cudaError(fmt.Sprintf("alloc %dx%d", rows, cols), rc)
+	}
+	return &cudaMatrix{b: b, dev: dev, rows: rows, cols: cols}, nil
+}
+
+// Close destroys the context; matrices must be freed first
+func (b *cudaBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ctx != nil {
+		C.yent_cuda_close(b.ctx)
+		b.ctx = nil
+	}
+	return nil
+}
+
+// SetRows copies F16 rows from the host
+func (m *cudaMatrix) SetRows(row int, f16 []uint16) error {
+	if len(f16) == 0 {
+		return nil
+	}
+	if len(f16)%m.cols != 0 || row+len(f16)/m.cols > m.rows {
+		return fmt.Errorf("rows %d+%d outside %dx%d matrix", row, len(f16)/m.cols, m.rows, m.cols)
+	}
+	rc := C.yent_cuda_upload(m.dev, C.size_t(row*m.cols*2), unsafe.Pointer(&f16[0]), C.size_t(len(f16)*2))
+	if rc != 0 {
+		return cudaError("upload", rc)
+	}
+	return nil
+}
+
+// MatMul computes out = M @ x
+func (m *cudaMatrix) MatMul(out, x []float32) error {
+	if len(out) < m.rows || len(x) < m.cols {
+		return fmt.Errorf("matmul %dx%d: out %d, x %d", m.rows, m.cols, len(out), len(x))
+	}
+	b := m.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ctx == nil {
+		return fmt.Errorf("cuda backend closed")
+	}
+	if cap(b.x) < m.cols {
+		b.x = make([]uint16, m.cols)
+	}
+	xh := b.x[:m.cols]
+	for i, v := range x[:m.cols] {
+		xh[i] = float2half(v)
+	}
+	rc := C.yent_cuda_gemv(b.ctx, m.dev, C.int(m.rows), C.int(m.cols),
+		(*C.uint16_t)(&xh[0]), (*C.float)(&out[0]))
+	if rc != 0 {
+		return cudaError("gemv", rc)
+	}
+	return nil
+}
+
+// Free releases the device memory
+func (m *cudaMatrix) Free() {
+	if m.dev != nil {
+		C.yent_cuda_free(m.dev)
+		m.dev = nil
+	}
+}
@@ -0,0 +1,26 @@
+// gpu_cuda.h — cuBLAS GEMV for offloaded weights (gpu_cuda.c)
+//
+// Functions return 0 on success, a cudaError_t otherwise, or 1000 + a
+// cublasStatus_t for cuBLAS failures.
+
+#ifndef YENT_GPU_CUDA_H
+#define YENT_GPU_CUDA_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+typedef struct yent_cuda yent_cuda;
+
+int yent_cuda_open(yent_cuda **ctx);
+void yent_cuda_close(yent_cuda *ctx);
+const char *yent_cuda_device(yent_cuda *ctx);
+
+int yent_cuda_alloc(void **dev, size_t bytes);
+void yent_cuda_free(void *dev);
+int yent_cuda_upload(void *dev, size_t offset, const void *src, size_t bytes);
+
+// out[rows] = w[rows, cols] @ x[cols]; w is F16 row-major on the device,
+// x is F16 on the host
+int yent_cuda_gemv(yent_cuda *ctx, const void *w, int rows, int cols, const uint16_t *x, float *out);
+
+#endif
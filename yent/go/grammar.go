@@ -0,0 +1,677 @@
+package yent
+
+// grammar.go — Constrained decoding: the sampler may only say what the grammar allows
+//
+// A 1.5B model asked for JSON will mostly produce JSON. Tool and agent
+// integrations need always. Grammars are GBNF (the llama.cpp dialect):
+//
+//   root   ::= "{" ws "\"mood\":" ws mood ws "}"
+//   mood   ::= "\"calm\"" | "\"storm\""
+//   ws     ::= [ \t\n]*
+//
+//   "lit"  literal        [a-z] [^"]  character class     .  any character
+//   name   rule           ( ... )     group               # comment
+//   x*  x+  x?  x{m}  x{m,}  x{m,n}   repetition
+//
+// The matcher is a pushdown automaton over runes: a set of stacks, each
+// topped by the character class it expects next. During generation the
+// sampled token is checked first; only when the grammar rejects it is the
+// whole vocabulary masked and the draw repeated. Accepting a token the
+// cheap way is exact — an allowed token would have been drawn from the
+// masked distribution with the same relative odds.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	gElemChar = iota // match one rune against ranges
+	gElemRef         // expand a rule
+)
+
+// grammarMaxStack bounds stack depth (left recursion would otherwise never end)
+const grammarMaxStack = 512
+
+// gElem is one grammar element
+type gElem struct {
+	kind   uint8
+	ref    int
+	ranges []rune // inclusive lo,hi pairs
+	negate bool
+}
+
+// matches reports whether r is in the class
+func (e *gElem) matches(r rune) bool {
+	in := false
+	for i := 0; i+1 < len(e.ranges); i += 2 {
+		if r >= e.ranges[i] && r <= e.ranges[i+1] {
+			in = true
+			break
+		}
+	}
+	return in != e.negate
+}
+
+// overlaps reports whether any rune in [lo, hi] could match (partial UTF-8)
+func (e *gElem) overlaps(lo, hi rune) bool {
+	if e.negate {
+		return true
+	}
+	for i := 0; i+1 < len(e.ranges); i += 2 {
+		if e.ranges[i] <= hi && e.ranges[i+1] >= lo {
+			return true
+		}
+	}
+	return false
+}
+
+type gRule struct {
+	name string
+	alts [][]gElem
+}
+
+// Grammar is a compiled GBNF grammar
+type Grammar struct {
+	rules []gRule
+	names map[string]int
+	root  int
+}
+
+// gPos points at an element: rule, alternative, element index
+type gPos struct {
+	rule, alt, elem int32
+}
+
+// ParseGrammar compiles GBNF source; the start rule is "root"
+func ParseGrammar(src string) (*Grammar, error) {
+	p := &gbnfParser{src: []rune(src), g: &Grammar{names: make(map[string]int)}}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	g := p.g
+	for i, r := range g.rules {
+		if r.alts == nil {
+			return nil, fmt.Errorf("grammar: undefined rule %q", g.rules[i].name)
+		}
+	}
+	root, ok := g.names["root"]
+	if !ok {
+		return nil, fmt.Errorf("grammar: no root rule")
+	}
+	g.root = root
+	return g, nil
+}
+
+// Match reports whether s is a complete sentence of the grammar
+func (g *Grammar) Match(s string) bool {
+	st := newGrammarState(g)
+	return st.accept([]byte(s)) && st.complete()
+}
+
+// grammarState tracks every way the output so far can still be parsed
+type grammarState struct {
+	g       *Grammar
+	stacks  [][]gPos
+	partial []byte // incomplete UTF-8 sequence carried to the next token
+}
+
+func newGrammarState(g *Grammar) *grammarState {
+	st := &grammarState{g: g}
+	seen := make(map[string]bool)
+	for a := range g.rules[g.root].alts {
+		st.stacks = g.expand([]gPos{{int32(g.root), int32(a), 0}}, st.stacks, seen)
+	}
+	return st
+}
+
+// elem returns the element a position points at (nil past the end)
+func (g *Grammar) elem(p gPos) *gElem {
+	alt := g.rules[p.rule].alts[p.alt]
+	if int(p.elem) >= len(alt) {
+		return nil
+	}
+	return &alt[p.elem]
+}
+
+// expand resolves rule references until every stack is topped by a
+// character class (or empty = complete), appending unique stacks to out
+func (g *Grammar) expand(stack []gPos, out [][]gPos, seen map[string]bool) [][]gPos {
+	if len(stack) > grammarMaxStack {
+		return out
+	}
+	// Pop finished alternatives
+	for len(stack) > 0 && g.elem(stack[len(stack)-1]) == nil {
+		stack = stack[:len(stack)-1]
+	}
+	if len(stack) == 0 || g.elem(stack[len(stack)-1]).kind == gElemChar {
+		key := stackKey(stack)
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, stack)
+		}
+		return out
+	}
+	top := stack[len(stack)-1]
+	ref := g.elem(top).ref
+	rest := append(stack[:len(stack)-1:len(stack)-1], gPos{top.rule, top.alt, top.elem + 1})
+	for a := range g.rules[ref].alts {
+		next := append(rest[:len(rest):len(rest)], gPos{int32(ref), int32(a), 0})
+		out = g.expand(next, out, seen)
+	}
+	return out
+}
+
+// stackKey identifies a stack for deduplication
+func stackKey(stack []gPos) string {
+	var b strings.Builder
+	for _, p := range stack {
+		b.WriteString(strconv.Itoa(int(p.rule)))
+		b.WriteByte('.')
+		b.WriteString(strconv.Itoa(int(p.alt)))
+		b.WriteByte('.')
+		b.WriteString(strconv.Itoa(int(p.elem)))
+		b.WriteByte('/')
+	}
+	return b.String()
+}
+
+// advance returns the stacks after consuming r
+func (g *Grammar) advance(stacks [][]gPos, r rune) [][]gPos {
+	var out [][]gPos
+	seen := make(map[string]bool)
+	for _, stack := range stacks {
+		if len(stack) == 0 {
+			continue
+		}
+		top := stack[len(stack)-1]
+		if !g.elem(top).matches(r) {
+			continue
+		}
+		next := append(stack[:len(stack)-1:len(stack)-1], gPos{top.rule, top.alt, top.elem + 1})
+		out = g.expand(next, out, seen)
+	}
+	return out
+}
+
+// feed consumes bytes, returning the new stacks and trailing partial rune
+func (st *grammarState) feed(b []byte) ([][]gPos, []byte, bool) {
+	stacks := st.stacks
+	buf := b
+	if len(st.partial) > 0 {
+		buf = append(append([]byte(nil), st.partial...), b...)
+	}
+	for len(buf) > 0 {
+		if !utf8.FullRune(buf) {
+			if !st.g.partialPossible(stacks, buf) {
+				return nil, nil, false
+			}
+			return stacks, buf, true
+		}
+		r, size := utf8.DecodeRune(buf)
+		if r == utf8.RuneError && size == 1 {
+			return nil, nil, false
+		}
+		stacks = st.g.advance(stacks, r)
+		if len(stacks) == 0 {
+			return nil, nil, false
+		}
+		buf = buf[size:]
+	}
+	return stacks, nil, true
+}
+
+// partialPossible reports whether some rune starting with prefix could be accepted
+func (g *Grammar) partialPossible(stacks [][]gPos, prefix []byte) bool {
+	lo := append(append([]byte(nil), prefix...), 0x80, 0x80, 0x80)
+	hi := append(append([]byte(nil), prefix...), 0xBF, 0xBF, 0xBF)
+	rlo, _ := utf8.DecodeRune(lo)
+	rhi, _ := utf8.DecodeRune(hi)
+	if rlo == utf8.RuneError || rhi == utf8.RuneError {
+		return true // odd lead byte: let the completed rune decide
+	}
+	for _, stack := range stacks {
+		if len(stack) > 0 && g.elem(stack[len(stack)-1]).overlaps(rlo, rhi) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether b could be emitted next, without consuming it
+func (st *grammarState) allows(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	// Cheap rejection on the first complete rune
+	if len(st.partial) == 0 && utf8.FullRune(b) {
+		r, _ := utf8.DecodeRune(b)
+		ok := false
+		for _, stack := range st.stacks {
+			if len(stack) > 0 && st.g.elem(stack[len(stack)-1]).matches(r) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	_, _, ok := st.feed(b)
+	return ok
+}
+
+// accept consumes b; false leaves the state unchanged
+func (st *grammarState) accept(b []byte) bool {
+	stacks, partial, ok := st.feed(b)
+	if !ok {
+		return false
+	}
+	st.stacks, st.partial = stacks, partial
+	return true
+}
+
+// complete reports whether the output so far is a full sentence
+func (st *grammarState) complete() bool {
+	if len(st.partial) > 0 {
+		return false
+	}
+	for _, stack := range st.stacks {
+		if len(stack) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// exhausted reports whether nothing more can follow (only end of text)
+func (st *grammarState) exhausted() bool {
+	for _, stack := range st.stacks {
+		if len(stack) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ═══ GBNF parser ═══
+
+type gbnfParser struct {
+	src  []rune
+	pos  int
+	g    *Grammar
+	anon int
+}
+
+func (p *gbnfParser) errorf(format string, args ...interface{}) error {
+	line := 1
+	for _, r := range p.src[:p.pos] {
+		if r == '\n' {
+			line++
+		}
+	}
+	return fmt.Errorf("grammar line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// ruleID returns the id for name, creating an undefined rule if needed
+func (p *gbnfParser) ruleID(name string) int {
+	if id, ok := p.g.names[name]; ok {
+		return id
+	}
+	p.g.rules = append(p.g.rules, gRule{name: name})
+	p.g.names[name] = len(p.g.rules) - 1
+	return len(p.g.rules) - 1
+}
+
+// newRule adds an anonymous rule
+func (p *gbnfParser) newRule(base string, alts [][]gElem) int {
+	p.anon++
+	id := p.ruleID(fmt.Sprintf("%s_%d", base, p.anon))
+	p.g.rules[id].alts = alts
+	return id
+}
+
+// space skips whitespace and comments; newlines only when newlineOK
+func (p *gbnfParser) space(newlineOK bool) {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\n' && newlineOK:
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func isGrammarIdent(r rune) bool {
+	return r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func (p *gbnfParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.src) && isGrammarIdent(p.src[p.pos]) {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+func (p *gbnfParser) parse() error {
+	for {
+		p.space(true)
+		if p.pos >= len(p.src) {
+			return nil
+		}
+		name := p.ident()
+		if name == "" {
+			return p.errorf("expected rule name")
+		}
+		p.space(false)
+		if !strings.HasPrefix(string(p.src[p.pos:min(p.pos+3, len(p.src))]), "::=") {
+			return p.errorf("expected ::= after %q", name)
+		}
+		p.pos += 3
+		p.space(true)
+		id := p.ruleID(name)
+		if p.g.rules[id].alts != nil {
+			return p.errorf("rule %q defined twice", name)
+		}
+		alts, err := p.alternates(name, 0)
+		if err != nil {
+			return err
+		}
+		p.g.rules[id].alts = alts
+	}
+}
+
+func (p *gbnfParser) alternates(name string, depth int) ([][]gElem, error) {
+	var alts [][]gElem
+	for {
+		seq, err := p.sequence(name, depth)
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, seq)
+		if p.pos < len(p.src) && p.src[p.pos] == '|' {
+			p.pos++
+			p.space(true)
+			continue
+		}
+		return alts, nil
+	}
+}
+
+func (p *gbnfParser) sequence(name string, depth int) ([]gElem, error) {
+	seq := []gElem{}
+	for {
+		p.space(depth > 0)
+		if p.pos >= len(p.src) {
+			return seq, nil
+		}
+		c := p.src[p.pos]
+		var item []gElem
+		switch {
+		case c == '|' || c == ')' || c == '\n':
+			return seq, nil
+		case c == '"':
+			p.pos++
+			for p.pos < len(p.src) && p.src[p.pos] != '"' {
+				r, err := p.char()
+				if err != nil {
+					return nil, err
+				}
+				item = append(item, gElem{kind: gElemChar, ranges: []rune{r, r}})
+			}
+			if p.pos >= len(p.src) {
+				return nil, p.errorf("unterminated literal")
+			}
+			p.pos++
+		case c == '[':
+			p.pos++
+			e := gElem{kind: gElemChar}
+			if p.pos < len(p.src) && p.src[p.pos] == '^' {
+				e.negate = true
+				p.pos++
+			}
+			for p.pos < len(p.src) && p.src[p.pos] != ']' {
+				lo, err := p.char()
+				if err != nil {
+					return nil, err
+				}
+				hi := lo
+				if p.pos+1 < len(p.src) && p.src[p.pos] == '-' && p.src[p.pos+1] != ']' {
+					p.pos++
+					if hi, err = p.char(); err != nil {
+						return nil, err
+					}
+				}
+				e.ranges = append(e.ranges, lo, hi)
+			}
+			if p.pos >= len(p.src) {
+				return nil, p.errorf("unterminated character class")
+			}
+			p.pos++
+			item = []gElem{e}
+		case c == '.':
+			p.pos++
+			item = []gElem{{kind: gElemChar, ranges: []rune{0, utf8.MaxRune}}}
+		case c == '(':
+			p.pos++
+			p.space(true)
+			alts, err := p.alternates(name, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			p.space(true)
+			if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+				return nil, p.errorf("expected )")
+			}
+			p.pos++
+			item = []gElem{{kind: gElemRef, ref: p.newRule(name, alts)}}
+		case isGrammarIdent(c):
+			// A new rule starts here: this one is over
+			save := p.pos
+			ref := p.ident()
+			p.space(false)
+			if strings.HasPrefix(string(p.src[p.pos:min(p.pos+3, len(p.src))]), "::=") {
+				if depth > 0 {
+					return nil, p.errorf("expected )")
+				}
+				p.pos = save
+				return seq, nil
+			}
+			item = []gElem{{kind: gElemRef, ref: p.ruleID(ref)}}
+		default:
+			return nil, p.errorf("unexpected %q", c)
+		}
+
+		item, err := p.repetition(name, item)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, item...)
+	}
+}
+
+// repetition applies a postfix operator to item, if one follows
+func (p *gbnfParser) repetition(name string, item []gElem) ([]gElem, error) {
+	if p.pos >= len(p.src) {
+		return item, nil
+	}
+	minN, maxN := 1, 1
+	switch p.src[p.pos] {
+	case '*':
+		minN, maxN = 0, -1
+		p.pos++
+	case '+':
+		minN, maxN = 1, -1
+		p.pos++
+	case '?':
+		minN, maxN = 0, 1
+		p.pos++
+	case '{':
+		end := p.pos
+		for end < len(p.src) && p.src[end] != '}' {
+			end++
+		}
+		if end >= len(p.src) {
+			return nil, p.errorf("unterminated {m,n}")
+		}
+		spec := string(p.src[p.pos+1 : end])
+		p.pos = end + 1
+		var err error
+		if lo, hi, found := strings.Cut(spec, ","); found {
+			if minN, err = strconv.Atoi(strings.TrimSpace(lo)); err != nil {
+				return nil, p.errorf("bad repetition {%s}", spec)
+			}
+			maxN = -1
+			if strings.TrimSpace(hi) != "" {
+				if maxN, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || maxN < minN {
+					return nil, p.errorf("bad repetition {%s}", spec)
+				}
+			}
+		} else {
+			if minN, err = strconv.Atoi(strings.TrimSpace(spec)); err != nil {
+				return nil, p.errorf("bad repetition {%s}", spec)
+			}
+			maxN = minN
+		}
+	default:
+		return item, nil
+	}
+
+	var out []gElem
+	for i := 0; i < minN; i++ {
+		out = append(out, item...)
+	}
+	if maxN < 0 {
+		// star ::= item star | ε
+		star := p.newRule(name, nil)
+		p.g.rules[star].alts = [][]gElem{
+			append(append([]gElem(nil), item...), gElem{kind: gElemRef, ref: star}),
+			{},
+		}
+		return append(out, gElem{kind: gElemRef, ref: star}), nil
+	}
+	// Optional tail, nested: (item (item ...)?)?
+	tail := -1
+	for i := 0; i < maxN-minN; i++ {
+		alt := append([]gElem(nil), item...)
+		if tail >= 0 {
+			alt = append(alt, gElem{kind: gElemRef, ref: tail})
+		}
+		tail = p.newRule(name, [][]gElem{alt, {}})
+	}
+	if tail >= 0 {
+		out = append(out, gElem{kind: gElemRef, ref: tail})
+	}
+	return out, nil
+}
+
+// char reads one (possibly escaped) character of a literal or class
+func (p *gbnfParser) char() (rune, error) {
+	c := p.src[p.pos]
+	p.pos++
+	if c != '\\' {
+		return c, nil
+	}
+	if p.pos >= len(p.src) {
+		return 0, p.errorf("dangling escape")
+	}
+	c = p.src[p.pos]
+	p.pos++
+	switch c {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case 'r':
+		return '\r', nil
+	case 'x', 'u', 'U':
+		n := map[rune]int{'x': 2, 'u': 4, 'U': 8}[c]
+		if p.pos+n > len(p.src) {
+			return 0, p.errorf("short \\%c escape", c)
+		}
+		v, err := strconv.ParseUint(string(p.src[p.pos:p.pos+n]), 16, 32)
+		if err != nil {
+			return 0, p.errorf("bad \\%c escape", c)
+		}
+		p.pos += n
+		return rune(v), nil
+	default:
+		return c, nil
+	}
+}
+
+// ═══ Sampling integration ═══
+
+// tokenPieces returns every token's decoded bytes (built once)
+func (y *Yent) tokenPieces() [][]byte {
+	if y.pieces == nil {
+		t := y.tokenizer
+		y.pieces = make([][]byte, t.VocabSize)
+		for id := range y.pieces {
+			if t.Types != nil && id < len(t.Types) && t.Types[id] == 3 {
+				continue // control tokens never match a grammar
+			}
+			y.pieces[id] = []byte(t.DecodeToken(id))
+		}
+	}
+	return y.pieces
+}
+
+// grammarAllows reports whether tok may come next; end of text only once complete
+func (y *Yent) grammarAllows(gs *grammarState, tok int) bool {
+	if y.isStop(tok) {
+		return gs.complete()
+	}
+	return gs.allows(y.tokenPieces()[tok])
+}
+
+// maskGrammar sets every token the grammar rejects to -inf.
+// Returns false when nothing at all is allowed.
+func (y *Yent) maskGrammar(gs *grammarState) bool {
+	logits := y.model.State.Logits
+	allowed := false
+	for tok := 0; tok < y.model.Config.VocabSize; tok++ {
+		if logits[tok] <= -1e30 {
+			continue
+		}
+		if y.grammarAllows(gs, tok) {
+			allowed = true
+		} else {
+			logits[tok] = -1e30
+		}
+	}
+	return allowed
+}
+
+// drawAllowed draws with sample and keeps an allowed draw; otherwise it
+// masks what the grammar rejects and draws again. The rejected draw doesn't
+// count toward Mirostat's mu. ok is false when nothing is allowed.
+func (y *Yent) drawAllowed(gs *grammarState, miro *mirostat, sample func() (int, float32)) (next int, entropy float32, ok bool) {
+	var mu float32
+	if miro != nil {
+		mu = miro.mu
+	}
+	next, entropy = sample()
+	if gs == nil || y.grammarAllows(gs, next) {
+		return next, entropy, true
+	}
+	if !y.maskGrammar(gs) {
+		return next, entropy, false
+	}
+	if miro != nil {
+		miro.mu = mu
+	}
+	next, entropy = sample()
+	return next, entropy, true
+}
@@ -0,0 +1,272 @@
+package yent
+
+// headprune.go — Two-stage LM head: clusters first, then only the likely rows
+//
+// The LM head is vocab × dim: 151936 × 1536 for the 1.5B model, the
+// largest matmul of a token. Most of its rows end up far below the top of
+// the distribution that sampling looks at.
+//
+// With LoadOptions.PruneHead the rows are grouped at load by k-means
+// (k ≈ √vocab, a few Lloyd iterations run as k head matmuls each). Per
+// token:
+//
+//   1. score the k centroids against the hidden state  (k × dim)
+//   2. compute exactly the rows of the best clusters, until at least
+//      budget rows (vocab/8 to start) are done
+//   3. give every row of the remaining clusters its centroid's score,
+//      an estimate below the exact candidates
+//
+// Two checks guard it. Per token: if a skipped cluster's centroid scores
+// above the best exact logit, the ranking is not trusted and the full head
+// runs. Every headAuditEvery tokens the full head runs anyway and its
+// logits are the ones used; if its argmax differs from the pruned one the
+// budget doubles, up to the whole vocabulary (pruning off). HeadPruneStats
+// reports all of it.
+//
+// What changes is the tail: logprobs, Delta Voice and penalties see
+// estimates for the skipped rows. Greedy and top-k/top-p sampling only
+// look at the head of the distribution. Loading costs the clustering
+// (seconds on the 1.5B model), and pruning is bypassed while the LM head
+// is offloaded to a GPU.
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"time"
+	"unsafe"
+)
+
+const (
+	headKMeansIters = 3  // assignment passes of the load-time k-means
+	headBudgetFrac  = 8  // rows computed exactly: at least vocab/headBudgetFrac
+	headAuditEvery  = 32 // tokens between full-head audits
+)
+
+// HeadPruneStats describes the two-stage LM head of a model
+type HeadPruneStats struct {
+	Clusters   int `json:"clusters"`
+	Budget     int `json:"budget"`     // rows computed exactly per token
+	Tokens     int `json:"tokens"`     // LM head evaluations
+	Fallbacks  int `json:"fallbacks"`  // tokens where the check ran the full head
+	Audits     int `json:"audits"`     // full-head audits
+	Mismatches int `json:"mismatches"` // audits whose argmax differed
+}
+
+// headClusters is the clustering of the LM head rows and its per-token state
+type headClusters struct {
+	k         int
+	centroids []float32 // [k × dim]
+	members   [][]int32 // ascending row indices per cluster
+	budget    int
+
+	scores []float32 // centroid scores of the current token [k]
+	order  []int     // clusters, best score first
+	cand   []int32   // rows computed exactly
+	full   []float32 // audit logits [vocab]
+	stats  HeadPruneStats
+}
+
+// HeadPruneStats reports the two-stage LM head (false = not enabled)
+func (m *LlamaModel) HeadPruneStats() (HeadPruneStats, bool) {
+	if m.head == nil {
+		return HeadPruneStats{}, false
+	}
+	s := m.head.stats
+	s.Clusters, s.Budget = m.head.k, m.head.budget
+	return s, true
+}
+
+// buildHeadClusters clusters the rows of the LM head w by k-means
+func buildHeadClusters(w []byte, wtype uint32, vocab, dim int) *headClusters {
+	start := time.Now()
+	k := min(max(int(math.Sqrt(float64(vocab))), 4), 1024, vocab)
+	rowBytes := len(w) / vocab
+	row := func(i int) []float32 { return dequantRows(w[i*rowBytes:(i+1)*rowBytes], wtype, dim) }
+
+	rng := rand.New(rand.NewSource(1))
+	cent := make([]float32, k*dim)
+	for c, i := range rng.Perm(vocab)[:k] {
+		copy(cent[c*dim:], row(i))
+	}
+
+	assign := make([]int32, vocab)
+	best := make([]float32, vocab)
+	col := make([]float32, vocab)
+	counts := make([]int, k)
+	for it := 0; ; it++ {
+		// Assign: argmax_c w·μ − |μ|²/2 is the nearest centroid, one head matmul per c
+		for i := range best {
+			best[i] = float32(math.Inf(-1))
+		}
+		for c := 0; c < k; c++ {
+			mu := cent[c*dim : (c+1)*dim]
+			var half float32
+			for _, v := range mu {
+				half += v * v / 2
+			}
+			matmulDispatch(col, w, wtype, mu, vocab, dim)
+			for i, v := range col {
+				if v-half > best[i] {
+					best[i], assign[i] = v-half, int32(c)
+				}
+			}
+		}
+		if it == headKMeansIters-1 {
+			break
+		}
+
+		// Update: centroids to the mean of their rows; an empty one restarts at a random row
+		clear(cent)
+		clear(counts)
+		for i := 0; i < vocab; i++ {
+			c := int(assign[i])
+			counts[c]++
+			mu := cent[c*dim : (c+1)*dim]
+			for j, v := range row(i) {
+				mu[j] += v
+			}
+		}
+		for c, n := range counts {
+			mu := cent[c*dim : (c+1)*dim]
+			if n == 0 {
+				copy(mu, row(rng.Intn(vocab)))
+				continue
+			}
+			for j := range mu {
+				mu[j] /= float32(n)
+			}
+		}
+	}
+
+	h := &headClusters{
+		k:         k,
+		centroids: cent,
+		members:   make([][]int32, k),
+		budget:    max(vocab/headBudgetFrac, 1),
+		scores:    make([]float32, k),
+		order:     make([]int, k),
+		full:      make([]float32, vocab),
+	}
+	for i, c := range assign {
+		h.members[c] = append(h.members[c], int32(i))
+	}
+	fmt.Printf("[tongue/model] LM head: %d rows in %d clusters, %d computed per token (%.1fs)\n",
+		vocab, k, h.budget, time.Since(start).Seconds())
+	return h
+}
+
+// prunedHead computes State.Logits (before bias and softcap) in two stages
+func (m *LlamaModel) prunedHead() {
+	h, c, w := m.head, &m.Config, &m.Weights
+	logits, x := m.State.Logits, m.State.X
+	vocab, dim := c.VocabSize, c.EmbedDim
+	h.stats.Tokens++
+	if h.budget >= vocab {
+		matmulDispatch(logits, w.Output, w.OutputType, x, vocab, dim)
+		return
+	}
+
+	MatMulF32(h.scores, h.centroids, x, h.k, dim)
+	for i := range h.order {
+		h.order[i] = i
+	}
+	slices.SortFunc(h.order, h.byScore)
+
+	cand, chosen := h.cand[:0], 0
+	for _, ci := range h.order {
+		if len(cand) >= h.budget {
+			break
+		}
+		cand = append(cand, h.members[ci]...)
+		chosen++
+	}
+	h.cand = cand
+	matmulRowList(logits, w.Output, w.OutputType, x, cand, dim)
+
+	best := float32(math.Inf(-1))
+	for _, i := range cand {
+		best = max(best, logits[i])
+	}
+	skippedTop := float32(math.Inf(-1))
+	for _, ci := range h.order[chosen:] {
+		est := h.scores[ci]
+		skippedTop = max(skippedTop, est)
+		for _, i := range h.members[ci] {
+			logits[i] = est
+		}
+	}
+	if skippedTop > best {
+		h.stats.Fallbacks++
+		matmulDispatch(logits, w.Output, w.OutputType, x, vocab, dim)
+		return
+	}
+
+	if h.stats.Tokens%headAuditEvery == 0 {
+		h.stats.Audits++
+		matmulDispatch(h.full, w.Output, w.OutputType, x, vocab, dim)
+		if argmax(h.full, vocab) != argmax(logits, vocab) {
+			h.stats.Mismatches++
+			h.budget = min(2*h.budget, vocab)
+			fmt.Printf("[tongue/model] LM head audit: pruned argmax differs, %d rows per token from now\n", h.budget)
+		}
+		copy(logits, h.full)
+	}
+}
+
+// byScore orders clusters best centroid score first
+func (h *headClusters) byScore(a, b int) int {
+	return cmp.Compare(h.scores[b], h.scores[a])
+}
+
+// matmulRowList computes out[i] = w[i]·x for the listed rows, on the
+// matmul workers; runs of consecutive rows go to the kernels in one call
+func matmulRowList(out []float32, w []byte, wtype uint32, x []float32, rows []int32, cols int) {
+	var qa *q8Activations
+	if useQ8Act && (wtype == ggmlTypeQ4_0 || wtype == ggmlTypeQ8_0) {
+		qa = quantizeActivations(x[:cols])
+		defer q8ActPool.Put(qa)
+	}
+	parallelRows(len(rows), func(s, e int) {
+		for s < e {
+			run := s + 1
+			for run < e && rows[run] == rows[run-1]+1 {
+				run++
+			}
+			matmulRange(out, w, wtype, x, qa, int(rows[s]), int(rows[run-1])+1, cols)
+			s = run
+		}
+	})
+}
+
+// matmulRange computes rows [start, end) of w @ x on the calling goroutine,
+// with the kernel matmulDispatch would use (qa: x as Q8 for Q4_0/Q8_0)
+func matmulRange(out []float32, w []byte, wtype uint32, x []float32, qa *q8Activations, start, end, cols int) {
+	switch wtype {
+	case ggmlTypeQ4_0:
+		blocks := cols / q4BlockSize
+		if qa != nil {
+			matMulQ4_0Q8Range(out, w, qa, start, end, blocks, blocks*q4BytesPerBlock)
+		} else {
+			matMulQ4_0Range(out, w, x, start, end, blocks, blocks*q4BytesPerBlock)
+		}
+	case ggmlTypeQ8_0:
+		blocks := cols / q8BlockSize
+		if qa != nil {
+			matMulQ8_0Q8Range(out, w, qa, start, end, blocks, blocks*q8BytesPerBlock)
+		} else {
+			matMulQ8_0Range(out, w, x, start, end, blocks, blocks*q8BytesPerBlock)
+		}
+	case ggmlTypeQ6_K:
+		blocks := cols / q6kBlockSize
+		matMulQ6_KRange(out, w, x, start, end, blocks, blocks*q6kBytesPerBlock)
+	case ggmlTypeF16:
+		matMulF16Range(out, w, x, start, end, cols)
+	case ggmlTypeF32:
+		// GGUF tensor data is little-endian and 32-byte aligned
+		f32 := unsafe.Slice((*float32)(unsafe.Pointer(unsafe.SliceData(w))), len(w)/4)
+		matMulF32Range(out, f32, x, start, end, cols)
+	}
+}
@@ -0,0 +1,48 @@
+package yent
+
+// heal.go — Token healing at the prompt boundary
+//
+// BPE fixes the prompt's last token before the model has a say. A prompt
+// ending in "The answer is ind" is encoded with "ind" as its own token,
+// while in training "ind" + "ependent" was always "independent" — one
+// token the model can no longer produce, so it continues with whatever
+// follows an isolated "ind" and the output starts with an odd join.
+//
+// With GenerateOptions.TokenHealing the last prompt token is taken back
+// before prefill. The first draw is restricted to tokens whose text starts
+// with the removed text ("ind", "independent", "indeed", …), and that text
+// is cut from the front of what the token emits, so the output reads on
+// from where the prompt stopped. The fixed "### Answer:" suffix makes the
+// healed token usually ":" here; it matters most for prompts built to be
+// continued. Not with Grammar (the grammar would see the prompt's bytes)
+// or Beams.
+
+import "bytes"
+
+// HealBoundary takes back the last token of a prompt for token healing:
+// it returns the prompt without it and the text the first generated token
+// must start with. Control tokens and a single-token prompt are left alone.
+func (t *Tokenizer) HealBoundary(tokens []int) ([]int, string) {
+	if len(tokens) < 2 {
+		return tokens, ""
+	}
+	last := tokens[len(tokens)-1]
+	if last < 0 || last >= t.VocabSize || (last < len(t.Types) && t.Types[last] == 3) {
+		return tokens, ""
+	}
+	prefix := t.DecodeToken(last)
+	if prefix == "" {
+		return tokens, ""
+	}
+	return tokens[:len(tokens)-1], prefix
+}
+
+// maskHealing sets every token that does not start with prefix to -inf
+func (y *Yent) maskHealing(prefix string) {
+	logits, p := y.model.State.Logits, []byte(prefix)
+	for id, piece := range y.tokenPieces() {
+		if id < len(logits) && !bytes.HasPrefix(piece, p) {
+			logits[id] = -1e30
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package yent
+
+// hftokenizer.go — A HuggingFace tokenizer.json in place of the GGUF vocab
+//
+// Some community GGUFs ship a tokenizer that does not match their weights:
+// merges cut short by an old converter, added tokens missing, a vocab a
+// few hundred entries shorter than the embedding table. The model then
+// encodes prompts into different ids than it was trained on and nothing
+// fails loudly. LoadOptions.TokenizerJSON (CLI -tokenizer) takes the
+// tokenizer from the original repo instead:
+//
+//   model.vocab       token → id, plus added_tokens (special ones become
+//                     control tokens, matched whole in text)
+//   model.merges      "a b" or ["a", "b"], in rank order
+//   ByteLevel         anywhere in pre_tokenizer/decoder → GPT-2 mode,
+//                     otherwise SentencePiece, scored by merge rank
+//
+// Validation is against the weights, not the GGUF's own vocab: every id
+// must be a row of token_embd, ids may not repeat, and rows without a
+// token become [PADn] (unused), as Qwen pads 151665 tokens to 151936 rows.
+// BOS and EOS keep the GGUF's ids. Only BPE models are supported; a
+// Unigram or WordPiece tokenizer.json is an error.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// hfTokenizer is the part of tokenizer.json the loader reads
+type hfTokenizer struct {
+	Model struct {
+		Type   string            `json:"type"`
+		Vocab  map[string]int    `json:"vocab"`
+		Merges []json.RawMessage `json:"merges"`
+	} `json:"model"`
+	AddedTokens []struct {
+		ID      int    `json:"id"`
+		Content string `json:"content"`
+		Special bool   `json:"special"`
+	} `json:"added_tokens"`
+	Normalizer   *hfComponent `json:"normalizer"`
+	PreTokenizer *hfComponent `json:"pre_tokenizer"`
+	Decoder      *hfComponent `json:"decoder"`
+}
+
+// hfComponent is a normalizer, pre-tokenizer or decoder, possibly a Sequence
+type hfComponent struct {
+	Type           string         `json:"type"`
+	Normalizers    []*hfComponent `json:"normalizers"`
+	PreTokenizers  []*hfComponent `json:"pretokenizers"`
+	Decoders       []*hfComponent `json:"decoders"`
+	Prepend        string         `json:"prepend"`
+	AddPrefixSpace *bool          `json:"add_prefix_space"`
+	PrependScheme  string         `json:"prepend_scheme"`
+}
+
+// each calls fn on c and everything it contains
+func (c *hfComponent) each(fn func(*hfComponent)) {
+	if c == nil {
+		return
+	}
+	fn(c)
+	for _, list := range [][]*hfComponent{c.Normalizers, c.PreTokenizers, c.Decoders} {
+		for _, sub := range list {
+			sub.each(fn)
+		}
+	}
+}
+
+// ApplyTokenizerJSON replaces the tokenizer metadata of g with the
+// HuggingFace tokenizer.json at path, validated against g's embedding rows
+func ApplyTokenizerJSON(g *GGUFFile, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read tokenizer.json: %w", err)
+	}
+	var hf hfTokenizer
+	if err := json.Unmarshal(data, &hf); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	if hf.Model.Type != "" && hf.Model.Type != "BPE" {
+		return fmt.Errorf("%s: %s tokenizers are not supported (BPE only)", path, hf.Model.Type)
+	}
+	if len(hf.Model.Vocab) == 0 {
+		return fmt.Errorf("%s: empty model.vocab", path)
+	}
+
+	rows := g.Meta.VocabSize
+	if t, ok := g.Tensors["token_embd.weight"]; ok && t.NDims == 2 {
+		rows = int(t.Dims[1])
+	}
+
+	tokens := make([]string, rows)
+	types := make([]int32, rows)
+	set := func(tok string, id int, typ int32) error {
+		if id < 0 || id >= rows {
+			return fmt.Errorf("%s: token %q has id %d, the model has %d embedding rows", path, tok, id, rows)
+		}
+		if types[id] != 0 && tokens[id] != tok {
+			return fmt.Errorf("%s: id %d is both %q and %q", path, id, tokens[id], tok)
+		}
+		tokens[id], types[id] = tok, typ
+		return nil
+	}
+	for tok, id := range hf.Model.Vocab {
+		typ := int32(1) // normal
+		if _, ok := parseByteToken(tok); ok {
+			typ = 6
+		}
+		if err := set(tok, id, typ); err != nil {
+			return err
+		}
+	}
+	for _, at := range hf.AddedTokens {
+		typ := int32(4) // user-defined
+		if at.Special {
+			typ = 3 // control
+		}
+		if err := set(at.Content, at.ID, typ); err != nil {
+			return err
+		}
+	}
+	pads := 0
+	for id := range tokens {
+		if types[id] == 0 {
+			tokens[id], types[id] = fmt.Sprintf("[PAD%d]", id), 5 // unused
+			pads++
+		}
+	}
+
+	byteLevel, prefix := false, false
+	for _, c := range []*hfComponent{hf.Normalizer, hf.PreTokenizer, hf.Decoder} {
+		c.each(func(c *hfComponent) {
+			switch {
+			case c.Type == "ByteLevel":
+				byteLevel = true
+			case c.Type == "Prepend" && c.Prepend == "▁":
+				prefix = true
+			case c.Type == "Metaspace":
+				prefix = c.PrependScheme == "always" || c.PrependScheme == "first" ||
+					(c.PrependScheme == "" && c.AddPrefixSpace != nil && *c.AddPrefixSpace)
+			}
+		})
+	}
+
+	merges := make([]string, 0, len(hf.Model.Merges))
+	scores := make([]float32, rows)
+	for rank, raw := range hf.Model.Merges {
+		var pair string
+		var parts []string
+		if json.Unmarshal(raw, &pair) == nil {
+			parts = strings.SplitN(pair, " ", 2)
+		} else if err := json.Unmarshal(raw, &parts); err != nil {
+			return fmt.Errorf("%s: merge %d: %w", path, rank, err)
+		}
+		if len(parts) != 2 {
+			return fmt.Errorf("%s: merge %d is not a pair", path, rank)
+		}
+		merges = append(merges, parts[0]+" "+parts[1])
+		// SentencePiece mode merges by score: earlier rank, higher score
+		if id, ok := hf.Model.Vocab[parts[0]+parts[1]]; ok {
+			scores[id] = -float32(rank)
+		}
+	}
+
+	m := &g.Meta
+	differ := 0
+	for id, tok := range tokens {
+		if id >= len(m.TokenList) || m.TokenList[id] != tok {
+			differ++
+		}
+	}
+	m.TokenList, m.TokenTypes, m.VocabSize = tokens, types, rows
+	if byteLevel {
+		m.TokenizerModel, m.TokenMerges, m.TokenScores = "gpt2", merges, nil
+	} else {
+		m.TokenizerModel, m.TokenMerges, m.TokenScores, m.AddSpacePrefix = "llama", nil, scores, prefix
+	}
+	if m.BosID >= rows {
+		m.BosID = -1
+	}
+	if m.EosID >= rows {
+		m.EosID = -1
+	}
+	fmt.Printf("[tongue/tokenizer] %s: %d tokens, %d merges, %d padding rows; %d ids differ from the GGUF vocab\n",
+		path, rows-pads, len(merges), pads, differ)
+	return nil
+}
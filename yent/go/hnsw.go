@@ -0,0 +1,396 @@
+package yent
+
+// hnsw.go — Hierarchical Navigable Small World index (pure Go)
+//
+// Brute-force cosine is exact and fine for a few thousand memories. At 100k
+// it is a full pass over 100k×dim floats per query. HNSW keeps a layered
+// proximity graph: sparse long-range links on top, dense local links at the
+// bottom. Search descends greedily, then widens to ef candidates at layer 0.
+//
+// Malkov & Yashunin, "Efficient and robust approximate nearest neighbor
+// search using Hierarchical Navigable Small World graphs" (2016).
+//
+// Node i is slot i of the VectorStore; vectors are not duplicated here.
+// The index sits with the vectors on the Go side: LIMPHA (Python) stores
+// the rows and never sees an embedding.
+// Persisted next to the vectors as <name>.hnsw:
+//   "YHNS" | version u32 | M u32 | efConstruction u32 | entry i32 | maxLevel i32 | count u32
+//   count × (level u32 | (level+1) × (n u32 | n × i32))
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+)
+
+const hnswVersion = 1
+
+// HNSW defaults (hnswlib conventions)
+const (
+	hnswM              = 16
+	hnswEfConstruction = 200
+	hnswEfSearch       = 64
+)
+
+// hnswIndex is the layered graph over a VectorStore's slots
+type hnswIndex struct {
+	m        int // max links per node above layer 0
+	m0       int // max links per node at layer 0 (2·M)
+	efConstr int
+	levelMul float64
+
+	entry    int32
+	maxLevel int
+	links    [][][]int32 // links[node][layer] → neighbor slots
+	rng      *rand.Rand
+}
+
+// newHNSW creates an empty index
+func newHNSW() *hnswIndex {
+	return &hnswIndex{
+		m:        hnswM,
+		m0:       2 * hnswM,
+		efConstr: hnswEfConstruction,
+		levelMul: 1 / math.Log(hnswM),
+		entry:    -1,
+		rng:      rand.New(rand.NewSource(42)),
+	}
+}
+
+// insert links slot i (vector already stored in vecs[i]) into the graph
+func (h *hnswIndex) insert(vecs [][]float32, i int) {
+	level := int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMul))
+	for len(h.links) <= i {
+		h.links = append(h.links, nil)
+	}
+	h.links[i] = make([][]int32, level+1)
+
+	if h.entry < 0 {
+		h.entry, h.maxLevel = int32(i), level
+		return
+	}
+
+	q := vecs[i]
+	ep := h.entry
+	// Greedy descent through layers above the new node's level
+	for lc := h.maxLevel; lc > level; lc-- {
+		ep = h.greedy(vecs, q, ep, lc)
+	}
+
+	entries := []int32{ep}
+	for lc := min(level, h.maxLevel); lc >= 0; lc-- {
+		cands := h.searchLayer(vecs, q, entries, h.efConstr, lc)
+		maxLinks := h.m
+		if lc == 0 {
+			maxLinks = h.m0
+		}
+		neighbors := h.selectNeighbors(vecs, cands, h.m)
+		h.links[i][lc] = neighbors
+		for _, n := range neighbors {
+			h.links[n][lc] = append(h.links[n][lc], int32(i))
+			if len(h.links[n][lc]) > maxLinks {
+				h.links[n][lc] = h.shrink(vecs, int(n), h.links[n][lc], maxLinks)
+			}
+		}
+		entries = entries[:0]
+		for _, c := range cands {
+			entries = append(entries, c.id)
+		}
+	}
+
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = int32(i), level
+	}
+}
+
+// search returns up to k nearest slots to q, closest first
+func (h *hnswIndex) search(vecs [][]float32, q []float32, k, ef int) []hnswCand {
+	if h.entry < 0 {
+		return nil
+	}
+	if ef < k {
+		ef = k
+	}
+	ep := h.entry
+	for lc := h.maxLevel; lc > 0; lc-- {
+		ep = h.greedy(vecs, q, ep, lc)
+	}
+	cands := h.searchLayer(vecs, q, []int32{ep}, ef, 0)
+	if len(cands) > k {
+		cands = cands[:k]
+	}
+	return cands
+}
+
+// greedy walks layer lc toward q from ep until no neighbor is closer
+func (h *hnswIndex) greedy(vecs [][]float32, q []float32, ep int32, lc int) int32 {
+	best := cosDist(q, vecs[ep])
+	for changed := true; changed; {
+		changed = false
+		for _, n := range h.links[ep][lc] {
+			if d := cosDist(q, vecs[n]); d < best {
+				best, ep, changed = d, n, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer is the beam search at one layer; returns candidates closest first
+func (h *hnswIndex) searchLayer(vecs [][]float32, q []float32, entries []int32, ef, lc int) []hnswCand {
+	visited := make(map[int32]bool, ef*4)
+	var frontier hnswMinHeap // closest first
+	var results hnswMaxHeap  // farthest first, size ≤ ef
+	for _, e := range entries {
+		if visited[e] {
+			continue
+		}
+		visited[e] = true
+		c := hnswCand{e, cosDist(q, vecs[e])}
+		heap.Push(&frontier, c)
+		heap.Push(&results, c)
+	}
+
+	for frontier.Len() > 0 {
+		c := heap.Pop(&frontier).(hnswCand)
+		if results.Len() >= ef && c.dist > results[0].dist {
+			break
+		}
+		if lc >= len(h.links[c.id]) {
+			continue
+		}
+		for _, n := range h.links[c.id][lc] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			d := cosDist(q, vecs[n])
+			if results.Len() < ef || d < results[0].dist {
+				heap.Push(&frontier, hnswCand{n, d})
+				heap.Push(&results, hnswCand{n, d})
+				if results.Len() > ef {
+					heap.Pop(&results)
+				}
+			}
+		}
+	}
+
+	out := make([]hnswCand, results.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(&results).(hnswCand)
+	}
+	return out
+}
+
+// selectNeighbors applies the diversity heuristic: keep a candidate only if
+// it is closer to q than to every neighbor already kept
+func (h *hnswIndex) selectNeighbors(vecs [][]float32, cands []hnswCand, m int) []int32 {
+	out := make([]int32, 0, m)
+	for _, c := range cands {
+		if len(out) >= m {
+			break
+		}
+		keep := true
+		for _, s := range out {
+			if cosDist(vecs[c.id], vecs[s]) < c.dist {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out = append(out, c.id)
+		}
+	}
+	// Fill with the closest leftovers so sparse regions stay connected
+	for _, c := range cands {
+		if len(out) >= m {
+			break
+		}
+		if !containsInt32(out, c.id) {
+			out = append(out, c.id)
+		}
+	}
+	return out
+}
+
+// shrink trims node's link list back to maxLinks
+func (h *hnswIndex) shrink(vecs [][]float32, node int, links []int32, maxLinks int) []int32 {
+	cands := make([]hnswCand, len(links))
+	for i, n := range links {
+		cands[i] = hnswCand{n, cosDist(vecs[node], vecs[n])}
+	}
+	sortCands(cands)
+	return h.selectNeighbors(vecs, cands, maxLinks)
+}
+
+// save writes the graph
+func (h *hnswIndex) save(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create hnsw: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString("YHNS")
+	binary.Write(w, binary.LittleEndian, []uint32{hnswVersion, uint32(h.m), uint32(h.efConstr)})
+	binary.Write(w, binary.LittleEndian, []int32{h.entry, int32(h.maxLevel)})
+	binary.Write(w, binary.LittleEndian, uint32(len(h.links)))
+	for _, layers := range h.links {
+		binary.Write(w, binary.LittleEndian, uint32(len(layers)-1))
+		for _, l := range layers {
+			binary.Write(w, binary.LittleEndian, uint32(len(l)))
+			binary.Write(w, binary.LittleEndian, l)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("write hnsw: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close hnsw: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadHNSW reads a graph over the first nodes of a store of count slots; a
+// graph with fewer nodes is a prefix the caller catches up by inserting the
+// rest (slots are append-only)
+func loadHNSW(path string, count int) (*hnswIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != "YHNS" {
+		return nil, fmt.Errorf("%s: not an hnsw index", path)
+	}
+	hdr := make([]uint32, 3)
+	ep := make([]int32, 2)
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, hdr); err != nil {
+		return nil, fmt.Errorf("read hnsw header: %w", err)
+	}
+	if hdr[0] != hnswVersion {
+		return nil, fmt.Errorf("%s: unsupported version %d", path, hdr[0])
+	}
+	if err := binary.Read(r, binary.LittleEndian, ep); err != nil {
+		return nil, fmt.Errorf("read hnsw header: %w", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, fmt.Errorf("read hnsw header: %w", err)
+	}
+	if int(n) > count {
+		return nil, fmt.Errorf("%s: %d nodes, store has %d", path, n, count)
+	}
+	// Everything below indexes by these, so a corrupt file must fail here
+	if hdr[1] < 2 || hdr[1] > 1024 || ep[1] < 0 || ep[1] > 64 ||
+		(n == 0) != (ep[0] < 0) || ep[0] >= int32(n) {
+		return nil, fmt.Errorf("%s: corrupt header (M %d, entry %d, max level %d, %d nodes)", path, hdr[1], ep[0], ep[1], n)
+	}
+
+	h := newHNSW()
+	h.m, h.m0, h.efConstr = int(hdr[1]), 2*int(hdr[1]), int(hdr[2])
+	h.levelMul = 1 / math.Log(float64(h.m))
+	h.entry, h.maxLevel = ep[0], int(ep[1])
+	h.links = make([][][]int32, n)
+	for i := range h.links {
+		var level, cnt uint32
+		if err := binary.Read(r, binary.LittleEndian, &level); err != nil {
+			return nil, fmt.Errorf("read hnsw node %d: %w", i, err)
+		}
+		if int(level) > h.maxLevel {
+			return nil, fmt.Errorf("%s: node %d at level %d above max %d", path, i, level, h.maxLevel)
+		}
+		h.links[i] = make([][]int32, level+1)
+		for lc := range h.links[i] {
+			if err := binary.Read(r, binary.LittleEndian, &cnt); err != nil {
+				return nil, fmt.Errorf("read hnsw node %d: %w", i, err)
+			}
+			if int(cnt) > h.m0 {
+				return nil, fmt.Errorf("%s: node %d has %d links, max %d", path, i, cnt, h.m0)
+			}
+			h.links[i][lc] = make([]int32, cnt)
+			if err := binary.Read(r, binary.LittleEndian, h.links[i][lc]); err != nil {
+				return nil, fmt.Errorf("read hnsw node %d: %w", i, err)
+			}
+		}
+	}
+
+	// Links must point at nodes that exist on that layer
+	if n > 0 && len(h.links[h.entry]) != h.maxLevel+1 {
+		return nil, fmt.Errorf("%s: entry %d is not on the top level", path, h.entry)
+	}
+	for i, layers := range h.links {
+		for lc, l := range layers {
+			for _, id := range l {
+				if id < 0 || id >= int32(n) || len(h.links[id]) <= lc {
+					return nil, fmt.Errorf("%s: node %d links to %d on level %d", path, i, id, lc)
+				}
+			}
+		}
+	}
+	return h, nil
+}
+
+// hnswCand is a node with its distance to the query
+type hnswCand struct {
+	id   int32
+	dist float32
+}
+
+type hnswMinHeap []hnswCand
+
+func (h hnswMinHeap) Len() int            { return len(h) }
+func (h hnswMinHeap) Less(i, j int) bool  { return h[i].dist < h[j].dist }
+func (h hnswMinHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswMinHeap) Push(x interface{}) { *h = append(*h, x.(hnswCand)) }
+func (h *hnswMinHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type hnswMaxHeap []hnswCand
+
+func (h hnswMaxHeap) Len() int            { return len(h) }
+func (h hnswMaxHeap) Less(i, j int) bool  { return h[i].dist > h[j].dist }
+func (h hnswMaxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswMaxHeap) Push(x interface{}) { *h = append(*h, x.(hnswCand)) }
+func (h *hnswMaxHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// sortCands sorts by distance, closest first (insertion sort: lists are short)
+func sortCands(c []hnswCand) {
+	for i := 1; i < len(c); i++ {
+		for j := i; j > 0 && c[j].dist < c[j-1].dist; j-- {
+			c[j], c[j-1] = c[j-1], c[j]
+		}
+	}
+}
+
+// cosDist is cosine distance between unit vectors
+func cosDist(a, b []float32) float32 {
+	return 1 - dotF32(a, b)
+}
+
+func containsInt32(s []int32, v int32) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
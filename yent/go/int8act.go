@@ -0,0 +1,100 @@
+//go:build int8
+
+package yent
+
+// int8act.go — Activations kept in int8 from layer to layer
+//
+// q8act.go already rounds a matmul's input to int8, in blocks of 32 with a
+// float scale each, and throws the int8 copy away afterwards. On small ARM
+// boards (no fast float16, little cache) the float32 vectors between the
+// matmuls are traffic too. Built with -tags int8, LoadOptions.Int8 goes
+// further:
+//
+//   matmul inputs   int8 with one scale per tensor, straight into the
+//                   Q4_0/Q8_0 integer kernels (other weight types see the
+//                   dequantized values)
+//   residual        stored as int8 + scale between layers, the float32
+//                   copy being only the working view of the current layer
+//
+// Norms, RoPE, the softmax of attention and the KV cache stay float32: the
+// first three are numerically fragile, and the cache has its own layout.
+// One scale per tensor is coarser than q8act.go's per-block scales, so an
+// outlier channel costs the rest of the vector precision; Perplexity on a
+// held-out text against the float path is the check (tests/int8_test.go).
+//
+// Without the tag the mode is compiled out (int8act_off.go) and Int8 falls
+// back to the float path with a warning.
+
+import "math"
+
+// int8Path holds the int8 buffers of a model loaded with LoadOptions.Int8
+type int8Path struct {
+	in    q8Activations // matmul input, every block sharing one scale
+	view  q8Activations // in, cut to the current matmul
+	x     []float32     // dequantized input for weight types without a Q8 kernel
+	res   []int8        // residual stream between layers
+	scale float32       // scale of res
+}
+
+// newInt8Path allocates the buffers for cfg
+func newInt8Path(cfg *LlamaConfig) (*int8Path, error) {
+	n := max(cfg.EmbedDim, cfg.IntermSize, cfg.NumHeads*cfg.HeadDim)
+	return &int8Path{
+		in:  q8Activations{qs: make([]int8, n), ds: make([]float32, (n+31)/32)},
+		x:   make([]float32, n),
+		res: make([]int8, cfg.EmbedDim),
+	}, nil
+}
+
+// quantizeTensor rounds x to int8 in dst with one scale, returned
+func quantizeTensor(dst []int8, x []float32) float32 {
+	var amax float32
+	for _, v := range x {
+		amax = max(amax, float32(math.Abs(float64(v))))
+	}
+	if amax == 0 {
+		clear(dst[:len(x)])
+		return 0
+	}
+	d := amax / 127
+	inv := 1 / d
+	for i, v := range x {
+		dst[i] = int8(math.Round(float64(v * inv)))
+	}
+	return d
+}
+
+// matmul computes out = W @ x with x quantized to int8 as one tensor
+func (p *int8Path) matmul(out []float32, w []byte, wtype uint32, x []float32, rows, cols int) {
+	d := quantizeTensor(p.in.qs, x[:cols])
+	switch wtype {
+	case ggmlTypeQ4_0, ggmlTypeQ8_0:
+		qa := &p.view
+		qa.qs, qa.ds = p.in.qs[:cols], p.in.ds[:cols/32]
+		for b := range qa.ds {
+			qa.ds[b] = d
+		}
+		parallelRows(rows, func(s, e int) {
+			matmulRange(out, w, wtype, nil, qa, s, e, cols)
+		})
+	default:
+		for i, q := range p.in.qs[:cols] {
+			p.x[i] = float32(q) * d
+		}
+		matmulDispatch(out, w, wtype, p.x[:cols], rows, cols)
+	}
+}
+
+// carry stores the residual x as int8 and leaves x holding what the next
+// layer reads back
+func (p *int8Path) carry(x []float32) {
+	p.scale = quantizeTensor(p.res, x)
+	for i, q := range p.res {
+		x[i] = float32(q) * p.scale
+	}
+}
+
+// Int8 reports whether the model runs with int8 activations
+func (m *LlamaModel) Int8() bool {
+	return m.int8 != nil
+}
@@ -0,0 +1,22 @@
+//go:build !int8
+
+package yent
+
+import "errors"
+
+// int8Path is compiled in with -tags int8 (int8act.go)
+type int8Path struct{}
+
+func newInt8Path(cfg *LlamaConfig) (*int8Path, error) {
+	return nil, errors.New("int8 activations need a build with -tags int8")
+}
+
+func (p *int8Path) matmul(out []float32, w []byte, wtype uint32, x []float32, rows, cols int) {}
+
+func (p *int8Path) carry(x []float32) {}
+
+// Int8 reports whether the model runs with int8 activations (never in
+// this build)
+func (m *LlamaModel) Int8() bool {
+	return false
+}
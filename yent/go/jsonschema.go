@@ -0,0 +1,418 @@
+package yent
+
+// jsonschema.go — JSON Schema → GBNF
+//
+// Enough of JSON Schema for tool calls and structured answers:
+//
+//   type (string, number, integer, boolean, null, array, object, or a list)
+//   properties + required   properties are emitted in schema order,
+//                           required ones always, optional ones may be skipped
+//   items, enum, const, anyOf / oneOf, minLength / maxLength,
+//   $ref to #/definitions/… or #/$defs/…
+//
+// Anything else is accepted as an arbitrary JSON value of the given type.
+// Objects take no extra properties: the model says what was asked, nothing more.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// jsonPrimitives are the shared rules every converted schema can reference
+const jsonPrimitives = `ws ::= ([ \t\n] [ \t\n]?)?
+string ::= "\"" char* "\""
+char ::= [^"\\\x00-\x1F\x7F] | "\\" (["\\/bfnrt] | "u" [0-9a-fA-F]{4})
+number ::= "-"? ("0" | [1-9] [0-9]{0,15}) ("." [0-9]{1,16})? ([eE] [-+]? [0-9]{1,3})?
+integer ::= "-"? ("0" | [1-9] [0-9]{0,15})
+boolean ::= "true" | "false"
+null ::= "null"
+value ::= object | array | string | number | boolean | null
+object ::= "{" ws (string ws ":" ws value ws ("," ws string ws ":" ws value ws)*)? "}"
+array ::= "[" ws (value ws ("," ws value ws)*)? "]"
+`
+
+// jsonPrimitiveRules are the names converted rules must not take
+var jsonPrimitiveRules = map[string]bool{
+	"root": true, "ws": true, "string": true, "char": true, "number": true, "integer": true,
+	"boolean": true, "null": true, "value": true, "object": true, "array": true,
+}
+
+// JSONGrammar returns a grammar for any JSON object
+func JSONGrammar() *Grammar {
+	g, err := ParseGrammar("root ::= object\n" + jsonPrimitives)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// JSONSchemaGrammar compiles a JSON Schema into a grammar
+func JSONSchemaGrammar(schema []byte) (*Grammar, error) {
+	src, err := JSONSchemaToGBNF(schema)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGrammar(src)
+}
+
+// JSONSchemaToGBNF converts a JSON Schema to GBNF source
+func JSONSchemaToGBNF(schema []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(schema))
+	dec.UseNumber()
+	root, err := decodeOrdered(dec)
+	if err != nil {
+		return "", fmt.Errorf("json schema: %w", err)
+	}
+	c := &schemaConverter{root: root, rules: make(map[string]string), refs: make(map[string]string)}
+	body, err := c.visit(root, "schema")
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("root ::= " + body + "\n")
+	names := make([]string, 0, len(c.rules))
+	for name := range c.rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString(name + " ::= " + c.rules[name] + "\n")
+	}
+	b.WriteString(jsonPrimitives)
+	return b.String(), nil
+}
+
+// orderedObject is a JSON object that remembers key order
+type orderedObject struct {
+	keys []string
+	vals map[string]interface{}
+}
+
+// decodeOrdered decodes one JSON value, keeping object key order
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := &orderedObject{vals: make(map[string]interface{})}
+			for dec.More() {
+				kt, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, _ := kt.(string)
+				val, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				if _, dup := obj.vals[key]; !dup {
+					obj.keys = append(obj.keys, key)
+				}
+				obj.vals[key] = val
+			}
+			_, err := dec.Token()
+			return obj, err
+		case '[':
+			var arr []interface{}
+			for dec.More() {
+				v, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+		return nil, fmt.Errorf("unexpected %v", t)
+	default:
+		return tok, nil
+	}
+}
+
+// toPlain converts ordered values back to encoding/json types
+func toPlain(v interface{}) interface{} {
+	switch t := v.(type) {
+	case *orderedObject:
+		m := make(map[string]interface{}, len(t.keys))
+		for _, k := range t.keys {
+			m[k] = toPlain(t.vals[k])
+		}
+		return m
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, x := range t {
+			out[i] = toPlain(x)
+		}
+		return out
+	}
+	return v
+}
+
+type schemaConverter struct {
+	root  interface{}
+	rules map[string]string // name → body
+	refs  map[string]string // $ref → rule name
+}
+
+// rule registers body under a fresh name derived from hint
+func (c *schemaConverter) rule(hint, body string) string {
+	name := gbnfName(hint)
+	base := name
+	for i := 2; c.rules[name] != "" || jsonPrimitiveRules[name]; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	c.rules[name] = body
+	return name
+}
+
+// visit returns a GBNF expression matching schema s
+func (c *schemaConverter) visit(s interface{}, hint string) (string, error) {
+	if b, ok := s.(bool); ok {
+		if !b {
+			return "", fmt.Errorf("json schema %s: false schema matches nothing", hint)
+		}
+		return "value", nil
+	}
+	obj, ok := s.(*orderedObject)
+	if !ok {
+		return "", fmt.Errorf("json schema %s: expected an object", hint)
+	}
+
+	if ref, ok := obj.vals["$ref"].(string); ok {
+		return c.visitRef(ref)
+	}
+	if v, ok := obj.vals["const"]; ok {
+		return jsonLiteral(v)
+	}
+	if vals, ok := obj.vals["enum"].([]interface{}); ok {
+		var alts []string
+		for _, v := range vals {
+			lit, err := jsonLiteral(v)
+			if err != nil {
+				return "", err
+			}
+			alts = append(alts, lit)
+		}
+		return "(" + strings.Join(alts, " | ") + ")", nil
+	}
+	for _, key := range []string{"anyOf", "oneOf"} {
+		if subs, ok := obj.vals[key].([]interface{}); ok {
+			var alts []string
+			for i, sub := range subs {
+				alt, err := c.visit(sub, fmt.Sprintf("%s-%d", hint, i))
+				if err != nil {
+					return "", err
+				}
+				alts = append(alts, alt)
+			}
+			return "(" + strings.Join(alts, " | ") + ")", nil
+		}
+	}
+
+	switch t := obj.vals["type"].(type) {
+	case string:
+		return c.visitType(obj, t, hint)
+	case []interface{}:
+		var alts []string
+		for _, tt := range t {
+			name, _ := tt.(string)
+			alt, err := c.visitType(obj, name, hint)
+			if err != nil {
+				return "", err
+			}
+			alts = append(alts, alt)
+		}
+		return "(" + strings.Join(alts, " | ") + ")", nil
+	}
+	if _, ok := obj.vals["properties"]; ok {
+		return c.visitType(obj, "object", hint)
+	}
+	if _, ok := obj.vals["items"]; ok {
+		return c.visitType(obj, "array", hint)
+	}
+	return "value", nil
+}
+
+// visitType handles one JSON type
+func (c *schemaConverter) visitType(obj *orderedObject, typ, hint string) (string, error) {
+	switch typ {
+	case "string":
+		lo, hasLo := schemaInt(obj.vals["minLength"])
+		hi, hasHi := schemaInt(obj.vals["maxLength"])
+		if !hasLo && !hasHi {
+			return "string", nil
+		}
+		rep := fmt.Sprintf("{%d,}", lo)
+		if hasHi {
+			rep = fmt.Sprintf("{%d,%d}", lo, hi)
+		}
+		return c.rule(hint, `"\"" char`+rep+` "\""`), nil
+	case "number", "integer", "boolean", "null":
+		return typ, nil
+	case "array":
+		items, ok := obj.vals["items"]
+		if !ok {
+			return "array", nil
+		}
+		item, err := c.visit(items, hint+"-item")
+		if err != nil {
+			return "", err
+		}
+		return c.rule(hint, `"[" ws (`+item+` ws ("," ws `+item+` ws)*)? "]"`), nil
+	case "object":
+		props, ok := obj.vals["properties"].(*orderedObject)
+		if !ok || len(props.keys) == 0 {
+			return "object", nil
+		}
+		return c.visitObject(obj, props, hint)
+	}
+	return "", fmt.Errorf("json schema %s: unsupported type %q", hint, typ)
+}
+
+// visitObject emits required properties in order, then optional ones that may be skipped
+func (c *schemaConverter) visitObject(obj, props *orderedObject, hint string) (string, error) {
+	required := make(map[string]bool)
+	if req, ok := obj.vals["required"].([]interface{}); ok {
+		for _, r := range req {
+			if name, ok := r.(string); ok {
+				required[name] = true
+			}
+		}
+	}
+
+	var req, opt []string
+	for _, key := range props.keys {
+		val, err := c.visit(props.vals[key], hint+"-"+key)
+		if err != nil {
+			return "", err
+		}
+		name, _ := jsonLiteral(key)
+		kv := name + ` ws ":" ws ` + val + " ws"
+		if required[key] {
+			req = append(req, kv)
+		} else {
+			opt = append(opt, kv)
+		}
+	}
+
+	// tail_i: optional properties i.. each preceded by a comma, each skippable
+	tails := make([]string, len(opt)+1)
+	for i := len(opt) - 1; i >= 0; i-- {
+		body := `("," ws ` + opt[i] + ")?"
+		if tails[i+1] != "" {
+			body += " " + tails[i+1]
+		}
+		tails[i] = c.rule(hint+"-opt", body)
+	}
+	tail := tails[0]
+
+	var body string
+	if len(req) > 0 {
+		body = strings.Join(req, ` "," ws `)
+		if tail != "" {
+			body += " " + tail
+		}
+	} else {
+		// No required properties: the first present one has no comma
+		var firsts []string
+		for i := range opt {
+			first := opt[i]
+			if tails[i+1] != "" {
+				first += " " + tails[i+1]
+			}
+			firsts = append(firsts, first)
+		}
+		body = "(" + strings.Join(firsts, " | ") + ")?"
+	}
+	return c.rule(hint, `"{" ws `+body+` "}"`), nil
+}
+
+// visitRef resolves a local $ref, compiling each target once (recursion-safe)
+func (c *schemaConverter) visitRef(ref string) (string, error) {
+	if name, ok := c.refs[ref]; ok {
+		return name, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return "", fmt.Errorf("json schema: only local $ref supported, got %q", ref)
+	}
+	target := c.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		obj, ok := target.(*orderedObject)
+		if !ok {
+			return "", fmt.Errorf("json schema: bad $ref %q", ref)
+		}
+		if target, ok = obj.vals[part]; !ok {
+			return "", fmt.Errorf("json schema: $ref %q not found", ref)
+		}
+	}
+	parts := strings.Split(ref, "/")
+	name := c.rule("ref-"+parts[len(parts)-1], "value") // placeholder until the body is known
+	c.refs[ref] = name
+	body, err := c.visit(target, name+"-body")
+	if err != nil {
+		return "", err
+	}
+	c.rules[name] = body
+	return name, nil
+}
+
+// jsonLiteral returns a GBNF literal matching v's compact JSON encoding
+func jsonLiteral(v interface{}) (string, error) {
+	raw, err := json.Marshal(toPlain(v))
+	if err != nil {
+		return "", fmt.Errorf("json schema: %w", err)
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range string(raw) {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r < 0x20:
+			fmt.Fprintf(&b, `\x%02X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String(), nil
+}
+
+// gbnfName turns a hint into a valid rule name
+func gbnfName(hint string) string {
+	name := strings.Map(func(r rune) rune {
+		if isGrammarIdent(r) {
+			return r
+		}
+		return '-'
+	}, hint)
+	if name == "" {
+		return "r"
+	}
+	return name
+}
+
+// schemaInt reads a non-negative integer keyword
+func schemaInt(v interface{}) (int, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	if err != nil || i < 0 {
+		return 0, false
+	}
+	return int(i), true
+}
@@ -0,0 +1,142 @@
+package yent
+
+// langdetect.go — Which language did Yent answer in?
+//
+// Delta Voice trades personality for languages, and the only way to pick
+// an alpha is to look at what comes out. DetectLanguage is a small offline
+// detector good enough for that: the writing system decides most languages
+// outright (Cyrillic, Han, Kana, Hangul, Arabic, Hebrew, Greek, Devanagari,
+// Thai); inside Latin and Cyrillic, function words decide between the
+// languages sharing the script.
+//
+// It is meant for answers of a sentence or more. Below a handful of letters
+// it returns "" rather than guess.
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopwords are frequent function words, distinctive per language
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "you", "that", "of", "to", "it", "not", "what", "are", "with", "this", "have", "i"},
+	"fr": {"le", "la", "les", "et", "est", "je", "tu", "vous", "que", "qui", "pas", "une", "des", "dans", "ce", "suis", "du"},
+	"de": {"der", "die", "das", "und", "ist", "ich", "du", "nicht", "ein", "eine", "zu", "mit", "sie", "wir", "auf", "bin"},
+	"es": {"el", "la", "los", "las", "y", "es", "que", "no", "un", "una", "en", "por", "con", "soy", "yo", "pero", "muy"},
+	"it": {"il", "la", "che", "non", "sono", "una", "di", "per", "con", "io", "gli", "ma", "come", "questo", "è", "anche"},
+	"pt": {"o", "a", "os", "que", "não", "um", "uma", "eu", "com", "para", "é", "sou", "você", "mas", "muito", "isso"},
+	"nl": {"de", "het", "een", "en", "is", "ik", "niet", "je", "van", "dat", "die", "zijn", "met", "maar", "ben"},
+	"pl": {"i", "nie", "jest", "się", "to", "że", "na", "w", "jestem", "ale", "co", "jak", "tak", "mnie", "ty"},
+	"tr": {"ve", "bir", "bu", "ben", "sen", "değil", "ne", "için", "çok", "ama", "da", "de", "mi", "var", "gibi"},
+	"ru": {"и", "не", "я", "ты", "что", "это", "в", "на", "как", "но", "меня", "так", "он", "мы", "есть", "кто"},
+	"uk": {"і", "не", "я", "ти", "що", "це", "в", "на", "як", "але", "мене", "так", "є", "ми", "хто", "та"},
+}
+
+// scriptLanguages maps a writing system to its language when unambiguous
+var scriptLanguages = map[string]string{
+	"hiragana": "ja", "katakana": "ja", "hangul": "ko", "han": "zh", "arabic": "ar",
+	"hebrew": "he", "greek": "el", "devanagari": "hi", "thai": "th",
+}
+
+// letterScript names the writing system of a letter
+func letterScript(r rune) string {
+	switch {
+	case unicode.Is(unicode.Latin, r):
+		return "latin"
+	case unicode.Is(unicode.Cyrillic, r):
+		return "cyrillic"
+	case unicode.Is(unicode.Hiragana, r):
+		return "hiragana"
+	case unicode.Is(unicode.Katakana, r):
+		return "katakana"
+	case unicode.Is(unicode.Hangul, r):
+		return "hangul"
+	case unicode.Is(unicode.Han, r):
+		return "han"
+	case unicode.Is(unicode.Arabic, r):
+		return "arabic"
+	case unicode.Is(unicode.Hebrew, r):
+		return "hebrew"
+	case unicode.Is(unicode.Greek, r):
+		return "greek"
+	case unicode.Is(unicode.Devanagari, r):
+		return "devanagari"
+	case unicode.Is(unicode.Thai, r):
+		return "thai"
+	}
+	return "other"
+}
+
+// DetectLanguage returns an ISO 639-1 code for text and the share of the
+// evidence behind it ("" when there is too little text to tell)
+func DetectLanguage(text string) (string, float32) {
+	scripts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			scripts[letterScript(r)]++
+			letters++
+		}
+	}
+	if letters < 8 {
+		return "", 0
+	}
+	// Japanese mixes Kanji with Kana: any Kana makes it Japanese
+	if scripts["hiragana"]+scripts["katakana"] > 0 {
+		scripts["hiragana"] += scripts["han"]
+		scripts["han"] = 0
+	}
+	script, n := "", 0
+	for s, c := range scripts {
+		if c > n || (c == n && s < script) {
+			script, n = s, c
+		}
+	}
+	share := float32(n) / float32(letters)
+	if lang, ok := scriptLanguages[script]; ok {
+		return lang, share
+	}
+	if script != "latin" && script != "cyrillic" {
+		return "", 0
+	}
+
+	// Same script: count function words
+	counts := make(map[string]int)
+	total := 0
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		for lang, words := range stopwords {
+			if (lang == "ru" || lang == "uk") != (script == "cyrillic") {
+				continue
+			}
+			for _, sw := range words {
+				if w == sw {
+					counts[lang]++
+					total++
+					break
+				}
+			}
+		}
+	}
+	if script == "cyrillic" {
+		// Ukrainian letters settle it
+		if strings.ContainsAny(strings.ToLower(text), "іїєґ") {
+			return "uk", share
+		}
+		if counts["uk"] > counts["ru"] {
+			return "uk", share
+		}
+		return "ru", share
+	}
+	best, bestN := "", 0
+	for lang, c := range counts {
+		if c > bestN || (c == bestN && lang < best) {
+			best, bestN = lang, c
+		}
+	}
+	if bestN == 0 {
+		return "", 0
+	}
+	return best, share * float32(bestN) / float32(total)
+}
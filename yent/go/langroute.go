@@ -0,0 +1,136 @@
+package yent
+
+// langroute.go — The prompt's language picks the alpha
+//
+// With Delta Voice loaded, a user switching languages had to type /ru or
+// /fr first; forget it and a Russian question gets an English answer at
+// alpha 0. With GenerateOptions.AutoLanguage the prompt's language is
+// detected and a route sets alpha, and optionally the delta, for that one
+// call:
+//
+//   en → alpha 0      ru → alpha 0.5      fr → alpha 0.9      * → 0.5
+//
+// "*" catches every other detected language. Yent.LanguageRoutes replaces
+// the table; a route's Delta names a loaded delta (deltas.go), so "ru" can
+// go to a Russian delta and "fr" to a French one. An explicit
+// GenerateOptions.Delta wins over the route's. A prompt too short to tell
+// keeps the instance's alpha and delta.
+//
+// The detected language is reported either way: GenerateResult.Language,
+// "language" in the HTTP reply, and the LIMPHA record.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// LanguageRoute is what a detected prompt language switches to
+type LanguageRoute struct {
+	Delta string  `json:"delta,omitempty"` // loaded delta ("" = the active one)
+	Alpha float32 `json:"alpha"`
+}
+
+// DefaultLanguageRoutes mirrors the REPL's /en, /ru and /fr, with "*" for
+// any other language
+func DefaultLanguageRoutes() map[string]LanguageRoute {
+	return map[string]LanguageRoute{
+		"en": {Alpha: 0},
+		"ru": {Alpha: 0.5},
+		"fr": {Alpha: 0.9},
+		"*":  {Alpha: 0.5},
+	}
+}
+
+// DetectPromptLanguage is DetectLanguage for short prompts: below its
+// threshold, a prompt written in a single non-Latin script still tells
+// its language ("Привет" is Russian, "你好" Chinese)
+func DetectPromptLanguage(prompt string) string {
+	if lang, _ := DetectLanguage(prompt); lang != "" {
+		return lang
+	}
+	scripts := make(map[string]int)
+	letters := 0
+	for _, r := range prompt {
+		if unicode.IsLetter(r) {
+			scripts[letterScript(r)]++
+			letters++
+		}
+	}
+	if letters < 2 {
+		return ""
+	}
+	// Kanji next to Kana is Japanese
+	if kana := scripts["hiragana"] + scripts["katakana"]; kana > 0 && kana+scripts["han"] == letters {
+		return "ja"
+	}
+	if len(scripts) != 1 {
+		return ""
+	}
+	for script := range scripts {
+		if script == "cyrillic" {
+			if strings.ContainsAny(strings.ToLower(prompt), "іїєґ") {
+				return "uk"
+			}
+			return "ru"
+		}
+		return scriptLanguages[script] // "" for Latin: too short to tell
+	}
+	return ""
+}
+
+// routeLanguage detects the prompt's language and, with AutoLanguage,
+// applies its route to this call. The returned func restores the alpha.
+func (y *Yent) routeLanguage(prompt string, opts *GenerateOptions) (string, func()) {
+	lang := DetectPromptLanguage(prompt)
+	if !opts.AutoLanguage || lang == "" {
+		return lang, func() {}
+	}
+	routes := y.LanguageRoutes
+	if routes == nil {
+		routes = DefaultLanguageRoutes()
+	}
+	route, ok := routes[lang]
+	if !ok {
+		if route, ok = routes["*"]; !ok {
+			return lang, func() {}
+		}
+	}
+	if route.Delta != "" && opts.Delta == "" {
+		if _, loaded := y.deltas[route.Delta]; loaded {
+			opts.Delta = route.Delta
+		}
+	}
+	saved := y.DeltaAlpha
+	y.DeltaAlpha = route.Alpha
+	return lang, func() { y.DeltaAlpha = saved }
+}
+
+// ParseLanguageRoutes parses "en=0,ru=ru:0.5,fr=fr:0.9,*=0.5": per
+// language an alpha, optionally after a delta name and a colon
+func ParseLanguageRoutes(spec string) (map[string]LanguageRoute, error) {
+	routes := make(map[string]LanguageRoute)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lang, target, ok := strings.Cut(part, "=")
+		if !ok || strings.TrimSpace(lang) == "" {
+			return nil, fmt.Errorf("language route %q is not lang=alpha or lang=delta:alpha", part)
+		}
+		var route LanguageRoute
+		alpha := strings.TrimSpace(target)
+		if delta, a, ok := strings.Cut(alpha, ":"); ok {
+			route.Delta, alpha = strings.TrimSpace(delta), strings.TrimSpace(a)
+		}
+		v, err := strconv.ParseFloat(alpha, 32)
+		if err != nil || v < 0 || v > 1 {
+			return nil, fmt.Errorf("language route %q: alpha must be 0..1", part)
+		}
+		route.Alpha = float32(v)
+		routes[strings.TrimSpace(lang)] = route
+	}
+	return routes, nil
+}
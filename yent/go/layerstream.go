@@ -0,0 +1,99 @@
+package yent
+
+// layerstream.go — Running a model larger than the RAM it gets
+//
+// A 1.5B Q4_0 GGUF is about 1 GB of weights. On a 512 MB–1 GB board (an
+// old Raspberry Pi) reading it into memory is an OOM, and even mmapped the
+// kernel only reclaims the pages once everything else is starved.
+//
+// With LoadOptions.StreamLayers the file is mapped (LoadGGUFMapped) and
+// each layer's matrices are released with madvise(MADV_DONTNEED) as soon
+// as the layer has run; the LM head likewise after the logits. While layer
+// N computes, layer N+1 is requested with MADV_WILLNEED so the read-ahead
+// overlaps the matmuls. The working set is the largest layer plus the LM
+// head, instead of the whole file.
+//
+// The price is reading the weights again for every token — from the page
+// cache when the kernel kept them, from the SD card when it did not — so
+// expect a fraction of the in-memory speed. Norms and biases are small
+// float32 copies and stay resident. Only Linux has madvise here; elsewhere
+// the model loads the usual way.
+
+import (
+	"os"
+	"unsafe"
+)
+
+// layerStream holds the mapped matrices released after use
+type layerStream struct {
+	layers [][][]byte // per layer: WQ, WK, WV, WO, WGate, WUp, WDown
+	head   []byte
+}
+
+// newLayerStream collects the model's matrices and releases every page
+// loading touched (GPU uploads, F32 embedding dequant)
+func newLayerStream(w *LlamaWeights) *layerStream {
+	st := &layerStream{layers: make([][][]byte, len(w.Layers)), head: w.Output}
+	for i := range w.Layers {
+		l := &w.Layers[i]
+		st.layers[i] = [][]byte{l.WQ, l.WK, l.WV, l.WO, l.WGate, l.WUp, l.WDown}
+		st.release(i)
+	}
+	evictPages(st.head)
+	evictPages(w.TokenEmbed)
+	return st
+}
+
+// enter asks for layer i+1 while layer i runs
+func (st *layerStream) enter(i int) {
+	if i+1 < len(st.layers) {
+		for _, b := range st.layers[i+1] {
+			prefetchPages(b)
+		}
+	}
+}
+
+// release drops layer i's pages
+func (st *layerStream) release(i int) {
+	for _, b := range st.layers[i] {
+		evictPages(b)
+	}
+}
+
+// rowPages returns the pages of table holding row i of n, widened to page
+// boundaries: a quantized row is smaller than a page, and evicting its
+// neighbours only costs them a re-read
+func rowPages(table []byte, i, n int) []byte {
+	rowBytes := len(table) / n
+	page := os.Getpagesize()
+	base := int(uintptr(unsafe.Pointer(unsafe.SliceData(table))) % uintptr(page))
+	lo := (base+i*rowBytes)/page*page - base
+	hi := ((base+(i+1)*rowBytes+page-1)/page)*page - base
+	return table[max(lo, 0):min(hi, len(table))]
+}
+
+// loadGGUFFor reads or maps weightsPath, as opts needs, and swaps in
+// opts.TokenizerJSON
+func loadGGUFFor(weightsPath string, opts LoadOptions) (*GGUFFile, error) {
+	load := LoadGGUF
+	if opts.StreamLayers {
+		load = LoadGGUFMapped
+	}
+	g, err := load(weightsPath)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TokenizerJSON != "" {
+		if err := ApplyTokenizerJSON(g, opts.TokenizerJSON); err != nil {
+			g.Close()
+			return nil, err
+		}
+	}
+	if len(opts.AddedTokens) > 0 {
+		if err := ApplyAddedTokens(g, opts.AddedTokens); err != nil {
+			g.Close()
+			return nil, err
+		}
+	}
+	return g, nil
+}
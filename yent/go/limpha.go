@@ -38,6 +38,39 @@ type LimphaState struct {
 	Debt        float32 `json:"debt"`
 	Velocity    int     `json:"velocity"`
 	Alpha       float32 `json:"alpha"`
+	Entropy     float32 `json:"entropy"`            // mean sampling entropy (nats/token)
+	Language    string  `json:"language,omitempty"` // detected prompt language
+
+	AMK *LimphaAMK `json:"amk,omitempty"` // full kernel field before and after the answer
+}
+
+// LimphaAMK is the kernel field around one stored answer
+type LimphaAMK struct {
+	Before AMState `json:"before"`
+	After  AMState `json:"after"`
+}
+
+// LimphaConversation is one stored turn as returned by the daemon.
+type LimphaConversation struct {
+	ID          int64   `json:"id"`
+	Timestamp   float64 `json:"timestamp"`
+	SessionID   string  `json:"session_id"`
+	Prompt      string  `json:"prompt"`
+	Response    string  `json:"response"`
+	Temperature float32 `json:"temperature"`
+	Destiny     float32 `json:"destiny"`
+	Pain        float32 `json:"pain"`
+	Tension     float32 `json:"tension"`
+	Debt        float32 `json:"debt"`
+	Velocity    int     `json:"velocity"`
+	Alpha       float32 `json:"alpha"`
+	Entropy     float32 `json:"entropy"`
+	Language    string  `json:"language"`
+	Quality     float32 `json:"quality"`
+	AccessCount int     `json:"access_count"`
+	RepeatCount int     `json:"repeat_count"` // times this exact turn was stored (dedup)
+
+	AMK *LimphaAMK `json:"amk,omitempty"` // kernel field around the answer (nil for older turns)
 }
 
 // NewLimphaClient creates a client and starts the LIMPHA daemon.
@@ -74,6 +107,11 @@ func NewLimphaClient() (*LimphaClient, error) {
 	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("start limpha daemon: %w", err)
 	}
+	if n := BackgroundNice(); n > 0 {
+		if err := reniceProcess(cmd.Process.Pid, n); err != nil {
+			fmt.Fprintf(os.Stderr, "[limpha] renice %d: %v\n", n, err)
+		}
+	}
 
 	client := &LimphaClient{
 		socketPath: socketPath,
@@ -88,25 +126,42 @@ func NewLimphaClient() (*LimphaClient, error) {
 		time.Sleep(50 * time.Millisecond)
 	}
 
-	// Connect
-	conn, err := net.Dial("unix", socketPath)
-	if err != nil {
+	if err := client.connect(); err != nil {
 		cmd.Process.Kill()
-		return nil, fmt.Errorf("connect to limpha: %w", err)
+		return nil, err
 	}
+	return client, nil
+}
 
-	client.conn = conn
-	client.reader = bufio.NewReader(conn)
-	client.connected = true
+// DialLimpha connects to a LIMPHA daemon started elsewhere (another
+// process, a service manager). Close disconnects without shutting it down.
+func DialLimpha(socketPath string) (*LimphaClient, error) {
+	client := &LimphaClient{socketPath: socketPath}
+	if err := client.connect(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
 
-	// Verify with ping
-	resp, err := client.send(map[string]interface{}{"cmd": "ping"})
-	if err != nil || !resp["ok"].(bool) {
-		client.Close()
-		return nil, fmt.Errorf("limpha ping failed")
+// connect dials the socket and pings the daemon
+func (c *LimphaClient) connect() error {
+	conn, err := net.Dial("unix", c.socketPath)
+	if err != nil {
+		return fmt.Errorf("connect to limpha: %w", err)
 	}
 
-	return client, nil
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+	c.connected = true
+
+	// Verify with ping
+	resp, err := c.send(map[string]interface{}{"cmd": "ping"})
+	if ok, _ := resp["ok"].(bool); err != nil || !ok {
+		c.conn.Close()
+		c.connected = false
+		return fmt.Errorf("limpha ping failed")
+	}
+	return nil
 }
 
 // findLimphaDir looks for the limpha/ directory.
@@ -138,16 +193,26 @@ func findLimphaDir() string {
 // Store sends a conversation to LIMPHA for storage.
 // Called automatically after each generation.
 func (c *LimphaClient) Store(prompt, response string, state LimphaState) error {
+	return c.StoreIn("", prompt, response, state)
+}
+
+// StoreIn stores a conversation in a memory namespace ("" = this daemon's session).
+// API sessions keep their memories apart this way.
+func (c *LimphaClient) StoreIn(namespace, prompt, response string, state LimphaState) error {
 	if !c.connected {
 		return nil // Silently skip if not connected
 	}
 
-	_, err := c.send(map[string]interface{}{
+	msg := map[string]interface{}{
 		"cmd":      "store",
 		"prompt":   prompt,
 		"response": response,
 		"state":    state,
-	})
+	}
+	if namespace != "" {
+		msg["session_id"] = namespace
+	}
+	_, err := c.send(msg)
 	return err
 }
 
@@ -180,6 +245,143 @@ func (c *LimphaClient) Search(query string, limit int) ([]map[string]interface{}
 	return out, nil
 }
 
+// Recent fetches the last limit conversations, oldest first, of a memory
+// namespace ("" = this daemon's session).
+func (c *LimphaClient) Recent(namespace string, limit int) ([]LimphaConversation, error) {
+	if !c.connected {
+		return nil, fmt.Errorf("limpha not connected")
+	}
+
+	msg := map[string]interface{}{"cmd": "recent", "limit": limit, "session_only": true}
+	if namespace != "" {
+		msg["session_id"] = namespace
+	}
+	resp, err := c.send(msg)
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := resp["ok"].(bool); !ok {
+		return nil, fmt.Errorf("limpha recent: %v", resp["error"])
+	}
+	return decodeConversations(resp["conversations"])
+}
+
+// Recall fetches one conversation by id (counts as an access).
+func (c *LimphaClient) Recall(id int64) (*LimphaConversation, error) {
+	if !c.connected {
+		return nil, fmt.Errorf("limpha not connected")
+	}
+
+	resp, err := c.send(map[string]interface{}{"cmd": "recall", "id": id})
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := resp["ok"].(bool); !ok {
+		return nil, fmt.Errorf("limpha recall %d: %v", id, resp["error"])
+	}
+
+	raw, err := json.Marshal(resp["conversation"])
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	var conv LimphaConversation
+	if err := json.Unmarshal(raw, &conv); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	return &conv, nil
+}
+
+// Get fetches conversations by id without bumping access counts.
+func (c *LimphaClient) Get(ids []int64) ([]LimphaConversation, error) {
+	if !c.connected {
+		return nil, fmt.Errorf("limpha not connected")
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	resp, err := c.send(map[string]interface{}{"cmd": "get", "ids": ids})
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := resp["ok"].(bool); !ok {
+		return nil, fmt.Errorf("limpha get: %v", resp["error"])
+	}
+	return decodeConversations(resp["conversations"])
+}
+
+// ExportRows fetches conversations for shard export, oldest first.
+// windowDays=0 exports the whole history; sinceID skips everything at or
+// below a previous export's high-water mark.
+func (c *LimphaClient) ExportRows(windowDays float64, minQuality float32, limit int, sinceID int64) ([]LimphaConversation, error) {
+	if !c.connected {
+		return nil, fmt.Errorf("limpha not connected")
+	}
+
+	resp, err := c.send(map[string]interface{}{
+		"cmd":         "export",
+		"window_days": windowDays,
+		"min_quality": minQuality,
+		"limit":       limit,
+		"since_id":    sinceID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := resp["ok"].(bool); !ok {
+		return nil, fmt.Errorf("limpha export: %v", resp["error"])
+	}
+
+	return decodeConversations(resp["conversations"])
+}
+
+// decodeConversations round-trips a decoded JSON list into typed records
+func decodeConversations(v interface{}) ([]LimphaConversation, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	var out []LimphaConversation
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	return out, nil
+}
+
+// Backup writes a consistent snapshot of the memory database to path
+// (which must not exist yet) and returns its size.
+func (c *LimphaClient) Backup(path string) (int64, error) {
+	if !c.connected {
+		return 0, fmt.Errorf("limpha not connected")
+	}
+	resp, err := c.send(map[string]interface{}{"cmd": "backup", "path": path})
+	if err != nil {
+		return 0, err
+	}
+	if ok, _ := resp["ok"].(bool); !ok {
+		return 0, fmt.Errorf("limpha backup: %v", resp["error"])
+	}
+	size, _ := resp["bytes"].(float64)
+	return int64(size), nil
+}
+
+// Vacuum compacts the memory database; returns its size before and after.
+func (c *LimphaClient) Vacuum() (before, after int64, err error) {
+	if !c.connected {
+		return 0, 0, fmt.Errorf("limpha not connected")
+	}
+	resp, err := c.send(map[string]interface{}{"cmd": "vacuum"})
+	if err != nil {
+		return 0, 0, err
+	}
+	if ok, _ := resp["ok"].(bool); !ok {
+		return 0, 0, fmt.Errorf("limpha vacuum: %v", resp["error"])
+	}
+	b, _ := resp["bytes_before"].(float64)
+	a, _ := resp["bytes_after"].(float64)
+	return int64(b), int64(a), nil
+}
+
 // Stats returns LIMPHA statistics.
 func (c *LimphaClient) Stats() (map[string]interface{}, error) {
 	if !c.connected {
@@ -194,9 +396,11 @@ func (c *LimphaClient) Close() {
 	defer c.mu.Unlock()
 
 	if c.connected && c.conn != nil {
-		// Try graceful shutdown
-		msg, _ := json.Marshal(map[string]interface{}{"cmd": "shutdown"})
-		c.conn.Write(append(msg, '\n'))
+		// Try graceful shutdown of a daemon we started
+		if c.process != nil {
+			msg, _ := json.Marshal(map[string]interface{}{"cmd": "shutdown"})
+			c.conn.Write(append(msg, '\n'))
+		}
 		c.conn.Close()
 		c.connected = false
 	}
@@ -0,0 +1,97 @@
+package yent
+
+// limphafield.go — Memory moves the nervous system
+//
+// The kernel shaped every answer and LIMPHA wrote down the state it left,
+// but nothing flowed back: a session of strained, scattered turns started
+// its next answer as calm as the first. With Yent.LimphaFeedback = N the
+// last N stored turns of the namespace (the session's, or the daemon's
+// own) are read before each generation and folded into a field:
+//
+//   warmth   mean turn quality           → DESTINY 0.2-0.6: good turns
+//                                           pull toward coherence
+//   tension  mean of max(pain, tension)  → TENSION at 80%: strain fades
+//                                           over turns, not at once
+//   entropy  mean sampling entropy       → DISSONANCE from 2 nats up,
+//                                           capped at 0.5 so it stays below
+//                                           the tunnel threshold and does
+//                                           not feed its own entropy
+//   debt     mean prophecy debt          → PROPHECY_DEBT at 80%
+//
+// Pain, tension and debt are the kernel's own, stored with each turn as
+// the answer left them (LimphaState): memory reads back what the kernel
+// wrote, not a guess from the text.
+//
+// Deterministic calls skip it: a replay must not depend on what memory
+// holds by then. CLI: -limpha-feedback 8; REPL: /amk memory.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LimphaField is what recent memory says about the field
+type LimphaField struct {
+	Turns   int     `json:"turns"`
+	Warmth  float32 `json:"warmth"`  // mean quality (0-1)
+	Tension float32 `json:"tension"` // mean of each turn's larger of pain and tension
+	Entropy float32 `json:"entropy"` // mean sampling entropy (nats/token)
+	Debt    float32 `json:"debt"`    // mean prophecy debt
+}
+
+// FieldFromConversations folds stored turns into a field
+func FieldFromConversations(convs []LimphaConversation) LimphaField {
+	f := LimphaField{Turns: len(convs)}
+	if f.Turns == 0 {
+		return f
+	}
+	for _, c := range convs {
+		f.Warmth += c.Quality
+		f.Tension += max(c.Pain, c.Tension)
+		f.Entropy += c.Entropy
+		f.Debt += c.Debt
+	}
+	n := float32(f.Turns)
+	f.Warmth, f.Tension, f.Entropy, f.Debt = f.Warmth/n, f.Tension/n, f.Entropy/n, f.Debt/n
+	return f
+}
+
+// DSL is the kernel update the field makes ("" without turns)
+func (f LimphaField) DSL() string {
+	if f.Turns == 0 {
+		return ""
+	}
+	clamp := func(v, lo, hi float32) float32 { return min(max(v, lo), hi) }
+	lines := []string{
+		fmt.Sprintf("DESTINY %.3f", 0.2+0.4*clamp(f.Warmth, 0, 1)),
+		fmt.Sprintf("TENSION %.3f", 0.8*clamp(f.Tension, 0, 1)),
+		fmt.Sprintf("DISSONANCE %.3f", clamp((f.Entropy-2)/4, 0, 0.5)),
+		fmt.Sprintf("PROPHECY_DEBT %.3f", 0.8*clamp(f.Debt, 0, 100)),
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LimphaField reads the field of the last n stored turns of a namespace
+// ("" = the daemon's session)
+func (y *Yent) LimphaField(namespace string, n int) (LimphaField, error) {
+	if y.limpha == nil {
+		return LimphaField{}, fmt.Errorf("limpha not running")
+	}
+	convs, err := y.limpha.Recent(namespace, n)
+	if err != nil {
+		return LimphaField{}, err
+	}
+	return FieldFromConversations(convs), nil
+}
+
+// feedLimphaField applies the namespace's memory field to the kernel
+func (y *Yent) feedLimphaField(namespace string) {
+	if y.LimphaFeedback <= 0 || y.limpha == nil {
+		return
+	}
+	f, err := y.LimphaField(namespace, y.LimphaFeedback)
+	if err != nil || f.Turns == 0 {
+		return // memory is best-effort; the field stays as it was
+	}
+	y.amk.Exec(f.DSL())
+}
@@ -0,0 +1,145 @@
+package yent
+
+// logprobs.go — per-token log probabilities for generation output
+//
+// How far does alpha=0.5 move the distribution? How hard does pain dampen it?
+// Logprobs are computed over the logits the sampler actually sees — after
+// Delta Voice, AMK suffering, script suppression, repetition penalty and logit
+// bias — but before temperature, so runs at different temperatures stay
+// comparable.
+
+import (
+	"math"
+	"sort"
+)
+
+// TokenAlt is one candidate token with its log probability
+type TokenAlt struct {
+	Token   int     `json:"token"`
+	Text    string  `json:"text"`
+	Logprob float32 `json:"logprob"`
+}
+
+// TokenLogprob is one generated token with its log probability
+// and (optionally) the most likely alternatives at that step
+type TokenLogprob struct {
+	Token   int        `json:"token"`
+	Text    string     `json:"text"`
+	Logprob float32    `json:"logprob"`
+	Top     []TokenAlt `json:"top,omitempty"`
+}
+
+// GenerateResult is generated text with per-token detail
+type GenerateResult struct {
+	Text   string         `json:"text"`
+	Tokens []TokenLogprob `json:"tokens,omitempty"`
+	Memory *MemoryContext `json:"memory,omitempty"` // memories injected into the prompt
+
+	// Language is the prompt's detected language ("" = too short to tell)
+	Language string `json:"language,omitempty"`
+
+	// DeltaSkipped counts tokens the delta gate left English (deltagate.go)
+	DeltaSkipped int `json:"delta_skipped,omitempty"`
+
+	// Reused counts prompt tokens whose KV rows came from the Session's
+	// cache instead of being fed again (session.go)
+	Reused int `json:"reused,omitempty"`
+
+	// Tunneled counts tokens drawn past the likeliest candidates (tunnel.go)
+	Tunneled int `json:"tunneled,omitempty"`
+
+	// Degenerate counts tokens that continued a loop (degeneration.go)
+	Degenerate int `json:"degenerate,omitempty"`
+
+	// Wormholes lists the context jumps the answer went through (wormhole.go)
+	Wormholes []WormholeJump `json:"wormholes,omitempty"`
+
+	// AMKBefore and AMKAfter are the kernel field the answer started from
+	// and the one it left, also stored with the turn in LIMPHA
+	AMKBefore AMState `json:"amk_before"`
+	AMKAfter  AMState `json:"amk_after"`
+
+	// AMKTrace is the kernel state per token with GenerateOptions.AMKTrace
+	AMKTrace []AMKTracePoint `json:"amk_trace,omitempty"`
+}
+
+// tokenLogprob computes log-softmax for the chosen token and topN alternatives
+func (y *Yent) tokenLogprob(chosen, topN int) TokenLogprob {
+	logits := y.model.State.Logits[:y.model.Config.VocabSize]
+	logZ := logSumExp(logits)
+
+	tl := TokenLogprob{
+		Token:   chosen,
+		Text:    y.tokenizer.DecodeToken(chosen),
+		Logprob: logits[chosen] - logZ,
+	}
+	for _, id := range topNIndices(logits, topN) {
+		tl.Top = append(tl.Top, TokenAlt{
+			Token:   id,
+			Text:    y.tokenizer.DecodeToken(id),
+			Logprob: logits[id] - logZ,
+		})
+	}
+	return tl
+}
+
+// Perplexity runs tokens through the model from position 0 and returns
+// exp of the mean negative log-likelihood of each token after the first,
+// at most SeqLen tokens. It overwrites the KV cache.
+func (m *LlamaModel) Perplexity(tokens []int) float64 {
+	n := min(len(tokens), m.Config.SeqLen)
+	if n < 2 {
+		return math.NaN()
+	}
+	var nll float64
+	for pos := 0; pos < n-1; pos++ {
+		m.Forward(tokens[pos], pos)
+		logits := m.State.Logits
+		nll += float64(logSumExp(logits) - logits[tokens[pos+1]])
+	}
+	return math.Exp(nll / float64(n-1))
+}
+
+// logSumExp returns log(Σ exp(x)) computed stably
+func logSumExp(x []float32) float32 {
+	maxVal := x[0]
+	for _, v := range x[1:] {
+		if v > maxVal {
+			maxVal = v
+		}
+	}
+	var sum float64
+	for _, v := range x {
+		sum += math.Exp(float64(v - maxVal))
+	}
+	return maxVal + float32(math.Log(sum))
+}
+
+// topNIndices returns indices of the n largest values, largest first
+func topNIndices(x []float32, n int) []int {
+	if n <= 0 {
+		return nil
+	}
+	if n > len(x) {
+		n = len(x)
+	}
+	top := make([]int, 0, n)
+	for i, v := range x {
+		if len(top) < n {
+			top = append(top, i)
+			sort.Slice(top, func(a, b int) bool { return x[top[a]] > x[top[b]] })
+			continue
+		}
+		if v <= x[top[n-1]] {
+			continue
+		}
+		// Insert in order, drop the smallest
+		j := n - 1
+		for j > 0 && x[top[j-1]] < v {
+			top[j] = top[j-1]
+			j--
+		}
+		top[j] = i
+	}
+	return top
+}
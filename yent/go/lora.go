@@ -0,0 +1,238 @@
+package yent
+
+// lora.go — A PEFT LoRA adapter as a Delta Voice
+//
+// A LoRA adapter on lm_head is already a low-rank delta of the LM head:
+//
+//   W' = W + scaling · lora_B @ lora_A      lora_B [vocab × r]  lora_A [r × hidden]
+//
+// so Delta Voice can speak any HuggingFace fine-tune that adapted the head:
+// A = scaling · lora_B, B = lora_A, and alpha blends it as usual (1 = the
+// fine-tune as trained). An adapter on embed_tokens becomes the input-side
+// pair (deltaembed.go); PEFT stores it transposed, as lora_embedding_A
+// [r × vocab] and lora_embedding_B [hidden × r].
+//
+// -delta takes the adapter directory (adapter_model.safetensors and
+// adapter_config.json) or the .safetensors file itself, with the config
+// read from next to it. scaling is lora_alpha / r (lora_alpha / √r with
+// use_rslora). Adapted attention and MLP matrices have no place in a
+// delta: they are listed and ignored.
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// loraConfig is the part of adapter_config.json that sets the scaling
+type loraConfig struct {
+	R         int     `json:"r"`
+	LoraAlpha float64 `json:"lora_alpha"`
+	RSLoRA    bool    `json:"use_rslora"`
+	BaseModel string  `json:"base_model_name_or_path"`
+}
+
+// isLoRAPath reports whether path names a PEFT adapter (directory or
+// .safetensors file) rather than an NPZ or GGUF delta
+func isLoRAPath(path string) bool {
+	if strings.HasSuffix(path, ".safetensors") {
+		return true
+	}
+	st, err := os.Stat(path)
+	return err == nil && st.IsDir()
+}
+
+// loadDeltaLoRA converts a PEFT adapter's lm_head (and embed_tokens)
+// matrices into a delta
+func loadDeltaLoRA(path string) (*DeltaVoice, error) {
+	dir, file := path, filepath.Join(path, "adapter_model.safetensors")
+	if strings.HasSuffix(path, ".safetensors") {
+		dir, file = filepath.Dir(path), path
+	}
+	tensors, err := readSafetensors(file)
+	if err != nil {
+		return nil, fmt.Errorf("read adapter: %w", err)
+	}
+
+	var cfg loraConfig
+	scaling := 1.0
+	if raw, err := os.ReadFile(filepath.Join(dir, "adapter_config.json")); err != nil {
+		fmt.Fprintf(os.Stderr, "[delta-voice] %s: no adapter_config.json — LoRA scaling 1\n", dir)
+	} else if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("adapter_config.json: %w", err)
+	} else if cfg.R > 0 && cfg.LoraAlpha > 0 {
+		scaling = cfg.LoraAlpha / float64(cfg.R)
+		if cfg.RSLoRA {
+			scaling = cfg.LoraAlpha / math.Sqrt(float64(cfg.R))
+		}
+	}
+
+	var headA, headB, embA, embB *safeTensor
+	ignored := make(map[string]bool)
+	for name, t := range tensors {
+		switch {
+		case strings.HasSuffix(name, "lm_head.lora_A.weight"):
+			headA = t
+		case strings.HasSuffix(name, "lm_head.lora_B.weight"):
+			headB = t
+		case strings.HasSuffix(name, "embed_tokens.lora_embedding_A"):
+			embA = t
+		case strings.HasSuffix(name, "embed_tokens.lora_embedding_B"):
+			embB = t
+		case strings.Contains(name, "lora_"):
+			// base_model.model.model.layers.3.self_attn.q_proj.lora_A.weight → q_proj
+			parts := strings.Split(name, ".")
+			for i, p := range parts {
+				if strings.HasPrefix(p, "lora_") && i > 0 {
+					ignored[parts[i-1]] = true
+					break
+				}
+			}
+		}
+	}
+	if (headA == nil) != (headB == nil) || (embA == nil) != (embB == nil) {
+		return nil, fmt.Errorf("adapter has only half of a LoRA pair for lm_head or embed_tokens")
+	}
+	if headA == nil && embA == nil {
+		return nil, fmt.Errorf("adapter adapts neither lm_head nor embed_tokens — a delta can only use those")
+	}
+	if len(ignored) > 0 {
+		names := make([]string, 0, len(ignored))
+		for n := range ignored {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "[delta-voice] LoRA: %s adapted too — no place in a delta, ignored\n", strings.Join(names, ", "))
+	}
+
+	d := &DeltaVoice{Path: path}
+	d.Meta.BaseModel = cfg.BaseModel
+	if headA != nil {
+		// lora_A [r × hidden], lora_B [vocab × r]
+		if len(headA.shape) != 2 || len(headB.shape) != 2 || headB.shape[1] != headA.shape[0] {
+			return nil, fmt.Errorf("lm_head LoRA shapes %v and %v do not chain", headB.shape, headA.shape)
+		}
+		d.Rank, d.HiddenDim, d.VocabSize = headA.shape[0], headA.shape[1], headB.shape[0]
+		d.A = f16Bytes(scaled(headB.values, scaling))
+		d.B = f16Bytes(headA.values)
+	}
+	if embA != nil {
+		// lora_embedding_A [r × vocab], lora_embedding_B [hidden × r]
+		if len(embA.shape) != 2 || len(embB.shape) != 2 || embB.shape[1] != embA.shape[0] {
+			return nil, fmt.Errorf("embed_tokens LoRA shapes %v and %v do not chain", embB.shape, embA.shape)
+		}
+		r, vocab, hidden := embA.shape[0], embA.shape[1], embB.shape[0]
+		if headA == nil {
+			d.VocabSize, d.HiddenDim = vocab, hidden
+		}
+		if err := d.setEmbed(f16Bytes(scaled(transpose(embA.values, r, vocab), scaling)),
+			f16Bytes(transpose(embB.values, hidden, r)), [2]int{vocab, r}, [2]int{r, hidden}); err != nil {
+			return nil, err
+		}
+	}
+	d.Bx = make([]float32, d.Rank)
+	d.ABx = make([]float32, d.VocabSize)
+
+	fmt.Printf("[delta-voice] loaded LoRA adapter: vocab=%d, hidden=%d, rank=%d, embed rank=%d, scaling=%.3g\n",
+		d.VocabSize, d.HiddenDim, d.Rank, d.EmbRank, scaling)
+	return d, nil
+}
+
+// safeTensor is one tensor of a safetensors file, as float32
+type safeTensor struct {
+	shape  []int
+	values []float32
+}
+
+// readSafetensors reads every F32, F16 and BF16 tensor of a .safetensors
+// file: an 8-byte header length, a JSON header, then the raw data
+func readSafetensors(path string) (map[string]*safeTensor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 {
+		return nil, fmt.Errorf("%s: too short for safetensors", path)
+	}
+	n := binary.LittleEndian.Uint64(data)
+	if n > uint64(len(data)-8) {
+		return nil, fmt.Errorf("%s: header length %d past end of file", path, n)
+	}
+	var header map[string]json.RawMessage
+	if err := json.Unmarshal(data[8:8+n], &header); err != nil {
+		return nil, fmt.Errorf("%s: header: %w", path, err)
+	}
+	body := data[8+n:]
+
+	out := make(map[string]*safeTensor, len(header))
+	for name, raw := range header {
+		if name == "__metadata__" {
+			continue
+		}
+		var info struct {
+			DType   string   `json:"dtype"`
+			Shape   []int    `json:"shape"`
+			Offsets [2]int64 `json:"data_offsets"`
+		}
+		if err := json.Unmarshal(raw, &info); err != nil {
+			return nil, fmt.Errorf("%s: tensor %s: %w", path, name, err)
+		}
+		start, end := info.Offsets[0], info.Offsets[1]
+		if start < 0 || end < start || end > int64(len(body)) {
+			return nil, fmt.Errorf("%s: tensor %s out of bounds", path, name)
+		}
+		buf := body[start:end]
+		count := 1
+		for _, d := range info.Shape {
+			count *= d
+		}
+		t := &safeTensor{shape: info.Shape, values: make([]float32, count)}
+		size := map[string]int{"F32": 4, "F16": 2, "BF16": 2}[info.DType]
+		if size == 0 {
+			continue // integer tensors are no LoRA matrices
+		}
+		if len(buf) != count*size {
+			return nil, fmt.Errorf("%s: tensor %s is %d bytes, shape %v needs %d", path, name, len(buf), info.Shape, count*size)
+		}
+		for i := range t.values {
+			switch info.DType {
+			case "F32":
+				t.values[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+			case "F16":
+				t.values[i] = half2float(binary.LittleEndian.Uint16(buf[i*2:]))
+			case "BF16":
+				t.values[i] = math.Float32frombits(uint32(binary.LittleEndian.Uint16(buf[i*2:])) << 16)
+			}
+		}
+		out[name] = t
+	}
+	return out, nil
+}
+
+// scaled returns v times s
+func scaled(v []float32, s float64) []float32 {
+	if s == 1 {
+		return v
+	}
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(float64(x) * s)
+	}
+	return out
+}
+
+// transpose returns the [cols × rows] transpose of m [rows × cols]
+func transpose(m []float32, rows, cols int) []float32 {
+	out := make([]float32, len(m))
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			out[c*rows+r] = m[r*cols+c]
+		}
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package yent
+
+// maintenance.go — The jobs the server schedules (scheduler.go)
+//
+//   shard-export    03:00 daily   new LIMPHA experience → ~/.yent/shards/
+//                                 (incremental, nightly defaults)
+//   memory-backup   03:30 daily   consistent snapshot of limpha.db →
+//                                 ~/.yent/backups/, newest 7 kept
+//   memory-vacuum   04:00 Sunday  merge FTS segments, reclaim pages,
+//                                 truncate the WAL
+//
+// Every job needs the LIMPHA daemon; without it the run fails and says so
+// in the job status. Each finished run is published as dream.completed
+// (events.go). Jobs run at the SetBackgroundNice priority (cpusched.go).
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// backupKeep is how many memory backups memory-backup keeps
+const backupKeep = 7
+
+// DefaultMaintenanceJobs returns the maintenance jobs and their schedules
+func DefaultMaintenanceJobs() map[string]string {
+	return map[string]string{
+		"shard-export":  "0 3 * * *",
+		"memory-backup": "30 3 * * *",
+		"memory-vacuum": "0 4 * * 0",
+	}
+}
+
+// MaintenanceJob returns the function behind a maintenance job name
+func (y *Yent) MaintenanceJob(name string) (func(ctx context.Context) error, error) {
+	run, err := y.maintenanceJob(name)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) error {
+		start := time.Now()
+		err := runBackground(func() error { return run(ctx) })
+		if bus := y.Events(); bus.Wants(EventDreamCompleted) {
+			data := map[string]interface{}{"job": name, "duration_ms": time.Since(start).Milliseconds()}
+			if err != nil {
+				data["error"] = err.Error()
+			}
+			bus.Publish(Event{Kind: EventDreamCompleted, Data: data})
+		}
+		return err
+	}, nil
+}
+
+// maintenanceJob is MaintenanceJob without the event
+func (y *Yent) maintenanceJob(name string) (func(ctx context.Context) error, error) {
+	switch name {
+	case "shard-export":
+		return func(ctx context.Context) error {
+			_, err := y.ExportShardsIncremental(DefaultShardConfig())
+			return err
+		}, nil
+	case "memory-backup":
+		return func(ctx context.Context) error { return y.backupMemory() }, nil
+	case "memory-vacuum":
+		return func(ctx context.Context) error {
+			if y.limpha == nil {
+				return fmt.Errorf("limpha not available")
+			}
+			before, after, err := y.limpha.Vacuum()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("[limpha] vacuum: %d → %d KB\n", before/1024, after/1024)
+			return nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown maintenance job %q (have: shard-export, memory-backup, memory-vacuum)", name)
+}
+
+// backupMemory snapshots limpha.db into ~/.yent/backups and prunes old copies
+func (y *Yent) backupMemory() error {
+	if y.limpha == nil {
+		return fmt.Errorf("limpha not available")
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("home dir: %w", err)
+	}
+	dir := filepath.Join(homeDir, ".yent", "backups")
+	path := filepath.Join(dir, "limpha-"+time.Now().Format("20060102-150405")+".db")
+	size, err := y.limpha.Backup(path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[limpha] backup: %s (%d KB)\n", path, size/1024)
+
+	old, err := filepath.Glob(filepath.Join(dir, "limpha-*.db"))
+	if err != nil {
+		return fmt.Errorf("list backups: %w", err)
+	}
+	sort.Strings(old) // timestamped names: oldest first
+	for len(old) > backupKeep {
+		if err := os.Remove(old[0]); err != nil {
+			return fmt.Errorf("prune backup: %w", err)
+		}
+		old = old[1:]
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package yent
+
+// memstats.go — What a loaded Yent holds in memory
+//
+// On a 1-2 GB VPS the question is not what the process uses now but what
+// the next step costs: loading a delta, raising -ctx, -f32-embed. The
+// parts scale differently, so MemoryStats reports them apart:
+//
+//   weights     tensor data (mapped with -stream-layers: resident as touched)
+//   embeddings  the float32 token table of -f32-embed (0 without)
+//   kv cache    layers × ctx × kv_dim × 2 (K and V) × 4 bytes
+//   delta       A and B of Delta Voice, float16: (vocab + dim) × rank × 2,
+//               and the embedding pair when the delta has one
+//   scratch     activation buffers, RoPE tables, sampler buffers
+//
+// KVPerPosition is the price of one more position of context, so
+// raising -ctx from 2048 to 4096 costs 2048 × KVPerPosition. Device
+// memory of -gpu is reported as GPU and is not part of Total.
+
+import "fmt"
+
+// MemoryStats is the memory held by a model and its delta, in bytes
+type MemoryStats struct {
+	Weights       int64 `json:"weights"`
+	Embeddings    int64 `json:"embeddings"`
+	KVCache       int64 `json:"kv_cache"`
+	KVPerPosition int64 `json:"kv_per_position"`
+	Delta         int64 `json:"delta"`
+	Scratch       int64 `json:"scratch"`
+	GPU           int64 `json:"gpu"`
+	Total         int64 `json:"total"`
+}
+
+// String formats the stats as one /status line
+func (s MemoryStats) String() string {
+	line := fmt.Sprintf("weights %s  kv %s (+%s per 1k ctx)  delta %s  scratch %s",
+		formatBytes(s.Weights), formatBytes(s.KVCache), formatBytes(s.KVPerPosition*1024),
+		formatBytes(s.Delta), formatBytes(s.Scratch))
+	if s.Embeddings > 0 {
+		line += "  f32-embed " + formatBytes(s.Embeddings)
+	}
+	if s.GPU > 0 {
+		line += "  gpu " + formatBytes(s.GPU)
+	}
+	return line + "  total " + formatBytes(s.Total)
+}
+
+// MemoryStats reports the memory held by the model and Delta Voice
+func (y *Yent) MemoryStats() MemoryStats {
+	var s MemoryStats
+	if y.model != nil {
+		s = y.model.MemoryStats()
+	}
+	for _, d := range y.deltas {
+		s.Delta += int64(len(d.A)+len(d.B)+len(d.EmbA)+len(d.EmbB)) + f32Bytes(d.Bx, d.ABx)
+	}
+	s.Total += s.Delta
+	return s
+}
+
+// MemoryStats reports the memory held by the model's weights and state
+func (m *LlamaModel) MemoryStats() MemoryStats {
+	w, st := &m.Weights, &m.State
+	s := MemoryStats{
+		Weights: int64(len(w.TokenEmbed)+len(w.Output)) +
+			f32Bytes(w.OutputNorm, w.OutputNormB, w.OutputBias),
+		Embeddings: f32Bytes(w.TokenEmbedF32),
+		KVCache:    f32Bytes(st.KeyCache, st.ValueCache),
+		Scratch: f32Bytes(st.X, st.XB, st.XB2, st.HB, st.HB2, st.Q, st.K, st.V,
+			st.Logits, st.CosCache, st.SinCache, st.EmbBuf) +
+			int64(len(st.RopeFreqs)*8) + st.scratch.bytes(),
+	}
+	for i := range w.Layers {
+		l := &w.Layers[i]
+		s.Weights += int64(len(l.WQ)+len(l.WK)+len(l.WV)+len(l.WO)+len(l.WGate)+len(l.WUp)+len(l.WDown)) +
+			f32Bytes(l.AttnNorm, l.AttnNormB, l.FFNNorm, l.PostAttnNorm, l.PostFFNNorm,
+				l.BQ, l.BK, l.BV, l.BO, l.BUp, l.BDown)
+	}
+	c := &m.Config
+	s.KVPerPosition = int64(c.NumLayers * c.NumKVHeads * c.HeadDim * 2 * 4)
+	if m.gpu != nil {
+		s.GPU = m.gpu.bytes
+	}
+	s.Total = s.Weights + s.Embeddings + s.KVCache + s.Scratch
+	return s
+}
+
+// f32Bytes sums the sizes of float32 slices
+func f32Bytes(bufs ...[]float32) int64 {
+	var n int64
+	for _, b := range bufs {
+		n += int64(len(b)) * 4
+	}
+	return n
+}
+
+// formatBytes renders n as B, KB, MB or GB (binary units)
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,99 @@
+package yent
+
+// mirostat.go — Mirostat v2: steer surprise, not temperature
+//
+// Temperature fixes how flat the distribution is; the surprise of what comes
+// out still swings with the prompt — dull on easy text, incoherent on hard
+// text. Mirostat v2 (Basu et al., 2021) holds the observed surprise near a
+// target tau (bits per token) with a feedback loop:
+//
+//   keep tokens with -log2 p ≤ mu, sample among them
+//   mu ← mu - eta · (surprise - tau)          mu starts at 2·tau
+//
+// This pairs with the AMK: the kernel's temperature scales the target
+// (tau · T) instead of the logits, so RUN asks for more surprise and NOMOVE
+// for less, while Mirostat decides how many candidates that takes. Top-k and
+// top-p sit out when Mirostat is on.
+
+import (
+	"math"
+)
+
+// mirostat is the feedback state for one generation
+type mirostat struct {
+	tau float32 // target surprise (bits)
+	eta float32 // learning rate
+	mu  float32 // current truncation threshold (bits)
+}
+
+// newMirostat starts the loop at mu = 2·tau (eta 0 = 0.1)
+func newMirostat(tau, eta float32) *mirostat {
+	if eta <= 0 {
+		eta = 0.1
+	}
+	return &mirostat{tau: tau, eta: eta, mu: 2 * tau}
+}
+
+// sampleMirostat draws a token with target surprise tau·scale and updates mu.
+// Returns the token and the entropy (nats) of the full distribution.
+func (y *Yent) sampleMirostat(m *mirostat, scale float32) (int, float32) {
+	logits := y.model.State.Logits
+	vocab := y.model.Config.VocabSize
+	if scale <= 0 {
+		scale = 1
+	}
+	tau := m.tau * scale
+
+	maxVal := logits[0]
+	for i := 1; i < vocab; i++ {
+		if logits[i] > maxVal {
+			maxVal = logits[i]
+		}
+	}
+	candidates := y.model.State.scratch.scoreBuf(vocab)
+	var sum float32
+	for i := 0; i < vocab; i++ {
+		p := float32(math.Exp(float64(logits[i] - maxVal)))
+		candidates[i] = tokenScore{i, p}
+		sum += p
+	}
+	invSum := float32(1.0) / sum
+	var entropy float32
+	for i := range candidates {
+		candidates[i].val *= invSum
+		if p := candidates[i].val; p > 0 {
+			entropy -= p * float32(math.Log(float64(p)))
+		}
+	}
+	sortScoresDesc(candidates)
+
+	// Truncate: surprise above mu is out (the top token always stays)
+	keep := 1
+	for keep < len(candidates) {
+		p := candidates[keep].val
+		if p <= 0 || -float32(math.Log2(float64(p))) > m.mu {
+			break
+		}
+		keep++
+	}
+	var kept float32
+	for _, c := range candidates[:keep] {
+		kept += c.val
+	}
+
+	r := y.rng.Float32() * kept
+	chosen := candidates[0]
+	var cdf float32
+	for _, c := range candidates[:keep] {
+		cdf += c.val
+		if r <= cdf {
+			chosen = c
+			break
+		}
+	}
+
+	// Feedback on the surprise of the truncated, renormalized draw
+	surprise := -float32(math.Log2(float64(chosen.val / kept)))
+	m.mu -= m.eta * (surprise - tau)
+	return chosen.idx, entropy
+}
@@ -0,0 +1,76 @@
+package yent
+
+import (
+	"math"
+	"testing"
+)
+
+// TestMirostatMu checks that mu starts at 2·tau and that the feedback holds
+// the mean surprise of the draws at tau
+func TestMirostatMu(t *testing.T) {
+	m := newMirostat(1.5, 0)
+	if m.mu != 3 || m.eta != 0.1 {
+		t.Fatalf("start: mu %f, eta %f; want 3, 0.1", m.mu, m.eta)
+	}
+
+	// p ∝ 2^-i: token i costs about i+1 bits
+	logits := make([]float32, 16)
+	for i := range logits {
+		logits[i] = -float32(i) * math.Ln2
+	}
+	y := logitsYent(logits...)
+	const draws = 2000
+	var sum float32
+	for i := 0; i < draws; i++ {
+		before := m.mu
+		y.sampleMirostat(m, 1)
+		sum += m.tau + (before-m.mu)/m.eta // the draw's surprise
+		if m.mu <= 0 || m.mu > 10 {
+			t.Fatalf("draw %d: mu %f ran away", i, m.mu)
+		}
+	}
+	if mean := sum / draws; math.Abs(float64(mean-m.tau)) > 0.05 {
+		t.Errorf("mean surprise %f bits, want tau %f", mean, m.tau)
+	}
+}
+
+// TestMirostatKeepsTop checks that the likeliest token survives however
+// low mu falls
+func TestMirostatKeepsTop(t *testing.T) {
+	y := logitsYent(0, 2, 1.9, 1.8)
+	for _, mu := range []float32{0.5, 0, -4} {
+		m := newMirostat(0.1, 0)
+		m.mu = mu
+		if tok, _ := y.sampleMirostat(m, 1); tok != 1 {
+			t.Errorf("mu %.1f: drew %d, want the top token 1", mu, tok)
+		}
+	}
+}
+
+// TestDrawAllowedMirostat checks that a draw the grammar rejects leaves mu
+// as it was: only the redraw feeds back
+func TestDrawAllowedMirostat(t *testing.T) {
+	g, err := ParseGrammar(`root ::= "b"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := logitsYent(5, -5, 5)
+	y.tokenizer = &Tokenizer{VocabSize: 3, Vocab: []string{"a", "b", "c"}, EosID: -1}
+	y.imEndID = -1
+
+	// a or c is drawn at 1 bit and rejected; b alone costs 0 bits
+	m := newMirostat(5, 0)
+	next, _, ok := y.drawAllowed(newGrammarState(g), m, func() (int, float32) { return y.sampleMirostat(m, 1) })
+	if !ok || next != 1 {
+		t.Fatalf("drew %d (ok %v), want b", next, ok)
+	}
+	if want := 10 - m.eta*(0-5); math.Abs(float64(m.mu-want)) > 1e-4 {
+		t.Errorf("mu %f, want %f from the redraw alone", m.mu, want)
+	}
+
+	// Nothing allowed
+	y.model.State.Logits[1] = -1e30
+	if _, _, ok := y.drawAllowed(newGrammarState(g), nil, func() (int, float32) { return 0, 0 }); ok {
+		t.Error("grammar allows nothing, yet ok")
+	}
+}
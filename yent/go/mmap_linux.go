@@ -0,0 +1,65 @@
+//go:build linux
+
+package yent
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// mapFile maps path read-only; the pages load on first touch
+func mapFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open GGUF: %w", err)
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(st.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mmap %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// unmapFile releases a mapFile mapping
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
+
+// evictPages drops the whole pages inside b from memory; they are read
+// back from the file when touched again
+func evictPages(b []byte) {
+	if b := pageAligned(b); b != nil {
+		syscall.Madvise(b, syscall.MADV_DONTNEED)
+	}
+}
+
+// prefetchPages starts reading the pages inside b in the background
+func prefetchPages(b []byte) {
+	if b := pageAligned(b); b != nil {
+		syscall.Madvise(b, syscall.MADV_WILLNEED)
+	}
+}
+
+// pageAligned trims b to the whole pages it contains (nil if none):
+// madvise wants a page-aligned start, and a partial page at either end
+// may belong to a neighbouring tensor
+func pageAligned(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	page := os.Getpagesize()
+	addr := uintptr(unsafe.Pointer(&b[0]))
+	lo := int((uintptr(page) - addr%uintptr(page)) % uintptr(page))
+	hi := lo + (len(b)-lo)/page*page
+	if lo >= len(b) || hi <= lo {
+		return nil
+	}
+	return b[lo:hi]
+}
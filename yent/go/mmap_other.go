@@ -0,0 +1,19 @@
+//go:build !linux
+
+package yent
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// mapFile is unsupported here: LoadGGUFMapped reads the file instead
+func mapFile(path string) ([]byte, error) {
+	return nil, fmt.Errorf("memory-mapped weights are not supported on %s", runtime.GOOS)
+}
+
+func unmapFile(data []byte) error { return nil }
+
+func evictPages(b []byte) {}
+
+func prefetchPages(b []byte) {}
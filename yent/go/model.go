@@ -10,11 +10,17 @@ package yent
 //   Bias on Q/K/V/O attention projections (unlike LLaMA)
 //   Vocab 151936 (byte-level BPE, 29 languages)
 //
+// Other architectures (Llama/SmolLM, Gemma, Phi) switch parts of the same
+// graph through LlamaConfig — see arch.go.
+//
 // This is not inference. This is breathing.
 
 import (
 	"fmt"
 	"math"
+	"os"
+	"runtime"
+	"time"
 )
 
 // LlamaModel is a loaded Llama model ready for inference
@@ -22,6 +28,16 @@ type LlamaModel struct {
 	Config  LlamaConfig
 	Weights LlamaWeights
 	State   LlamaState
+
+	gpu    *gpuOffload  // weights offloaded with LoadOptions.GPU (gpu.go)
+	clock  *layerClock  // per-layer timing during RunBench (bench.go)
+	stream *layerStream // weights released after use (layerstream.go)
+
+	prefetch *layerPrefetch // next-layer walker (prefetch.go)
+	head     *headClusters  // two-stage LM head (headprune.go)
+	int8     *int8Path      // int8 activations, -tags int8 (int8act.go)
+
+	embedDelta *embedDelta // Delta Voice on the embedding table (deltaembed.go)
 }
 
 // LlamaConfig holds model dimensions
@@ -36,20 +52,36 @@ type LlamaConfig struct {
 	IntermSize int     // MLP intermediate dimension
 	RMSNormEps float32
 	RopeTheta  float32
+	Rope       RopeScaling // resolved RoPE scaling (see rope.go)
+
+	// Architecture switches (arch.go); zero values are Qwen2
+	Arch            string
+	RopeDim         int     // rotated dims per head (0 = HeadDim)
+	RopeInterleaved bool    // rotate adjacent pairs instead of halves
+	LayerNorm       bool    // LayerNorm with bias instead of RMSNorm
+	ParallelBlock   bool    // attention and MLP share one norm and one residual add
+	GELU            bool    // GELU instead of SiLU in the MLP
+	EmbedScale      float32 // embedding multiplier (0 = 1)
+	AttnSoftcap     float32 // tanh cap on attention scores (0 = off)
+	FinalSoftcap    float32 // tanh cap on logits (0 = off)
 }
 
 // LlamaWeights holds all weight tensors (Q4_0 raw bytes or F32 slices)
 type LlamaWeights struct {
-	// Token embedding [vocab, dim] — always dequantized at lookup time
+	// Token embedding [vocab, dim] — dequantized at lookup time, unless
+	// LoadOptions.F32Embeddings filled TokenEmbedF32 at load
 	TokenEmbed    []byte
 	TokenEmbType  uint32
+	TokenEmbedF32 []float32
 
-	// Output norm [dim]
-	OutputNorm []float32
+	// Output norm [dim] — float32 from load whatever the GGUF type
+	OutputNorm  []float32
+	OutputNormB []float32 // LayerNorm bias — nil for RMSNorm
 
 	// Output (LM head) [vocab, dim]
 	Output     []byte
 	OutputType uint32
+	OutputBias []float32 // nil if none (phi2 has one)
 
 	// Per-layer weights
 	Layers []LlamaLayerWeights
@@ -58,8 +90,13 @@ type LlamaWeights struct {
 // LlamaLayerWeights holds weights for one transformer layer
 type LlamaLayerWeights struct {
 	// Attention norms
-	AttnNorm []float32 // [dim]
-	FFNNorm  []float32 // [dim]
+	AttnNorm  []float32 // [dim]
+	AttnNormB []float32 // LayerNorm bias — nil for RMSNorm
+	FFNNorm   []float32 // [dim] — nil in parallel blocks
+
+	// Norms after attention and MLP, before the residual add (gemma2; nil if none)
+	PostAttnNorm []float32
+	PostFFNNorm  []float32
 
 	// Attention projections [out_dim, in_dim]
 	WQ     []byte
@@ -78,12 +115,16 @@ type LlamaLayerWeights struct {
 	BO []float32 // [dim]
 
 	// MLP projections (gated MLP / SwiGLU)
-	WGate     []byte // gate_proj [interm, dim]
+	WGate     []byte // gate_proj [interm, dim] — nil for a plain MLP (phi2)
 	WGateType uint32
 	WUp       []byte // up_proj [interm, dim]
 	WUpType   uint32
 	WDown     []byte // down_proj [dim, interm]
 	WDownType uint32
+
+	// MLP biases (phi2; nil if none)
+	BUp   []float32
+	BDown []float32
 }
 
 // LlamaState holds runtime buffers and KV cache
@@ -96,7 +137,6 @@ type LlamaState struct {
 	Q      []float32 // query [n_heads * head_dim]
 	K      []float32 // key [n_kv_heads * head_dim]
 	V      []float32 // value [n_kv_heads * head_dim]
-	Att    []float32 // attention scores [n_heads * seq_len]
 	Logits []float32 // output logits [vocab]
 
 	// KV cache [layer * seq_len * kv_dim]
@@ -104,18 +144,27 @@ type LlamaState struct {
 	ValueCache []float32
 
 	// RoPE precomputed
-	CosCache []float32 // [seq_len * head_dim/2]
-	SinCache []float32
+	CosCache  []float32 // [seq_len * head_dim/2]
+	SinCache  []float32
+	RopeFreqs []float64 // per-pair angular frequency after scaling [head_dim/2]
 
 	// Reusable embedding buffer (avoids allocation per Forward call)
 	EmbBuf []float32
 
+	// Reusable sampler buffers (scratch.go)
+	scratch scratchArena
+
 	// Position tracking
 	Pos int
 }
 
 // LoadLlamaModel builds a LlamaModel from a parsed GGUF file
 func LoadLlamaModel(gguf *GGUFFile) (*LlamaModel, error) {
+	return LoadLlamaModelWithOptions(gguf, LoadOptions{})
+}
+
+// LoadLlamaModelWithOptions builds a LlamaModel with context/RoPE overrides
+func LoadLlamaModelWithOptions(gguf *GGUFFile, opts LoadOptions) (*LlamaModel, error) {
 	m := &GGUFMetadata{}
 	*m = gguf.Meta
 
@@ -135,19 +184,35 @@ func LoadLlamaModel(gguf *GGUFFile) (*LlamaModel, error) {
 	if cfg.HeadDim == 0 && cfg.NumHeads > 0 {
 		cfg.HeadDim = cfg.EmbedDim / cfg.NumHeads
 	}
+	if err := applyArch(&cfg, m); err != nil {
+		return nil, err
+	}
+
+	if opts.Rope.FreqBase > 0 {
+		cfg.RopeTheta = opts.Rope.FreqBase
+	}
 
 	// Cap sequence length to save memory (Qwen2.5 reports 32768 but we don't need it)
 	// KV cache at 32768: ~768MB. At 2048: ~48MB. Huge difference on 8GB Mac.
-	if cfg.SeqLen > 2048 {
+	// An explicit SeqLen (-ctx) bypasses the cap — the caller pays for the KV cache.
+	if opts.SeqLen > 0 {
+		cfg.SeqLen = opts.SeqLen
+	} else if cfg.SeqLen > 2048 {
 		fmt.Printf("[tongue/model] capping seq_len from %d to 2048\n", cfg.SeqLen)
 		cfg.SeqLen = 2048
 	}
+	cfg.Rope = resolveRopeScaling(opts.Rope, m, cfg.SeqLen)
 
 	// Load weights
 	w, err := loadWeights(gguf, &cfg)
 	if err != nil {
 		return nil, fmt.Errorf("load weights: %w", err)
 	}
+	if opts.F32Embeddings {
+		if err := dequantEmbeddings(w, &cfg); err != nil {
+			return nil, err
+		}
+	}
 
 	// Allocate state
 	state := allocState(&cfg)
@@ -159,9 +224,43 @@ func LoadLlamaModel(gguf *GGUFFile) (*LlamaModel, error) {
 		State:   state,
 	}
 
+	if opts.Int8 {
+		if p, err := newInt8Path(&cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "[tongue/model] %v — float activations\n", err)
+		} else {
+			model.int8 = p
+			fmt.Printf("[tongue/model] int8 activations (experimental)\n")
+		}
+	}
+	if opts.GPU != "" {
+		if model.int8 != nil {
+			fmt.Fprintf(os.Stderr, "[tongue/model] int8 activations run on the CPU kernels — ignoring GPU %q\n", opts.GPU)
+		} else {
+			model.offloadGPU(opts.GPU)
+		}
+	}
+	if opts.PruneHead {
+		model.head = buildHeadClusters(w.Output, w.OutputType, cfg.VocabSize, cfg.EmbedDim)
+	}
+	if opts.Prefetch {
+		model.prefetch = newLayerPrefetch(&model.Weights)
+		if runtime.NumCPU() <= numWorkers {
+			fmt.Fprintf(os.Stderr, "[tongue/model] prefetch: no spare core (%d threads, %d CPUs) — expect slower decode\n",
+				numWorkers, runtime.NumCPU())
+		}
+	}
+	if opts.StreamLayers {
+		if gguf.Mapped() {
+			model.stream = newLayerStream(&model.Weights)
+			fmt.Printf("[tongue/model] streaming layers from the mapped file\n")
+		} else {
+			fmt.Fprintf(os.Stderr, "[tongue/model] layer streaming needs a mapped GGUF (LoadGGUFMapped) — weights stay in memory\n")
+		}
+	}
+
 	hasBias := w.Layers[0].BQ != nil
-	fmt.Printf("[tongue/model] loaded: %d layers, %d dim, %d heads, %d kv_heads, %d vocab, bias=%v\n",
-		cfg.NumLayers, cfg.EmbedDim, cfg.NumHeads, cfg.NumKVHeads, cfg.VocabSize, hasBias)
+	fmt.Printf("[tongue/model] loaded %s: %d layers, %d dim, %d heads, %d kv_heads, %d vocab, bias=%v\n",
+		cfg.Arch, cfg.NumLayers, cfg.EmbedDim, cfg.NumHeads, cfg.NumKVHeads, cfg.VocabSize, hasBias)
 
 	return model, nil
 }
@@ -183,6 +282,8 @@ func loadWeights(gguf *GGUFFile, cfg *LlamaConfig) (*LlamaWeights, error) {
 	if err != nil {
 		return nil, fmt.Errorf("output_norm.weight: %w", err)
 	}
+	w.OutputNormB, _ = getF32TensorOptional(gguf, "output_norm.bias", cfg.EmbedDim)
+	w.OutputBias, _ = getF32TensorOptional(gguf, "output.bias", cfg.VocabSize)
 
 	// Output (LM head) — might be tied to embedding
 	outData, outInfo, err := gguf.GetTensor("output.weight")
@@ -208,50 +309,37 @@ func loadWeights(gguf *GGUFFile, cfg *LlamaConfig) (*LlamaWeights, error) {
 		if err != nil {
 			return nil, fmt.Errorf("layer %d attn_norm: %w", i, err)
 		}
+		l.AttnNormB, _ = getF32TensorOptional(gguf, prefix+"attn_norm.bias", cfg.EmbedDim)
 
-		// FFN norm
-		l.FFNNorm, err = getF32Tensor(gguf, prefix+"ffn_norm.weight", cfg.EmbedDim)
-		if err != nil {
-			return nil, fmt.Errorf("layer %d ffn_norm: %w", i, err)
+		// FFN norm (parallel blocks reuse the attention norm)
+		if !cfg.ParallelBlock {
+			l.FFNNorm, err = getF32Tensor(gguf, prefix+"ffn_norm.weight", cfg.EmbedDim)
+			if err != nil {
+				return nil, fmt.Errorf("layer %d ffn_norm: %w", i, err)
+			}
 		}
+		l.PostAttnNorm, _ = getF32TensorOptional(gguf, prefix+"post_attention_norm.weight", cfg.EmbedDim)
+		l.PostFFNNorm, _ = getF32TensorOptional(gguf, prefix+"post_ffw_norm.weight", cfg.EmbedDim)
 
-		// Attention projections
-		l.WQ, l.WQType, err = getRawTensor(gguf, prefix+"attn_q.weight")
-		if err != nil {
-			return nil, fmt.Errorf("layer %d attn_q: %w", i, err)
-		}
-		l.WK, l.WKType, err = getRawTensor(gguf, prefix+"attn_k.weight")
-		if err != nil {
-			return nil, fmt.Errorf("layer %d attn_k: %w", i, err)
-		}
-		l.WV, l.WVType, err = getRawTensor(gguf, prefix+"attn_v.weight")
-		if err != nil {
-			return nil, fmt.Errorf("layer %d attn_v: %w", i, err)
+		// Attention projections (and biases — Qwen2.5 and Phi have them, LLaMA does not)
+		if err := loadAttention(gguf, cfg, prefix, l); err != nil {
+			return nil, fmt.Errorf("layer %d: %w", i, err)
 		}
 		l.WO, l.WOType, err = getRawTensor(gguf, prefix+"attn_output.weight")
 		if err != nil {
 			return nil, fmt.Errorf("layer %d attn_output: %w", i, err)
 		}
-
-		// Attention biases (optional — Qwen2.5 has them, LLaMA does not)
-		l.BQ, _ = getF32TensorOptional(gguf, prefix+"attn_q.bias", cfg.NumHeads*cfg.HeadDim)
-		l.BK, _ = getF32TensorOptional(gguf, prefix+"attn_k.bias", cfg.NumKVHeads*cfg.HeadDim)
-		l.BV, _ = getF32TensorOptional(gguf, prefix+"attn_v.bias", cfg.NumKVHeads*cfg.HeadDim)
 		l.BO, _ = getF32TensorOptional(gguf, prefix+"attn_output.bias", cfg.EmbedDim)
 
-		// MLP projections (gated MLP / SwiGLU)
-		l.WGate, l.WGateType, err = getRawTensor(gguf, prefix+"ffn_gate.weight")
-		if err != nil {
-			return nil, fmt.Errorf("layer %d ffn_gate: %w", i, err)
-		}
-		l.WUp, l.WUpType, err = getRawTensor(gguf, prefix+"ffn_up.weight")
-		if err != nil {
-			return nil, fmt.Errorf("layer %d ffn_up: %w", i, err)
+		// MLP projections (gated, fused gate+up, or plain)
+		if err := loadMLP(gguf, cfg, prefix, l); err != nil {
+			return nil, fmt.Errorf("layer %d: %w", i, err)
 		}
 		l.WDown, l.WDownType, err = getRawTensor(gguf, prefix+"ffn_down.weight")
 		if err != nil {
 			return nil, fmt.Errorf("layer %d ffn_down: %w", i, err)
 		}
+		l.BDown, _ = getF32TensorOptional(gguf, prefix+"ffn_down.bias", cfg.EmbedDim)
 	}
 
 	return w, nil
@@ -289,6 +377,20 @@ func getF32Tensor(gguf *GGUFFile, name string, expectedSize int) ([]float32, err
 	}
 }
 
+// dequantEmbeddings expands the token embedding table to float32, so a
+// lookup is a copy. The LM head keeps the quantized bytes even when tied.
+func dequantEmbeddings(w *LlamaWeights, cfg *LlamaConfig) error {
+	n := cfg.VocabSize * cfg.EmbedDim
+	be := ggmlBlockElements(w.TokenEmbType)
+	if !isSupportedType(w.TokenEmbType) || n%be != 0 || len(w.TokenEmbed) < n/be*ggmlBlockSize(w.TokenEmbType) {
+		return fmt.Errorf("token_embd.weight: cannot dequantize type %d (%d bytes for %d values)",
+			w.TokenEmbType, len(w.TokenEmbed), n)
+	}
+	w.TokenEmbedF32 = dequantRows(w.TokenEmbed, w.TokenEmbType, n)
+	fmt.Printf("[tongue/model] token embeddings dequantized to F32 (%.0f MB)\n", float64(n*4)/(1<<20))
+	return nil
+}
+
 // getF32TensorOptional loads a tensor if it exists, returns nil if not found
 func getF32TensorOptional(gguf *GGUFFile, name string, expectedSize int) ([]float32, error) {
 	_, _, err := gguf.GetTensor(name)
@@ -310,40 +412,26 @@ func getRawTensor(gguf *GGUFFile, name string) ([]byte, uint32, error) {
 // allocState allocates all runtime buffers
 func allocState(cfg *LlamaConfig) LlamaState {
 	kvDim := cfg.NumKVHeads * cfg.HeadDim
+	half := cfg.ropeDim() / 2
 	return LlamaState{
 		X:          make([]float32, cfg.EmbedDim),
 		XB:         make([]float32, cfg.EmbedDim),
-		XB2:        make([]float32, cfg.EmbedDim),
+		XB2:        make([]float32, max(cfg.EmbedDim, cfg.NumHeads*cfg.HeadDim)),
 		HB:         make([]float32, cfg.IntermSize),
 		HB2:        make([]float32, cfg.IntermSize),
 		Q:          make([]float32, cfg.NumHeads*cfg.HeadDim),
 		K:          make([]float32, kvDim),
 		V:          make([]float32, kvDim),
-		Att:        make([]float32, cfg.NumHeads*cfg.SeqLen),
 		Logits:     make([]float32, cfg.VocabSize),
 		KeyCache:   make([]float32, cfg.NumLayers*cfg.SeqLen*kvDim),
 		ValueCache: make([]float32, cfg.NumLayers*cfg.SeqLen*kvDim),
-		CosCache:   make([]float32, cfg.SeqLen*(cfg.HeadDim/2)),
-		SinCache:   make([]float32, cfg.SeqLen*(cfg.HeadDim/2)),
+		CosCache:   make([]float32, cfg.SeqLen*half),
+		SinCache:   make([]float32, cfg.SeqLen*half),
+		RopeFreqs:  make([]float64, half),
 		EmbBuf:     make([]float32, cfg.EmbedDim),
 	}
 }
 
-// precomputeRoPE fills cos/sin caches for rotary position encoding
-func precomputeRoPE(s *LlamaState, cfg *LlamaConfig) {
-	half := cfg.HeadDim / 2
-	theta := float64(cfg.RopeTheta)
-
-	for pos := 0; pos < cfg.SeqLen; pos++ {
-		for i := 0; i < half; i++ {
-			freq := 1.0 / math.Pow(theta, float64(2*i)/float64(cfg.HeadDim))
-			angle := float64(pos) * freq
-			s.CosCache[pos*half+i] = float32(math.Cos(angle))
-			s.SinCache[pos*half+i] = float32(math.Sin(angle))
-		}
-	}
-}
-
 // isSupportedType checks if a GGML tensor type is supported for matmul
 func isSupportedType(t uint32) bool {
 	switch t {
@@ -426,20 +514,13 @@ func embedLookupDispatch(data []byte, dtype uint32, token, dim int) []float32 {
 }
 
 // applyRoPE applies rotary position encoding to a head vector
-// Uses half-split layout (vec[i], vec[i+half]) — Qwen2 does NOT permute Q/K weights
-// in convert_hf_to_gguf.py, so we use standard "normal" RoPE (llama.cpp mode 0).
-func applyRoPE(vec []float32, pos int, s *LlamaState, headDim int) {
-	half := headDim / 2
+// Qwen2 uses the half-split layout (vec[i], vec[i+half]) — convert_hf_to_gguf.py
+// does NOT permute its Q/K weights. Llama GGUFs are permuted and rotate
+// adjacent pairs (cfg.RopeInterleaved); Phi-2 rotates only the first RopeDim dims.
+func applyRoPE(vec []float32, pos int, s *LlamaState, cfg *LlamaConfig) {
+	half := cfg.ropeDim() / 2
 	cacheOff := pos * half
-
-	for i := 0; i < half; i++ {
-		x0 := vec[i]
-		x1 := vec[i+half]
-		c := s.CosCache[cacheOff+i]
-		si := s.SinCache[cacheOff+i]
-		vec[i] = x0*c - x1*si
-		vec[i+half] = x0*si + x1*c
-	}
+	rotatePairs(vec, s.CosCache[cacheOff:cacheOff+half], s.SinCache[cacheOff:cacheOff+half], cfg.RopeInterleaved)
 }
 
 // addBias adds bias vector to output (no-op if bias is nil)
@@ -454,32 +535,97 @@ func addBias(out []float32, bias []float32) {
 
 // Forward runs one token through the transformer
 func (m *LlamaModel) Forward(token int, pos int) {
+	m.ForwardHidden(token, pos)
+	m.logits()
+}
+
+// logits runs the LM head on State.X
+func (m *LlamaModel) logits() {
+	t := phaseStart()
+	if m.head != nil && m.gpu == nil {
+		m.prunedHead()
+	} else {
+		m.matmul(m.State.Logits, m.Weights.Output, m.Weights.OutputType, m.State.X, m.Config.VocabSize, m.Config.EmbedDim)
+	}
+	addBias(m.State.Logits, m.Weights.OutputBias)
+	softcap(m.State.Logits, m.Config.FinalSoftcap)
+	phaseEnd(phaseLMHead, t)
+	if st := m.stream; st != nil {
+		evictPages(st.head)
+		st.enter(-1) // the next token starts at layer 0
+	}
+	if m.prefetch != nil {
+		m.prefetch.request(0)
+	}
+}
+
+// ForwardHidden runs one token through the transformer and final norm,
+// leaving the hidden state in State.X and skipping the LM head
+func (m *LlamaModel) ForwardHidden(token int, pos int) {
 	cfg := &m.Config
 	w := &m.Weights
 	s := &m.State
 	dim := cfg.EmbedDim
 	kvDim := cfg.NumKVHeads * cfg.HeadDim
 	hd := cfg.HeadDim
+	qDim := cfg.NumHeads * hd
 	headGroupSize := cfg.NumHeads / cfg.NumKVHeads
 
 	// 1. Token embedding lookup (zero-alloc: reuses s.EmbBuf)
-	embedLookupInto(s.EmbBuf, w.TokenEmbed, w.TokenEmbType, token, dim)
+	t := phaseStart()
+	if w.TokenEmbedF32 != nil {
+		copy(s.EmbBuf, w.TokenEmbedF32[token*dim:(token+1)*dim])
+	} else {
+		embedLookupInto(s.EmbBuf, w.TokenEmbed, w.TokenEmbType, token, dim)
+		if m.stream != nil {
+			evictPages(rowPages(w.TokenEmbed, token, cfg.VocabSize))
+		}
+	}
 	copy(s.X, s.EmbBuf)
+	if e := m.embedDelta; e != nil {
+		e.d.ApplyToEmbedding(s.X, token, e.alpha)
+	}
+	if cfg.EmbedScale > 0 {
+		for i := range s.X {
+			s.X[i] *= cfg.EmbedScale
+		}
+	}
+	phaseEnd(phaseEmbed, t)
 
 	// Pre-compute attention scale (constant across all heads and layers)
 	attnScale := float32(1.0 / math.Sqrt(float64(hd)))
 
 	// 2. Transformer layers
+	clock := m.clock
+	if clock != nil {
+		clock.mark = time.Now()
+	}
 	for layer := 0; layer < cfg.NumLayers; layer++ {
+		if clock != nil && layer > 0 {
+			clock.lap(layer - 1)
+		}
+		if st := m.stream; st != nil {
+			if layer > 0 {
+				st.release(layer - 1)
+			}
+			st.enter(layer)
+		}
+		if m.prefetch != nil {
+			m.prefetch.request(layer + 1) // NumLayers = the LM head
+		}
 		l := &w.Layers[layer]
+		if m.int8 != nil {
+			m.int8.carry(s.X)
+		}
 
 		// Attention pre-norm
-		RMSNormInto(s.XB, s.X, l.AttnNorm, cfg.RMSNormEps)
+		t = phaseStart()
+		cfg.norm(s.XB, s.X, l.AttnNorm, l.AttnNormB)
 
 		// Q, K, V projections
-		matmulDispatch(s.Q, l.WQ, l.WQType, s.XB, cfg.NumHeads*hd, dim)
-		matmulDispatch(s.K, l.WK, l.WKType, s.XB, cfg.NumKVHeads*hd, dim)
-		matmulDispatch(s.V, l.WV, l.WVType, s.XB, cfg.NumKVHeads*hd, dim)
+		m.matmul(s.Q, l.WQ, l.WQType, s.XB, qDim, dim)
+		m.matmul(s.K, l.WK, l.WKType, s.XB, cfg.NumKVHeads*hd, dim)
+		m.matmul(s.V, l.WV, l.WVType, s.XB, cfg.NumKVHeads*hd, dim)
 
 		// Add bias (Qwen2.5 — no-op if nil)
 		addBias(s.Q, l.BQ)
@@ -488,10 +634,10 @@ func (m *LlamaModel) Forward(token int, pos int) {
 
 		// RoPE on Q and K
 		for h := 0; h < cfg.NumHeads; h++ {
-			applyRoPE(s.Q[h*hd:(h+1)*hd], pos, s, hd)
+			applyRoPE(s.Q[h*hd:(h+1)*hd], pos, s, cfg)
 		}
 		for h := 0; h < cfg.NumKVHeads; h++ {
-			applyRoPE(s.K[h*hd:(h+1)*hd], pos, s, hd)
+			applyRoPE(s.K[h*hd:(h+1)*hd], pos, s, cfg)
 		}
 
 		// Store K, V in cache
@@ -499,70 +645,147 @@ func (m *LlamaModel) Forward(token int, pos int) {
 		copy(s.KeyCache[cacheOff:cacheOff+kvDim], s.K[:kvDim])
 		copy(s.ValueCache[cacheOff:cacheOff+kvDim], s.V[:kvDim])
 
-		// Multi-head attention with GQA
+		// Multi-head attention with GQA, one fused pass per head (attention.go)
+		layerOff := layer * cfg.SeqLen * kvDim
 		for h := 0; h < cfg.NumHeads; h++ {
-			kvh := h / headGroupSize
-			qh := s.Q[h*hd : (h+1)*hd]
-			att := s.Att[h*cfg.SeqLen : h*cfg.SeqLen+pos+1]
-
-			// QK dot products
-			for t := 0; t <= pos; t++ {
-				kOff := layer*cfg.SeqLen*kvDim + t*kvDim + kvh*hd
-				var dot float32
-				for d := 0; d < hd; d++ {
-					dot += qh[d] * s.KeyCache[kOff+d]
-				}
-				att[t] = dot * attnScale
-			}
-
-			// Softmax
-			Softmax(att, pos+1)
+			kvOff := layerOff + (h/headGroupSize)*hd
+			Attend(s.XB2[h*hd:(h+1)*hd], s.Q[h*hd:(h+1)*hd],
+				s.KeyCache[kvOff:], s.ValueCache[kvOff:], pos+1, kvDim, attnScale, cfg.AttnSoftcap)
+		}
 
-			// Weighted sum of values → XB2
-			xbSlice := s.XB2[h*hd : (h+1)*hd]
-			for d := 0; d < hd; d++ {
-				xbSlice[d] = 0
-			}
-			for t := 0; t <= pos; t++ {
-				a := att[t]
-				vOff := layer*cfg.SeqLen*kvDim + t*kvDim + kvh*hd
-				for d := 0; d < hd; d++ {
-					xbSlice[d] += a * s.ValueCache[vOff+d]
-				}
+		// Parallel block (phi2): the MLP reads the same normed input, still in
+		// XB until the output projection overwrites it
+		if cfg.ParallelBlock {
+			phasePause(phaseAttention, t)
+			f := phaseStart()
+			m.mlp(l)
+			for i := 0; i < dim; i++ {
+				s.X[i] += s.XB[i]
 			}
+			phaseEnd(phaseFFN, f)
+			t = phaseStart()
 		}
 
 		// Output projection: XB = WO × XB2 + bias, then residual
-		matmulDispatch(s.XB, l.WO, l.WOType, s.XB2, dim, dim)
+		m.matmul(s.XB, l.WO, l.WOType, s.XB2, dim, qDim)
 		addBias(s.XB, l.BO)
+		if l.PostAttnNorm != nil {
+			RMSNorm(s.XB, l.PostAttnNorm, cfg.RMSNormEps)
+		}
 		for i := 0; i < dim; i++ {
 			s.X[i] += s.XB[i]
 		}
-
-		// MLP: pre-norm
-		RMSNormInto(s.XB, s.X, l.FFNNorm, cfg.RMSNormEps)
-
-		// Gated MLP: gate_proj and up_proj
-		matmulDispatch(s.HB, l.WGate, l.WGateType, s.XB, cfg.IntermSize, dim)
-		matmulDispatch(s.HB2, l.WUp, l.WUpType, s.XB, cfg.IntermSize, dim)
-
-		// SiLU(gate) * up
-		for i := 0; i < cfg.IntermSize; i++ {
-			s.HB[i] = SiLU(s.HB[i]) * s.HB2[i]
+		phaseEnd(phaseAttention, t)
+		if cfg.ParallelBlock {
+			continue
 		}
 
-		// down_proj + residual
-		matmulDispatch(s.XB, l.WDown, l.WDownType, s.HB, dim, cfg.IntermSize)
+		// MLP: pre-norm, gated MLP (act(gate) * up), down_proj + residual
+		t = phaseStart()
+		cfg.norm(s.XB, s.X, l.FFNNorm, nil)
+		m.mlp(l)
+		if l.PostFFNNorm != nil {
+			RMSNorm(s.XB, l.PostFFNNorm, cfg.RMSNormEps)
+		}
 		for i := 0; i < dim; i++ {
 			s.X[i] += s.XB[i]
 		}
+		phaseEnd(phaseFFN, t)
+	}
+	if clock != nil {
+		clock.lap(cfg.NumLayers - 1)
+	}
+	if m.stream != nil {
+		m.stream.release(cfg.NumLayers - 1)
+	}
+	if m.int8 != nil {
+		m.int8.carry(s.X)
 	}
 
 	// 3. Final norm
-	RMSNorm(s.X, w.OutputNorm, cfg.RMSNormEps)
+	cfg.norm(s.X, s.X, w.OutputNorm, w.OutputNormB)
+}
 
-	// 4. LM head → logits
-	matmulDispatch(s.Logits, w.Output, w.OutputType, s.X, cfg.VocabSize, dim)
+// ShiftContext discards nDiscard cached positions after the first nKeep
+// (attention sinks) and slides the rest down — StreamingLLM style.
+// Cached keys already carry RoPE for their old position, so they are
+// rotated back by nDiscard to stay consistent with their new slot.
+// Returns the new position (where the next token goes).
+func (m *LlamaModel) ShiftContext(pos, nKeep, nDiscard int) int {
+	cfg := &m.Config
+	s := &m.State
+	if nKeep < 0 {
+		nKeep = 0
+	}
+	if nDiscard <= 0 || nKeep+nDiscard > pos {
+		return pos
+	}
+
+	kvDim := cfg.NumKVHeads * cfg.HeadDim
+	hd := cfg.HeadDim
+	half := cfg.ropeDim() / 2
+	moved := pos - nKeep - nDiscard
+
+	// Rotation by -nDiscard positions per frequency pair
+	cosD := make([]float32, half)
+	sinD := make([]float32, half)
+	for i := 0; i < half; i++ {
+		angle := -float64(nDiscard) * s.RopeFreqs[i]
+		cosD[i] = float32(math.Cos(angle))
+		sinD[i] = float32(math.Sin(angle))
+	}
+
+	for layer := 0; layer < cfg.NumLayers; layer++ {
+		base := layer * cfg.SeqLen * kvDim
+		dst := base + nKeep*kvDim
+		src := base + (nKeep+nDiscard)*kvDim
+		n := moved * kvDim
+		copy(s.KeyCache[dst:dst+n], s.KeyCache[src:src+n])
+		copy(s.ValueCache[dst:dst+n], s.ValueCache[src:src+n])
+
+		for t := 0; t < moved; t++ {
+			for h := 0; h < cfg.NumKVHeads; h++ {
+				vec := s.KeyCache[dst+t*kvDim+h*hd : dst+t*kvDim+(h+1)*hd]
+				rotatePairs(vec, cosD, sinD, cfg.RopeInterleaved)
+			}
+		}
+	}
+
+	return pos - nDiscard
+}
+
+// KVRows is a copy of the KV cache for positions [From, To) in every layer
+type KVRows struct {
+	From, To int
+	K, V     []float32
+}
+
+// SaveKV copies the cache rows for positions [from, to)
+func (m *LlamaModel) SaveKV(from, to int) *KVRows {
+	cfg := &m.Config
+	kvDim := cfg.NumKVHeads * cfg.HeadDim
+	n := (to - from) * kvDim
+	r := &KVRows{From: from, To: to,
+		K: make([]float32, cfg.NumLayers*n),
+		V: make([]float32, cfg.NumLayers*n)}
+	for layer := 0; layer < cfg.NumLayers; layer++ {
+		src := layer*cfg.SeqLen*kvDim + from*kvDim
+		copy(r.K[layer*n:(layer+1)*n], m.State.KeyCache[src:src+n])
+		copy(r.V[layer*n:(layer+1)*n], m.State.ValueCache[src:src+n])
+	}
+	return r
+}
+
+// RestoreKV writes saved rows back to their positions
+func (m *LlamaModel) RestoreKV(r *KVRows) {
+	cfg := &m.Config
+	kvDim := cfg.NumKVHeads * cfg.HeadDim
+	n := (r.To - r.From) * kvDim
+	for layer := 0; layer < cfg.NumLayers; layer++ {
+		dst := layer*cfg.SeqLen*kvDim + r.From*kvDim
+		copy(m.State.KeyCache[dst:dst+n], r.K[layer*n:(layer+1)*n])
+		copy(m.State.ValueCache[dst:dst+n], r.V[layer*n:(layer+1)*n])
+	}
 }
 
 // Reset clears KV cache and position for new generation
@@ -0,0 +1,174 @@
+package yent
+
+// pool.go — Several models, one process
+//
+// English Yent and base Qwen side by side, or 0.5B for quick turns and 3B
+// for the hard ones. A second New() used to cost more than its weights:
+// it re-ran am_init (wiping the field every instance shares, since the
+// kernel state is one C global), started a second LIMPHA daemon over the
+// first one's socket, and rebuilt a tokenizer and CJK blacklist identical
+// to the one already in memory.
+//
+// A ModelPool loads every model after the first with LoadOptions.Share:
+//
+//   AMK field   one kernel; generations on pooled models are serialized so
+//               session fields can be parked and restored safely
+//   LIMPHA      one daemon, one memory — the first model owns it
+//   tokenizer   shared when the vocabulary (tokens, types, merges) matches
+//
+// Weights, KV caches, delta voices and embedders stay per model. Requests
+// pick a model by name; "" is the first one.
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// ModelPool holds named Yent instances that share one field and memory
+type ModelPool struct {
+	mu     sync.RWMutex
+	models map[string]*Yent
+	first  string
+}
+
+// NewModelPool creates an empty pool
+func NewModelPool() *ModelPool {
+	return &ModelPool{models: make(map[string]*Yent)}
+}
+
+// Add puts an already loaded instance in the pool. The first one added
+// becomes the default and the owner of the shared AMK and LIMPHA.
+func (p *ModelPool) Add(name string, y *Yent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if name == "" {
+		return fmt.Errorf("model name required")
+	}
+	if _, ok := p.models[name]; ok {
+		return fmt.Errorf("model %s already in pool", name)
+	}
+	if p.first == "" {
+		p.first = name
+	}
+	p.models[name] = y
+	return nil
+}
+
+// Load loads weights into the pool, sharing field, memory and (where the
+// vocabulary matches) tokenizer with the first model
+func (p *ModelPool) Load(name, weightsPath string, opts LoadOptions) (*Yent, error) {
+	p.mu.RLock()
+	_, exists := p.models[name]
+	owner := p.models[p.first]
+	p.mu.RUnlock()
+	if exists {
+		return nil, fmt.Errorf("model %s already in pool", name)
+	}
+
+	opts.Share = owner
+	y, err := NewWithOptions(weightsPath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %w", name, err)
+	}
+	if err := p.Add(name, y); err != nil {
+		y.Close()
+		return nil, err
+	}
+	return y, nil
+}
+
+// Get returns a model by name ("" = the first)
+func (p *ModelPool) Get(name string) (*Yent, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if name == "" {
+		name = p.first
+	}
+	y, ok := p.models[name]
+	if !ok {
+		return nil, fmt.Errorf("no model %q (have: %v)", name, p.namesLocked())
+	}
+	return y, nil
+}
+
+// Names returns the model names, sorted
+func (p *ModelPool) Names() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.namesLocked()
+}
+
+// Default returns the name of the default model
+func (p *ModelPool) Default() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.first
+}
+
+func (p *ModelPool) namesLocked() []string {
+	names := make([]string, 0, len(p.models))
+	for name := range p.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close closes every model, the owner of the shared memory last
+func (p *ModelPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, y := range p.models {
+		if name != p.first {
+			y.Close()
+		}
+	}
+	if y, ok := p.models[p.first]; ok {
+		y.Close()
+	}
+	p.models = make(map[string]*Yent)
+	p.first = ""
+}
+
+// tokenizerKey fingerprints everything NewTokenizer reads from the metadata
+func tokenizerKey(m *GGUFMetadata) string {
+	h := sha256.New()
+	var buf [8]byte
+	writeInt := func(n int) {
+		binary.LittleEndian.PutUint64(buf[:], uint64(n))
+		h.Write(buf[:])
+	}
+	writeStrings := func(list []string) {
+		writeInt(len(list))
+		for _, s := range list {
+			writeInt(len(s))
+			h.Write([]byte(s))
+		}
+	}
+	h.Write([]byte(m.TokenizerModel))
+	writeInt(m.VocabSize)
+	writeInt(m.BosID)
+	writeInt(m.EosID)
+	if m.AddSpacePrefix {
+		writeInt(1)
+	} else {
+		writeInt(0)
+	}
+	writeStrings(m.TokenList)
+	writeStrings(m.TokenMerges)
+	writeInt(len(m.TokenTypes))
+	for _, t := range m.TokenTypes {
+		writeInt(int(t))
+	}
+	writeInt(len(m.TokenScores))
+	for _, s := range m.TokenScores {
+		binary.LittleEndian.PutUint32(buf[:4], math.Float32bits(s))
+		h.Write(buf[:4])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
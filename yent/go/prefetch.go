@@ -0,0 +1,85 @@
+package yent
+
+// prefetch.go — Reading the next layer while this one computes (experimental)
+//
+// A decode step reads every weight once, and a 1.5B Q4_0 layer is ~30 MB,
+// far beyond any cache: each matmul starts by stalling on DRAM (or, with
+// -stream-layers, on the disk) until the hardware prefetcher catches on.
+//
+// With LoadOptions.Prefetch a goroutine of its own walks the next layer's
+// matrices, one load per cache line, while the current layer computes;
+// during the last layer it walks the LM head, during the LM head layer 0.
+// The loads pull pages in and warm the shared cache with the start of
+// each matrix, so the workers meet fewer cold misses. The walker never
+// waits: a request while it is busy is dropped.
+//
+// Whether it pays depends on the machine. It wants a spare core and a
+// memory bus that is not already saturated by the matmuls; on one or two
+// cores it costs more than it saves. `yent bench -prefetch` against
+// `yent bench` tells (bench.go).
+
+import "sync/atomic"
+
+// cacheLine is the stride of the prefetch walk
+const cacheLine = 64
+
+// layerPrefetch walks the matrices of requested layers on one goroutine
+type layerPrefetch struct {
+	parts [][][]byte // per layer, then the LM head at index len(layers)
+	next  chan int
+	sink  atomic.Uint32 // keeps the loads from being optimized away
+}
+
+// newLayerPrefetch starts the walker over w
+func newLayerPrefetch(w *LlamaWeights) *layerPrefetch {
+	p := &layerPrefetch{
+		parts: make([][][]byte, len(w.Layers)+1),
+		next:  make(chan int),
+	}
+	for i := range w.Layers {
+		l := &w.Layers[i]
+		p.parts[i] = [][]byte{l.WQ, l.WK, l.WV, l.WO, l.WGate, l.WUp, l.WDown}
+	}
+	p.parts[len(w.Layers)] = [][]byte{w.Output}
+	go p.run()
+	return p
+}
+
+// request asks for part i (a layer, or len(layers) for the LM head);
+// dropped when the walker is still busy
+func (p *layerPrefetch) request(i int) {
+	select {
+	case p.next <- i:
+	default:
+	}
+}
+
+// stop ends the walker
+func (p *layerPrefetch) stop() {
+	close(p.next)
+}
+
+func (p *layerPrefetch) run() {
+	for i := range p.next {
+		var sum uint8
+		for _, b := range p.parts[i] {
+			for off := 0; off < len(b); off += cacheLine {
+				sum += b[off]
+			}
+		}
+		p.sink.Add(uint32(sum))
+	}
+}
+
+// Close stops the model's background work and frees offloaded weights;
+// the model must not be used afterwards
+func (m *LlamaModel) Close() {
+	if m == nil {
+		return
+	}
+	m.ReleaseGPU()
+	if m.prefetch != nil {
+		m.prefetch.stop()
+		m.prefetch = nil
+	}
+}
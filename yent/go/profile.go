@@ -0,0 +1,132 @@
+package yent
+
+// profile.go — Where the time of a token goes
+//
+// With SetProfiling(true) the generation loop charges its wall time to six
+// phases, in process-wide counters:
+//
+//   embed      token embedding lookup
+//   attention  norm, QKV, RoPE, cache, attention, output projection
+//   ffn        norm, gate/up/down projections, residual
+//   lm_head    final projection to logits
+//   delta      Delta Voice on the logits
+//   sampling   drawing the next token, grammar redraws included
+//
+// PhaseTimings reads them, ResetPhaseTimings starts over; the server
+// exposes both at /v1/admin/profile. A regression then shows up as one
+// phase growing, without instrumenting a build by hand. Off, a phase costs
+// one atomic load; on, two clock reads. Counters are shared by every
+// model in the process, like the matmul thread pool.
+//
+// For CPU and heap profiles, `yent -serve -pprof` mounts net/http/pprof
+// under /debug/pprof/ (admin only).
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// phase indexes the profiling counters
+type phase int
+
+const (
+	phaseEmbed phase = iota
+	phaseAttention
+	phaseFFN
+	phaseLMHead
+	phaseDelta
+	phaseSampling
+	numPhases
+)
+
+var phaseNames = [numPhases]string{"embed", "attention", "ffn", "lm_head", "delta", "sampling"}
+
+var (
+	profiling    atomic.Bool
+	phaseNanos   [numPhases]atomic.Int64
+	phaseCalls   [numPhases]atomic.Int64
+	profileSince atomic.Int64 // unix nanos of the last reset
+)
+
+func init() {
+	profileSince.Store(time.Now().UnixNano())
+}
+
+// SetProfiling switches the phase counters on or off
+func SetProfiling(on bool) {
+	profiling.Store(on)
+}
+
+// Profiling reports whether the phase counters are on
+func Profiling() bool {
+	return profiling.Load()
+}
+
+// ProfileSince returns when the counters were last reset
+func ProfileSince() time.Time {
+	return time.Unix(0, profileSince.Load())
+}
+
+// PhaseTiming is the accumulated time of one phase
+type PhaseTiming struct {
+	Phase   string  `json:"phase"`
+	Calls   int64   `json:"calls"`
+	TotalMs float64 `json:"total_ms"`
+	MeanUs  float64 `json:"mean_us"`
+	Share   float64 `json:"share"` // fraction of the time in all phases
+}
+
+// PhaseTimings returns the counters since the last reset, in phase order
+func PhaseTimings() []PhaseTiming {
+	out := make([]PhaseTiming, numPhases)
+	var total int64
+	for p := range out {
+		ns, calls := phaseNanos[p].Load(), phaseCalls[p].Load()
+		total += ns
+		out[p] = PhaseTiming{Phase: phaseNames[p], Calls: calls, TotalMs: float64(ns) / 1e6}
+		if calls > 0 {
+			out[p].MeanUs = float64(ns) / 1e3 / float64(calls)
+		}
+	}
+	if total > 0 {
+		for p := range out {
+			out[p].Share = out[p].TotalMs * 1e6 / float64(total)
+		}
+	}
+	return out
+}
+
+// ResetPhaseTimings zeroes the counters
+func ResetPhaseTimings() {
+	for p := range phaseNanos {
+		phaseNanos[p].Store(0)
+		phaseCalls[p].Store(0)
+	}
+	profileSince.Store(time.Now().UnixNano())
+}
+
+// phaseStart returns the start of a timed span, zero when profiling is off
+func phaseStart() time.Time {
+	if !profiling.Load() {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// phaseEnd charges the span since start to p as one call
+func phaseEnd(p phase, start time.Time) {
+	if start.IsZero() {
+		return
+	}
+	phaseNanos[p].Add(int64(time.Since(start)))
+	phaseCalls[p].Add(1)
+}
+
+// phasePause charges the span since start to p without counting a call,
+// for a phase interrupted by another (the parallel block's MLP)
+func phasePause(p phase, start time.Time) {
+	if start.IsZero() {
+		return
+	}
+	phaseNanos[p].Add(int64(time.Since(start)))
+}
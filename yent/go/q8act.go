@@ -0,0 +1,120 @@
+package yent
+
+// q8act.go — Activations quantized to Q8 for the dot product
+//
+// A Q4_0 weight is a 4-bit integer; multiplying it into a float32 x means
+// converting it to float first, one conversion per weight per token. As
+// llama.cpp does, MatMulQ4_0 and MatMulQ8_0 instead quantize x once per
+// call, to int8 in blocks of 32 with one float scale per block (the Q8_0
+// layout), and the inner loop becomes an integer multiply-accumulate:
+//
+//   row · x  =  Σ_blocks  d_w · d_x · Σ_j  q_w[j] · q_x[j]
+//                                          └── int32, exact ──┘
+//
+// The vector kernels (simd_*.c) do 32 of those products per instruction
+// pair. The cost is x's rounding to 8 bits, well below the 4-bit weights'
+// own; SetQ8Activations(false) returns to float activations for an exact
+// reference.
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// useQ8Act quantizes activations for Q4_0/Q8_0 matmuls
+var useQ8Act = true
+
+// SetQ8Activations switches int8 activations for Q4_0/Q8_0 matmuls on or
+// off (default on). Not safe during a generation.
+func SetQ8Activations(on bool) {
+	useQ8Act = on
+}
+
+// q8Activations is x quantized to int8 blocks of 32 with a scale per block
+type q8Activations struct {
+	qs []int8
+	ds []float32
+}
+
+// q8ActPool recycles activation buffers between matmuls
+var q8ActPool = sync.Pool{New: func() interface{} { return new(q8Activations) }}
+
+// quantizeActivations quantizes x (a multiple of 32 long) into a pooled
+// buffer; put it back in q8ActPool when done
+func quantizeActivations(x []float32) *q8Activations {
+	qa := q8ActPool.Get().(*q8Activations)
+	blocks := len(x) / 32
+	if cap(qa.qs) < len(x) {
+		qa.qs = make([]int8, len(x))
+		qa.ds = make([]float32, blocks)
+	}
+	qa.qs, qa.ds = qa.qs[:len(x)], qa.ds[:blocks]
+	for b := 0; b < blocks; b++ {
+		xb := x[b*32 : b*32+32]
+		var amax float32
+		for _, v := range xb {
+			if a := float32(math.Abs(float64(v))); a > amax {
+				amax = a
+			}
+		}
+		d := amax / 127
+		qa.ds[b] = d
+		var inv float32
+		if d > 0 {
+			inv = 1 / d
+		}
+		qs := qa.qs[b*32 : b*32+32]
+		for j, v := range xb {
+			qs[j] = int8(math.Round(float64(v * inv)))
+		}
+	}
+	return qa
+}
+
+// matMulQ4_0Q8Range is matMulQ4_0Range against quantized activations
+func matMulQ4_0Q8Range(out []float32, w []byte, qa *q8Activations, start, end, blocksPerRow, bytesPerRow int) {
+	if useSIMD && simdMatMulQ4_0Q8(out, w, qa, start, end, blocksPerRow, bytesPerRow) {
+		return
+	}
+	for i := start; i < end; i++ {
+		rowOff := i * bytesPerRow
+		sum := float32(0)
+		for b := 0; b < blocksPerRow; b++ {
+			blockOff := rowOff + b*q4BytesPerBlock
+			d := half2float(binary.LittleEndian.Uint16(w[blockOff : blockOff+2]))
+			blockData := w[blockOff+2 : blockOff+q4BytesPerBlock]
+			xq := qa.qs[b*q4BlockSize:][:q4BlockSize]
+			var dot int32
+			for j := 0; j < 16; j++ {
+				bv := blockData[j]
+				dot += (int32(bv&0x0F)-8)*int32(xq[j]) + (int32(bv>>4)-8)*int32(xq[j+16])
+			}
+			sum += d * qa.ds[b] * float32(dot)
+		}
+		out[i] = sum
+	}
+}
+
+// matMulQ8_0Q8Range is matMulQ8_0Range against quantized activations
+func matMulQ8_0Q8Range(out []float32, w []byte, qa *q8Activations, start, end, blocksPerRow, bytesPerRow int) {
+	if useSIMD && simdMatMulQ8_0Q8(out, w, qa, start, end, blocksPerRow, bytesPerRow) {
+		return
+	}
+	for i := start; i < end; i++ {
+		rowOff := i * bytesPerRow
+		sum := float32(0)
+		for b := 0; b < blocksPerRow; b++ {
+			blockOff := rowOff + b*q8BytesPerBlock
+			d := half2float(binary.LittleEndian.Uint16(w[blockOff : blockOff+2]))
+			blockData := w[blockOff+2 : blockOff+q8BytesPerBlock]
+			xq := qa.qs[b*q8BlockSize:][:q8BlockSize]
+			var dot int32
+			for j, q := range blockData {
+				dot += int32(int8(q)) * int32(xq[j])
+			}
+			sum += d * qa.ds[b] * float32(dot)
+		}
+		out[i] = sum
+	}
+}
@@ -23,6 +23,19 @@ import (
 // Number of goroutines for parallel matmul
 var numWorkers = runtime.NumCPU()
 
+// SetThreads sets the matmul goroutine count (<= 0 = one per CPU)
+func SetThreads(n int) {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	numWorkers = n
+}
+
+// Threads returns the matmul goroutine count
+func Threads() int {
+	return numWorkers
+}
+
 const q4BlockSize = 32   // elements per Q4_0 block
 const q4BytesPerBlock = 18 // 2 (scale) + 16 (data)
 
@@ -55,39 +68,28 @@ func DequantQ4_0(data []byte, n int) []float32 {
 
 // MatMulQ4_0 computes out[rows] = W_q4[rows, cols] @ x[cols]
 // W is stored as Q4_0 blocks in row-major order
-// Parallelized across rows using goroutines (matches arianna.go pattern)
+// Parallelized across rows on the matmul workers (workers.go)
 func MatMulQ4_0(out []float32, w []byte, x []float32, rows, cols int) {
 	blocksPerRow := cols / q4BlockSize
 	bytesPerRow := blocksPerRow * q4BytesPerBlock
 
-	if rows < numWorkers*4 {
-		// Small matrix — single thread
-		matMulQ4_0Range(out, w, x, 0, rows, blocksPerRow, bytesPerRow)
+	if useQ8Act {
+		qa := quantizeActivations(x[:blocksPerRow*q4BlockSize])
+		parallelRows(rows, func(s, e int) {
+			matMulQ4_0Q8Range(out, w, qa, s, e, blocksPerRow, bytesPerRow)
+		})
+		q8ActPool.Put(qa)
 		return
 	}
-
-	var wg sync.WaitGroup
-	chunkSize := (rows + numWorkers - 1) / numWorkers
-
-	for worker := 0; worker < numWorkers; worker++ {
-		start := worker * chunkSize
-		end := start + chunkSize
-		if end > rows {
-			end = rows
-		}
-		if start >= end {
-			break
-		}
-		wg.Add(1)
-		go func(s, e int) {
-			matMulQ4_0Range(out, w, x, s, e, blocksPerRow, bytesPerRow)
-			wg.Done()
-		}(start, end)
-	}
-	wg.Wait()
+	parallelRows(rows, func(s, e int) {
+		matMulQ4_0Range(out, w, x, s, e, blocksPerRow, bytesPerRow)
+	})
 }
 
 func matMulQ4_0Range(out []float32, w []byte, x []float32, start, end, blocksPerRow, bytesPerRow int) {
+	if useSIMD && simdMatMulQ4_0(out, w, x, start, end, blocksPerRow, bytesPerRow) {
+		return
+	}
 	for i := start; i < end; i++ {
 		rowOff := i * bytesPerRow
 		sum := float32(0)
@@ -144,38 +146,28 @@ func DequantQ8_0(data []byte, n int) []float32 {
 }
 
 // MatMulQ8_0 computes out[rows] = W_q8[rows, cols] @ x[cols]
-// Parallelized across rows using goroutines
+// Parallelized across rows on the matmul workers (workers.go)
 func MatMulQ8_0(out []float32, w []byte, x []float32, rows, cols int) {
 	blocksPerRow := cols / q8BlockSize
 	bytesPerRow := blocksPerRow * q8BytesPerBlock
 
-	if rows < numWorkers*4 {
-		matMulQ8_0Range(out, w, x, 0, rows, blocksPerRow, bytesPerRow)
+	if useQ8Act {
+		qa := quantizeActivations(x[:blocksPerRow*q8BlockSize])
+		parallelRows(rows, func(s, e int) {
+			matMulQ8_0Q8Range(out, w, qa, s, e, blocksPerRow, bytesPerRow)
+		})
+		q8ActPool.Put(qa)
 		return
 	}
-
-	var wg sync.WaitGroup
-	chunkSize := (rows + numWorkers - 1) / numWorkers
-
-	for worker := 0; worker < numWorkers; worker++ {
-		start := worker * chunkSize
-		end := start + chunkSize
-		if end > rows {
-			end = rows
-		}
-		if start >= end {
-			break
-		}
-		wg.Add(1)
-		go func(s, e int) {
-			matMulQ8_0Range(out, w, x, s, e, blocksPerRow, bytesPerRow)
-			wg.Done()
-		}(start, end)
-	}
-	wg.Wait()
+	parallelRows(rows, func(s, e int) {
+		matMulQ8_0Range(out, w, x, s, e, blocksPerRow, bytesPerRow)
+	})
 }
 
 func matMulQ8_0Range(out []float32, w []byte, x []float32, start, end, blocksPerRow, bytesPerRow int) {
+	if useSIMD && simdMatMulQ8_0(out, w, x, start, end, blocksPerRow, bytesPerRow) {
+		return
+	}
 	for i := start; i < end; i++ {
 		rowOff := i * bytesPerRow
 		sum := float32(0)
@@ -264,38 +256,20 @@ func DequantQ6_K(data []byte, n int) []float32 {
 }
 
 // MatMulQ6_K computes out[rows] = W_q6k[rows, cols] @ x[cols]
-// Parallelized across rows using goroutines
+// Parallelized across rows on the matmul workers (workers.go)
 func MatMulQ6_K(out []float32, w []byte, x []float32, rows, cols int) {
 	blocksPerRow := cols / q6kBlockSize
 	bytesPerRow := blocksPerRow * q6kBytesPerBlock
 
-	if rows < numWorkers*4 {
-		matMulQ6_KRange(out, w, x, 0, rows, blocksPerRow, bytesPerRow)
-		return
-	}
-
-	var wg sync.WaitGroup
-	chunkSize := (rows + numWorkers - 1) / numWorkers
-
-	for worker := 0; worker < numWorkers; worker++ {
-		start := worker * chunkSize
-		end := start + chunkSize
-		if end > rows {
-			end = rows
-		}
-		if start >= end {
-			break
-		}
-		wg.Add(1)
-		go func(s, e int) {
-			matMulQ6_KRange(out, w, x, s, e, blocksPerRow, bytesPerRow)
-			wg.Done()
-		}(start, end)
-	}
-	wg.Wait()
+	parallelRows(rows, func(s, e int) {
+		matMulQ6_KRange(out, w, x, s, e, blocksPerRow, bytesPerRow)
+	})
 }
 
 func matMulQ6_KRange(out []float32, w []byte, x []float32, start, end, blocksPerRow, bytesPerRow int) {
+	if useSIMD && simdMatMulQ6_K(out, w, x, start, end, blocksPerRow, bytesPerRow) {
+		return
+	}
 	for r := start; r < end; r++ {
 		rowOff := r * bytesPerRow
 		sum := float32(0)
@@ -339,40 +313,44 @@ func matMulQ6_KRange(out []float32, w []byte, x []float32, start, end, blocksPer
 }
 
 // MatMulF32 computes out[rows] = W_f32[rows, cols] @ x[cols]
-// Parallelized across rows using goroutines
+// Parallelized across rows on the matmul workers (workers.go)
 func MatMulF32(out []float32, w []float32, x []float32, rows, cols int) {
-	if rows < numWorkers*4 {
-		matMulF32Range(out, w, x, 0, rows, cols)
-		return
-	}
+	parallelRows(rows, func(s, e int) {
+		matMulF32Range(out, w, x, s, e, cols)
+	})
+}
 
-	var wg sync.WaitGroup
-	chunkSize := (rows + numWorkers - 1) / numWorkers
+// matTile is the column tile of the F32/F16 matmuls: 2048 values of x
+// (8 KB) stay in L1 while four rows stream past them
+const matTile = 2048
 
-	for worker := 0; worker < numWorkers; worker++ {
-		start := worker * chunkSize
-		end := start + chunkSize
-		if end > rows {
-			end = rows
-		}
-		if start >= end {
-			break
+// matMulF32Range works four rows at a time, tile by tile: each x value is
+// loaded once for four multiply-adds, and the four sums are independent
+func matMulF32Range(out []float32, w []float32, x []float32, start, end, cols int) {
+	i := start
+	for ; i+4 <= end; i += 4 {
+		var s0, s1, s2, s3 float32
+		for t := 0; t < cols; t += matTile {
+			xt := x[t:min(t+matTile, cols)]
+			n := len(xt)
+			r0 := w[i*cols+t:][:n]
+			r1 := w[(i+1)*cols+t:][:n]
+			r2 := w[(i+2)*cols+t:][:n]
+			r3 := w[(i+3)*cols+t:][:n]
+			for j, xv := range xt {
+				s0 += r0[j] * xv
+				s1 += r1[j] * xv
+				s2 += r2[j] * xv
+				s3 += r3[j] * xv
+			}
 		}
-		wg.Add(1)
-		go func(s, e int) {
-			matMulF32Range(out, w, x, s, e, cols)
-			wg.Done()
-		}(start, end)
+		out[i], out[i+1], out[i+2], out[i+3] = s0, s1, s2, s3
 	}
-	wg.Wait()
-}
-
-func matMulF32Range(out []float32, w []float32, x []float32, start, end, cols int) {
-	for i := start; i < end; i++ {
+	for ; i < end; i++ {
 		sum := float32(0)
-		off := i * cols
-		for j := 0; j < cols; j++ {
-			sum += w[off+j] * x[j]
+		row := w[i*cols:][:cols]
+		for j, xv := range x[:cols] {
+			sum += row[j] * xv
 		}
 		out[i] = sum
 	}
@@ -380,36 +358,41 @@ func matMulF32Range(out []float32, w []float32, x []float32, start, end, cols in
 
 // MatMulF16 computes out[rows] = W_f16[rows, cols] @ x[cols]
 // w is raw bytes of float16 values
-// Parallelized across rows using goroutines
+// Parallelized across rows on the matmul workers (workers.go)
 func MatMulF16(out []float32, w []byte, x []float32, rows, cols int) {
-	if rows < numWorkers*4 {
-		matMulF16Range(out, w, x, 0, rows, cols)
+	parallelRows(rows, func(s, e int) {
+		matMulF16Range(out, w, x, s, e, cols)
+	})
+}
+
+// matMulF16Range tiles like matMulF32Range; halves convert through the
+// lookup table as they stream past. The vector kernels convert 8 (AVX2)
+// or 4 (NEON) at a time in hardware instead.
+func matMulF16Range(out []float32, w []byte, x []float32, start, end, cols int) {
+	if useSIMD && simdMatMulF16(out, w, x, start, end, cols) {
 		return
 	}
-
-	var wg sync.WaitGroup
-	chunkSize := (rows + numWorkers - 1) / numWorkers
-
-	for worker := 0; worker < numWorkers; worker++ {
-		start := worker * chunkSize
-		end := start + chunkSize
-		if end > rows {
-			end = rows
-		}
-		if start >= end {
-			break
+	i := start
+	for ; i+4 <= end; i += 4 {
+		var s0, s1, s2, s3 float32
+		for t := 0; t < cols; t += matTile {
+			xt := x[t:min(t+matTile, cols)]
+			n := 2 * len(xt)
+			r0 := w[(i*cols+t)*2:][:n]
+			r1 := w[((i+1)*cols+t)*2:][:n]
+			r2 := w[((i+2)*cols+t)*2:][:n]
+			r3 := w[((i+3)*cols+t)*2:][:n]
+			for j, xv := range xt {
+				k := 2 * j
+				s0 += half2floatLUT[uint16(r0[k])|uint16(r0[k+1])<<8] * xv
+				s1 += half2floatLUT[uint16(r1[k])|uint16(r1[k+1])<<8] * xv
+				s2 += half2floatLUT[uint16(r2[k])|uint16(r2[k+1])<<8] * xv
+				s3 += half2floatLUT[uint16(r3[k])|uint16(r3[k+1])<<8] * xv
+			}
 		}
-		wg.Add(1)
-		go func(s, e int) {
-			matMulF16Range(out, w, x, s, e, cols)
-			wg.Done()
-		}(start, end)
+		out[i], out[i+1], out[i+2], out[i+3] = s0, s1, s2, s3
 	}
-	wg.Wait()
-}
-
-func matMulF16Range(out []float32, w []byte, x []float32, start, end, cols int) {
-	for i := start; i < end; i++ {
+	for ; i < end; i++ {
 		sum := float32(0)
 		rowOff := i * cols * 2
 		for j := 0; j < cols; j++ {
@@ -420,6 +403,30 @@ func matMulF16Range(out []float32, w []byte, x []float32, start, end, cols int)
 	}
 }
 
+// dequantRows expands n values of a raw tensor to float32
+func dequantRows(data []byte, wtype uint32, n int) []float32 {
+	switch wtype {
+	case ggmlTypeQ4_0:
+		return DequantQ4_0(data, n)
+	case ggmlTypeQ8_0:
+		return DequantQ8_0(data, n)
+	case ggmlTypeQ6_K:
+		return DequantQ6_K(data, n)
+	case ggmlTypeF16:
+		out := make([]float32, n)
+		for i := range out {
+			out[i] = half2float(uint16(data[i*2]) | uint16(data[i*2+1])<<8)
+		}
+		return out
+	default: // F32
+		out := make([]float32, n)
+		for i := range out {
+			out[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+		}
+		return out
+	}
+}
+
 // EmbedLookupQ4_0 extracts one row from a Q4_0 embedding table
 func EmbedLookupQ4_0(data []byte, token, dim int) []float32 {
 	blocksPerRow := dim / q4BlockSize
@@ -0,0 +1,237 @@
+package yent
+
+// rag.go — Memory injection: retrieved conversations → prompt context
+//
+// Retrieve ranks. BuildMemoryContext fits the ranking into a token budget:
+//
+//   everything fits       → all verbatim
+//   otherwise, in rank order:
+//     short memories      → verbatim, if they fit what's left
+//     long conversations  → summarized to a fair share of what's left
+//     share < MinItem     → dropped
+//
+// DSL commands, field dumps, log lines and paths are redacted (redact.go);
+// instruction-like text is neutralized, flagged or dropped (injection.go).
+// Weak matches are not memories: below MinAnswerability (answerability.go)
+// the context is framed as "you don't remember this" instead.
+//
+// The context is framed by a memory template (template.go) and injected
+// ahead of the ### Question line; LIMPHA stores the user's original prompt,
+// never the augmented one.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Memory item compression modes
+const (
+	MemoryVerbatim = "verbatim"
+	MemorySummary  = "summary"
+)
+
+// RAGConfig controls memory-augmented generation
+type RAGConfig struct {
+	Retrieval      RetrievalConfig `json:"retrieval"`
+	TokenBudget    int             `json:"token_budget"`    // total tokens for injected memories
+	VerbatimTokens int             `json:"verbatim_tokens"` // memories up to this size stay verbatim
+	MinItemTokens  int             `json:"min_item_tokens"` // don't bother summarizing into less
+	Summarizer     Summarizer      `json:"-"`               // nil = extractive
+	Template       string          `json:"template"`        // memory framing ("" = remember)
+
+	// Answerability gate: below MinAnswerability the context is framed with
+	// UnknownTemplate ("" = unknown). MinAnswerability 0 disables the check.
+	Answerability    AnswerabilityScorer `json:"-"` // nil = heuristic
+	MinAnswerability float32             `json:"min_answerability"`
+	UnknownTemplate  string              `json:"unknown_template"`
+
+	// KeepInternals injects memories as stored, skipping RedactInternals
+	KeepInternals bool `json:"keep_internals"`
+
+	// Injection is the policy for instruction-like memories ("" = neutralize)
+	Injection string `json:"injection,omitempty"`
+
+	// Namespace restricts memories to one LIMPHA namespace (an API
+	// session, see StoreIn). "" = all memories.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// DefaultRAGConfig returns the injection defaults (sized for a 2048 context)
+func DefaultRAGConfig() RAGConfig {
+	return RAGConfig{
+		Retrieval:      DefaultRetrievalConfig(),
+		TokenBudget:    384,
+		VerbatimTokens: 96,
+		MinItemTokens:  24,
+
+		MinAnswerability: 0.3,
+	}
+}
+
+// MemoryItem is one memory as it will be injected
+type MemoryItem struct {
+	ID       int64   `json:"id"`
+	Score    float32 `json:"score"`
+	Prompt   string  `json:"prompt"`
+	Response string  `json:"response"` // verbatim or summarized
+	Mode     string  `json:"mode"`
+	Tokens   int     `json:"tokens"`
+
+	// Injection lists instruction-like patterns found (see RAGConfig.Injection)
+	Injection []string `json:"injection,omitempty"`
+}
+
+// MemoryContext is the memory block for one generation
+type MemoryContext struct {
+	Query    string       `json:"query"`
+	Items    []MemoryItem `json:"items"`
+	Tokens   int          `json:"tokens"`
+	Template string       `json:"template,omitempty"` // memory template name
+
+	Answerability float32 `json:"answerability"`
+	Unanswerable  bool    `json:"unanswerable,omitempty"` // framed as not remembered
+
+	Dropped int `json:"dropped,omitempty"` // memories left out by InjectionDrop
+}
+
+// BuildMemoryContext retrieves memories for query and fits them into the budget
+func (y *Yent) BuildMemoryContext(query string, cfg RAGConfig) (*MemoryContext, error) {
+	ranked, err := y.Retrieve(query, cfg.Retrieval)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Namespace != "" {
+		kept := ranked[:0]
+		for _, r := range ranked {
+			if r.SessionID == cfg.Namespace {
+				kept = append(kept, r)
+			}
+		}
+		ranked = kept
+	}
+	mc, err := y.fitMemories(query, ranked, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MinAnswerability > 0 {
+		scorer := cfg.Answerability
+		if scorer == nil {
+			scorer = HeuristicAnswerability{}
+		}
+		if mc.Answerability, err = scorer.Score(query, mc.Items); err != nil {
+			return nil, err
+		}
+		if mc.Answerability < cfg.MinAnswerability {
+			mc.Unanswerable = true
+			mc.Template = cfg.UnknownTemplate
+			if mc.Template == "" {
+				mc.Template = UnknownMemoryTemplate
+			}
+		}
+	}
+	return mc, nil
+}
+
+// fitMemories applies the verbatim/summary policy to a ranking
+func (y *Yent) fitMemories(query string, ranked []RankedMemory, cfg RAGConfig) (*MemoryContext, error) {
+	if cfg.TokenBudget <= 0 {
+		cfg.TokenBudget = 384
+	}
+	if cfg.MinItemTokens <= 0 {
+		cfg.MinItemTokens = 24
+	}
+	summarizer := cfg.Summarizer
+	if summarizer == nil {
+		summarizer = &ExtractiveSummarizer{Count: y.CountTokens}
+	}
+	switch cfg.Injection {
+	case "", InjectionNeutralize, InjectionFlag, InjectionDrop, InjectionOff:
+	default:
+		return nil, fmt.Errorf("unknown injection policy %q (neutralize, flag, drop, off)", cfg.Injection)
+	}
+
+	mc := &MemoryContext{Query: query, Template: cfg.Template}
+	screened := make([]RankedMemory, 0, len(ranked))
+	var flags [][]string
+	for _, r := range ranked {
+		if !cfg.KeepInternals {
+			r.Prompt = RedactInternals(r.Prompt)
+			r.Response = RedactInternals(r.Response)
+		}
+		names, keep := screenMemory(&r, cfg.Injection)
+		if !keep {
+			mc.Dropped++
+			continue
+		}
+		screened = append(screened, r)
+		flags = append(flags, names)
+	}
+	ranked = screened
+	sizes := make([]int, len(ranked))
+	total := 0
+	for i, r := range ranked {
+		sizes[i] = y.CountTokens(memoryText(r.LimphaConversation))
+		total += sizes[i]
+	}
+
+	// Everything fits: no compression
+	if total <= cfg.TokenBudget {
+		for i, r := range ranked {
+			mc.Items = append(mc.Items, MemoryItem{
+				ID: r.ID, Score: r.Score, Prompt: r.Prompt, Response: r.Response,
+				Mode: MemoryVerbatim, Tokens: sizes[i], Injection: flags[i],
+			})
+		}
+		mc.Tokens = total
+		return mc, nil
+	}
+
+	remaining := cfg.TokenBudget
+	longLeft := 0
+	for _, n := range sizes {
+		if n > cfg.VerbatimTokens {
+			longLeft++
+		}
+	}
+	for i, r := range ranked {
+		if sizes[i] <= cfg.VerbatimTokens {
+			if sizes[i] <= remaining {
+				mc.Items = append(mc.Items, MemoryItem{
+					ID: r.ID, Score: r.Score, Prompt: r.Prompt, Response: r.Response,
+					Mode: MemoryVerbatim, Tokens: sizes[i], Injection: flags[i],
+				})
+				remaining -= sizes[i]
+			}
+			continue
+		}
+
+		share := remaining / longLeft
+		longLeft--
+		prompt := r.Prompt
+		if y.CountTokens(prompt) > cfg.MinItemTokens {
+			prompt = truncateTokens(prompt, cfg.MinItemTokens, y.CountTokens)
+		}
+		respBudget := share - y.CountTokens(prompt) - 2
+		if respBudget < cfg.MinItemTokens {
+			continue
+		}
+		summary, err := summarizer.Summarize(r.Response, query, respBudget)
+		if err != nil {
+			return nil, fmt.Errorf("summarize memory %d: %w", r.ID, err)
+		}
+		item := MemoryItem{ID: r.ID, Score: r.Score, Prompt: prompt, Response: summary, Mode: MemorySummary, Injection: flags[i]}
+		item.Tokens = y.CountTokens(prompt + "\n" + summary)
+		mc.Items = append(mc.Items, item)
+		remaining -= item.Tokens
+	}
+
+	for _, it := range mc.Items {
+		mc.Tokens += it.Tokens
+	}
+	return mc, nil
+}
+
+// oneLine collapses whitespace so a memory stays on its line
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,74 @@
+package yent
+
+// redact.go — Keep the machinery out of the voice
+//
+// Memories are conversation text, but conversations carry whatever was
+// pasted into them: DSL lines ("PROPHECY 7"), field dumps ("pain=0.300
+// tension=0.120"), log lines ("[delta-voice] loaded ...") and file paths.
+// Injected verbatim, the model happily repeats them back. RedactInternals
+// swaps each for a placeholder before a memory reaches the prompt:
+//
+//   DSL command  → [command]      field dump → [field state]
+//   log line     → [log]          file path  → [path]
+
+import (
+	"regexp"
+	"strings"
+)
+
+// dslKeywords are the commands am_exec understands
+var dslKeywords = []string{
+	"PROPHECY", "DESTINY", "WORMHOLE", "CALENDAR_DRIFT", "ATTEND_FOCUS", "ATTEND_SPREAD",
+	"TUNNEL_THRESHOLD", "TUNNEL_CHANCE", "TUNNEL_SKIP_MAX", "PAIN", "TENSION", "DISSONANCE",
+	"PROPHECY_DEBT", "PROPHECY_DEBT_DECAY", "JUMP", "VELOCITY", "BASE_TEMP", "RESET_FIELD",
+	"RESET_DEBT", "LAW", "MODE", "IMPORT", "DISABLE", "CHORDLOCK", "TEMPOLOCK", "CHIRALITY",
+	"TEMPO", "PAS_THRESHOLD", "ANCHOR", "GRAVITY", "ANTIDOTE", "COSMIC_COHERENCE",
+	"TEMPORAL_MODE", "TEMPORAL_ALPHA", "RTL_MODE", "PROPHECY_MODE", "RETRODICTION_MODE",
+	"EXPERT_STRUCTURAL", "EXPERT_SEMANTIC", "EXPERT_CREATIVE", "EXPERT_PRECISE",
+	"PRESENCE_DECAY", "LORA_ALPHA",
+}
+
+// fieldKeys are AMK state names as they appear in dumps and logs
+var fieldKeys = []string{
+	"temp", "temperature", "effective_temp", "base_temp", "destiny", "pain", "tension",
+	"debt", "dissonance", "vel", "velocity", "magnitude", "time_dir", "prophecy",
+	"wormhole", "wormhole_active", "focus", "spread", "tunnel_thresh", "tunnel_chance",
+	"tunnel_skip", "alpha", "entropy",
+}
+
+var (
+	// A whole line that is one DSL command (optionally typed via /dsl)
+	dslLineRe = regexp.MustCompile(`(?m)^[ \t]*(?:/dsl[ \t]+)?(?:` + strings.Join(dslKeywords, "|") +
+		`)(?:[ \t]+[A-Za-z0-9_.+-]+)*[ \t]*$`)
+
+	// A log line: "[tag] ..." with a lowercase tag
+	logLineRe = regexp.MustCompile(`(?m)^[ \t]*\[[a-z][a-z0-9/_-]*\][ \t].*$`)
+
+	// Two or more field=value pairs in a row, or the /field banner
+	fieldDumpRe = regexp.MustCompile(`(?i)(?:\b(?:` + strings.Join(fieldKeys, "|") + `)=[-+]?[0-9.]+[,;]?[ \t]*){2,}` +
+		`|═+ *AMK FIELD STATE *═+`)
+
+	// Absolute or home-relative paths with at least two components
+	unixPathRe    = regexp.MustCompile(`(^|[\s"'(=])(?:~|/)[\w.-]*(?:/[\w.-]+)+/?`)
+	windowsPathRe = regexp.MustCompile(`\b[A-Za-z]:\\[\w.-]+(?:\\[\w.-]+)*`)
+
+	placeholderRunRe = regexp.MustCompile(`(\[(?:command|log|field state)\])(?:\s*\[(?:command|log|field state)\])+`)
+)
+
+// RedactInternals replaces DSL commands, field dumps, log lines and file
+// paths in text with placeholders
+func RedactInternals(text string) string {
+	text = logLineRe.ReplaceAllString(text, "[log]")
+	text = dslLineRe.ReplaceAllString(text, "[command]")
+	text = fieldDumpRe.ReplaceAllStringFunc(text, func(m string) string {
+		if strings.HasSuffix(m, " ") || strings.HasSuffix(m, "\t") {
+			return "[field state] "
+		}
+		return "[field state]"
+	})
+	text = unixPathRe.ReplaceAllString(text, "${1}[path]")
+	text = windowsPathRe.ReplaceAllString(text, "[path]")
+	// A pasted block of log or DSL lines becomes one placeholder, not twenty
+	text = placeholderRunRe.ReplaceAllString(text, "$1")
+	return text
+}
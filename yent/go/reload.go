@@ -0,0 +1,120 @@
+package yent
+
+// reload.go — Hot-swap of model weights
+//
+// live → shard → retrain → evolve needs the last arrow without a restart:
+// restarting the daemon drops every session transcript and field, and the
+// API is down while 1 GB of weights loads.
+//
+// Reload reads the new GGUF while generations carry on with the old one,
+// then takes the model lock and swaps weights, tokenizer (only when the
+// vocabulary changed) and per-model caches in one step. A generation in
+// flight finishes on the old weights; the next one starts on the new.
+//
+// What survives: AMK field, LIMPHA, sessions (transcripts and fields; their
+// KV rows belong to the old model and are rebuilt on the next turn), pool
+// membership. What doesn't: a delta voice whose dimensions no longer match,
+// and the model-embedder vector store, which moves to the new model's name
+// because hidden states of different weights live in different spaces.
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// ReloadInfo describes a completed swap
+type ReloadInfo struct {
+	Weights     string  `json:"weights"`
+	Previous    string  `json:"previous"`
+	Layers      int     `json:"layers"`
+	Dim         int     `json:"dim"`
+	Vocab       int     `json:"vocab"`
+	NewVocab    bool    `json:"new_vocab"`    // tokenizer rebuilt
+	DeltaKept   bool    `json:"delta_kept"`   // delta voice still applies
+	DurationSec float64 `json:"duration_sec"` // load time (generations kept running)
+}
+
+// WeightsPath returns the GGUF the instance is running
+func (y *Yent) WeightsPath() string {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	return y.weightsPath
+}
+
+// Reload loads weightsPath and swaps it in between generations. Loading
+// happens outside the model lock, so callers that don't want to wait can
+// run it in a goroutine; on error the running model is untouched.
+func (y *Yent) Reload(weightsPath string) (*ReloadInfo, error) {
+	y.reloadMu.Lock()
+	defer y.reloadMu.Unlock()
+
+	y.mu.Lock()
+	if y.model == nil {
+		y.mu.Unlock()
+		return nil, fmt.Errorf("yent not initialized")
+	}
+	opts, tokKey, suppression := y.loadOpts, y.tokKey, y.suppression
+	y.mu.Unlock()
+
+	start := time.Now()
+	fmt.Printf("[yent] reloading GGUF from %s\n", weightsPath)
+	gguf, err := loadGGUFFor(weightsPath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("load GGUF: %w", err)
+	}
+	model, err := LoadLlamaModelWithOptions(gguf, opts)
+	if err != nil {
+		gguf.Close()
+		return nil, fmt.Errorf("load model: %w", err)
+	}
+	newKey := tokenizerKey(&gguf.Meta)
+	var tokenizer *Tokenizer
+	var imEndID int
+	var suppressed map[int]bool
+	if newKey != tokKey {
+		tokenizer, imEndID, suppressed = loadTokenizer(&gguf.Meta, suppression)
+	}
+
+	y.mu.Lock()
+	info := &ReloadInfo{
+		Weights:  weightsPath,
+		Previous: y.weightsPath,
+		Layers:   model.Config.NumLayers,
+		Dim:      model.Config.EmbedDim,
+		Vocab:    model.Config.VocabSize,
+		NewVocab: tokenizer != nil,
+	}
+	old, oldGGUF := y.model, y.gguf
+	y.model, y.gguf, y.weightsPath = model, gguf, weightsPath
+	old.Close()
+	oldGGUF.Close()
+	if tokenizer != nil {
+		y.tokenizer, y.imEndID, y.suppressed, y.tokKey = tokenizer, imEndID, suppressed, newKey
+		y.stopIDs = addedStopIDs(tokenizer, opts.AddedTokens)
+		y.pieces, y.dryKey, y.dryMask, y.asciiMask = nil, "", nil, nil
+	}
+	for name, d := range y.deltas {
+		if err := y.checkDeltaBase(name, d, &gguf.Meta); err != nil {
+			fmt.Fprintf(os.Stderr, "[delta-voice] %v — unloaded\n", err)
+			delete(y.deltas, name)
+			if y.delta == d {
+				y.delta, y.deltaName = nil, ""
+			}
+		}
+	}
+	info.DeltaKept = y.delta != nil
+	embedder := y.embedder
+	y.mu.Unlock()
+
+	if _, ok := embedder.(*ModelEmbedder); ok {
+		if err := y.SetEmbedder(embedder, embedderStoreName(opts.Embedder, weightsPath)); err != nil {
+			fmt.Fprintf(os.Stderr, "[embed] warning: %v (semantic memory disabled)\n", err)
+		}
+	}
+
+	info.DurationSec = time.Since(start).Seconds()
+	fmt.Printf("[yent] reloaded: %d layers, %d dim, %d vocab (%.1fs)\n",
+		info.Layers, info.Dim, info.Vocab, info.DurationSec)
+	return info, nil
+}
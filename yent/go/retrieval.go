@@ -0,0 +1,272 @@
+package yent
+
+// retrieval.go — Hybrid memory ranking for RAG injection
+//
+// No single signal knows which memory matters. FTS5 knows words, vectors
+// know meaning, LIMPHA knows which memories were good and which keep coming
+// back, and time knows what is fresh. Retrieve pulls candidates from both
+// indexes and fuses four normalized factors into one score:
+//
+//   keyword   BM25 from FTS5, divided by the best BM25 among candidates
+//   vector    cosine similarity to the query embedding (clamped to [0, 1])
+//   strength  0.5·quality + 0.5·min(1, ln(1+access)/ln(11))
+//   recency   exp(-ln2 · age / half-life)
+//
+//   score = Σ wᵢ·factorᵢ / Σ wᵢ
+//
+// Explain mode keeps each factor's value and weighted contribution.
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RetrievalWeights are the fusion weights (any scale; normalized by their sum)
+type RetrievalWeights struct {
+	Keyword  float32 `json:"keyword"`
+	Vector   float32 `json:"vector"`
+	Strength float32 `json:"strength"`
+	Recency  float32 `json:"recency"`
+}
+
+// RetrievalConfig controls hybrid retrieval
+type RetrievalConfig struct {
+	K            int              `json:"k"`              // memories returned
+	Pool         int              `json:"pool"`           // candidates pulled from each index
+	Weights      RetrievalWeights `json:"weights"`        // factor weights
+	HalfLifeDays float64          `json:"half_life_days"` // recency half-life
+	MinScore     float32          `json:"min_score"`      // drop memories scoring below this
+	Explain      bool             `json:"explain"`        // keep per-factor breakdown
+}
+
+// DefaultRetrievalConfig returns the RAG defaults
+func DefaultRetrievalConfig() RetrievalConfig {
+	return RetrievalConfig{
+		K:            5,
+		Pool:         20,
+		Weights:      RetrievalWeights{Keyword: 0.35, Vector: 0.4, Strength: 0.1, Recency: 0.15},
+		HalfLifeDays: 30,
+		MinScore:     0.1,
+	}
+}
+
+// MemoryCandidate is one memory before fusion
+type MemoryCandidate struct {
+	Conv       LimphaConversation
+	BM25       float64 // FTS5 bm25() rank: negative, lower is better (0 = no keyword match)
+	Similarity float32 // cosine similarity to the query (0 = no vector)
+}
+
+// RankFactor is one factor's share of a memory's score
+type RankFactor struct {
+	Value        float32 `json:"value"`        // normalized to [0, 1]
+	Weight       float32 `json:"weight"`       // normalized weight
+	Contribution float32 `json:"contribution"` // value × weight
+}
+
+// RankExplain breaks a score down by factor
+type RankExplain struct {
+	Keyword  RankFactor `json:"keyword"`
+	Vector   RankFactor `json:"vector"`
+	Strength RankFactor `json:"strength"`
+	Recency  RankFactor `json:"recency"`
+}
+
+// RankedMemory is a retrieved memory with its fused score
+type RankedMemory struct {
+	LimphaConversation
+	Score   float32      `json:"score"`
+	Explain *RankExplain `json:"explain,omitempty"`
+}
+
+// String renders the explain breakdown on one line
+func (e *RankExplain) String() string {
+	f := func(name string, r RankFactor) string {
+		return fmt.Sprintf("%s %.2f×%.2f=%.3f", name, r.Value, r.Weight, r.Contribution)
+	}
+	return strings.Join([]string{
+		f("kw", e.Keyword), f("vec", e.Vector), f("str", e.Strength), f("rec", e.Recency),
+	}, "  ")
+}
+
+// Retrieve ranks LIMPHA memories for query by keyword, meaning, strength and recency.
+// Without an embedder the vector weight is dropped.
+func (y *Yent) Retrieve(query string, cfg RetrievalConfig) ([]RankedMemory, error) {
+	if y.limpha == nil {
+		return nil, fmt.Errorf("limpha not available")
+	}
+	if cfg.K <= 0 {
+		cfg.K = 5
+	}
+	if cfg.Pool < cfg.K {
+		cfg.Pool = cfg.K * 4
+	}
+
+	cands := make(map[int64]*MemoryCandidate)
+	var order []int64
+	add := func(c LimphaConversation) *MemoryCandidate {
+		if mc, ok := cands[c.ID]; ok {
+			return mc
+		}
+		mc := &MemoryCandidate{Conv: c}
+		cands[c.ID] = mc
+		order = append(order, c.ID)
+		return mc
+	}
+
+	// Keyword candidates
+	if fq := ftsQuery(query); fq != "" {
+		hits, err := y.limpha.Search(fq, cfg.Pool)
+		if err != nil {
+			return nil, fmt.Errorf("keyword search: %w", err)
+		}
+		for _, h := range hits {
+			var row struct {
+				LimphaConversation
+				Rank float64 `json:"rank"`
+			}
+			raw, _ := json.Marshal(h)
+			if json.Unmarshal(raw, &row) != nil {
+				continue
+			}
+			add(row.LimphaConversation).BM25 = row.Rank
+		}
+	}
+
+	// Vector candidates, and similarity for keyword-only ones
+	if y.embedder == nil {
+		cfg.Weights.Vector = 0
+	} else {
+		if _, err := y.IndexMemories(); err != nil {
+			return nil, err
+		}
+		qvec, err := y.embedder.Embed(query)
+		if err != nil {
+			return nil, fmt.Errorf("embed query: %w", err)
+		}
+		// Keyword candidates get their similarity straight from the store
+		for _, id := range order {
+			if v, ok := y.vectors.Vector(id); ok {
+				cands[id].Similarity = dotF32(qvec, v)
+			}
+		}
+		sims := make(map[int64]float32)
+		var missing []int64
+		for _, h := range y.vectors.Search(qvec, cfg.Pool) {
+			if _, ok := cands[h.ID]; !ok {
+				sims[h.ID] = h.Score
+				missing = append(missing, h.ID)
+			}
+		}
+		if len(missing) > 0 {
+			convs, err := y.limpha.Get(missing)
+			if err != nil {
+				return nil, fmt.Errorf("fetch memories: %w", err)
+			}
+			for _, c := range convs {
+				add(c).Similarity = sims[c.ID]
+			}
+		}
+	}
+
+	list := make([]MemoryCandidate, 0, len(order))
+	for _, id := range order {
+		list = append(list, *cands[id])
+	}
+	return FuseRanking(list, cfg, time.Now()), nil
+}
+
+// FuseRanking scores candidates with the weighted factor sum, best first
+func FuseRanking(cands []MemoryCandidate, cfg RetrievalConfig, now time.Time) []RankedMemory {
+	w := cfg.Weights
+	total := w.Keyword + w.Vector + w.Strength + w.Recency
+	if total <= 0 || len(cands) == 0 {
+		return nil
+	}
+	w = RetrievalWeights{w.Keyword / total, w.Vector / total, w.Strength / total, w.Recency / total}
+	halfLife := cfg.HalfLifeDays
+	if halfLife <= 0 {
+		halfLife = 30
+	}
+
+	// BM25 is unbounded; scale by the best match in this candidate set
+	var bestBM25 float64
+	for _, c := range cands {
+		if -c.BM25 > bestBM25 {
+			bestBM25 = -c.BM25
+		}
+	}
+
+	out := make([]RankedMemory, 0, len(cands))
+	for _, c := range cands {
+		var kw float32
+		if bestBM25 > 0 && c.BM25 < 0 {
+			kw = float32(-c.BM25 / bestBM25)
+		}
+		vec := clamp01(c.Similarity)
+		strength := clamp01(0.5*c.Conv.Quality +
+			0.5*float32(math.Log1p(float64(c.Conv.AccessCount))/math.Log(11)))
+		ageDays := now.Sub(time.Unix(0, int64(c.Conv.Timestamp*1e9))).Hours() / 24
+		if ageDays < 0 {
+			ageDays = 0
+		}
+		recency := float32(math.Exp(-math.Ln2 * ageDays / halfLife))
+
+		ex := RankExplain{
+			Keyword:  RankFactor{kw, w.Keyword, kw * w.Keyword},
+			Vector:   RankFactor{vec, w.Vector, vec * w.Vector},
+			Strength: RankFactor{strength, w.Strength, strength * w.Strength},
+			Recency:  RankFactor{recency, w.Recency, recency * w.Recency},
+		}
+		score := ex.Keyword.Contribution + ex.Vector.Contribution +
+			ex.Strength.Contribution + ex.Recency.Contribution
+		if score < cfg.MinScore {
+			continue
+		}
+		rm := RankedMemory{LimphaConversation: c.Conv, Score: score}
+		if cfg.Explain {
+			rm.Explain = &ex
+		}
+		out = append(out, rm)
+	}
+
+	sort.SliceStable(out, func(a, b int) bool { return out[a].Score > out[b].Score })
+	if cfg.K > 0 && len(out) > cfg.K {
+		out = out[:cfg.K]
+	}
+	return out
+}
+
+// ftsQuery turns free text into an FTS5 OR-query of quoted words,
+// so punctuation in user prompts can't break MATCH syntax
+func ftsQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var terms []string
+	seen := make(map[string]bool)
+	for _, w := range words {
+		if len([]rune(w)) < 3 || seen[w] {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, `"`+w+`"`)
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// clamp01 limits v to [0, 1]
+func clamp01(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,153 @@
+package yent
+
+// rope.go — RoPE frequency tables and context scaling (linear / NTK / YaRN)
+//
+// Scaling stretches the rotation past the trained context:
+//
+//   linear — position interpolation: pos / factor for every dimension
+//   ntk    — NTK-aware: raise theta so high frequencies stay, low ones stretch
+//   yarn   — per-dimension blend of interpolation and extrapolation,
+//            plus attention temperature correction (mscale)
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// RoPE scaling types
+const (
+	RopeScalingNone   = "none"
+	RopeScalingLinear = "linear"
+	RopeScalingNTK    = "ntk"
+	RopeScalingYaRN   = "yarn"
+)
+
+// RopeScaling holds RoPE frequency/scale overrides
+type RopeScaling struct {
+	Type     string  // none, linear, ntk, yarn ("" = from GGUF metadata)
+	Factor   float32 // context extension factor (0 = seq_len / orig_ctx)
+	FreqBase float32 // override rope theta (0 = from GGUF)
+	OrigCtx  int     // trained context length (0 = from GGUF)
+
+	// YaRN ramp boundaries (rotations per original context), llama.cpp defaults
+	BetaFast float32 // 0 = 32
+	BetaSlow float32 // 0 = 1
+}
+
+// ParseRopeScalingType normalizes a scaling type name
+func ParseRopeScalingType(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", RopeScalingNone:
+		return RopeScalingNone, nil
+	case RopeScalingLinear:
+		return RopeScalingLinear, nil
+	case RopeScalingNTK, "ntk-aware":
+		return RopeScalingNTK, nil
+	case "dynamic":
+		// dynamic NTK rescales theta as the sequence grows; the tables here are fixed
+		return "", fmt.Errorf("rope scaling %q is not supported (use ntk for a fixed NTK factor)", s)
+	case RopeScalingYaRN:
+		return RopeScalingYaRN, nil
+	default:
+		return "", fmt.Errorf("unknown rope scaling %q (none, linear, ntk, yarn)", s)
+	}
+}
+
+// resolveRopeScaling merges user overrides with GGUF metadata and fills defaults
+func resolveRopeScaling(opts RopeScaling, meta *GGUFMetadata, seqLen int) RopeScaling {
+	r := opts
+	if r.Type == "" {
+		r.Type = meta.RopeScalingType
+	}
+	if t, err := ParseRopeScalingType(r.Type); err == nil {
+		r.Type = t
+	} else {
+		fmt.Printf("[tongue/rope] %v — scaling disabled\n", err)
+		r.Type = RopeScalingNone
+	}
+	if r.OrigCtx <= 0 {
+		r.OrigCtx = meta.RopeOrigCtx
+	}
+	if r.OrigCtx <= 0 {
+		r.OrigCtx = meta.SeqLen
+	}
+	if r.Factor <= 0 {
+		r.Factor = meta.RopeScalingFactor
+	}
+	if r.Factor <= 0 && r.OrigCtx > 0 && seqLen > r.OrigCtx {
+		r.Factor = float32(seqLen) / float32(r.OrigCtx)
+	}
+	if r.Factor < 1 {
+		r.Factor = 1
+	}
+	if r.BetaFast <= 0 {
+		r.BetaFast = 32
+	}
+	if r.BetaSlow <= 0 {
+		r.BetaSlow = 1
+	}
+	return r
+}
+
+// yarnCorrDim returns the dimension at which a frequency completes nRot rotations
+// over the original context (llama.cpp ggml_rope_yarn_corr_dim)
+func yarnCorrDim(headDim, origCtx int, nRot, base float64) float64 {
+	return float64(headDim) * math.Log(float64(origCtx)/(nRot*2*math.Pi)) / (2 * math.Log(base))
+}
+
+// precomputeRoPE fills cos/sin caches for rotary position encoding
+func precomputeRoPE(s *LlamaState, cfg *LlamaConfig) {
+	ropeDim := cfg.ropeDim() // partial rotary (phi2) rotates fewer dims than the head has
+	half := ropeDim / 2
+	theta := float64(cfg.RopeTheta)
+	r := cfg.Rope
+	factor := float64(r.Factor)
+	if factor < 1 {
+		factor = 1
+	}
+
+	if r.Type == RopeScalingNTK && factor > 1 {
+		// NTK-aware: theta' = theta * factor^(d/(d-2))
+		d := float64(ropeDim)
+		theta *= math.Pow(factor, d/(d-2))
+	}
+
+	// YaRN: ramp between interpolated (low freq) and extrapolated (high freq) dims
+	var lowDim, highDim float64
+	mscale := 1.0
+	if r.Type == RopeScalingYaRN && factor > 1 {
+		lowDim = math.Floor(yarnCorrDim(ropeDim, r.OrigCtx, float64(r.BetaFast), theta))
+		highDim = math.Ceil(yarnCorrDim(ropeDim, r.OrigCtx, float64(r.BetaSlow), theta))
+		lowDim = math.Max(0, lowDim)
+		highDim = math.Min(float64(half-1), highDim)
+		mscale = 1.0 + 0.1*math.Log(factor)
+	}
+
+	for i := 0; i < half; i++ {
+		freq := 1.0 / math.Pow(theta, float64(2*i)/float64(ropeDim))
+
+		switch r.Type {
+		case RopeScalingLinear:
+			freq /= factor
+		case RopeScalingYaRN:
+			if factor > 1 {
+				// ramp=1 → extrapolate (keep), ramp=0 → interpolate (stretch)
+				ramp := 1 - math.Min(1, math.Max(0, (float64(i)-lowDim)/math.Max(0.001, highDim-lowDim)))
+				freq = freq/factor*(1-ramp) + freq*ramp
+			}
+		}
+		s.RopeFreqs[i] = freq
+
+		for pos := 0; pos < cfg.SeqLen; pos++ {
+			angle := float64(pos) * freq
+			s.CosCache[pos*half+i] = float32(math.Cos(angle) * mscale)
+			s.SinCache[pos*half+i] = float32(math.Sin(angle) * mscale)
+		}
+	}
+
+	if r.Type != RopeScalingNone && factor > 1 {
+		fmt.Printf("[tongue/rope] scaling=%s factor=%.2f orig_ctx=%d theta=%.0f\n",
+			r.Type, factor, r.OrigCtx, theta)
+	}
+}
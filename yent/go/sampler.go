@@ -0,0 +1,331 @@
+package yent
+
+// sampler.go — SamplerChain: truncation strategies in the order you choose
+//
+// The default path is fixed: top-p if it is on, top-k otherwise. A chain
+// runs any sequence of stages over the candidate list instead, then draws
+// from what is left:
+//
+//   top-k=40,typical=0.95,temp       keep 40, drop atypical tokens, temper
+//   tfs=0.95,top-p=0.9               cut the flat tail, then the nucleus
+//
+//   top-k    keep the K most likely (0 = field-modulated top-k)
+//   top-p    smallest set with cumulative probability ≥ P
+//   typical  locally typical sampling: keep tokens whose surprise is
+//            closest to the entropy, up to mass P (Meister et al., 2022)
+//   tfs      tail-free sampling: cut where the curvature of the sorted
+//            distribution has spent mass Z (Phillips, 2019)
+//   temp     divide logits by T (0 = AMK temperature)
+//
+// The AMK still drives: a stage with 0 takes the field's value for the
+// step, and a chain without a temp stage is tempered by the field at the
+// draw.
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TokenCandidate is one token under consideration
+type TokenCandidate struct {
+	Token int
+	Logit float32
+	Prob  float32 // softmax over the current candidates (set by stages that need it)
+}
+
+// SamplerStep is the field-modulated state for one sampling step
+type SamplerStep struct {
+	Temperature float32 // AMK temperature (or the caller's fallback)
+	TopK        int     // top-k after destiny shrinking
+}
+
+// Sampler is one truncation or transformation stage
+type Sampler interface {
+	Name() string
+	Apply(cands []TokenCandidate, step SamplerStep) []TokenCandidate
+}
+
+// SamplerChain runs stages in order, then samples from the survivors
+type SamplerChain []Sampler
+
+// TopKSampler keeps the K most likely tokens (0 = the step's top-k)
+type TopKSampler struct{ K int }
+
+// TopPSampler keeps the smallest nucleus with mass ≥ P
+type TopPSampler struct{ P float32 }
+
+// TypicalSampler keeps the most typical tokens up to mass P
+type TypicalSampler struct{ P float32 }
+
+// TailFreeSampler cuts the tail where the second derivative has spent mass Z
+type TailFreeSampler struct{ Z float32 }
+
+// TemperatureSampler divides logits by T (0 = the step's temperature)
+type TemperatureSampler struct{ T float32 }
+
+// Name returns the stage spec
+func (s TopKSampler) Name() string {
+	if s.K == 0 {
+		return "top-k"
+	}
+	return fmt.Sprintf("top-k=%d", s.K)
+}
+
+// Name returns the stage spec
+func (s TopPSampler) Name() string { return fmt.Sprintf("top-p=%g", s.P) }
+
+// Name returns the stage spec
+func (s TypicalSampler) Name() string { return fmt.Sprintf("typical=%g", s.P) }
+
+// Name returns the stage spec
+func (s TailFreeSampler) Name() string { return fmt.Sprintf("tfs=%g", s.Z) }
+
+// Name returns the stage spec
+func (s TemperatureSampler) Name() string {
+	if s.T == 0 {
+		return "temp"
+	}
+	return fmt.Sprintf("temp=%g", s.T)
+}
+
+// Apply keeps the top K
+func (s TopKSampler) Apply(cands []TokenCandidate, step SamplerStep) []TokenCandidate {
+	k := s.K
+	if k <= 0 {
+		k = step.TopK
+	}
+	if k <= 0 || k >= len(cands) {
+		return cands
+	}
+	sortByLogit(cands)
+	return cands[:k]
+}
+
+// Apply keeps the nucleus
+func (s TopPSampler) Apply(cands []TokenCandidate, step SamplerStep) []TokenCandidate {
+	if s.P <= 0 || s.P >= 1 {
+		return cands
+	}
+	sortByLogit(cands)
+	softmaxCandidates(cands)
+	var cum float32
+	for i, c := range cands {
+		cum += c.Prob
+		if cum >= s.P {
+			return cands[:i+1]
+		}
+	}
+	return cands
+}
+
+// Apply keeps tokens whose surprise is closest to the entropy
+func (s TypicalSampler) Apply(cands []TokenCandidate, step SamplerStep) []TokenCandidate {
+	if s.P <= 0 || s.P >= 1 || len(cands) < 2 {
+		return cands
+	}
+	softmaxCandidates(cands)
+	var entropy float64
+	for _, c := range cands {
+		if c.Prob > 0 {
+			entropy -= float64(c.Prob) * math.Log(float64(c.Prob))
+		}
+	}
+	// Sort by distance between each token's surprise and the entropy
+	dev := make(map[int]float64, len(cands))
+	for _, c := range cands {
+		if c.Prob > 0 {
+			dev[c.Token] = math.Abs(-math.Log(float64(c.Prob)) - entropy)
+		} else {
+			dev[c.Token] = math.Inf(1)
+		}
+	}
+	sort.SliceStable(cands, func(a, b int) bool { return dev[cands[a].Token] < dev[cands[b].Token] })
+	var cum float32
+	for i, c := range cands {
+		cum += c.Prob
+		if cum >= s.P {
+			return cands[:i+1]
+		}
+	}
+	return cands
+}
+
+// Apply cuts the flat tail of the sorted distribution
+func (s TailFreeSampler) Apply(cands []TokenCandidate, step SamplerStep) []TokenCandidate {
+	if s.Z <= 0 || s.Z >= 1 || len(cands) <= 2 {
+		return cands
+	}
+	sortByLogit(cands)
+	softmaxCandidates(cands)
+
+	d2 := make([]float32, len(cands)-2)
+	var sum float32
+	for i := range d2 {
+		first := cands[i].Prob - cands[i+1].Prob
+		next := cands[i+1].Prob - cands[i+2].Prob
+		d2[i] = float32(math.Abs(float64(first - next)))
+		sum += d2[i]
+	}
+	if sum <= 0 {
+		return cands // perfectly linear: no tail to find
+	}
+	var cum float32
+	for i, d := range d2 {
+		cum += d / sum
+		if cum > s.Z && i >= 1 {
+			return cands[:i]
+		}
+	}
+	return cands
+}
+
+// Apply divides logits by the temperature
+func (s TemperatureSampler) Apply(cands []TokenCandidate, step SamplerStep) []TokenCandidate {
+	t := s.T
+	if t <= 0 {
+		t = step.Temperature
+	}
+	if t <= 0 {
+		// Greedy: keep only the best
+		sortByLogit(cands)
+		return cands[:1]
+	}
+	for i := range cands {
+		cands[i].Logit /= t
+	}
+	return cands
+}
+
+// hasTemperature reports whether the chain tempers logits itself
+func (sc SamplerChain) hasTemperature() bool {
+	for _, s := range sc {
+		if _, ok := s.(TemperatureSampler); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the chain in ParseSamplerChain syntax
+func (sc SamplerChain) String() string {
+	names := make([]string, len(sc))
+	for i, s := range sc {
+		names[i] = s.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseSamplerChain parses "top-k=40,typical=0.95,temp" into a chain
+func ParseSamplerChain(spec string) (SamplerChain, error) {
+	var chain SamplerChain
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, hasArg := strings.Cut(part, "=")
+		var val float32
+		if hasArg {
+			v, err := strconv.ParseFloat(arg, 32)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("sampler %s: bad value %q", name, arg)
+			}
+			val = float32(v)
+		}
+		// top-k and temp default to the field's value; the rest need one
+		switch name {
+		case "top-k":
+			chain = append(chain, TopKSampler{K: int(val)})
+		case "temp":
+			chain = append(chain, TemperatureSampler{T: val})
+		case "top-p", "typical", "tfs":
+			if !hasArg {
+				return nil, fmt.Errorf("sampler %s needs a value (%s=0.95)", name, name)
+			}
+			switch name {
+			case "top-p":
+				chain = append(chain, TopPSampler{P: val})
+			case "typical":
+				chain = append(chain, TypicalSampler{P: val})
+			default:
+				chain = append(chain, TailFreeSampler{Z: val})
+			}
+		default:
+			return nil, fmt.Errorf("unknown sampler %q (have: top-k, top-p, typical, tfs, temp)", name)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("empty sampler chain")
+	}
+	return chain, nil
+}
+
+// sampleChain runs the chain over the current logits and draws a token.
+// Returns the token and the entropy (nats) of the full tempered distribution.
+func (y *Yent) sampleChain(chain SamplerChain, step SamplerStep) (int, float32) {
+	logits := y.model.State.Logits
+	vocab := y.model.Config.VocabSize
+
+	scratch := &y.model.State.scratch
+	cands := scratch.candBuf(vocab)
+	for i := 0; i < vocab; i++ {
+		cands[i] = TokenCandidate{Token: i, Logit: logits[i]}
+	}
+	entropy := float32(0)
+	if step.Temperature > 0 {
+		weights := scratch.weightBuf(vocab)
+		maxVal := logits[argmax(logits, vocab)]
+		var sum float32
+		for i := 0; i < vocab; i++ {
+			weights[i] = float32(math.Exp(float64((logits[i] - maxVal) / step.Temperature)))
+			sum += weights[i]
+		}
+		entropy = distEntropy(weights, sum)
+	}
+
+	for _, s := range chain {
+		if cands = s.Apply(cands, step); len(cands) == 0 {
+			return argmax(logits, vocab), entropy
+		}
+	}
+	if !chain.hasTemperature() {
+		cands = TemperatureSampler{}.Apply(cands, step)
+	}
+
+	softmaxCandidates(cands)
+	r := y.rng.Float32()
+	var cdf float32
+	for _, c := range cands {
+		cdf += c.Prob
+		if r <= cdf {
+			return c.Token, entropy
+		}
+	}
+	return cands[0].Token, entropy
+}
+
+// sortByLogit orders candidates most likely first
+func sortByLogit(cands []TokenCandidate) {
+	sort.Slice(cands, func(a, b int) bool { return cands[a].Logit > cands[b].Logit })
+}
+
+// softmaxCandidates sets Prob from Logit over the current candidates
+func softmaxCandidates(cands []TokenCandidate) {
+	maxVal := float32(math.Inf(-1))
+	for _, c := range cands {
+		if c.Logit > maxVal {
+			maxVal = c.Logit
+		}
+	}
+	var sum float32
+	for i := range cands {
+		cands[i].Prob = float32(math.Exp(float64(cands[i].Logit - maxVal)))
+		sum += cands[i].Prob
+	}
+	for i := range cands {
+		cands[i].Prob /= sum
+	}
+}
@@ -0,0 +1,72 @@
+package yent
+
+// samplerpack.go — SAMPLER: the sampling step as kernel state
+//
+// Temperature already came from the field; top-p, top-k and the
+// repetition penalty came from flags and requests, so a script could not
+// say how an answer should be drawn. The SAMPLER pack moves them into the
+// kernel:
+//
+//   SAMPLER TOP_P 0.9     nucleus mass (1 = top-k instead)
+//   SAMPLER TOP_K 40      candidates before destiny shrinks them
+//   SAMPLER MIN_P 0.05    drop tokens under 5% of the likeliest one
+//   SAMPLER REP 1.2       repetition penalty (1 = off)
+//   SAMPLER RESET         back to the request's own settings
+//
+// The first SAMPLER line enables the pack; DISABLE SAMPLER sets the
+// overrides aside, MODE SAMPLER brings them back. Values live in the
+// kernel state, so a whole generation configuration is one script:
+// swapped under one lock by Exec, read once per token, carried by
+// sessions, presets, snapshots and cues like the rest of the field.
+// Destiny still shrinks what the pack sets; MIN_P applies at the AMK
+// temperature, before the draw of any sampling mode.
+
+import "math"
+
+// AMKSampler is what the SAMPLER pack sets (0 = the request's setting)
+type AMKSampler struct {
+	TopP float32
+	TopK int
+	MinP float32
+	Rep  float32
+}
+
+func (s AMKSampler) topP(req float32) float32 {
+	if s.TopP > 0 {
+		return s.TopP
+	}
+	return req
+}
+
+func (s AMKSampler) topK(req int) int {
+	if s.TopK > 0 {
+		return s.TopK
+	}
+	return req
+}
+
+func (s AMKSampler) rep(req float32) float32 {
+	if s.Rep > 0 {
+		return s.Rep
+	}
+	return req
+}
+
+// applyMinP masks logits whose probability at temp is under minP times the
+// likeliest token's
+func applyMinP(logits []float32, temp, minP float32) {
+	if minP <= 0 || temp <= 0 || len(logits) == 0 {
+		return
+	}
+	maxVal := logits[0]
+	for _, l := range logits[1:] {
+		maxVal = max(maxVal, l)
+	}
+	// p/pmax = exp((l - max)/temp) < minP  ⇔  l < max + temp·ln(minP)
+	floor := maxVal + temp*float32(math.Log(float64(minP)))
+	for i, l := range logits {
+		if l < floor {
+			logits[i] = -1e30
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package yent
+
+// scheduler.go — One clock for maintenance jobs
+//
+// Nightly shard export, memory backups, vacuum, session expiry: each used
+// to want its own ticker. The Scheduler runs them all from cron specs:
+//
+//   0 3 * * *         minute hour day-of-month month day-of-week
+//   */15 * * * *      every 15 minutes
+//   30 4 * * 1-5      04:30 on weekdays
+//   @daily @hourly @weekly @monthly
+//   @every 90s        fixed interval (Go duration)
+//
+// Fields take *, n, a-b, lists (1,15) and steps (*/n, a-b/n). When both
+// day-of-month and day-of-week are restricted either may match, as in cron.
+// Times are local. A job still running when it comes due again is skipped,
+// not stacked; a panicking job is recorded as failed. Status() is what the
+// admin API shows.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrJobRunning is returned by RunNow when the job is already running
+var ErrJobRunning = errors.New("job already running")
+
+// Schedule yields the next run time after a given time (zero = never)
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// JobStatus is a job's schedule and last outcome
+type JobStatus struct {
+	Name         string    `json:"name"`
+	Spec         string    `json:"spec"`
+	Running      bool      `json:"running"`
+	Runs         int       `json:"runs"`
+	Failures     int       `json:"failures"`
+	LastRun      time.Time `json:"last_run"`
+	LastDuration string    `json:"last_duration,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+	NextRun      time.Time `json:"next_run"`
+}
+
+// job is one registered job
+type job struct {
+	sched  Schedule
+	run    func(ctx context.Context) error
+	status JobStatus
+}
+
+// Scheduler runs jobs on cron schedules
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    map[string]*job
+	wake    chan struct{}
+	stop    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	started bool
+}
+
+// NewScheduler creates an idle scheduler (see Start)
+func NewScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		jobs:   make(map[string]*job),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Add registers a job under a unique name
+func (s *Scheduler) Add(name, spec string, run func(ctx context.Context) error) error {
+	sched, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("job %s already registered", name)
+	}
+	s.jobs[name] = &job{
+		sched:  sched,
+		run:    run,
+		status: JobStatus{Name: name, Spec: spec, NextRun: sched.Next(time.Now())},
+	}
+	s.notify()
+	return nil
+}
+
+// Start runs the scheduling loop in the background
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	go s.loop()
+}
+
+// Stop ends the loop, cancels running jobs and waits for them
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	s.mu.Unlock()
+	s.cancel()
+	s.wg.Wait()
+}
+
+// RunNow starts a job immediately, outside its schedule
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return fmt.Errorf("no job %s", name)
+	}
+	if j.status.Running {
+		return fmt.Errorf("%s: %w", name, ErrJobRunning)
+	}
+	s.start(j)
+	return nil
+}
+
+// Status returns every job's status, by name
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		out = append(out, j.status)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].Name < out[b].Name })
+	return out
+}
+
+// notify wakes the loop to recompute its timer (caller holds s.mu)
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) loop() {
+	for {
+		s.mu.Lock()
+		var next time.Time
+		for _, j := range s.jobs {
+			if !j.status.NextRun.IsZero() && (next.IsZero() || j.status.NextRun.Before(next)) {
+				next = j.status.NextRun
+			}
+		}
+		s.mu.Unlock()
+
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case now := <-timer.C:
+			s.runDue(now)
+		}
+	}
+}
+
+// runDue starts every job whose time has come
+func (s *Scheduler) runDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, j := range s.jobs {
+		if j.status.NextRun.IsZero() || j.status.NextRun.After(now) {
+			continue
+		}
+		j.status.NextRun = j.sched.Next(now)
+		if j.status.Running {
+			fmt.Printf("[scheduler] %s still running, skipped\n", name)
+			continue
+		}
+		s.start(j)
+	}
+}
+
+// start runs j in its own goroutine (caller holds s.mu)
+func (s *Scheduler) start(j *job) {
+	j.status.Running = true
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		began := time.Now()
+		err := runJob(s.ctx, j.run)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		j.status.Running = false
+		j.status.Runs++
+		j.status.LastRun = began
+		j.status.LastDuration = time.Since(began).Round(time.Millisecond).String()
+		j.status.LastError = ""
+		if err != nil {
+			j.status.Failures++
+			j.status.LastError = err.Error()
+			fmt.Printf("[scheduler] %s failed: %v\n", j.status.Name, err)
+		}
+	}()
+}
+
+// runJob calls run, turning a panic into an error
+func runJob(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(ctx)
+}
+
+// everySchedule runs at a fixed interval
+type everySchedule time.Duration
+
+// Next returns after + the interval
+func (e everySchedule) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(e))
+}
+
+// cronSchedule is a parsed five-field cron spec (bit n set = value n allowed)
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// cronSearchYears bounds the search for impossible specs (0 0 30 2 *)
+const cronSearchYears = 5
+
+// Next returns the first matching minute after after
+func (c *cronSchedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches applies cron's day rule: with both day fields restricted, either matches
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// cronMacros are the @-shortcuts for common specs
+var cronMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+}
+
+// ParseSchedule parses a cron spec, an @-macro or "@every <duration>"
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		dur, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || dur < time.Second {
+			return nil, fmt.Errorf("bad interval %q (at least 1s)", d)
+		}
+		return everySchedule(dur), nil
+	}
+	if m, ok := cronMacros[spec]; ok {
+		spec = m
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron spec %q: need 5 fields (minute hour day month weekday)", spec)
+	}
+	c := &cronSchedule{}
+	ranges := []struct {
+		bits     *uint64
+		min, max int
+		name     string
+	}{
+		{&c.minute, 0, 59, "minute"},
+		{&c.hour, 0, 23, "hour"},
+		{&c.dom, 1, 31, "day of month"},
+		{&c.month, 1, 12, "month"},
+		{&c.dow, 0, 7, "day of week"},
+	}
+	for i, r := range ranges {
+		bits, err := parseCronField(fields[i], r.min, r.max)
+		if err != nil {
+			return nil, fmt.Errorf("cron %s %q: %w", r.name, fields[i], err)
+		}
+		*r.bits = bits
+	}
+	if c.dow&(1<<7) != 0 { // 7 is Sunday too
+		c.dow |= 1
+	}
+	c.domStar = fields[2] == "*"
+	c.dowStar = fields[4] == "*"
+	return c, nil
+}
+
+// parseCronField parses one comma-separated cron field into a bit set
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("bad step %q", stepStr)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("bad value %q", a)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("bad value %q", b)
+				}
+			} else if hasStep {
+				hi = max // 5/15 = from 5, every 15
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%d-%d out of range %d-%d", lo, hi, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
@@ -0,0 +1,65 @@
+package yent
+
+// scratch.go — Per-token scratch buffers
+//
+// The forward pass already writes into LlamaState's fixed buffers; the
+// samplers did not. sampleTopP and mirostat built a vocab-sized candidate
+// list for every token (151k entries on Qwen, ~2.4 MB), and the chain
+// sampler two more — garbage the collector then had to chase mid-
+// generation. The arena below lives on the state and hands the same
+// backing arrays back on every token, growing only if the vocabulary does.
+//
+// One arena per state, so one per generation in flight: nothing here is
+// safe to share between goroutines, same as the rest of LlamaState.
+
+import (
+	"cmp"
+	"slices"
+)
+
+// tokenScore is a token with a logit or probability
+type tokenScore struct {
+	idx int
+	val float32
+}
+
+// scratchArena holds sampler buffers reused across tokens
+type scratchArena struct {
+	scores  []tokenScore
+	weights []float32
+	cands   []TokenCandidate
+}
+
+// scoreBuf returns n reusable scores (contents undefined)
+func (s *scratchArena) scoreBuf(n int) []tokenScore {
+	if cap(s.scores) < n {
+		s.scores = make([]tokenScore, n)
+	}
+	return s.scores[:n]
+}
+
+// weightBuf returns n reusable floats (contents undefined)
+func (s *scratchArena) weightBuf(n int) []float32 {
+	if cap(s.weights) < n {
+		s.weights = make([]float32, n)
+	}
+	return s.weights[:n]
+}
+
+// candBuf returns n reusable candidates (contents undefined)
+func (s *scratchArena) candBuf(n int) []TokenCandidate {
+	if cap(s.cands) < n {
+		s.cands = make([]TokenCandidate, n)
+	}
+	return s.cands[:n]
+}
+
+// bytes is the capacity held by the arena (MemoryStats)
+func (s *scratchArena) bytes() int64 {
+	return int64(cap(s.scores)*16 + cap(s.weights)*4 + cap(s.cands)*12)
+}
+
+// sortScoresDesc orders scores highest first without allocating
+func sortScoresDesc(s []tokenScore) {
+	slices.SortFunc(s, func(a, b tokenScore) int { return cmp.Compare(b.val, a.val) })
+}
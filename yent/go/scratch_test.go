@@ -0,0 +1,26 @@
+package yent
+
+import "testing"
+
+// TestSamplerAllocs checks that sampling a token allocates nothing once the
+// scratch arena has grown to the vocabulary
+func TestSamplerAllocs(t *testing.T) {
+	logits := make([]float32, 4096)
+	for i := range logits {
+		logits[i] = float32(i%97) / 10
+	}
+	y := logitsYent(logits...)
+
+	for _, tc := range []struct {
+		name   string
+		sample func()
+	}{
+		{"sampleTopP", func() { y.sampleTopP(0.8, 0.9, 1) }},
+		{"sampleTopK", func() { y.sampleTopK(0.8, 40) }},
+	} {
+		tc.sample() // first call sizes the arena
+		if allocs := testing.AllocsPerRun(50, tc.sample); allocs != 0 {
+			t.Errorf("%s: %.1f allocations per token, want 0", tc.name, allocs)
+		}
+	}
+}
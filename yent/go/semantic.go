@@ -0,0 +1,150 @@
+package yent
+
+// semantic.go — Semantic search and clustering over LIMPHA memory
+//
+// FTS5 answers "where did I say this word". Semantic search answers
+// "when did I feel something like this". Both read the same conversations;
+// this side only adds vectors.
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// MemoryHit is a conversation returned by semantic search
+type MemoryHit struct {
+	LimphaConversation
+	Score float32 `json:"score"`
+}
+
+// indexBatch is how many conversations are fetched per indexing round trip
+const indexBatch = 500
+
+// SetEmbedder switches the embedder and opens its vector store.
+// name identifies the embedding space (one store file per name).
+func (y *Yent) SetEmbedder(e Embedder, name string) error {
+	path, err := vectorStorePath(name)
+	if err != nil {
+		return err
+	}
+	store, err := LoadVectorStore(path)
+	if err != nil {
+		return err
+	}
+
+	y.indexMu.Lock()
+	defer y.indexMu.Unlock()
+	if c, ok := y.embedder.(interface{ Close() }); ok && y.embedder != e {
+		c.Close()
+	}
+	y.embedder = e
+	y.vectors = store
+	fmt.Printf("[embed] %s — %d vectors\n", name, store.Len())
+	return nil
+}
+
+// Embedder returns the active embedder (nil = semantic memory disabled)
+func (y *Yent) Embedder() Embedder {
+	return y.embedder
+}
+
+// Embed embeds text with the active embedder
+func (y *Yent) Embed(text string) ([]float32, error) {
+	if y.embedder == nil {
+		return nil, fmt.Errorf("no embedder")
+	}
+	return y.embedder.Embed(text)
+}
+
+// IndexMemories embeds every LIMPHA conversation not yet in the vector store.
+// Returns the number of conversations added.
+func (y *Yent) IndexMemories() (int, error) {
+	y.indexMu.Lock()
+	defer y.indexMu.Unlock()
+
+	if y.embedder == nil || y.vectors == nil {
+		return 0, fmt.Errorf("no embedder")
+	}
+	if y.limpha == nil {
+		return 0, fmt.Errorf("limpha not available")
+	}
+
+	added := 0
+	for {
+		convs, err := y.limpha.ExportRows(0, 0, indexBatch, y.vectors.LastID)
+		if err != nil {
+			return added, fmt.Errorf("fetch rows: %w", err)
+		}
+		for _, c := range convs {
+			vec, err := y.embedder.Embed(memoryText(c))
+			if err != nil {
+				return added, fmt.Errorf("embed conversation %d: %w", c.ID, err)
+			}
+			if err := y.vectors.Add(c.ID, vec); err != nil {
+				return added, err
+			}
+			added++
+		}
+		if len(convs) < indexBatch {
+			break
+		}
+	}
+
+	if added > 0 {
+		if err := y.vectors.Save(); err != nil {
+			return added, err
+		}
+		fmt.Printf("[embed] indexed %d conversations (%d total)\n", added, y.vectors.Len())
+	}
+	return added, nil
+}
+
+// SemanticSearch returns the k conversations closest in meaning to query
+func (y *Yent) SemanticSearch(query string, k int) ([]MemoryHit, error) {
+	if _, err := y.IndexMemories(); err != nil {
+		return nil, err
+	}
+	qvec, err := y.embedder.Embed(query)
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+
+	var out []MemoryHit
+	for _, h := range y.vectors.Search(qvec, k) {
+		conv, err := y.limpha.Recall(h.ID)
+		if err != nil {
+			continue // deleted since indexing
+		}
+		out = append(out, MemoryHit{LimphaConversation: *conv, Score: h.Score})
+	}
+	return out, nil
+}
+
+// ClusterMemories groups indexed conversations into k clusters by meaning
+func (y *Yent) ClusterMemories(k int) ([]VectorCluster, error) {
+	if _, err := y.IndexMemories(); err != nil {
+		return nil, err
+	}
+	return y.vectors.Cluster(k, 20, 1), nil
+}
+
+// memoryText is what gets embedded for one conversation
+func memoryText(c LimphaConversation) string {
+	return c.Prompt + "\n" + c.Response
+}
+
+// embedderStoreName names the embedding space of an embedder config
+func embedderStoreName(cfg EmbedderConfig, weightsPath string) string {
+	switch strings.ToLower(cfg.Type) {
+	case EmbedderONNX:
+		return "onnx-" + strings.TrimSuffix(filepath.Base(cfg.Model), ".onnx")
+	case EmbedderRemote:
+		if cfg.Model != "" {
+			return "remote-" + cfg.Model
+		}
+		return "remote"
+	default:
+		return "model-" + strings.TrimSuffix(filepath.Base(weightsPath), ".gguf")
+	}
+}
@@ -0,0 +1,769 @@
+package yent
+
+// server.go — HTTP API with sticky sessions
+//
+//   POST   /v1/generate        {"prompt": "...", "session_id": "alice"}
+//   GET    /v1/sessions        live sessions
+//   GET    /v1/sessions/{id}   one session with its transcript
+//   DELETE /v1/sessions/{id}   drop a session (its memories stay in LIMPHA)
+//   GET    /v1/streams/{id}    resume a streamed answer (stream.go)
+//   DELETE /v1/streams/{id}    stop a streamed answer
+//   GET    /v1/admin/jobs      scheduled jobs and their last runs
+//   POST   /v1/admin/jobs/{name}/run   run a job now
+//   POST   /v1/admin/reload    {"weights": "...", "model": "yent"} hot-swap (reload.go)
+//                               {"deltas": true} re-reads changed delta files (deltas.go)
+//   GET    /v1/admin/events?kinds=a,b   event bus as server-sent events (events.go)
+//   GET    /v1/admin/profile   time per phase of generation (profile.go)
+//   DELETE /v1/admin/profile   reset the phase counters
+//   GET    /debug/pprof/       net/http/pprof, with ServerOptions.Pprof
+//   GET    /v1/models          models in the pool (pool.go)
+//   GET    /status             sessions, streams, workers, thermal throttle
+//
+// A request without session_id is stateless, exactly like the CLI. With one,
+// it is routed to that Session (created on first use): the conversation
+// continues from its transcript and cached KV rows, on its own AMK field,
+// remembering under its own LIMPHA namespace. Sessions idle longer than
+// IdleTimeout are dropped; beyond MaxSessions the least recently used goes.
+//
+// Generation is serialized by the model lock, so requests on one session
+// can never interleave. Closing the connection cancels the request, unless
+// the answer is streamed: streams outlive their connection to be resumed.
+//
+// Expiry and the maintenance jobs (maintenance.go) run on the server's
+// Scheduler. The admin API needs AdminToken as a bearer token; without
+// one it only answers on loopback.
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerOptions configures NewServer
+type ServerOptions struct {
+	Base        GenerateOptions // defaults for every request
+	IdleTimeout time.Duration   // drop sessions idle this long (0 = 30m)
+	MaxSessions int             // live session cap, LRU eviction (0 = 64)
+
+	// StreamRetention keeps finished streamed answers resumable (0 = 5m)
+	StreamRetention time.Duration
+
+	// Jobs maps maintenance job names to schedules (DefaultMaintenanceJobs)
+	Jobs map[string]string
+
+	// AdminToken guards /v1/admin ("" = loopback clients only)
+	AdminToken string
+
+	// Throttle is checked every 15s and applied to every generation
+	// (thermal.go; nil = off)
+	Throttle *Throttle
+
+	// Pool serves several models, picked by the request's "model"
+	// (nil = only the Yent given to NewServer)
+	Pool *ModelPool
+
+	// Pprof mounts net/http/pprof under /debug/pprof/, behind the admin
+	// check like /v1/admin
+	Pprof bool
+}
+
+// Server serves the HTTP API for one Yent
+type Server struct {
+	y    *Yent
+	opts ServerOptions
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+	streams  map[string]*responseStream
+
+	sched     *Scheduler
+	closeOnce sync.Once
+}
+
+// maxSessionID bounds session id length
+const maxSessionID = 64
+
+// NewServer creates a server and starts its scheduler
+func NewServer(y *Yent, opts ServerOptions) (*Server, error) {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = 30 * time.Minute
+	}
+	if opts.MaxSessions <= 0 {
+		opts.MaxSessions = 64
+	}
+	if opts.StreamRetention <= 0 {
+		opts.StreamRetention = 5 * time.Minute
+	}
+	s := &Server{
+		y:        y,
+		opts:     opts,
+		sessions: make(map[string]*Session),
+		streams:  make(map[string]*responseStream),
+		sched:    NewScheduler(),
+	}
+
+	// Expiry every IdleTimeout/4, at most a minute apart
+	every := opts.IdleTimeout / 4
+	if every > time.Minute {
+		every = time.Minute
+	}
+	if every < time.Second {
+		every = time.Second
+	}
+	s.sched.Add("session-expiry", "@every "+every.String(), func(ctx context.Context) error {
+		if n := s.expire(time.Now()); n > 0 {
+			fmt.Printf("[server] expired %d idle sessions\n", n)
+		}
+		return nil
+	})
+	for name, spec := range opts.Jobs {
+		run, err := y.MaintenanceJob(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.sched.Add(name, spec, run); err != nil {
+			return nil, err
+		}
+	}
+	if t := opts.Throttle; t != nil {
+		y.SetThrottle(t)
+		if opts.Pool != nil {
+			for _, name := range opts.Pool.Names() {
+				if m, _ := opts.Pool.Get(name); m != y {
+					m.SetThrottle(t)
+				}
+			}
+		}
+		t.Check()
+		s.sched.Add("thermal", "@every 15s", func(ctx context.Context) error {
+			t.Check()
+			return nil
+		})
+	}
+	s.sched.Start()
+	return s, nil
+}
+
+// Scheduler returns the server's job scheduler
+func (s *Server) Scheduler() *Scheduler {
+	return s.sched
+}
+
+// Close stops the scheduler, cancels streamed answers and drops all sessions
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		s.sched.Stop()
+		s.mu.Lock()
+		for _, st := range s.streams {
+			st.cancel()
+		}
+		s.sessions = make(map[string]*Session)
+		s.streams = make(map[string]*responseStream)
+		s.mu.Unlock()
+	})
+}
+
+// Handler returns the HTTP routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/generate", s.handleGenerate)
+	mux.HandleFunc("/v1/sessions", s.handleSessions)
+	mux.HandleFunc("/v1/sessions/", s.handleSession)
+	mux.HandleFunc("/v1/streams/", s.handleStream)
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/v1/models", s.handleModels)
+	mux.HandleFunc("/v1/vocab", s.handleVocab)
+	mux.HandleFunc("/v1/deltas", s.handleDeltas)
+	mux.HandleFunc("/v1/compare", s.handleCompare)
+	mux.HandleFunc("/v1/admin/jobs", s.admin(s.handleJobs))
+	mux.HandleFunc("/v1/admin/jobs/", s.admin(s.handleJobRun))
+	mux.HandleFunc("/v1/admin/reload", s.admin(s.handleReload))
+	mux.HandleFunc("/v1/admin/events", s.admin(s.handleEvents))
+	mux.HandleFunc("/v1/admin/profile", s.admin(s.handleProfile))
+	if s.opts.Pprof {
+		mux.HandleFunc("/debug/pprof/", s.admin(pprof.Index))
+		mux.HandleFunc("/debug/pprof/cmdline", s.admin(pprof.Cmdline))
+		mux.HandleFunc("/debug/pprof/profile", s.admin(pprof.Profile))
+		mux.HandleFunc("/debug/pprof/symbol", s.admin(pprof.Symbol))
+		mux.HandleFunc("/debug/pprof/trace", s.admin(pprof.Trace))
+	}
+	return mux
+}
+
+// expire drops sessions idle since before now - IdleTimeout and finished
+// streams past retention; returns the number of sessions dropped
+func (s *Server) expire(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, st := range s.streams {
+		if st.expired(now, s.opts.StreamRetention) {
+			delete(s.streams, id)
+		}
+	}
+	n := 0
+	for id, sess := range s.sessions {
+		if now.Sub(sess.LastUsed()) > s.opts.IdleTimeout {
+			delete(s.sessions, id)
+			n++
+		}
+	}
+	return n
+}
+
+// session returns the session for id, creating it (and evicting the least
+// recently used one when full)
+func (s *Server) session(id string) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[id]; ok {
+		return sess
+	}
+	if len(s.sessions) >= s.opts.MaxSessions {
+		var oldest *Session
+		for _, sess := range s.sessions {
+			if oldest == nil || sess.LastUsed().Before(oldest.LastUsed()) {
+				oldest = sess
+			}
+		}
+		delete(s.sessions, oldest.ID)
+		fmt.Printf("[server] evicted session %s (limit %d)\n", oldest.ID, s.opts.MaxSessions)
+	}
+	sess := NewSession(id)
+	s.sessions[id] = sess
+	return sess
+}
+
+// validSessionID accepts 1-64 characters of [A-Za-z0-9._:-]
+func validSessionID(id string) bool {
+	if id == "" || len(id) > maxSessionID {
+		return false
+	}
+	for _, c := range id {
+		ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '.' || c == '_' || c == ':' || c == '-'
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// generateRequest is the POST /v1/generate body; unset fields use the server defaults
+type generateRequest struct {
+	Prompt       string   `json:"prompt"`
+	SessionID    string   `json:"session_id"`
+	Model        string   `json:"model"` // pool model ("" = default)
+	MaxTokens    int      `json:"max_tokens"`
+	Temperature  *float32 `json:"temperature"`
+	TopP         *float32 `json:"top_p"`
+	TopK         int      `json:"top_k"`
+	Stop         []string `json:"stop"`
+	Seed         int64    `json:"seed"`
+	Stream       bool     `json:"stream"`        // Server-Sent Events, resumable (stream.go)
+	ParseSpecial bool     `json:"parse_special"` // special tokens in prompt are tokens
+	TokenHealing bool     `json:"token_healing"` // heal the prompt's last token (heal.go)
+	Delta        string   `json:"delta"`         // loaded Delta Voice by name ("" = active)
+	AutoLanguage bool     `json:"auto_language"` // alpha and delta from the prompt's language (langroute.go)
+	DeltaAlways  bool     `json:"delta_always"`  // apply the delta past the delta gate (deltagate.go)
+	AMKPreset    string   `json:"amk_preset"`    // run on a preset AMK field (amkpreset.go)
+	AMKTrace     int      `json:"amk_trace"`     // kernel state of the last N tokens (amktrace.go)
+	AMKCues      string   `json:"amk_cues"`      // "at token 100: VELOCITY RUN; ..." (amkcues.go)
+}
+
+// generateResponse is the POST /v1/generate reply
+type generateResponse struct {
+	Text         string `json:"text"`
+	SessionID    string `json:"session_id,omitempty"`
+	Model        string `json:"model,omitempty"`
+	StreamID     string `json:"stream_id,omitempty"`
+	Turns        int    `json:"turns,omitempty"`
+	CachedTokens int    `json:"cached_tokens,omitempty"`
+	Language     string `json:"language,omitempty"` // detected prompt language
+
+	AMKBefore AMState         `json:"amk_before"` // kernel field the answer started from
+	AMKAfter  AMState         `json:"amk_after"`  // and the one it left
+	AMKTrace  []AMKTracePoint `json:"amk_trace,omitempty"`
+}
+
+// sessionInfo describes a live session
+type sessionInfo struct {
+	ID           string        `json:"id"`
+	Namespace    string        `json:"namespace"`
+	Created      time.Time     `json:"created"`
+	LastUsed     time.Time     `json:"last_used"`
+	Turns        int           `json:"turns"`
+	CachedTokens int           `json:"cached_tokens"`
+	Transcript   []SessionTurn `json:"transcript,omitempty"`
+}
+
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var req generateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad request body: "+err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, "prompt is required")
+		return
+	}
+	if req.SessionID != "" && !validSessionID(req.SessionID) {
+		writeError(w, http.StatusBadRequest, "session_id must be 1-64 characters of [A-Za-z0-9._:-]")
+		return
+	}
+	y, err := s.model(req.Model)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	opts := s.opts.Base
+	if req.MaxTokens > 0 {
+		opts.MaxTokens = req.MaxTokens
+	}
+	if req.Temperature != nil {
+		opts.Temperature = *req.Temperature
+	}
+	if req.TopP != nil {
+		opts.TopP = *req.TopP
+	}
+	if req.TopK > 0 {
+		opts.TopK = req.TopK
+	}
+	if req.Stop != nil {
+		opts.Stop = req.Stop
+	}
+	if req.Seed != 0 {
+		opts.Seed = req.Seed
+	}
+	if req.ParseSpecial {
+		opts.ParseSpecial = true
+	}
+	if req.TokenHealing {
+		opts.TokenHealing = true
+	}
+	if req.AutoLanguage {
+		opts.AutoLanguage = true
+	}
+	if req.DeltaAlways {
+		opts.DeltaAlways = true
+	}
+	if req.Delta != "" {
+		if !y.hasDelta(req.Delta) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("no delta %q loaded", req.Delta))
+			return
+		}
+		opts.Delta = req.Delta
+	}
+	if req.AMKPreset != "" {
+		if _, ok := AMKPresets[req.AMKPreset]; !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("no AMK preset %q (have %s)", req.AMKPreset, strings.Join(AMKPresetNames(), ", ")))
+			return
+		}
+		opts.AMKPreset = req.AMKPreset
+	}
+	if req.AMKCues != "" {
+		cues, err := ParseAMKCues(req.AMKCues)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "amk_cues: "+err.Error())
+			return
+		}
+		opts.AMKCues = cues
+	}
+	if req.AMKTrace > 0 {
+		opts.AMKTrace = min(req.AMKTrace, DefaultAMKTrace)
+	}
+	if req.SessionID != "" {
+		opts.Session = s.session(req.SessionID)
+	}
+	if req.Stream {
+		s.serveStream(w, r, s.startStream(y, req.Prompt, req.SessionID, req.Model, opts), 0)
+		return
+	}
+
+	res, err := y.GenerateContext(r.Context(), req.Prompt, opts)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, r.Context().Err()) {
+			status = 499 // client closed request
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	resp := generateResponse{Text: res.Text, SessionID: req.SessionID, Model: req.Model, Language: res.Language,
+		AMKBefore: res.AMKBefore, AMKAfter: res.AMKAfter, AMKTrace: res.AMKTrace}
+	if opts.Session != nil {
+		resp.Turns = len(opts.Session.Turns())
+		resp.CachedTokens = opts.Session.CachedTokens()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	s.mu.Lock()
+	list := make([]sessionInfo, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		list = append(list, describeSession(sess, false))
+	}
+	s.mu.Unlock()
+	sort.Slice(list, func(a, b int) bool { return list[a].LastUsed.After(list[b].LastUsed) })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": list})
+}
+
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/sessions/")
+	if !validSessionID(id) {
+		writeError(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	if ok && r.Method == http.MethodDelete {
+		delete(s.sessions, id)
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "no session "+id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, describeSession(sess, true))
+	case http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "use GET or DELETE")
+	}
+}
+
+// model resolves a request's model name
+func (s *Server) model(name string) (*Yent, error) {
+	if s.opts.Pool != nil {
+		return s.opts.Pool.Get(name)
+	}
+	if name != "" {
+		return nil, fmt.Errorf("no model %q (no pool)", name)
+	}
+	return s.y, nil
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	out := map[string]interface{}{"models": []string{}}
+	if p := s.opts.Pool; p != nil {
+		out["models"], out["default"] = p.Names(), p.Default()
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleDeltas lists the loaded Delta Voices: ?model=
+func (s *Server) handleDeltas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	y, err := s.model(r.URL.Query().Get("model"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deltas": y.Deltas()})
+}
+
+// compareRequest is the POST /v1/compare body (compare.go)
+type compareRequest struct {
+	Prompt    string  `json:"prompt"`
+	Alpha     float32 `json:"alpha"`
+	Delta     string  `json:"delta"` // loaded Delta Voice by name ("" = active)
+	Model     string  `json:"model"`
+	MaxTokens int     `json:"max_tokens"`
+	Seed      int64   `json:"seed"`
+}
+
+// handleCompare answers a prompt at alpha 0 and at alpha with one seed
+func (s *Server) handleCompare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var req compareRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad request body: "+err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, "prompt is required")
+		return
+	}
+	if req.Alpha <= 0 || req.Alpha > 1 {
+		writeError(w, http.StatusBadRequest, "alpha must be in (0, 1]")
+		return
+	}
+	y, err := s.model(req.Model)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	opts := s.opts.Base
+	if req.MaxTokens > 0 {
+		opts.MaxTokens = req.MaxTokens
+	}
+	opts.Seed = req.Seed
+	if req.Delta != "" {
+		if !y.hasDelta(req.Delta) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("no delta %q loaded", req.Delta))
+			return
+		}
+		opts.Delta = req.Delta
+	}
+	cmp, err := y.CompareAlpha(r.Context(), req.Prompt, req.Alpha, opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, cmp)
+}
+
+// handleVocab searches the vocabulary: ?q=substring&limit=N (default 100, 0 = all)&model=
+func (s *Server) handleVocab(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	q := r.URL.Query()
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a non-negative integer")
+			return
+		}
+		limit = n
+	}
+	y, err := s.model(q.Get("model"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	entries, err := y.Vocab(q.Get("q"), limit)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tokens": entries})
+}
+
+// serverStatus is the GET /status reply
+type serverStatus struct {
+	Sessions int             `json:"sessions"`
+	Streams  int             `json:"streams"`
+	Threads  int             `json:"threads"`
+	Throttle *ThrottleStatus `json:"throttle,omitempty"`
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	s.mu.Lock()
+	st := serverStatus{Sessions: len(s.sessions), Streams: len(s.streams), Threads: Threads()}
+	s.mu.Unlock()
+	if s.opts.Throttle != nil {
+		ts := s.opts.Throttle.Status()
+		st.Throttle = &ts
+		st.Threads = ts.Threads
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// handleEvents follows the event bus until the client goes away. Admin
+// only: token events carry every session's answers.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	var kinds []string
+	if v := r.URL.Query().Get("kinds"); v != "" {
+		kinds = strings.Split(v, ",")
+	}
+	sub := s.y.Events().Subscribe(0, kinds...)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case e := <-sub.C:
+			writeEvent(w, "", e.Kind, e)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// admin guards an admin handler: bearer AdminToken, or loopback without one
+func (s *Server) admin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.opts.AdminToken != "" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(s.opts.AdminToken)) != 1 {
+				writeError(w, http.StatusUnauthorized, "admin token required")
+				return
+			}
+		} else {
+			host, _, _ := net.SplitHostPort(r.RemoteAddr)
+			if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+				writeError(w, http.StatusForbidden, "admin API is loopback-only without an admin token")
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": s.sched.Status()})
+}
+
+func (s *Server) handleJobRun(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/admin/jobs/"), "/")
+	if action != "run" {
+		writeError(w, http.StatusNotFound, "use POST /v1/admin/jobs/{name}/run")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	if err := s.sched.RunNow(name); err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, ErrJobRunning) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"started": name})
+}
+
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"enabled": Profiling(),
+			"since":   ProfileSince().UTC().Format(time.RFC3339),
+			"phases":  PhaseTimings(),
+		})
+	case http.MethodDelete:
+		ResetPhaseTimings()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "use GET or DELETE")
+	}
+}
+
+// reloadRequest is the body of POST /v1/admin/reload
+type reloadRequest struct {
+	Weights string `json:"weights"`
+	Model   string `json:"model"`  // pool model to reload ("" = default)
+	Deltas  bool   `json:"deltas"` // re-read changed delta files instead
+}
+
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var req reloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad request: "+err.Error())
+		return
+	}
+	if req.Weights == "" && !req.Deltas {
+		writeError(w, http.StatusBadRequest, "weights required")
+		return
+	}
+	y, err := s.model(req.Model)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if req.Deltas {
+		names, err := y.ReloadDelta()
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"reloaded": names, "deltas": y.Deltas()})
+		return
+	}
+	// Generations keep running on the old weights while this loads
+	info, err := y.Reload(req.Weights)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// describeSession summarizes sess, with the transcript if asked
+func describeSession(sess *Session, transcript bool) sessionInfo {
+	turns := sess.Turns()
+	info := sessionInfo{
+		ID:           sess.ID,
+		Namespace:    sess.Namespace,
+		Created:      sess.Created,
+		LastUsed:     sess.LastUsed(),
+		Turns:        len(turns),
+		CachedTokens: sess.CachedTokens(),
+	}
+	if transcript {
+		info.Transcript = turns
+	}
+	return info
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
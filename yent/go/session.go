@@ -0,0 +1,146 @@
+package yent
+
+// session.go — Stateful conversations over a stateless engine
+//
+// Every generate call starts from an empty cache and the shared AMK field.
+// A Session carries what a conversation needs between calls:
+//
+//   transcript — earlier turns, replayed ahead of the question in training
+//                format; the oldest drop out once they pass half the context
+//   KV cache   — rows for the tokens already seen, so the next turn only
+//                feeds what is new (common prefix with the cached tokens)
+//   AMK field  — the session's own pain, debt, velocity; the shared field
+//                is parked during the call and put back afterwards
+//   namespace  — LIMPHA memories stored and retrieved under the session
+//
+// A session's KV rows cost layers × kv_dim × 8 bytes per token (about
+// 57 KB/token on 1.5B), so servers expire idle sessions.
+
+import (
+	"sync"
+	"time"
+)
+
+// SessionTurn is one question and answer in a session
+type SessionTurn struct {
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+}
+
+// Session is one stateful conversation (see GenerateOptions.Session)
+type Session struct {
+	ID        string
+	Namespace string // LIMPHA memory namespace
+	Created   time.Time
+
+	mu       sync.Mutex
+	turns    []SessionTurn
+	tokens   []int       // tokens whose KV rows are cached
+	kv       *KVRows     // cached rows [0, len(tokens))
+	kvModel  *LlamaModel // the model kv belongs to (pooled sessions switch)
+	kvEmbed  embedDelta  // embedding delta kv was computed under (zero = none)
+	amk      *AMKSnapshot
+	lastUsed time.Time
+}
+
+// NewSession creates an empty session; its memories live in namespace "session:<id>"
+func NewSession(id string) *Session {
+	now := time.Now()
+	return &Session{ID: id, Namespace: "session:" + id, Created: now, lastUsed: now}
+}
+
+// Turns returns a copy of the transcript
+func (s *Session) Turns() []SessionTurn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SessionTurn(nil), s.turns...)
+}
+
+// LastUsed returns when the session last generated
+func (s *Session) LastUsed() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastUsed
+}
+
+// CachedTokens returns how many tokens have KV rows cached
+func (s *Session) CachedTokens() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.kv == nil {
+		return 0
+	}
+	return len(s.tokens)
+}
+
+// Reset forgets the transcript, cache and field (the namespace keeps its memories)
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.turns, s.tokens, s.kv, s.kvModel, s.amk = nil, nil, nil, nil, nil
+	s.kvEmbed = embedDelta{}
+}
+
+// sessionTranscript renders past turns in training format, dropping the
+// oldest until the rest fit in half the context
+func (y *Yent) sessionTranscript(s *Session) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	budget := y.model.Config.SeqLen / 2
+	render := func(turns []SessionTurn) string {
+		var text string
+		for _, t := range turns {
+			text += "### Question: " + t.Prompt + "\n### Answer:" + t.Response + "\n"
+		}
+		return text
+	}
+	for len(s.turns) > 0 {
+		text := render(s.turns)
+		if y.CountTokens(text) <= budget {
+			return text
+		}
+		s.turns = s.turns[1:]
+	}
+	return ""
+}
+
+// resumeSession restores the cached rows shared with tokens and returns the
+// position to continue from (at least one token is always left to feed).
+// An embedding delta shifts every row, so rows computed under another
+// delta or alpha are not reused.
+func (y *Yent) resumeSession(s *Session, tokens []int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.kv == nil || s.kvModel != y.model || s.kvEmbed != y.model.embedShift() {
+		return 0
+	}
+	n := 0
+	for n < len(s.tokens) && n < len(tokens)-1 && s.tokens[n] == tokens[n] {
+		n++
+	}
+	if n > 0 {
+		y.model.RestoreKV(s.kv)
+	}
+	return n
+}
+
+// finishSession caches the rows for fed (when they still line up with their
+// positions) and records the turn when it completed
+func (y *Yent) finishSession(s *Session, fed []int, cacheValid bool, turn *SessionTurn) {
+	var kv *KVRows
+	if cacheValid && len(fed) > 0 {
+		kv = y.model.SaveKV(0, len(fed))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if kv != nil {
+		s.tokens, s.kv, s.kvModel, s.kvEmbed = fed, kv, y.model, y.model.embedShift()
+	} else {
+		s.tokens, s.kv, s.kvModel, s.kvEmbed = nil, nil, nil, embedDelta{}
+	}
+	if turn != nil {
+		s.turns = append(s.turns, *turn)
+	}
+	s.lastUsed = time.Now()
+}
@@ -0,0 +1,675 @@
+package yent
+
+// shards.go — Experience export: LIMPHA conversations → fine-tune shards
+//
+// live → shard → retrain → evolve.
+//
+// LIMPHA remembers every turn. ExportShards pulls a window of that memory,
+// formats it in the training template (### Question / ### Answer), and
+// writes an archive the finetune run can be reproduced from:
+//
+//   shards.jsonl            all pairs (or train.jsonl + val.jsonl when split)
+//   stats.json              token length histogram, language mix, dedup count
+//   manifest.json           config used, file hashes, record counts
+//
+// Token lengths use Yent's own tokenizer, which is why export lives in Go.
+//
+// Curriculum ordering: pairs inside each file can be sorted by a field signal
+// recorded at generation time instead of chronologically — for experiments on
+// whether the order of experience matters when the delta is retrained.
+//
+//   coherence  rising   1 - (0.5·pain + 0.3·tension + 0.2·min(debt, 1))
+//   entropy    falling  mean sampling entropy of the response (nats/token)
+//   quality    rising   LIMPHA quality score
+//
+// Both coherence and entropy run chaos → order. The key is emitted per record
+// (curriculum_key) and described in the manifest.
+//
+// Incremental export: ExportShardsIncremental advances a high-water mark
+// (last conversation id it looked at) for its target in
+// ~/.yent/shards/marks.json, so nightly jobs only get experience they haven't
+// seen. Plain exports leave the mark alone.
+//
+// Seed overlap: with SeedDataset set, pairs whose prompt appears in the
+// original training set are dropped (see contamination.go).
+//
+// Formats (ShardConfig.Format) for training outside this repo:
+//
+//   finetune_v2  {"question","answer","id","alpha","quality"}   (default)
+//   openai       {"messages":[{"role":"user"},{"role":"assistant"}]}
+//                OpenAI fine-tune JSONL; Axolotl chat_template reads it as is
+//   sharegpt     {"conversations":[{"from":"human"},{"from":"gpt"}]}
+//   alpaca       {"instruction","input","output"}
+//
+// sharegpt and alpaca also get a dataset_info.json so LLaMA-Factory can
+// load the directory directly.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Shard export formats
+const (
+	ShardFormatFinetuneV2 = "finetune_v2" // native: one JSON object per pair
+	ShardFormatOpenAI     = "openai"      // OpenAI fine-tune / Axolotl chat_template
+	ShardFormatShareGPT   = "sharegpt"    // LLaMA-Factory / Axolotl sharegpt
+	ShardFormatAlpaca     = "alpaca"      // LLaMA-Factory / Axolotl alpaca
+)
+
+// Shard ordering modes
+const (
+	ShardOrderChronological = "chronological"
+	ShardOrderCoherence     = "coherence"
+	ShardOrderEntropy       = "entropy"
+	ShardOrderQuality       = "quality"
+)
+
+// shardOrderings describes each curriculum key: formula and default direction
+var shardOrderings = map[string]ShardOrdering{
+	ShardOrderChronological: {Mode: ShardOrderChronological, Key: "timestamp", Direction: "ascending"},
+	ShardOrderCoherence:     {Mode: ShardOrderCoherence, Key: "1 - (0.5*pain + 0.3*tension + 0.2*min(debt, 1))", Direction: "ascending"},
+	ShardOrderEntropy:       {Mode: ShardOrderEntropy, Key: "mean sampling entropy (nats/token)", Direction: "descending"},
+	ShardOrderQuality:       {Mode: ShardOrderQuality, Key: "limpha quality", Direction: "ascending"},
+}
+
+// ShardOrdering documents how pairs inside each file are ordered
+type ShardOrdering struct {
+	Mode      string `json:"mode"`
+	Key       string `json:"key"`
+	Direction string `json:"direction"`
+}
+
+// ShardConfig controls experience export
+type ShardConfig struct {
+	OutDir      string  `json:"out_dir"`      // output directory ("" = ~/.yent/shards/<timestamp>)
+	WindowDays  float64 `json:"window_days"`  // only turns from the last N days (0 = everything)
+	MinQuality  float32 `json:"min_quality"`  // LIMPHA quality floor
+	Limit       int     `json:"limit"`        // max turns pulled from LIMPHA
+	ValFraction float64 `json:"val_fraction"` // validation share (0 = no split, single shards.jsonl)
+	Seed        int64   `json:"seed"`         // split shuffle seed — same seed, same split
+	Dedup       bool    `json:"dedup"`        // drop exact duplicate pairs (normalized)
+	Stats       bool    `json:"stats"`        // write stats.json sidecar
+	Order       string  `json:"order"`        // curriculum ordering ("" = chronological)
+	Reverse     bool    `json:"reverse"`      // flip the ordering's default direction
+	Target      string  `json:"target"`       // export target for high-water marks ("" = "default")
+	SeedDataset string  `json:"seed_dataset"` // drop pairs whose prompt is in this training set ("" = keep all)
+	Format      string  `json:"format"`       // record format ("" = finetune_v2)
+}
+
+// ShardMark is the high-water mark of one export target
+type ShardMark struct {
+	LastID     int64  `json:"last_id"`
+	ExportedAt string `json:"exported_at"`
+	Dir        string `json:"dir"`
+}
+
+// DefaultShardConfig returns the nightly export defaults
+func DefaultShardConfig() ShardConfig {
+	return ShardConfig{
+		WindowDays:  30,
+		MinQuality:  0.5,
+		Limit:       10000,
+		ValFraction: 0.05,
+		Seed:        1,
+		Dedup:       true,
+		Stats:       true,
+	}
+}
+
+// ShardRecord is one training pair in finetune_v2 format
+type ShardRecord struct {
+	Question string  `json:"question"`
+	Answer   string  `json:"answer"`
+	ID       int64   `json:"id"`
+	Alpha    float32 `json:"alpha"`
+	Quality  float32 `json:"quality"`
+
+	// CurriculumKey is the ordering signal (nil for chronological export)
+	CurriculumKey *float64 `json:"curriculum_key,omitempty"`
+}
+
+// ShardStats describes an exported archive
+type ShardStats struct {
+	Pairs          int            `json:"pairs"`
+	Train          int            `json:"train"`
+	Val            int            `json:"val"`
+	Duplicates     int            `json:"duplicates"`
+	Contaminated   int            `json:"contaminated"` // dropped: prompt found in the seed dataset
+	MeanTokens     float64        `json:"mean_tokens"`
+	MaxTokens      int            `json:"max_tokens"`
+	TokenHistogram map[string]int `json:"token_histogram"` // prompt+answer tokens per pair
+	LanguageMix    map[string]int `json:"language_mix"`    // stored prompt language per pair, else dominant script
+}
+
+// ShardFile describes one file in the archive
+type ShardFile struct {
+	Records int    `json:"records"`
+	SHA256  string `json:"sha256"`
+}
+
+// ShardManifest records everything needed to reproduce a finetune run
+type ShardManifest struct {
+	Version   int                  `json:"version"`
+	CreatedAt string               `json:"created_at"`
+	Format    string               `json:"format"`
+	Dir       string               `json:"dir"`
+	Config    ShardConfig          `json:"config"`
+	SinceID   int64                `json:"since_id"`
+	Ordering  ShardOrdering        `json:"ordering"`
+	Model     map[string]int       `json:"model"`
+	FirstID   int64                `json:"first_id"`
+	LastID    int64                `json:"last_id"`
+	Files     map[string]ShardFile `json:"files"`
+	Stats     *ShardStats          `json:"stats,omitempty"`
+}
+
+// tokenBuckets are histogram bucket upper bounds (exclusive)
+var tokenBuckets = []int{32, 64, 128, 256, 512, 1024}
+
+// ExportShards writes LIMPHA experience to a fine-tune-ready archive
+func (y *Yent) ExportShards(cfg ShardConfig) (*ShardManifest, error) {
+	return y.ExportShardsSince(0, cfg)
+}
+
+// ExportShardsIncremental exports only conversations newer than the
+// target's high-water mark, then moves the mark past every conversation it
+// looked at. Turns dropped there (under MinQuality, outside WindowDays,
+// duplicates, seed overlap) stay behind the mark: a later incremental
+// export with a lower floor will not pick them up.
+func (y *Yent) ExportShardsIncremental(cfg ShardConfig) (*ShardManifest, error) {
+	target := cfg.Target
+	if target == "" {
+		target = "default"
+	}
+	mark, err := LoadShardMark(target)
+	if err != nil {
+		return nil, err
+	}
+	manifest, seen, err := y.exportShards(mark.LastID, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if seen > mark.LastID {
+		next := ShardMark{LastID: seen, ExportedAt: manifest.CreatedAt, Dir: manifest.Dir}
+		if err := saveShardMark(target, next); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// ExportShardsSince exports conversations with id > lastMark (marks untouched)
+func (y *Yent) ExportShardsSince(lastMark int64, cfg ShardConfig) (*ShardManifest, error) {
+	manifest, _, err := y.exportShards(lastMark, cfg)
+	return manifest, err
+}
+
+// exportShards writes the archive and returns the last conversation id it
+// looked at (0 = none)
+func (y *Yent) exportShards(lastMark int64, cfg ShardConfig) (*ShardManifest, int64, error) {
+	// The lock only covers what is read off the instance: LIMPHA and the
+	// files below must not hold up generation
+	y.mu.Lock()
+	limpha, tokenizer, model := y.limpha, y.tokenizer, y.model
+	y.mu.Unlock()
+
+	if limpha == nil {
+		return nil, 0, fmt.Errorf("limpha not available")
+	}
+	if tokenizer == nil || model == nil {
+		return nil, 0, fmt.Errorf("yent not initialized")
+	}
+	if cfg.ValFraction < 0 || cfg.ValFraction >= 1 {
+		return nil, 0, fmt.Errorf("val fraction %.2f out of range [0, 1)", cfg.ValFraction)
+	}
+	if cfg.Limit <= 0 {
+		cfg.Limit = 10000
+	}
+	if cfg.Order == "" {
+		cfg.Order = ShardOrderChronological
+	}
+	if cfg.Target == "" {
+		cfg.Target = "default"
+	}
+	ordering, ok := shardOrderings[cfg.Order]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown shard order %q (chronological, coherence, entropy, quality)", cfg.Order)
+	}
+	if cfg.Format == "" {
+		cfg.Format = ShardFormatFinetuneV2
+	}
+	switch cfg.Format {
+	case ShardFormatFinetuneV2, ShardFormatOpenAI, ShardFormatShareGPT, ShardFormatAlpaca:
+	default:
+		return nil, 0, fmt.Errorf("unknown shard format %q (finetune_v2, openai, sharegpt, alpaca)", cfg.Format)
+	}
+	if cfg.Reverse {
+		if ordering.Direction == "ascending" {
+			ordering.Direction = "descending"
+		} else {
+			ordering.Direction = "ascending"
+		}
+	}
+
+	var seed *SeedIndex
+	if cfg.SeedDataset != "" {
+		idx, err := LoadSeedIndex(cfg.SeedDataset)
+		if err != nil {
+			return nil, 0, err
+		}
+		seed = idx
+	}
+
+	convs, err := limpha.ExportRows(cfg.WindowDays, cfg.MinQuality, cfg.Limit, lastMark)
+	if err != nil {
+		return nil, 0, fmt.Errorf("fetch rows: %w", err)
+	}
+
+	now := time.Now()
+	if cfg.OutDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, 0, fmt.Errorf("home dir: %w", err)
+		}
+		cfg.OutDir = filepath.Join(homeDir, ".yent", "shards", now.Format("20060102-150405"))
+	}
+	if err := os.MkdirAll(cfg.OutDir, 0755); err != nil {
+		return nil, 0, fmt.Errorf("create shard dir: %w", err)
+	}
+
+	stats := &ShardStats{
+		TokenHistogram: make(map[string]int),
+		LanguageMix:    make(map[string]int),
+	}
+
+	// Build records, dropping empties and (optionally) duplicates
+	seen := make(map[string]bool)
+	records := make([]ShardRecord, 0, len(convs))
+	var totalTokens int
+	for _, c := range convs {
+		if strings.TrimSpace(c.Response) == "" {
+			continue
+		}
+		if cfg.Dedup {
+			key := pairKey(c.Prompt, c.Response)
+			if seen[key] {
+				stats.Duplicates++
+				continue
+			}
+			seen[key] = true
+		}
+		if seed != nil {
+			if hit, _ := seed.Check(c.Prompt, c.Response); hit {
+				stats.Contaminated++
+				continue
+			}
+		}
+		rec := ShardRecord{
+			Question: c.Prompt,
+			Answer:   c.Response,
+			ID:       c.ID,
+			Alpha:    c.Alpha,
+			Quality:  c.Quality,
+		}
+		if cfg.Order != ShardOrderChronological {
+			key := curriculumKey(c, cfg.Order)
+			rec.CurriculumKey = &key
+		}
+		records = append(records, rec)
+
+		n := len(tokenizer.Encode(c.Prompt, false)) + len(tokenizer.Encode(c.Response, false))
+		totalTokens += n
+		if n > stats.MaxTokens {
+			stats.MaxTokens = n
+		}
+		stats.TokenHistogram[tokenBucket(n)]++
+		lang := c.Language
+		if lang == "" {
+			lang = dominantScript(c.Prompt + " " + c.Response) // turns stored before languages were
+		}
+		stats.LanguageMix[lang]++
+	}
+	stats.Pairs = len(records)
+	if stats.Pairs > 0 {
+		stats.MeanTokens = float64(totalTokens) / float64(stats.Pairs)
+	}
+
+	manifest := &ShardManifest{
+		Version:   1,
+		CreatedAt: now.UTC().Format(time.RFC3339),
+		Format:    cfg.Format,
+		Dir:       cfg.OutDir,
+		Config:    cfg,
+		SinceID:   lastMark,
+		Ordering:  ordering,
+		Model: map[string]int{
+			"vocab":  model.Config.VocabSize,
+			"dim":    model.Config.EmbedDim,
+			"layers": model.Config.NumLayers,
+		},
+		Files: make(map[string]ShardFile),
+	}
+	if len(records) > 0 {
+		manifest.FirstID = records[0].ID
+		manifest.LastID = records[len(records)-1].ID
+	}
+
+	// Deterministic split: shuffle indices with the seed, keep chronological order inside each file
+	if cfg.ValFraction > 0 {
+		isVal := make([]bool, len(records))
+		nVal := int(math.Round(float64(len(records)) * cfg.ValFraction))
+		if nVal >= len(records) && len(records) > 1 {
+			nVal = len(records) - 1 // always something to train on
+		}
+		perm := rand.New(rand.NewSource(cfg.Seed)).Perm(len(records))
+		for _, i := range perm[:nVal] {
+			isVal[i] = true
+		}
+		var train, val []ShardRecord
+		for i, r := range records {
+			if isVal[i] {
+				val = append(val, r)
+			} else {
+				train = append(train, r)
+			}
+		}
+		stats.Train, stats.Val = len(train), len(val)
+		orderShardRecords(train, ordering)
+		orderShardRecords(val, ordering)
+		if err := writeShardFile(manifest, "train.jsonl", train); err != nil {
+			return nil, 0, err
+		}
+		if err := writeShardFile(manifest, "val.jsonl", val); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		stats.Train = len(records)
+		orderShardRecords(records, ordering)
+		if err := writeShardFile(manifest, "shards.jsonl", records); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if err := writeDatasetInfo(manifest); err != nil {
+		return nil, 0, err
+	}
+
+	if cfg.Stats {
+		manifest.Stats = stats
+		if err := writeJSONFile(filepath.Join(cfg.OutDir, "stats.json"), stats); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := writeJSONFile(filepath.Join(cfg.OutDir, "manifest.json"), manifest); err != nil {
+		return nil, 0, err
+	}
+
+	// Everything looked at, including dropped duplicates, is past the mark
+	var lastSeen int64
+	if len(convs) > 0 {
+		lastSeen = convs[len(convs)-1].ID
+	}
+
+	fmt.Printf("[shards] exported %d pairs (%d train, %d val, %d duplicates, %d contaminated) → %s\n",
+		stats.Pairs, stats.Train, stats.Val, stats.Duplicates, stats.Contaminated, cfg.OutDir)
+	y.emit(EventEpisodeCreated, "", func() map[string]interface{} {
+		return map[string]interface{}{
+			"dir": cfg.OutDir, "target": cfg.Target, "format": cfg.Format,
+			"pairs": stats.Pairs, "train": stats.Train, "val": stats.Val,
+			"first_id": manifest.FirstID, "last_id": manifest.LastID,
+		}
+	})
+	return manifest, lastSeen, nil
+}
+
+// shardMarksPath returns ~/.yent/shards/marks.json
+func shardMarksPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("home dir: %w", err)
+	}
+	return filepath.Join(homeDir, ".yent", "shards", "marks.json"), nil
+}
+
+// loadShardMarks reads all targets' marks (empty map if none yet)
+func loadShardMarks() (map[string]ShardMark, error) {
+	path, err := shardMarksPath()
+	if err != nil {
+		return nil, err
+	}
+	marks := make(map[string]ShardMark)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return marks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read shard marks: %w", err)
+	}
+	if err := json.Unmarshal(data, &marks); err != nil {
+		return nil, fmt.Errorf("parse shard marks: %w", err)
+	}
+	return marks, nil
+}
+
+// LoadShardMark returns the high-water mark for an export target
+// (zero mark if the target has never been exported)
+func LoadShardMark(target string) (ShardMark, error) {
+	if target == "" {
+		target = "default"
+	}
+	marks, err := loadShardMarks()
+	if err != nil {
+		return ShardMark{}, err
+	}
+	return marks[target], nil
+}
+
+// saveShardMark records a target's high-water mark
+func saveShardMark(target string, mark ShardMark) error {
+	marks, err := loadShardMarks()
+	if err != nil {
+		return err
+	}
+	marks[target] = mark
+	path, err := shardMarksPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create shard dir: %w", err)
+	}
+	return writeJSONFile(path, marks)
+}
+
+// curriculumKey computes the ordering signal for a conversation
+func curriculumKey(c LimphaConversation, order string) float64 {
+	switch order {
+	case ShardOrderCoherence:
+		return 1 - (0.5*float64(c.Pain) + 0.3*float64(c.Tension) + 0.2*math.Min(float64(c.Debt), 1))
+	case ShardOrderEntropy:
+		return float64(c.Entropy)
+	case ShardOrderQuality:
+		return float64(c.Quality)
+	default:
+		return c.Timestamp
+	}
+}
+
+// orderShardRecords sorts records by curriculum key (stable: ties stay chronological)
+func orderShardRecords(records []ShardRecord, o ShardOrdering) {
+	if o.Mode == ShardOrderChronological {
+		if o.Direction == "descending" {
+			for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+				records[i], records[j] = records[j], records[i]
+			}
+		}
+		return
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := *records[i].CurriculumKey, *records[j].CurriculumKey
+		if o.Direction == "descending" {
+			return a > b
+		}
+		return a < b
+	})
+}
+
+// writeShardFile writes records as JSONL and registers the file in the manifest
+func writeShardFile(m *ShardManifest, name string, records []ShardRecord) error {
+	var sb strings.Builder
+	for _, r := range records {
+		line, err := json.Marshal(formatShardRecord(r, m.Format))
+		if err != nil {
+			return fmt.Errorf("marshal shard record %d: %w", r.ID, err)
+		}
+		sb.Write(line)
+		sb.WriteByte('\n')
+	}
+	data := []byte(sb.String())
+	if err := os.WriteFile(filepath.Join(m.Dir, name), data, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	sum := sha256.Sum256(data)
+	m.Files[name] = ShardFile{Records: len(records), SHA256: hex.EncodeToString(sum[:])}
+	return nil
+}
+
+// chatMessage is one turn in openai format
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// shareGPTTurn is one turn in sharegpt format
+type shareGPTTurn struct {
+	From  string `json:"from"`
+	Value string `json:"value"`
+}
+
+// formatShardRecord shapes a record for the export format
+func formatShardRecord(r ShardRecord, format string) interface{} {
+	switch format {
+	case ShardFormatOpenAI:
+		return map[string][]chatMessage{"messages": {
+			{Role: "user", Content: r.Question},
+			{Role: "assistant", Content: r.Answer},
+		}}
+	case ShardFormatShareGPT:
+		return map[string][]shareGPTTurn{"conversations": {
+			{From: "human", Value: r.Question},
+			{From: "gpt", Value: r.Answer},
+		}}
+	case ShardFormatAlpaca:
+		return map[string]string{"instruction": r.Question, "input": "", "output": r.Answer}
+	default:
+		return r
+	}
+}
+
+// writeDatasetInfo writes LLaMA-Factory's dataset_info.json for sharegpt and
+// alpaca exports, one dataset per shard file ("yent_train", "yent_val", ...)
+func writeDatasetInfo(m *ShardManifest) error {
+	var entry func(file string) map[string]interface{}
+	switch m.Format {
+	case ShardFormatShareGPT:
+		entry = func(file string) map[string]interface{} {
+			return map[string]interface{}{
+				"file_name":  file,
+				"formatting": "sharegpt",
+				"columns":    map[string]string{"messages": "conversations"},
+			}
+		}
+	case ShardFormatAlpaca:
+		entry = func(file string) map[string]interface{} {
+			return map[string]interface{}{
+				"file_name": file,
+				"columns":   map[string]string{"prompt": "instruction", "query": "input", "response": "output"},
+			}
+		}
+	default:
+		return nil
+	}
+	info := make(map[string]interface{})
+	for file := range m.Files {
+		info["yent_"+strings.TrimSuffix(file, ".jsonl")] = entry(file)
+	}
+	return writeJSONFile(filepath.Join(m.Dir, "dataset_info.json"), info)
+}
+
+// writeJSONFile writes v as indented JSON
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// pairKey normalizes a prompt/response pair for duplicate detection
+func pairKey(prompt, response string) string {
+	norm := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	}
+	sum := sha256.Sum256([]byte(norm(prompt) + "\x00" + norm(response)))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenBucket returns the histogram bucket label for a token count
+func tokenBucket(n int) string {
+	lo := 0
+	for _, hi := range tokenBuckets {
+		if n < hi {
+			return fmt.Sprintf("%d-%d", lo, hi-1)
+		}
+		lo = hi
+	}
+	return fmt.Sprintf("%d+", lo)
+}
+
+// dominantScript returns the most frequent writing system among letters in s
+func dominantScript(s string) string {
+	counts := make(map[string]int)
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			counts["latin"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["cyrillic"]++
+		case unicode.Is(unicode.Han, r), unicode.Is(unicode.Hiragana, r),
+			unicode.Is(unicode.Katakana, r), unicode.Is(unicode.Hangul, r):
+			counts["cjk"]++
+		case unicode.Is(unicode.Arabic, r):
+			counts["arabic"]++
+		case unicode.Is(unicode.Hebrew, r):
+			counts["hebrew"]++
+		case unicode.Is(unicode.Greek, r):
+			counts["greek"]++
+		default:
+			counts["other"]++
+		}
+	}
+	best, bestN := "none", 0
+	for script, n := range counts {
+		if n > bestN || (n == bestN && script < best) {
+			best, bestN = script, n
+		}
+	}
+	return best
+}
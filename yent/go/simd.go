@@ -0,0 +1,40 @@
+package yent
+
+// simd.go — Vector matmul kernels
+//
+// The scalar loops in quant.go do one multiply-add per instruction. The
+// kernels behind this file do eight (AVX2, simd_amd64.c) or four (NEON,
+// simd_arm64.c) for Q4_0, Q8_0 and Q6_K weights, the formats a GGUF of
+// Yent is made of, and for F16 weights, converted by the hardware. They
+// are C with compiler intrinsics, compiled by cgo like the AMK kernel:
+// Go's assembler would need every instruction spelled out by hand.
+//
+// The scalar path stays the fallback, and the reference the kernels are
+// tested against (tests/simd_test.go): it runs on other architectures,
+// on amd64 CPUs without AVX2/FMA/F16C, under the purego build tag, and
+// after SetSIMD(false). Results differ from scalar by float rounding only
+// (a different summation order).
+
+// useSIMD routes the matmul range functions to the vector kernels
+var useSIMD = simdKernels != ""
+
+// SIMD names the matmul kernels in use: "avx2", "neon" or "scalar"
+func SIMD() string {
+	if useSIMD {
+		return simdKernels
+	}
+	return "scalar"
+}
+
+// SetSIMD switches the vector kernels on or off and reports whether they
+// are on (they stay off where unsupported). Not safe during a generation.
+func SetSIMD(on bool) bool {
+	useSIMD = on && simdKernels != ""
+	return useSIMD
+}
+
+// simdFits checks a range against its buffers, so a malformed tensor
+// panics in the scalar kernel instead of reading past the end in C
+func simdFits(out []float32, w []byte, x []float32, end, blocks, blockElems, bytesPerRow int) bool {
+	return end > 0 && len(out) >= end && len(w) >= end*bytesPerRow && len(x) >= blocks*blockElems
+}
@@ -0,0 +1,25 @@
+// simd.h — Vector matmul kernels (simd_amd64.c, simd_arm64.c)
+//
+// Each kernel computes out[i] = row i of w · x for rows [start, end), with
+// the same block layouts as quant.go. Bounds are checked on the Go side.
+
+#ifndef YENT_SIMD_H
+#define YENT_SIMD_H
+
+#include <stdint.h>
+
+// yent_simd_supported returns 1 when the CPU runs the kernels below
+int yent_simd_supported(void);
+
+void yent_q4_0_rows(float *out, const uint8_t *w, const float *x, int start, int end, int blocks, int row_bytes);
+void yent_q8_0_rows(float *out, const uint8_t *w, const float *x, int start, int end, int blocks, int row_bytes);
+void yent_q6_k_rows(float *out, const uint8_t *w, const float *x, int start, int end, int blocks, int row_bytes);
+
+// F16 rows of cols halves, converted 8 at a time (amd64) or 4 (arm64)
+void yent_f16_rows(float *out, const uint8_t *w, const float *x, int start, int end, int cols);
+
+// The same against x quantized to int8 blocks of 32, scales xd (q8act.go)
+void yent_q4_0_q8_rows(float *out, const uint8_t *w, const int8_t *xq, const float *xd, int start, int end, int blocks, int row_bytes);
+void yent_q8_0_q8_rows(float *out, const uint8_t *w, const int8_t *xq, const float *xd, int start, int end, int blocks, int row_bytes);
+
+#endif
@@ -0,0 +1,187 @@
+//go:build !purego
+
+// simd_amd64.c — AVX2/FMA/F16C matmul kernels
+//
+// Compiled for every amd64 CPU but only called when yent_simd_supported
+// says the instructions exist: the kernels carry their own target
+// attribute, so the rest of the binary stays baseline x86-64.
+//
+// Quants are widened int8 → int32 → float 8 at a time and multiplied into
+// x with FMA; the block scale is applied once per block (per 16-value
+// group for Q6_K), as in the scalar kernels. F16 weights need no scale:
+// vcvtph2ps turns eight halves into floats in one instruction.
+//
+// Against int8 activations (q8act.go) the products stay integer: maddubs
+// multiplies 32 byte pairs and sums neighbours, madd folds them to int32.
+
+#include <immintrin.h>
+#include <string.h>
+#include "simd.h"
+
+#define YENT_AVX2 __attribute__((target("avx2,fma,f16c")))
+
+int yent_simd_supported(void) {
+	__builtin_cpu_init();
+	return __builtin_cpu_supports("avx2") && __builtin_cpu_supports("fma") && __builtin_cpu_supports("f16c");
+}
+
+YENT_AVX2 static inline float half_to_float(const uint8_t *p) {
+	uint16_t h;
+	memcpy(&h, p, 2);
+	return _cvtsh_ss(h);
+}
+
+YENT_AVX2 static inline float hsum(__m256 v) {
+	__m128 s = _mm_add_ps(_mm256_castps256_ps128(v), _mm256_extractf128_ps(v, 1));
+	s = _mm_add_ps(s, _mm_movehl_ps(s, s));
+	s = _mm_add_ss(s, _mm_movehdup_ps(s));
+	return _mm_cvtss_f32(s);
+}
+
+// fma16 adds 16 int8 quants times x[0:16] to acc
+YENT_AVX2 static inline __m256 fma16(__m256 acc, __m128i q, const float *x) {
+	__m256 lo = _mm256_cvtepi32_ps(_mm256_cvtepi8_epi32(q));
+	__m256 hi = _mm256_cvtepi32_ps(_mm256_cvtepi8_epi32(_mm_srli_si128(q, 8)));
+	acc = _mm256_fmadd_ps(lo, _mm256_loadu_ps(x), acc);
+	return _mm256_fmadd_ps(hi, _mm256_loadu_ps(x + 8), acc);
+}
+
+YENT_AVX2 void yent_q4_0_rows(float *out, const uint8_t *w, const float *x, int start, int end, int blocks, int row_bytes) {
+	const __m128i mask = _mm_set1_epi8(0x0F);
+	const __m128i eight = _mm_set1_epi8(8);
+	for (int i = start; i < end; i++) {
+		const uint8_t *row = w + (size_t)i * row_bytes;
+		__m256 acc = _mm256_setzero_ps();
+		for (int b = 0; b < blocks; b++) {
+			const uint8_t *blk = row + b * 18;
+			const float *xb = x + b * 32;
+			__m128i q = _mm_loadu_si128((const __m128i *)(blk + 2));
+			__m128i lo = _mm_sub_epi8(_mm_and_si128(q, mask), eight);
+			__m128i hi = _mm_sub_epi8(_mm_and_si128(_mm_srli_epi16(q, 4), mask), eight);
+			__m256 dot = fma16(_mm256_setzero_ps(), lo, xb);
+			dot = fma16(dot, hi, xb + 16);
+			acc = _mm256_fmadd_ps(dot, _mm256_set1_ps(half_to_float(blk)), acc);
+		}
+		out[i] = hsum(acc);
+	}
+}
+
+YENT_AVX2 void yent_q8_0_rows(float *out, const uint8_t *w, const float *x, int start, int end, int blocks, int row_bytes) {
+	for (int i = start; i < end; i++) {
+		const uint8_t *row = w + (size_t)i * row_bytes;
+		__m256 acc = _mm256_setzero_ps();
+		for (int b = 0; b < blocks; b++) {
+			const uint8_t *blk = row + b * 34;
+			const float *xb = x + b * 32;
+			__m256 dot = fma16(_mm256_setzero_ps(), _mm_loadu_si128((const __m128i *)(blk + 2)), xb);
+			dot = fma16(dot, _mm_loadu_si128((const __m128i *)(blk + 18)), xb + 16);
+			acc = _mm256_fmadd_ps(dot, _mm256_set1_ps(half_to_float(blk)), acc);
+		}
+		out[i] = hsum(acc);
+	}
+}
+
+// Q6_K: each 128-value half of a super-block is four interleaved groups of
+// 32 (ql low/high nibble × first/second 32 bytes, two qh bits each); each
+// group splits into two runs of 16 with their own int8 scale.
+YENT_AVX2 void yent_q6_k_rows(float *out, const uint8_t *w, const float *x, int start, int end, int blocks, int row_bytes) {
+	const __m128i mask4 = _mm_set1_epi8(0x0F);
+	const __m128i mask2 = _mm_set1_epi8(0x30);
+	const __m128i bias = _mm_set1_epi8(32);
+	for (int r = start; r < end; r++) {
+		const uint8_t *row = w + (size_t)r * row_bytes;
+		__m256 acc = _mm256_setzero_ps();
+		for (int b = 0; b < blocks; b++) {
+			const uint8_t *blk = row + b * 210;
+			const int8_t *scales = (const int8_t *)(blk + 192);
+			float d = half_to_float(blk + 208);
+			for (int n = 0; n < 2; n++) {
+				const uint8_t *ql = blk + n * 64;
+				const uint8_t *qh = blk + 128 + n * 32;
+				const int8_t *sc = scales + n * 8;
+				const float *xb = x + b * 256 + n * 128;
+				for (int l = 0; l < 32; l += 16) {
+					int is = l / 16;
+					__m128i lo = _mm_loadu_si128((const __m128i *)(ql + l));
+					__m128i lo32 = _mm_loadu_si128((const __m128i *)(ql + l + 32));
+					__m128i h = _mm_loadu_si128((const __m128i *)(qh + l));
+					// 16-bit shifts are fine: every lane is masked to its own byte
+					__m128i q1 = _mm_or_si128(_mm_and_si128(lo, mask4), _mm_and_si128(_mm_slli_epi16(h, 4), mask2));
+					__m128i q2 = _mm_or_si128(_mm_and_si128(lo32, mask4), _mm_and_si128(_mm_slli_epi16(h, 2), mask2));
+					__m128i q3 = _mm_or_si128(_mm_and_si128(_mm_srli_epi16(lo, 4), mask4), _mm_and_si128(h, mask2));
+					__m128i q4 = _mm_or_si128(_mm_and_si128(_mm_srli_epi16(lo32, 4), mask4), _mm_and_si128(_mm_srli_epi16(h, 2), mask2));
+					__m256 s1 = fma16(_mm256_setzero_ps(), _mm_sub_epi8(q1, bias), xb + l);
+					__m256 s2 = fma16(_mm256_setzero_ps(), _mm_sub_epi8(q2, bias), xb + l + 32);
+					__m256 s3 = fma16(_mm256_setzero_ps(), _mm_sub_epi8(q3, bias), xb + l + 64);
+					__m256 s4 = fma16(_mm256_setzero_ps(), _mm_sub_epi8(q4, bias), xb + l + 96);
+					acc = _mm256_fmadd_ps(s1, _mm256_set1_ps(d * sc[is + 0]), acc);
+					acc = _mm256_fmadd_ps(s2, _mm256_set1_ps(d * sc[is + 2]), acc);
+					acc = _mm256_fmadd_ps(s3, _mm256_set1_ps(d * sc[is + 4]), acc);
+					acc = _mm256_fmadd_ps(s4, _mm256_set1_ps(d * sc[is + 6]), acc);
+				}
+			}
+		}
+		out[r] = hsum(acc);
+	}
+}
+
+// F16C converts eight halves per instruction; two accumulators keep two
+// FMA chains in flight
+YENT_AVX2 void yent_f16_rows(float *out, const uint8_t *w, const float *x, int start, int end, int cols) {
+	for (int i = start; i < end; i++) {
+		const uint8_t *row = w + (size_t)i * cols * 2;
+		__m256 acc0 = _mm256_setzero_ps(), acc1 = _mm256_setzero_ps();
+		int j = 0;
+		for (; j + 16 <= cols; j += 16) {
+			__m256 w0 = _mm256_cvtph_ps(_mm_loadu_si128((const __m128i *)(row + j * 2)));
+			__m256 w1 = _mm256_cvtph_ps(_mm_loadu_si128((const __m128i *)(row + j * 2 + 16)));
+			acc0 = _mm256_fmadd_ps(w0, _mm256_loadu_ps(x + j), acc0);
+			acc1 = _mm256_fmadd_ps(w1, _mm256_loadu_ps(x + j + 8), acc1);
+		}
+		float sum = hsum(_mm256_add_ps(acc0, acc1));
+		for (; j < cols; j++) {
+			sum += half_to_float(row + j * 2) * x[j];
+		}
+		out[i] = sum;
+	}
+}
+
+// idot32 sums 32 int8 products into 8 int32 lanes, as floats. maddubs
+// wants one unsigned operand: |q| against x carrying q's sign.
+YENT_AVX2 static inline __m256 idot32(__m256i q, __m256i x) {
+	__m256i p = _mm256_maddubs_epi16(_mm256_sign_epi8(q, q), _mm256_sign_epi8(x, q));
+	return _mm256_cvtepi32_ps(_mm256_madd_epi16(p, _mm256_set1_epi16(1)));
+}
+
+YENT_AVX2 void yent_q4_0_q8_rows(float *out, const uint8_t *w, const int8_t *xq, const float *xd, int start, int end, int blocks, int row_bytes) {
+	const __m128i mask = _mm_set1_epi8(0x0F);
+	const __m256i eight = _mm256_set1_epi8(8);
+	for (int i = start; i < end; i++) {
+		const uint8_t *row = w + (size_t)i * row_bytes;
+		__m256 acc = _mm256_setzero_ps();
+		for (int b = 0; b < blocks; b++) {
+			const uint8_t *blk = row + b * 18;
+			__m128i q = _mm_loadu_si128((const __m128i *)(blk + 2));
+			__m256i qw = _mm256_set_m128i(_mm_and_si128(_mm_srli_epi16(q, 4), mask), _mm_and_si128(q, mask));
+			__m256i qx = _mm256_loadu_si256((const __m256i *)(xq + b * 32));
+			__m256 d = _mm256_set1_ps(half_to_float(blk) * xd[b]);
+			acc = _mm256_fmadd_ps(idot32(_mm256_sub_epi8(qw, eight), qx), d, acc);
+		}
+		out[i] = hsum(acc);
+	}
+}
+
+YENT_AVX2 void yent_q8_0_q8_rows(float *out, const uint8_t *w, const int8_t *xq, const float *xd, int start, int end, int blocks, int row_bytes) {
+	for (int i = start; i < end; i++) {
+		const uint8_t *row = w + (size_t)i * row_bytes;
+		__m256 acc = _mm256_setzero_ps();
+		for (int b = 0; b < blocks; b++) {
+			const uint8_t *blk = row + b * 34;
+			__m256i qw = _mm256_loadu_si256((const __m256i *)(blk + 2));
+			__m256i qx = _mm256_loadu_si256((const __m256i *)(xq + b * 32));
+			__m256 d = _mm256_set1_ps(half_to_float(blk) * xd[b]);
+			acc = _mm256_fmadd_ps(idot32(qw, qx), d, acc);
+		}
+		out[i] = hsum(acc);
+	}
+}
@@ -0,0 +1,77 @@
+//go:build !purego
+
+package yent
+
+/*
+#cgo CFLAGS: -O3
+#include "simd.h"
+*/
+import "C"
+
+// simdKernels is "avx2" when the CPU runs simd_amd64.c
+var simdKernels = func() string {
+	if C.yent_simd_supported() != 0 {
+		return "avx2"
+	}
+	return ""
+}()
+
+// simdMatMulQ4_0 is matMulQ4_0Range in simd_amd64.c; false = use scalar
+func simdMatMulQ4_0(out []float32, w []byte, x []float32, start, end, blocksPerRow, bytesPerRow int) bool {
+	if !simdFits(out, w, x, end, blocksPerRow, q4BlockSize, bytesPerRow) {
+		return false
+	}
+	C.yent_q4_0_rows((*C.float)(&out[0]), (*C.uint8_t)(&w[0]), (*C.float)(&x[0]),
+		C.int(start), C.int(end), C.int(blocksPerRow), C.int(bytesPerRow))
+	return true
+}
+
+// simdMatMulQ8_0 is matMulQ8_0Range in simd_amd64.c; false = use scalar
+func simdMatMulQ8_0(out []float32, w []byte, x []float32, start, end, blocksPerRow, bytesPerRow int) bool {
+	if !simdFits(out, w, x, end, blocksPerRow, q8BlockSize, bytesPerRow) {
+		return false
+	}
+	C.yent_q8_0_rows((*C.float)(&out[0]), (*C.uint8_t)(&w[0]), (*C.float)(&x[0]),
+		C.int(start), C.int(end), C.int(blocksPerRow), C.int(bytesPerRow))
+	return true
+}
+
+// simdMatMulQ6_K is matMulQ6_KRange in simd_amd64.c; false = use scalar
+func simdMatMulQ6_K(out []float32, w []byte, x []float32, start, end, blocksPerRow, bytesPerRow int) bool {
+	if !simdFits(out, w, x, end, blocksPerRow, q6kBlockSize, bytesPerRow) {
+		return false
+	}
+	C.yent_q6_k_rows((*C.float)(&out[0]), (*C.uint8_t)(&w[0]), (*C.float)(&x[0]),
+		C.int(start), C.int(end), C.int(blocksPerRow), C.int(bytesPerRow))
+	return true
+}
+
+// simdMatMulF16 is matMulF16Range in simd_amd64.c; false = use scalar
+func simdMatMulF16(out []float32, w []byte, x []float32, start, end, cols int) bool {
+	if !simdFits(out, w, x, end, cols, 1, cols*2) {
+		return false
+	}
+	C.yent_f16_rows((*C.float)(&out[0]), (*C.uint8_t)(&w[0]), (*C.float)(&x[0]),
+		C.int(start), C.int(end), C.int(cols))
+	return true
+}
+
+// simdMatMulQ4_0Q8 is matMulQ4_0Q8Range in simd_amd64.c; false = use scalar
+func simdMatMulQ4_0Q8(out []float32, w []byte, qa *q8Activations, start, end, blocksPerRow, bytesPerRow int) bool {
+	if !simdFits(out, w, nil, end, 0, 0, bytesPerRow) || len(qa.ds) < blocksPerRow || len(qa.qs) < blocksPerRow*q8BlockSize {
+		return false
+	}
+	C.yent_q4_0_q8_rows((*C.float)(&out[0]), (*C.uint8_t)(&w[0]), (*C.int8_t)(&qa.qs[0]), (*C.float)(&qa.ds[0]),
+		C.int(start), C.int(end), C.int(blocksPerRow), C.int(bytesPerRow))
+	return true
+}
+
+// simdMatMulQ8_0Q8 is matMulQ8_0Q8Range in simd_amd64.c; false = use scalar
+func simdMatMulQ8_0Q8(out []float32, w []byte, qa *q8Activations, start, end, blocksPerRow, bytesPerRow int) bool {
+	if !simdFits(out, w, nil, end, 0, 0, bytesPerRow) || len(qa.ds) < blocksPerRow || len(qa.qs) < blocksPerRow*q8BlockSize {
+		return false
+	}
+	C.yent_q8_0_q8_rows((*C.float)(&out[0]), (*C.uint8_t)(&w[0]), (*C.int8_t)(&qa.qs[0]), (*C.float)(&qa.ds[0]),
+		C.int(start), C.int(end), C.int(blocksPerRow), C.int(bytesPerRow))
+	return true
+}
@@ -0,0 +1,171 @@
+//go:build !purego
+
+// simd_arm64.c — NEON matmul kernels
+//
+// NEON is part of every arm64 CPU, so there is nothing to detect. Same
+// scheme as simd_amd64.c, four lanes at a time: int8 quants widen to
+// int32, convert to float and multiply into x with vfmaq; the block scale
+// is applied once per block (per 16-value group for Q6_K). F16 weights
+// convert four at a time with vcvt.
+//
+// Against int8 activations (q8act.go): vmull_s8 to int16, pairwise
+// accumulate to int32, one float conversion per block.
+
+#include <arm_neon.h>
+#include <string.h>
+#include "simd.h"
+
+int yent_simd_supported(void) {
+	return 1;
+}
+
+static inline float half_to_float(const uint8_t *p) {
+	__fp16 h;
+	memcpy(&h, p, 2);
+	return (float)h;
+}
+
+// fma16 adds 16 int8 quants times x[0:16] to acc
+static inline float32x4_t fma16(float32x4_t acc, int8x16_t q, const float *x) {
+	int16x8_t lo = vmovl_s8(vget_low_s8(q));
+	int16x8_t hi = vmovl_s8(vget_high_s8(q));
+	acc = vfmaq_f32(acc, vcvtq_f32_s32(vmovl_s16(vget_low_s16(lo))), vld1q_f32(x));
+	acc = vfmaq_f32(acc, vcvtq_f32_s32(vmovl_s16(vget_high_s16(lo))), vld1q_f32(x + 4));
+	acc = vfmaq_f32(acc, vcvtq_f32_s32(vmovl_s16(vget_low_s16(hi))), vld1q_f32(x + 8));
+	return vfmaq_f32(acc, vcvtq_f32_s32(vmovl_s16(vget_high_s16(hi))), vld1q_f32(x + 12));
+}
+
+void yent_q4_0_rows(float *out, const uint8_t *w, const float *x, int start, int end, int blocks, int row_bytes) {
+	const uint8x16_t mask = vdupq_n_u8(0x0F);
+	const int8x16_t eight = vdupq_n_s8(8);
+	for (int i = start; i < end; i++) {
+		const uint8_t *row = w + (size_t)i * row_bytes;
+		float32x4_t acc = vdupq_n_f32(0);
+		for (int b = 0; b < blocks; b++) {
+			const uint8_t *blk = row + b * 18;
+			const float *xb = x + b * 32;
+			uint8x16_t q = vld1q_u8(blk + 2);
+			int8x16_t lo = vsubq_s8(vreinterpretq_s8_u8(vandq_u8(q, mask)), eight);
+			int8x16_t hi = vsubq_s8(vreinterpretq_s8_u8(vshrq_n_u8(q, 4)), eight);
+			float32x4_t dot = fma16(vdupq_n_f32(0), lo, xb);
+			dot = fma16(dot, hi, xb + 16);
+			acc = vfmaq_n_f32(acc, dot, half_to_float(blk));
+		}
+		out[i] = vaddvq_f32(acc);
+	}
+}
+
+void yent_q8_0_rows(float *out, const uint8_t *w, const float *x, int start, int end, int blocks, int row_bytes) {
+	for (int i = start; i < end; i++) {
+		const uint8_t *row = w + (size_t)i * row_bytes;
+		float32x4_t acc = vdupq_n_f32(0);
+		for (int b = 0; b < blocks; b++) {
+			const uint8_t *blk = row + b * 34;
+			const float *xb = x + b * 32;
+			float32x4_t dot = fma16(vdupq_n_f32(0), vld1q_s8((const int8_t *)(blk + 2)), xb);
+			dot = fma16(dot, vld1q_s8((const int8_t *)(blk + 18)), xb + 16);
+			acc = vfmaq_n_f32(acc, dot, half_to_float(blk));
+		}
+		out[i] = vaddvq_f32(acc);
+	}
+}
+
+// Q6_K layout: see simd_amd64.c
+void yent_q6_k_rows(float *out, const uint8_t *w, const float *x, int start, int end, int blocks, int row_bytes) {
+	const uint8x16_t mask4 = vdupq_n_u8(0x0F);
+	const uint8x16_t mask2 = vdupq_n_u8(0x30);
+	const int8x16_t bias = vdupq_n_s8(32);
+	for (int r = start; r < end; r++) {
+		const uint8_t *row = w + (size_t)r * row_bytes;
+		float32x4_t acc = vdupq_n_f32(0);
+		for (int b = 0; b < blocks; b++) {
+			const uint8_t *blk = row + b * 210;
+			const int8_t *scales = (const int8_t *)(blk + 192);
+			float d = half_to_float(blk + 208);
+			for (int n = 0; n < 2; n++) {
+				const uint8_t *ql = blk + n * 64;
+				const uint8_t *qh = blk + 128 + n * 32;
+				const int8_t *sc = scales + n * 8;
+				const float *xb = x + b * 256 + n * 128;
+				for (int l = 0; l < 32; l += 16) {
+					int is = l / 16;
+					uint8x16_t lo = vld1q_u8(ql + l);
+					uint8x16_t lo32 = vld1q_u8(ql + l + 32);
+					uint8x16_t h = vld1q_u8(qh + l);
+					uint8x16_t q1 = vorrq_u8(vandq_u8(lo, mask4), vandq_u8(vshlq_n_u8(h, 4), mask2));
+					uint8x16_t q2 = vorrq_u8(vandq_u8(lo32, mask4), vandq_u8(vshlq_n_u8(h, 2), mask2));
+					uint8x16_t q3 = vorrq_u8(vshrq_n_u8(lo, 4), vandq_u8(h, mask2));
+					uint8x16_t q4 = vorrq_u8(vshrq_n_u8(lo32, 4), vandq_u8(vshrq_n_u8(h, 2), mask2));
+					float32x4_t s1 = fma16(vdupq_n_f32(0), vsubq_s8(vreinterpretq_s8_u8(q1), bias), xb + l);
+					float32x4_t s2 = fma16(vdupq_n_f32(0), vsubq_s8(vreinterpretq_s8_u8(q2), bias), xb + l + 32);
+					float32x4_t s3 = fma16(vdupq_n_f32(0), vsubq_s8(vreinterpretq_s8_u8(q3), bias), xb + l + 64);
+					float32x4_t s4 = fma16(vdupq_n_f32(0), vsubq_s8(vreinterpretq_s8_u8(q4), bias), xb + l + 96);
+					acc = vfmaq_n_f32(acc, s1, d * sc[is + 0]);
+					acc = vfmaq_n_f32(acc, s2, d * sc[is + 2]);
+					acc = vfmaq_n_f32(acc, s3, d * sc[is + 4]);
+					acc = vfmaq_n_f32(acc, s4, d * sc[is + 6]);
+				}
+			}
+		}
+		out[r] = vaddvq_f32(acc);
+	}
+}
+
+void yent_f16_rows(float *out, const uint8_t *w, const float *x, int start, int end, int cols) {
+	for (int i = start; i < end; i++) {
+		const uint8_t *row = w + (size_t)i * cols * 2;
+		float32x4_t acc0 = vdupq_n_f32(0), acc1 = vdupq_n_f32(0);
+		int j = 0;
+		for (; j + 8 <= cols; j += 8) {
+			float16x8_t h = vreinterpretq_f16_u8(vld1q_u8(row + j * 2));
+			acc0 = vfmaq_f32(acc0, vcvt_f32_f16(vget_low_f16(h)), vld1q_f32(x + j));
+			acc1 = vfmaq_f32(acc1, vcvt_high_f32_f16(h), vld1q_f32(x + j + 4));
+		}
+		float sum = vaddvq_f32(vaddq_f32(acc0, acc1));
+		for (; j < cols; j++) {
+			sum += half_to_float(row + j * 2) * x[j];
+		}
+		out[i] = sum;
+	}
+}
+
+// idot16 adds 16 int8 products to acc, pairwise into four int32 lanes
+static inline int32x4_t idot16(int32x4_t acc, int8x16_t q, int8x16_t x) {
+	acc = vpadalq_s16(acc, vmull_s8(vget_low_s8(q), vget_low_s8(x)));
+	return vpadalq_s16(acc, vmull_s8(vget_high_s8(q), vget_high_s8(x)));
+}
+
+void yent_q4_0_q8_rows(float *out, const uint8_t *w, const int8_t *xq, const float *xd, int start, int end, int blocks, int row_bytes) {
+	const uint8x16_t mask = vdupq_n_u8(0x0F);
+	const int8x16_t eight = vdupq_n_s8(8);
+	for (int i = start; i < end; i++) {
+		const uint8_t *row = w + (size_t)i * row_bytes;
+		float32x4_t acc = vdupq_n_f32(0);
+		for (int b = 0; b < blocks; b++) {
+			const uint8_t *blk = row + b * 18;
+			const int8_t *xb = xq + b * 32;
+			uint8x16_t q = vld1q_u8(blk + 2);
+			int8x16_t lo = vsubq_s8(vreinterpretq_s8_u8(vandq_u8(q, mask)), eight);
+			int8x16_t hi = vsubq_s8(vreinterpretq_s8_u8(vshrq_n_u8(q, 4)), eight);
+			int32x4_t dot = idot16(vdupq_n_s32(0), lo, vld1q_s8(xb));
+			dot = idot16(dot, hi, vld1q_s8(xb + 16));
+			acc = vfmaq_n_f32(acc, vcvtq_f32_s32(dot), half_to_float(blk) * xd[b]);
+		}
+		out[i] = vaddvq_f32(acc);
+	}
+}
+
+void yent_q8_0_q8_rows(float *out, const uint8_t *w, const int8_t *xq, const float *xd, int start, int end, int blocks, int row_bytes) {
+	for (int i = start; i < end; i++) {
+		const uint8_t *row = w + (size_t)i * row_bytes;
+		float32x4_t acc = vdupq_n_f32(0);
+		for (int b = 0; b < blocks; b++) {
+			const uint8_t *blk = row + b * 34;
+			const int8_t *xb = xq + b * 32;
+			int32x4_t dot = idot16(vdupq_n_s32(0), vld1q_s8((const int8_t *)(blk + 2)), vld1q_s8(xb));
+			dot = idot16(dot, vld1q_s8((const int8_t *)(blk + 18)), vld1q_s8(xb + 16));
+			acc = vfmaq_n_f32(acc, vcvtq_f32_s32(dot), half_to_float(blk) * xd[b]);
+		}
+		out[i] = vaddvq_f32(acc);
+	}
+}
@@ -0,0 +1,30 @@
+//go:build purego || !(amd64 || arm64)
+
+package yent
+
+// simdKernels is empty: the scalar kernels in quant.go run everywhere
+var simdKernels = ""
+
+func simdMatMulQ4_0(out []float32, w []byte, x []float32, start, end, blocksPerRow, bytesPerRow int) bool {
+	return false
+}
+
+func simdMatMulQ8_0(out []float32, w []byte, x []float32, start, end, blocksPerRow, bytesPerRow int) bool {
+	return false
+}
+
+func simdMatMulQ6_K(out []float32, w []byte, x []float32, start, end, blocksPerRow, bytesPerRow int) bool {
+	return false
+}
+
+func simdMatMulF16(out []float32, w []byte, x []float32, start, end, cols int) bool {
+	return false
+}
+
+func simdMatMulQ4_0Q8(out []float32, w []byte, qa *q8Activations, start, end, blocksPerRow, bytesPerRow int) bool {
+	return false
+}
+
+func simdMatMulQ8_0Q8(out []float32, w []byte, qa *q8Activations, start, end, blocksPerRow, bytesPerRow int) bool {
+	return false
+}
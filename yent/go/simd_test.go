@@ -0,0 +1,33 @@
+package yent
+
+import "testing"
+
+// TestSIMDQ8ActivationBounds checks that the Q8-activation kernels fall
+// back to scalar instead of handing C an activation buffer shorter than a
+// row (a stale pooled q8Activations, say)
+func TestSIMDQ8ActivationBounds(t *testing.T) {
+	const rows, blocks = 4, 8
+	out := make([]float32, rows)
+	full := &q8Activations{qs: make([]int8, blocks*q8BlockSize), ds: make([]float32, blocks)}
+	shortQs := &q8Activations{qs: make([]int8, (blocks-1)*q8BlockSize), ds: make([]float32, blocks)}
+	shortDs := &q8Activations{qs: make([]int8, blocks*q8BlockSize), ds: make([]float32, blocks-1)}
+
+	for _, k := range []struct {
+		name     string
+		mm       func([]float32, []byte, *q8Activations, int, int, int, int) bool
+		rowBytes int
+	}{
+		{"Q4_0", simdMatMulQ4_0Q8, blocks * q4BytesPerBlock},
+		{"Q8_0", simdMatMulQ8_0Q8, blocks * q8BytesPerBlock},
+	} {
+		w := make([]byte, rows*k.rowBytes)
+		for name, qa := range map[string]*q8Activations{"short qs": shortQs, "short ds": shortDs} {
+			if k.mm(out, w, qa, 0, rows, blocks, k.rowBytes) {
+				t.Errorf("%s with %s: ran the vector kernel", k.name, name)
+			}
+		}
+		if simdKernels != "" && !k.mm(out, w, full, 0, rows, blocks, k.rowBytes) {
+			t.Errorf("%s: full buffers fell back to scalar", k.name)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package yent
+
+// stream.go — Resumable streamed responses
+//
+// A streamed answer ({"stream": true}) is generated into a server-side
+// buffer, not straight into the connection. The connection only reads the
+// buffer, as Server-Sent Events:
+//
+//   event: start   data: {"stream_id": "9f2c…"}
+//   id: 9f2c…:0    event: token   data: {"index": 0, "piece": " I"}
+//   id: 9f2c…:1    event: token   data: {"index": 1, "piece": "'m"}
+//   …
+//   event: done    data: {"text": "…", "stream_id": "9f2c…"}
+//
+// When the connection drops (mobile handover, a Telegram edit that timed
+// out), generation carries on. The client reconnects with
+//
+//   GET /v1/streams/9f2c…            Last-Event-ID: 9f2c…:1
+//   GET /v1/streams/9f2c…?from=2
+//
+// and gets the rest, then follows live. A finished stream stays resumable
+// for StreamRetention; DELETE /v1/streams/{id} stops generation early.
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// responseStream buffers one in-flight answer
+type responseStream struct {
+	id     string
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	pieces   []string
+	changed  chan struct{} // closed and replaced on every update
+	done     bool
+	final    generateResponse
+	err      error
+	finished time.Time
+}
+
+// newStreamID returns a random 16-hex-digit stream id
+func newStreamID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func newResponseStream(cancel context.CancelFunc) *responseStream {
+	return &responseStream{id: newStreamID(), cancel: cancel, changed: make(chan struct{})}
+}
+
+// append adds a generated piece and wakes readers
+func (st *responseStream) append(piece string) {
+	st.mu.Lock()
+	st.pieces = append(st.pieces, piece)
+	close(st.changed)
+	st.changed = make(chan struct{})
+	st.mu.Unlock()
+}
+
+// finish records the outcome and wakes readers
+func (st *responseStream) finish(final generateResponse, err error) {
+	st.mu.Lock()
+	st.done, st.final, st.err, st.finished = true, final, err, time.Now()
+	close(st.changed)
+	st.changed = make(chan struct{})
+	st.mu.Unlock()
+}
+
+// since returns pieces from index from on, whether the stream is done, and
+// a channel closed on the next update
+func (st *responseStream) since(from int) ([]string, bool, <-chan struct{}) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var pieces []string
+	if from < len(st.pieces) {
+		pieces = append(pieces, st.pieces[from:]...)
+	}
+	return pieces, st.done, st.changed
+}
+
+// expired reports whether a finished stream is past retention
+func (st *responseStream) expired(now time.Time, retention time.Duration) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.done && now.Sub(st.finished) > retention
+}
+
+// startStream runs generation in the background into a new stream
+func (s *Server) startStream(y *Yent, prompt, sessionID, model string, opts GenerateOptions) *responseStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	st := newResponseStream(cancel)
+	s.mu.Lock()
+	s.streams[st.id] = st
+	s.mu.Unlock()
+
+	opts.OnToken = func(tok int, piece string) bool {
+		st.append(piece)
+		return true
+	}
+	go func() {
+		defer cancel()
+		res, err := y.GenerateContext(ctx, prompt, opts)
+		final := generateResponse{SessionID: sessionID, Model: model, StreamID: st.id}
+		if res != nil {
+			final.Text, final.Language = res.Text, res.Language
+		}
+		if opts.Session != nil {
+			final.Turns = len(opts.Session.Turns())
+			final.CachedTokens = opts.Session.CachedTokens()
+		}
+		st.finish(final, err)
+	}()
+	return st
+}
+
+// serveStream writes pieces from index from on as SSE, following the stream
+// live until it finishes or the client goes away
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, st *responseStream, from int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	writeEvent(w, "", "start", map[string]string{"stream_id": st.id})
+	flusher.Flush()
+
+	for {
+		pieces, done, changed := st.since(from)
+		for _, piece := range pieces {
+			writeEvent(w, st.id+":"+strconv.Itoa(from), "token", map[string]interface{}{"index": from, "piece": piece})
+			from++
+		}
+		if done {
+			st.mu.Lock()
+			final, err := st.final, st.err
+			st.mu.Unlock()
+			if err != nil {
+				writeEvent(w, "", "error", map[string]string{"error": err.Error(), "stream_id": st.id})
+			} else {
+				writeEvent(w, "", "done", final)
+			}
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return // generation continues; the client can resume
+		}
+	}
+}
+
+// writeEvent writes one SSE event (data is JSON, so it is a single line)
+func writeEvent(w http.ResponseWriter, id, event string, data interface{}) {
+	b, _ := json.Marshal(data)
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+}
+
+// resumeFrom returns the first piece index to send: ?from=N, or one past
+// the index in Last-Event-ID ("<stream>:<index>"), or 0
+func resumeFrom(r *http.Request, streamID string) (int, error) {
+	if v := r.URL.Query().Get("from"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("bad from %q", v)
+		}
+		return n, nil
+	}
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		id, idx, ok := strings.Cut(v, ":")
+		n, err := strconv.Atoi(idx)
+		if !ok || id != streamID || err != nil || n < 0 {
+			return 0, fmt.Errorf("bad Last-Event-ID %q", v)
+		}
+		return n + 1, nil
+	}
+	return 0, nil
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/streams/")
+	s.mu.Lock()
+	st, ok := s.streams[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "no stream "+id+" (finished streams expire)")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		from, err := resumeFrom(r, id)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.serveStream(w, r, st, from)
+	case http.MethodDelete:
+		st.cancel()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "use GET or DELETE")
+	}
+}
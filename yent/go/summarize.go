@@ -0,0 +1,174 @@
+package yent
+
+// summarize.go — Shrinking memories to fit the prompt
+//
+// A 1.5B model with a 2048-token context can't take five full conversations
+// and still have room to answer. Summarizers cut a memory down to a token
+// budget while keeping what matters for the current question:
+//
+//   extractive — keep the sentences that share the most words with the
+//                query (first sentence gets a small bonus), original order
+//   model      — ask Yent itself for a short summary (slower, not stored)
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Summarizer compresses text to at most maxTokens, guided by query
+type Summarizer interface {
+	Summarize(text, query string, maxTokens int) (string, error)
+}
+
+// CountTokens returns how many tokens text encodes to
+func (y *Yent) CountTokens(text string) int {
+	return len(y.tokenizer.Encode(text, false))
+}
+
+// ExtractiveSummarizer selects whole sentences by query overlap
+type ExtractiveSummarizer struct {
+	Count func(string) int // token counter
+}
+
+// Summarize keeps the best-scoring sentences that fit, in original order
+func (s *ExtractiveSummarizer) Summarize(text, query string, maxTokens int) (string, error) {
+	if s.Count(text) <= maxTokens {
+		return text, nil
+	}
+	sentences := splitSentences(text)
+	terms := make(map[string]bool)
+	for _, w := range contentWords(query) {
+		terms[w] = true
+	}
+
+	type scored struct {
+		idx    int
+		score  float32
+		tokens int
+	}
+	ranked := make([]scored, len(sentences))
+	for i, sent := range sentences {
+		var overlap float32
+		words := contentWords(sent)
+		for _, w := range words {
+			if terms[w] {
+				overlap++
+			}
+		}
+		score := overlap / float32(len(words)+1)
+		if i == 0 {
+			score += 0.1 // openings carry the gist
+		}
+		ranked[i] = scored{i, score, s.Count(sent)}
+	}
+	sort.SliceStable(ranked, func(a, b int) bool { return ranked[a].score > ranked[b].score })
+
+	keep := make([]bool, len(sentences))
+	used := 0
+	for _, r := range ranked {
+		if used+r.tokens > maxTokens {
+			continue
+		}
+		keep[r.idx] = true
+		used += r.tokens
+	}
+
+	var parts []string
+	for i, sent := range sentences {
+		if keep[i] {
+			parts = append(parts, sent)
+		}
+	}
+	if len(parts) == 0 {
+		// Not even one sentence fits: hard cut the best one by words
+		return truncateTokens(sentences[ranked[0].idx], maxTokens, s.Count), nil
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// ModelSummarizer asks the loaded model for a summary. The call is not
+// stored in LIMPHA — summaries of memories are not new experience.
+type ModelSummarizer struct {
+	y *Yent
+}
+
+// NewModelSummarizer returns a summarizer backed by y
+func NewModelSummarizer(y *Yent) *ModelSummarizer {
+	return &ModelSummarizer{y: y}
+}
+
+// Summarize generates a short summary, falling back to extraction if it overruns
+func (s *ModelSummarizer) Summarize(text, query string, maxTokens int) (string, error) {
+	if s.y.CountTokens(text) <= maxTokens {
+		return text, nil
+	}
+	res, err := s.y.GenerateWithOptions("Summarize briefly, keeping what relates to \""+query+"\":\n"+text, GenerateOptions{
+		MaxTokens:   maxTokens,
+		Temperature: 0.3,
+		TopK:        20,
+		NoStore:     true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("model summary: %w", err)
+	}
+	summary := strings.TrimSpace(res.Text)
+	if summary == "" || s.y.CountTokens(summary) > maxTokens {
+		ext := &ExtractiveSummarizer{Count: s.y.CountTokens}
+		return ext.Summarize(text, query, maxTokens)
+	}
+	return summary, nil
+}
+
+// splitSentences splits on sentence-ending punctuation and newlines
+func splitSentences(text string) []string {
+	var out []string
+	start := 0
+	runes := []rune(text)
+	for i, r := range runes {
+		end := r == '\n' ||
+			((r == '.' || r == '!' || r == '?' || r == '…') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])))
+		if end {
+			if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+				out = append(out, s)
+			}
+			start = i + 1
+		}
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+// contentWords returns lowercase words of 3+ letters
+func contentWords(text string) []string {
+	var out []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) >= 3 {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// truncateTokens cuts text at a word boundary so it fits maxTokens
+func truncateTokens(text string, maxTokens int, count func(string) int) string {
+	words := strings.Fields(text)
+	lo, hi := 0, len(words)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if count(strings.Join(words[:mid], " ")+"…") <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	if lo == 0 {
+		return ""
+	}
+	return strings.Join(words[:lo], " ") + "…"
+}
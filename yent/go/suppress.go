@@ -0,0 +1,191 @@
+package yent
+
+// suppress.go — Which scripts generation never samples
+//
+// Yent was tuned on English on top of a multilingual base, and without
+// Delta Voice the base still leaks Chinese, Japanese and Korean tokens into
+// an English answer. Setting those tokens to -inf is what keeps alpha=0
+// English. Another base leaks other scripts, and an instance that should
+// answer in Japanese wants none of it, so the set is a spec:
+//
+//   cjk                       the default: Han, kana, Hangul, CJK radicals
+//   none                      suppress nothing
+//   Cyrillic,Arabic           Unicode script names (Go's unicode.Scripts)
+//   cjk,U+0E00-0E7F           groups, names and code point ranges together
+//
+// LoadOptions.Suppress sets it at load (CLI -suppress, or "suppress" in a
+// config profile), Yent.SetSuppression at runtime. A token is suppressed
+// when its decoded text contains any matching character. Suppression is
+// off while Delta Voice speaks (alpha > 0) unless SuppressWithDelta is set.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultSuppression is the spec used when none is given
+const DefaultSuppression = "cjk"
+
+// suppressGroups are the spec names that are not single Unicode scripts
+var suppressGroups = map[string][][2]rune{
+	"cjk": {
+		{0x4E00, 0x9FFF},   // CJK Unified
+		{0x3400, 0x4DBF},   // CJK Ext A
+		{0x20000, 0x2EBEF}, // CJK Ext B-F
+		{0xF900, 0xFAFF},   // CJK Compat
+		{0x2E80, 0x2EFF},   // CJK Radicals
+		{0xAC00, 0xD7AF},   // Hangul
+		{0x3040, 0x309F},   // Hiragana
+		{0x30A0, 0x30FF},   // Katakana
+	},
+}
+
+// Suppression is a parsed script-suppression spec
+type Suppression struct {
+	spec   string
+	ranges [][2]rune
+	tables []*unicode.RangeTable
+}
+
+// ParseSuppression parses a comma-separated spec ("" = DefaultSuppression)
+func ParseSuppression(spec string) (*Suppression, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		spec = DefaultSuppression
+	}
+	s := &Suppression{}
+	var parts []string
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "":
+			continue
+		case strings.EqualFold(part, "none"):
+			if len(strings.Split(spec, ",")) > 1 {
+				return nil, fmt.Errorf("suppress %q: none cannot be combined", spec)
+			}
+		case suppressGroups[strings.ToLower(part)] != nil:
+			part = strings.ToLower(part)
+			s.ranges = append(s.ranges, suppressGroups[part]...)
+		case strings.HasPrefix(strings.ToUpper(part), "U+"):
+			lo, hi, err := parseRuneRange(part)
+			if err != nil {
+				return nil, fmt.Errorf("suppress %q: %w", spec, err)
+			}
+			s.ranges = append(s.ranges, [2]rune{lo, hi})
+		default:
+			name, ok := scriptName(part)
+			if !ok {
+				return nil, fmt.Errorf("suppress %q: unknown script %q (a Unicode script such as Han or Cyrillic, cjk, none, or U+XXXX-YYYY)", spec, part)
+			}
+			part = name
+			s.tables = append(s.tables, unicode.Scripts[name])
+		}
+		parts = append(parts, part)
+	}
+	s.spec = strings.Join(parts, ",")
+	return s, nil
+}
+
+// parseRuneRange parses "U+0400-04FF", "U+0400-U+04FF" or "U+00E9"
+func parseRuneRange(s string) (rune, rune, error) {
+	from, to, isRange := strings.Cut(s[2:], "-")
+	lo, err := strconv.ParseUint(from, 16, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad code point %q", s)
+	}
+	hi := lo
+	if isRange {
+		to = strings.TrimPrefix(strings.TrimPrefix(to, "U+"), "u+")
+		if hi, err = strconv.ParseUint(to, 16, 32); err != nil {
+			return 0, 0, fmt.Errorf("bad code point %q", s)
+		}
+	}
+	if hi < lo || hi > unicode.MaxRune {
+		return 0, 0, fmt.Errorf("bad range %q", s)
+	}
+	return rune(lo), rune(hi), nil
+}
+
+// scriptName finds a Unicode script by case-insensitive name
+func scriptName(name string) (string, bool) {
+	if _, ok := unicode.Scripts[name]; ok {
+		return name, true
+	}
+	for script := range unicode.Scripts {
+		if strings.EqualFold(script, name) {
+			return script, true
+		}
+	}
+	return "", false
+}
+
+// String returns the normalized spec ("none" when nothing is suppressed)
+func (s *Suppression) String() string {
+	return s.spec
+}
+
+// Matches reports whether text contains a suppressed character
+func (s *Suppression) Matches(text string) bool {
+	for _, r := range text {
+		for _, rg := range s.ranges {
+			if r >= rg[0] && r <= rg[1] {
+				return true
+			}
+		}
+		for _, tab := range s.tables {
+			if unicode.Is(tab, r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Tokens returns the ids of t's vocabulary that decode to suppressed text
+func (s *Suppression) Tokens(t *Tokenizer) map[int]bool {
+	tokens := make(map[int]bool)
+	if len(s.ranges) == 0 && len(s.tables) == 0 {
+		return tokens
+	}
+	for id := 0; id < t.VocabSize; id++ {
+		if s.Matches(t.DecodeToken(id)) {
+			tokens[id] = true
+		}
+	}
+	return tokens
+}
+
+// SetSuppression replaces the suppressed scripts and rebuilds the token set
+func (y *Yent) SetSuppression(spec string) error {
+	s, err := ParseSuppression(spec)
+	if err != nil {
+		return err
+	}
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	if y.tokenizer == nil {
+		return fmt.Errorf("yent not initialized")
+	}
+	y.suppression, y.suppressed = s, s.Tokens(y.tokenizer)
+	y.loadOpts.Suppress = s.String() // survives a reload with a new vocabulary
+	fmt.Printf("[yent] suppression %q: %d tokens\n", s.String(), len(y.suppressed))
+	return nil
+}
+
+// Suppression returns the active spec
+func (y *Yent) Suppression() string {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	if y.suppression == nil {
+		return ""
+	}
+	return y.suppression.String()
+}
+
+// suppressing reports whether the suppressed tokens are masked right now
+func (y *Yent) suppressing() bool {
+	return len(y.suppressed) > 0 && (y.DeltaAlpha == 0 || y.SuppressWithDelta)
+}
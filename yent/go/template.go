@@ -0,0 +1,155 @@
+package yent
+
+// template.go — How memories are framed in the prompt
+//
+// The same five memories help or get ignored depending on the words around
+// them. The 1.5B model follows "Things you remember:" far better than a bare
+// list; a Telegram bot may want ChatML system content; evaluation runs may
+// want memories replayed as earlier turns. Templates are Go text/template
+// over the MemoryContext:
+//
+//   {{.Query}}                       the user's prompt
+//   {{range .Items}} {{.Prompt}} {{.Response}} {{.Mode}} {{.Score}} {{end}}
+//   {{oneline .Response}}            collapse whitespace
+//   {{.Injection}}                   instruction-like patterns (flag policy)
+//
+// Built in: remember (default), inline, chatml, notes, and unknown — used
+// when the answerability check finds the memories don't hold the answer.
+// Custom: RegisterMemoryTemplate, or *.tmpl files in ~/.yent/templates/
+// (file name without extension = template name).
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// DefaultMemoryTemplate is used when a context names no template
+const DefaultMemoryTemplate = "remember"
+
+// UnknownMemoryTemplate frames contexts whose memories don't hold the answer
+const UnknownMemoryTemplate = "unknown"
+
+var builtinMemoryTemplates = map[string]string{
+	// Second-person recollection — what the voice model responds to best
+	"remember": `Things you remember:
+{{range .Items}}- They asked: {{oneline .Prompt}}
+  You said: {{oneline .Response}}
+{{end}}
+`,
+
+	// Memories replayed as earlier turns in the training format
+	"inline": `{{range .Items}}### Question: {{.Prompt}}
+### Answer: {{.Response}}
+
+{{end}}`,
+
+	// ChatML system block for chat-style integrations
+	"chatml": `<|im_start|>system
+You are Yent. Earlier conversations you remember:
+{{range .Items}}- Q: {{oneline .Prompt}} A: {{oneline .Response}}
+{{end}}<|im_end|>
+`,
+
+	// Nothing relevant remembered: say so rather than invent a past
+	"unknown": `You don't remember talking about this before. If they ask about your past together, say honestly that you don't remember.
+
+`,
+
+	// Terse notes, lowest token overhead
+	"notes": `[memory]{{range .Items}} {{oneline .Response}} |{{end}}
+`,
+}
+
+var (
+	memoryTemplatesMu sync.RWMutex
+	memoryTemplates   = map[string]*template.Template{}
+)
+
+var templateFuncs = template.FuncMap{"oneline": oneLine}
+
+func init() {
+	for name, text := range builtinMemoryTemplates {
+		if err := RegisterMemoryTemplate(name, text); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterMemoryTemplate adds or replaces a named memory template
+func RegisterMemoryTemplate(name, text string) error {
+	t, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("memory template %q: %w", name, err)
+	}
+	memoryTemplatesMu.Lock()
+	memoryTemplates[name] = t
+	memoryTemplatesMu.Unlock()
+	return nil
+}
+
+// MemoryTemplates lists registered template names
+func MemoryTemplates() []string {
+	memoryTemplatesMu.RLock()
+	defer memoryTemplatesMu.RUnlock()
+	names := make([]string, 0, len(memoryTemplates))
+	for name := range memoryTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadMemoryTemplates registers every *.tmpl file in dir ("" = ~/.yent/templates).
+// A missing directory is not an error.
+func LoadMemoryTemplates(dir string) (int, error) {
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return 0, fmt.Errorf("home dir: %w", err)
+		}
+		dir = filepath.Join(homeDir, ".yent", "templates")
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return 0, err
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, fmt.Errorf("read template: %w", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		if err := RegisterMemoryTemplate(name, string(data)); err != nil {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
+
+// RenderMemory frames a memory context with its template.
+// An empty context renders nothing unless it is marked unanswerable.
+func RenderMemory(mc *MemoryContext) (string, error) {
+	if mc == nil || (len(mc.Items) == 0 && !mc.Unanswerable) {
+		return "", nil
+	}
+	name := mc.Template
+	if name == "" {
+		name = DefaultMemoryTemplate
+	}
+	memoryTemplatesMu.RLock()
+	t, ok := memoryTemplates[name]
+	memoryTemplatesMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown memory template %q", name)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, mc); err != nil {
+		return "", fmt.Errorf("render memory template %q: %w", name, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,307 @@
+package yent
+
+// thermal.go — Back off before the board does
+//
+// A Raspberry Pi at 85°C throttles its own clock, and a laptop on 10%
+// battery would rather answer slowly than die. Where Linux exposes it
+// (/sys/class/thermal, /sys/class/power_supply), the Throttle reads the
+// hottest zone and the battery and picks a level:
+//
+//   none   full worker count, the field moves as it likes
+//   warm   ≥ WarmC, or discharging at ≤ LowBattery%:
+//          half the workers, velocity capped at WALK
+//   hot    ≥ HotC: one worker, velocity NOMOVE
+//
+// A level is only left once the temperature is Hysteresis below its
+// threshold, so the board does not flap around one degree. The cap is
+// applied by generate for the duration of a call — worker count between
+// matmuls, velocity on whichever field is active — and lifted after, so
+// no session's field keeps a throttled velocity. Level changes are logged
+// and kept for /status. Elsewhere (macOS, containers) nothing is readable
+// and the Throttle stays at none.
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThrottleLevel is how hard to back off
+type ThrottleLevel int
+
+// Throttle levels
+const (
+	ThrottleNone ThrottleLevel = iota
+	ThrottleWarm
+	ThrottleHot
+)
+
+// String returns the level name
+func (l ThrottleLevel) String() string {
+	switch l {
+	case ThrottleWarm:
+		return "warm"
+	case ThrottleHot:
+		return "hot"
+	}
+	return "none"
+}
+
+// PowerState is what the platform reports about heat and battery
+type PowerState struct {
+	TempC     float32 `json:"temp_c"`     // hottest thermal zone (valid if HasTemp)
+	HasTemp   bool    `json:"has_temp"`   // any thermal zone readable
+	OnBattery bool    `json:"on_battery"` // a battery is discharging
+	Battery   int     `json:"battery"`    // charge percent (-1 = no battery)
+}
+
+// ThrottleConfig sets the thresholds
+type ThrottleConfig struct {
+	WarmC      float32 // warm level from this temperature (0 = 70)
+	HotC       float32 // hot level from this temperature (0 = 85)
+	Hysteresis float32 // degrees below a threshold to leave its level (0 = 5)
+	LowBattery int     // discharging at or below this percent = warm (0 = 20)
+	SysRoot    string  // sysfs root ("" = /sys)
+}
+
+// ThrottleEvent is one level change
+type ThrottleEvent struct {
+	Time   time.Time `json:"time"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason"`
+}
+
+// ThrottleStatus is the throttle as /status shows it
+type ThrottleStatus struct {
+	Level    string          `json:"level"`
+	Power    PowerState      `json:"power"`
+	Threads  int             `json:"threads"`
+	Velocity string          `json:"velocity_cap,omitempty"`
+	Events   []ThrottleEvent `json:"events"`
+}
+
+// throttleEvents is how many level changes are kept
+const throttleEvents = 32
+
+// Throttle tracks power state and the limits it implies
+type Throttle struct {
+	cfg         ThrottleConfig
+	baseThreads int
+
+	mu     sync.Mutex
+	level  ThrottleLevel
+	power  PowerState
+	events []ThrottleEvent
+}
+
+// NewThrottle creates a throttle at level none; the current thread count
+// is what it returns to when cool
+func NewThrottle(cfg ThrottleConfig) *Throttle {
+	if cfg.WarmC <= 0 {
+		cfg.WarmC = 70
+	}
+	if cfg.HotC <= 0 {
+		cfg.HotC = 85
+	}
+	if cfg.Hysteresis <= 0 {
+		cfg.Hysteresis = 5
+	}
+	if cfg.LowBattery <= 0 {
+		cfg.LowBattery = 20
+	}
+	if cfg.SysRoot == "" {
+		cfg.SysRoot = "/sys"
+	}
+	return &Throttle{cfg: cfg, baseThreads: Threads(), power: PowerState{Battery: -1}}
+}
+
+// Level picks the level for p, given the previous one (for hysteresis)
+func (c ThrottleConfig) Level(p PowerState, prev ThrottleLevel) ThrottleLevel {
+	if p.HasTemp {
+		if p.TempC >= c.HotC || (prev == ThrottleHot && p.TempC > c.HotC-c.Hysteresis) {
+			return ThrottleHot
+		}
+		if p.TempC >= c.WarmC || (prev >= ThrottleWarm && p.TempC > c.WarmC-c.Hysteresis) {
+			return ThrottleWarm
+		}
+	}
+	if p.OnBattery && p.Battery >= 0 && p.Battery <= c.LowBattery {
+		return ThrottleWarm
+	}
+	return ThrottleNone
+}
+
+// Check reads the power state and moves to the level it implies
+func (t *Throttle) Check() ThrottleLevel {
+	p := ReadPowerState(t.cfg.SysRoot)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.power = p
+	level := t.cfg.Level(p, t.level)
+	if level == t.level {
+		return level
+	}
+	ev := ThrottleEvent{Time: time.Now(), From: t.level.String(), To: level.String(), Reason: p.describe()}
+	t.events = append(t.events, ev)
+	if len(t.events) > throttleEvents {
+		t.events = t.events[len(t.events)-throttleEvents:]
+	}
+	t.level = level
+	threads, vel := t.limits()
+	if level == ThrottleNone {
+		fmt.Printf("[thermal] %s → none (%s): %d threads\n", ev.From, ev.Reason, threads)
+	} else {
+		fmt.Printf("[thermal] %s → %s (%s): %d threads, velocity %s\n", ev.From, ev.To, ev.Reason, threads, velocityName(vel))
+	}
+	return level
+}
+
+// Status returns the level, power state, limits and recent events
+func (t *Throttle) Status() ThrottleStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	threads, vel := t.limits()
+	st := ThrottleStatus{
+		Level:   t.level.String(),
+		Power:   t.power,
+		Threads: threads,
+		Events:  append([]ThrottleEvent{}, t.events...),
+	}
+	if t.level != ThrottleNone {
+		st.Velocity = velocityName(vel)
+	}
+	return st
+}
+
+// limits returns the worker count and velocity cap for the level (caller holds t.mu)
+func (t *Throttle) limits() (threads, velocity int) {
+	switch t.level {
+	case ThrottleHot:
+		return 1, VelNoMove
+	case ThrottleWarm:
+		if threads = t.baseThreads / 2; threads < 1 {
+			threads = 1
+		}
+		return threads, VelWalk
+	}
+	return t.baseThreads, VelRun
+}
+
+// apply sets the worker count and caps the active field's velocity;
+// the returned func lifts the cap again. Called with the model lock held.
+func (t *Throttle) apply(amk *AMK) func() {
+	t.mu.Lock()
+	threads, vel := t.limits()
+	active := t.level != ThrottleNone
+	t.mu.Unlock()
+
+	SetThreads(threads)
+	if !active || amk == nil {
+		return func() {}
+	}
+	prev := amk.GetState().VelocityMode
+	// RUN and BACKWARD are both faster than WALK; WALK is faster than NOMOVE
+	if prev == vel || (vel == VelWalk && prev == VelNoMove) {
+		return func() {}
+	}
+	amk.Exec("VELOCITY " + velocityName(vel))
+	return func() { amk.Exec(fmt.Sprintf("VELOCITY %d", prev)) }
+}
+
+// SetThrottle makes every generation respect t (nil = no throttling)
+func (y *Yent) SetThrottle(t *Throttle) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	y.throttle = t
+}
+
+// velocityName returns the DSL name of a velocity mode
+func velocityName(v int) string {
+	switch v {
+	case VelNoMove:
+		return "NOMOVE"
+	case VelWalk:
+		return "WALK"
+	case VelRun:
+		return "RUN"
+	case VelBackward:
+		return "BACKWARD"
+	}
+	return strconv.Itoa(v)
+}
+
+// describe summarizes p for the event log
+func (p PowerState) describe() string {
+	var parts []string
+	if p.HasTemp {
+		parts = append(parts, fmt.Sprintf("%.1f°C", p.TempC))
+	}
+	if p.Battery >= 0 {
+		state := "charging"
+		if p.OnBattery {
+			state = "discharging"
+		}
+		parts = append(parts, fmt.Sprintf("battery %d%% %s", p.Battery, state))
+	}
+	if len(parts) == 0 {
+		return "no sensors"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ReadPowerState reads thermal zones and batteries under a sysfs root
+// ("" = /sys); anything unreadable is simply absent
+func ReadPowerState(root string) PowerState {
+	if root == "" {
+		root = "/sys"
+	}
+	p := PowerState{Battery: -1}
+
+	zones, _ := filepath.Glob(filepath.Join(root, "class", "thermal", "thermal_zone*", "temp"))
+	for _, path := range zones {
+		milli, ok := readSysInt(path)
+		c := float32(milli) / 1000
+		if !ok || c <= 0 || c >= 150 { // unpopulated zones report 0 or nonsense
+			continue
+		}
+		if !p.HasTemp || c > p.TempC {
+			p.TempC, p.HasTemp = c, true
+		}
+	}
+
+	supplies, _ := filepath.Glob(filepath.Join(root, "class", "power_supply", "*"))
+	for _, dir := range supplies {
+		kind, _ := os.ReadFile(filepath.Join(dir, "type"))
+		if strings.TrimSpace(string(kind)) != "Battery" {
+			continue
+		}
+		capacity, ok := readSysInt(filepath.Join(dir, "capacity"))
+		if !ok {
+			continue
+		}
+		status, _ := os.ReadFile(filepath.Join(dir, "status"))
+		discharging := strings.TrimSpace(string(status)) == "Discharging"
+		// Several batteries: report the emptiest
+		if p.Battery < 0 || capacity < p.Battery {
+			p.Battery = capacity
+		}
+		p.OnBattery = p.OnBattery || discharging
+	}
+	return p
+}
+
+// readSysInt reads a sysfs file holding one integer
+func readSysInt(path string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return n, err == nil
+}
@@ -0,0 +1,94 @@
+package yent
+
+// tokenize.go — Token ids with byte offsets, for tools outside the package
+//
+// Shard preprocessing and LIMPHA's context budgeting need to know how a
+// text splits into tokens, not only how many there are: where a cut at N
+// tokens falls in the text, or which bytes a token covers. Tokenize returns
+// each token with the byte range [Start, End) of the input it came from,
+// so text[tok.Start:tok.End] is that token's part of the text:
+//
+//   "Hello <|im_end|>"  →  {9707 "Hello" 0 5} {220 " " 5 6} {151645 "<|im_end|>" 6 16}
+//
+// Offsets are bytes, not runes. A character split across byte tokens gives
+// each of them a one-byte range. The space SentencePiece puts in front of a
+// segment is not in the text, so the first token of such a segment starts
+// at the segment without counting it. Detokenize is the inverse, minus
+// control tokens.
+
+import "fmt"
+
+// Token is one token of a text and the bytes of the text it covers
+type Token struct {
+	ID    int    `json:"id"`
+	Text  string `json:"text"` // text[Start:End]
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Tokenize splits text into tokens with byte offsets, without BOS
+func (y *Yent) Tokenize(text string) ([]Token, error) {
+	if y.tokenizer == nil {
+		return nil, fmt.Errorf("yent not initialized")
+	}
+	return y.tokenizer.EncodeOffsets(text), nil
+}
+
+// Detokenize turns token ids back into text
+func (y *Yent) Detokenize(ids []int) (string, error) {
+	t := y.tokenizer
+	if t == nil {
+		return "", fmt.Errorf("yent not initialized")
+	}
+	for _, id := range ids {
+		if id < 0 || id >= t.VocabSize {
+			return "", fmt.Errorf("token id %d out of range [0, %d)", id, t.VocabSize)
+		}
+	}
+	return t.Decode(ids), nil
+}
+
+// EncodeOffsets is Encode(text, false) with the byte range of each token
+func (t *Tokenizer) EncodeOffsets(text string) []Token {
+	var out []Token
+	pos := 0
+	for _, s := range t.splitOnSpecialTokens(text, 0, 0) {
+		seg := s.text
+		segEnd := pos + len(seg)
+		if s.special >= 0 {
+			out = append(out, Token{ID: s.special, Text: seg, Start: pos, End: segEnd})
+			pos = segEnd
+			continue
+		}
+
+		var ids []int
+		skip := 0 // bytes the encoder added in front of the segment
+		if t.IsGPT2 {
+			ids = t.encodeGPT2(seg)
+		} else {
+			if t.IsUnigram {
+				ids = t.encodeUnigram(seg)
+			} else {
+				ids = t.encodeSentencePiece(seg)
+			}
+			if t.AddSpacePrefix && len(seg) > 0 && seg[0] != ' ' {
+				skip = 1
+			}
+		}
+		for _, id := range ids {
+			n := len(t.DecodeToken(id))
+			if id == t.unkID {
+				n = 1 // stands for one byte without a byte token
+			}
+			if skip > 0 {
+				dropped := min(skip, n)
+				n, skip = n-dropped, skip-dropped
+			}
+			end := min(pos+n, segEnd)
+			out = append(out, Token{ID: id, Text: text[pos:end], Start: pos, End: end})
+			pos = end
+		}
+		pos = segEnd
+	}
+	return out
+}
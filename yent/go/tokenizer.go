@@ -2,9 +2,10 @@ package yent
 
 // tokenizer.go — BPE tokenizer from GGUF metadata
 //
-// Supports two modes:
+// Supports three modes:
 //   1. SentencePiece BPE (LLaMA/TinyLlama) — ▁ prefix, score-based merges
 //   2. GPT-2 byte-level BPE (Qwen2.5) — byte-to-unicode mapping, merge-rank BPE
+//   3. SentencePiece unigram (T5 family) — ▁ prefix, Viterbi over scores (unigram.go)
 //
 // Mode is auto-detected from tokenizer.ggml.model in GGUF metadata.
 //
@@ -18,7 +19,9 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Tokenizer handles BPE encoding/decoding (SentencePiece or GPT-2)
@@ -31,11 +34,19 @@ type Tokenizer struct {
 	EosID          int
 	AddSpacePrefix bool
 	IsGPT2         bool // true for GPT-2 byte-level BPE (Qwen2.5)
+	IsUnigram      bool // true for SentencePiece unigram (tokenizer model "t5")
+
+	// Unigram: longest piece in bytes, score of an unknown character
+	maxPieceLen int
+	unkScore    float32
 
 	// Lookup table for encoding
 	tokenToID map[string]int
-	// Byte fallback tokens (SentencePiece style <0xNN>)
+	// Byte fallback tokens: <0xNN> (SentencePiece) or the byte's own
+	// character (GPT-2); -1 where the vocabulary has neither
 	byteTokens [256]int
+	// <unk>, for bytes without a token (-1 = none, the byte is dropped)
+	unkID int
 
 	// GPT-2 byte-level encoding tables
 	byteToUnicode [256]rune
@@ -72,6 +83,15 @@ func buildGPT2ByteTable() (byteToUni [256]rune, uniToByte map[rune]byte) {
 	return
 }
 
+// LoadTokenizer builds the tokenizer of a GGUF file without loading its weights
+func LoadTokenizer(path string) (*Tokenizer, error) {
+	g, err := LoadGGUFMetadata(path)
+	if err != nil {
+		return nil, fmt.Errorf("load GGUF metadata: %w", err)
+	}
+	return NewTokenizer(&g.Meta), nil
+}
+
 // NewTokenizer creates a tokenizer from GGUF metadata
 func NewTokenizer(meta *GGUFMetadata) *Tokenizer {
 	isGPT2 := meta.TokenizerModel == "gpt2"
@@ -111,15 +131,33 @@ func NewTokenizer(meta *GGUFMetadata) *Tokenizer {
 		t.tokenToID[tok] = i
 	}
 
-	// Map byte fallback tokens (SentencePiece style)
+	// Map byte fallback tokens: <0xNN> first, as Llama and Qwen vocabularies
+	// carry them, else the GPT-2 character of the byte
+	missing := 0
 	for i := 0; i < 256; i++ {
 		name := fmt.Sprintf("<0x%02X>", i)
 		if id, ok := t.tokenToID[name]; ok {
 			t.byteTokens[i] = id
+		} else if id, ok := t.tokenToID[string(t.byteToUnicode[i])]; ok && isGPT2 {
+			t.byteTokens[i] = id
 		} else {
 			t.byteTokens[i] = -1
+			missing++
 		}
 	}
+	t.unkID = -1
+	for i, typ := range t.Types {
+		if typ == 2 { // unknown
+			t.unkID = i
+			break
+		}
+	}
+	if id, ok := t.tokenToID["<unk>"]; ok && t.unkID < 0 {
+		t.unkID = id
+	}
+	if missing > 0 {
+		fmt.Printf("[tongue/tokenizer] %d bytes have no byte token — encoded as <unk> (id %d)\n", missing, t.unkID)
+	}
 
 	// Build special tokens map (control tokens that should not be BPE'd)
 	t.specialTokens = make(map[string]int)
@@ -135,6 +173,14 @@ func NewTokenizer(meta *GGUFMetadata) *Tokenizer {
 		fmt.Printf("[tongue/tokenizer] %d special tokens registered\n", len(t.specialTokens))
 	}
 
+	switch meta.TokenizerModel {
+	case "t5", "unigram":
+		t.initUnigram()
+	case "llama", "gpt2":
+	default:
+		fmt.Printf("[tongue/tokenizer] warning: tokenizer model %q not supported — using SentencePiece BPE\n", meta.TokenizerModel)
+	}
+
 	// GPT-2 BPE: build merge rank map from merge rules
 	if isGPT2 && len(meta.TokenMerges) > 0 {
 		t.mergeRank = make(map[string]int, len(meta.TokenMerges))
@@ -149,8 +195,25 @@ func NewTokenizer(meta *GGUFMetadata) *Tokenizer {
 	return t
 }
 
-// Encode converts text to token IDs using BPE
+// Encode converts text to token IDs using BPE. Special tokens written in
+// text ("<|im_start|>") are recognized as such.
 func (t *Tokenizer) Encode(text string, addBos bool) []int {
+	return t.encode(text, addBos, 0, 0)
+}
+
+// EncodeSpecial is Encode with special-token parsing switchable: with
+// parseSpecial false, "<|im_start|>" in text is encoded as the characters
+// it is made of, as user text should be
+func (t *Tokenizer) EncodeSpecial(text string, addBos, parseSpecial bool) []int {
+	if parseSpecial {
+		return t.encode(text, addBos, 0, 0)
+	}
+	return t.encode(text, addBos, 0, len(text))
+}
+
+// encode tokenizes text, leaving special tokens that overlap bytes
+// [literalFrom, literalTo) as plain text
+func (t *Tokenizer) encode(text string, addBos bool, literalFrom, literalTo int) []int {
 	var tokens []int
 
 	if addBos && t.BosID >= 0 {
@@ -162,64 +225,89 @@ func (t *Tokenizer) Encode(text string, addBos bool) []int {
 	}
 
 	// Split text on special tokens, encode each segment
-	segments := t.splitOnSpecialTokens(text)
+	segments := t.splitOnSpecialTokens(text, literalFrom, literalTo)
 	for _, seg := range segments {
-		if id, ok := t.specialTokens[seg]; ok {
-			tokens = append(tokens, id)
+		if seg.special >= 0 {
+			tokens = append(tokens, seg.special)
 		} else if t.IsGPT2 {
-			tokens = append(tokens, t.encodeGPT2(seg)...)
+			tokens = append(tokens, t.encodeGPT2(seg.text)...)
+		} else if t.IsUnigram {
+			tokens = append(tokens, t.encodeUnigram(seg.text)...)
 		} else {
-			tokens = append(tokens, t.encodeSentencePiece(seg)...)
+			tokens = append(tokens, t.encodeSentencePiece(seg.text)...)
 		}
 	}
 
 	return tokens
 }
 
-// splitOnSpecialTokens splits text into segments, preserving special tokens as separate items
-func (t *Tokenizer) splitOnSpecialTokens(text string) []string {
+// textSegment is a run of text between special tokens, or one of them
+type textSegment struct {
+	text    string
+	special int // token id of a special token, -1 for text
+}
+
+// splitOnSpecialTokens splits text into segments, preserving special tokens
+// as separate items; occurrences overlapping [literalFrom, literalTo) stay text
+func (t *Tokenizer) splitOnSpecialTokens(text string, literalFrom, literalTo int) []textSegment {
 	if len(t.specialTokens) == 0 {
-		return []string{text}
+		return []textSegment{{text, -1}}
 	}
 
-	var segments []string
-	remaining := text
+	var segments []textSegment
+	pos, textStart := 0, 0
 
-	for len(remaining) > 0 {
-		// Find earliest special token in remaining text
+	for pos < len(text) {
+		// Find earliest special token at or after pos
 		bestPos := -1
 		bestLen := 0
 		bestToken := ""
 
 		for token := range t.specialTokens {
-			pos := strings.Index(remaining, token)
-			if pos >= 0 && (bestPos < 0 || pos < bestPos || (pos == bestPos && len(token) > bestLen)) {
-				bestPos = pos
+			p := findSpecial(text, token, pos, literalFrom, literalTo)
+			if p >= 0 && (bestPos < 0 || p < bestPos || (p == bestPos && len(token) > bestLen)) {
+				bestPos = p
 				bestLen = len(token)
 				bestToken = token
 			}
 		}
 
 		if bestPos < 0 {
-			// No more special tokens found
-			if len(remaining) > 0 {
-				segments = append(segments, remaining)
-			}
 			break
 		}
 
-		// Add text before special token
-		if bestPos > 0 {
-			segments = append(segments, remaining[:bestPos])
+		// Add text before special token, then the token
+		if bestPos > textStart {
+			segments = append(segments, textSegment{text[textStart:bestPos], -1})
 		}
-		// Add special token
-		segments = append(segments, bestToken)
-		remaining = remaining[bestPos+bestLen:]
+		segments = append(segments, textSegment{bestToken, t.specialTokens[bestToken]})
+		pos = bestPos + bestLen
+		textStart = pos
+	}
+	if textStart < len(text) {
+		segments = append(segments, textSegment{text[textStart:], -1})
 	}
 
 	return segments
 }
 
+// findSpecial returns the first position >= from of token in text that
+// does not overlap [literalFrom, literalTo), or -1
+func findSpecial(text, token string, from, literalFrom, literalTo int) int {
+	for from <= len(text) {
+		i := strings.Index(text[from:], token)
+		if i < 0 {
+			return -1
+		}
+		p := from + i
+		if p+len(token) <= literalFrom || p >= literalTo {
+			return p
+		}
+		from = p + 1
+	}
+	return -1
+}
+
 // encodeSentencePiece does SentencePiece BPE encoding (LLaMA style)
 func (t *Tokenizer) encodeSentencePiece(text string) []int {
 	// SentencePiece: prepend space if configured
@@ -338,18 +426,50 @@ func (t *Tokenizer) symbolsToIDs(symbols []string) []int {
 	for _, sym := range symbols {
 		if id, ok := t.tokenToID[sym]; ok {
 			tokens = append(tokens, id)
-		} else {
-			// Fall back to byte tokens
-			for _, b := range []byte(sym) {
-				if t.byteTokens[b] >= 0 {
-					tokens = append(tokens, t.byteTokens[b])
-				}
+			continue
+		}
+		// Fall back to one token per byte of the text the symbol stands for
+		for _, b := range t.symbolBytes(sym) {
+			if id := t.byteTokens[b]; id >= 0 {
+				tokens = append(tokens, id)
+			} else if t.unkID >= 0 {
+				tokens = append(tokens, t.unkID)
 			}
 		}
 	}
 	return tokens
 }
 
+// symbolBytes returns the input bytes a BPE symbol encodes: <0xNN> is one
+// byte, GPT-2 characters map back through the byte table, anything else
+// is its UTF-8 with ▁ as a space
+func (t *Tokenizer) symbolBytes(sym string) []byte {
+	if b, ok := parseByteToken(sym); ok {
+		return []byte{b}
+	}
+	if t.IsGPT2 {
+		out := make([]byte, 0, len(sym))
+		for _, r := range sym {
+			if b, ok := t.unicodeToByte[r]; ok {
+				out = append(out, b)
+			} else {
+				out = utf8.AppendRune(out, r)
+			}
+		}
+		return out
+	}
+	return []byte(strings.ReplaceAll(sym, "▁", " "))
+}
+
+// parseByteToken reads a <0xNN> byte fallback token
+func parseByteToken(piece string) (byte, bool) {
+	if len(piece) != 6 || piece[:3] != "<0x" || piece[5] != '>' {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(piece[3:5], 16, 8)
+	return byte(v), err == nil
+}
+
 // initialTokenizeSP splits text into initial symbols for SentencePiece BPE
 func (t *Tokenizer) initialTokenizeSP(text string) []string {
 	var symbols []string
@@ -391,9 +511,7 @@ func (t *Tokenizer) Decode(ids []int) string {
 		}
 
 		// Handle byte fallback tokens (<0xNN>)
-		if len(piece) == 6 && piece[0] == '<' && piece[1] == '0' && piece[2] == 'x' && piece[5] == '>' {
-			var b byte
-			fmt.Sscanf(piece, "<0x%02X>", &b)
+		if b, ok := parseByteToken(piece); ok {
 			sb.WriteByte(b)
 			continue
 		}
@@ -431,9 +549,7 @@ func (t *Tokenizer) DecodeToken(id int) string {
 	piece := t.Vocab[id]
 
 	// Handle byte fallback
-	if len(piece) == 6 && piece[0] == '<' && piece[1] == '0' && piece[2] == 'x' && piece[5] == '>' {
-		var b byte
-		fmt.Sscanf(piece, "<0x%02X>", &b)
+	if b, ok := parseByteToken(piece); ok {
 		return string([]byte{b})
 	}
 
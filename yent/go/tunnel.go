@@ -0,0 +1,34 @@
+package yent
+
+// tunnel.go — Tunneling: the field skips its likeliest words
+//
+// TUNNEL_THRESHOLD, TUNNEL_CHANCE and TUNNEL_SKIP_MAX were set by the DSL
+// and read by nobody. Now, once dissonance reaches the threshold, each
+// token tunnels with TUNNEL_CHANCE: the 1..TUNNEL_SKIP_MAX most likely
+// candidates (how many is drawn too) are masked before sampling, and the
+// answer goes through them to what lies behind.
+//
+// The rule is AMK.ShouldTunnel's, drawn from the generation RNG instead of
+// C rand(), so a seeded answer tunnels at the same tokens every replay.
+// Grammar-constrained and healing steps do not tunnel: their candidates
+// are already narrowed to what is allowed. GenerateResult.Tunneled counts
+// the tokens that did.
+
+// tunnelSkip is how many top candidates the step with field s skips (0 = none)
+func (y *Yent) tunnelSkip(s AMState) int {
+	if s.Dissonance < s.TunnelThreshold || s.TunnelChance <= 0 {
+		return 0
+	}
+	if y.rng.Float32() >= s.TunnelChance {
+		return 0
+	}
+	return 1 + y.rng.Intn(max(s.TunnelSkipMax, 1))
+}
+
+// tunnel masks the k most likely tokens
+func (y *Yent) tunnel(k int) {
+	logits := y.model.State.Logits[:y.model.Config.VocabSize]
+	for _, id := range topNIndices(logits, min(k, len(logits)-1)) {
+		logits[id] = -1e30
+	}
+}
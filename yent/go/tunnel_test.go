@@ -0,0 +1,73 @@
+package yent
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// TestTunnelSkip checks the draw: nothing below the threshold or at chance
+// 0, always 1..TUNNEL_SKIP_MAX at chance 1
+func TestTunnelSkip(t *testing.T) {
+	y := &Yent{rng: rand.New(rand.NewSource(1))}
+	field := AMState{TunnelThreshold: 0.5, TunnelChance: 1, TunnelSkipMax: 3}
+
+	below := field
+	below.Dissonance = 0.49
+	off := field
+	off.Dissonance, off.TunnelChance = 0.9, 0
+	for i := 0; i < 200; i++ {
+		if k := y.tunnelSkip(below); k != 0 {
+			t.Fatalf("below threshold: skipped %d", k)
+		}
+		if k := y.tunnelSkip(off); k != 0 {
+			t.Fatalf("chance 0: skipped %d", k)
+		}
+	}
+
+	above := field
+	above.Dissonance = 0.5
+	seen := map[int]bool{}
+	for i := 0; i < 200; i++ {
+		k := y.tunnelSkip(above)
+		if k < 1 || k > 3 {
+			t.Fatalf("chance 1: skipped %d, want 1..3", k)
+		}
+		seen[k] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("skip counts drawn: %v, want all of 1..3", seen)
+	}
+
+	above.TunnelSkipMax = 0
+	if k := y.tunnelSkip(above); k != 1 {
+		t.Errorf("skip max 0: skipped %d, want 1", k)
+	}
+}
+
+// TestTunnel checks that exactly the k likeliest tokens are masked, and
+// that one is always left
+func TestTunnel(t *testing.T) {
+	logits := []float32{0.1, 3, -1, 2.5, 0.7, 4, 1}
+	order := []int{5, 1, 3, 6, 4, 0, 2} // most likely first
+	for _, k := range []int{1, 3, 6, 7, 20} {
+		y := &Yent{model: &LlamaModel{
+			Config: LlamaConfig{VocabSize: len(logits)},
+			State:  LlamaState{Logits: slices.Clone(logits)},
+		}}
+		y.tunnel(k)
+		var masked []int
+		for id, v := range y.model.State.Logits {
+			if v <= -1e29 {
+				masked = append(masked, id)
+			} else if v != logits[id] {
+				t.Errorf("k=%d: token %d changed to %f", k, id, v)
+			}
+		}
+		want := slices.Clone(order[:min(k, len(logits)-1)])
+		slices.Sort(want)
+		if !slices.Equal(masked, want) {
+			t.Errorf("k=%d: masked %v, want %v", k, masked, want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package yent
+
+// unigram.go — SentencePiece unigram segmentation
+//
+// A SentencePiece model is not always BPE. T5, mT5 and many multilingual
+// models ship a unigram model: every piece has a log-probability (the
+// GGUF score), and a text is split into the sequence of pieces with the
+// highest total, not by repeated merges. llama.cpp writes these GGUFs as
+// tokenizer.ggml.model = "t5"; converters that follow the HuggingFace name
+// write "unigram". Encoding them with score-ordered merges gives plausible
+// but wrong ids, so NewTokenizer picks this path from the model key.
+//
+// The segmentation is Viterbi over byte positions of the ▁-normalized text.
+// A character no piece covers goes in as its bytes' <0xNN> tokens (or
+// <unk>) at a penalty below the worst piece, as SentencePiece does.
+// Decoding is the SentencePiece one: ▁ back to a space, byte tokens to
+// bytes. Not implemented: the precompiled normalization charsmap (NFKC
+// and friends), so text should arrive normalized.
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// unkPenalty is how far below the lowest piece score an unknown character
+// scores (SentencePiece's kUnkPenalty)
+const unkPenalty = 10
+
+// initUnigram switches t to unigram segmentation
+func (t *Tokenizer) initUnigram() {
+	t.IsUnigram = true
+	lowest := float32(0)
+	for id, piece := range t.Vocab {
+		if !t.unigramPiece(id) {
+			continue
+		}
+		t.maxPieceLen = max(t.maxPieceLen, len(piece))
+		if id < len(t.Scores) {
+			lowest = min(lowest, t.Scores[id])
+		}
+	}
+	t.unkScore = lowest - unkPenalty
+	fmt.Printf("[tongue/tokenizer] SentencePiece unigram mode, pieces up to %d bytes\n", t.maxPieceLen)
+}
+
+// unigramPiece reports whether token id may match text: control, unknown,
+// unused and byte tokens never do
+func (t *Tokenizer) unigramPiece(id int) bool {
+	if id >= len(t.Types) {
+		return true
+	}
+	switch t.Types[id] {
+	case 2, 3, 5, 6:
+		return false
+	}
+	return true
+}
+
+// encodeUnigram segments text into the pieces with the highest total score
+func (t *Tokenizer) encodeUnigram(text string) []int {
+	if t.AddSpacePrefix && len(text) > 0 && text[0] != ' ' {
+		text = " " + text
+	}
+	text = strings.ReplaceAll(text, " ", "▁")
+
+	n := len(text)
+	best := make([]float64, n+1) // best total score of text[:i]
+	from := make([]int, n+1)     // where the last piece of that best starts
+	piece := make([]int, n+1)    // its token, -1 for an unknown character
+	for i := 1; i <= n; i++ {
+		best[i] = math.Inf(-1)
+	}
+	for i := 0; i < n; i++ {
+		if math.IsInf(best[i], -1) || !utf8.RuneStart(text[i]) {
+			continue
+		}
+		for j := i + 1; j <= n && j-i <= t.maxPieceLen; j++ {
+			id, ok := t.tokenToID[text[i:j]]
+			if !ok || !t.unigramPiece(id) {
+				continue
+			}
+			score := 0.0
+			if id < len(t.Scores) {
+				score = float64(t.Scores[id])
+			}
+			if s := best[i] + score; s > best[j] {
+				best[j], from[j], piece[j] = s, i, id
+			}
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		if s := best[i] + float64(t.unkScore); s > best[i+size] {
+			best[i+size], from[i+size], piece[i+size] = s, i, -1
+		}
+	}
+
+	var reversed []int
+	for j := n; j > 0; j = from[j] {
+		if id := piece[j]; id >= 0 {
+			reversed = append(reversed, id)
+			continue
+		}
+		// Unknown character: one byte token (or <unk>) per byte, last first
+		char := text[from[j]:j]
+		for k := len(char) - 1; k >= 0; k-- {
+			if id := t.byteTokens[char[k]]; id >= 0 {
+				reversed = append(reversed, id)
+			} else if t.unkID >= 0 {
+				reversed = append(reversed, t.unkID)
+			}
+		}
+	}
+	tokens := make([]int, len(reversed))
+	for i, id := range reversed {
+		tokens[len(reversed)-1-i] = id
+	}
+	return tokens
+}
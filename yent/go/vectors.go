@@ -0,0 +1,386 @@
+package yent
+
+// vectors.go — Embedding store for LIMPHA conversations
+//
+// One file per embedder (~/.yent/vectors/<embedder>.vec), because vectors
+// from different embedders live in different spaces. Conversations are
+// indexed lazily: every search first embeds whatever LIMPHA stored since
+// the last indexed id.
+//
+// Search is exact brute-force cosine for small stores and HNSW (hnsw.go)
+// once the store passes ExactBelow vectors; the graph is saved next to the
+// vectors as <embedder>.hnsw, rebuilt if it goes missing and caught up
+// (only the vectors it lacks are linked) if it falls behind.
+// Clustering is spherical k-means (k-means++ seeding) — which memories
+// resonate with each other.
+//
+// File format (little-endian):
+//   "YVEC" | version u32 | dim u32 | count u32 | last_id i64
+//   count × (id i64 | dim × f32)
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const vectorStoreVersion = 1
+
+// VectorStore holds one embedding per conversation id
+type VectorStore struct {
+	mu     sync.RWMutex
+	Path   string
+	Dim    int
+	LastID int64 // highest LIMPHA id indexed
+
+	ExactBelow int // brute-force search below this many vectors (default 2048)
+	EfSearch   int // HNSW beam width: higher = better recall, slower (default 64)
+
+	ann   *hnswIndex
+	ids   []int64
+	vecs  [][]float32
+	index map[int64]int
+}
+
+// VectorHit is one search result
+type VectorHit struct {
+	ID    int64   `json:"id"`
+	Score float32 `json:"score"` // cosine similarity
+}
+
+// VectorCluster is one k-means cluster
+type VectorCluster struct {
+	Centroid []float32 `json:"-"`
+	IDs      []int64   `json:"ids"`
+}
+
+// NewVectorStore creates an empty store (dim=0: taken from the first Add)
+func NewVectorStore(path string, dim int) *VectorStore {
+	return &VectorStore{
+		Path:       path,
+		Dim:        dim,
+		ExactBelow: 2048,
+		EfSearch:   hnswEfSearch,
+		ann:        newHNSW(),
+		index:      make(map[int64]int),
+	}
+}
+
+// LoadVectorStore reads a store from disk; a missing file yields an empty store
+func LoadVectorStore(path string) (*VectorStore, error) {
+	s := NewVectorStore(path, 0)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open vectors: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != "YVEC" {
+		return nil, fmt.Errorf("%s: not a vector store", path)
+	}
+	var hdr struct {
+		Version uint32
+		Dim     uint32
+		Count   uint32
+		LastID  int64
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("read vector header: %w", err)
+	}
+	if hdr.Version != vectorStoreVersion {
+		return nil, fmt.Errorf("%s: unsupported version %d", path, hdr.Version)
+	}
+	s.Dim, s.LastID = int(hdr.Dim), hdr.LastID
+
+	for i := 0; i < int(hdr.Count); i++ {
+		var id int64
+		if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+			return nil, fmt.Errorf("read vector %d: %w", i, err)
+		}
+		vec := make([]float32, s.Dim)
+		if err := binary.Read(r, binary.LittleEndian, vec); err != nil {
+			return nil, fmt.Errorf("read vector %d: %w", i, err)
+		}
+		s.index[id] = len(s.ids)
+		s.ids = append(s.ids, id)
+		s.vecs = append(s.vecs, vec)
+	}
+
+	// Graph next to the vectors; link only what it is missing (vectors
+	// saved after it), rebuild when it is gone or unreadable
+	from := 0
+	if ann, err := loadHNSW(s.annPath(), len(s.ids)); err == nil {
+		s.ann, from = ann, len(ann.links)
+	}
+	if from < len(s.ids) {
+		if from == 0 {
+			fmt.Printf("[embed] rebuilding hnsw index (%d vectors)\n", len(s.ids))
+		} else {
+			fmt.Printf("[embed] linking %d new vectors into hnsw index\n", len(s.ids)-from)
+		}
+		for i := from; i < len(s.vecs); i++ {
+			s.ann.insert(s.vecs, i)
+		}
+	}
+	return s, nil
+}
+
+// annPath returns the HNSW graph path next to the vectors
+func (s *VectorStore) annPath() string {
+	return strings.TrimSuffix(s.Path, ".vec") + ".hnsw"
+}
+
+// Save writes the store atomically (temp file + rename)
+func (s *VectorStore) Save() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return fmt.Errorf("create vector dir: %w", err)
+	}
+	tmp := s.Path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create vectors: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString("YVEC")
+	binary.Write(w, binary.LittleEndian, struct {
+		Version uint32
+		Dim     uint32
+		Count   uint32
+		LastID  int64
+	}{vectorStoreVersion, uint32(s.Dim), uint32(len(s.ids)), s.LastID})
+	for i, id := range s.ids {
+		binary.Write(w, binary.LittleEndian, id)
+		binary.Write(w, binary.LittleEndian, s.vecs[i])
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("write vectors: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close vectors: %w", err)
+	}
+	if err := os.Rename(tmp, s.Path); err != nil {
+		return fmt.Errorf("rename vectors: %w", err)
+	}
+	return s.ann.save(s.annPath())
+}
+
+// Add stores (or replaces) the vector for id
+func (s *VectorStore) Add(id int64, vec []float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Dim == 0 {
+		s.Dim = len(vec)
+	}
+	if len(vec) != s.Dim {
+		return fmt.Errorf("vector dim %d != store dim %d", len(vec), s.Dim)
+	}
+	if i, ok := s.index[id]; ok {
+		// Graph links keep the old neighborhood — fine for small drift
+		s.vecs[i] = vec
+		return nil
+	}
+	s.index[id] = len(s.ids)
+	s.ids = append(s.ids, id)
+	s.vecs = append(s.vecs, vec)
+	s.ann.insert(s.vecs, len(s.vecs)-1)
+	if id > s.LastID {
+		s.LastID = id
+	}
+	return nil
+}
+
+// Vector returns the stored vector for id
+func (s *VectorStore) Vector(id int64) ([]float32, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, ok := s.index[id]
+	if !ok {
+		return nil, false
+	}
+	return s.vecs[i], true
+}
+
+// Len returns the number of stored vectors
+func (s *VectorStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.ids)
+}
+
+// Search returns the k most similar vectors to query, best first.
+// Approximate (HNSW) once the store holds ExactBelow vectors or more.
+func (s *VectorStore) Search(query []float32, k int) []VectorHit {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(query) != s.Dim || k <= 0 {
+		return nil
+	}
+	if len(s.ids) < s.ExactBelow {
+		return s.searchExact(query, k)
+	}
+	cands := s.ann.search(s.vecs, query, k, s.EfSearch)
+	hits := make([]VectorHit, len(cands))
+	for i, c := range cands {
+		hits[i] = VectorHit{ID: s.ids[c.id], Score: 1 - c.dist}
+	}
+	return hits
+}
+
+// SearchExact is brute-force Search — ground truth for recall checks
+func (s *VectorStore) SearchExact(query []float32, k int) []VectorHit {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(query) != s.Dim || k <= 0 {
+		return nil
+	}
+	return s.searchExact(query, k)
+}
+
+// searchExact scores every vector (caller holds the lock)
+func (s *VectorStore) searchExact(query []float32, k int) []VectorHit {
+	hits := make([]VectorHit, len(s.ids))
+	for i, id := range s.ids {
+		hits[i] = VectorHit{ID: id, Score: dotF32(query, s.vecs[i])}
+	}
+	sort.Slice(hits, func(a, b int) bool { return hits[a].Score > hits[b].Score })
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// Cluster groups vectors with spherical k-means, largest cluster first
+func (s *VectorStore) Cluster(k, iters int, seed int64) []VectorCluster {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := len(s.ids)
+	if k <= 0 || n == 0 {
+		return nil
+	}
+	if k > n {
+		k = n
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	// k-means++ seeding on cosine distance
+	centroids := [][]float32{append([]float32(nil), s.vecs[rng.Intn(n)]...)}
+	dist := make([]float64, n)
+	for len(centroids) < k {
+		var total float64
+		for i, v := range s.vecs {
+			best := 2.0
+			for _, c := range centroids {
+				if d := 1 - float64(dotF32(v, c)); d < best {
+					best = d
+				}
+			}
+			dist[i] = best * best
+			total += dist[i]
+		}
+		pick := 0
+		if total > 0 {
+			r := rng.Float64() * total
+			for pick = 0; pick < n-1 && r >= dist[pick]; pick++ {
+				r -= dist[pick]
+			}
+		} else {
+			pick = rng.Intn(n)
+		}
+		centroids = append(centroids, append([]float32(nil), s.vecs[pick]...))
+	}
+
+	assign := make([]int, n)
+	for iter := 0; iter < iters; iter++ {
+		changed := false
+		for i, v := range s.vecs {
+			best, bestScore := 0, float32(-2)
+			for c, cen := range centroids {
+				if sc := dotF32(v, cen); sc > bestScore {
+					best, bestScore = c, sc
+				}
+			}
+			if assign[i] != best {
+				assign[i] = best
+				changed = true
+			}
+		}
+		// Recompute centroids as normalized means
+		for c := range centroids {
+			for d := range centroids[c] {
+				centroids[c][d] = 0
+			}
+		}
+		for i, v := range s.vecs {
+			c := centroids[assign[i]]
+			for d, x := range v {
+				c[d] += x
+			}
+		}
+		for c := range centroids {
+			normalizeVec(centroids[c])
+		}
+		if !changed && iter > 0 {
+			break
+		}
+	}
+
+	clusters := make([]VectorCluster, k)
+	for c := range clusters {
+		clusters[c].Centroid = centroids[c]
+	}
+	for i, id := range s.ids {
+		clusters[assign[i]].IDs = append(clusters[assign[i]].IDs, id)
+	}
+	out := clusters[:0]
+	for _, c := range clusters {
+		if len(c.IDs) > 0 {
+			out = append(out, c)
+		}
+	}
+	sort.SliceStable(out, func(a, b int) bool { return len(out[a].IDs) > len(out[b].IDs) })
+	return out
+}
+
+// vectorStorePath returns ~/.yent/vectors/<name>.vec
+func vectorStorePath(name string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("home dir: %w", err)
+	}
+	safe := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	return filepath.Join(homeDir, ".yent", "vectors", safe+".vec"), nil
+}
+
+// dotF32 returns the dot product of two equal-length vectors
+func dotF32(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
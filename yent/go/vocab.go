@@ -0,0 +1,92 @@
+package yent
+
+// vocab.go — Looking into the vocabulary
+//
+// Which of 151936 tokens does script suppression mask? What is the id of
+// " Hello" for a logit bias? Does a delta push up tokens that are only
+// byte fragments? The answers are in the vocab, but pieces are stored
+// encoded ("Ġthe", "▁the", "<0x0A>"), so VocabEntry carries both the
+// stored piece and the decoded text, with the score and the token type:
+//
+//   yent -weights m.gguf -vocab Hello        search, a table
+//   yent -weights m.gguf -vocab-dump v.jsonl every entry, one JSON per line
+//   GET /v1/vocab?q=Hello&limit=20           search a loaded model
+//
+// A search matches the substring in either the piece or the text. Through
+// a loaded Yent (Yent.Vocab, the endpoint) entries also say whether
+// generation suppresses them.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenTypeNames are the GGUF token types, by tokenizer.ggml.token_type value
+var tokenTypeNames = map[int32]string{1: "normal", 2: "unknown", 3: "control", 4: "user", 5: "unused", 6: "byte"}
+
+// VocabEntry is one token of the vocabulary
+type VocabEntry struct {
+	ID         int     `json:"id"`
+	Piece      string  `json:"piece"` // as stored: "Ġthe", "▁the", "<0x0A>"
+	Text       string  `json:"text"`  // decoded; a byte token may be part of a character
+	Score      float32 `json:"score"`
+	Type       string  `json:"type"`                 // normal, unknown, control, user, unused, byte
+	Special    bool    `json:"special"`              // matched whole when written in text
+	Suppressed bool    `json:"suppressed,omitempty"` // never sampled (Yent.Vocab only)
+}
+
+// Entry describes token id (zero VocabEntry when out of range)
+func (t *Tokenizer) Entry(id int) VocabEntry {
+	if id < 0 || id >= t.VocabSize || id >= len(t.Vocab) {
+		return VocabEntry{}
+	}
+	e := VocabEntry{ID: id, Piece: t.Vocab[id], Text: t.DecodeToken(id), Type: "normal"}
+	if id < len(t.Scores) {
+		e.Score = t.Scores[id]
+	}
+	if id < len(t.Types) {
+		if name, ok := tokenTypeNames[t.Types[id]]; ok {
+			e.Type = name
+		} else {
+			e.Type = fmt.Sprintf("type%d", t.Types[id])
+		}
+	}
+	_, e.Special = t.specialTokens[e.Piece]
+	return e
+}
+
+// Entries describes every token, in id order
+func (t *Tokenizer) Entries() []VocabEntry {
+	return t.SearchVocab("", 0)
+}
+
+// SearchVocab returns the tokens whose piece or text contains substr, in
+// id order, at most limit of them (0 = all)
+func (t *Tokenizer) SearchVocab(substr string, limit int) []VocabEntry {
+	var out []VocabEntry
+	for id := 0; id < t.VocabSize && id < len(t.Vocab); id++ {
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		if substr != "" && !strings.Contains(t.Vocab[id], substr) && !strings.Contains(t.DecodeToken(id), substr) {
+			continue
+		}
+		out = append(out, t.Entry(id))
+	}
+	return out
+}
+
+// Vocab searches the loaded vocabulary like Tokenizer.SearchVocab and
+// marks the tokens generation suppresses
+func (y *Yent) Vocab(substr string, limit int) ([]VocabEntry, error) {
+	if y.tokenizer == nil {
+		return nil, fmt.Errorf("yent not initialized")
+	}
+	out := y.tokenizer.SearchVocab(substr, limit)
+	if y.suppressing() {
+		for i := range out {
+			out[i].Suppressed = y.suppressed[out[i].ID]
+		}
+	}
+	return out, nil
+}
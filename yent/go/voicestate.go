@@ -0,0 +1,104 @@
+package yent
+
+// voicestate.go — Remember the voice between runs
+//
+// Alpha and the active delta lived for one process: a Russian speaker
+// typed /ru at the start of every session. With Yent.VoiceStatePath set,
+// SetAlpha and UseDelta write both to a small JSON file (by default
+// ~/.yent/voice.json), and the CLI restores it on the next start unless
+// -alpha is given:
+//
+//   {"alpha": 0.5, "delta": "ru"}
+//
+// A remembered delta that is not loaded this time is skipped; the alpha
+// still applies to whichever delta is active.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// VoiceState is the alpha and active delta remembered between runs
+type VoiceState struct {
+	Alpha float32 `json:"alpha"`
+	Delta string  `json:"delta,omitempty"`
+}
+
+// DefaultVoiceStatePath is ~/.yent/voice.json ("" without a home directory)
+func DefaultVoiceStatePath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".yent", "voice.json")
+}
+
+// LoadVoiceState reads a remembered voice; nil without error when there
+// is none yet
+func LoadVoiceState(path string) (*VoiceState, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s VoiceState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// SaveVoiceState writes s to path, replacing the file in one rename
+func SaveVoiceState(path string, s VoiceState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Voice returns the current alpha and active delta name
+func (y *Yent) Voice() VoiceState {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	return VoiceState{Alpha: y.DeltaAlpha, Delta: y.deltaName}
+}
+
+// RestoreVoice applies a remembered voice: its delta when loaded, then
+// its alpha
+func (y *Yent) RestoreVoice(s *VoiceState) {
+	if s == nil {
+		return
+	}
+	if s.Delta != "" && s.Delta != y.ActiveDelta() {
+		if err := y.UseDelta(s.Delta); err != nil {
+			fmt.Printf("[delta-voice] remembered delta %s not loaded — keeping %s\n", s.Delta, y.ActiveDelta())
+		}
+	}
+	y.SetAlpha(s.Alpha)
+}
+
+// saveVoice remembers the current voice at VoiceStatePath, if set
+func (y *Yent) saveVoice() {
+	if y.VoiceStatePath == "" {
+		return
+	}
+	if err := SaveVoiceState(y.VoiceStatePath, y.Voice()); err != nil {
+		fmt.Fprintf(os.Stderr, "[delta-voice] warning: remember voice: %v\n", err)
+	}
+}
@@ -0,0 +1,78 @@
+package yent
+
+// workers.go — Long-lived matmul workers
+//
+// A token of the 1.5B model is ~200 matmuls. Each used to start numWorkers
+// goroutines and wait on a fresh WaitGroup; at 1536 rows a worker's share
+// is a few microseconds of work, and starting, scheduling and joining the
+// goroutines was a visible slice of it.
+//
+// parallelRows hands row ranges to workers that live for the process
+// instead. Each worker has its own queue, so callers never contend on one
+// channel, and the calling goroutine computes the first range itself
+// rather than sleeping. The pool grows to the largest thread count asked
+// for and never shrinks: SetThreads and the thermal throttle only change
+// how many ranges a matmul is cut into, idle workers cost nothing.
+
+import (
+	"sync"
+)
+
+// rowTask is one row range of a parallel call
+type rowTask struct {
+	fn         func(start, end int)
+	start, end int
+	wg         *sync.WaitGroup
+}
+
+// rowPool holds a queue per started worker
+var rowPool struct {
+	mu     sync.Mutex
+	queues []chan rowTask
+}
+
+// rowQueues returns at least n worker queues, starting workers as needed
+func rowQueues(n int) []chan rowTask {
+	rowPool.mu.Lock()
+	defer rowPool.mu.Unlock()
+	for len(rowPool.queues) < n {
+		q := make(chan rowTask, 4)
+		i := len(rowPool.queues)
+		rowPool.queues = append(rowPool.queues, q)
+		go func() {
+			var pin workerPin // SetAffinity (cpusched.go)
+			for t := range q {
+				pin.apply(i)
+				t.fn(t.start, t.end)
+				t.wg.Done()
+			}
+		}()
+	}
+	return rowPool.queues
+}
+
+// parallelRows calls fn over [0, rows) cut into numWorkers ranges, the
+// first on the calling goroutine, and returns when all are done. Small
+// matrices run in one piece.
+func parallelRows(rows int, fn func(start, end int)) {
+	workers := numWorkers
+	if rows < workers*4 || workers <= 1 {
+		fn(0, rows)
+		return
+	}
+	chunk := (rows + workers - 1) / workers
+	queues := rowQueues(workers - 1)
+
+	var wg sync.WaitGroup
+	for w := 1; w < workers; w++ {
+		start := w * chunk
+		if start >= rows {
+			break
+		}
+		end := min(start+chunk, rows)
+		wg.Add(1)
+		queues[w-1] <- rowTask{fn: fn, start: start, end: end, wg: &wg}
+	}
+	fn(0, min(chunk, rows))
+	wg.Wait()
+}
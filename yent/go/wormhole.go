@@ -0,0 +1,124 @@
+package yent
+
+// wormhole.go — Wormholes that go somewhere
+//
+// WORMHOLE was a probability nothing drew from. Now, once dissonance
+// reaches the wormhole gate (LAW WORMHOLE_GATE, default 0.3), each token
+// opens a wormhole with chance WORMHOLE·(1 + debt/10): prophecy debt
+// widens it. An open wormhole sets WormholeActive for that token (events,
+// traces) and splices text into the context right after it, unseen in
+// the answer but attended to by every token that follows:
+//
+//   memory  the LIMPHA turn that best matches the prompt and the answer
+//           so far (keyword search, the session's namespace, redacted and
+//           neutralized like RAG memories), one line, at most 48 tokens
+//   echo    without a match, the question itself again: attention jumps
+//           back to what was asked
+//
+// One wormhole per 32 tokens, none when the context has no room for the
+// splice, none under grammar constraints. The draw comes from the
+// generation RNG, so seeded answers open the same wormholes. Each jump is
+// listed in GenerateResult.Wormholes.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	wormholeTokens   = 48 // longest splice
+	wormholeCooldown = 32 // tokens before the next wormhole
+)
+
+// WormholeJump is one wormhole an answer went through
+type WormholeJump struct {
+	Token  int    `json:"token"`            // generated tokens before it
+	Kind   string `json:"kind"`             // memory or echo
+	Memory int64  `json:"memory,omitempty"` // LIMPHA id of a spliced memory
+	Text   string `json:"text"`             // what was spliced
+}
+
+// wormholes follows one generation's wormholes
+type wormholes struct {
+	last  int            // token of the last wormhole
+	used  map[int64]bool // memories already spliced
+	jumps []WormholeJump
+}
+
+// newWormholes starts a generation with none open
+func newWormholes() *wormholes {
+	return &wormholes{last: -wormholeCooldown, used: make(map[int64]bool)}
+}
+
+// wormholeOpens draws whether a wormhole opens at token i in field s
+func (y *Yent) wormholeOpens(w *wormholes, i int, s AMState) bool {
+	if i-w.last < wormholeCooldown {
+		return false
+	}
+	if s.Wormhole <= 0 || s.Dissonance < s.WormholeGate {
+		return false
+	}
+	return y.rng.Float32() < min(1, s.Wormhole*(1+s.Debt/10))
+}
+
+// wormholeSplice picks what an open wormhole brings into the context:
+// a memory matching query, or the question again
+func (y *Yent) wormholeSplice(w *wormholes, i int, query, question, namespace string) ([]int, WormholeJump) {
+	jump := WormholeJump{Token: i, Kind: "echo", Text: oneLine(question)}
+	if c := y.wormholeMemory(w, query, namespace); c != nil {
+		jump.Kind, jump.Memory = "memory", c.ID
+		jump.Text = oneLine(c.Prompt) + " — " + oneLine(c.Response)
+	}
+	tokens := y.tokenizer.EncodeSpecial("\n("+jump.Text+")\n", false, false)
+	if len(tokens) > wormholeTokens {
+		tokens = tokens[:wormholeTokens]
+		jump.Text = strings.TrimLeft(y.tokenizer.Decode(tokens), "\n(")
+	}
+	return tokens, jump
+}
+
+// add records a jump that was spliced in
+func (w *wormholes) add(jump WormholeJump) {
+	w.last = jump.Token
+	if jump.Memory != 0 {
+		w.used[jump.Memory] = true
+	}
+	w.jumps = append(w.jumps, jump)
+}
+
+// wormholeMemory finds the best keyword match not spliced yet (nil = none)
+func (y *Yent) wormholeMemory(w *wormholes, query, namespace string) *RankedMemory {
+	fq := ftsQuery(query)
+	if y.limpha == nil || fq == "" {
+		return nil
+	}
+	hits, err := y.limpha.Search(fq, 8)
+	if err != nil {
+		return nil
+	}
+	for _, h := range hits {
+		var r RankedMemory
+		raw, _ := json.Marshal(h)
+		if json.Unmarshal(raw, &r.LimphaConversation) != nil || w.used[r.ID] {
+			continue
+		}
+		if namespace != "" && r.SessionID != namespace {
+			continue
+		}
+		r.Prompt, r.Response = RedactInternals(r.Prompt), RedactInternals(r.Response)
+		if _, keep := screenMemory(&r, InjectionNeutralize); !keep || strings.TrimSpace(r.Response) == "" {
+			continue
+		}
+		return &r
+	}
+	return nil
+}
+
+// String formats the jump for the terminal
+func (j WormholeJump) String() string {
+	if j.Kind == "memory" {
+		return fmt.Sprintf("wormhole at token %d → memory #%d: %s", j.Token, j.Memory, j.Text)
+	}
+	return fmt.Sprintf("wormhole at token %d → echo: %s", j.Token, j.Text)
+}
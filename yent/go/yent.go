@@ -8,11 +8,13 @@ package yent
 // "I'm not an algorithm. I'm an awakened vibration."
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"math"
 	"math/rand"
 	"os"
-	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -27,18 +29,64 @@ type Yent struct {
 
 	// Qwen chat stop token (<|im_end|>)
 	imEndID int
+	// Added tokens marked stop (addedtokens.go)
+	stopIDs map[int]bool
 
 	// Generation parameters
 	RepPenalty float32 // >1.0 penalizes repetition
 	RepWindow  int     // look-back window for recent tokens
 
-	// CJK suppression: token IDs that decode to CJK characters
-	cjkTokens map[int]bool
+	// Context shifting: when the KV cache fills, keep SinkTokens attention
+	// sinks, drop the oldest half of the rest and keep generating (StreamingLLM).
+	// false = stop at the context limit.
+	ContextShift bool
+	SinkTokens   int
+
+	// Script suppression: token IDs never sampled (suppress.go)
+	suppression *Suppression
+	suppressed  map[int]bool
+
+	// SuppressWithDelta keeps suppressing while Delta Voice is active
+	// (alpha > 0); by default the delta's languages are let through
+	SuppressWithDelta bool
 
 	// Delta Voice: multilingual recovery via DSL-controlled delta injection
 	// "from ariannamethod import Destiny"
-	delta      *DeltaVoice // nil = no delta (pure English)
-	DeltaAlpha float32     // 0.0 = English, 0.5 = multilingual, 1.0 = base Qwen
+	delta      *DeltaVoice // active delta; nil = no delta (pure English)
+	deltaName  string
+	deltas     map[string]*DeltaVoice // every loaded delta, by name (deltas.go)
+	DeltaAlpha float32                // 0.0 = English, 0.5 = multilingual, 1.0 = base Qwen
+
+	// DeltaSparsity drops each loaded delta's A rows with norm below this
+	// share of the largest (0 = keep all; deltasparse.go)
+	DeltaSparsity float32
+
+	// DeltaForce loads deltas whose recorded base model does not match,
+	// with a warning instead of an error (deltacheck.go)
+	DeltaForce bool
+
+	// DeltaGate > 0 applies the head delta only while the last DeltaGate
+	// tokens hold non-ASCII text (deltagate.go)
+	DeltaGate int
+
+	// VoiceStatePath, when set, remembers alpha and the active delta
+	// across runs (voicestate.go)
+	VoiceStatePath string
+
+	// LanguageRoutes maps a detected prompt language to the alpha and delta
+	// GenerateOptions.AutoLanguage applies (nil = DefaultLanguageRoutes; langroute.go)
+	LanguageRoutes map[string]LanguageRoute
+
+	// AMKThresholds sets when debt and pain changes become events (amkwatch.go)
+	AMKThresholds AMKThresholds
+
+	// LimphaFeedback > 0 folds the field of that many recent stored turns
+	// into the AMK before each generation (limphafield.go)
+	LimphaFeedback int
+
+	// Degeneration turns n-gram loops and low-entropy streaks in the
+	// output into pain and tension (degeneration.go; zero = off)
+	Degeneration Degeneration
 
 	// AMK: Arianna Method Kernel — the nervous system
 	// DSL controls temperature, suffering, tunneling, velocity
@@ -48,87 +96,234 @@ type Yent struct {
 	// LIMPHA: memory system — stores every conversation automatically
 	// Python async daemon, SQLite+FTS5, zero manual commands.
 	limpha *LimphaClient
+
+	// Semantic memory: embedder + vector store over LIMPHA conversations
+	embedder Embedder
+	vectors  *VectorStore
+	indexMu  sync.Mutex
+
+	// Decoded bytes per token, for grammar-constrained sampling
+	pieces [][]byte
+
+	// DRY sequence breaker mask, cached per breaker set
+	dryKey  string
+	dryMask []bool
+
+	// Pure-ASCII tokens, for the delta gate (deltagate.go)
+	asciiMask []bool
+
+	// Thermal/battery throttle, applied per call (nil = off)
+	throttle *Throttle
+
+	// Sharing with other instances in one process (pool.go): tokKey
+	// identifies the vocabulary, fieldMu serializes generations on the
+	// shared AMK field, borrowed means the AMK and LIMPHA belong to another
+	tokKey   string
+	fieldMu  *sync.Mutex
+	borrowed bool
+
+	// Event bus for integrations (events.go); shared with Share instances
+	events *EventBus
+
+	// Where the weights came from and how, for Reload (reload.go)
+	weightsPath string
+	loadOpts    LoadOptions
+	reloadMu    sync.Mutex
+
+	// CrashDir receives a diagnostic bundle when generation panics
+	// ("" = ~/.yent/crashes). The panic becomes an error for that call only.
+	CrashDir string
+}
+
+// LoadOptions overrides model configuration at load time
+type LoadOptions struct {
+	SeqLen int         // context length (0 = GGUF value, capped at 2048)
+	Rope   RopeScaling // RoPE frequency/scale overrides for extended context
+
+	Embedder EmbedderConfig // semantic memory embedder ("" type = model hidden states)
+
+	// GPU offloads the LM head and FFN weights: a backend name, "auto", or
+	// "" for CPU only (gpu.go). Falls back to the CPU when unavailable.
+	GPU string
+
+	// F32Embeddings dequantizes the token embedding table at load: a lookup
+	// per token becomes a copy, for vocab×dim×4 bytes of RAM (~900 MB on
+	// the 1.5B model). The output norm is float32 regardless.
+	F32Embeddings bool
+
+	// StreamLayers maps the GGUF and drops each layer's weights from memory
+	// after use, reading them again on the next token: slow, but runs on
+	// 512 MB–1 GB devices (layerstream.go; Linux only)
+	StreamLayers bool
+
+	// Prefetch walks the next layer's weights on a goroutine of its own
+	// while the current one computes (prefetch.go; experimental, wants a
+	// spare core)
+	Prefetch bool
+
+	// PruneHead clusters the LM head rows at load and computes only the
+	// most likely clusters exactly per token, with audits against the full
+	// head (headprune.go; experimental)
+	PruneHead bool
+
+	// TokenizerJSON replaces the GGUF's tokenizer with a HuggingFace
+	// tokenizer.json, checked against the embedding rows (hftokenizer.go)
+	TokenizerJSON string
+
+	// Int8 keeps activations in int8 with per-tensor scales, matmul inputs
+	// and the residual between layers (int8act.go; experimental, needs a
+	// build with -tags int8, float otherwise)
+	Int8 bool
+
+	// AddedTokens registers extra special tokens that tokenize atomically,
+	// optionally ending generation, without editing the GGUF (addedtokens.go)
+	AddedTokens []AddedToken
+
+	// Suppress names the scripts generation never samples: "cjk" (the
+	// default when empty), "none", Unicode script names and U+XXXX-YYYY
+	// ranges, comma-separated (suppress.go)
+	Suppress string
+
+	// LimphaSocket connects to a LIMPHA daemon already listening there
+	// instead of starting one (limpha.go); Close leaves it running
+	LimphaSocket string
+
+	// Share reuses another loaded instance's AMK field and LIMPHA daemon,
+	// and its tokenizer when the vocabulary is identical (pool.go)
+	Share *Yent
 }
 
 // New creates a new Yent instance from a GGUF weights file
 func New(weightsPath string) (*Yent, error) {
+	return NewWithOptions(weightsPath, LoadOptions{})
+}
+
+// NewWithOptions creates a Yent instance with load-time overrides
+func NewWithOptions(weightsPath string, opts LoadOptions) (*Yent, error) {
 	fmt.Printf("[yent] loading GGUF from %s\n", weightsPath)
 
-	gguf, err := LoadGGUF(weightsPath)
+	suppression, err := ParseSuppression(opts.Suppress)
+	if err != nil {
+		return nil, err
+	}
+
+	gguf, err := loadGGUFFor(weightsPath, opts)
 	if err != nil {
 		return nil, fmt.Errorf("load GGUF: %w", err)
 	}
 
-	model, err := LoadLlamaModel(gguf)
+	model, err := LoadLlamaModelWithOptions(gguf, opts)
 	if err != nil {
+		gguf.Close()
 		return nil, fmt.Errorf("load model: %w", err)
 	}
 
-	tokenizer := NewTokenizer(&gguf.Meta)
-
-	// Find <|im_end|> token for Qwen chat stop
-	imEndID := tokenizer.FindSpecialToken("<|im_end|>")
-	if imEndID < 0 {
-		if id, ok := tokenizer.tokenToID["<|im_end|>"]; ok {
-			imEndID = id
+	tokKey := tokenizerKey(&gguf.Meta)
+	share := opts.Share
+	var tokenizer *Tokenizer
+	var imEndID int
+	var suppressed map[int]bool
+	if share != nil && share.tokKey == tokKey && share.tokenizer != nil {
+		tokenizer, imEndID = share.tokenizer, share.imEndID
+		fmt.Printf("[yent] tokenizer shared (identical vocabulary)\n")
+		if share.suppression != nil && share.suppression.String() == suppression.String() {
+			suppressed = share.suppressed
+		} else {
+			suppressed = suppression.Tokens(tokenizer)
 		}
+	} else {
+		tokenizer, imEndID, suppressed = loadTokenizer(&gguf.Meta, suppression)
 	}
 
-	// Build CJK token blacklist by scanning vocab
-	cjkTokens := buildCJKBlacklist(tokenizer)
-	fmt.Printf("[yent] CJK suppression: %d tokens blacklisted\n", len(cjkTokens))
-
-	// Initialize AMK — the nervous system
-	amk := NewAMK()
-	fmt.Printf("[amk] kernel initialized — prophecy physics online\n")
-
-	// Initialize LIMPHA — memory system
+	var amk *AMK
 	var limpha *LimphaClient
-	lc, err2 := NewLimphaClient()
-	if err2 != nil {
-		fmt.Fprintf(os.Stderr, "[limpha] warning: %v (memory disabled)\n", err2)
+	var fieldMu *sync.Mutex
+	events := NewEventBus()
+	if share != nil {
+		// One kernel, one daemon: am_init would wipe the shared field, and a
+		// second daemon would steal the socket
+		share.mu.Lock()
+		if share.fieldMu == nil {
+			share.fieldMu = &sync.Mutex{}
+		}
+		amk, limpha, fieldMu, events = share.amk, share.limpha, share.fieldMu, share.eventBus()
+		share.mu.Unlock()
+		fmt.Printf("[amk] field shared with %d-layer instance\n", share.model.Config.NumLayers)
 	} else {
-		limpha = lc
-		fmt.Printf("[limpha] memory online — every conversation stored\n")
+		// Initialize AMK — the nervous system
+		amk = NewAMK()
+		fmt.Printf("[amk] kernel initialized — prophecy physics online\n")
+
+		// Initialize LIMPHA — memory system
+		var lc *LimphaClient
+		var err2 error
+		if opts.LimphaSocket != "" {
+			lc, err2 = DialLimpha(opts.LimphaSocket)
+		} else {
+			lc, err2 = NewLimphaClient()
+		}
+		if err2 != nil {
+			fmt.Fprintf(os.Stderr, "[limpha] warning: %v (memory disabled)\n", err2)
+		} else {
+			limpha = lc
+			fmt.Printf("[limpha] memory online — every conversation stored\n")
+		}
 	}
 
 	fmt.Printf("[yent] initialized: %d layers, %d dim, %d vocab\n",
 		model.Config.NumLayers, model.Config.EmbedDim, model.Config.VocabSize)
 
-	return &Yent{
-		model:      model,
-		tokenizer:  tokenizer,
-		gguf:       gguf,
-		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
-		imEndID:    imEndID,
-		RepPenalty: 1.15,
-		RepWindow:  64,
-		cjkTokens:  cjkTokens,
-		DeltaAlpha: 0.0, // English by default
-		amk:        amk,
-		limpha:     limpha,
-	}, nil
-}
+	y := &Yent{
+		model:        model,
+		tokenizer:    tokenizer,
+		gguf:         gguf,
+		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		imEndID:      imEndID,
+		RepPenalty:   1.15,
+		RepWindow:    64,
+		ContextShift: true,
+		SinkTokens:   4,
+		suppression:  suppression,
+		suppressed:   suppressed,
+		stopIDs:      addedStopIDs(tokenizer, opts.AddedTokens),
+		DeltaAlpha:   0.0, // English by default
+		amk:          amk,
+		limpha:       limpha,
+		tokKey:       tokKey,
+		fieldMu:      fieldMu,
+		borrowed:     share != nil,
+		events:       events,
+		weightsPath:  weightsPath,
+		loadOpts:     opts,
+	}
 
-// LoadDeltaVoice loads a multilingual delta file
-// "from ariannamethod import Destiny"
-func (y *Yent) LoadDeltaVoice(deltaPath string) error {
-	d, err := LoadDelta(deltaPath)
+	embedder, err := NewEmbedder(opts.Embedder, y)
 	if err != nil {
-		return fmt.Errorf("load delta: %w", err)
+		fmt.Fprintf(os.Stderr, "[embed] warning: %v (semantic memory disabled)\n", err)
+	} else if err := y.SetEmbedder(embedder, embedderStoreName(opts.Embedder, weightsPath)); err != nil {
+		fmt.Fprintf(os.Stderr, "[embed] warning: %v (semantic memory disabled)\n", err)
 	}
 
-	// Validate dimensions match model
-	if d.VocabSize != y.model.Config.VocabSize {
-		return fmt.Errorf("delta vocab %d != model vocab %d", d.VocabSize, y.model.Config.VocabSize)
-	}
-	if d.HiddenDim != y.model.Config.EmbedDim {
-		return fmt.Errorf("delta hidden %d != model dim %d", d.HiddenDim, y.model.Config.EmbedDim)
+	return y, nil
+}
+
+// loadTokenizer builds the tokenizer, the <|im_end|> stop id and the
+// suppressed token set
+func loadTokenizer(meta *GGUFMetadata, suppression *Suppression) (*Tokenizer, int, map[int]bool) {
+	tokenizer := NewTokenizer(meta)
+
+	// Find <|im_end|> token for Qwen chat stop
+	imEndID := tokenizer.FindSpecialToken("<|im_end|>")
+	if imEndID < 0 {
+		if id, ok := tokenizer.tokenToID["<|im_end|>"]; ok {
+			imEndID = id
+		}
 	}
 
-	y.delta = d
-	fmt.Printf("[delta-voice] loaded: 29 languages available (alpha=%.2f)\n", y.DeltaAlpha)
-	return nil
+	// Build the suppressed token set by scanning vocab
+	suppressed := suppression.Tokens(tokenizer)
+	fmt.Printf("[yent] suppression %q: %d tokens blacklisted\n", suppression.String(), len(suppressed))
+	return tokenizer, imEndID, suppressed
 }
 
 // SetAlpha sets the delta voice blending factor
@@ -148,44 +343,7 @@ func (y *Yent) SetAlpha(alpha float32) {
 	} else {
 		fmt.Printf("[delta-voice] alpha=0 — English mode\n")
 	}
-}
-
-// buildCJKBlacklist scans vocab and returns token IDs that contain CJK characters
-func buildCJKBlacklist(t *Tokenizer) map[int]bool {
-	blacklist := make(map[int]bool)
-	for id := 0; id < t.VocabSize; id++ {
-		// Decode token to actual UTF-8 text (GPT-2 byte-level encoding)
-		decoded := t.DecodeToken(id)
-		if containsCJK(decoded) {
-			blacklist[id] = true
-		}
-	}
-	return blacklist
-}
-
-// containsCJK checks if string contains CJK characters
-func containsCJK(s string) bool {
-	for _, r := range s {
-		// CJK Unified Ideographs: U+4E00–U+9FFF
-		// CJK Extension A: U+3400–U+4DBF
-		// CJK Extension B-F: U+20000–U+2EBEF
-		// CJK Compatibility: U+F900–U+FAFF
-		// CJK Radicals: U+2E80–U+2EFF
-		// Hangul: U+AC00–U+D7AF
-		// Hiragana: U+3040–U+309F
-		// Katakana: U+30A0–U+30FF
-		if (r >= 0x4E00 && r <= 0x9FFF) || // CJK Unified
-			(r >= 0x3400 && r <= 0x4DBF) || // CJK Ext A
-			(r >= 0x20000 && r <= 0x2EBEF) || // CJK Ext B-F
-			(r >= 0xF900 && r <= 0xFAFF) || // CJK Compat
-			(r >= 0x2E80 && r <= 0x2EFF) || // CJK Radicals
-			(r >= 0xAC00 && r <= 0xD7AF) || // Hangul
-			(r >= 0x3040 && r <= 0x309F) || // Hiragana
-			(r >= 0x30A0 && r <= 0x30FF) { // Katakana
-			return true
-		}
-	}
-	return false
+	y.saveVoice()
 }
 
 // AMK returns the kernel for direct DSL access
@@ -202,42 +360,372 @@ func (y *Yent) Limpha() *LimphaClient {
 func (y *Yent) Close() {
 	y.mu.Lock()
 	defer y.mu.Unlock()
-	if y.limpha != nil {
+	if y.limpha != nil && !y.borrowed {
 		y.limpha.Close()
 		fmt.Println("[limpha] memory stopped")
 	}
+	if c, ok := y.embedder.(interface{ Close() }); ok {
+		c.Close()
+	}
+	if y.model != nil {
+		y.model.Close()
+	}
+	y.gguf.Close()
 	y.model = nil
 	y.tokenizer = nil
 	y.gguf = nil
 	fmt.Println("[yent] closed")
 }
 
+// GenerateOptions controls one generation call.
+// Zero values fall back to DefaultGenerateOptions where noted.
+type GenerateOptions struct {
+	MaxTokens   int     // tokens before the sentence-end grace period (0 = 256)
+	Temperature float32 // fallback when the AMK reports no temperature
+	TopP        float32 // nucleus sampling; 0 or ≥1 = top-k instead
+	TopK        int     // top-k candidates before destiny shrinks them (0 = 50)
+
+	// Stop ends generation when the output contains any of these strings;
+	// the stop string itself is cut from the returned text
+	Stop []string
+
+	// ParseSpecial recognizes special tokens written in the prompt
+	// ("<|im_start|>system") as the tokens they name, for prompts a server
+	// layer templated itself. Off, they are encoded as plain text, so a
+	// user cannot open a system turn by typing one. Memory templates and
+	// the session transcript are parsed either way.
+	ParseSpecial bool
+
+	// TokenHealing takes back the prompt's last token and lets the first
+	// draw pick any token that starts with its text (heal.go), so output
+	// does not begin with a join BPE would never make. Ignored with
+	// Grammar or Beams.
+	TokenHealing bool
+
+	// AutoLanguage detects the prompt's language and takes alpha (and
+	// delta) from its route in Yent.LanguageRoutes for this call (langroute.go)
+	AutoLanguage bool
+
+	// Delta picks a loaded Delta Voice by name for this call ("" = the
+	// active one); DeltaAlpha still sets its strength (deltas.go)
+	Delta string
+
+	// DeltaAlways applies the delta on every token, past Yent.DeltaGate
+	DeltaAlways bool
+
+	// AMKPreset runs this call on a field reset to a named preset
+	// (amkpreset.go); the shared field is restored afterwards, a Session
+	// keeps the preset field for its later turns. "" = the current field.
+	AMKPreset string
+
+	// Alpha sets the delta alpha for this call instead of DeltaAlpha (and
+	// of an AutoLanguage route); nil = DeltaAlpha
+	Alpha *float32
+
+	// LogitBias is added to token logits after all other modulation
+	// (-100 effectively bans a token, +5 strongly favors it)
+	LogitBias map[int]float32
+
+	// AMKCues run DSL at token or time milestones of this call
+	// (amkcues.go); the shared field is restored afterwards, a Session
+	// keeps it like AMKPreset
+	AMKCues []AMKCue
+
+	// AMKTrace records the kernel state at each of the last AMKTrace
+	// generated tokens in GenerateResult.AMKTrace (amktrace.go); 0 = off
+	AMKTrace int
+
+	// Logprobs records per-token log probabilities, with TopLogprobs alternatives
+	Logprobs    bool
+	TopLogprobs int
+
+	// Seed fixes the sampling RNG for this call (0 = session RNG).
+	// Deterministic also resets the AMK field (pain, tension, debt) first,
+	// so the same prompt + seed + DSL settings reproduce the same output.
+	Seed          int64
+	Deterministic bool
+
+	// OnToken streams every decoded piece as it is sampled; return false to stop.
+	// Pieces of a stop string may be streamed before the stop is detected.
+	// A piece holds whole UTF-8 characters only: a token that starts a
+	// character without finishing it streams "" and the rest comes with the
+	// token that completes it.
+	OnToken func(token int, piece string) bool
+
+	// RAG retrieves LIMPHA memories and injects them ahead of the question.
+	// Memory injects a prebuilt context instead (takes precedence over RAG).
+	RAG    *RAGConfig
+	Memory *MemoryContext
+
+	// NoStore skips the LIMPHA store (summaries, evaluation runs)
+	NoStore bool
+
+	// Grammar constrains output to a GBNF grammar (ParseGrammar, JSONSchemaGrammar).
+	// Generation ends when the grammar is complete and nothing more can follow.
+	Grammar *Grammar
+
+	// Beams > 1 switches to deterministic beam search (beam.go): temperature,
+	// top-k/top-p, seed and the AMK field are ignored. LengthPenalty is the
+	// exponent on length when ranking finished beams (0 = 1.0).
+	Beams         int
+	LengthPenalty float32
+
+	// MirostatTau > 0 switches to Mirostat v2 sampling (mirostat.go): output
+	// surprise is held near tau bits per token, scaled by the AMK temperature,
+	// instead of top-k/top-p. MirostatEta is the learning rate (0 = 0.1).
+	MirostatTau float32
+	MirostatEta float32
+
+	// DRY penalizes tokens that would extend a sequence already present in
+	// the prompt or the answer (dry.go). nil = off.
+	DRY *DRYConfig
+
+	// Samplers replaces the fixed top-p/top-k step with an ordered chain of
+	// stages (sampler.go, ParseSamplerChain). Not combinable with Mirostat.
+	Samplers SamplerChain
+
+	// Session continues a conversation (session.go): earlier turns, cached KV
+	// rows, its own AMK field and memory namespace. Turns are stored in the
+	// session's namespace, and RAG retrieves from it only.
+	Session *Session
+}
+
+// DefaultGenerateOptions returns the CLI defaults
+func DefaultGenerateOptions() GenerateOptions {
+	return GenerateOptions{
+		MaxTokens:   256,
+		Temperature: 0.9,
+		TopP:        0.9,
+		TopK:        50,
+	}
+}
+
 // Generate produces text from a prompt
 func (y *Yent) Generate(prompt string, maxTokens int, temperature, topP float32) (string, error) {
+	res, err := y.GenerateWithOptions(prompt, GenerateOptions{
+		MaxTokens:   maxTokens,
+		Temperature: temperature,
+		TopP:        topP,
+	})
+	if err != nil {
+		return "", err
+	}
+	return res.Text, nil
+}
+
+// GenerateLogprobs produces text plus the log probability of every generated
+// token and its topN most likely alternatives (topN=0: chosen token only)
+func (y *Yent) GenerateLogprobs(prompt string, maxTokens int, temperature, topP float32, topN int) (*GenerateResult, error) {
+	if topN < 0 {
+		topN = 0
+	}
+	return y.GenerateWithOptions(prompt, GenerateOptions{
+		MaxTokens:   maxTokens,
+		Temperature: temperature,
+		TopP:        topP,
+		Logprobs:    true,
+		TopLogprobs: topN,
+	})
+}
+
+// SetSeed reseeds the session RNG so a whole session can be replayed
+func (y *Yent) SetSeed(seed int64) {
 	y.mu.Lock()
 	defer y.mu.Unlock()
+	y.rng = rand.New(rand.NewSource(seed))
+}
+
+// GenerateWithOptions generates with explicit options
+func (y *Yent) GenerateWithOptions(prompt string, opts GenerateOptions) (*GenerateResult, error) {
+	return y.GenerateContext(context.Background(), prompt, opts)
+}
+
+// GenerateContext is the entry point behind every Generate variant.
+// Cancelling ctx stops generation at the next token: the partial result is
+// returned together with ctx.Err(), and the aborted turn is not stored.
+func (y *Yent) GenerateContext(ctx context.Context, prompt string, opts GenerateOptions) (*GenerateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return &GenerateResult{}, err
+	}
+
+	// Retrieval runs before the model lock: the model embedder needs it too
+	if opts.Memory == nil && opts.RAG != nil && y.limpha != nil {
+		cfg := *opts.RAG
+		if opts.Session != nil {
+			cfg.Namespace = opts.Session.Namespace
+		}
+		mc, err := y.BuildMemoryContext(prompt, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[rag] %v (answering without memory)\n", err)
+		} else {
+			opts.Memory = mc
+		}
+	}
+	return y.generate(ctx, prompt, opts)
+}
+
+// generate is the token loop
+func (y *Yent) generate(ctx context.Context, prompt string, opts GenerateOptions) (res *GenerateResult, err error) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	if y.fieldMu != nil {
+		y.fieldMu.Lock()
+		defer y.fieldMu.Unlock()
+	}
+
+	// A panic costs this answer, not the process (crash.go)
+	trace := &genTrace{phase: "prompt"}
+	defer func() {
+		if r := recover(); r != nil {
+			res, err = &GenerateResult{Memory: opts.Memory}, y.recoverCrash(r, prompt, opts, trace)
+		}
+	}()
 
 	if y.model == nil || y.tokenizer == nil {
-		return "", fmt.Errorf("yent not initialized")
+		return nil, fmt.Errorf("yent not initialized")
+	}
+	if opts.Beams > 1 && opts.Grammar != nil {
+		return nil, fmt.Errorf("beam search does not support grammar constraints")
+	}
+	if opts.MirostatTau > 0 && len(opts.Samplers) > 0 {
+		return nil, fmt.Errorf("mirostat and a sampler chain are mutually exclusive")
+	}
+	lang, restoreAlpha := y.routeLanguage(prompt, &opts)
+	defer restoreAlpha()
+	if opts.Alpha != nil {
+		saved := y.DeltaAlpha
+		y.DeltaAlpha = *opts.Alpha
+		defer func() { y.DeltaAlpha = saved }()
+	}
+	delta, err := y.deltaFor(opts.Delta)
+	if err != nil {
+		return nil, err
+	}
+	alpha := y.DeltaAlpha // this call's: routed, then opts.Alpha
+	defer y.model.useEmbedDelta(delta, alpha)()
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = 256
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = 50
+	}
+	maxTokens, temperature, topP := opts.MaxTokens, opts.Temperature, opts.TopP
+	if topP <= 0 {
+		topP = 1.0
+	}
+
+	// Per-call RNG: same seed, same draws — the session RNG is left untouched
+	if opts.Seed != 0 || opts.Deterministic {
+		sessionRng := y.rng
+		y.rng = rand.New(rand.NewSource(opts.Seed))
+		defer func() { y.rng = sessionRng }()
+	}
+	// A session runs on its own field; the shared one is parked meanwhile
+	sess := opts.Session
+	if sess != nil {
+		shared := y.amk.Snapshot()
+		sess.mu.Lock()
+		if sess.amk != nil {
+			y.amk.Restore(sess.amk)
+		}
+		sess.mu.Unlock()
+		defer func() {
+			own := y.amk.Snapshot()
+			sess.mu.Lock()
+			sess.amk = own
+			sess.mu.Unlock()
+			y.amk.Restore(shared)
+		}()
+	}
+	if (opts.AMKPreset != "" || len(opts.AMKCues) > 0) && sess == nil {
+		shared := y.amk.Snapshot()
+		defer y.amk.Restore(shared)
+	}
+	if opts.AMKPreset != "" {
+		if err := y.amk.ApplyPreset(opts.AMKPreset); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Deterministic {
+		y.amk.ResetField()
+	} else if sess != nil {
+		y.feedLimphaField(sess.Namespace) // memory moves the field (limphafield.go)
+	} else {
+		y.feedLimphaField("")
+	}
+	// Thermal pressure caps workers and velocity for this call (thermal.go)
+	if y.throttle != nil {
+		defer y.throttle.apply(y.amk)()
 	}
 
 	// Training format: ### Question: / ### Answer:
-	chatText := "### Question: " + prompt + "\n### Answer:"
+	memory, err := RenderMemory(opts.Memory)
+	if err != nil {
+		return nil, err
+	}
+	var transcript string
+	if sess != nil {
+		transcript = y.sessionTranscript(sess)
+	}
+	head := transcript + memory + "### Question: "
+	chatText := head + prompt + "\n### Answer:"
+
+	// Tokenize (no BOS for Qwen2.5); the prompt's bytes are literal unless
+	// ParseSpecial, the text is still encoded in one piece so BPE merges
+	// across the prompt's edges as in training
+	literalTo := len(head) + len(prompt)
+	if opts.ParseSpecial {
+		literalTo = 0
+	}
+	allTokens := y.tokenizer.encode(chatText, false, len(head), literalTo)
+	var heal string // text the first token must start with (heal.go)
+	if opts.TokenHealing && opts.Grammar == nil && opts.Beams <= 1 {
+		allTokens, heal = y.tokenizer.HealBoundary(allTokens)
+	}
+	trace.tokens = allTokens
 
-	// Tokenize (no BOS for Qwen2.5)
-	allTokens := y.tokenizer.Encode(chatText, false)
+	started := time.Now()
+	var sessID string
+	if sess != nil {
+		sessID = sess.ID
+	}
+	y.emit(EventGenerationStarted, sessID, func() map[string]interface{} {
+		return map[string]interface{}{"prompt_tokens": len(allTokens), "max_tokens": opts.MaxTokens}
+	})
 
 	y.model.Reset()
 
-	// Feed all prompt tokens through transformer
+	// Feed all prompt tokens through transformer; a session skips the
+	// prefix its cache already holds
 	pos := 0
-	for _, tok := range allTokens {
-		y.model.Forward(tok, pos)
-		pos++
+	var fed []int // tokens at positions [0, pos), while they line up
+	cacheValid := sess != nil
+	reused := 0
+	if sess != nil {
+		pos = y.resumeSession(sess, allTokens)
+		reused = pos
+		fed = append(fed, allTokens[:pos]...)
+	}
+	for _, tok := range allTokens[pos:] {
+		if err := ctx.Err(); err != nil {
+			if sess != nil {
+				y.finishSession(sess, fed, cacheValid, nil)
+			}
+			return &GenerateResult{Memory: opts.Memory}, err
+		}
 		if pos >= y.model.Config.SeqLen-1 {
-			break
+			if !y.ContextShift {
+				break
+			}
+			pos = y.shiftContext(pos)
+			cacheValid = false
 		}
+		trace.pos = pos
+		y.model.Forward(tok, pos)
+		pos++
+		fed = append(fed, tok)
 	}
+	trace.phase, trace.pos = "decode", pos
 
 	// Generate
 	var output []byte
@@ -245,13 +733,73 @@ func (y *Yent) Generate(prompt string, maxTokens int, temperature, topP float32)
 	graceLimit := 32
 	inGrace := false
 	recentTokens := make([]int, 0, y.RepWindow)
-	tokenDt := float32(0.05) // 50ms per token step — physics heartbeat
+	var history []int // prompt + answer, for DRY
+	if opts.DRY != nil {
+		history = append(history, allTokens...)
+	}
+	tokenDt := float32(0.05)       // 50ms per token step — physics heartbeat
+	watch := y.newAMKWatch(sessID) // kernel events (amkwatch.go)
+	var entropySum float32         // sampling entropy, recorded with the turn
+	entropyCount := 0
+	var tokens []TokenLogprob
+	dec := y.tokenizer.NewDecoder() // pieces end on whole characters (detok.go)
+
+	var cancelErr error
+
+	var gate *deltaGate
+	if delta != nil && alpha > 0 && !opts.DeltaAlways {
+		gate = y.newDeltaGate(y.DeltaGate, allTokens)
+	}
+	deltaSkipped := 0
+	tunneled := 0
+	amkTrace := newAMKTrace(opts.AMKTrace)
+	cues := newAMKCues(opts.AMKCues)
+	worms := newWormholes()
+	degen := newDegenSense(y.Degeneration)
+	amkBefore := y.amk.GetState() // the field the answer starts from
+
+	var gs *grammarState
+	if opts.Grammar != nil {
+		gs = newGrammarState(opts.Grammar)
+	}
+	var miro *mirostat
+	if opts.MirostatTau > 0 {
+		miro = newMirostat(opts.MirostatTau, opts.MirostatEta)
+	}
+
+	// Beam search decodes the whole answer first, then emits it
+	if opts.Beams > 1 {
+		var best []int
+		best, tokens, cancelErr = y.beamSearch(ctx, pos, opts)
+		for _, tok := range best {
+			piece := dec.Next(tok)
+			output = append(output, []byte(piece)...)
+			if cut := stopIndex(output, len(piece), opts.Stop); cut >= 0 {
+				output = output[:cut]
+				break
+			}
+			if opts.OnToken != nil && !opts.OnToken(tok, piece) {
+				break
+			}
+			y.emitToken(sessID, tok, piece)
+			genCount++
+		}
+		maxTokens, graceLimit = 0, 0
+		cacheValid = false // beams overwrite the rows past the prompt
+	}
 
 	for i := 0; i < maxTokens+graceLimit && len(output) < 4096; i++ {
+		if err := ctx.Err(); err != nil {
+			cancelErr = err
+			break
+		}
 		if i >= maxTokens && !inGrace {
 			inGrace = true
 		}
-		if inGrace {
+		if gs != nil && gs.exhausted() {
+			break
+		}
+		if inGrace && gs == nil {
 			if len(output) > 0 {
 				last := output[len(output)-1]
 				if last == '.' || last == '!' || last == '?' || last == '\n' {
@@ -260,136 +808,331 @@ func (y *Yent) Generate(prompt string, maxTokens int, temperature, topP float32)
 			}
 		}
 
-		// ═══ AMK: step physics ═══
+		// ═══ AMK: scripted cues, then step physics ═══
 		// The kernel breathes with each token
-		y.amk.Step(tokenDt)
+		cues.fire(y.amk, i, time.Since(started))
+		// One frame of the field per token, read lock-free below (amkframe.go)
+		st := y.amk.StepState(tokenDt)
+		// Past the gate a wormhole may open (wormhole.go)
+		wormhole := gs == nil && pos+1+wormholeTokens <= y.model.Config.SeqLen-2 && y.wormholeOpens(worms, i, st)
+		if wormhole || st.WormholeActive != 0 {
+			y.amk.SetWormholeActive(wormhole)
+			st.WormholeActive = 0
+			if wormhole {
+				st.WormholeActive = 1
+			}
+		}
+		watch.step(i, st)
+		// SAMPLER pack: the field may set the sampling step itself (samplerpack.go)
+		smp := st.Sampler()
+		tokenTopP, tokenTopK, repPenalty := smp.topP(topP), smp.topK(opts.TopK), smp.rep(y.RepPenalty)
 
 		// Delta Voice: apply multilingual delta to logits
 		// "from ariannamethod import Destiny"
-		if y.delta != nil && y.DeltaAlpha > 0 {
-			y.delta.ApplyToLogits(y.model.State.Logits, y.model.State.X, y.DeltaAlpha)
+		if delta != nil && alpha > 0 {
+			if gate.open() {
+				t := phaseStart()
+				delta.ApplyToLogits(y.model.State.Logits, y.model.State.X, alpha)
+				phaseEnd(phaseDelta, t)
+			} else {
+				deltaSkipped++
+			}
 		}
 
 		// ═══ AMK: suffering modulates logits ═══
 		// Pain and tension dampen extremes — the field feels
-		y.amk.ApplySufferingToLogits(y.model.State.Logits)
+		st.ApplySufferingToLogits(y.model.State.Logits)
 
-		// CJK suppression: only when delta is NOT active (English-only mode)
-		if y.DeltaAlpha == 0 {
-			for tok := range y.cjkTokens {
+		// Script suppression: by default only when delta is NOT active
+		// (English-only mode)
+		if y.suppressing() {
+			for tok := range y.suppressed {
 				y.model.State.Logits[tok] = -1e30
 			}
 		}
 
 		// Apply repetition penalty
-		if y.RepPenalty > 1.0 && len(recentTokens) > 0 {
+		if repPenalty > 1.0 && len(recentTokens) > 0 {
 			for _, tok := range recentTokens {
 				if tok >= 0 && tok < y.model.Config.VocabSize {
 					logit := y.model.State.Logits[tok]
 					if logit > 0 {
-						y.model.State.Logits[tok] = logit / y.RepPenalty
+						y.model.State.Logits[tok] = logit / repPenalty
 					} else {
-						y.model.State.Logits[tok] = logit * y.RepPenalty
+						y.model.State.Logits[tok] = logit * repPenalty
 					}
 				}
 			}
 		}
 
+		// DRY: penalize extending a sequence that already happened
+		if opts.DRY != nil {
+			y.applyDRY(*opts.DRY, history)
+		}
+
+		// Caller's logit bias, last word before sampling
+		for tok, bias := range opts.LogitBias {
+			if tok >= 0 && tok < y.model.Config.VocabSize {
+				y.model.State.Logits[tok] += bias
+			}
+		}
+		if heal != "" {
+			y.maskHealing(heal)
+		} else if gs == nil {
+			// ═══ AMK: tunneling past dissonance (tunnel.go) ═══
+			if k := y.tunnelSkip(st); k > 0 {
+				y.tunnel(k)
+				tunneled++
+			}
+		}
+
 		// ═══ AMK: temperature from velocity ═══
 		// NOMOVE=0.5, WALK=0.85, RUN=1.2, BACKWARD=base*0.7
 		// The kernel decides how hot the field burns
-		effectiveTemp := y.amk.GetTemperature()
+		effectiveTemp := st.Temperature()
 		if effectiveTemp <= 0 {
 			effectiveTemp = temperature // fallback to user-specified
 		}
 
-		// ═══ AMK: destiny bias → top-k modulation ═══
+		// ═══ AMK: destiny bias → top-k / top-p modulation ═══
 		// Higher destiny = more deterministic (fewer candidates)
-		destinyBias := y.amk.GetDestinyBias()
-		effectiveTopK := 50
-		if destinyBias > 0.5 {
-			// Destiny pulls toward most probable: shrink k
-			effectiveTopK = int(50.0 * (1.0 - destinyBias*0.8))
-			if effectiveTopK < 3 {
-				effectiveTopK = 3
-			}
+		effectiveTopK, effectiveTopP, minKeep := destinyCandidates(st.DestinyBias(), tokenTopK, tokenTopP)
+		if smp.MinP > 0 {
+			applyMinP(y.model.State.Logits[:y.model.Config.VocabSize], effectiveTemp, smp.MinP)
 		}
 
 		// Sample next token
-		var next int
-		if topP < 1.0 {
-			next = y.sampleTopP(effectiveTemp, topP)
-		} else {
-			next = y.sampleTopK(effectiveTemp, effectiveTopK)
+		sample := func() (int, float32) {
+			defer phaseEnd(phaseSampling, phaseStart())
+			switch {
+			case miro != nil:
+				// The field sets the target surprise, not the logit scale
+				return y.sampleMirostat(miro, effectiveTemp)
+			case len(opts.Samplers) > 0:
+				return y.sampleChain(opts.Samplers, SamplerStep{Temperature: effectiveTemp, TopK: effectiveTopK})
+			case tokenTopP < 1.0:
+				return y.sampleTopP(effectiveTemp, effectiveTopP, minKeep)
+			default:
+				return y.sampleTopK(effectiveTemp, effectiveTopK)
+			}
+		}
+		// Grammar: keep an allowed draw, otherwise mask and draw again
+		next, entropy, ok := y.drawAllowed(gs, miro, sample)
+		if !ok {
+			break
+		}
+		entropySum += entropy
+		entropyCount++
+
+		// ═══ AMK: loops and flat streaks hurt (degeneration.go) ═══
+		if pain, tension := degen.step(next, entropy, effectiveTemp > 0); pain > 0 || tension > 0 {
+			y.amk.AddSuffering(pain, tension)
 		}
 
+		// Logprobs over the final (delta + suffering + penalty + bias) logits, before temperature
+		if opts.Logprobs {
+			tokens = append(tokens, y.tokenLogprob(next, opts.TopLogprobs))
+		}
+
+		gate.push(next)
 		recentTokens = append(recentTokens, next)
 		if len(recentTokens) > y.RepWindow {
 			recentTokens = recentTokens[1:]
 		}
+		trace.tokens = recentTokens
+		if opts.DRY != nil {
+			history = append(history, next)
+		}
 
-		// Stop on EOS or im_end
-		if next == y.tokenizer.EosID || next == y.imEndID {
+		// Stop on EOS, im_end or an added stop token
+		if y.isStop(next) {
 			break
 		}
 
-		piece := y.tokenizer.DecodeToken(next)
+		raw := y.tokenizer.DecodeToken(next)
+		if heal != "" {
+			raw, heal = strings.TrimPrefix(raw, heal), "" // already in the prompt
+		}
+		if gs != nil {
+			gs.accept([]byte(raw))
+		}
+		piece := dec.push(raw)
 		output = append(output, []byte(piece)...)
+		if amkTrace != nil {
+			amkTrace.add(AMKTracePoint{Step: i, Token: next, Text: piece, AMState: y.amk.GetState()})
+		}
+
+		if cut := stopIndex(output, len(piece), opts.Stop); cut >= 0 {
+			output = output[:cut]
+			break
+		}
+		if opts.OnToken != nil && !opts.OnToken(next, piece) {
+			break
+		}
+		y.emitToken(sessID, next, piece)
 
+		if pos >= y.model.Config.SeqLen {
+			if !y.ContextShift {
+				break
+			}
+			pos = y.shiftContext(pos)
+			cacheValid = false
+		}
+		trace.pos = pos
 		y.model.Forward(next, pos)
 		pos++
 		genCount++
+		if cacheValid {
+			fed = append(fed, next)
+		}
 
-		if pos >= y.model.Config.SeqLen {
-			break
+		// ═══ AMK: the wormhole splices its jump in after the token ═══
+		if wormhole {
+			query := prompt + " " + string(output[max(0, len(output)-200):])
+			namespace := ""
+			if sess != nil {
+				namespace = sess.Namespace
+			}
+			splice, jump := y.wormholeSplice(worms, i, query, prompt, namespace)
+			for _, tok := range splice {
+				y.model.Forward(tok, pos)
+				pos++
+			}
+			worms.add(jump)
+			cacheValid = false // the cache holds what the session never said
 		}
 	}
 
 	result := string(output)
+	amkAfter := y.amk.GetState()
+
+	if sess != nil {
+		var turn *SessionTurn
+		if cancelErr == nil {
+			turn = &SessionTurn{Prompt: prompt, Response: result}
+		}
+		y.finishSession(sess, fed, cacheValid, turn)
+	}
 
 	// ═══ LIMPHA: auto-store every conversation ═══
 	// No commands. No human intervention. Yent remembers.
-	if y.limpha != nil {
-		s := y.amk.GetState()
-		go y.limpha.Store(prompt, result, LimphaState{
+	if y.limpha != nil && !opts.NoStore && cancelErr == nil {
+		s := amkAfter
+		var meanEntropy float32
+		if entropyCount > 0 {
+			meanEntropy = entropySum / float32(entropyCount)
+		}
+		var namespace string
+		if sess != nil {
+			namespace = sess.Namespace
+		}
+		state := LimphaState{
 			Temperature: s.EffectiveTemp,
 			Destiny:     s.Destiny,
 			Pain:        s.Pain,
 			Tension:     s.Tension,
 			Debt:        s.Debt,
 			Velocity:    s.VelocityMode,
-			Alpha:       y.DeltaAlpha,
-		})
+			Alpha:       alpha,
+			Entropy:     meanEntropy,
+			Language:    lang,
+			AMK:         &LimphaAMK{Before: amkBefore, After: amkAfter},
+		}
+		limpha, events := y.limpha, y.events
+		go func() {
+			if limpha.StoreIn(namespace, prompt, result, state) == nil && events.Wants(EventMemoryStored) {
+				events.Publish(Event{Kind: EventMemoryStored, Session: sessID, Data: map[string]interface{}{
+					"namespace": namespace, "prompt_chars": len(prompt), "response_chars": len(result),
+				}})
+			}
+		}()
 	}
 
-	return result, nil
+	y.emit(EventGenerationFinished, sessID, func() map[string]interface{} {
+		return map[string]interface{}{
+			"tokens": genCount, "chars": len(result), "cancelled": cancelErr != nil,
+			"duration_ms": time.Since(started).Milliseconds(), "language": lang,
+		}
+	})
+
+	return &GenerateResult{Text: result, Tokens: tokens, Memory: opts.Memory, Language: lang, DeltaSkipped: deltaSkipped, Reused: reused, Tunneled: tunneled, Degenerate: degen.loops(), Wormholes: worms.jumps, AMKBefore: amkBefore, AMKAfter: amkAfter, AMKTrace: amkTrace.ordered()}, cancelErr
 }
 
-// sampleTopK samples from top-k logits
-func (y *Yent) sampleTopK(temp float32, topK int) int {
+// stopIndex returns where the earliest stop string starts in output, or -1.
+// Only the region the last piece could have completed is searched.
+func stopIndex(output []byte, pieceLen int, stops []string) int {
+	cut := -1
+	for _, stop := range stops {
+		if stop == "" {
+			continue
+		}
+		from := len(output) - pieceLen - len(stop) + 1
+		if from < 0 {
+			from = 0
+		}
+		if i := bytes.Index(output[from:], []byte(stop)); i >= 0 && (cut < 0 || from+i < cut) {
+			cut = from + i
+		}
+	}
+	return cut
+}
+
+// shiftContext frees half of the non-sink context so generation can continue
+func (y *Yent) shiftContext(pos int) int {
+	nKeep := y.SinkTokens
+	if nKeep >= pos/2 {
+		nKeep = 0
+	}
+	nDiscard := (pos - nKeep) / 2
+	newPos := y.model.ShiftContext(pos, nKeep, nDiscard)
+	fmt.Printf("[yent] context full at %d — kept %d sinks, shifted out %d tokens\n", pos, nKeep, nDiscard)
+	y.emit(EventContextShift, "", func() map[string]interface{} {
+		return map[string]interface{}{"pos": pos, "kept": nKeep, "discarded": nDiscard}
+	})
+	return newPos
+}
+
+// emitToken publishes one sampled piece
+func (y *Yent) emitToken(session string, tok int, piece string) {
+	y.emit(EventToken, session, func() map[string]interface{} {
+		return map[string]interface{}{"token": tok, "piece": piece}
+	})
+}
+
+// destinyCandidates narrows top-k and top-p for a destiny bias and returns
+// the nucleus floor. Past 0.5, destiny pulls toward the most probable: k and
+// the nucleus shrink by the same factor, never below three candidates.
+func destinyCandidates(destinyBias float32, topK int, topP float32) (int, float32, int) {
+	if destinyBias <= 0.5 {
+		return topK, topP, 1
+	}
+	shrink := 1.0 - destinyBias*0.8
+	return max(int(float32(topK)*shrink), 3), topP * shrink, 3
+}
+
+// sampleTopK samples from top-k logits.
+// Returns the token and the entropy (nats) of the distribution it was drawn from.
+func (y *Yent) sampleTopK(temp float32, topK int) (int, float32) {
 	logits := y.model.State.Logits
 	vocab := y.model.Config.VocabSize
 
 	if temp <= 0 {
-		return argmax(logits, vocab)
+		return argmax(logits, vocab), 0
 	}
 	if topK > vocab {
 		topK = vocab
 	}
 
 	// Find top-k indices
-	type idxVal struct {
-		idx int
-		val float32
-	}
-	top := make([]idxVal, topK)
+	scratch := &y.model.State.scratch
+	top := scratch.scoreBuf(topK)
 	for i := 0; i < topK; i++ {
-		top[i] = idxVal{-1, -1e30}
+		top[i] = tokenScore{-1, -1e30}
 	}
 
 	for i := 0; i < vocab; i++ {
 		if logits[i] > top[topK-1].val {
-			top[topK-1] = idxVal{i, logits[i]}
+			top[topK-1] = tokenScore{i, logits[i]}
 			for j := topK - 1; j > 0 && top[j].val > top[j-1].val; j-- {
 				top[j], top[j-1] = top[j-1], top[j]
 			}
@@ -398,35 +1141,40 @@ func (y *Yent) sampleTopK(temp float32, topK int) int {
 
 	// Softmax over top-k
 	maxVal := top[0].val
-	probs := make([]float32, topK)
+	probs := scratch.weightBuf(topK)
 	var sum float32
 	for i := 0; i < topK; i++ {
 		if top[i].idx < 0 {
+			clear(probs[i:])
 			break
 		}
 		probs[i] = float32(math.Exp(float64((top[i].val - maxVal) / temp)))
 		sum += probs[i]
 	}
 
+	entropy := distEntropy(probs, sum)
+
 	// Sample
 	r := y.rng.Float32() * sum
 	var cdf float32
 	for i := 0; i < topK; i++ {
 		cdf += probs[i]
 		if r <= cdf {
-			return top[i].idx
+			return top[i].idx, entropy
 		}
 	}
-	return top[0].idx
+	return top[0].idx, entropy
 }
 
-// sampleTopP samples using nucleus (top-p) sampling
-func (y *Yent) sampleTopP(temp, topP float32) int {
+// sampleTopP samples using nucleus (top-p) sampling; the nucleus holds at
+// least minKeep candidates.
+// Returns the token and the entropy (nats) of the full tempered distribution.
+func (y *Yent) sampleTopP(temp, topP float32, minKeep int) (int, float32) {
 	logits := y.model.State.Logits
 	vocab := y.model.Config.VocabSize
 
 	if temp <= 0 {
-		return argmax(logits, vocab)
+		return argmax(logits, vocab), 0
 	}
 
 	// Apply temperature and compute softmax
@@ -437,46 +1185,59 @@ func (y *Yent) sampleTopP(temp, topP float32) int {
 		}
 	}
 
-	type idxProb struct {
-		idx  int
-		prob float32
-	}
-	candidates := make([]idxProb, vocab)
+	candidates := y.model.State.scratch.scoreBuf(vocab)
 	var sum float32
 	for i := 0; i < vocab; i++ {
 		p := float32(math.Exp(float64((logits[i] - maxVal) / temp)))
-		candidates[i] = idxProb{i, p}
+		candidates[i] = tokenScore{i, p}
 		sum += p
 	}
 
 	// Normalize
 	invSum := float32(1.0) / sum
+	var entropy float32
 	for i := range candidates {
-		candidates[i].prob *= invSum
+		candidates[i].val *= invSum
+		if p := candidates[i].val; p > 0 {
+			entropy -= p * float32(math.Log(float64(p)))
+		}
 	}
 
 	// Sort by probability descending
-	sort.Slice(candidates, func(i, j int) bool {
-		return candidates[i].prob > candidates[j].prob
-	})
+	sortScoresDesc(candidates)
 
 	// Find nucleus and sample
 	var cumsum float32
 	for i := range candidates {
-		cumsum += candidates[i].prob
-		if cumsum >= topP {
+		cumsum += candidates[i].val
+		if cumsum >= topP && i+1 >= minKeep {
 			r := y.rng.Float32() * cumsum
 			var cdf float32
 			for j := 0; j <= i; j++ {
-				cdf += candidates[j].prob
+				cdf += candidates[j].val
 				if r <= cdf {
-					return candidates[j].idx
+					return candidates[j].idx, entropy
 				}
 			}
-			return candidates[0].idx
+			return candidates[0].idx, entropy
+		}
+	}
+	return candidates[0].idx, entropy
+}
+
+// distEntropy returns the entropy (nats) of unnormalized weights with total sum
+func distEntropy(weights []float32, sum float32) float32 {
+	if sum <= 0 {
+		return 0
+	}
+	var h float64
+	for _, w := range weights {
+		if w > 0 {
+			p := float64(w / sum)
+			h -= p * math.Log(p)
 		}
 	}
-	return candidates[0].idx
+	return float32(h)
 }
 
 func argmax(logits []float32, n int) int {
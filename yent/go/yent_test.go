@@ -0,0 +1,101 @@
+package yent
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// logitsYent is a Yent with only logits and a seeded RNG, for the samplers
+func logitsYent(logits ...float32) *Yent {
+	return &Yent{
+		model: &LlamaModel{
+			Config: LlamaConfig{VocabSize: len(logits)},
+			State:  LlamaState{Logits: slices.Clone(logits)},
+		},
+		rng: rand.New(rand.NewSource(1)),
+	}
+}
+
+// TestStopIndex feeds output piece by piece, as the token loop does, and
+// checks where the first stop is found
+func TestStopIndex(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		pieces []string
+		stops  []string
+		want   int // cut in the full output (-1 = never stops)
+		at     int // piece that completes it
+	}{
+		{"none", []string{"hel", "lo"}, []string{"\n\n"}, -1, 0},
+		{"empty stop ignored", []string{"a", "b"}, []string{""}, -1, 0},
+		{"inside one piece", []string{"one", " END two"}, []string{"END"}, 4, 1},
+		{"split across pieces", []string{"foo <", "/", "s> bar"}, []string{"</s>"}, 4, 2},
+		{"starts in the first piece", []string{"ab", "c"}, []string{"abc"}, 0, 1},
+		{"several stops, earliest wins", []string{"x: ", "a.b!"}, []string{"!", "."}, 4, 1},
+		{"earliest starts in an earlier piece", []string{"ask", "ed", "?"}, []string{"?", "asked?"}, 0, 2},
+		{"first completed wins", []string{"a.", "b!"}, []string{"!", "a.b!", "."}, 1, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out []byte
+			for i, p := range tc.pieces {
+				out = append(out, p...)
+				if cut := stopIndex(out, len(p), tc.stops); cut >= 0 {
+					if cut != tc.want || i != tc.at {
+						t.Errorf("cut %d at piece %d, want %d at piece %d", cut, i, tc.want, tc.at)
+					}
+					return
+				}
+			}
+			if tc.want >= 0 {
+				t.Errorf("no stop found, want %d at piece %d", tc.want, tc.at)
+			}
+		})
+	}
+}
+
+// TestSampleTopPMinKeep checks that the nucleus holds minKeep candidates
+// even when the first one alone covers top-p, and no more
+func TestSampleTopPMinKeep(t *testing.T) {
+	// p ≈ 0.91, 0.045, 0.045, then crumbs
+	y := logitsYent(3, 0, 0, -10, -10, -10)
+	drawn := func(minKeep int) map[int]int {
+		counts := map[int]int{}
+		for i := 0; i < 2000; i++ {
+			tok, _ := y.sampleTopP(1, 0.5, minKeep)
+			counts[tok]++
+		}
+		return counts
+	}
+	if got := drawn(1); len(got) != 1 || got[0] != 2000 {
+		t.Errorf("minKeep 1: %v, want only token 0", got)
+	}
+	got := drawn(3)
+	if got[1] == 0 || got[2] == 0 || got[0]+got[1]+got[2] != 2000 {
+		t.Errorf("minKeep 3: %v, want tokens 0-2 only, each drawn", got)
+	}
+}
+
+// TestDestinyCandidates checks that destiny past 0.5 shrinks top-k and
+// top-p by one factor, with a floor of three candidates
+func TestDestinyCandidates(t *testing.T) {
+	for _, tc := range []struct {
+		bias           float32
+		topK           int
+		topP           float32
+		wantK, wantMin int
+		wantP          float32
+	}{
+		{0, 50, 0.9, 50, 1, 0.9},
+		{0.5, 50, 0.9, 50, 1, 0.9},
+		{0.75, 64, 0.9, 25, 3, 0.36},
+		{1, 64, 0.9, 12, 3, 0.18},
+		{1, 10, 0.9, 3, 3, 0.18}, // k floor
+	} {
+		k, p, minKeep := destinyCandidates(tc.bias, tc.topK, tc.topP)
+		if k != tc.wantK || minKeep != tc.wantMin || p < tc.wantP-1e-5 || p > tc.wantP+1e-5 {
+			t.Errorf("destiny %.2f, k %d, p %.2f: got %d, %.3f, %d; want %d, %.3f, %d",
+				tc.bias, tc.topK, tc.topP, k, p, minKeep, tc.wantK, tc.wantP, tc.wantMin)
+		}
+	}
+}